@@ -0,0 +1,100 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/thiagozs/go-exchange/internal/config"
+	"github.com/thiagozs/go-exchange/internal/logger"
+)
+
+var configOpts struct {
+	output       string
+	validateOnly bool
+	summary      bool
+}
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Print the effective configuration with secrets redacted",
+	Long: `Load the configuration from the environment and print the effective
+values by variable, as YAML (default) or JSON. Credentials are shown as
+"***(N)", N being their length. With --validate-only nothing is printed on
+success and every validation error is reported, exiting 1. --summary prints
+the startup summary serve logs instead, as JSON.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if configOpts.output != "yaml" && configOpts.output != "json" {
+			return &exitError{code: exitBadArgs, err: fmt.Errorf("--output must be yaml or json, got %q", configOpts.output)}
+		}
+		cmd.SilenceUsage = true
+		cfg, err := loadConfig(cmd)
+		if err != nil {
+			return fmt.Errorf("invalid configuration:\n%w", err)
+		}
+		if configOpts.validateOnly {
+			return nil
+		}
+		if configOpts.summary {
+			enc := json.NewEncoder(cmd.OutOrStdout())
+			enc.SetIndent("", "  ")
+			return enc.Encode(startupSummary(cfg, logger.PlannedExporters(cfg)))
+		}
+		return writeSettings(cmd.OutOrStdout(), cfg.Redacted().Settings(), configOpts.output)
+	},
+}
+
+// writeSettings prints settings in declaration order. The YAML form writes
+// each value as JSON, which YAML accepts as flow style.
+func writeSettings(w io.Writer, settings []config.Setting, format string) error {
+	var buf bytes.Buffer
+	if format == "json" {
+		buf.WriteString("{\n")
+	}
+	for i, s := range settings {
+		v, err := json.Marshal(s.Value)
+		if err != nil {
+			return fmt.Errorf("%s: %w", s.Env, err)
+		}
+		if format == "json" {
+			sep := ","
+			if i == len(settings)-1 {
+				sep = ""
+			}
+			fmt.Fprintf(&buf, "  %q: %s%s\n", s.Env, v, sep)
+			continue
+		}
+		fmt.Fprintf(&buf, "%s: %s\n", s.Env, v)
+	}
+	if format == "json" {
+		buf.WriteString("}\n")
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// settingsLine renders settings on one line as KEY=value pairs, values in
+// JSON, for logging.
+func settingsLine(settings []config.Setting) string {
+	var b strings.Builder
+	for i, s := range settings {
+		v, _ := json.Marshal(s.Value)
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		fmt.Fprintf(&b, "%s=%s", s.Env, v)
+	}
+	return b.String()
+}
+
+func init() {
+	f := configCmd.Flags()
+	f.StringVarP(&configOpts.output, "output", "o", "yaml", "output format: yaml or json")
+	f.BoolVar(&configOpts.validateOnly, "validate-only", false, "only validate, exiting 1 with all errors")
+	f.BoolVar(&configOpts.summary, "summary", false, "print the startup summary as JSON instead")
+	rootCmd.AddCommand(configCmd)
+}
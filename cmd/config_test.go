@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/thiagozs/go-exchange/internal/config"
+)
+
+func TestWriteSettings(t *testing.T) {
+	settings := []config.Setting{
+		{Env: "HTTP_ADDR", Value: ":8080"},
+		{Env: "REDIS_PASSWORD", Value: "***(7)"},
+		{Env: "AGGREGATE_SOURCES", Value: []string{"bcb", "static"}},
+	}
+
+	var yaml bytes.Buffer
+	if err := writeSettings(&yaml, settings, "yaml"); err != nil {
+		t.Fatalf("yaml: %v", err)
+	}
+	want := "HTTP_ADDR: \":8080\"\nREDIS_PASSWORD: \"***(7)\"\nAGGREGATE_SOURCES: [\"bcb\",\"static\"]\n"
+	if yaml.String() != want {
+		t.Fatalf("unexpected yaml:\n%s", yaml.String())
+	}
+
+	var out bytes.Buffer
+	if err := writeSettings(&out, settings, "json"); err != nil {
+		t.Fatalf("json: %v", err)
+	}
+	var decoded map[string]any
+	if err := json.Unmarshal(out.Bytes(), &decoded); err != nil {
+		t.Fatalf("invalid json: %v\n%s", err, out.String())
+	}
+	if decoded["REDIS_PASSWORD"] != "***(7)" || len(decoded) != 3 {
+		t.Fatalf("unexpected json %v", decoded)
+	}
+}
@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/thiagozs/go-exchange/internal/logger"
+	"github.com/thiagozs/go-exchange/internal/server"
+)
+
+// Exit codes of the convert subcommand.
+const (
+	exitBadArgs       = 2
+	exitProviderError = 3
+)
+
+var convertOpts struct {
+	from    string
+	to      string
+	amount  string
+	unit    string
+	output  string
+	timeout time.Duration
+}
+
+var convertCmd = &cobra.Command{
+	Use:   "convert",
+	Short: "Convert an amount once and print the result",
+	Long: `Convert an amount with the configured provider and fees, without
+starting the HTTP server. Set CACHE_BACKEND=memory to run without Redis.
+
+Exit codes: 0 success, 2 bad arguments, 3 provider error.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if convertOpts.output != "text" && convertOpts.output != "json" {
+			return &exitError{code: exitBadArgs, err: fmt.Errorf("--output must be text or json, got %q", convertOpts.output)}
+		}
+		// past flag validation a failure is not a usage error
+		cmd.SilenceUsage = true
+		cfg, err := loadConfig(cmd)
+		if err != nil {
+			return err
+		}
+		// logs go to stderr so stdout carries only the result
+		opts := logOptions(cfg)
+		opts.Out = os.Stderr
+		lg := logger.New(opts)
+
+		s, err := server.New(cfg, lg)
+		if err != nil {
+			return err
+		}
+		ctx, cancel := context.WithTimeout(cmd.Context(), convertOpts.timeout)
+		defer cancel()
+
+		b, err := s.Convert(ctx, convertOpts.from, convertOpts.to, convertOpts.amount, convertOpts.unit)
+		if err != nil {
+			var cerr *server.ConvertError
+			if errors.As(err, &cerr) && cerr.Input {
+				return &exitError{code: exitBadArgs, err: err}
+			}
+			return &exitError{code: exitProviderError, err: err}
+		}
+		if convertOpts.output == "json" {
+			_, err = fmt.Fprintf(cmd.OutOrStdout(), "%s\n", b)
+			return err
+		}
+		return printConversion(cmd.OutOrStdout(), b)
+	},
+}
+
+// conversionText holds the /convert fields shown by the text output.
+type conversionText struct {
+	From           string  `json:"from"`
+	To             string  `json:"to"`
+	AmountCents    int64   `json:"amount_cents"`
+	ResultCents    int64   `json:"result_cents"`
+	FeePercent     float64 `json:"fee_percent"`
+	FeeAmountCents int64   `json:"fee_amount_cents"`
+	NetResultCents int64   `json:"net_result_cents"`
+	Rate           float64 `json:"rate"`
+	RateTimestamp  string  `json:"rate_timestamp"`
+	Source         string  `json:"source"`
+}
+
+func printConversion(w io.Writer, b []byte) error {
+	var c conversionText
+	if err := json.Unmarshal(b, &c); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "%s %s = %s %s\n", formatCents(c.AmountCents), c.From, formatCents(c.ResultCents), c.To)
+	fmt.Fprintf(w, "fee: %s %s (%g%%)\n", formatCents(c.FeeAmountCents), c.To, c.FeePercent*100)
+	fmt.Fprintf(w, "net: %s %s\n", formatCents(c.NetResultCents), c.To)
+	if c.Rate != 0 {
+		fmt.Fprintf(w, "rate: %g", c.Rate)
+		if c.Source != "" {
+			fmt.Fprintf(w, " (%s", c.Source)
+			if c.RateTimestamp != "" {
+				fmt.Fprintf(w, ", %s", c.RateTimestamp)
+			}
+			fmt.Fprint(w, ")")
+		}
+		fmt.Fprintln(w)
+	}
+	return nil
+}
+
+// formatCents renders cents as a decimal amount, e.g. 50325 => 503.25.
+func formatCents(c int64) string {
+	sign := ""
+	if c < 0 {
+		sign, c = "-", -c
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, c/100, c%100)
+}
+
+func init() {
+	f := convertCmd.Flags()
+	f.StringVar(&convertOpts.from, "from", "", "source currency, e.g. USD")
+	f.StringVar(&convertOpts.to, "to", "", "target currency, e.g. BRL")
+	f.StringVar(&convertOpts.amount, "amount", "", "amount in cents (1000) or decimal units (10.00)")
+	f.StringVar(&convertOpts.unit, "unit", "", "unit of --amount: cents or major; by default its format decides")
+	f.StringVarP(&convertOpts.output, "output", "o", "text", "output format: text or json")
+	f.DurationVar(&convertOpts.timeout, "timeout", 15*time.Second, "conversion timeout")
+	rootCmd.AddCommand(convertCmd)
+}
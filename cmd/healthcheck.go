@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var healthcheckOpts struct {
+	url     string
+	timeout time.Duration
+}
+
+var healthcheckCmd = &cobra.Command{
+	Use:   "healthcheck",
+	Short: "Check that the local server is ready",
+	Long: `GET the readiness endpoint and exit 0 on 200, 1 otherwise. Meant for
+container HEALTHCHECKs in images without curl; the URL defaults to /ready on
+HTTP_ADDR.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		url := healthcheckOpts.url
+		if url == "" {
+			cfg, err := loadConfig(cmd)
+			if err != nil {
+				return err
+			}
+			url = healthcheckURL(cfg.HTTPAddr)
+		}
+		ctx, cancel := context.WithTimeout(cmd.Context(), healthcheckOpts.timeout)
+		defer cancel()
+		return healthcheck(ctx, url)
+	},
+}
+
+// healthcheckURL returns the /ready URL of a server listening on addr. An
+// empty or wildcard host is reached on localhost.
+func healthcheckURL(addr string) string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		host, port = addr, "80"
+	}
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "localhost"
+	}
+	return "http://" + net.JoinHostPort(host, port) + "/ready"
+}
+
+// healthcheck GETs url, following at most one redirect, and fails unless the
+// response is 200.
+func healthcheck(ctx context.Context, url string) error {
+	client := &http.Client{CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		if len(via) > 1 {
+			return errors.New("stopped after 1 redirect")
+		}
+		return nil
+	}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("healthcheck %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("healthcheck %s: status %d: %s", url, resp.StatusCode, body)
+	}
+	return nil
+}
+
+func init() {
+	f := healthcheckCmd.Flags()
+	f.StringVar(&healthcheckOpts.url, "url", "", "readiness URL (default http://localhost:<HTTP_ADDR port>/ready)")
+	f.DurationVar(&healthcheckOpts.timeout, "timeout", 2*time.Second, "request timeout")
+	rootCmd.AddCommand(healthcheckCmd)
+}
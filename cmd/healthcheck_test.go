@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHealthcheck(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ready":
+			w.WriteHeader(http.StatusOK)
+		case "/moved":
+			http.Redirect(w, r, "/ready", http.StatusFound)
+		case "/loop":
+			http.Redirect(w, r, "/moved", http.StatusFound)
+		case "/down":
+			http.Error(w, "redis unavailable", http.StatusServiceUnavailable)
+		case "/slow":
+			select {
+			case <-r.Context().Done():
+			case <-time.After(time.Second):
+			}
+		}
+	}))
+	defer srv.Close()
+
+	cases := []struct {
+		path string
+		err  string
+	}{
+		{path: "/ready"},
+		{path: "/moved"},
+		{path: "/loop", err: "stopped after 1 redirect"},
+		{path: "/down", err: "status 503: redis unavailable"},
+	}
+	for _, tc := range cases {
+		err := healthcheck(context.Background(), srv.URL+tc.path)
+		if tc.err == "" && err != nil {
+			t.Fatalf("%s: unexpected error: %v", tc.path, err)
+		}
+		if tc.err != "" && (err == nil || !strings.Contains(err.Error(), tc.err)) {
+			t.Fatalf("%s: expected %q, got %v", tc.path, tc.err, err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := healthcheck(ctx, srv.URL+"/slow"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected a timeout, got %v", err)
+	}
+}
+
+func TestHealthcheckURL(t *testing.T) {
+	for addr, want := range map[string]string{
+		":8080":          "http://localhost:8080/ready",
+		"0.0.0.0:9000":   "http://localhost:9000/ready",
+		"127.0.0.1:8080": "http://127.0.0.1:8080/ready",
+		"[::]:8080":      "http://localhost:8080/ready",
+	} {
+		if got := healthcheckURL(addr); got != want {
+			t.Errorf("healthcheckURL(%q) = %s, want %s", addr, got, want)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+//go:build !windows
+
+package cmd
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/sirupsen/logrus"
+	"github.com/thiagozs/go-exchange/internal/logger"
+)
+
+// watchLogLevelSignal toggles the log level between info and debug on every
+// SIGUSR1 until ctx is done.
+func watchLogLevelSignal(ctx context.Context, lg *logger.Logger) {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGUSR1)
+	go func() {
+		defer signal.Stop(sigCh)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-sigCh:
+				toggleDebug(lg)
+			}
+		}
+	}()
+}
+
+// toggleDebug switches to debug, or back to info when already at debug.
+func toggleDebug(lg *logger.Logger) {
+	next := logrus.DebugLevel.String()
+	if lg.Level() == next {
+		next = logrus.InfoLevel.String()
+	}
+	_ = lg.SetLevel(next)
+}
@@ -0,0 +1,11 @@
+package cmd
+
+import (
+	"context"
+
+	"github.com/thiagozs/go-exchange/internal/logger"
+)
+
+// watchLogLevelSignal is a no-op: Windows has no SIGUSR1. Use
+// PUT /admin/loglevel instead.
+func watchLogLevelSignal(context.Context, *logger.Logger) {}
@@ -1,10 +1,20 @@
 package cmd
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net"
+	"slices"
+
 	"github.com/spf13/cobra"
 	"github.com/thiagozs/go-exchange/internal/config"
+	"github.com/thiagozs/go-exchange/internal/grpcserver"
 	"github.com/thiagozs/go-exchange/internal/logger"
 	"github.com/thiagozs/go-exchange/internal/server"
+	"github.com/thiagozs/go-exchange/internal/version"
 )
 
 var rootCmd = &cobra.Command{
@@ -16,51 +26,171 @@ var serveCmd = &cobra.Command{
 	Use:   "serve",
 	Short: "Start HTTP server",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		cfg, err := config.Load()
+		cfg, err := loadConfig(cmd)
 		if err != nil {
 			return err
 		}
+		if err := applyServeFlags(cmd, cfg); err != nil {
+			return &exitError{code: exitBadArgs, err: err}
+		}
+		return runServe(cmd, cfg)
+	},
+}
 
-		// initialize logger
-		lg := logger.New(logger.Options{Format: cfg.LogFormat, Level: cfg.LogLevel, Name: cfg.AppName})
+// runServe starts the server with cfg; tests replace it.
+var runServe = serve
 
-		// register telemetry hooks / formatter helpers
-		if err := lg.SetupTelemetry(cmd.Context(), cfg); err != nil {
-			lg.WithContext(cmd.Context()).Errorf("setup telemetry error: %v", err)
-		}
+func serve(cmd *cobra.Command, cfg *config.Config) error {
+	// initialize logger
+	lg := logger.New(logOptions(cfg))
+	lg.WithContext(cmd.Context()).Debugf("effective configuration: %s", settingsLine(cfg.Redacted().Settings()))
 
-		// init OTLP (traces/metrics/logs) if collector configured
-		//var shutdown func(context.Context) error
+	// register telemetry hooks / formatter helpers
+	if err := lg.SetupTelemetry(cmd.Context(), cfg); err != nil {
+		lg.WithContext(cmd.Context()).Errorf("setup telemetry error: %v", err)
+	}
 
-		shutdown, infos, err := lg.SetupOTel(cmd.Context(), cfg)
-		if err != nil {
-			lg.WithContext(cmd.Context()).Warnf("failed to setup otel: %v", err)
-		} else {
-			if len(infos) > 0 {
-				for _, info := range infos {
-					lg.WithContext(cmd.Context()).Infof("OTEL exporter: type=%s endpoint=%s insecure=%t headers=%v", info.Type, info.Endpoint, info.Insecure, info.Headers)
-				}
+	// init OTLP (traces/metrics/logs) if collector configured
+	//var shutdown func(context.Context) error
+
+	shutdown, infos, err := lg.SetupOTel(cmd.Context(), cfg)
+	if err != nil {
+		lg.WithContext(cmd.Context()).Warnf("failed to setup otel: %v", err)
+	} else {
+		if len(infos) > 0 {
+			for _, info := range infos {
+				lg.WithContext(cmd.Context()).Info(exporterLogLine(info))
 			}
 		}
+	}
+	s, err := server.New(cfg, lg)
+	if err != nil {
 		if shutdown != nil {
-			defer func() { _ = shutdown(cmd.Context()) }()
+			_ = shutdown(cmd.Context())
 		}
+		return err
+	}
+	// added before the gRPC API and the pollers of Run, so the exporters stop
+	// after them and flush what they log while stopping
+	if shutdown != nil {
+		s.Lifecycle().Add("otel", nil, shutdown)
+	}
+	// one entry with what this instance is configured to do, also on /status
+	sum := s.SetStartupSummary(startupSummary(cfg, infos))
+	lg.With(summaryFields(sum)).WithContext(cmd.Context()).Infof("startup summary")
+	// SIGUSR1 toggles debug logging without a restart
+	sigCtx, stopSig := context.WithCancel(cmd.Context())
+	defer stopSig()
+	watchLogLevelSignal(sigCtx, lg)
+
+	// gRPC API next to the HTTP server; it stops on the same signal
+	if cfg.GRPCAddr != "" {
+		gs := grpcserver.New(cfg, s.Exchange(), lg)
+		s.Lifecycle().Add("grpc", func(context.Context) error {
+			lis, err := net.Listen("tcp", cfg.GRPCAddr)
+			if err != nil {
+				return fmt.Errorf("gRPC listen on %s: %w", cfg.GRPCAddr, err)
+			}
+			go func() {
+				if err := gs.Serve(lis); err != nil {
+					lg.WithContext(context.Background()).Errorf("gRPC server error: %v", err)
+				}
+			}()
+			return nil
+		}, func(ctx context.Context) error {
+			gs.Shutdown(ctx)
+			return nil
+		})
+	}
 
-		s := server.New(cfg, lg)
-		lg.WithContext(cmd.Context()).Infof("Starting server on %s", cfg.HTTPAddr)
+	info := version.Get(cfg)
+	lg.WithContext(cmd.Context()).Infof("Starting server on %s version=%s commit=%s", cfg.HTTPAddr, info.Version, info.Commit)
 
-		// if shutdown != nil {
-		// 	defer shutdown(cmd.Context())
-		// }
+	// if shutdown != nil {
+	// 	defer shutdown(cmd.Context())
+	// }
 
-		return s.Run()
+	return s.Run()
+}
+
+var versionCmd = &cobra.Command{
+	Use:   "version",
+	Short: "Print build information",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// print the build info even when the environment does not validate;
+		// Load returns a nil config then and the defaults are used
+		cfg, _ := loadConfig(cmd)
+		enc := json.NewEncoder(cmd.OutOrStdout())
+		enc.SetIndent("", "  ")
+		return enc.Encode(version.Get(cfg))
 	},
 }
 
 func init() {
+	// bad flags are bad arguments for every subcommand
+	rootCmd.SetFlagErrorFunc(func(cmd *cobra.Command, err error) error {
+		return &exitError{code: exitBadArgs, err: err}
+	})
+	rootCmd.PersistentFlags().StringVar(&configFile, "config", "",
+		"YAML configuration file; environment variables override its values (env CONFIG_FILE)")
 	rootCmd.AddCommand(serveCmd)
+	rootCmd.AddCommand(versionCmd)
+}
+
+// exitError carries the process exit code of a failed command.
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string { return e.err.Error() }
+
+func (e *exitError) Unwrap() error { return e.err }
+
+// ExitCode returns the process exit code for an error returned by Execute:
+// the command's own code when it set one, 1 otherwise.
+func ExitCode(err error) int {
+	var eerr *exitError
+	if errors.As(err, &eerr) {
+		return eerr.code
+	}
+	return 1
+}
+
+// configFile is the --config flag, shared by every subcommand.
+var configFile string
+
+// logOptions maps the LOG_* settings to logger options.
+func logOptions(cfg *config.Config) logger.Options {
+	return logger.Options{
+		Format:        cfg.LogFormat,
+		Level:         cfg.LogLevel,
+		Name:          cfg.AppName,
+		Color:         cfg.LogColor,
+		DisableCaller: !cfg.LogCaller,
+		RedactKeys:    cfg.LogRedactKeys,
+		DedupInterval: cfg.LogDedupInterval,
+		DedupBurst:    cfg.LogDedupBurst,
+	}
+}
+
+// loadConfig loads the configuration from --config, falling back to
+// CONFIG_FILE, with environment variables overriding the file.
+func loadConfig(cmd *cobra.Command) (*config.Config, error) {
+	if cmd.Flags().Changed("config") {
+		return config.LoadFile(configFile)
+	}
+	return config.Load()
 }
 
 func Execute() error {
 	return rootCmd.Execute()
 }
+
+// exporterLogLine describes an OTel exporter for the startup log. Like the
+// startup summary it names the headers without their values, which are
+// usually credentials.
+func exporterLogLine(info logger.ExporterInfo) string {
+	return fmt.Sprintf("OTEL exporter: type=%s endpoint=%s insecure=%t headers=%v",
+		info.Type, info.Endpoint, info.Insecure, slices.Sorted(maps.Keys(info.Headers)))
+}
@@ -0,0 +1,16 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/thiagozs/go-exchange/internal/logger"
+)
+
+func TestExporterLogLineOmitsHeaderValues(t *testing.T) {
+	line := exporterLogLine(logger.ExporterInfo{Type: "otlp-http", Endpoint: "collector:4318",
+		Headers: map[string]string{"x-honeycomb-team": "hc-secret", "api-key": "k-secret"}})
+	if strings.Contains(line, "secret") || !strings.Contains(line, "headers=[api-key x-honeycomb-team]") {
+		t.Fatalf("expected only the header names, got %q", line)
+	}
+}
@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/thiagozs/go-exchange/internal/config"
+)
+
+// serveFlags override the most common settings for quick experiments. A flag
+// only applies when given, so it never clobbers the environment with its
+// zero default.
+var serveFlags struct {
+	addr      string
+	logLevel  string
+	logFormat string
+	provider  string
+	redisAddr string
+	cacheTTL  time.Duration
+}
+
+// applyServeFlags copies the serve flags that were set onto cfg.
+func applyServeFlags(cmd *cobra.Command, cfg *config.Config) error {
+	f := cmd.Flags()
+	if f.Changed("addr") {
+		cfg.HTTPAddr = serveFlags.addr
+	}
+	if f.Changed("log-level") {
+		cfg.LogLevel = serveFlags.logLevel
+	}
+	if f.Changed("log-format") {
+		cfg.LogFormat = serveFlags.logFormat
+	}
+	if f.Changed("provider") {
+		cfg.Provider = serveFlags.provider
+	}
+	if f.Changed("redis-addr") {
+		cfg.RedisAddr = serveFlags.redisAddr
+	}
+	if f.Changed("cache-ttl") {
+		if serveFlags.cacheTTL <= 0 {
+			return fmt.Errorf("--cache-ttl must be positive, got %v", serveFlags.cacheTTL)
+		}
+		cfg.CacheTTL = serveFlags.cacheTTL
+	}
+	return nil
+}
+
+func init() {
+	f := serveCmd.Flags()
+	f.StringVar(&serveFlags.addr, "addr", "", "listen address, e.g. :9090 (env HTTP_ADDR)")
+	f.StringVar(&serveFlags.logLevel, "log-level", "", "log level: debug, info, warn, error (env LOG_LEVEL)")
+	f.StringVar(&serveFlags.logFormat, "log-format", "", "log format: text or json (env LOG_FORMAT)")
+	f.StringVar(&serveFlags.provider, "provider", "", "exchange provider, e.g. bcb (env EXCHANGE_PROVIDER)")
+	f.StringVar(&serveFlags.redisAddr, "redis-addr", "", "Redis address; empty uses the in-memory cache (env REDIS_ADDR)")
+	f.DurationVar(&serveFlags.cacheTTL, "cache-ttl", 0, "conversion cache TTL, e.g. 10m (env CACHE_TTL)")
+}
@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/thiagozs/go-exchange/internal/config"
+)
+
+// executeServe runs `go-exchange serve args...` with a stubbed runner and
+// returns the configuration it would have served with.
+func executeServe(t *testing.T, args ...string) *config.Config {
+	t.Helper()
+	var got *config.Config
+	old := runServe
+	runServe = func(cmd *cobra.Command, cfg *config.Config) error {
+		got = cfg
+		return nil
+	}
+	t.Cleanup(func() {
+		runServe = old
+		serveCmd.Flags().VisitAll(func(f *pflag.Flag) {
+			_ = f.Value.Set(f.DefValue)
+			f.Changed = false
+		})
+		rootCmd.SetArgs(nil)
+	})
+
+	rootCmd.SetArgs(append([]string{"serve"}, args...))
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("execute: %v", err)
+	}
+	if got == nil {
+		t.Fatal("the server was not started")
+	}
+	return got
+}
+
+func TestServeFlagsOverrideEnvironment(t *testing.T) {
+	t.Setenv("CACHE_BACKEND", "memory")
+	t.Setenv("HTTP_ADDR", ":8081")
+	t.Setenv("LOG_LEVEL", "warn")
+
+	cfg := executeServe(t, "--addr", ":9090", "--log-level", "debug", "--log-format", "json",
+		"--provider", "bcb", "--redis-addr", "", "--cache-ttl", "10m")
+	if cfg.HTTPAddr != ":9090" || cfg.LogLevel != "debug" || cfg.LogFormat != "json" ||
+		cfg.Provider != "bcb" || cfg.RedisAddr != "" || cfg.CacheTTL != 10*time.Minute {
+		t.Fatalf("flags not applied: %+v", cfg)
+	}
+}
+
+func TestServeFlagsKeepEnvironmentWhenUnset(t *testing.T) {
+	t.Setenv("CACHE_BACKEND", "memory")
+	t.Setenv("HTTP_ADDR", ":8081")
+	t.Setenv("LOG_LEVEL", "warn")
+	t.Setenv("CACHE_TTL", "2m")
+
+	cfg := executeServe(t, "--provider", "static")
+	if cfg.HTTPAddr != ":8081" || cfg.LogLevel != "warn" || cfg.CacheTTL != 2*time.Minute || cfg.RedisAddr != "localhost:6379" {
+		t.Fatalf("unset flags clobbered the environment: %+v", cfg)
+	}
+	if cfg.Provider != "static" {
+		t.Fatalf("expected the provider flag, got %q", cfg.Provider)
+	}
+}
@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"cmp"
+	"encoding/json"
+	"maps"
+	"slices"
+
+	"github.com/thiagozs/go-exchange/internal/config"
+	"github.com/thiagozs/go-exchange/internal/logger"
+	"github.com/thiagozs/go-exchange/internal/provider"
+	"github.com/thiagozs/go-exchange/internal/server"
+	"github.com/thiagozs/go-exchange/internal/version"
+)
+
+// startupSummary describes what cfg configures the instance to do, with the
+// OTel exporters in infos. The cache backend is the configured one; serve
+// replaces it with the one actually opened.
+func startupSummary(cfg *config.Config, infos []logger.ExporterInfo) server.StartupSummary {
+	red := cfg.Redacted()
+	build := version.Get(cfg)
+	name := cmp.Or(cfg.Provider, "exchangerate.host")
+	sum := server.StartupSummary{
+		Version:       build.Version,
+		Commit:        build.Commit,
+		Provider:      server.SummaryProvider{Name: name, BaseURL: provider.BaseURL(name, cfg)},
+		Cache:         server.SummaryCache{Backend: "redis", Addr: red.RedisAddr},
+		FeeSource:     feeSource(cfg),
+		OTelExporters: []server.SummaryExporter{},
+		HTTPAddr:      cfg.HTTPAddr,
+		GRPCAddr:      cfg.GRPCAddr,
+		Features:      features(cfg),
+		Warnings:      cfg.Warnings(),
+	}
+	if name == "aggregate" {
+		sum.Provider.Sources = cfg.AggregateSources
+	}
+	if cfg.CacheBackend == config.CacheBackendMemory || cfg.RedisAddr == "" {
+		sum.Cache = server.SummaryCache{Backend: "memory"}
+	}
+	for _, info := range infos {
+		sum.OTelExporters = append(sum.OTelExporters, server.SummaryExporter{Type: info.Type, Endpoint: info.Endpoint,
+			Protocol: info.Protocol, Insecure: info.Insecure, Compression: info.Compression,
+			Headers: slices.Sorted(maps.Keys(info.Headers))})
+	}
+	if sum.Warnings == nil {
+		sum.Warnings = []string{}
+	}
+	return sum
+}
+
+// summaryFields turns sum into log fields, one per top-level key of its
+// JSON form.
+func summaryFields(sum server.StartupSummary) map[string]any {
+	fields := map[string]any{}
+	b, err := json.Marshal(sum)
+	if err == nil {
+		_ = json.Unmarshal(b, &fields)
+	}
+	return fields
+}
+
+// feeSource names the fee provider newFeeProvider picks, in the same order
+// of precedence.
+func feeSource(cfg *config.Config) string {
+	switch {
+	case cfg.FeeAPIURL != "":
+		return "api"
+	case cfg.FeeTiers.Enabled():
+		return "tiers"
+	case len(cfg.Fees) > 0:
+		return "pairs"
+	case cfg.FeePercent > 0:
+		return "percent"
+	}
+	return "none"
+}
+
+// features lists the optional middlewares and features cfg turns on.
+func features(cfg *config.Config) []string {
+	out := []string{}
+	for _, f := range []struct {
+		name string
+		on   bool
+	}{
+		{"api_keys", len(cfg.APIKeys) > 0},
+		{"admin", cfg.AdminEnabled},
+		{"docs", cfg.DocsEnabled},
+		{"debug_endpoints", cfg.DebugEndpoints},
+		{"grpc", cfg.GRPCAddr != ""},
+		{"grpc_reflection", cfg.GRPCAddr != "" && cfg.GRPCReflection},
+		{"strict_query_params", cfg.StrictQueryParams},
+		{"request_timeout", cfg.RequestTimeout > 0},
+		{"gzip", cfg.GzipMinSize > 0},
+		{"load_shedding", cfg.MaxInflightRequests > 0},
+		{"server_timing", cfg.ServerTiming},
+		{"rate_stream", cfg.StreamMaxSubscribers > 0},
+		{"rate_alerts", len(cfg.RateAlerts) > 0},
+		{"hot_refresh", cfg.HotRefreshThreshold > 0},
+		{"stale_while_revalidate", cfg.RatesSoftTTL > 0},
+		{"provider_overrides", len(cfg.ProviderOverrides) > 0},
+		{"warmup", len(cfg.WarmupBases) > 0},
+		{"upstream_quota", len(cfg.ProviderMonthlyQuota) > 0},
+	} {
+		if f.on {
+			out = append(out, f.name)
+		}
+	}
+	return out
+}
@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/spf13/pflag"
+)
+
+func TestConfigSummary(t *testing.T) {
+	t.Setenv("EXCHANGE_PROVIDER", "bcb")
+	t.Setenv("REDIS_ADDR", "redis:6379")
+	t.Setenv("REDIS_PASSWORD", "s3cret")
+	t.Setenv("EXCHANGE_FEE_PERCENT", "1.5")
+	t.Setenv("OTLP_ENDPOINT", "http://collector:4317")
+	t.Setenv("OTLP_HEADERS", "authorization=Bearer tok")
+	t.Setenv("ENVIRONMENT", "production")
+
+	var out bytes.Buffer
+	rootCmd.SetOut(&out)
+	rootCmd.SetArgs([]string{"config", "--summary"})
+	t.Cleanup(func() {
+		rootCmd.SetOut(nil)
+		rootCmd.SetArgs(nil)
+		configCmd.Flags().VisitAll(func(f *pflag.Flag) {
+			_ = f.Value.Set(f.DefValue)
+			f.Changed = false
+		})
+	})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("execute: %v", err)
+	}
+
+	var sum struct {
+		Provider struct {
+			Name    string `json:"name"`
+			BaseURL string `json:"base_url"`
+		} `json:"provider"`
+		Cache struct {
+			Backend string `json:"backend"`
+			Addr    string `json:"addr"`
+		} `json:"cache"`
+		FeeSource     string `json:"fee_source"`
+		OTelExporters []struct {
+			Type    string   `json:"type"`
+			Headers []string `json:"headers"`
+		} `json:"otel_exporters"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &sum); err != nil {
+		t.Fatalf("invalid json: %v\n%s", err, out.String())
+	}
+	if sum.Provider.Name != "bcb" || !strings.HasPrefix(sum.Provider.BaseURL, "https://olinda.bcb.gov.br/") {
+		t.Fatalf("unexpected provider %+v", sum.Provider)
+	}
+	if sum.Cache.Backend != "redis" || sum.Cache.Addr != "redis:6379" || sum.FeeSource != "percent" {
+		t.Fatalf("unexpected cache %+v or fee source %q", sum.Cache, sum.FeeSource)
+	}
+	if len(sum.OTelExporters) != 3 || sum.OTelExporters[0].Type != "otlp-grpc" ||
+		len(sum.OTelExporters[0].Headers) != 1 || sum.OTelExporters[0].Headers[0] != "authorization" {
+		t.Fatalf("unexpected exporters %+v", sum.OTelExporters)
+	}
+	if strings.Contains(out.String(), "s3cret") || strings.Contains(out.String(), "tok") {
+		t.Fatalf("summary leaks a secret:\n%s", out.String())
+	}
+}
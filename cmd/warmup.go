@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/thiagozs/go-exchange/internal/config"
+	"github.com/thiagozs/go-exchange/internal/logger"
+	"github.com/thiagozs/go-exchange/internal/server"
+)
+
+var warmupOpts struct {
+	bases   []string
+	timeout time.Duration
+	strict  bool
+}
+
+var warmupCmd = &cobra.Command{
+	Use:   "warmup",
+	Short: "Prefetch provider rates into the cache",
+	Long: `Prefetch the provider rates for WARMUP_BASES (or --bases) into the shared
+cache, e.g. from an init container, so the first requests after a deploy hit.
+Failures are logged; with --strict they exit 1.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		cfg, err := loadConfig(cmd)
+		if err != nil {
+			return err
+		}
+		bases := cfg.WarmupBases
+		if len(warmupOpts.bases) > 0 {
+			// validated and normalized like the environment variable
+			if bases, err = config.NormalizeWarmupBases(warmupOpts.bases); err != nil {
+				return &exitError{code: exitBadArgs, err: err}
+			}
+		}
+		if len(bases) == 0 {
+			return &exitError{code: exitBadArgs, err: errors.New("no bases to warm up: set WARMUP_BASES or --bases")}
+		}
+		lg := logger.New(logOptions(cfg))
+		if cfg.CacheBackend == config.CacheBackendMemory || cfg.RedisAddr == "" {
+			lg.WithContext(cmd.Context()).Warnf("warmup with the in-memory cache only warms this process")
+		}
+
+		s, err := server.New(cfg, lg)
+		if err != nil {
+			return err
+		}
+		ctx, cancel := context.WithTimeout(cmd.Context(), warmupOpts.timeout)
+		defer cancel()
+		if err := s.Warmup(ctx, bases); err != nil && warmupOpts.strict {
+			return err
+		}
+		return nil
+	},
+}
+
+func init() {
+	f := warmupCmd.Flags()
+	f.StringSliceVar(&warmupOpts.bases, "bases", nil, "base currencies, e.g. USD,EUR (default WARMUP_BASES)")
+	f.DurationVar(&warmupOpts.timeout, "timeout", 30*time.Second, "warmup timeout")
+	f.BoolVar(&warmupOpts.strict, "strict", false, "exit 1 when any base fails")
+	rootCmd.AddCommand(warmupCmd)
+}
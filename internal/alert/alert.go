@@ -0,0 +1,206 @@
+// Package alert checks the RATE_ALERTS thresholds in the background and
+// notifies their webhooks when a rate crosses one.
+package alert
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/thiagozs/go-exchange/internal/config"
+	"github.com/thiagozs/go-exchange/internal/exchange"
+	"github.com/thiagozs/go-exchange/internal/logger"
+)
+
+// States of an alert.
+const (
+	// StatePending is an alert whose rate was not read yet.
+	StatePending = "pending"
+	StateClear   = "clear"
+	StateFired   = "fired"
+)
+
+// Events delivered to the webhooks.
+const (
+	EventFired   = "fired"
+	EventCleared = "cleared"
+)
+
+// RateSource looks up the current rate of a pair; *exchange.Service
+// implements it.
+type RateSource interface {
+	Rate(ctx context.Context, req exchange.RateRequest) (exchange.RateResponse, error)
+}
+
+// Status is the state of one alert, as reported by GET /admin/alerts. The
+// webhook URL is left out: it may embed a token.
+type Status struct {
+	Name       string  `json:"name"`
+	Pair       string  `json:"pair"`
+	Direction  string  `json:"direction"`
+	Threshold  float64 `json:"threshold"`
+	Hysteresis float64 `json:"hysteresis"`
+
+	State     string    `json:"state"`
+	Rate      float64   `json:"rate,omitempty"`
+	CheckedAt time.Time `json:"checked_at,omitzero"`
+	ChangedAt time.Time `json:"changed_at,omitzero"`
+	// CheckError is the last failure to read the rate, cleared by the next
+	// successful check.
+	CheckError string `json:"check_error,omitempty"`
+	// DeliveryError is the last failure to deliver an event to the webhook,
+	// cleared by the next delivery.
+	DeliveryError string `json:"delivery_error,omitempty"`
+}
+
+// next returns the state of a after reading rate in state. An alert fires
+// when the rate crosses the threshold and clears only once it is back past
+// the threshold by more than the hysteresis, so a rate hovering around the
+// threshold does not flap.
+func next(a config.RateAlert, state string, rate float64) string {
+	fired := state == StateFired
+	switch a.Direction {
+	case config.AlertAbove:
+		if rate > a.Threshold || (fired && rate >= a.Threshold-a.Hysteresis) {
+			return StateFired
+		}
+	case config.AlertBelow:
+		if rate < a.Threshold || (fired && rate <= a.Threshold+a.Hysteresis) {
+			return StateFired
+		}
+	}
+	return StateClear
+}
+
+// transition is the event for a state change, empty when none is due. An
+// alert that starts out clear sends nothing.
+func transition(from, to string) string {
+	switch {
+	case from != StateFired && to == StateFired:
+		return EventFired
+	case from == StateFired && to == StateClear:
+		return EventCleared
+	}
+	return ""
+}
+
+// Checker reads the rate of every alert each ALERT_CHECK_INTERVAL and
+// delivers an event on each fired/cleared transition.
+type Checker struct {
+	rates    RateSource
+	hook     *webhook
+	interval time.Duration
+	log      *logger.Logger
+	now      func() time.Time
+
+	alerts []config.RateAlert
+
+	mu sync.Mutex
+	// statuses are indexed like alerts
+	statuses []Status
+}
+
+// New returns a checker for cfg.RateAlerts. A nil client uses a plain client
+// with ALERT_WEBHOOK_TIMEOUT.
+func New(cfg *config.Config, rates RateSource, client *http.Client, lg *logger.Logger) *Checker {
+	if client == nil {
+		client = &http.Client{Timeout: cfg.AlertWebhookTimeout}
+	}
+	log := lg.With(map[string]any{"component": "alert"})
+	c := &Checker{rates: rates, interval: cfg.AlertCheckInterval, log: log, now: time.Now,
+		hook: &webhook{client: client, secret: cfg.AlertWebhookSecret, maxRetries: cfg.AlertWebhookMaxRetries,
+			backoff: 500 * time.Millisecond, log: log},
+	}
+	for _, a := range cfg.RateAlerts {
+		c.alerts = append(c.alerts, a)
+		c.statuses = append(c.statuses, Status{Name: a.Name, Pair: a.Pair, Direction: a.Direction,
+			Threshold: a.Threshold, Hysteresis: a.Hysteresis, State: StatePending})
+	}
+	return c
+}
+
+// Run checks the alerts right away and then every interval until ctx is
+// done.
+func (c *Checker) Run(ctx context.Context) {
+	t := time.NewTicker(c.interval)
+	defer t.Stop()
+	for {
+		c.Check(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// Check reads the rate of each alerted pair once and delivers the events of
+// the alerts that changed state.
+func (c *Checker) Check(ctx context.Context) {
+	pairs := map[string]bool{}
+	for _, a := range c.alerts {
+		pairs[a.Pair] = true
+	}
+
+	rates := map[string]exchange.RateResponse{}
+	failures := map[string]error{}
+	for pair := range pairs {
+		from, to := pair[:3], pair[4:]
+		q, err := c.rates.Rate(ctx, exchange.RateRequest{From: from, To: to})
+		if err != nil {
+			c.log.WithContext(ctx).Warnf("alert: rate %s failed: %v", pair, err)
+			failures[pair] = err
+			continue
+		}
+		rates[pair] = q
+	}
+
+	var fired []int
+	var events []Event
+	c.mu.Lock()
+	now := c.now()
+	for i := range c.statuses {
+		st := &c.statuses[i]
+		st.CheckedAt = now
+		if err, failed := failures[st.Pair]; failed {
+			st.CheckError = err.Error()
+			continue
+		}
+		q := rates[st.Pair]
+		st.CheckError = ""
+		st.Rate = q.Rate
+		state := next(c.alerts[i], st.State, q.Rate)
+		event := transition(st.State, state)
+		if state != st.State {
+			st.State, st.ChangedAt = state, now
+		}
+		if event == "" {
+			continue
+		}
+		c.log.WithContext(ctx).Infof("alert %q %s: %s rate %v %s %v", st.Name, event, st.Pair, q.Rate, st.Direction, st.Threshold)
+		fired = append(fired, i)
+		events = append(events, newEvent(c.alerts[i], event, q, now))
+	}
+	c.mu.Unlock()
+
+	// delivered without the lock, so a slow webhook does not block
+	// GET /admin/alerts
+	for j, i := range fired {
+		err := c.hook.deliver(ctx, c.alerts[i].WebhookURL, events[j])
+		c.mu.Lock()
+		if err != nil {
+			c.statuses[i].DeliveryError = err.Error()
+		} else {
+			c.statuses[i].DeliveryError = ""
+		}
+		c.mu.Unlock()
+	}
+}
+
+// Statuses returns the state of every alert, in configuration order.
+func (c *Checker) Statuses() []Status {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]Status(nil), c.statuses...)
+}
@@ -0,0 +1,183 @@
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/thiagozs/go-exchange/internal/config"
+	"github.com/thiagozs/go-exchange/internal/exchange"
+	"github.com/thiagozs/go-exchange/internal/logger"
+)
+
+func TestStateMachine(t *testing.T) {
+	above := config.RateAlert{Direction: config.AlertAbove, Threshold: 5.8, Hysteresis: 0.05}
+	below := config.RateAlert{Direction: config.AlertBelow, Threshold: 5.5, Hysteresis: 0.05}
+	cases := []struct {
+		alert  config.RateAlert
+		rates  []float64
+		events string
+	}{
+		// starts below the threshold: nothing to report
+		{above, []float64{5.7}, ""},
+		// starts above the threshold: fires once while it holds
+		{above, []float64{5.9, 5.95, 5.81}, "fired"},
+		// dips within the hysteresis do not clear it
+		{above, []float64{5.7, 5.81, 5.79, 5.76, 5.82}, "fired"},
+		// clears below threshold-hysteresis, then may fire again
+		{above, []float64{5.81, 5.74, 5.79, 5.81}, "fired,cleared,fired"},
+		// exactly at the threshold is not above it
+		{above, []float64{5.8}, ""},
+		{below, []float64{5.4, 5.52, 5.56, 5.49}, "fired,cleared,fired"},
+	}
+	for _, tc := range cases {
+		state := StatePending
+		var events []string
+		for _, r := range tc.rates {
+			s := next(tc.alert, state, r)
+			if ev := transition(state, s); ev != "" {
+				events = append(events, ev)
+			}
+			state = s
+		}
+		if got := strings.Join(events, ","); got != tc.events {
+			t.Fatalf("%s %v over %v: got events %q, want %q", tc.alert.Direction, tc.alert.Threshold, tc.rates, got, tc.events)
+		}
+	}
+}
+
+// scriptedRates returns the scripted rates in turn, then keeps the last one;
+// a zero rate fails the lookup.
+type scriptedRates struct {
+	mu    sync.Mutex
+	rates []float64
+	calls int
+}
+
+func (s *scriptedRates) Rate(ctx context.Context, req exchange.RateRequest) (exchange.RateResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rate := s.rates[min(s.calls, len(s.rates)-1)]
+	s.calls++
+	if rate == 0 {
+		return exchange.RateResponse{}, errors.New("provider down")
+	}
+	return exchange.RateResponse{From: req.From, To: req.To, Rate: rate, Source: "bcb"}, nil
+}
+
+// receiver records the webhook requests; the first failures requests are
+// answered with 503.
+type receiver struct {
+	mu       sync.Mutex
+	failures int
+	attempts int
+	events   []Event
+	sigs     []string
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.attempts++
+	if rc.attempts <= rc.failures {
+		http.Error(w, "busy", http.StatusServiceUnavailable)
+		return
+	}
+	body, _ := io.ReadAll(r.Body)
+	var ev Event
+	if err := json.Unmarshal(body, &ev); err != nil {
+		http.Error(w, "bad body", http.StatusBadRequest)
+		return
+	}
+	if sig := r.Header.Get(SignatureHeader); sig != Sign("s3cret", body) {
+		http.Error(w, "bad signature "+sig, http.StatusUnauthorized)
+		return
+	}
+	rc.events = append(rc.events, ev)
+	rc.sigs = append(rc.sigs, r.Header.Get(SignatureHeader))
+}
+
+func newTestChecker(url string, rates RateSource, maxRetries int) *Checker {
+	cfg := &config.Config{AlertCheckInterval: time.Minute, AlertWebhookSecret: "s3cret", AlertWebhookMaxRetries: maxRetries,
+		RateAlerts: config.RateAlerts{{Name: "usd high", Pair: "USD-BRL", Direction: config.AlertAbove,
+			Threshold: 5.8, Hysteresis: 0.05, WebhookURL: url}}}
+	c := New(cfg, rates, nil, logger.New(logger.Options{Format: "text", Level: "info", Out: &bytes.Buffer{}}))
+	c.hook.backoff = time.Millisecond
+	return c
+}
+
+func TestCheckerDeliversSignedEvents(t *testing.T) {
+	rc := &receiver{failures: 2}
+	srv := httptest.NewServer(rc)
+	defer srv.Close()
+	rates := &scriptedRates{rates: []float64{5.7, 5.85, 5.9, 5.78, 5.7}}
+	c := newTestChecker(srv.URL, rates, 3)
+
+	for range 5 {
+		c.Check(context.Background())
+	}
+
+	if len(rc.events) != 2 || rc.attempts != 4 {
+		t.Fatalf("expected two events after two retried attempts, got %d events in %d attempts", len(rc.events), rc.attempts)
+	}
+	if ev := rc.events[0]; ev.Alert != "usd high" || ev.Event != EventFired || ev.Pair != "USD-BRL" || ev.Rate != 5.85 ||
+		ev.Threshold != 5.8 || ev.Direction != config.AlertAbove || ev.Source != "bcb" || ev.At.IsZero() {
+		t.Fatalf("unexpected fired event %+v", ev)
+	}
+	if ev := rc.events[1]; ev.Event != EventCleared || ev.Rate != 5.7 {
+		t.Fatalf("unexpected cleared event %+v", ev)
+	}
+
+	st := c.Statuses()[0]
+	if st.State != StateClear || st.Rate != 5.7 || st.ChangedAt.IsZero() || st.DeliveryError != "" || st.CheckError != "" {
+		t.Fatalf("unexpected status %+v", st)
+	}
+}
+
+func TestCheckerRecordsFailures(t *testing.T) {
+	rc := &receiver{failures: 100}
+	srv := httptest.NewServer(rc)
+	defer srv.Close()
+	rates := &scriptedRates{rates: []float64{5.9, 0}}
+	c := newTestChecker(srv.URL, rates, 1)
+
+	c.Check(context.Background())
+	st := c.Statuses()[0]
+	if st.State != StateFired || !strings.Contains(st.DeliveryError, "503") || rc.attempts != 2 {
+		t.Fatalf("expected a fired alert with a delivery error after 2 attempts, got %+v (%d attempts)", st, rc.attempts)
+	}
+
+	// a failed lookup keeps the state
+	c.Check(context.Background())
+	st = c.Statuses()[0]
+	if st.State != StateFired || st.CheckError == "" || st.Rate != 5.9 {
+		t.Fatalf("expected the fired state kept with a check error, got %+v", st)
+	}
+}
+
+func TestWebhookDoesNotRetryClientErrors(t *testing.T) {
+	var attempts int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if r.Header.Get(SignatureHeader) != "" {
+			t.Errorf("unexpected signature without a secret")
+		}
+		http.Error(w, "gone", http.StatusGone)
+	}))
+	defer srv.Close()
+
+	c := newTestChecker(srv.URL, &scriptedRates{rates: []float64{5.9}}, 3)
+	c.hook.secret = ""
+	c.Check(context.Background())
+	if attempts != 1 || !strings.Contains(c.Statuses()[0].DeliveryError, "410") {
+		t.Fatalf("expected a single attempt, got %d: %+v", attempts, c.Statuses()[0])
+	}
+}
@@ -0,0 +1,109 @@
+package alert
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/thiagozs/go-exchange/internal/config"
+	"github.com/thiagozs/go-exchange/internal/exchange"
+	"github.com/thiagozs/go-exchange/internal/logger"
+)
+
+// SignatureHeader carries "sha256=" and the hex HMAC-SHA256 of the request
+// body keyed with ALERT_WEBHOOK_SECRET. It is omitted when no secret is set.
+const SignatureHeader = "X-Signature-256"
+
+// Event is the JSON body POSTed to a webhook.
+type Event struct {
+	Alert         string    `json:"alert"`
+	Event         string    `json:"event"`
+	Pair          string    `json:"pair"`
+	Direction     string    `json:"direction"`
+	Threshold     float64   `json:"threshold"`
+	Rate          float64   `json:"rate"`
+	RateTimestamp time.Time `json:"rate_timestamp,omitzero"`
+	Source        string    `json:"source,omitempty"`
+	At            time.Time `json:"at"`
+}
+
+func newEvent(a config.RateAlert, event string, q exchange.RateResponse, at time.Time) Event {
+	return Event{Alert: a.Name, Event: event, Pair: a.Pair, Direction: a.Direction, Threshold: a.Threshold,
+		Rate: q.Rate, RateTimestamp: q.RateTimestamp.UTC(), Source: q.Source, At: at.UTC()}
+}
+
+// Sign returns the SignatureHeader value for body, for receivers to compare
+// with hmac.Equal.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// webhook delivers events, retrying network errors, 429 and 5xx responses
+// with exponential backoff.
+type webhook struct {
+	client     *http.Client
+	secret     string
+	maxRetries int
+	// backoff is the wait before the first retry, doubled for each further
+	// one
+	backoff time.Duration
+	log     *logger.Logger
+}
+
+func (h *webhook) deliver(ctx context.Context, url string, ev Event) error {
+	body, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	wait := h.backoff
+	for attempt := 0; ; attempt++ {
+		retry, err := h.attempt(ctx, url, body)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt >= h.maxRetries || ctx.Err() != nil {
+			h.log.WithContext(ctx).Errorf("alert %q: webhook delivery failed after %d attempts: %v", ev.Alert, attempt+1, err)
+			return err
+		}
+		h.log.WithContext(ctx).Warnf("alert %q: retrying webhook attempt=%d/%d in %v: %v", ev.Alert, attempt+2, h.maxRetries+1, wait, err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+		wait *= 2
+	}
+}
+
+// attempt performs one delivery; retry reports whether a failure is worth
+// retrying.
+func (h *webhook) attempt(ctx context.Context, url string, body []byte) (retry bool, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if h.secret != "" {
+		req.Header.Set(SignatureHeader, Sign(h.secret, body))
+	}
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests,
+			fmt.Errorf("webhook: unexpected status %d", resp.StatusCode)
+	}
+	return false, nil
+}
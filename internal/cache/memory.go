@@ -0,0 +1,167 @@
+package cache
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// memoryMaxEntries bounds the memory cache; writes beyond it are dropped
+// until expired entries are swept.
+const memoryMaxEntries = 10000
+
+type memoryEntry struct {
+	value   string
+	expires time.Time // zero: no expiry
+}
+
+// MemoryCache is an in-process cache used when Redis is not available. It
+// honours TTLs but is neither shared between instances nor persistent.
+type MemoryCache struct {
+	mu  sync.Mutex
+	m   map[string]memoryEntry
+	now func() time.Time
+
+	fills singleflight.Group
+}
+
+func NewMemory() *MemoryCache {
+	return &MemoryCache{m: map[string]memoryEntry{}, now: time.Now}
+}
+
+func (c *MemoryCache) Get(ctx context.Context, key string) (string, error) {
+	start := time.Now()
+	val, ok := c.get(key)
+	observe(ctx, backendMemory, opGet, start, ok, nil)
+	return val, nil
+}
+
+// GetWithTTL is Get plus the time left before the entry expires.
+func (c *MemoryCache) GetWithTTL(ctx context.Context, key string) (string, time.Duration, error) {
+	start := time.Now()
+	e, ok := c.lookup(key)
+	observe(ctx, backendMemory, opGet, start, ok, nil)
+	if !ok || e.expires.IsZero() {
+		return e.value, 0, nil
+	}
+	return e.value, e.expires.Sub(c.now()), nil
+}
+
+func (c *MemoryCache) get(key string) (string, bool) {
+	e, ok := c.lookup(key)
+	return e.value, ok
+}
+
+// lookup returns the live entry under key, dropping it when expired.
+func (c *MemoryCache) lookup(key string) (memoryEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.m[key]
+	if !ok {
+		return memoryEntry{}, false
+	}
+	if !e.expires.IsZero() && !c.now().Before(e.expires) {
+		delete(c.m, key)
+		return memoryEntry{}, false
+	}
+	return e, true
+}
+
+func (c *MemoryCache) Set(ctx context.Context, key string, value string, ttl time.Duration) error {
+	defer observe(ctx, backendMemory, opSet, time.Now(), false, nil)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, exists := c.m[key]; !exists && len(c.m) >= memoryMaxEntries {
+		c.sweep()
+		if len(c.m) >= memoryMaxEntries {
+			return nil
+		}
+	}
+	e := memoryEntry{value: value}
+	if ttl > 0 {
+		e.expires = c.now().Add(ttl)
+	}
+	c.m[key] = e
+	return nil
+}
+
+// GetOrSet returns the value stored under key, calling fill on a miss.
+// Concurrent callers for the same key share one fill. Nothing is stored when
+// fill fails or returns ""; its value and error are returned.
+func (c *MemoryCache) GetOrSet(ctx context.Context, key string, ttl time.Duration, fill func(ctx context.Context) (string, error)) (string, error) {
+	if val, _ := c.Get(ctx, key); val != "" {
+		return val, nil
+	}
+	v, err, _ := c.fills.Do(key, func() (any, error) {
+		// a fill may have completed between the Get and Do
+		if val, ok := c.get(key); ok {
+			return val, nil
+		}
+		val, err := fill(ctx)
+		if err == nil && val != "" {
+			_ = c.Set(ctx, key, val, ttl)
+		}
+		return val, err
+	})
+	return v.(string), err
+}
+
+func (c *MemoryCache) Delete(ctx context.Context, key string) error {
+	defer observe(ctx, backendMemory, opDelete, time.Now(), false, nil)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.m, key)
+	return nil
+}
+
+func (c *MemoryCache) DeleteByPrefix(ctx context.Context, prefix string) (int64, error) {
+	defer observe(ctx, backendMemory, opDeleteByPrefix, time.Now(), false, nil)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var n int64
+	for k := range c.m {
+		if strings.HasPrefix(k, prefix) {
+			delete(c.m, k)
+			n++
+		}
+	}
+	return n, nil
+}
+
+// Incr increments the counter under key and returns its new value. The
+// expiry is set when the counter is created.
+func (c *MemoryCache) Incr(ctx context.Context, key string, ttl time.Duration) (int64, error) {
+	defer observe(ctx, backendMemory, opIncr, time.Now(), false, nil)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var n int64
+	e, ok := c.m[key]
+	if ok && (e.expires.IsZero() || c.now().Before(e.expires)) {
+		n, _ = strconv.ParseInt(e.value, 10, 64)
+	} else {
+		e = memoryEntry{}
+		if ttl > 0 {
+			e.expires = c.now().Add(ttl)
+		}
+	}
+	n++
+	// counters are few and must keep counting, so memoryMaxEntries does not
+	// apply to them
+	e.value = strconv.FormatInt(n, 10)
+	c.m[key] = e
+	return n, nil
+}
+
+// sweep drops expired entries. Callers hold c.mu.
+func (c *MemoryCache) sweep() {
+	now := c.now()
+	for k, e := range c.m {
+		if !e.expires.IsZero() && !now.Before(e.expires) {
+			delete(c.m, k)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMemoryCacheTTL(t *testing.T) {
+	now := time.Date(2025, 1, 2, 10, 0, 0, 0, time.UTC)
+	c := NewMemory()
+	c.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	_ = c.Set(ctx, "short", "a", time.Minute)
+	_ = c.Set(ctx, "forever", "b", 0)
+	now = now.Add(time.Minute)
+	if got, _ := c.Get(ctx, "short"); got != "" {
+		t.Fatalf("expected expired entry to be gone, got %q", got)
+	}
+	if got, _ := c.Get(ctx, "forever"); got != "b" {
+		t.Fatalf("expected entry without TTL to survive, got %q", got)
+	}
+}
+
+func TestMemoryGetWithTTL(t *testing.T) {
+	now := time.Date(2025, 1, 2, 10, 0, 0, 0, time.UTC)
+	c := NewMemory()
+	c.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	_ = c.Set(ctx, "short", "a", time.Minute)
+	_ = c.Set(ctx, "forever", "b", 0)
+	now = now.Add(20 * time.Second)
+	if v, ttl, _ := c.GetWithTTL(ctx, "short"); v != "a" || ttl != 40*time.Second {
+		t.Fatalf("expected a with 40s left, got %q %v", v, ttl)
+	}
+	if v, ttl, _ := c.GetWithTTL(ctx, "forever"); v != "b" || ttl != 0 {
+		t.Fatalf("expected b without TTL, got %q %v", v, ttl)
+	}
+	now = now.Add(time.Minute)
+	if v, ttl, _ := c.GetWithTTL(ctx, "short"); v != "" || ttl != 0 {
+		t.Fatalf("expected expired entry to be a miss, got %q %v", v, ttl)
+	}
+}
+
+func TestMemoryIncr(t *testing.T) {
+	now := time.Date(2025, 1, 2, 10, 0, 0, 0, time.UTC)
+	c := NewMemory()
+	c.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	for want := int64(1); want <= 3; want++ {
+		if n, err := c.Incr(ctx, "quota", time.Hour); err != nil || n != want {
+			t.Fatalf("expected %d, got %d (%v)", want, n, err)
+		}
+	}
+	if got, _ := c.Get(ctx, "quota"); got != "3" {
+		t.Fatalf("expected the counter readable with Get, got %q", got)
+	}
+	// later increments keep the expiry of the first one
+	now = now.Add(time.Hour)
+	if n, _ := c.Incr(ctx, "quota", time.Hour); n != 1 {
+		t.Fatalf("expected the expired counter to restart, got %d", n)
+	}
+}
+
+func TestMemoryCacheBounded(t *testing.T) {
+	now := time.Date(2025, 1, 2, 10, 0, 0, 0, time.UTC)
+	c := NewMemory()
+	c.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	for i := range memoryMaxEntries {
+		_ = c.Set(ctx, fmt.Sprintf("k%d", i), "v", time.Minute)
+	}
+	_ = c.Set(ctx, "overflow", "v", time.Minute)
+	if got, _ := c.Get(ctx, "overflow"); got != "" {
+		t.Fatalf("expected the write to be dropped while full")
+	}
+
+	// expired entries are swept to make room
+	now = now.Add(time.Minute)
+	_ = c.Set(ctx, "overflow", "v", time.Minute)
+	if got, _ := c.Get(ctx, "overflow"); got != "v" || len(c.m) != 1 {
+		t.Fatalf("expected the sweep to make room, got %q with %d entries", got, len(c.m))
+	}
+}
+
+func TestMemoryGetOrSetSharesFill(t *testing.T) {
+	c := NewMemory()
+	var fills atomic.Int32
+	release := make(chan struct{})
+	fill := func(ctx context.Context) (string, error) {
+		fills.Add(1)
+		<-release
+		return "v", nil
+	}
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if v, err := c.GetOrSet(context.Background(), "k", time.Minute, fill); err != nil || v != "v" {
+				t.Errorf("GetOrSet: %q, %v", v, err)
+			}
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if n := fills.Load(); n != 1 {
+		t.Fatalf("expected a single fill, got %d", n)
+	}
+}
@@ -0,0 +1,92 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+const meterName = "github.com/thiagozs/go-exchange/internal/cache"
+
+// Backend names reported on metrics.
+const (
+	backendRedis  = "redis"
+	backendMemory = "memory"
+)
+
+// Operation names reported on metrics.
+const (
+	opGet            = "get"
+	opSet            = "set"
+	opDelete         = "delete"
+	opDeleteByPrefix = "delete_prefix"
+	opIncr           = "incr"
+)
+
+// cacheMetrics holds the cache instruments, created on first use from the
+// global MeterProvider so SetupOTel can install it after the cache is built.
+var cacheMetrics struct {
+	once     sync.Once
+	hits     metric.Int64Counter
+	misses   metric.Int64Counter
+	errors   metric.Int64Counter
+	timeouts metric.Int64Counter
+	duration metric.Float64Histogram
+}
+
+func initMetrics() {
+	cacheMetrics.once.Do(func() {
+		meter := otel.GetMeterProvider().Meter(meterName)
+		cacheMetrics.hits, _ = meter.Int64Counter("cache.hits",
+			metric.WithDescription("Cache lookups that found a value"),
+		)
+		cacheMetrics.misses, _ = meter.Int64Counter("cache.misses",
+			metric.WithDescription("Cache lookups that found no value"),
+		)
+		cacheMetrics.errors, _ = meter.Int64Counter("cache.errors",
+			metric.WithDescription("Failed cache operations"),
+		)
+		cacheMetrics.timeouts, _ = meter.Int64Counter("cache.timeouts",
+			metric.WithDescription("Cache operations that exceeded REDIS_OP_TIMEOUT"),
+		)
+		cacheMetrics.duration, _ = meter.Float64Histogram("cache.operation.duration",
+			metric.WithDescription("Duration of cache operations"),
+			metric.WithUnit("s"),
+		)
+	})
+}
+
+// observe records one cache operation started at start. hit is only
+// meaningful for lookups that succeeded.
+func observe(ctx context.Context, backend, op string, start time.Time, hit bool, err error) {
+	initMetrics()
+	attrs := metric.WithAttributes(
+		attribute.String("cache.backend", backend),
+		attribute.String("cache.operation", op),
+	)
+	if cacheMetrics.duration != nil {
+		cacheMetrics.duration.Record(ctx, time.Since(start).Seconds(), attrs)
+	}
+	switch {
+	case err != nil:
+		if cacheMetrics.errors != nil {
+			cacheMetrics.errors.Add(ctx, 1, attrs)
+		}
+		if isTimeout(err) && cacheMetrics.timeouts != nil {
+			cacheMetrics.timeouts.Add(ctx, 1, attrs)
+		}
+	case op != opGet:
+	case hit:
+		if cacheMetrics.hits != nil {
+			cacheMetrics.hits.Add(ctx, 1, attrs)
+		}
+	default:
+		if cacheMetrics.misses != nil {
+			cacheMetrics.misses.Add(ctx, 1, attrs)
+		}
+	}
+}
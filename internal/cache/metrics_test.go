@@ -0,0 +1,99 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// useMeter installs an in-memory metric pipeline for a test. The cache
+// instruments are recreated against the new MeterProvider.
+func useMeter(t *testing.T) *sdkmetric.ManualReader {
+	t.Helper()
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	prev := otel.GetMeterProvider()
+	otel.SetMeterProvider(mp)
+	cacheMetrics.once = sync.Once{}
+	t.Cleanup(func() {
+		otel.SetMeterProvider(prev)
+		cacheMetrics.once = sync.Once{}
+		_ = mp.Shutdown(context.Background())
+	})
+	return reader
+}
+
+// counts returns the value of an int64 counter per cache.operation.
+func counts(t *testing.T, reader *sdkmetric.ManualReader, name string) map[string]int64 {
+	t.Helper()
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatalf("collect: %v", err)
+	}
+	out := map[string]int64{}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name != name {
+				continue
+			}
+			switch data := m.Data.(type) {
+			case metricdata.Sum[int64]:
+				for _, dp := range data.DataPoints {
+					op, _ := dp.Attributes.Value(attribute.Key("cache.operation"))
+					out[op.AsString()] += dp.Value
+				}
+			case metricdata.Histogram[float64]:
+				for _, dp := range data.DataPoints {
+					op, _ := dp.Attributes.Value(attribute.Key("cache.operation"))
+					out[op.AsString()] += int64(dp.Count)
+				}
+			}
+		}
+	}
+	return out
+}
+
+func TestRedisCacheMetrics(t *testing.T) {
+	reader := useMeter(t)
+	c, mr := newTestCache(t)
+	ctx := context.Background()
+
+	_, _ = c.Get(ctx, "k")
+	_ = c.Set(ctx, "k", "v", time.Minute)
+	_, _ = c.Get(ctx, "k")
+	mr.SetError("boom")
+	_, _ = c.Get(ctx, "k")
+
+	if got := counts(t, reader, "cache.hits"); got[opGet] != 1 {
+		t.Fatalf("expected 1 hit, got %v", got)
+	}
+	if got := counts(t, reader, "cache.misses"); got[opGet] != 1 {
+		t.Fatalf("expected 1 miss, got %v", got)
+	}
+	if got := counts(t, reader, "cache.errors"); got[opGet] != 1 {
+		t.Fatalf("expected 1 error, got %v", got)
+	}
+	if got := counts(t, reader, "cache.operation.duration"); got[opGet] != 3 || got[opSet] != 1 {
+		t.Fatalf("expected 3 get and 1 set observations, got %v", got)
+	}
+}
+
+func TestMemoryCacheMetrics(t *testing.T) {
+	reader := useMeter(t)
+	c := NewMemory()
+	ctx := context.Background()
+
+	_, _ = c.Get(ctx, "k")
+	_ = c.Set(ctx, "k", "v", time.Minute)
+	_, _ = c.Get(ctx, "k")
+
+	if hits, misses := counts(t, reader, "cache.hits"), counts(t, reader, "cache.misses"); hits[opGet] != 1 || misses[opGet] != 1 {
+		t.Fatalf("expected 1 hit and 1 miss, got %v / %v", hits, misses)
+	}
+}
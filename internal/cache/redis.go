@@ -2,33 +2,112 @@ package cache
 
 import (
 	"context"
+	"crypto/rand"
+	"errors"
+	"net"
+	"strings"
 	"time"
 
 	"github.com/redis/go-redis/v9"
+	"github.com/thiagozs/go-exchange/internal/health"
 	"github.com/thiagozs/go-exchange/internal/logger"
 )
 
 type RedisCache struct {
-	client *redis.Client
-	log    *logger.Logger
+	client    *redis.Client
+	prefix    string
+	opTimeout time.Duration
+	log       *logger.Logger
 }
 
-func New(addr string, db int, username, password string, log *logger.Logger) *RedisCache {
+// New returns a RedisCache. keyPrefix is prepended to every key, so callers
+// keep using bare keys like "convert:USD:BRL:1000". Each Redis command is
+// bounded by opTimeout (0 leaves it to the caller's context).
+func New(addr string, db int, username, password, keyPrefix string, opTimeout time.Duration, log *logger.Logger) *RedisCache {
 	r := redis.NewClient(&redis.Options{
 		Addr:     addr,
 		DB:       db,
 		Username: username,
 		Password: password,
+		// honour context deadlines instead of the 3s socket read timeout
+		ContextTimeoutEnabled: true,
 	})
-	return &RedisCache{client: r, log: log}
+	return &RedisCache{client: r, prefix: keyPrefix, opTimeout: opTimeout, log: log}
+}
+
+// pingRetryInterval spaces startup PINGs while Redis is still coming up.
+const pingRetryInterval = 250 * time.Millisecond
+
+// Ping checks that Redis answers PING, retrying connection failures until ctx
+// is done so a Redis started alongside the service has time to come up.
+// Errors returned by Redis itself (NOAUTH, WRONGPASS) are not retried.
+func (r *RedisCache) Ping(ctx context.Context) error {
+	for {
+		err := r.client.Ping(ctx).Err()
+		var rerr redis.Error
+		if err == nil || errors.As(err, &rerr) {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(pingRetryInterval):
+		}
+	}
+}
+
+// HealthCheck sends a single PING, so the check reports the current round
+// trip instead of waiting for Redis to come up.
+func (r *RedisCache) HealthCheck(ctx context.Context) health.Result {
+	if err := r.client.Ping(ctx).Err(); err != nil {
+		return health.Fail(health.StatusUnhealthy, err)
+	}
+	return health.OK(nil)
+}
+
+// Close releases the Redis connections.
+func (r *RedisCache) Close() error {
+	return r.client.Close()
+}
+
+// opContext bounds a single Redis command by the operation timeout, so a
+// blackholed Redis cannot hold a request for the client dial/read timeouts.
+func (r *RedisCache) opContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if r.opTimeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, r.opTimeout)
+}
+
+// isTimeout reports whether err is a deadline or network timeout.
+func isTimeout(err error) bool {
+	var nerr net.Error
+	return errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &nerr) && nerr.Timeout())
+}
+
+// key returns the stored name of key.
+func (r *RedisCache) key(key string) string {
+	return r.prefix + key
 }
 
 func (r *RedisCache) Get(ctx context.Context, key string) (string, error) {
-	val, err := r.client.Get(ctx, key).Result()
+	key = r.key(key)
+	start := time.Now()
+	opCtx, cancel := r.opContext(ctx)
+	defer cancel()
+	val, err := r.client.Get(opCtx, key).Result()
 	if err == redis.Nil {
+		observe(ctx, backendRedis, opGet, start, false, nil)
 		r.log.WithContext(ctx).Debugf("cache miss: %s", key)
 		return "", nil
-	} else if err != nil {
+	}
+	observe(ctx, backendRedis, opGet, start, true, err)
+	if isTimeout(err) {
+		// a slow cache must not fail the request: fall through to the provider
+		r.log.WithContext(ctx).Warnf("cache get timed out, treating as miss: %s", key)
+		return "", nil
+	}
+	if err != nil {
 		r.log.WithContext(ctx).Errorf("cache error: %v", err)
 		return "", err
 	}
@@ -36,8 +115,52 @@ func (r *RedisCache) Get(ctx context.Context, key string) (string, error) {
 	return val, nil
 }
 
+// GetWithTTL is Get plus the remaining TTL of key, read with PTTL in the same
+// pipeline so it costs no extra round trip.
+func (r *RedisCache) GetWithTTL(ctx context.Context, key string) (string, time.Duration, error) {
+	key = r.key(key)
+	start := time.Now()
+	opCtx, cancel := r.opContext(ctx)
+	defer cancel()
+	var (
+		get  *redis.StringCmd
+		pttl *redis.DurationCmd
+	)
+	_, err := r.client.Pipelined(opCtx, func(pipe redis.Pipeliner) error {
+		get = pipe.Get(opCtx, key)
+		pttl = pipe.PTTL(opCtx, key)
+		return nil
+	})
+	if errors.Is(err, redis.Nil) {
+		observe(ctx, backendRedis, opGet, start, false, nil)
+		r.log.WithContext(ctx).Debugf("cache miss: %s", key)
+		return "", 0, nil
+	}
+	observe(ctx, backendRedis, opGet, start, true, err)
+	if isTimeout(err) {
+		r.log.WithContext(ctx).Warnf("cache get timed out, treating as miss: %s", key)
+		return "", 0, nil
+	}
+	if err != nil {
+		r.log.WithContext(ctx).Errorf("cache error: %v", err)
+		return "", 0, err
+	}
+	r.log.WithContext(ctx).Debugf("cache hit: %s", key)
+	// PTTL answers negative values for keys without expiry
+	return get.Val(), max(pttl.Val(), 0), nil
+}
+
 func (r *RedisCache) Set(ctx context.Context, key string, value string, ttl time.Duration) error {
-	err := r.client.Set(ctx, key, value, ttl).Err()
+	key = r.key(key)
+	start := time.Now()
+	opCtx, cancel := r.opContext(ctx)
+	defer cancel()
+	err := r.client.Set(opCtx, key, value, ttl).Err()
+	observe(ctx, backendRedis, opSet, start, false, err)
+	if isTimeout(err) {
+		r.log.WithContext(ctx).Warnf("cache set timed out, not cached: %s", key)
+		return nil
+	}
 	if err != nil {
 		r.log.WithContext(ctx).Errorf("cache set error: %v", err)
 	} else {
@@ -45,3 +168,153 @@ func (r *RedisCache) Set(ctx context.Context, key string, value string, ttl time
 	}
 	return err
 }
+
+func (r *RedisCache) Delete(ctx context.Context, key string) error {
+	key = r.key(key)
+	start := time.Now()
+	opCtx, cancel := r.opContext(ctx)
+	defer cancel()
+	err := r.client.Del(opCtx, key).Err()
+	observe(ctx, backendRedis, opDelete, start, false, err)
+	if err != nil {
+		r.log.WithContext(ctx).Errorf("cache delete error: %v", err)
+	} else {
+		r.log.WithContext(ctx).Debugf("cache delete: %s", key)
+	}
+	return err
+}
+
+// Incr atomically increments the counter under key and returns its new
+// value. INCR and the expiry run in one MULTI, so the counter is never left
+// without ttl.
+func (r *RedisCache) Incr(ctx context.Context, key string, ttl time.Duration) (int64, error) {
+	key = r.key(key)
+	start := time.Now()
+	opCtx, cancel := r.opContext(ctx)
+	defer cancel()
+	var incr *redis.IntCmd
+	_, err := r.client.TxPipelined(opCtx, func(pipe redis.Pipeliner) error {
+		incr = pipe.Incr(opCtx, key)
+		if ttl > 0 {
+			pipe.Expire(opCtx, key, ttl)
+		}
+		return nil
+	})
+	observe(ctx, backendRedis, opIncr, start, false, err)
+	if err != nil {
+		r.log.WithContext(ctx).Errorf("cache incr error: %v", err)
+		return 0, err
+	}
+	return incr.Val(), nil
+}
+
+// Stampede protection for GetOrSet: the fill lock expires after fillLockTTL
+// in case its holder dies, and waiting callers re-check every
+// fillPollInterval.
+const (
+	fillLockTTL      = 10 * time.Second
+	fillPollInterval = 50 * time.Millisecond
+)
+
+// releaseLock deletes a fill lock only while it is still held by the token,
+// so a fill that outlived fillLockTTL cannot release someone else's lock.
+var releaseLock = redis.NewScript(`if redis.call("GET", KEYS[1]) == ARGV[1] then return redis.call("DEL", KEYS[1]) end return 0`)
+
+// GetOrSet returns the value stored under key, calling fill and storing its
+// result for ttl on a miss. Across instances only the holder of a short-lived
+// SETNX lock runs fill; the others poll until the value appears or the lock
+// is released. When Redis fails, fill runs without the lock. Nothing is
+// stored when fill fails or returns ""; its value and error are returned.
+func (r *RedisCache) GetOrSet(ctx context.Context, key string, ttl time.Duration, fill func(ctx context.Context) (string, error)) (string, error) {
+	lockKey := r.key(key) + ":lock"
+	for {
+		val, err := r.Get(ctx, key)
+		if err != nil {
+			return fill(ctx)
+		}
+		if val != "" {
+			return val, nil
+		}
+		token := rand.Text()
+		opCtx, cancel := r.opContext(ctx)
+		locked, err := r.client.SetNX(opCtx, lockKey, token, fillLockTTL).Result()
+		cancel()
+		if err != nil {
+			r.log.WithContext(ctx).Errorf("cache lock error: %v", err)
+			return fill(ctx)
+		}
+		if locked {
+			return r.fillLocked(ctx, key, ttl, fill, lockKey, token)
+		}
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-time.After(fillPollInterval):
+		}
+	}
+}
+
+func (r *RedisCache) fillLocked(ctx context.Context, key string, ttl time.Duration, fill func(ctx context.Context) (string, error), lockKey, token string) (string, error) {
+	defer func() {
+		// release even when the request was cancelled mid-fill
+		opCtx, cancel := r.opContext(context.WithoutCancel(ctx))
+		defer cancel()
+		if err := releaseLock.Run(opCtx, r.client, []string{lockKey}, token).Err(); err != nil {
+			r.log.WithContext(ctx).Warnf("cache lock release error: %v", err)
+		}
+	}()
+	val, err := fill(ctx)
+	if err == nil && val != "" {
+		_ = r.Set(ctx, key, val, ttl)
+	}
+	return val, err
+}
+
+// scanBatch is the SCAN COUNT hint and DEL batch size used by
+// DeleteByPrefix, so a large flush never blocks Redis on a single command.
+const scanBatch = 500
+
+// DeleteByPrefix removes every key starting with prefix and returns the
+// number of keys removed. Matching keys are collected with SCAN before being
+// deleted, as deleting while iterating may make SCAN skip keys; keys written
+// meanwhile may survive.
+func (r *RedisCache) DeleteByPrefix(ctx context.Context, prefix string) (deleted int64, err error) {
+	start := time.Now()
+	defer func() { observe(ctx, backendRedis, opDeleteByPrefix, start, false, err) }()
+	prefix = r.key(prefix)
+	match := globEscaper.Replace(prefix) + "*"
+	var keys []string
+	var cursor uint64
+	for {
+		// each command gets its own timeout; a large flush may take longer
+		opCtx, cancel := r.opContext(ctx)
+		page, next, err := r.client.Scan(opCtx, cursor, match, scanBatch).Result()
+		cancel()
+		if err != nil {
+			r.log.WithContext(ctx).Errorf("cache scan error: %v", err)
+			return 0, err
+		}
+		keys = append(keys, page...)
+		if cursor = next; cursor == 0 {
+			break
+		}
+	}
+
+	for start := 0; start < len(keys); start += scanBatch {
+		batch := keys[start:min(start+scanBatch, len(keys))]
+		opCtx, cancel := r.opContext(ctx)
+		n, err := r.client.Del(opCtx, batch...).Result()
+		cancel()
+		deleted += n
+		if err != nil {
+			r.log.WithContext(ctx).Errorf("cache delete error: %v", err)
+			return deleted, err
+		}
+	}
+	r.log.WithContext(ctx).Debugf("cache delete prefix: %s (%d keys)", prefix, deleted)
+	return deleted, nil
+}
+
+// globEscaper quotes the SCAN MATCH metacharacters so the prefix is matched
+// literally.
+var globEscaper = strings.NewReplacer(`\`, `\\`, "*", `\*`, "?", `\?`, "[", `\[`, "]", `\]`)
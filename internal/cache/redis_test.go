@@ -0,0 +1,277 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/thiagozs/go-exchange/internal/logger"
+)
+
+func newTestCache(t *testing.T) (*RedisCache, *miniredis.Miniredis) {
+	t.Helper()
+	return newPrefixedTestCache(t, "", &bytes.Buffer{})
+}
+
+func newPrefixedTestCache(t *testing.T, prefix string, logOut *bytes.Buffer) (*RedisCache, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	lg := logger.New(logger.Options{Format: "text", Level: "debug", Out: logOut})
+	c := New(mr.Addr(), 0, "", "", prefix, time.Second, lg)
+	t.Cleanup(func() { _ = c.client.Close() })
+	return c, mr
+}
+
+func TestDelete(t *testing.T) {
+	c, mr := newTestCache(t)
+	ctx := context.Background()
+	if err := c.Set(ctx, "k", "v", time.Minute); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	if err := c.Delete(ctx, "k"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if mr.Exists("k") {
+		t.Fatalf("expected key to be deleted")
+	}
+	if err := c.Delete(ctx, "missing"); err != nil {
+		t.Fatalf("deleting a missing key should not fail: %v", err)
+	}
+}
+
+func TestGetWithTTL(t *testing.T) {
+	c, mr := newPrefixedTestCache(t, "app:", &bytes.Buffer{})
+	ctx := context.Background()
+	if err := c.Set(ctx, "k", "v", time.Minute); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	mr.FastForward(20 * time.Second)
+	if v, ttl, err := c.GetWithTTL(ctx, "k"); err != nil || v != "v" || ttl != 40*time.Second {
+		t.Fatalf("expected v with 40s left, got %q %v %v", v, ttl, err)
+	}
+	_ = c.Set(ctx, "forever", "w", 0)
+	if v, ttl, err := c.GetWithTTL(ctx, "forever"); err != nil || v != "w" || ttl != 0 {
+		t.Fatalf("expected w without TTL, got %q %v %v", v, ttl, err)
+	}
+	if v, ttl, err := c.GetWithTTL(ctx, "missing"); err != nil || v != "" || ttl != 0 {
+		t.Fatalf("expected a miss, got %q %v %v", v, ttl, err)
+	}
+}
+
+func TestDeleteByPrefix(t *testing.T) {
+	c, mr := newTestCache(t)
+	ctx := context.Background()
+	// more keys than one SCAN batch
+	for i := range scanBatch + 20 {
+		mr.Set(fmt.Sprintf("convert:USD:BRL:%d", i), "x")
+	}
+	mr.Set("rates:bcb:USD", "5.1")
+	mr.Set("convert*", "literal glob")
+
+	n, err := c.DeleteByPrefix(ctx, "convert:")
+	if err != nil {
+		t.Fatalf("delete by prefix: %v", err)
+	}
+	if n != scanBatch+20 {
+		t.Fatalf("expected %d keys deleted, got %d", scanBatch+20, n)
+	}
+	if keys := mr.Keys(); len(keys) != 2 {
+		t.Fatalf("expected unrelated keys to survive, got %v", keys)
+	}
+
+	// glob metacharacters in the prefix are matched literally
+	if n, err := c.DeleteByPrefix(ctx, "conv*"); err != nil || n != 0 {
+		t.Fatalf("expected no match for a literal conv*, got %d, %v", n, err)
+	}
+}
+
+func TestIncr(t *testing.T) {
+	c, mr := newPrefixedTestCache(t, "fx:", &bytes.Buffer{})
+	ctx := context.Background()
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := c.Incr(ctx, "quota", time.Hour); err != nil {
+				t.Errorf("incr: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if got, _ := c.Get(ctx, "quota"); got != "20" {
+		t.Fatalf("expected 20 increments, got %q", got)
+	}
+	if ttl := mr.TTL("fx:quota"); ttl <= 0 || ttl > time.Hour {
+		t.Fatalf("expected the counter to expire within an hour, got %v", ttl)
+	}
+}
+
+func TestKeyPrefix(t *testing.T) {
+	var logs bytes.Buffer
+	c, mr := newPrefixedTestCache(t, "staging:", &logs)
+	ctx := context.Background()
+
+	if err := c.Set(ctx, "convert:USD:BRL:1000", "v", time.Minute); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	if got, _ := mr.Get("staging:convert:USD:BRL:1000"); got != "v" {
+		t.Fatalf("expected the value under the prefixed key, got keys %v", mr.Keys())
+	}
+	if mr.Exists("convert:USD:BRL:1000") {
+		t.Fatalf("bare key must not be written")
+	}
+	if got, err := c.Get(ctx, "convert:USD:BRL:1000"); err != nil || got != "v" {
+		t.Fatalf("get: %q, %v", got, err)
+	}
+	if !strings.Contains(logs.String(), "cache hit: staging:convert:USD:BRL:1000") {
+		t.Fatalf("expected the prefixed key in debug logs, got:\n%s", logs.String())
+	}
+
+	// other namespaces are left alone
+	mr.Set("prod:convert:USD:BRL:1000", "p")
+	if n, err := c.DeleteByPrefix(ctx, "convert:"); err != nil || n != 1 {
+		t.Fatalf("expected 1 key deleted, got %d, %v", n, err)
+	}
+	if err := c.Delete(ctx, "rates:bcb:USD"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if keys := mr.Keys(); len(keys) != 1 || keys[0] != "prod:convert:USD:BRL:1000" {
+		t.Fatalf("expected only the prod key to survive, got %v", keys)
+	}
+}
+
+func TestEmptyPrefixReadsBareKeys(t *testing.T) {
+	c, mr := newTestCache(t)
+	// written by a version without CACHE_KEY_PREFIX
+	mr.Set("rates:bcb:USD", "5.1")
+	if got, err := c.Get(context.Background(), "rates:bcb:USD"); err != nil || got != "5.1" {
+		t.Fatalf("expected bare key to be read, got %q, %v", got, err)
+	}
+}
+
+func TestGetOrSetSingleFillAcrossInstances(t *testing.T) {
+	_, mr := newTestCache(t)
+	lg := logger.New(logger.Options{Format: "text", Level: "info", Out: &bytes.Buffer{}})
+	// several instances sharing one Redis
+	instances := make([]*RedisCache, 4)
+	for i := range instances {
+		instances[i] = New(mr.Addr(), 0, "", "", "", time.Second, lg)
+		t.Cleanup(func() { _ = instances[i].client.Close() })
+	}
+
+	var fills atomic.Int32
+	fill := func(ctx context.Context) (string, error) {
+		fills.Add(1)
+		time.Sleep(200 * time.Millisecond)
+		return "filled", nil
+	}
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i := range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err := instances[i%len(instances)].GetOrSet(context.Background(), "convert:USD:BRL:1000", time.Minute, fill)
+			if err == nil && v != "filled" {
+				err = fmt.Errorf("unexpected value %q", v)
+			}
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("GetOrSet: %v", err)
+		}
+	}
+	if n := fills.Load(); n != 1 {
+		t.Fatalf("expected a single fill, got %d", n)
+	}
+	if mr.Exists("convert:USD:BRL:1000:lock") {
+		t.Fatalf("expected the fill lock to be released")
+	}
+}
+
+func TestGetOrSetFailedFillIsNotStored(t *testing.T) {
+	c, mr := newTestCache(t)
+	ctx := context.Background()
+	boom := errors.New("upstream down")
+	if _, err := c.GetOrSet(ctx, "k", time.Minute, func(context.Context) (string, error) { return "", boom }); !errors.Is(err, boom) {
+		t.Fatalf("expected the fill error, got %v", err)
+	}
+	if len(mr.Keys()) != 0 {
+		t.Fatalf("expected nothing stored, got %v", mr.Keys())
+	}
+	// the lock is released, so the next caller fills right away
+	v, err := c.GetOrSet(ctx, "k", time.Minute, func(context.Context) (string, error) { return "ok", nil })
+	if err != nil || v != "ok" {
+		t.Fatalf("expected ok, got %q, %v", v, err)
+	}
+	if got, _ := mr.Get("k"); got != "ok" {
+		t.Fatalf("expected the value to be stored, got %q", got)
+	}
+}
+
+// blackholeAddr returns the address of a listener that accepts connections
+// and never answers, like Redis behind a network blackhole.
+func blackholeAddr(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	var mu sync.Mutex
+	var conns []net.Conn
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			mu.Lock()
+			conns = append(conns, c)
+			mu.Unlock()
+		}
+	}()
+	t.Cleanup(func() {
+		ln.Close()
+		mu.Lock()
+		defer mu.Unlock()
+		for _, c := range conns {
+			c.Close()
+		}
+	})
+	return ln.Addr().String()
+}
+
+func TestOpTimeoutOnUnresponsiveRedis(t *testing.T) {
+	reader := useMeter(t)
+	lg := logger.New(logger.Options{Format: "text", Level: "info", Out: &bytes.Buffer{}})
+	c := New(blackholeAddr(t), 0, "", "", "", 100*time.Millisecond, lg)
+	t.Cleanup(func() { _ = c.client.Close() })
+	ctx := context.Background()
+
+	start := time.Now()
+	if v, err := c.Get(ctx, "k"); err != nil || v != "" {
+		t.Fatalf("expected a timed-out get to be a miss, got %q, %v", v, err)
+	}
+	if err := c.Set(ctx, "k", "v", time.Minute); err != nil {
+		t.Fatalf("expected a timed-out set to be ignored, got %v", err)
+	}
+	if d := time.Since(start); d > 2*time.Second {
+		t.Fatalf("cache operations were not bounded: took %s", d)
+	}
+	if got := counts(t, reader, "cache.timeouts"); got[opGet] != 1 || got[opSet] != 1 {
+		t.Fatalf("expected one get and one set timeout, got %v", got)
+	}
+}
@@ -0,0 +1,74 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Directions of a RateAlert.
+const (
+	AlertAbove = "above"
+	AlertBelow = "below"
+)
+
+// RateAlert notifies WebhookURL when the rate of Pair crosses Threshold in
+// Direction. It clears once the rate is back past the threshold by more than
+// Hysteresis, and only then may fire again.
+type RateAlert struct {
+	Name       string  `json:"name,omitempty"`
+	Pair       string  `json:"pair"`
+	Direction  string  `json:"direction"`
+	Threshold  float64 `json:"threshold"`
+	Hysteresis float64 `json:"hysteresis,omitempty"`
+	WebhookURL string  `json:"webhook_url"`
+}
+
+// RateAlerts are the alerts of RATE_ALERTS, parsed from a JSON array:
+//
+//	[{"pair":"USD-BRL","direction":"above","threshold":5.8,"hysteresis":0.02,
+//	  "webhook_url":"https://hooks.example.com/fx"}]
+//
+// Names default to "USD-BRL above 5.8" and must be unique.
+type RateAlerts []RateAlert
+
+// UnmarshalText implements encoding.TextUnmarshaler so the env parser can
+// load RATE_ALERTS directly.
+func (a *RateAlerts) UnmarshalText(text []byte) error {
+	var alerts []RateAlert
+	if err := json.Unmarshal(text, &alerts); err != nil {
+		return fmt.Errorf("invalid rate alerts: %w", err)
+	}
+	names := map[string]bool{}
+	for i := range alerts {
+		al := &alerts[i]
+		pair, err := normalizeFeePair(al.Pair)
+		if err != nil || strings.Contains(pair, "*") {
+			return fmt.Errorf("invalid rate alert pair %q: expected FROM-TO with 3-letter codes", al.Pair)
+		}
+		al.Pair = pair
+		al.Direction = strings.ToLower(strings.TrimSpace(al.Direction))
+		if al.Direction != AlertAbove && al.Direction != AlertBelow {
+			return fmt.Errorf("rate alert %s: direction must be %q or %q, got %q", pair, AlertAbove, AlertBelow, al.Direction)
+		}
+		if al.Threshold <= 0 {
+			return fmt.Errorf("rate alert %s: threshold must be positive, got %v", pair, al.Threshold)
+		}
+		if al.Hysteresis < 0 {
+			return fmt.Errorf("rate alert %s: hysteresis must be >= 0, got %v", pair, al.Hysteresis)
+		}
+		if u, err := url.Parse(al.WebhookURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("rate alert %s: webhook_url must be an http(s) URL", pair)
+		}
+		if al.Name == "" {
+			al.Name = fmt.Sprintf("%s %s %v", pair, al.Direction, al.Threshold)
+		}
+		if names[al.Name] {
+			return fmt.Errorf("duplicate rate alert %q", al.Name)
+		}
+		names[al.Name] = true
+	}
+	*a = alerts
+	return nil
+}
@@ -0,0 +1,90 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Known API key permissions.
+const (
+	// PermCacheBypass allows honouring Cache-Control no-cache/no-store on requests.
+	PermCacheBypass = "cache_bypass"
+	// PermAdmin allows the /admin endpoints.
+	PermAdmin = "admin"
+	// PermInternal allows internal callers to skip fees with include_fee=false.
+	PermInternal = "internal"
+)
+
+// APIKey is a client credential. Name identifies the key in logs and stats so
+// the secret itself never needs to be printed. Plan names the FEE_PLANS entry
+// the key is charged by; empty means the default fee configuration.
+type APIKey struct {
+	Name        string
+	Key         string
+	Permissions []string
+	Plan        string
+}
+
+// Has reports whether the key grants perm.
+func (k APIKey) Has(perm string) bool {
+	return slices.Contains(k.Permissions, perm)
+}
+
+// APIKeys is parsed from a comma-separated list of
+// NAME:KEY[:PERM|PERM...[:META|META...]] entries, where META are key=value
+// metadata; plan=NAME is the only one known. E.g.
+// "ops:s3cr3t:cache_bypass,partner:abc123::plan=gold".
+type APIKeys []APIKey
+
+// UnmarshalText implements encoding.TextUnmarshaler so the env parser can
+// load API_KEYS directly.
+func (k *APIKeys) UnmarshalText(text []byte) error {
+	var keys APIKeys
+	seen := map[string]bool{}
+	for entry := range strings.SplitSeq(string(text), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.Split(entry, ":")
+		if len(parts) < 2 || len(parts) > 4 {
+			return fmt.Errorf("invalid API key entry %q: expected NAME:KEY[:PERMS[:META]]", entry)
+		}
+		name, key := strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+		if name == "" || key == "" {
+			return fmt.Errorf("invalid API key entry %q: name and key are required", entry)
+		}
+		if seen[name] {
+			return fmt.Errorf("duplicate API key name %q", name)
+		}
+		seen[name] = true
+		ak := APIKey{Name: name, Key: key}
+		if len(parts) >= 3 {
+			for p := range strings.SplitSeq(parts[2], "|") {
+				if p = strings.TrimSpace(p); p != "" {
+					ak.Permissions = append(ak.Permissions, p)
+				}
+			}
+		}
+		if len(parts) == 4 {
+			for m := range strings.SplitSeq(parts[3], "|") {
+				if m = strings.TrimSpace(m); m == "" {
+					continue
+				}
+				k, v, _ := strings.Cut(m, "=")
+				k, v = strings.TrimSpace(k), strings.TrimSpace(v)
+				switch {
+				case k != "plan":
+					return fmt.Errorf("invalid API key %q metadata %q: expected plan=NAME", name, m)
+				case v == "":
+					return fmt.Errorf("invalid API key %q metadata %q: plan must not be empty", name, m)
+				}
+				ak.Plan = v
+			}
+		}
+		keys = append(keys, ak)
+	}
+	*k = keys
+	return nil
+}
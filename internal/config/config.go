@@ -1,12 +1,94 @@
 package config
 
 import (
+	"errors"
 	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/caarlos0/env/v11"
 )
 
+// Redis startup policies (REDIS_STARTUP).
+const (
+	RedisStartupRequired = "required"
+	RedisStartupOptional = "optional"
+)
+
+// Cache backends (CACHE_BACKEND).
+const (
+	CacheBackendRedis  = "redis"
+	CacheBackendMemory = "memory"
+)
+
+// Rounding of the percent part of fees to whole cents (FEE_ROUNDING).
+const (
+	FeeRoundingHalfUp   = "half_up"   // ties away from zero
+	FeeRoundingHalfEven = "half_even" // ties to the even cent (banker's rounding)
+	FeeRoundingFloor    = "floor"
+	FeeRoundingCeil     = "ceil"
+)
+
+// PTAX rate sides (BCB_RATE_SIDE).
+const (
+	BCBSideBuy  = "buy"
+	BCBSideSell = "sell"
+	BCBSideMid  = "mid"
+)
+
+// PTAX bulletins (BCB_BULLETIN). BCBBulletinLatest selects the most recent
+// bulletin of the day.
+const (
+	BCBBulletinOpening      = "abertura"
+	BCBBulletinIntermediate = "intermediario"
+	BCBBulletinClosing      = "fechamento"
+	BCBBulletinLatest       = "latest"
+)
+
+// Text log coloring (LOG_COLOR).
+const (
+	LogColorAuto   = "auto"
+	LogColorAlways = "always"
+	LogColorNever  = "never"
+)
+
+// Trace samplers (OTEL_TRACES_SAMPLER), named as in the OpenTelemetry spec.
+const (
+	SamplerAlwaysOn                = "always_on"
+	SamplerAlwaysOff               = "always_off"
+	SamplerTraceIDRatio            = "traceidratio"
+	SamplerParentBasedAlwaysOn     = "parentbased_always_on"
+	SamplerParentBasedAlwaysOff    = "parentbased_always_off"
+	SamplerParentBasedTraceIDRatio = "parentbased_traceidratio"
+)
+
+// OTLP transport protocols (OTLP_PROTOCOL, OTEL_EXPORTER_OTLP_PROTOCOL).
+const (
+	OTLPProtocolGRPC = "grpc"
+	OTLPProtocolHTTP = "http/protobuf"
+)
+
+// SDK resource detectors (OTEL_RESOURCE_DETECTORS); "none" disables them.
+const (
+	ResourceDetectorEnv       = "env"
+	ResourceDetectorHost      = "host"
+	ResourceDetectorProcess   = "process"
+	ResourceDetectorContainer = "container"
+	ResourceDetectorNone      = "none"
+)
+
+// DefaultResourceDetectors are used when OTEL_RESOURCE_DETECTORS is unset.
+var DefaultResourceDetectors = []string{ResourceDetectorEnv, ResourceDetectorHost, ResourceDetectorProcess, ResourceDetectorContainer}
+
+// OTLP export compression (OTLP_COMPRESSION).
+const (
+	OTLPCompressionGzip = "gzip"
+	OTLPCompressionNone = "none"
+)
+
 type Config struct {
 	HTTPAddr         string        `env:"HTTP_ADDR" envDefault:":8080"`
 	RedisAddr        string        `env:"REDIS_ADDR" envDefault:"localhost:6379"`
@@ -15,29 +97,298 @@ type Config struct {
 	RedisPassword    string        `env:"REDIS_PASSWORD" envDefault:""`
 	RedisRequireAuth bool          `env:"REDIS_REQUIRE_AUTH" envDefault:"false"`
 	CacheTTL         time.Duration `env:"CACHE_TTL" envDefault:"5m"`
+	CacheKeyPrefix   string        `env:"CACHE_KEY_PREFIX" envDefault:""` // prepended to every Redis key, e.g. "staging:"
 	FeeAPIURL        string        `env:"FEE_API_URL" envDefault:""`
 	FeePercent       float64       `env:"EXCHANGE_FEE_PERCENT" envDefault:"0"`
+	FeeCacheTTL      time.Duration `env:"FEE_CACHE_TTL" envDefault:"5m"` // FEE_API_URL cache per pair and amount band, 0 disables
+	// FEE_API_URL client: per-attempt timeout, retries on network errors and
+	// 5xx, and an optional credential header. With FEE_FAIL_OPEN=false an
+	// unavailable fee API fails the conversion (502) instead of charging no fee.
+	FeeAPITimeout    time.Duration `env:"FEE_API_TIMEOUT" envDefault:"5s"`
+	FeeAPIMaxRetries int           `env:"FEE_API_MAX_RETRIES" envDefault:"2"`
+	FeeAPIAuthHeader string        `env:"FEE_API_AUTH_HEADER" envDefault:"Authorization"`
+	FeeAPIAuthToken  string        `env:"FEE_API_AUTH_TOKEN" envDefault:""`
+	FeeFailOpen      bool          `env:"FEE_FAIL_OPEN" envDefault:"true"`
+	// How the percent part of a fee is rounded to whole cents.
+	FeeRounding string `env:"FEE_ROUNDING" envDefault:"half_up"`
+	// REDIS_STARTUP=optional, the default, logs a warning and falls back to
+	// an in-process cache when Redis does not answer PING within
+	// REDIS_STARTUP_TIMEOUT; required fails startup instead. An empty
+	// REDIS_ADDR always uses the in-process cache.
+	RedisStartup        string        `env:"REDIS_STARTUP" envDefault:"optional"`
+	RedisStartupTimeout time.Duration `env:"REDIS_STARTUP_TIMEOUT" envDefault:"5s"`
+	// Bound on each Redis command; timed-out reads count as cache misses and
+	// timed-out writes are logged and skipped.
+	RedisOpTimeout time.Duration `env:"REDIS_OP_TIMEOUT" envDefault:"250ms"`
+	// CACHE_BACKEND=memory always uses the in-process cache and never
+	// contacts Redis; redis follows REDIS_ADDR and REDIS_STARTUP.
+	CacheBackend string `env:"CACHE_BACKEND" envDefault:"redis"`
+	// Upper bound on conversion amounts in cents (422 above it); 0 disables it.
+	MaxAmountCents int64 `env:"MAX_AMOUNT_CENTS" envDefault:"100000000000"`
+	// Amounts without a unit (cents or major) are read as cents when
+	// integers and as major units when decimals, logging a deprecation
+	// warning; false makes the unit mandatory, as AmountUnitRequired, set by
+	// Load.
+	AmountHeuristic    bool `env:"AMOUNT_HEURISTIC" envDefault:"true"`
+	AmountUnitRequired bool `env:"-"`
+	// Base currencies whose rates are prefetched at startup and by the
+	// warmup subcommand.
+	WarmupBases []string `env:"WARMUP_BASES" envSeparator:","`
+	// Per-pair fees: EXCHANGE_FEES entries (PAIR=PERCENT, see FeeRules)
+	// override the rules read from the FEE_CONFIG_PATH JSON file. When set they
+	// take precedence over EXCHANGE_FEE_PERCENT.
+	Fees          FeeRules `env:"EXCHANGE_FEES"`
+	FeeConfigPath string   `env:"FEE_CONFIG_PATH" envDefault:""`
+	// Fixed fee and bounds in cents, added to whichever percent applies. The
+	// EXCHANGE_FEE_*_CENTS values are the default limits; per-pair limits come
+	// from FEE_CONFIG_PATH and are merged into FeeLimits by Load.
+	FeeFixedCents int64     `env:"EXCHANGE_FEE_FIXED_CENTS" envDefault:"0"`
+	FeeMinCents   int64     `env:"EXCHANGE_FEE_MIN_CENTS" envDefault:"0"`
+	FeeMaxCents   int64     `env:"EXCHANGE_FEE_MAX_CENTS" envDefault:"0"`
+	FeeLimits     FeeLimits `env:"-"`
+	// Pairs converted without any fee (see FeePairSet).
+	FeeExemptPairs FeePairSet `env:"FEE_EXEMPT_PAIRS"`
+	// Tiered fees by gross converted amount, as a JSON schedule (see
+	// FeeTiers) in FEE_TIERS or in the FEE_TIERS_PATH file. When set they take
+	// precedence over EXCHANGE_FEES and EXCHANGE_FEE_PERCENT.
+	FeeTiers     FeeTiers `env:"FEE_TIERS"`
+	FeeTiersPath string   `env:"FEE_TIERS_PATH" envDefault:""`
+	// Named fee plans (JSON, see FeePlans) API keys are charged by instead
+	// of the configuration above, which stays the default plan.
+	FeePlans FeePlans `env:"FEE_PLANS"`
 	// Exchangerate.host or others - specific settings
 	Provider       string `env:"EXCHANGE_PROVIDER" envDefault:"exchangerate.host"`
 	ExchangeAPIKey string `env:"EXCHANGE_API_KEY" envDefault:""`
+	// Providers a /convert request may pick with ?provider=, built next to
+	// EXCHANGE_PROVIDER; empty disables the parameter.
+	ProviderOverrides []string `env:"ALLOWED_PROVIDER_OVERRIDES" envSeparator:","`
+	// Static provider (EXCHANGE_PROVIDER=static): rates file and the pivot
+	// currency used for pairs missing from it.
+	StaticRatesPath  string `env:"STATIC_RATES_PATH"`
+	StaticRatesPivot string `env:"STATIC_RATES_PIVOT" envDefault:"USD"`
+	// Aggregate provider (EXCHANGE_PROVIDER=aggregate): sources queried in
+	// parallel, combined with median or mean. A quorum of 0 means a majority.
+	AggregateSources       []string      `env:"AGGREGATE_SOURCES" envSeparator:","`
+	AggregateMethod        string        `env:"AGGREGATE_METHOD" envDefault:"median"`
+	AggregateQuorum        int           `env:"AGGREGATE_QUORUM" envDefault:"0"`
+	AggregateSourceTimeout time.Duration `env:"AGGREGATE_SOURCE_TIMEOUT" envDefault:"5s"`
 	// BCB / PTAX provider specific settings
 	BCBAPIBaseURL  string        `env:"BCB_API_BASE_URL" envDefault:"https://olinda.bcb.gov.br/olinda/servico/PTAX/versao/v1/odata/"`
 	BCBTimeout     time.Duration `env:"BCB_TIMEOUT_SECONDS" envDefault:"10s"`
 	BCBMaxRetries  int           `env:"BCB_MAX_RETRIES" envDefault:"3"`
 	BCBMaxBackDays int           `env:"BCB_MAX_BACK_DAYS" envDefault:"0"`
+	// Brazilian bank holidays (YYYY-MM-DD, comma-separated) skipped, like
+	// weekends, when walking back BCB_MAX_BACK_DAYS business days.
+	BCBHolidays []string `env:"BCB_HOLIDAYS" envSeparator:","`
+	// PTAX quote used: rate side (buy|sell|mid) and bulletin
+	// (abertura|intermediario|fechamento|latest).
+	BCBRateSide string `env:"BCB_RATE_SIDE" envDefault:"sell"`
+	BCBBulletin string `env:"BCB_BULLETIN" envDefault:"latest"`
+	// Rate freshness: reject upstream rates older than MAX_RATE_AGE (0 disables).
+	// Freshness uses a clock corrected by the skew measured against providers
+	// once it exceeds CLOCK_SKEW_THRESHOLD.
+	MaxRateAge         time.Duration `env:"MAX_RATE_AGE" envDefault:"0"`
+	ClockSkewThreshold time.Duration `env:"CLOCK_SKEW_THRESHOLD" envDefault:"5s"`
+	// Outbound URL policy for provider and fee API calls: https only and no
+	// loopback/link-local/private destinations unless explicitly allowed.
+	OutboundAllowHTTP    bool     `env:"OUTBOUND_ALLOW_HTTP" envDefault:"false"`
+	OutboundAllowPrivate bool     `env:"OUTBOUND_ALLOW_PRIVATE" envDefault:"false"`
+	OutboundAllowedHosts []string `env:"OUTBOUND_ALLOWED_HOSTS" envSeparator:","`
+	// HTTP client shared by the rate providers. OUTBOUND_PROXY overrides the
+	// HTTPS_PROXY/HTTP_PROXY environment variables.
+	ProviderHTTPTimeout         time.Duration `env:"PROVIDER_HTTP_TIMEOUT" envDefault:"15s"`
+	ProviderMaxIdleConns        int           `env:"PROVIDER_MAX_IDLE_CONNS" envDefault:"100"`
+	ProviderMaxIdleConnsPerHost int           `env:"PROVIDER_MAX_IDLE_CONNS_PER_HOST" envDefault:"10"`
+	ProviderTLSHandshakeTimeout time.Duration `env:"PROVIDER_TLS_HANDSHAKE_TIMEOUT" envDefault:"10s"`
+	OutboundProxy               string        `env:"OUTBOUND_PROXY"`
+	// Identification sent on provider and fee API requests: PROVIDER_USER_AGENT
+	// (empty: go-exchange/<version>) and comma-separated KEY=VALUE headers.
+	ProviderUserAgent    string `env:"PROVIDER_USER_AGENT"`
+	ProviderExtraHeaders string `env:"PROVIDER_EXTRA_HEADERS"`
+	// Retries for exchangerate.host / exchangerate-api requests (network
+	// errors, 5xx and 429). BCB uses BCB_MAX_RETRIES.
+	ProviderRetryMaxAttempts    int           `env:"PROVIDER_RETRY_MAX_ATTEMPTS" envDefault:"3"`
+	ProviderRetryInitialBackoff time.Duration `env:"PROVIDER_RETRY_INITIAL_BACKOFF" envDefault:"200ms"`
+	ProviderRetryMaxBackoff     time.Duration `env:"PROVIDER_RETRY_MAX_BACKOFF" envDefault:"5s"`
+	ProviderRetryJitter         float64       `env:"PROVIDER_RETRY_JITTER" envDefault:"0.2"`
+	// Bytes of upstream response bodies included in provider logs.
+	ProviderLogBodyLimit int `env:"PROVIDER_LOG_BODY_LIMIT" envDefault:"512"`
+	// exchangerate.host and exchangerate-api derive the rates of base
+	// currencies they refuse (free plans) from the FALLBACK_BASE table; "off"
+	// disables it.
+	FallbackBase string `env:"FALLBACK_BASE" envDefault:"USD"`
+	// Providers registered with provider.Register convert pairs they have no
+	// direct rate for through PIVOT_CURRENCY; "off" disables it.
+	PivotCurrency string `env:"PIVOT_CURRENCY" envDefault:"USD"`
+	// Upstream rate cache: RATES_CACHE_TTL is how long fetched rates are kept
+	// (0 disables caching) and can be overridden per provider. Past
+	// RATES_SOFT_TTL cached rates are served marked as stale while a
+	// background refresh runs; a zero soft TTL disables stale serving.
+	// RATES_HARD_TTL is the deprecated name of RATES_CACHE_TTL, read when
+	// RATES_CACHE_TTL is not set.
+	RatesCacheTTL                 time.Duration  `env:"RATES_CACHE_TTL" envDefault:"20m"`
+	RatesHardTTL                  *time.Duration `env:"RATES_HARD_TTL"`
+	RatesSoftTTL                  time.Duration  `env:"RATES_SOFT_TTL" envDefault:"0"`
+	ExchangerateHostRatesCacheTTL *time.Duration `env:"EXCHANGERATE_HOST_RATES_CACHE_TTL"`
+	ExchangeRateAPIRatesCacheTTL  *time.Duration `env:"EXCHANGERATE_API_RATES_CACHE_TTL"`
+	BCBRatesCacheTTL              *time.Duration `env:"BCB_RATES_CACHE_TTL"`
+	// Monthly upstream request quotas as provider=requests pairs
+	// (exchangerate-api=1500); requests are counted either way. Past
+	// PROVIDER_QUOTA_SOFT_THRESHOLD of a quota warnings are logged and the
+	// rate cache TTLs are multiplied by PROVIDER_QUOTA_TTL_MULTIPLIER; once it
+	// is used up the provider is not called until next month (UTC).
+	ProviderMonthlyQuota       map[string]int64 `env:"PROVIDER_MONTHLY_QUOTA" envSeparator:"," envKeyValSeparator:"="`
+	ProviderQuotaSoftThreshold float64          `env:"PROVIDER_QUOTA_SOFT_THRESHOLD" envDefault:"0.8"`
+	ProviderQuotaTTLMultiplier float64          `env:"PROVIDER_QUOTA_TTL_MULTIPLIER" envDefault:"4"`
+	// Hot bases: requests are counted per base currency with a decay of
+	// HOT_REFRESH_HALF_LIFE, and the rates of bases scoring at least
+	// HOT_REFRESH_THRESHOLD are refetched HOT_REFRESH_LEAD before they expire,
+	// checked every HOT_REFRESH_INTERVAL. A zero threshold disables it.
+	HotRefreshThreshold float64       `env:"HOT_REFRESH_THRESHOLD" envDefault:"0"`
+	HotRefreshHalfLife  time.Duration `env:"HOT_REFRESH_HALF_LIFE" envDefault:"5m"`
+	HotRefreshLead      time.Duration `env:"HOT_REFRESH_LEAD" envDefault:"1m"`
+	HotRefreshInterval  time.Duration `env:"HOT_REFRESH_INTERVAL" envDefault:"10s"`
+	// API keys: comma-separated NAME:KEY[:PERM|PERM[:plan=NAME]] entries.
+	// Requests may authenticate with X-API-Key or a Bearer token; anonymous
+	// access is kept.
+	APIKeys APIKeys `env:"API_KEYS" envDefault:""`
+	// Admin endpoints (cache flush), restricted to API keys with the admin
+	// permission.
+	AdminEnabled bool `env:"ADMIN_ENABLED" envDefault:"false"`
+	// Reject query parameters an endpoint does not read (400
+	// UNKNOWN_PARAMETERS) instead of ignoring them.
+	StrictQueryParams bool `env:"STRICT_QUERY_PARAMS" envDefault:"false"`
+	// Bounds each component check of GET /health?verbose=true.
+	HealthCheckTimeout time.Duration `env:"HEALTH_CHECK_TIMEOUT" envDefault:"2s"`
+	// Swagger UI for /openapi.json at /docs.
+	DocsEnabled bool `env:"DOCS_ENABLED" envDefault:"false"`
+	// net/http/pprof and runtime stats under /debug/, served on DEBUG_ADDR
+	// only, never on the public HTTP_ADDR.
+	DebugEndpoints bool   `env:"DEBUG_ENDPOINTS" envDefault:"false"`
+	DebugAddr      string `env:"DEBUG_ADDR" envDefault:":6060"`
+	// gRPC API (Convert, GetRate): listens on GRPC_ADDR when set; server
+	// reflection is opt-in.
+	GRPCAddr       string `env:"GRPC_ADDR" envDefault:""`
+	GRPCReflection bool   `env:"GRPC_REFLECTION" envDefault:"false"`
+	// REQUEST_TIMEOUT bounds each HTTP request but /stream/rates; 0 disables
+	// it.
+	RequestTimeout time.Duration `env:"REQUEST_TIMEOUT" envDefault:"10s"`
+	// POST /convert/batch responses to requests sent with an Idempotency-Key
+	// are kept in the cache for IDEMPOTENCY_TTL and replayed to retries; 0
+	// ignores the header.
+	IdempotencyTTL time.Duration `env:"IDEMPOTENCY_TTL" envDefault:"24h"`
+	// Responses of at least GZIP_MIN_SIZE bytes are gzipped for clients
+	// accepting it; 0 disables compression.
+	GzipMinSize int `env:"GZIP_MIN_SIZE" envDefault:"1024"`
+	// Load shedding: at most MAX_INFLIGHT_REQUESTS requests are served at
+	// once (0 disables the limit) and up to MAX_QUEUED_REQUESTS more wait up
+	// to MAX_QUEUE_WAIT for a slot; anything beyond gets 503.
+	MaxInflightRequests int           `env:"MAX_INFLIGHT_REQUESTS" envDefault:"256"`
+	MaxQueuedRequests   int           `env:"MAX_QUEUED_REQUESTS" envDefault:"512"`
+	MaxQueueWait        time.Duration `env:"MAX_QUEUE_WAIT" envDefault:"2s"`
+	// Rate stream (GET /stream/rates): up to STREAM_MAX_SUBSCRIBERS open
+	// streams (0 disables it) of up to STREAM_MAX_PAIRS pairs each. Pairs are
+	// polled no more often than STREAM_MIN_INTERVAL and an update is sent when
+	// the rate moves by more than STREAM_RATE_EPSILON.
+	StreamMaxSubscribers int           `env:"STREAM_MAX_SUBSCRIBERS" envDefault:"100"`
+	StreamMaxPairs       int           `env:"STREAM_MAX_PAIRS" envDefault:"10"`
+	StreamMinInterval    time.Duration `env:"STREAM_MIN_INTERVAL" envDefault:"5s"`
+	StreamRateEpsilon    float64       `env:"STREAM_RATE_EPSILON" envDefault:"0"`
+	// Rate alerts: RATE_ALERTS (JSON, see RateAlerts) are checked every
+	// ALERT_CHECK_INTERVAL and delivered to their webhook, signed with
+	// ALERT_WEBHOOK_SECRET when set.
+	RateAlerts             RateAlerts    `env:"RATE_ALERTS"`
+	AlertCheckInterval     time.Duration `env:"ALERT_CHECK_INTERVAL" envDefault:"1m"`
+	AlertWebhookSecret     string        `env:"ALERT_WEBHOOK_SECRET" envDefault:""`
+	AlertWebhookTimeout    time.Duration `env:"ALERT_WEBHOOK_TIMEOUT" envDefault:"5s"`
+	AlertWebhookMaxRetries int           `env:"ALERT_WEBHOOK_MAX_RETRIES" envDefault:"3"`
 	// Logger configuration
-	LogFormat     string `env:"LOG_FORMAT" envDefault:"text"` // text or json
-	LogLevel      string `env:"LOG_LEVEL" envDefault:"info"`
-	OTelCollector string `env:"OTEL_COLLECTOR_URL" envDefault:""` // optional OTEL collector endpoint
+	LogFormat string `env:"LOG_FORMAT" envDefault:"text"` // text or json
+	LogLevel  string `env:"LOG_LEVEL" envDefault:"info"`
+	// LOG_COLOR colors the text format level: auto only when writing to a
+	// terminal, always or never.
+	LogColor string `env:"LOG_COLOR" envDefault:"auto"`
+	// LOG_CALLER adds the calling file:line:func as the file field; turning
+	// it off saves a stack walk per entry.
+	LogCaller bool `env:"LOG_CALLER" envDefault:"true"`
+	// LOG_REDACT_KEYS: field names whose values are logged as "***"; key=value
+	// pairs with these names are also scrubbed from messages and values.
+	LogRedactKeys []string `env:"LOG_REDACT_KEYS" envSeparator:"," envDefault:"api_key,access_key,authorization,password"`
+	// Duplicate log suppression: at most LOG_DEDUP_BURST similar entries per
+	// LOG_DEDUP_INTERVAL (0 disables), the rest are summarized.
+	LogDedupInterval time.Duration `env:"LOG_DEDUP_INTERVAL" envDefault:"0"`
+	LogDedupBurst    int           `env:"LOG_DEDUP_BURST" envDefault:"10"`
+	OTelCollector    string        `env:"OTEL_COLLECTOR_URL" envDefault:""` // optional OTEL collector endpoint
+	// Access log throttling: above ACCESS_LOG_RATE_LIMIT req/s (0 disables) only
+	// one in ACCESS_LOG_SAMPLE_N successful requests is logged; errors and
+	// requests slower than ACCESS_LOG_SLOW_THRESHOLD are always logged.
+	AccessLogRateLimit       float64       `env:"ACCESS_LOG_RATE_LIMIT" envDefault:"0"`
+	AccessLogBurst           int           `env:"ACCESS_LOG_BURST" envDefault:"0"`
+	AccessLogSampleN         int           `env:"ACCESS_LOG_SAMPLE_N" envDefault:"100"`
+	AccessLogSlowThreshold   time.Duration `env:"ACCESS_LOG_SLOW_THRESHOLD" envDefault:"1s"`
+	AccessLogSummaryInterval time.Duration `env:"ACCESS_LOG_SUMMARY_INTERVAL" envDefault:"30s"`
+	// Paths never written to the access log (their spans are kept), and the
+	// share of successful requests logged; errors and slow requests always
+	// are.
+	AccessLogExcludePaths []string `env:"ACCESS_LOG_EXCLUDE_PATHS" envSeparator:"," envDefault:"/health,/ready,/metrics"`
+	AccessLogSampleRate   float64  `env:"ACCESS_LOG_SAMPLE_RATE" envDefault:"1"`
+	// Send the per-dependency timing breakdown (cache, provider, fee, total) as
+	// a Server-Timing response header.
+	ServerTiming bool `env:"SERVER_TIMING" envDefault:"true"`
 	// Advanced OTLP options
 	OTLPEndpoint string `env:"OTLP_ENDPOINT" envDefault:""` // explicit OTLP endpoint (overrides OTEL_COLLECTOR_URL)
 	OTLPHeaders  string `env:"OTLP_HEADERS" envDefault:""`  // comma-separated headers KEY=VALUE
 	OTLPUseTLS   bool   `env:"OTLP_USE_TLS" envDefault:"false"`
+	// OTLP transport (grpc or http/protobuf). When set it replaces the
+	// scheme/port detection; the per-signal variables override OTLP_PROTOCOL,
+	// which overrides OTEL_EXPORTER_OTLP_PROTOCOL.
+	OTLPProtocol        string `env:"OTLP_PROTOCOL" envDefault:""`
+	OTLPTracesProtocol  string `env:"OTLP_TRACES_PROTOCOL" envDefault:""`
+	OTLPMetricsProtocol string `env:"OTLP_METRICS_PROTOCOL" envDefault:""`
+	OTLPLogsProtocol    string `env:"OTLP_LOGS_PROTOCOL" envDefault:""`
+	// Switch a signal to the protocol the startup probe detected when it does
+	// not match the exporter (only when no protocol is set explicitly);
+	// otherwise the mismatch is just logged.
+	OTLPAutodetect bool `env:"OTLP_AUTODETECT" envDefault:"false"`
+	// Per-signal endpoints and headers override the shared collector and
+	// OTLP_HEADERS; an endpoint of "off" disables that signal.
+	OTLPTracesEndpoint  string `env:"OTLP_TRACES_ENDPOINT" envDefault:""`
+	OTLPMetricsEndpoint string `env:"OTLP_METRICS_ENDPOINT" envDefault:""`
+	OTLPLogsEndpoint    string `env:"OTLP_LOGS_ENDPOINT" envDefault:""`
+	OTLPTracesHeaders   string `env:"OTLP_TRACES_HEADERS" envDefault:""`
+	OTLPMetricsHeaders  string `env:"OTLP_METRICS_HEADERS" envDefault:""`
+	OTLPLogsHeaders     string `env:"OTLP_LOGS_HEADERS" envDefault:""`
+	// Batch span/log processor tuning; zero keeps the SDK defaults (queue of
+	// 2048, batches of 512, 30s export timeout). OTLP_COMPRESSION applies to
+	// the trace, metric and log exporters.
+	OTLPBatchQueueSize      int           `env:"OTLP_BATCH_QUEUE_SIZE" envDefault:"0"`
+	OTLPBatchExportTimeout  time.Duration `env:"OTLP_BATCH_EXPORT_TIMEOUT" envDefault:"0"`
+	OTLPBatchMaxExportBatch int           `env:"OTLP_BATCH_MAX_EXPORT_BATCH" envDefault:"0"`
+	OTLPCompression         string        `env:"OTLP_COMPRESSION" envDefault:"none"`
+	// Attach trace exemplars to histogram observations made under a sampled span
+	// (not every metrics backend accepts exemplars).
+	MetricsExemplars bool `env:"METRICS_EXEMPLARS" envDefault:"false"`
 	// TLS customization for OTLP exporters (optional)
 	OTLPTLSCAPath          string `env:"OTLP_TLS_CA_PATH" envDefault:""`
 	OTLPTLSCertPath        string `env:"OTLP_TLS_CERT_PATH" envDefault:""`
 	OTLPTLSKeyPath         string `env:"OTLP_TLS_KEY_PATH" envDefault:""`
 	OTLPInsecureSkipVerify bool   `env:"OTLP_INSECURE_SKIP_VERIFY" envDefault:"false"`
+	// Standard OpenTelemetry variables, used only when the custom ones above are
+	// empty: OTLP_ENDPOINT > OTEL_COLLECTOR_URL > OTEL_EXPORTER_OTLP_ENDPOINT and
+	// OTLP_HEADERS > OTEL_EXPORTER_OTLP_HEADERS. OTEL_RESOURCE_ATTRIBUTES is
+	// merged into the SDK resource under the APP_* attributes.
+	OTelExporterEndpoint   string `env:"OTEL_EXPORTER_OTLP_ENDPOINT" envDefault:""`
+	OTelExporterHeaders    string `env:"OTEL_EXPORTER_OTLP_HEADERS" envDefault:""`
+	OTelExporterProtocol   string `env:"OTEL_EXPORTER_OTLP_PROTOCOL" envDefault:""` // grpc or http/protobuf
+	OTelResourceAttributes string `env:"OTEL_RESOURCE_ATTRIBUTES" envDefault:""`
+	// Detectors adding host, process and container attributes to the
+	// resource; configured attributes win over detected ones.
+	OTelResourceDetectors []string `env:"OTEL_RESOURCE_DETECTORS" envSeparator:","`
+	// Trace sampling. The ratio samplers take OTEL_TRACES_SAMPLER_ARG in
+	// [0,1] (default 1); the default keeps every trace while honouring the
+	// parent's sampling decision.
+	OTelTracesSampler    string `env:"OTEL_TRACES_SAMPLER" envDefault:"parentbased_traceidratio"`
+	OTelTracesSamplerArg string `env:"OTEL_TRACES_SAMPLER_ARG" envDefault:""`
 	// Application environment (development, staging, production, etc)
 	Environment string `env:"ENVIRONMENT" envDefault:"development"`
 	// Service identification
@@ -46,11 +397,36 @@ type Config struct {
 	AppEnv     string `env:"APP_ENV" envDefault:"development"`
 }
 
+// Load parses the environment, on top of the CONFIG_FILE file when it is set,
+// and validates it. Validation problems are reported together, joined into
+// one error.
 func Load() (*Config, error) {
+	return LoadFile(os.Getenv("CONFIG_FILE"))
+}
+
+// LoadFile is Load with the settings in the YAML file at path as defaults:
+// environment variables override them field by field. An empty path reads
+// the environment only.
+func LoadFile(path string) (*Config, error) {
+	environ := env.ToMap(os.Environ())
+	if path != "" {
+		vars, err := readConfigFile(path)
+		if err != nil {
+			return nil, err
+		}
+		for name, v := range vars {
+			if _, set := environ[name]; !set {
+				environ[name] = v
+			}
+		}
+	}
 	cfg := &Config{}
-	if err := env.Parse(cfg); err != nil {
+	if err := env.ParseWithOptions(cfg, env.Options{Environment: environ}); err != nil {
 		return nil, err
 	}
+	if _, set := environ["RATES_CACHE_TTL"]; !set && cfg.RatesHardTTL != nil {
+		cfg.RatesCacheTTL = *cfg.RatesHardTTL
+	}
 	// basic validation
 	if cfg.HTTPAddr == "" {
 		cfg.HTTPAddr = ":8080"
@@ -58,17 +434,317 @@ func Load() (*Config, error) {
 	if cfg.CacheTTL <= 0 {
 		cfg.CacheTTL = 5 * time.Minute
 	}
-	// Warn if Redis address is configured but no password is set. Many Redis
-	// deployments require authentication; this helps catch that misconfiguration.
-	if cfg.RedisAddr != "" && cfg.RedisPassword == "" {
-		// Print a simple warning; avoid creating a logger here to keep
-		// config loading simple and free of side-effects.
-		fmt.Printf("WARNING: REDIS_ADDR is set (%s) but REDIS_PASSWORD is empty. If your Redis requires auth, set REDIS_PASSWORD.\n", cfg.RedisAddr)
+	var errs []error
+	if cfg.RedisStartup != RedisStartupRequired && cfg.RedisStartup != RedisStartupOptional {
+		errs = append(errs, fmt.Errorf("REDIS_STARTUP must be %q or %q, got %q", RedisStartupRequired, RedisStartupOptional, cfg.RedisStartup))
+	}
+	if cfg.CacheBackend != CacheBackendRedis && cfg.CacheBackend != CacheBackendMemory {
+		errs = append(errs, fmt.Errorf("CACHE_BACKEND must be %q or %q, got %q", CacheBackendRedis, CacheBackendMemory, cfg.CacheBackend))
+	}
+	if cfg.LogDedupInterval < 0 {
+		errs = append(errs, fmt.Errorf("LOG_DEDUP_INTERVAL must not be negative, got %s", cfg.LogDedupInterval))
+	}
+	if cfg.LogDedupInterval > 0 && cfg.LogDedupBurst < 1 {
+		errs = append(errs, fmt.Errorf("LOG_DEDUP_BURST must be at least 1, got %d", cfg.LogDedupBurst))
+	}
+	switch cfg.LogColor {
+	case LogColorAuto, LogColorAlways, LogColorNever:
+	default:
+		errs = append(errs, fmt.Errorf("LOG_COLOR must be %q, %q or %q, got %q", LogColorAuto, LogColorAlways, LogColorNever, cfg.LogColor))
+	}
+	for _, p := range []struct{ name, value string }{
+		{"OTLP_PROTOCOL", cfg.OTLPProtocol},
+		{"OTLP_TRACES_PROTOCOL", cfg.OTLPTracesProtocol},
+		{"OTLP_METRICS_PROTOCOL", cfg.OTLPMetricsProtocol},
+		{"OTLP_LOGS_PROTOCOL", cfg.OTLPLogsProtocol},
+		{"OTEL_EXPORTER_OTLP_PROTOCOL", cfg.OTelExporterProtocol},
+	} {
+		switch p.value {
+		case "", OTLPProtocolGRPC, OTLPProtocolHTTP:
+		default:
+			errs = append(errs, fmt.Errorf("%s must be %q or %q, got %q", p.name, OTLPProtocolGRPC, OTLPProtocolHTTP, p.value))
+		}
+	}
+	if detectors, err := NormalizeResourceDetectors(cfg.OTelResourceDetectors); err != nil {
+		errs = append(errs, err)
+	} else {
+		cfg.OTelResourceDetectors = detectors
+	}
+	if cfg.OTLPCompression != OTLPCompressionGzip && cfg.OTLPCompression != OTLPCompressionNone {
+		errs = append(errs, fmt.Errorf("OTLP_COMPRESSION must be %q or %q, got %q", OTLPCompressionGzip, OTLPCompressionNone, cfg.OTLPCompression))
+	}
+	if cfg.OTLPBatchQueueSize < 0 {
+		errs = append(errs, fmt.Errorf("OTLP_BATCH_QUEUE_SIZE must be >= 0, got %d", cfg.OTLPBatchQueueSize))
+	}
+	if cfg.OTLPBatchMaxExportBatch < 0 {
+		errs = append(errs, fmt.Errorf("OTLP_BATCH_MAX_EXPORT_BATCH must be >= 0, got %d", cfg.OTLPBatchMaxExportBatch))
+	}
+	if cfg.OTLPBatchQueueSize > 0 && cfg.OTLPBatchMaxExportBatch > cfg.OTLPBatchQueueSize {
+		errs = append(errs, fmt.Errorf("OTLP_BATCH_MAX_EXPORT_BATCH (%d) must not exceed OTLP_BATCH_QUEUE_SIZE (%d)", cfg.OTLPBatchMaxExportBatch, cfg.OTLPBatchQueueSize))
+	}
+	if cfg.OTLPBatchExportTimeout < 0 {
+		errs = append(errs, fmt.Errorf("OTLP_BATCH_EXPORT_TIMEOUT must be >= 0, got %s", cfg.OTLPBatchExportTimeout))
+	}
+	if _, err := SamplerRatio(cfg.OTelTracesSampler, cfg.OTelTracesSamplerArg); err != nil {
+		errs = append(errs, err)
+	}
+	cfg.FallbackBase = strings.ToUpper(strings.TrimSpace(cfg.FallbackBase))
+	if cfg.FallbackBase != "OFF" && (len(cfg.FallbackBase) != 3 || strings.Trim(cfg.FallbackBase, "ABCDEFGHIJKLMNOPQRSTUVWXYZ") != "") {
+		errs = append(errs, fmt.Errorf("FALLBACK_BASE must be a 3-letter currency code or off, got %q", cfg.FallbackBase))
+	}
+	for name, quota := range cfg.ProviderMonthlyQuota {
+		if quota < 0 {
+			errs = append(errs, fmt.Errorf("PROVIDER_MONTHLY_QUOTA for %s must be >= 0, got %d", name, quota))
+		}
+	}
+	if cfg.ProviderQuotaSoftThreshold <= 0 || cfg.ProviderQuotaSoftThreshold > 1 {
+		errs = append(errs, fmt.Errorf("PROVIDER_QUOTA_SOFT_THRESHOLD must be in (0, 1], got %v", cfg.ProviderQuotaSoftThreshold))
+	}
+	if cfg.ProviderQuotaTTLMultiplier < 1 {
+		errs = append(errs, fmt.Errorf("PROVIDER_QUOTA_TTL_MULTIPLIER must be >= 1, got %v", cfg.ProviderQuotaTTLMultiplier))
+	}
+	cfg.AmountUnitRequired = !cfg.AmountHeuristic
+	if cfg.HotRefreshThreshold < 0 {
+		errs = append(errs, fmt.Errorf("HOT_REFRESH_THRESHOLD must be >= 0, got %v", cfg.HotRefreshThreshold))
+	}
+	if cfg.HotRefreshThreshold > 0 {
+		if cfg.HotRefreshHalfLife <= 0 {
+			errs = append(errs, fmt.Errorf("HOT_REFRESH_HALF_LIFE must be positive, got %s", cfg.HotRefreshHalfLife))
+		}
+		if cfg.HotRefreshLead <= 0 {
+			errs = append(errs, fmt.Errorf("HOT_REFRESH_LEAD must be positive, got %s", cfg.HotRefreshLead))
+		}
+		if cfg.HotRefreshInterval <= 0 {
+			errs = append(errs, fmt.Errorf("HOT_REFRESH_INTERVAL must be positive, got %s", cfg.HotRefreshInterval))
+		} else if cfg.HotRefreshLead > 0 && cfg.HotRefreshInterval >= cfg.HotRefreshLead {
+			errs = append(errs, fmt.Errorf("HOT_REFRESH_INTERVAL must be shorter than HOT_REFRESH_LEAD %s, got %s", cfg.HotRefreshLead, cfg.HotRefreshInterval))
+		}
+	}
+	cfg.PivotCurrency = strings.ToUpper(strings.TrimSpace(cfg.PivotCurrency))
+	if cfg.PivotCurrency != "OFF" && (len(cfg.PivotCurrency) != 3 || strings.Trim(cfg.PivotCurrency, "ABCDEFGHIJKLMNOPQRSTUVWXYZ") != "") {
+		errs = append(errs, fmt.Errorf("PIVOT_CURRENCY must be a 3-letter currency code or off, got %q", cfg.PivotCurrency))
+	}
+	if cfg.AccessLogSampleRate < 0 || cfg.AccessLogSampleRate > 1 {
+		errs = append(errs, fmt.Errorf("ACCESS_LOG_SAMPLE_RATE must be between 0 and 1, got %v", cfg.AccessLogSampleRate))
 	}
-	// If the deployment requires Redis authentication, fail fast when password
-	// is not provided. This prevents the runtime NOAUTH errors seen earlier.
-	if cfg.RedisAddr != "" && cfg.RedisRequireAuth && cfg.RedisPassword == "" {
-		return nil, fmt.Errorf("redis requires authentication (REDIS_REQUIRE_AUTH=true) but REDIS_PASSWORD is empty")
+	if cfg.DebugEndpoints && (cfg.DebugAddr == "" || cfg.DebugAddr == cfg.HTTPAddr) {
+		errs = append(errs, fmt.Errorf("DEBUG_ADDR must be set and differ from HTTP_ADDR %q, got %q", cfg.HTTPAddr, cfg.DebugAddr))
+	}
+	if bases, err := NormalizeWarmupBases(cfg.WarmupBases); err != nil {
+		errs = append(errs, err)
+	} else {
+		cfg.WarmupBases = bases
+	}
+	switch cfg.FeeRounding {
+	case FeeRoundingHalfUp, FeeRoundingHalfEven, FeeRoundingFloor, FeeRoundingCeil:
+	default:
+		errs = append(errs, fmt.Errorf("FEE_ROUNDING must be %q, %q, %q or %q, got %q",
+			FeeRoundingHalfUp, FeeRoundingHalfEven, FeeRoundingFloor, FeeRoundingCeil, cfg.FeeRounding))
+	}
+	cfg.BCBRateSide = strings.ToLower(strings.TrimSpace(cfg.BCBRateSide))
+	switch cfg.BCBRateSide {
+	case BCBSideBuy, BCBSideSell, BCBSideMid:
+	default:
+		errs = append(errs, fmt.Errorf("BCB_RATE_SIDE must be %q, %q or %q, got %q",
+			BCBSideBuy, BCBSideSell, BCBSideMid, cfg.BCBRateSide))
+	}
+	// BCB spells the intermediate bulletin with an accent
+	cfg.BCBBulletin = strings.ReplaceAll(strings.ToLower(strings.TrimSpace(cfg.BCBBulletin)), "á", "a")
+	switch cfg.BCBBulletin {
+	case BCBBulletinOpening, BCBBulletinIntermediate, BCBBulletinClosing, BCBBulletinLatest:
+	default:
+		errs = append(errs, fmt.Errorf("BCB_BULLETIN must be %q, %q, %q or %q, got %q",
+			BCBBulletinOpening, BCBBulletinIntermediate, BCBBulletinClosing, BCBBulletinLatest, cfg.BCBBulletin))
+	}
+	if cfg.FeeAPIMaxRetries < 0 {
+		errs = append(errs, fmt.Errorf("FEE_API_MAX_RETRIES must be >= 0, got %d", cfg.FeeAPIMaxRetries))
+	}
+	if cfg.FeeConfigPath != "" {
+		if rules, limits, err := loadFeeFile(cfg.FeeConfigPath); err != nil {
+			errs = append(errs, err)
+		} else {
+			for pair, pct := range cfg.Fees {
+				rules[pair] = pct
+			}
+			cfg.Fees = rules
+			cfg.FeeLimits = limits
+		}
+	}
+	// the EXCHANGE_FEE_*_CENTS values replace the file's default limits
+	if def := (FeeLimit{FixedCents: cfg.FeeFixedCents, MinCents: cfg.FeeMinCents, MaxCents: cfg.FeeMaxCents}); !def.IsZero() {
+		if err := def.validate("EXCHANGE_FEE_*_CENTS"); err != nil {
+			errs = append(errs, err)
+		} else {
+			if cfg.FeeLimits == nil {
+				cfg.FeeLimits = FeeLimits{}
+			}
+			cfg.FeeLimits[FeeDefault] = def
+		}
+	}
+	if cfg.FeeTiersPath != "" {
+		if cfg.FeeTiers.Enabled() {
+			errs = append(errs, fmt.Errorf("set only one of FEE_TIERS and FEE_TIERS_PATH"))
+		} else if tiers, err := loadFeeTiersFile(cfg.FeeTiersPath); err != nil {
+			errs = append(errs, err)
+		} else {
+			cfg.FeeTiers = tiers
+		}
+	}
+	if cfg.RequestTimeout < 0 {
+		errs = append(errs, fmt.Errorf("REQUEST_TIMEOUT must be >= 0, got %s", cfg.RequestTimeout))
+	}
+	for _, k := range cfg.APIKeys {
+		if _, ok := cfg.FeePlans[k.Plan]; k.Plan != "" && !ok {
+			errs = append(errs, fmt.Errorf("API_KEYS: key %q is on fee plan %q, which FEE_PLANS does not define", k.Name, k.Plan))
+		}
+	}
+	if cfg.IdempotencyTTL < 0 {
+		errs = append(errs, fmt.Errorf("IDEMPOTENCY_TTL must be >= 0, got %s", cfg.IdempotencyTTL))
+	}
+	if cfg.GzipMinSize < 0 {
+		errs = append(errs, fmt.Errorf("GZIP_MIN_SIZE must be >= 0, got %d", cfg.GzipMinSize))
+	}
+	if cfg.MaxInflightRequests < 0 {
+		errs = append(errs, fmt.Errorf("MAX_INFLIGHT_REQUESTS must be >= 0, got %d", cfg.MaxInflightRequests))
+	}
+	if cfg.MaxQueuedRequests < 0 {
+		errs = append(errs, fmt.Errorf("MAX_QUEUED_REQUESTS must be >= 0, got %d", cfg.MaxQueuedRequests))
+	}
+	if cfg.MaxInflightRequests > 0 && cfg.MaxQueuedRequests > 0 && cfg.MaxQueueWait <= 0 {
+		errs = append(errs, fmt.Errorf("MAX_QUEUE_WAIT must be positive, got %s", cfg.MaxQueueWait))
+	}
+	if cfg.StreamMaxSubscribers < 0 {
+		errs = append(errs, fmt.Errorf("STREAM_MAX_SUBSCRIBERS must be >= 0, got %d", cfg.StreamMaxSubscribers))
+	}
+	if cfg.StreamMaxPairs < 1 {
+		errs = append(errs, fmt.Errorf("STREAM_MAX_PAIRS must be at least 1, got %d", cfg.StreamMaxPairs))
+	}
+	if cfg.StreamMinInterval <= 0 {
+		errs = append(errs, fmt.Errorf("STREAM_MIN_INTERVAL must be positive, got %s", cfg.StreamMinInterval))
+	}
+	if cfg.StreamRateEpsilon < 0 {
+		errs = append(errs, fmt.Errorf("STREAM_RATE_EPSILON must be >= 0, got %v", cfg.StreamRateEpsilon))
+	}
+	if len(cfg.RateAlerts) > 0 && cfg.AlertCheckInterval <= 0 {
+		errs = append(errs, fmt.Errorf("ALERT_CHECK_INTERVAL must be positive, got %s", cfg.AlertCheckInterval))
+	}
+	if cfg.AlertWebhookMaxRetries < 0 {
+		errs = append(errs, fmt.Errorf("ALERT_WEBHOOK_MAX_RETRIES must be >= 0, got %d", cfg.AlertWebhookMaxRetries))
+	}
+	if cfg.BCBMaxBackDays < 0 {
+		errs = append(errs, fmt.Errorf("BCB_MAX_BACK_DAYS must be >= 0, got %d", cfg.BCBMaxBackDays))
+	}
+	// the Redis checks below do not apply when Redis is never contacted
+	if cfg.CacheBackend != CacheBackendMemory && cfg.RedisAddr != "" && cfg.RedisPassword == "" {
+		// If the deployment requires Redis authentication, fail fast when
+		// password is not provided. This prevents the runtime NOAUTH errors
+		// seen earlier.
+		if cfg.RedisRequireAuth {
+			errs = append(errs, fmt.Errorf("redis requires authentication (REDIS_REQUIRE_AUTH=true) but REDIS_PASSWORD is empty"))
+		}
+	}
+	// Print simple warnings; avoid creating a logger here to keep config
+	// loading simple and free of side-effects. They go to stderr so they never
+	// mix with command output such as `convert --output json`.
+	for _, w := range cfg.Warnings() {
+		fmt.Fprintf(os.Stderr, "WARNING: %s\n", w)
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
 	}
 	return cfg, nil
 }
+
+// Warnings lists the settings that are valid but likely mistakes, for the
+// startup summary and stderr.
+func (c *Config) Warnings() []string {
+	var warnings []string
+	// Many Redis deployments require authentication; this helps catch that
+	// misconfiguration.
+	if c.CacheBackend != CacheBackendMemory && c.RedisAddr != "" && c.RedisPassword == "" && !c.RedisRequireAuth {
+		warnings = append(warnings, fmt.Sprintf("REDIS_ADDR is set (%s) but REDIS_PASSWORD is empty. If your Redis requires auth, set REDIS_PASSWORD.", c.RedisAddr))
+	}
+	if c.OTLPUseTLS && c.OTLPInsecureSkipVerify {
+		warnings = append(warnings, "OTLP_INSECURE_SKIP_VERIFY=true: the OTLP collector certificate is not verified.")
+	}
+	if c.RatesHardTTL != nil {
+		warnings = append(warnings, "RATES_HARD_TTL is deprecated, use RATES_CACHE_TTL; it is ignored when RATES_CACHE_TTL is set.")
+	}
+	if c.OutboundAllowHTTP || c.OutboundAllowPrivate {
+		warnings = append(warnings, fmt.Sprintf("outbound URL policy relaxed (OUTBOUND_ALLOW_HTTP=%t, OUTBOUND_ALLOW_PRIVATE=%t).", c.OutboundAllowHTTP, c.OutboundAllowPrivate))
+	}
+	return warnings
+}
+
+// SamplerRatio validates an OTEL_TRACES_SAMPLER name and returns the ratio
+// for the traceidratio samplers, parsed from arg (default 1). An empty name is
+// the default parentbased_traceidratio; other samplers ignore arg.
+func SamplerRatio(name, arg string) (float64, error) {
+	switch name {
+	case "", SamplerTraceIDRatio, SamplerParentBasedTraceIDRatio:
+	case SamplerAlwaysOn, SamplerAlwaysOff, SamplerParentBasedAlwaysOn, SamplerParentBasedAlwaysOff:
+		return 0, nil
+	default:
+		return 0, fmt.Errorf("OTEL_TRACES_SAMPLER %q is not supported", name)
+	}
+	arg = strings.TrimSpace(arg)
+	if arg == "" {
+		return 1, nil
+	}
+	ratio, err := strconv.ParseFloat(arg, 64)
+	if err != nil || ratio < 0 || ratio > 1 {
+		return 0, fmt.Errorf("OTEL_TRACES_SAMPLER_ARG must be a number between 0 and 1, got %q", arg)
+	}
+	return ratio, nil
+}
+
+// NormalizeResourceDetectors trims, lower-cases and de-duplicates
+// OTEL_RESOURCE_DETECTORS. An empty list yields DefaultResourceDetectors and
+// "none", which must be used alone, yields an empty non-nil list.
+func NormalizeResourceDetectors(names []string) ([]string, error) {
+	out := []string{}
+	none := false
+	for _, n := range names {
+		n = strings.ToLower(strings.TrimSpace(n))
+		switch n {
+		case "":
+			continue
+		case ResourceDetectorNone:
+			none = true
+			continue
+		case ResourceDetectorEnv, ResourceDetectorHost, ResourceDetectorProcess, ResourceDetectorContainer:
+		default:
+			return nil, fmt.Errorf("OTEL_RESOURCE_DETECTORS: unknown detector %q (want %s, %s, %s, %s or %s)", n,
+				ResourceDetectorEnv, ResourceDetectorHost, ResourceDetectorProcess, ResourceDetectorContainer, ResourceDetectorNone)
+		}
+		if !slices.Contains(out, n) {
+			out = append(out, n)
+		}
+	}
+	switch {
+	case none && len(out) > 0:
+		return nil, fmt.Errorf("OTEL_RESOURCE_DETECTORS: %q cannot be combined with other detectors", ResourceDetectorNone)
+	case none:
+		return out, nil
+	case len(out) == 0:
+		return slices.Clone(DefaultResourceDetectors), nil
+	}
+	return out, nil
+}
+
+// NormalizeWarmupBases trims, upper-cases and de-duplicates WARMUP_BASES,
+// rejecting entries that are not 3-letter codes.
+func NormalizeWarmupBases(bases []string) ([]string, error) {
+	var out []string
+	for _, b := range bases {
+		b = strings.ToUpper(strings.TrimSpace(b))
+		if b == "" || slices.Contains(out, b) {
+			continue
+		}
+		if len(b) != 3 || strings.Trim(b, "ABCDEFGHIJKLMNOPQRSTUVWXYZ") != "" {
+			return nil, fmt.Errorf("WARMUP_BASES: invalid currency %q", b)
+		}
+		out = append(out, b)
+	}
+	return out, nil
+}
@@ -0,0 +1,624 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadValidatesBCBMaxBackDays(t *testing.T) {
+	cases := []struct {
+		value   string
+		wantErr bool
+	}{
+		{value: "0"},
+		{value: "5"},
+		{value: "-1", wantErr: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.value, func(t *testing.T) {
+			t.Setenv("BCB_MAX_BACK_DAYS", tc.value)
+			cfg, err := Load()
+			if tc.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "BCB_MAX_BACK_DAYS") {
+					t.Fatalf("expected a BCB_MAX_BACK_DAYS error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := cfg.BCBMaxBackDays; got < 0 {
+				t.Fatalf("unexpected value %d", got)
+			}
+		})
+	}
+}
+
+func TestLoadValidatesRedisStartup(t *testing.T) {
+	if cfg, err := Load(); err != nil || cfg.RedisStartup != RedisStartupOptional {
+		t.Fatalf("expected REDIS_STARTUP to default to optional, got %q (%v)", cfg.RedisStartup, err)
+	}
+	for _, v := range []string{"required", "optional"} {
+		t.Setenv("REDIS_STARTUP", v)
+		if _, err := Load(); err != nil {
+			t.Fatalf("REDIS_STARTUP=%s: unexpected error: %v", v, err)
+		}
+	}
+	t.Setenv("REDIS_STARTUP", "lazy")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "REDIS_STARTUP") {
+		t.Fatalf("expected a REDIS_STARTUP error, got %v", err)
+	}
+}
+
+func TestLoadValidatesCacheBackend(t *testing.T) {
+	t.Setenv("CACHE_BACKEND", "memory")
+	if _, err := Load(); err != nil {
+		t.Fatalf("CACHE_BACKEND=memory: unexpected error: %v", err)
+	}
+	t.Setenv("CACHE_BACKEND", "memcached")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "CACHE_BACKEND") {
+		t.Fatalf("expected a CACHE_BACKEND error, got %v", err)
+	}
+}
+
+func TestLoadValidatesLogColor(t *testing.T) {
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.LogColor != LogColorAuto {
+		t.Fatalf("expected default LOG_COLOR=auto, got %q", cfg.LogColor)
+	}
+	t.Setenv("LOG_COLOR", "sometimes")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "LOG_COLOR") {
+		t.Fatalf("expected a LOG_COLOR error, got %v", err)
+	}
+}
+
+func TestLoadValidatesLogDedup(t *testing.T) {
+	t.Setenv("LOG_DEDUP_INTERVAL", "30s")
+	t.Setenv("LOG_DEDUP_BURST", "0")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "LOG_DEDUP_BURST") {
+		t.Fatalf("expected a LOG_DEDUP_BURST error, got %v", err)
+	}
+	t.Setenv("LOG_DEDUP_INTERVAL", "0")
+	if _, err := Load(); err != nil {
+		t.Fatalf("disabled dedup: unexpected error: %v", err)
+	}
+}
+
+func TestLoadValidatesStream(t *testing.T) {
+	t.Setenv("STREAM_MAX_PAIRS", "0")
+	t.Setenv("STREAM_MIN_INTERVAL", "0s")
+	t.Setenv("STREAM_RATE_EPSILON", "-0.1")
+	_, err := Load()
+	for _, name := range []string{"STREAM_MAX_PAIRS", "STREAM_MIN_INTERVAL", "STREAM_RATE_EPSILON"} {
+		if err == nil || !strings.Contains(err.Error(), name) {
+			t.Fatalf("expected a %s error, got %v", name, err)
+		}
+	}
+	t.Setenv("STREAM_MAX_PAIRS", "5")
+	t.Setenv("STREAM_MIN_INTERVAL", "1s")
+	t.Setenv("STREAM_RATE_EPSILON", "0.001")
+	if _, err := Load(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestLoadValidatesLoadShedding(t *testing.T) {
+	t.Setenv("MAX_INFLIGHT_REQUESTS", "-1")
+	t.Setenv("MAX_QUEUED_REQUESTS", "-1")
+	t.Setenv("REQUEST_TIMEOUT", "-1s")
+	t.Setenv("IDEMPOTENCY_TTL", "-1s")
+	_, err := Load()
+	for _, name := range []string{"MAX_INFLIGHT_REQUESTS", "MAX_QUEUED_REQUESTS", "REQUEST_TIMEOUT", "IDEMPOTENCY_TTL"} {
+		if err == nil || !strings.Contains(err.Error(), name) {
+			t.Fatalf("expected a %s error, got %v", name, err)
+		}
+	}
+	t.Setenv("REQUEST_TIMEOUT", "0s")
+	t.Setenv("IDEMPOTENCY_TTL", "0s")
+	t.Setenv("MAX_INFLIGHT_REQUESTS", "10")
+	t.Setenv("MAX_QUEUED_REQUESTS", "10")
+	t.Setenv("MAX_QUEUE_WAIT", "0s")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "MAX_QUEUE_WAIT") {
+		t.Fatalf("expected a MAX_QUEUE_WAIT error, got %v", err)
+	}
+	// without a queue the wait is unused
+	t.Setenv("MAX_QUEUED_REQUESTS", "0")
+	if _, err := Load(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestLoadAccessLogFilters(t *testing.T) {
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if strings.Join(cfg.AccessLogExcludePaths, ",") != "/health,/ready,/metrics" || cfg.AccessLogSampleRate != 1 {
+		t.Fatalf("unexpected defaults %v %v", cfg.AccessLogExcludePaths, cfg.AccessLogSampleRate)
+	}
+	t.Setenv("ACCESS_LOG_SAMPLE_RATE", "1.5")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "ACCESS_LOG_SAMPLE_RATE") {
+		t.Fatalf("expected an ACCESS_LOG_SAMPLE_RATE error, got %v", err)
+	}
+}
+
+func TestLoadValidatesDebugAddr(t *testing.T) {
+	t.Setenv("DEBUG_ENDPOINTS", "true")
+	t.Setenv("HTTP_ADDR", ":8080")
+	t.Setenv("DEBUG_ADDR", ":8080")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "DEBUG_ADDR") {
+		t.Fatalf("expected a DEBUG_ADDR error, got %v", err)
+	}
+	t.Setenv("DEBUG_ADDR", "127.0.0.1:6060")
+	if _, err := Load(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestLoadValidatesFallbackBase(t *testing.T) {
+	t.Setenv("FALLBACK_BASE", "dollar")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "FALLBACK_BASE") {
+		t.Fatalf("expected a FALLBACK_BASE error, got %v", err)
+	}
+	for value, want := range map[string]string{" eur ": "EUR", "off": "OFF"} {
+		t.Setenv("FALLBACK_BASE", value)
+		cfg, err := Load()
+		if err != nil || cfg.FallbackBase != want {
+			t.Fatalf("%q: expected %q, got %+v (%v)", value, want, cfg, err)
+		}
+	}
+}
+
+func TestLoadParsesProviderMonthlyQuota(t *testing.T) {
+	t.Setenv("PROVIDER_MONTHLY_QUOTA", "exchangerate-api=1500,bcb=0")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.ProviderMonthlyQuota["exchangerate-api"] != 1500 || len(cfg.ProviderMonthlyQuota) != 2 {
+		t.Fatalf("unexpected quotas %v", cfg.ProviderMonthlyQuota)
+	}
+	if cfg.ProviderQuotaSoftThreshold != 0.8 || cfg.ProviderQuotaTTLMultiplier != 4 {
+		t.Fatalf("unexpected defaults %v %v", cfg.ProviderQuotaSoftThreshold, cfg.ProviderQuotaTTLMultiplier)
+	}
+	for name, value := range map[string]string{
+		"PROVIDER_MONTHLY_QUOTA":        "exchangerate-api=-1",
+		"PROVIDER_QUOTA_SOFT_THRESHOLD": "1.5",
+		"PROVIDER_QUOTA_TTL_MULTIPLIER": "0.5",
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv(name, value)
+			if _, err := Load(); err == nil || !strings.Contains(err.Error(), name) {
+				t.Fatalf("expected a %s error, got %v", name, err)
+			}
+		})
+	}
+}
+
+func TestLoadValidatesPivotCurrency(t *testing.T) {
+	t.Setenv("PIVOT_CURRENCY", "US")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "PIVOT_CURRENCY") {
+		t.Fatalf("expected a PIVOT_CURRENCY error, got %v", err)
+	}
+	t.Setenv("PIVOT_CURRENCY", " eur ")
+	if cfg, err := Load(); err != nil || cfg.PivotCurrency != "EUR" {
+		t.Fatalf("expected EUR, got %+v (%v)", cfg, err)
+	}
+}
+
+func TestLoadValidatesHotRefresh(t *testing.T) {
+	t.Setenv("HOT_REFRESH_LEAD", "0s")
+	if _, err := Load(); err != nil {
+		t.Fatalf("expected the settings ignored while disabled, got %v", err)
+	}
+	t.Setenv("HOT_REFRESH_THRESHOLD", "5")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "HOT_REFRESH_LEAD") {
+		t.Fatalf("expected a HOT_REFRESH_LEAD error, got %v", err)
+	}
+	t.Setenv("HOT_REFRESH_LEAD", "10s")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "HOT_REFRESH_INTERVAL") {
+		t.Fatalf("expected a HOT_REFRESH_INTERVAL error, got %v", err)
+	}
+	t.Setenv("HOT_REFRESH_LEAD", "1m")
+	if cfg, err := Load(); err != nil || cfg.HotRefreshThreshold != 5 || cfg.HotRefreshHalfLife.String() != "5m0s" {
+		t.Fatalf("expected the hot refresh settings, got %+v (%v)", cfg, err)
+	}
+}
+
+func TestLoadValidatesFeeRounding(t *testing.T) {
+	t.Setenv("FEE_ROUNDING", "bankers")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "FEE_ROUNDING") {
+		t.Fatalf("expected a FEE_ROUNDING error, got %v", err)
+	}
+	t.Setenv("FEE_ROUNDING", "half_even")
+	if cfg, err := Load(); err != nil || cfg.FeeRounding != FeeRoundingHalfEven {
+		t.Fatalf("expected half_even, got %+v (%v)", cfg, err)
+	}
+}
+
+func TestLoadAmountHeuristic(t *testing.T) {
+	if cfg, err := Load(); err != nil || !cfg.AmountHeuristic || cfg.AmountUnitRequired {
+		t.Fatalf("expected the heuristic on by default, got %+v (%v)", cfg, err)
+	}
+	t.Setenv("AMOUNT_HEURISTIC", "false")
+	if cfg, err := Load(); err != nil || !cfg.AmountUnitRequired {
+		t.Fatalf("expected the unit required, got %+v (%v)", cfg, err)
+	}
+}
+
+func TestLoadParsesRateAlerts(t *testing.T) {
+	t.Setenv("RATE_ALERTS", `[{"pair":"usd-brl","direction":"Above","threshold":5.8,"hysteresis":0.02,"webhook_url":"https://hooks.example.com/fx"},
+		{"name":"cheap euro","pair":"EUR-BRL","direction":"below","threshold":5.5,"webhook_url":"http://alerts:8080/hook"}]`)
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if len(cfg.RateAlerts) != 2 {
+		t.Fatalf("expected 2 alerts, got %+v", cfg.RateAlerts)
+	}
+	if a := cfg.RateAlerts[0]; a.Name != "USD-BRL above 5.8" || a.Pair != "USD-BRL" || a.Direction != AlertAbove || a.Hysteresis != 0.02 {
+		t.Fatalf("unexpected first alert %+v", a)
+	}
+	if a := cfg.RateAlerts[1]; a.Name != "cheap euro" || a.Direction != AlertBelow {
+		t.Fatalf("unexpected second alert %+v", a)
+	}
+
+	for _, bad := range []string{
+		`[{"pair":"USD-*","direction":"above","threshold":5.8,"webhook_url":"https://h.example.com"}]`,
+		`[{"pair":"USD-BRL","direction":"over","threshold":5.8,"webhook_url":"https://h.example.com"}]`,
+		`[{"pair":"USD-BRL","direction":"above","threshold":0,"webhook_url":"https://h.example.com"}]`,
+		`[{"pair":"USD-BRL","direction":"above","threshold":5.8,"webhook_url":"ftp://h.example.com"}]`,
+		`[{"pair":"USD-BRL","direction":"above","threshold":5.8,"webhook_url":"https://h.example.com"},
+		  {"pair":"USD-BRL","direction":"above","threshold":5.8,"webhook_url":"https://h.example.com"}]`,
+	} {
+		t.Setenv("RATE_ALERTS", bad)
+		if _, err := Load(); err == nil || !strings.Contains(err.Error(), "rate alert") {
+			t.Fatalf("%s: expected a rate alert error, got %v", bad, err)
+		}
+	}
+}
+
+func TestLoadValidatesOTLPBatchAndCompression(t *testing.T) {
+	t.Setenv("OTLP_COMPRESSION", "zstd")
+	t.Setenv("OTLP_BATCH_QUEUE_SIZE", "100")
+	t.Setenv("OTLP_BATCH_MAX_EXPORT_BATCH", "200")
+	_, err := Load()
+	if err == nil || !strings.Contains(err.Error(), "OTLP_COMPRESSION") || !strings.Contains(err.Error(), "OTLP_BATCH_MAX_EXPORT_BATCH") {
+		t.Fatalf("expected OTLP_COMPRESSION and OTLP_BATCH_MAX_EXPORT_BATCH errors, got %v", err)
+	}
+	t.Setenv("OTLP_COMPRESSION", "gzip")
+	t.Setenv("OTLP_BATCH_MAX_EXPORT_BATCH", "50")
+	if _, err := Load(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestNormalizeResourceDetectors(t *testing.T) {
+	got, err := NormalizeResourceDetectors([]string{" Host", "process", "host"})
+	if err != nil || strings.Join(got, ",") != "host,process" {
+		t.Fatalf("got %v, %v", got, err)
+	}
+	if got, err := NormalizeResourceDetectors(nil); err != nil || len(got) != len(DefaultResourceDetectors) {
+		t.Fatalf("expected the defaults, got %v, %v", got, err)
+	}
+	if got, err := NormalizeResourceDetectors([]string{"none"}); err != nil || got == nil || len(got) != 0 {
+		t.Fatalf("expected an empty list for none, got %v, %v", got, err)
+	}
+	for _, bad := range [][]string{{"gcp"}, {"none", "host"}} {
+		if _, err := NormalizeResourceDetectors(bad); err == nil {
+			t.Fatalf("expected an error for %v", bad)
+		}
+	}
+}
+
+func TestLoadNormalizesWarmupBases(t *testing.T) {
+	t.Setenv("WARMUP_BASES", " usd,EUR, ,usd")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if strings.Join(cfg.WarmupBases, ",") != "USD,EUR" {
+		t.Fatalf("unexpected bases %v", cfg.WarmupBases)
+	}
+	t.Setenv("WARMUP_BASES", "USD,EURO")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "WARMUP_BASES") {
+		t.Fatalf("expected a WARMUP_BASES error, got %v", err)
+	}
+}
+
+func TestLoadParsesFees(t *testing.T) {
+	t.Setenv("EXCHANGE_FEES", "usd-brl=0.012,USD-*=0.008,Default=0.01")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := FeeRules{"USD-BRL": 0.012, "USD-*": 0.008, FeeDefault: 0.01}
+	if len(cfg.Fees) != len(want) {
+		t.Fatalf("expected %v, got %v", want, cfg.Fees)
+	}
+	for k, v := range want {
+		if cfg.Fees[k] != v {
+			t.Fatalf("expected %v, got %v", want, cfg.Fees)
+		}
+	}
+}
+
+func TestLoadRejectsMalformedFees(t *testing.T) {
+	cases := map[string]string{
+		"USD-BRL":                 "expected PAIR=PERCENT",
+		"USD-BRL=abc":             "not a number",
+		"USDBRL=0.01":             "invalid fee pair",
+		"US-BRL=0.01":             "invalid fee pair",
+		"USD-BRL=1.5":             "outside [0, 1)",
+		"USD-BRL=-0.01":           "outside [0, 1)",
+		"USD-BRL=0.1,usd-brl=0.2": "duplicate fee entry for USD-BRL",
+	}
+	for value, want := range cases {
+		t.Run(value, func(t *testing.T) {
+			t.Setenv("EXCHANGE_FEES", value)
+			if _, err := Load(); err == nil || !strings.Contains(err.Error(), want) {
+				t.Fatalf("expected an error containing %q, got %v", want, err)
+			}
+		})
+	}
+}
+
+func TestLoadParsesFeePlans(t *testing.T) {
+	t.Setenv("FEE_PLANS", `{"gold":{"fees":{"usd-brl":0.004}},"volume":{"tiers":{"tiers":[{"from_cents":0,"percent":0.008}]}}}`)
+	t.Setenv("API_KEYS", "gold:k1::plan=gold,ops:k2:admin|internal:plan=volume,anon:k3")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.FeePlans["gold"].Fees["USD-BRL"] != 0.004 || !cfg.FeePlans["volume"].Tiers.Enabled() {
+		t.Fatalf("unexpected plans %+v", cfg.FeePlans)
+	}
+	if k := cfg.APIKeys[1]; k.Plan != "volume" || !k.Has(PermAdmin) || !k.Has(PermInternal) || cfg.APIKeys[2].Plan != "" {
+		t.Fatalf("unexpected keys %+v", cfg.APIKeys)
+	}
+
+	cases := []struct{ plans, keys, want string }{
+		{`{"gold":{"fees":{"default":0.01}}}`, "x:k::plan=silver", `fee plan "silver"`},
+		{`{"default":{"fees":{"default":0.01}}}`, "", "invalid fee plan name"},
+		{`{"gold":{"fees":{"default":0.01},"tiers":{"tiers":[{"from_cents":0,"percent":0.01}]}}}`, "", "not both"},
+		{`{"gold":{}}`, "", "fees or tiers are required"},
+		{`{"gold":{"fees":{"USD":0.01}}}`, "", "fee plan gold: invalid fee pair"},
+		{`{"gold":{"fees":{"default":0.01}}}`, "x:k::tier=gold", "expected plan=NAME"},
+		{`{"gold":{"fees":{"default":0.01}}}`, "x:k::plan=", "plan must not be empty"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.want, func(t *testing.T) {
+			t.Setenv("FEE_PLANS", tc.plans)
+			t.Setenv("API_KEYS", tc.keys)
+			if _, err := Load(); err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Fatalf("expected an error containing %q, got %v", tc.want, err)
+			}
+		})
+	}
+}
+
+func TestLoadMergesFeeFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fees.json")
+	if err := os.WriteFile(path, []byte(`{"USD-BRL":0.02,"USD-EUR":0.004,"default":0.01}`), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	t.Setenv("FEE_CONFIG_PATH", path)
+	t.Setenv("EXCHANGE_FEES", "USD-BRL=0.012")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Fees["USD-BRL"] != 0.012 || cfg.Fees["USD-EUR"] != 0.004 || cfg.Fees[FeeDefault] != 0.01 {
+		t.Fatalf("expected EXCHANGE_FEES to override the file, got %v", cfg.Fees)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"USD-BRAZIL":0.01}`), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "FEE_CONFIG_PATH") {
+		t.Fatalf("expected a FEE_CONFIG_PATH error, got %v", err)
+	}
+}
+
+func TestLoadValidatesFeeTiers(t *testing.T) {
+	cases := map[string]string{
+		`{"tiers":[]}`: "at least one tier",
+		`{"tiers":[{"from_cents":100,"percent":0.01}]}`:                                                                     "must start at from_cents 0",
+		`{"tiers":[{"from_cents":0,"percent":0.01},{"from_cents":0,"percent":0.006}]}`:                                      "sorted and non-overlapping",
+		`{"tiers":[{"from_cents":0,"percent":0.01},{"from_cents":500,"percent":0.006},{"from_cents":100,"percent":0.003}]}`: "sorted and non-overlapping",
+		`{"tiers":[{"from_cents":0,"percent":1.2}]}`:                                                                        "outside [0, 1)",
+		`{"tiers":[{"from_cents":0,"percent":0.01}],"pairs":{"USD":[{"from_cents":0,"percent":0.01}]}}`:                     "invalid fee tiers pair",
+		`{"tiers":[{"from_cents":0,"percent":0.01}],"pairs":{"USD-BRL":[{"from_cents":5,"percent":0.01}]}}`:                 "pairs.USD-BRL",
+		`not json`: "invalid fee tiers",
+	}
+	for value, want := range cases {
+		t.Run(value, func(t *testing.T) {
+			t.Setenv("FEE_TIERS", value)
+			if _, err := Load(); err == nil || !strings.Contains(err.Error(), want) {
+				t.Fatalf("expected an error containing %q, got %v", want, err)
+			}
+		})
+	}
+}
+
+func TestLoadFeeTiersFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tiers.json")
+	if err := os.WriteFile(path, []byte(`{"tiers":[{"from_cents":0,"percent":0.01},{"from_cents":100000,"percent":0.006}]}`), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	t.Setenv("FEE_TIERS_PATH", path)
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(cfg.FeeTiers.Tiers) != 2 || cfg.FeeTiers.Tiers[1].FromCents != 100000 {
+		t.Fatalf("unexpected tiers %+v", cfg.FeeTiers)
+	}
+
+	t.Setenv("FEE_TIERS", `{"tiers":[{"from_cents":0,"percent":0.01}]}`)
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "only one of FEE_TIERS and FEE_TIERS_PATH") {
+		t.Fatalf("expected a conflict error, got %v", err)
+	}
+}
+
+func TestLoadFeeLimits(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fees.json")
+	if err := os.WriteFile(path, []byte(`{
+		"USD-BRL": {"percent": 0.009, "min_cents": 200, "max_cents": 15000},
+		"EUR-*": {"fixed_cents": 50},
+		"default": {"percent": 0.01, "fixed_cents": 10}
+	}`), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	t.Setenv("FEE_CONFIG_PATH", path)
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Fees["USD-BRL"] != 0.009 || cfg.Fees[FeeDefault] != 0.01 {
+		t.Fatalf("unexpected fees %v", cfg.Fees)
+	}
+	if _, ok := cfg.Fees["EUR-*"]; ok {
+		t.Fatalf("expected a limits-only entry to leave the percent rules alone")
+	}
+	want := FeeLimits{
+		"USD-BRL":  {MinCents: 200, MaxCents: 15000},
+		"EUR-*":    {FixedCents: 50},
+		FeeDefault: {FixedCents: 10},
+	}
+	if len(cfg.FeeLimits) != len(want) {
+		t.Fatalf("expected %v, got %v", want, cfg.FeeLimits)
+	}
+	for k, v := range want {
+		if cfg.FeeLimits[k] != v {
+			t.Fatalf("expected %v, got %v", want, cfg.FeeLimits)
+		}
+	}
+
+	// the env values replace the default limits from the file
+	t.Setenv("EXCHANGE_FEE_MIN_CENTS", "100")
+	cfg, err = Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.FeeLimits[FeeDefault] != (FeeLimit{MinCents: 100}) || cfg.FeeLimits["USD-BRL"].MaxCents != 15000 {
+		t.Fatalf("unexpected limits %v", cfg.FeeLimits)
+	}
+
+	t.Setenv("EXCHANGE_FEE_MAX_CENTS", "50")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "min_cents 100 is above max_cents 50") {
+		t.Fatalf("expected a bounds error, got %v", err)
+	}
+}
+
+func TestLoadParsesFeeExemptPairs(t *testing.T) {
+	t.Setenv("FEE_EXEMPT_PAIRS", "usd-brl, eur<>GBP,*-JPY")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := FeePairSet{"USD-BRL": true, "EUR-GBP": true, "GBP-EUR": true, "*-JPY": true}
+	if len(cfg.FeeExemptPairs) != len(want) {
+		t.Fatalf("expected %v, got %v", want, cfg.FeeExemptPairs)
+	}
+	for k := range want {
+		if !cfg.FeeExemptPairs[k] {
+			t.Fatalf("expected %v, got %v", want, cfg.FeeExemptPairs)
+		}
+	}
+
+	for _, value := range []string{"USDBRL", "default", "US<>BRL"} {
+		t.Setenv("FEE_EXEMPT_PAIRS", value)
+		if _, err := Load(); err == nil || !strings.Contains(err.Error(), "invalid fee exempt pair") {
+			t.Fatalf("%s: expected an invalid pair error, got %v", value, err)
+		}
+	}
+}
+
+func TestLoadValidatesOTLPProtocol(t *testing.T) {
+	t.Setenv("OTEL_EXPORTER_OTLP_PROTOCOL", "http/protobuf")
+	if _, err := Load(); err != nil {
+		t.Fatalf("OTEL_EXPORTER_OTLP_PROTOCOL=http/protobuf: unexpected error: %v", err)
+	}
+	t.Setenv("OTEL_EXPORTER_OTLP_PROTOCOL", "http/json")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "OTEL_EXPORTER_OTLP_PROTOCOL") {
+		t.Fatalf("expected an OTEL_EXPORTER_OTLP_PROTOCOL error, got %v", err)
+	}
+	t.Setenv("OTEL_EXPORTER_OTLP_PROTOCOL", "")
+	t.Setenv("OTLP_METRICS_PROTOCOL", "thrift")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "OTLP_METRICS_PROTOCOL") {
+		t.Fatalf("expected an OTLP_METRICS_PROTOCOL error, got %v", err)
+	}
+}
+
+func TestLoadValidatesTracesSampler(t *testing.T) {
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.OTelTracesSampler != SamplerParentBasedTraceIDRatio {
+		t.Fatalf("unexpected default sampler %q", cfg.OTelTracesSampler)
+	}
+	t.Setenv("OTEL_TRACES_SAMPLER", "traceidratio")
+	t.Setenv("OTEL_TRACES_SAMPLER_ARG", "1.5")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "OTEL_TRACES_SAMPLER_ARG") {
+		t.Fatalf("expected an OTEL_TRACES_SAMPLER_ARG error, got %v", err)
+	}
+	t.Setenv("OTEL_TRACES_SAMPLER", "jaeger_remote")
+	t.Setenv("OTEL_TRACES_SAMPLER_ARG", "")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "OTEL_TRACES_SAMPLER") {
+		t.Fatalf("expected an OTEL_TRACES_SAMPLER error, got %v", err)
+	}
+}
+
+func TestLoadReadsDeprecatedRatesHardTTL(t *testing.T) {
+	t.Setenv("RATES_HARD_TTL", "5m")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.RatesCacheTTL != 5*time.Minute {
+		t.Fatalf("expected RATES_HARD_TTL to set the rates TTL, got %v", cfg.RatesCacheTTL)
+	}
+	if !slices.ContainsFunc(cfg.Warnings(), func(w string) bool { return strings.Contains(w, "RATES_HARD_TTL") }) {
+		t.Fatalf("expected a deprecation warning, got %v", cfg.Warnings())
+	}
+
+	t.Setenv("RATES_CACHE_TTL", "7m")
+	if cfg, err = Load(); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.RatesCacheTTL != 7*time.Minute {
+		t.Fatalf("expected RATES_CACHE_TTL to win over RATES_HARD_TTL, got %v", cfg.RatesCacheTTL)
+	}
+}
+
+func TestLoadValidatesBCBQuote(t *testing.T) {
+	t.Setenv("BCB_RATE_SIDE", " Mid ")
+	t.Setenv("BCB_BULLETIN", "Intermediário")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.BCBRateSide != BCBSideMid || cfg.BCBBulletin != BCBBulletinIntermediate {
+		t.Fatalf("expected normalized values, got %q %q", cfg.BCBRateSide, cfg.BCBBulletin)
+	}
+	for name, value := range map[string]string{
+		"BCB_RATE_SIDE": "average",
+		"BCB_BULLETIN":  "noon",
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv(name, value)
+			if _, err := Load(); err == nil || !strings.Contains(err.Error(), name) {
+				t.Fatalf("expected a %s error, got %v", name, err)
+			}
+		})
+	}
+}
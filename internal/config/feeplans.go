@@ -0,0 +1,66 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// DefaultFeePlan names the fee configuration of requests without a plan:
+// FEE_API_URL, FEE_TIERS, EXCHANGE_FEES or EXCHANGE_FEE_PERCENT.
+const DefaultFeePlan = "default"
+
+// FeePlan is the fee schedule of a plan: per-pair percents matched like
+// EXCHANGE_FEES, or a tiered schedule like FEE_TIERS.
+type FeePlan struct {
+	Fees  FeeRules
+	Tiers FeeTiers
+}
+
+// FeePlans maps plan names to their schedules, parsed from JSON:
+//
+//	{"gold":{"fees":{"USD-BRL":0.004,"default":0.006}},
+//	 "volume":{"tiers":{"tiers":[{"from_cents":0,"percent":0.008},{"from_cents":100000,"percent":0.004}]}}}
+//
+// API keys are put on a plan with their plan metadata (see APIKeys).
+type FeePlans map[string]FeePlan
+
+// UnmarshalText implements encoding.TextUnmarshaler so the env parser can
+// load FEE_PLANS directly.
+func (p *FeePlans) UnmarshalText(text []byte) error {
+	var raw map[string]struct {
+		Fees  map[string]float64 `json:"fees"`
+		Tiers json.RawMessage    `json:"tiers"`
+	}
+	if err := json.Unmarshal(text, &raw); err != nil {
+		return fmt.Errorf("invalid fee plans: %w", err)
+	}
+	plans := make(FeePlans, len(raw))
+	for name, r := range raw {
+		name = strings.TrimSpace(name)
+		if name == "" || strings.EqualFold(name, DefaultFeePlan) {
+			return fmt.Errorf("invalid fee plan name %q: must be non-empty and not %q", name, DefaultFeePlan)
+		}
+		var plan FeePlan
+		switch {
+		case len(r.Fees) > 0 && len(r.Tiers) > 0:
+			return fmt.Errorf("fee plan %s: set fees or tiers, not both", name)
+		case len(r.Fees) > 0:
+			plan.Fees = FeeRules{}
+			for pair, pct := range r.Fees {
+				if err := plan.Fees.add(pair, pct); err != nil {
+					return fmt.Errorf("fee plan %s: %w", name, err)
+				}
+			}
+		case len(r.Tiers) > 0:
+			if err := plan.Tiers.UnmarshalText(r.Tiers); err != nil {
+				return fmt.Errorf("fee plan %s: %w", name, err)
+			}
+		default:
+			return fmt.Errorf("fee plan %s: fees or tiers are required", name)
+		}
+		plans[name] = plan
+	}
+	*p = plans
+	return nil
+}
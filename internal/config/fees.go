@@ -0,0 +1,196 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// FeeDefault is the FeeRules key applied to pairs without a matching rule.
+const FeeDefault = "default"
+
+// FeeRules maps currency pairs to fee percents (0.012 = 1.2%). Keys are
+// upper-case FROM-TO pairs where either side may be "*", plus FeeDefault.
+// It is parsed from a comma-separated list of PAIR=PERCENT entries, e.g.
+// "USD-BRL=0.012,USD-*=0.008,default=0.01".
+type FeeRules map[string]float64
+
+// UnmarshalText implements encoding.TextUnmarshaler so the env parser can
+// load EXCHANGE_FEES directly.
+func (f *FeeRules) UnmarshalText(text []byte) error {
+	rules := FeeRules{}
+	for entry := range strings.SplitSeq(string(text), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		pair, pct, ok := strings.Cut(entry, "=")
+		if !ok {
+			return fmt.Errorf("invalid fee entry %q: expected PAIR=PERCENT", entry)
+		}
+		v, err := strconv.ParseFloat(strings.TrimSpace(pct), 64)
+		if err != nil {
+			return fmt.Errorf("invalid fee entry %q: %q is not a number", entry, strings.TrimSpace(pct))
+		}
+		if err := rules.add(pair, v); err != nil {
+			return err
+		}
+	}
+	*f = rules
+	return nil
+}
+
+// add validates and stores one rule under its normalized key.
+func (f FeeRules) add(pair string, pct float64) error {
+	key, err := normalizeFeePair(pair)
+	if err != nil {
+		return err
+	}
+	if pct < 0 || pct >= 1 {
+		return fmt.Errorf("invalid fee for %s: %v is outside [0, 1)", key, pct)
+	}
+	if _, dup := f[key]; dup {
+		return fmt.Errorf("duplicate fee entry for %s", key)
+	}
+	f[key] = pct
+	return nil
+}
+
+func normalizeFeePair(pair string) (string, error) {
+	pair = strings.TrimSpace(pair)
+	if strings.EqualFold(pair, FeeDefault) {
+		return FeeDefault, nil
+	}
+	from, to, ok := strings.Cut(strings.ToUpper(pair), "-")
+	if !ok || !validFeeSide(from) || !validFeeSide(to) {
+		return "", fmt.Errorf("invalid fee pair %q: expected FROM-TO with 3-letter codes or *, or %q", pair, FeeDefault)
+	}
+	return from + "-" + to, nil
+}
+
+func validFeeSide(s string) bool {
+	if s == "*" {
+		return true
+	}
+	if len(s) != 3 {
+		return false
+	}
+	for _, c := range s {
+		if c < 'A' || c > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+// FeePairSet is a set of normalized FROM-TO pairs (either side may be "*"),
+// parsed from a comma-separated list. "FROM-TO" adds one direction and
+// "FROM<>TO" adds both, e.g. "USD-BRL,EUR<>GBP".
+type FeePairSet map[string]bool
+
+// UnmarshalText implements encoding.TextUnmarshaler so the env parser can
+// load FEE_EXEMPT_PAIRS directly.
+func (f *FeePairSet) UnmarshalText(text []byte) error {
+	set := FeePairSet{}
+	for entry := range strings.SplitSeq(string(text), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		pairs := []string{entry}
+		if a, b, ok := strings.Cut(entry, "<>"); ok {
+			pairs = []string{a + "-" + b, b + "-" + a}
+		}
+		for _, pair := range pairs {
+			key, err := normalizeFeePair(pair)
+			if err != nil || key == FeeDefault {
+				return fmt.Errorf("invalid fee exempt pair %q: expected FROM-TO or FROM<>TO with 3-letter codes or *", entry)
+			}
+			set[key] = true
+		}
+	}
+	*f = set
+	return nil
+}
+
+// FeeLimit adds a fixed component and bounds to a percent fee, in cents of
+// the target currency. A zero MaxCents means no upper bound.
+type FeeLimit struct {
+	FixedCents int64 `json:"fixed_cents"`
+	MinCents   int64 `json:"min_cents"`
+	MaxCents   int64 `json:"max_cents"`
+}
+
+// IsZero reports whether the limit changes nothing.
+func (l FeeLimit) IsZero() bool {
+	return l == FeeLimit{}
+}
+
+func (l FeeLimit) validate(key string) error {
+	if l.FixedCents < 0 || l.MinCents < 0 || l.MaxCents < 0 {
+		return fmt.Errorf("invalid fee limits for %s: amounts must be >= 0", key)
+	}
+	if l.MaxCents > 0 && l.MinCents > l.MaxCents {
+		return fmt.Errorf("invalid fee limits for %s: min_cents %d is above max_cents %d", key, l.MinCents, l.MaxCents)
+	}
+	return nil
+}
+
+// FeeLimits maps FeeRules keys to fixed fees and bounds.
+type FeeLimits map[string]FeeLimit
+
+// feeFileEntry is a FEE_CONFIG_PATH value: a bare percent, or an object that
+// may also carry fixed_cents, min_cents and max_cents.
+type feeFileEntry struct {
+	Percent *float64 `json:"percent"`
+	FeeLimit
+}
+
+func (e *feeFileEntry) UnmarshalJSON(b []byte) error {
+	var pct float64
+	if err := json.Unmarshal(b, &pct); err == nil {
+		e.Percent = &pct
+		return nil
+	}
+	type plain feeFileEntry
+	return json.Unmarshal(b, (*plain)(e))
+}
+
+// loadFeeFile reads FEE_CONFIG_PATH, a JSON object with the same keys as
+// EXCHANGE_FEES whose values are percents or objects with limits:
+// {"USD-BRL":{"percent":0.009,"min_cents":200,"max_cents":15000},"default":0.01}.
+func loadFeeFile(path string) (FeeRules, FeeLimits, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("FEE_CONFIG_PATH: %w", err)
+	}
+	var raw map[string]feeFileEntry
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return nil, nil, fmt.Errorf("FEE_CONFIG_PATH %s: %w", path, err)
+	}
+	rules, limits := FeeRules{}, FeeLimits{}
+	for pair, entry := range raw {
+		if entry.Percent != nil {
+			if err := rules.add(pair, *entry.Percent); err != nil {
+				return nil, nil, fmt.Errorf("FEE_CONFIG_PATH %s: %w", path, err)
+			}
+		}
+		if entry.FeeLimit.IsZero() {
+			continue
+		}
+		key, err := normalizeFeePair(pair)
+		if err != nil {
+			return nil, nil, fmt.Errorf("FEE_CONFIG_PATH %s: %w", path, err)
+		}
+		if _, dup := limits[key]; dup {
+			return nil, nil, fmt.Errorf("FEE_CONFIG_PATH %s: duplicate fee entry for %s", path, key)
+		}
+		if err := entry.FeeLimit.validate(key); err != nil {
+			return nil, nil, fmt.Errorf("FEE_CONFIG_PATH %s: %w", path, err)
+		}
+		limits[key] = entry.FeeLimit
+	}
+	return rules, limits, nil
+}
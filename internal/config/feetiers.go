@@ -0,0 +1,109 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// FeeTier is one band of a tiered fee schedule: gross converted amounts from
+// FromCents (inclusive) up to the next tier's FromCents (exclusive) pay
+// Percent.
+type FeeTier struct {
+	Name      string  `json:"name,omitempty"`
+	FromCents int64   `json:"from_cents"`
+	Percent   float64 `json:"percent"`
+}
+
+// FeeTiers is a tiered fee schedule with optional per-pair overrides, parsed
+// from JSON:
+//
+//	{"tiers":[{"from_cents":0,"percent":0.01},{"from_cents":100000,"percent":0.006}],
+//	 "pairs":{"USD-BRL":[{"from_cents":0,"percent":0.008}]}}
+//
+// Pair keys follow EXCHANGE_FEES (FROM-TO, either side may be "*").
+type FeeTiers struct {
+	Tiers []FeeTier            `json:"tiers"`
+	Pairs map[string][]FeeTier `json:"pairs,omitempty"`
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler so the env parser can
+// load FEE_TIERS directly.
+func (t *FeeTiers) UnmarshalText(text []byte) error {
+	// decode through a plain type: FeeTiers itself is a TextUnmarshaler,
+	// which encoding/json would use for JSON strings only
+	type plain FeeTiers
+	var ft FeeTiers
+	if err := json.Unmarshal(text, (*plain)(&ft)); err != nil {
+		return fmt.Errorf("invalid fee tiers: %w", err)
+	}
+	if err := ft.normalize(); err != nil {
+		return err
+	}
+	*t = ft
+	return nil
+}
+
+// Enabled reports whether a schedule was configured.
+func (t FeeTiers) Enabled() bool {
+	return len(t.Tiers) > 0
+}
+
+// normalize validates the schedule and upper-cases the pair keys.
+func (t *FeeTiers) normalize() error {
+	if err := validateFeeTiers("tiers", t.Tiers); err != nil {
+		return err
+	}
+	pairs := make(map[string][]FeeTier, len(t.Pairs))
+	for pair, tiers := range t.Pairs {
+		key, err := normalizeFeePair(pair)
+		if err != nil || key == FeeDefault {
+			return fmt.Errorf("invalid fee tiers pair %q: expected FROM-TO with 3-letter codes or *", pair)
+		}
+		if _, dup := pairs[key]; dup {
+			return fmt.Errorf("duplicate fee tiers pair %s", key)
+		}
+		if err := validateFeeTiers("pairs."+key, tiers); err != nil {
+			return err
+		}
+		pairs[key] = tiers
+	}
+	if len(pairs) > 0 {
+		t.Pairs = pairs
+	}
+	return nil
+}
+
+// validateFeeTiers requires tiers to start at 0 and be sorted by strictly
+// increasing thresholds, so every amount falls into exactly one tier.
+func validateFeeTiers(name string, tiers []FeeTier) error {
+	if len(tiers) == 0 {
+		return fmt.Errorf("fee tiers %s: at least one tier is required", name)
+	}
+	if tiers[0].FromCents != 0 {
+		return fmt.Errorf("fee tiers %s: the first tier must start at from_cents 0, got %d", name, tiers[0].FromCents)
+	}
+	for i, tier := range tiers {
+		if tier.Percent < 0 || tier.Percent >= 1 {
+			return fmt.Errorf("fee tiers %s[%d]: percent %v is outside [0, 1)", name, i, tier.Percent)
+		}
+		if i > 0 && tier.FromCents <= tiers[i-1].FromCents {
+			return fmt.Errorf("fee tiers %s[%d]: from_cents %d must be greater than %d (tiers must be sorted and non-overlapping)",
+				name, i, tier.FromCents, tiers[i-1].FromCents)
+		}
+	}
+	return nil
+}
+
+// loadFeeTiersFile reads FEE_TIERS_PATH.
+func loadFeeTiersFile(path string) (FeeTiers, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return FeeTiers{}, fmt.Errorf("FEE_TIERS_PATH: %w", err)
+	}
+	var t FeeTiers
+	if err := t.UnmarshalText(b); err != nil {
+		return FeeTiers{}, fmt.Errorf("FEE_TIERS_PATH %s: %w", path, err)
+	}
+	return t, nil
+}
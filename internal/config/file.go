@@ -0,0 +1,92 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// readConfigFile reads a YAML (or JSON) configuration file into environment
+// variable values. Keys are the variable names, case-insensitive
+// (http_addr: ":9090" sets HTTP_ADDR); scalars are written as in the
+// environment, lists are joined with commas and objects are encoded as JSON,
+// the form FEE_TIERS expects. Unknown keys are an error listing all of them.
+func readConfigFile(path string) (map[string]string, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml", ".json":
+	default:
+		return nil, fmt.Errorf("config file %s: unsupported format, use .yaml, .yml or .json", path)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("config file: %w", err)
+	}
+	var raw map[string]any
+	if err := yaml.Unmarshal(b, &raw); err != nil {
+		return nil, fmt.Errorf("config file %s: %w", path, err)
+	}
+
+	known := envNames()
+	vars := make(map[string]string, len(raw))
+	var unknown []string
+	for key, v := range raw {
+		name := strings.ToUpper(key)
+		if !known[name] {
+			unknown = append(unknown, key)
+			continue
+		}
+		s, err := fileValue(v)
+		if err != nil {
+			return nil, fmt.Errorf("config file %s: %s: %w", path, key, err)
+		}
+		vars[name] = s
+	}
+	if len(unknown) > 0 {
+		slices.Sort(unknown)
+		return nil, fmt.Errorf("config file %s: unknown keys: %s", path, strings.Join(unknown, ", "))
+	}
+	return vars, nil
+}
+
+// envNames returns the environment variables Config reads.
+func envNames() map[string]bool {
+	t := reflect.TypeFor[Config]()
+	names := make(map[string]bool, t.NumField())
+	for i := range t.NumField() {
+		if name, _, _ := strings.Cut(t.Field(i).Tag.Get("env"), ","); name != "" && name != "-" {
+			names[name] = true
+		}
+	}
+	return names
+}
+
+// fileValue renders a decoded file value in its environment form.
+func fileValue(v any) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case []any:
+		parts := make([]string, len(v))
+		for i, item := range v {
+			s, err := fileValue(item)
+			if err != nil {
+				return "", err
+			}
+			parts[i] = s
+		}
+		return strings.Join(parts, ","), nil
+	case map[string]any:
+		b, err := json.Marshal(v)
+		return string(b), err
+	default:
+		return fmt.Sprint(v), nil
+	}
+}
@@ -0,0 +1,79 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeConfigFile(t *testing.T, name, body string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(body), 0o600); err != nil {
+		t.Fatalf("write config file: %v", err)
+	}
+	return path
+}
+
+func TestLoadFileLayersEnvironment(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", `
+http_addr: ":9090"
+LOG_LEVEL: debug
+cache_ttl: 10m
+redis_op_timeout: 1s
+admin_enabled: true
+server_timing: false
+aggregate_sources: [bcb, static]
+fee_tiers:
+  tiers:
+    - {from_cents: 0, percent: 0.01}
+`)
+	t.Setenv("LOG_LEVEL", "warn")
+	t.Setenv("REDIS_OP_TIMEOUT", "2s")
+	t.Setenv("SERVER_TIMING", "true")
+
+	cfg, err := LoadFile(path)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	// from the file
+	if cfg.HTTPAddr != ":9090" || cfg.CacheTTL != 10*time.Minute || !cfg.AdminEnabled {
+		t.Fatalf("file values not applied: addr=%q ttl=%v admin=%t", cfg.HTTPAddr, cfg.CacheTTL, cfg.AdminEnabled)
+	}
+	if strings.Join(cfg.AggregateSources, ",") != "bcb,static" || len(cfg.FeeTiers.Tiers) != 1 {
+		t.Fatalf("file lists not applied: %v %+v", cfg.AggregateSources, cfg.FeeTiers)
+	}
+	// the environment wins, field by field
+	if cfg.LogLevel != "warn" || cfg.RedisOpTimeout != 2*time.Second || !cfg.ServerTiming {
+		t.Fatalf("env overrides not applied: level=%q op=%v timing=%t", cfg.LogLevel, cfg.RedisOpTimeout, cfg.ServerTiming)
+	}
+	// and defaults fill the rest
+	if cfg.Provider != "exchangerate.host" {
+		t.Fatalf("expected the default provider, got %q", cfg.Provider)
+	}
+}
+
+func TestLoadReadsConfigFileEnv(t *testing.T) {
+	t.Setenv("CONFIG_FILE", writeConfigFile(t, "config.yml", "exchange_provider: bcb\n"))
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.Provider != "bcb" {
+		t.Fatalf("expected the file provider, got %q", cfg.Provider)
+	}
+}
+
+func TestLoadFileRejectsUnknownKeys(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", "http_addr: \":9090\"\nhttp_adr: x\nredis_pasword: y\n")
+	_, err := LoadFile(path)
+	if err == nil || !strings.Contains(err.Error(), "unknown keys: http_adr, redis_pasword") {
+		t.Fatalf("expected the unknown keys listed, got %v", err)
+	}
+
+	if _, err := LoadFile(writeConfigFile(t, "config.toml", "x = 1\n")); err == nil || !strings.Contains(err.Error(), "unsupported format") {
+		t.Fatalf("expected an unsupported format error, got %v", err)
+	}
+}
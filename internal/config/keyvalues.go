@@ -0,0 +1,51 @@
+package config
+
+import (
+	"net/url"
+	"strings"
+)
+
+// ParseKeyValues splits comma-separated KEY=VALUE pairs, as in OTLP_HEADERS
+// and PROVIDER_EXTRA_HEADERS, optionally percent-decoding keys and values.
+// Pairs without "=" or with an empty key are skipped.
+func ParseKeyValues(s string, decode bool) map[string]string {
+	out := map[string]string{}
+	for part := range strings.SplitSeq(s, ",") {
+		k, v, ok := strings.Cut(part, "=")
+		if !ok {
+			continue
+		}
+		k, v = strings.TrimSpace(k), strings.TrimSpace(v)
+		if decode {
+			var err error
+			if k, err = url.PathUnescape(k); err != nil {
+				continue
+			}
+			if v, err = url.PathUnescape(v); err != nil {
+				continue
+			}
+		}
+		if k == "" {
+			continue
+		}
+		out[k] = v
+	}
+	return out
+}
+
+// sensitiveKeyParts are substrings of header names whose values are
+// credentials.
+var sensitiveKeyParts = []string{"auth", "token", "key", "secret", "password", "cookie", "session", "signature", "credential"}
+
+// SensitiveKey reports whether a header or field named k looks like it
+// carries a credential, e.g. Authorization or X-Proxy-Token, so its value
+// must not be logged.
+func SensitiveKey(k string) bool {
+	k = strings.ToLower(k)
+	for _, part := range sensitiveKeyParts {
+		if strings.Contains(k, part) {
+			return true
+		}
+	}
+	return false
+}
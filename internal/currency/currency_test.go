@@ -0,0 +1,30 @@
+package currency
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestTableSortedAndUnique(t *testing.T) {
+	if !slices.IsSortedFunc(table, func(a, b Currency) int { return strings.Compare(a.Code, b.Code) }) {
+		t.Fatal("currency table is not sorted by code")
+	}
+	for i := 1; i < len(table); i++ {
+		if table[i].Code == table[i-1].Code {
+			t.Fatalf("duplicate currency %s", table[i].Code)
+		}
+	}
+}
+
+func TestLookup(t *testing.T) {
+	for code, minor := range map[string]int{"usd": 2, "JPY": 0, "KWD": 3} {
+		c, ok := Lookup(code)
+		if !ok || c.MinorUnits != minor {
+			t.Errorf("Lookup(%s) = %+v, %v; want minor units %d", code, c, ok, minor)
+		}
+	}
+	if _, ok := Lookup("XXX"); ok {
+		t.Error("Lookup(XXX) found a currency")
+	}
+}
@@ -0,0 +1,226 @@
+package exchange
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/thiagozs/go-exchange/internal/provider"
+)
+
+// cachePolicy says whether a conversion may read from and write to the
+// conversion cache.
+type cachePolicy struct {
+	read  bool
+	write bool
+}
+
+// cacheSchemaVersion is part of the conversion cache keys. Bump it when
+// cachedConversion changes in a way older entries can't be read back
+// correctly, so a deploy stops serving them instead of waiting out CACHE_TTL.
+// It is a variable only so tests can simulate a bump.
+var cacheSchemaVersion = 1
+
+// conversionKey is the conversion cache key of amount cents from->to. It uses
+// integer cents so "10" and "10.00" share an entry, and keeps the "convert:"
+// prefix the admin cache purge deletes by.
+func (s *Service) conversionKey(from, to string, cents int64) string {
+	key := "convert:v" + strconv.Itoa(cacheSchemaVersion) + ":"
+	if s.provider != "" {
+		key += s.provider + ":"
+	}
+	return key + from + ":" + to + ":" + strconv.FormatInt(cents, 10)
+}
+
+// errUncacheable is returned by the conversion cache fill for provider
+// results that must not be stored.
+var errUncacheable = errors.New("conversion result not cacheable")
+
+// cachedConversion is the provider result stored in the conversion cache.
+// Fees are applied per request on top of it, so fee changes and per-caller
+// waivers never see another request's fee. StoredAt is when the result was
+// fetched from the provider and stored, so responses can tell clients how old
+// the rate is and how long it stays fresh; entries written before it existed
+// have none.
+type cachedConversion struct {
+	ResultCents   int64     `json:"result_cents"`
+	Rate          float64   `json:"rate,omitempty"`
+	RateTimestamp time.Time `json:"rate_timestamp,omitzero"`
+	Source        string    `json:"source,omitempty"`
+	RateSide      string    `json:"rate_side,omitempty"`
+	Bulletin      string    `json:"bulletin,omitempty"`
+	Sources       []string  `json:"sources,omitempty"`
+	Spread        float64   `json:"rate_spread,omitempty"`
+	Derived       bool      `json:"derived,omitempty"`
+	StoredAt      time.Time `json:"stored_at,omitzero"`
+}
+
+func encodeConversion(conv provider.ConvertResult, storedAt time.Time) string {
+	b, _ := json.Marshal(cachedConversion{
+		ResultCents: conv.ResultCents, Rate: conv.Rate, RateTimestamp: conv.RateTimestamp,
+		Source: conv.Source, RateSide: conv.RateSide, Bulletin: conv.Bulletin,
+		Sources: conv.Sources, Spread: conv.Spread, Derived: conv.Derived, StoredAt: storedAt.UTC(),
+	})
+	return string(b)
+}
+
+func decodeConversion(val string) (conv provider.ConvertResult, storedAt time.Time, ok bool) {
+	var c cachedConversion
+	if err := json.Unmarshal([]byte(val), &c); err != nil {
+		return provider.ConvertResult{}, time.Time{}, false
+	}
+	return provider.ConvertResult{
+		ResultCents: c.ResultCents, Rate: c.Rate, RateTimestamp: c.RateTimestamp,
+		Source: c.Source, RateSide: c.RateSide, Bulletin: c.Bulletin,
+		Sources: c.Sources, Spread: c.Spread, Derived: c.Derived,
+	}, c.StoredAt, true
+}
+
+// cacheEntry describes the conversion cache entry behind a result.
+type cacheEntry struct {
+	// storedAt is when the entry was written, zero when there is none or it
+	// predates stored_at.
+	storedAt time.Time
+	// read reports whether the result was read from the entry, which then
+	// had ttl left (zero when the cache could not tell).
+	read bool
+	ttl  time.Duration
+}
+
+// expires is when the entry expires, zero when unknown.
+func (e cacheEntry) expires(now time.Time, cacheTTL time.Duration) time.Time {
+	switch {
+	case e.read && e.ttl > 0:
+		return now.Add(e.ttl)
+	case !e.storedAt.IsZero() && cacheTTL > 0:
+		return e.storedAt.Add(cacheTTL)
+	}
+	return time.Time{}
+}
+
+// readConversion reads the conversion cache entry under key with its TTL.
+func (s *Service) readConversion(ctx context.Context, key string) (provider.ConvertResult, cacheEntry, bool) {
+	val, ttl, err := s.cache.GetWithTTL(ctx, key)
+	if err != nil || val == "" {
+		return provider.ConvertResult{}, cacheEntry{}, false
+	}
+	conv, storedAt, ok := decodeConversion(val)
+	return conv, cacheEntry{storedAt: storedAt, read: true, ttl: ttl}, ok
+}
+
+// cachedConvert returns the provider result for amount cents, served from the
+// conversion cache when policy allows it. hit reports whether the conversion
+// or the provider rate came from a cache; entry describes the conversion cache
+// entry behind the result.
+func (s *Service) cachedConvert(ctx context.Context, policy cachePolicy, from, to string, amountInt int64) (conv provider.ConvertResult, hit bool, entry cacheEntry, err error) {
+	if s.demand != nil {
+		s.demand.Record(strings.ToUpper(from))
+	}
+	key := s.conversionKey(from, to, amountInt)
+
+	// reads are timed until the provider is called or the result is known
+	stopCache := func() {}
+	if policy.read {
+		stopCache = sync.OnceFunc(track(ctx, PhaseCache))
+		defer stopCache()
+		// hits, the common case, read the entry and its TTL in one round
+		// trip; misses go on to the shared fill
+		if conv, entry, ok := s.readConversion(ctx, key); ok {
+			return conv, true, entry, nil
+		}
+	}
+	if !policy.read || !policy.write {
+		stopCache()
+		// cache bypass or dry run: no fill to share with other requests
+		conv, cacheable, err := s.providerConvert(ctx, from, to, amountInt)
+		if err != nil {
+			return provider.ConvertResult{}, false, cacheEntry{}, err
+		}
+		if policy.write && cacheable {
+			entry.storedAt = s.now()
+			stop := track(ctx, PhaseCache)
+			if err := s.cache.Set(ctx, key, encodeConversion(conv, entry.storedAt), s.cacheTTL); err != nil {
+				entry.storedAt = time.Time{}
+			}
+			stop()
+		}
+		return conv, conv.CacheHit, entry, nil
+	}
+
+	// concurrent misses for the same key, here or on other instances, share
+	// one provider call
+	filled := false
+	val, err := s.cache.GetOrSet(ctx, key, s.cacheTTL, func(ctx context.Context) (string, error) {
+		stopCache()
+		filled = true
+		var cacheable bool
+		conv, cacheable, err = s.providerConvert(ctx, from, to, amountInt)
+		if err != nil {
+			return "", err
+		}
+		if !cacheable {
+			return encodeConversion(conv, time.Time{}), errUncacheable
+		}
+		entry.storedAt = s.now()
+		return encodeConversion(conv, entry.storedAt), nil
+	})
+	stopCache()
+	if filled {
+		if err != nil {
+			if !errors.Is(err, errUncacheable) {
+				return provider.ConvertResult{}, false, cacheEntry{}, err
+			}
+			entry.storedAt = time.Time{}
+		}
+		return conv, conv.CacheHit, entry, nil
+	}
+	if errors.Is(err, errUncacheable) {
+		// shared with a concurrent fill that did not store its result
+		err = nil
+	}
+	if err != nil {
+		return provider.ConvertResult{}, false, cacheEntry{}, err
+	}
+	if conv, storedAt, ok := decodeConversion(val); ok {
+		// filled by another request meanwhile, with the full TTL ahead
+		return conv, true, cacheEntry{storedAt: storedAt, read: true}, nil
+	}
+	// unreadable entry: convert without the cache
+	conv, _, err = s.providerConvert(ctx, from, to, amountInt)
+	return conv, conv.CacheHit, cacheEntry{}, err
+}
+
+// providerConvert converts amount cents with the provider. cacheable is false
+// for results that must not be stored in the conversion cache.
+func (s *Service) providerConvert(ctx context.Context, from, to string, amountInt int64) (conv provider.ConvertResult, cacheable bool, err error) {
+	stop := track(ctx, PhaseProvider)
+	conv, err = s.convert(ctx, from, to, amountInt)
+	stop()
+	if err != nil {
+		return provider.ConvertResult{}, false, err
+	}
+	// amounts are positive, so a zero result points at a misbehaving provider
+	if conv.ResultCents == 0 {
+		s.log.WithContext(ctx).Warnf("not caching zero conversion result for %s->%s amount=%d", from, to, amountInt)
+		return conv, false, nil
+	}
+	if conv.Stale {
+		// a refreshed rate is on its way; don't pin the stale one for CACHE_TTL
+		s.log.WithContext(ctx).Debugf("not caching stale conversion result for %s->%s", from, to)
+		return conv, false, nil
+	}
+	return conv, true, nil
+}
+
+// convert calls the provider, using rate metadata when it is available.
+func (s *Service) convert(ctx context.Context, from, to string, amount int64) (provider.ConvertResult, error) {
+	if dp, ok := s.prov.(provider.DetailedProvider); ok {
+		return dp.ConvertDetailed(ctx, from, to, amount)
+	}
+	resCents, err := s.prov.Convert(ctx, from, to, amount)
+	return provider.ConvertResult{ResultCents: resCents}, err
+}
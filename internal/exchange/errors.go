@@ -0,0 +1,85 @@
+package exchange
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/thiagozs/go-exchange/internal/fee"
+	"github.com/thiagozs/go-exchange/internal/provider"
+)
+
+// Kind classifies a failed conversion, so each transport can pick its own
+// status for it.
+type Kind int
+
+const (
+	// KindInvalid is a rejected argument; Err is a *ValidationError.
+	KindInvalid Kind = iota + 1
+	// KindUnsupported is a currency pair the provider does not convert.
+	KindUnsupported
+	// KindUnavailable is a misconfigured provider, a provider out of quota or
+	// an unavailable fee API.
+	KindUnavailable
+	// KindInternal is any other provider failure.
+	KindInternal
+	// KindTimeout is a provider or fee API call cut short by a deadline,
+	// either the request's or the client's own timeout.
+	KindTimeout
+)
+
+// Machine-readable codes of conversion failures.
+const (
+	CodeCurrencyNotSupported  = "CURRENCY_NOT_SUPPORTED"
+	CodeProviderMissingAPIKey = "PROVIDER_MISSING_API_KEY"
+	CodeProviderQuotaExceeded = "PROVIDER_QUOTA_EXCEEDED"
+	CodeProviderError         = "PROVIDER_ERROR"
+	CodeFeeUnavailable        = "FEE_UNAVAILABLE"
+	CodeTimeout               = "TIMEOUT"
+)
+
+// Error is a failed Convert or Rate. Code and Message are safe to show to
+// clients; the provider or fee API error itself is only in Err.
+type Error struct {
+	Kind    Kind
+	Code    string
+	Message string
+	Err     error
+}
+
+func (e *Error) Error() string { return e.Message }
+
+func (e *Error) Unwrap() error { return e.Err }
+
+// invalid wraps a rejected argument. Its code is the upper-cased validation
+// code.
+func invalid(err error) *Error {
+	code, msg := strings.ToUpper(CodeInvalidAmount), err.Error()
+	var verr *ValidationError
+	if errors.As(err, &verr) {
+		code, msg = strings.ToUpper(verr.Code), verr.Message
+	}
+	return &Error{Kind: KindInvalid, Code: code, Message: msg, Err: err}
+}
+
+// Classify wraps a provider or fee failure, for callers using the provider
+// directly too.
+func Classify(from, to string, err error) *Error {
+	switch {
+	case errors.As(err, new(provider.MissingAPIKeyError)):
+		return &Error{Kind: KindUnavailable, Code: CodeProviderMissingAPIKey,
+			Message: "exchange provider requires an API key. Set EXCHANGE_API_KEY.", Err: err}
+	case errors.Is(err, provider.ErrQuotaExceeded):
+		return &Error{Kind: KindUnavailable, Code: CodeProviderQuotaExceeded,
+			Message: "exchange provider monthly quota exceeded", Err: err}
+	case errors.Is(err, context.DeadlineExceeded):
+		return &Error{Kind: KindTimeout, Code: CodeTimeout, Message: "conversion timed out", Err: err}
+	case errors.Is(err, provider.ErrCurrencyNotSupported):
+		return &Error{Kind: KindUnsupported, Code: CodeCurrencyNotSupported,
+			Message: "currency pair " + strings.ToUpper(from) + "->" + strings.ToUpper(to) + " is not supported by the provider", Err: err}
+	case errors.Is(err, fee.ErrUnavailable):
+		return &Error{Kind: KindUnavailable, Code: CodeFeeUnavailable, Message: "fee service unavailable", Err: err}
+	default:
+		return &Error{Kind: KindInternal, Code: CodeProviderError, Message: "exchange provider request failed", Err: err}
+	}
+}
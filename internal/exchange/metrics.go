@@ -0,0 +1,97 @@
+package exchange
+
+import (
+	"cmp"
+	"context"
+	"sync"
+
+	"github.com/thiagozs/go-exchange/internal/config"
+	"github.com/thiagozs/go-exchange/internal/currency"
+	"github.com/thiagozs/go-exchange/internal/fee"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+const meterName = "github.com/thiagozs/go-exchange/internal/exchange"
+
+// anonymousTenant labels conversions of requests without an API key.
+const anonymousTenant = "anonymous"
+
+// otherCurrency labels codes outside the currency table, so junk codes that
+// reach a provider cannot add metric series.
+const otherCurrency = "other"
+
+// conversionMetrics holds the business instruments of a Service. They are
+// created on first use from the global MeterProvider, so SetupOTel (or a
+// test) can install it after the service is built.
+type conversionMetrics struct {
+	once        sync.Once
+	conversions metric.Int64Counter
+	amount      metric.Int64Histogram
+	feeRevenue  metric.Int64Counter
+	unitMissing metric.Int64Counter
+}
+
+func (m *conversionMetrics) init() {
+	m.once.Do(func() {
+		meter := otel.GetMeterProvider().Meter(meterName)
+		m.conversions, _ = meter.Int64Counter("exchange.conversions",
+			metric.WithDescription("Successful conversions"),
+			metric.WithUnit("{conversion}"),
+		)
+		m.amount, _ = meter.Int64Histogram("exchange.amount_cents",
+			metric.WithDescription("Converted amounts, in cents of the source currency"),
+			metric.WithUnit("{cent}"),
+		)
+		m.feeRevenue, _ = meter.Int64Counter("exchange.fee_revenue_cents",
+			metric.WithDescription("Fees charged, in cents of the target currency"),
+			metric.WithUnit("{cent}"),
+		)
+		m.unitMissing, _ = meter.Int64Counter("exchange.amount_unit_missing",
+			metric.WithDescription("Conversion requests whose amount had no unit (deprecated)"),
+			metric.WithUnit("{request}"),
+		)
+	})
+}
+
+// metricCurrency is code when it is in the currency table, otherCurrency
+// otherwise.
+func metricCurrency(code string) string {
+	if _, ok := currency.Lookup(code); ok {
+		return code
+	}
+	return otherCurrency
+}
+
+// record counts a successful conversion served by provider, labelled with
+// the tenant in ctx and its fee plan; tenants are API key names, so they are
+// bounded by API_KEYS.
+func (m *conversionMetrics) record(ctx context.Context, provider string, c ConvertResponse) {
+	m.init()
+	if m.conversions == nil {
+		return
+	}
+	tenant, _ := fee.TenantFromContext(ctx)
+	attrs := metric.WithAttributes(
+		attribute.String("provider.name", provider),
+		attribute.String("exchange.from", metricCurrency(c.From)),
+		attribute.String("exchange.to", metricCurrency(c.To)),
+		attribute.String("exchange.tenant", cmp.Or(tenant.Name, anonymousTenant)),
+		attribute.String("exchange.fee_plan", cmp.Or(tenant.Plan, config.DefaultFeePlan)),
+	)
+	m.conversions.Add(ctx, 1, attrs)
+	m.amount.Record(ctx, c.AmountCents, attrs)
+	if c.FeeAmount.TotalCents > 0 {
+		m.feeRevenue.Add(ctx, c.FeeAmount.TotalCents, attrs)
+	}
+}
+
+// recordMissingUnit counts a request of client whose amount had no unit.
+func (m *conversionMetrics) recordMissingUnit(ctx context.Context, client string) {
+	m.init()
+	if m.unitMissing == nil {
+		return
+	}
+	m.unitMissing.Add(ctx, 1, metric.WithAttributes(attribute.String("exchange.tenant", client)))
+}
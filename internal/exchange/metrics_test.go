@@ -0,0 +1,141 @@
+package exchange
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/thiagozs/go-exchange/internal/config"
+	"github.com/thiagozs/go-exchange/internal/fee"
+	"github.com/thiagozs/go-exchange/internal/logger"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestConvertRecordsConversionMetrics(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	prev := otel.GetMeterProvider()
+	otel.SetMeterProvider(mp)
+	t.Cleanup(func() {
+		otel.SetMeterProvider(prev)
+		_ = mp.Shutdown(context.Background())
+	})
+
+	svc := newTestService(&config.Config{CacheTTL: time.Minute, Provider: "bcb"}, &metaProv{ts: time.Now()},
+		newMemCache(), fee.NewEnvFeeProviderWithPercent(0.01))
+	for range 2 {
+		if _, err := svc.Convert(context.Background(), ConvertRequest{From: "USD", To: "BRL", Amount: "10.00"}); err != nil {
+			t.Fatalf("convert: %v", err)
+		}
+	}
+	if _, err := svc.Convert(context.Background(), ConvertRequest{From: "USD", To: "XYZ", Amount: "10.00"}); err != nil {
+		t.Fatalf("convert: %v", err)
+	}
+	partner := fee.WithTenant(context.Background(), fee.Tenant{Name: "partner", Plan: "gold"})
+	if _, err := svc.Convert(partner, ConvertRequest{From: "USD", To: "BRL", Amount: "10.00"}); err != nil {
+		t.Fatalf("convert: %v", err)
+	}
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatalf("collect: %v", err)
+	}
+	data := map[string]metricdata.Aggregation{}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			data[m.Name] = m.Data
+		}
+	}
+
+	labels := func(to, tenant, plan string) attribute.Set {
+		return attribute.NewSet(attribute.String("provider.name", "bcb"),
+			attribute.String("exchange.from", "USD"), attribute.String("exchange.to", to),
+			attribute.String("exchange.tenant", tenant), attribute.String("exchange.fee_plan", plan))
+	}
+	pair := func(to string) attribute.Set { return labels(to, "anonymous", "default") }
+	sums := func(name string) map[attribute.Set]int64 {
+		out := map[attribute.Set]int64{}
+		sum, _ := data[name].(metricdata.Sum[int64])
+		for _, dp := range sum.DataPoints {
+			out[dp.Attributes] = dp.Value
+		}
+		return out
+	}
+	if got := sums("exchange.conversions"); got[pair("BRL")] != 2 || got[pair("other")] != 1 ||
+		got[labels("BRL", "partner", "gold")] != 1 || len(got) != 3 {
+		t.Fatalf("expected 2 USD/BRL, 1 USD/other and 1 partner conversions, got %v", got)
+	}
+	// 10.00 USD at 5 is 50.00 BRL, 1% fee
+	if got := sums("exchange.fee_revenue_cents"); got[pair("BRL")] != 100 {
+		t.Fatalf("expected 100 cents of fee revenue, got %v", got)
+	}
+	amounts := map[attribute.Set]metricdata.HistogramDataPoint[int64]{}
+	hist, _ := data["exchange.amount_cents"].(metricdata.Histogram[int64])
+	for _, dp := range hist.DataPoints {
+		amounts[dp.Attributes] = dp
+	}
+	if dp := amounts[pair("BRL")]; len(amounts) != 3 || dp.Count != 2 || dp.Sum != 2000 {
+		t.Fatalf("expected two USD/BRL amounts of 1000 cents, got %d/%d over %d pairs", dp.Count, dp.Sum, len(amounts))
+	}
+}
+
+func TestConvertWarnsMissingUnitOncePerClient(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	prev := otel.GetMeterProvider()
+	otel.SetMeterProvider(mp)
+	t.Cleanup(func() {
+		otel.SetMeterProvider(prev)
+		_ = mp.Shutdown(context.Background())
+	})
+
+	var logs bytes.Buffer
+	lg := logger.New(logger.Options{Format: "text", Level: "info", Out: &logs})
+	svc := New(&config.Config{CacheTTL: time.Minute}, &countingProv{}, newMemCache(), nil, lg)
+	now := time.Now()
+	svc.now = func() time.Time { return now }
+	partner := fee.WithTenant(context.Background(), fee.Tenant{Name: "partner"})
+	convert := func(ctx context.Context, unit string) {
+		t.Helper()
+		if _, err := svc.Convert(ctx, ConvertRequest{From: "USD", To: "BRL", Amount: "1000", Unit: unit}); err != nil {
+			t.Fatalf("convert: %v", err)
+		}
+	}
+	for range 3 {
+		convert(context.Background(), "")
+		convert(partner, "")
+		convert(partner, UnitCents)
+	}
+	if got := strings.Count(logs.String(), "deprecated: amount"); got != 2 {
+		t.Fatalf("expected one warning per client, got %d:\n%s", got, logs.String())
+	}
+	now = now.Add(unitWarningInterval)
+	convert(partner, "")
+	if got := strings.Count(logs.String(), "deprecated: amount"); got != 3 {
+		t.Fatalf("expected another warning after the interval, got %d", got)
+	}
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatalf("collect: %v", err)
+	}
+	got := map[string]int64{}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if sum, ok := m.Data.(metricdata.Sum[int64]); ok && m.Name == "exchange.amount_unit_missing" {
+				for _, dp := range sum.DataPoints {
+					tenant, _ := dp.Attributes.Value("exchange.tenant")
+					got[tenant.AsString()] = dp.Value
+				}
+			}
+		}
+	}
+	if got["anonymous"] != 3 || got["partner"] != 4 || len(got) != 2 {
+		t.Fatalf("expected every request without unit counted, got %v", got)
+	}
+}
@@ -0,0 +1,365 @@
+// Package exchange converts amounts between currencies: it validates the
+// request, serves the provider result from the conversion cache and applies
+// the configured fee. The HTTP and gRPC servers and the convert command are
+// thin adapters over Service.
+package exchange
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/thiagozs/go-exchange/internal/config"
+	"github.com/thiagozs/go-exchange/internal/fee"
+	"github.com/thiagozs/go-exchange/internal/logger"
+	"github.com/thiagozs/go-exchange/internal/provider"
+)
+
+// Reasons reported in ConvertResponse.FeeWaived.
+const (
+	FeeWaivedExemptPair = "exempt_pair"
+	FeeWaivedRequest    = "include_fee=false"
+)
+
+// rateProbeCents is the amount converted to look up a rate: large enough
+// that rounding to cents does not distort rates derived from the result.
+const rateProbeCents = 1_000_000
+
+// ConvertRequest is one conversion. Amount accepts cents ("1000") or decimal
+// units ("10.00").
+type ConvertRequest struct {
+	From   string
+	To     string
+	Amount string
+	// Unit is UnitCents or UnitMajor; empty leaves it to the amount format
+	// when AMOUNT_HEURISTIC allows it.
+	Unit string
+	// WaiveFee skips the fee; callers check the permission for it.
+	WaiveFee bool
+	// SkipCacheRead revalidates against the provider and SkipCacheWrite does
+	// not store the result in the conversion cache.
+	SkipCacheRead  bool
+	SkipCacheWrite bool
+}
+
+// ConvertResponse is a priced conversion: the provider result for
+// AmountCents with the fee applied.
+type ConvertResponse struct {
+	From        string
+	To          string
+	AmountCents int64
+	// Unit is the unit Amount was read in.
+	Unit   string
+	Result provider.ConvertResult
+	// CacheHit reports whether the conversion or the provider rate came from
+	// a cache.
+	CacheHit bool
+
+	Fee       fee.FeeQuote
+	FeeAmount fee.FeeAmount
+	// FeeUnavailable is set when the fee could not be quoted and none was
+	// charged (FEE_FAIL_OPEN).
+	FeeUnavailable bool
+	// FeeWaived is the reason no fee was charged, empty when it was.
+	FeeWaived string
+	// Expires is when the conversion cache entry behind Result expires, so
+	// clients may reuse the response until then. It is zero when the result
+	// was not cached.
+	Expires time.Time
+	CacheFreshness
+}
+
+// CacheFreshness tells how fresh a result read from the conversion cache is.
+type CacheFreshness struct {
+	// FromCache reports whether the result was read from the conversion
+	// cache; the other fields are only set then.
+	FromCache bool
+	// FetchedAt is when the provider result was fetched, zero for entries
+	// written before it was recorded.
+	FetchedAt time.Time
+	// RateAge is how long before the response FetchedAt was.
+	RateAge time.Duration
+	// CacheExpiresIn is how long the entry has left, zero when unknown.
+	CacheExpiresIn time.Duration
+}
+
+// freshness describes entry as seen at now.
+func freshness(entry cacheEntry, now, expires time.Time) CacheFreshness {
+	if !entry.read {
+		return CacheFreshness{}
+	}
+	f := CacheFreshness{FromCache: true, FetchedAt: entry.storedAt}
+	if !entry.storedAt.IsZero() {
+		f.RateAge = max(now.Sub(entry.storedAt), 0)
+	}
+	if !expires.IsZero() {
+		f.CacheExpiresIn = max(expires.Sub(now), 0)
+	}
+	return f
+}
+
+// NetResultCents is the converted amount minus the fee.
+func (c ConvertResponse) NetResultCents() int64 {
+	return c.Result.ResultCents - c.FeeAmount.TotalCents
+}
+
+// RateRequest asks for the provider rate of a currency pair.
+type RateRequest struct {
+	From string
+	To   string
+}
+
+// RateResponse is the provider rate for a currency pair.
+type RateResponse struct {
+	From          string
+	To            string
+	Rate          float64
+	RateTimestamp time.Time
+	Source        string
+	Stale         bool
+	CacheHit      bool
+	CacheFreshness
+}
+
+// Service performs conversions with a provider, the conversion cache and a
+// fee provider.
+type Service struct {
+	prov      provider.Provider
+	cache     provider.Cache
+	fee       fee.Provider
+	feeLimits config.FeeLimits
+	log       *logger.Logger
+
+	cacheTTL       time.Duration
+	maxAmountCents int64
+	// unitRequired rejects amounts without a unit (AMOUNT_HEURISTIC=false)
+	unitRequired bool
+	exemptPairs  config.FeePairSet
+	feeFailOpen  bool
+	feeRounding  string
+	now          func() time.Time
+	// provider names the provider of a WithProvider copy; it is part of the
+	// conversion cache keys.
+	provider string
+	// providerName labels the conversion metrics: EXCHANGE_PROVIDER, or the
+	// name given to WithProvider.
+	providerName string
+	metrics      *conversionMetrics
+	unitWarnings *unitWarnings
+	// demand counts the base currencies converted with prov; nil on
+	// WithProvider copies.
+	demand DemandRecorder
+	// switched is the provider set by SetProvider, nil while prov is in use.
+	// It is nil itself on WithProvider copies, which keep their provider.
+	switched *atomic.Pointer[activeProvider]
+}
+
+// activeProvider is a provider switched to with SetProvider.
+type activeProvider struct {
+	prov provider.Provider
+	name string
+}
+
+// DemandRecorder is told the base currency of every conversion, to find the
+// bases worth refreshing ahead of time.
+type DemandRecorder interface {
+	Record(base string)
+}
+
+// TrackDemand reports the base of every conversion of s to d. It must be
+// called before s serves requests.
+func (s *Service) TrackDemand(d DemandRecorder) {
+	s.demand = d
+}
+
+// New builds the service. fp may be nil when no fee is configured.
+func New(cfg *config.Config, prov provider.Provider, cache provider.Cache, fp fee.Provider, lg *logger.Logger) *Service {
+	return &Service{
+		prov:           prov,
+		cache:          cache,
+		fee:            fp,
+		feeLimits:      cfg.FeeLimits,
+		log:            lg.With(map[string]any{"component": "exchange"}),
+		cacheTTL:       cfg.CacheTTL,
+		maxAmountCents: cfg.MaxAmountCents,
+		unitRequired:   cfg.AmountUnitRequired,
+		exemptPairs:    cfg.FeeExemptPairs,
+		feeFailOpen:    cfg.FeeFailOpen,
+		feeRounding:    cfg.FeeRounding,
+		now:            time.Now,
+		providerName:   cmp.Or(cfg.Provider, "exchangerate.host"),
+		metrics:        &conversionMetrics{},
+		unitWarnings:   &unitWarnings{last: map[string]time.Time{}},
+		switched:       &atomic.Pointer[activeProvider]{},
+	}
+}
+
+// WithProvider returns a copy of s converting with prov, named name. Its
+// conversions are cached under their own keys, so they never mix with those
+// of the configured provider.
+func (s *Service) WithProvider(name string, prov provider.Provider) *Service {
+	c := *s
+	c.prov, c.provider, c.providerName = prov, name, name
+	c.demand, c.switched = nil, nil
+	return &c
+}
+
+// SetProvider makes s convert with prov, named name, and returns the name of
+// the provider it replaces. Conversions in flight finish with the provider
+// they started with. Like WithProvider, a switched provider caches under its
+// own keys; switching back to the configured provider by its name restores
+// its keys and demand tracking.
+func (s *Service) SetProvider(name string, prov provider.Provider) (previous string) {
+	var next *activeProvider
+	if name != s.providerName {
+		next = &activeProvider{prov: prov, name: name}
+	}
+	if prev := s.switched.Swap(next); prev != nil {
+		return prev.name
+	}
+	return s.providerName
+}
+
+// Provider returns the provider s converts with and its name.
+func (s *Service) Provider() (name string, prov provider.Provider) {
+	c := s.current()
+	return c.providerName, c.prov
+}
+
+// current returns s, or a copy of it converting with the provider set by
+// SetProvider. Requests call it once so a switch cannot change their
+// provider halfway.
+func (s *Service) current() *Service {
+	if s.switched == nil {
+		return s
+	}
+	a := s.switched.Load()
+	if a == nil {
+		return s
+	}
+	return s.WithProvider(a.name, a.prov)
+}
+
+// Convert validates req and converts it. Failures are *Error.
+func (s *Service) Convert(ctx context.Context, req ConvertRequest) (ConvertResponse, error) {
+	s = s.current()
+	cents, unit, err := s.validate(req)
+	if err != nil {
+		return ConvertResponse{}, invalid(err)
+	}
+	if req.Unit == "" {
+		s.warnMissingUnit(ctx, req.Amount, unit)
+	}
+	policy := cachePolicy{read: !req.SkipCacheRead, write: !req.SkipCacheWrite}
+	conv, hit, entry, err := s.cachedConvert(ctx, policy, req.From, req.To, cents)
+	if err != nil {
+		return ConvertResponse{}, Classify(req.From, req.To, err)
+	}
+	resp, err := s.price(ctx, req, cents, conv, hit)
+	if err != nil {
+		return ConvertResponse{}, Classify(req.From, req.To, err)
+	}
+	resp.Unit = unit
+	now := s.now()
+	resp.Expires = entry.expires(now, s.cacheTTL)
+	resp.CacheFreshness = freshness(entry, now, resp.Expires)
+	s.metrics.record(ctx, s.providerName, resp)
+	return resp, nil
+}
+
+// unitWarningInterval is how often the deprecation of amounts without unit
+// is logged per client; every such request is still counted.
+var unitWarningInterval = time.Hour
+
+// unitWarnings remembers when each client was last warned about an amount
+// without unit. Clients are API key names, so they are bounded by API_KEYS.
+type unitWarnings struct {
+	mu   sync.Mutex
+	last map[string]time.Time
+}
+
+// due reports whether client is to be warned at now, and if so records it.
+func (w *unitWarnings) due(client string, now time.Time) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if last, ok := w.last[client]; ok && now.Sub(last) < unitWarningInterval {
+		return false
+	}
+	w.last[client] = now
+	return true
+}
+
+// warnMissingUnit counts a request whose amount had no unit and logs the
+// deprecation at most once per unitWarningInterval for its client.
+func (s *Service) warnMissingUnit(ctx context.Context, amount, unit string) {
+	tenant, _ := fee.TenantFromContext(ctx)
+	client := cmp.Or(tenant.Name, anonymousTenant)
+	s.metrics.recordMissingUnit(ctx, client)
+	if s.unitWarnings.due(client, s.now()) {
+		s.log.WithContext(ctx).Warnf("deprecated: amount %q without unit read as %s; pass unit=cents or unit=major (client %s, logged once per %s)",
+			amount, unit, client, unitWarningInterval)
+	}
+}
+
+// Rate returns the provider rate for a currency pair, sharing the conversion
+// cache with Convert. Providers that do not report the rate get it derived
+// from a probe conversion. Failures are *Error.
+func (s *Service) Rate(ctx context.Context, req RateRequest) (RateResponse, error) {
+	s = s.current()
+	if err := ValidateCurrency("from", req.From); err != nil {
+		return RateResponse{}, invalid(err)
+	}
+	if err := ValidateCurrency("to", req.To); err != nil {
+		return RateResponse{}, invalid(err)
+	}
+	conv, hit, entry, err := s.cachedConvert(ctx, cachePolicy{read: true, write: true}, req.From, req.To, rateProbeCents)
+	if err != nil {
+		return RateResponse{}, Classify(req.From, req.To, err)
+	}
+	now := s.now()
+	rate := conv.Rate
+	if rate == 0 {
+		rate = float64(conv.ResultCents) / rateProbeCents
+	}
+	return RateResponse{
+		From:           req.From,
+		To:             req.To,
+		Rate:           rate,
+		RateTimestamp:  conv.RateTimestamp,
+		Source:         conv.Source,
+		Stale:          conv.Stale,
+		CacheHit:       hit,
+		CacheFreshness: freshness(entry, now, entry.expires(now, s.cacheTTL)),
+	}, nil
+}
+
+// price applies the fee to a provider result, quoted on the gross converted
+// amount. Exempt pairs and waived requests pay none.
+func (s *Service) price(ctx context.Context, req ConvertRequest, cents int64, conv provider.ConvertResult, hit bool) (ConvertResponse, error) {
+	c := ConvertResponse{From: req.From, To: req.To, AmountCents: cents, Result: conv, CacheHit: hit}
+
+	switch {
+	case req.WaiveFee:
+		c.FeeWaived = FeeWaivedRequest
+	case fee.Exempt(s.exemptPairs, req.From, req.To):
+		c.FeeWaived = FeeWaivedExemptPair
+	case s.fee != nil || len(s.feeLimits) > 0:
+		stop := track(ctx, PhaseFee)
+		q, err := fee.AsQuoter(s.fee, s.feeLimits).Quote(ctx, req.From, req.To, conv.ResultCents)
+		stop()
+		switch {
+		case err == nil:
+			c.Fee = q
+		case errors.Is(err, fee.ErrUnavailable) && !s.feeFailOpen:
+			return ConvertResponse{}, err
+		default:
+			s.log.WithContext(ctx).Warnf("fee unavailable for %s->%s, charging no fee: %v", req.From, req.To, err)
+			c.FeeUnavailable = true
+		}
+	}
+	c.FeeAmount = c.Fee.Amount(conv.ResultCents, s.feeRounding)
+	return c, nil
+}
@@ -0,0 +1,689 @@
+package exchange
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/thiagozs/go-exchange/internal/cache"
+	"github.com/thiagozs/go-exchange/internal/config"
+	"github.com/thiagozs/go-exchange/internal/fee"
+	"github.com/thiagozs/go-exchange/internal/logger"
+	"github.com/thiagozs/go-exchange/internal/provider"
+)
+
+// memCache is an in-memory cache recording writes. With now set it tracks
+// expiries for GetWithTTL, without ever expiring entries.
+type memCache struct {
+	mu      sync.Mutex
+	m       map[string]string
+	sets    int
+	now     func() time.Time
+	expires map[string]time.Time
+}
+
+func newMemCache() *memCache {
+	return &memCache{m: map[string]string{}, expires: map[string]time.Time{}}
+}
+
+func (c *memCache) Get(ctx context.Context, key string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.m[key], nil
+}
+
+func (c *memCache) GetWithTTL(ctx context.Context, key string) (string, time.Duration, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if exp, ok := c.expires[key]; ok && c.now != nil {
+		return c.m[key], exp.Sub(c.now()), nil
+	}
+	return c.m[key], 0, nil
+}
+
+func (c *memCache) Set(ctx context.Context, key string, value string, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sets++
+	c.m[key] = value
+	if c.now != nil && ttl > 0 {
+		c.expires[key] = c.now().Add(ttl)
+	}
+	return nil
+}
+
+func (c *memCache) GetOrSet(ctx context.Context, key string, ttl time.Duration, fill func(ctx context.Context) (string, error)) (string, error) {
+	if v, _ := c.Get(ctx, key); v != "" {
+		return v, nil
+	}
+	v, err := fill(ctx)
+	if err == nil && v != "" {
+		_ = c.Set(ctx, key, v, ttl)
+	}
+	return v, err
+}
+
+func (c *memCache) Delete(ctx context.Context, key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.m, key)
+	return nil
+}
+
+func (c *memCache) DeleteByPrefix(ctx context.Context, prefix string) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var n int64
+	for k := range c.m {
+		if strings.HasPrefix(k, prefix) {
+			delete(c.m, k)
+			n++
+		}
+	}
+	return n, nil
+}
+
+// countingProv returns a fixed result and counts calls.
+type countingProv struct {
+	mu    sync.Mutex
+	calls int
+}
+
+func (p *countingProv) Convert(ctx context.Context, from, to string, amount int64) (int64, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.calls++
+	return 20000, nil
+}
+
+// zeroProv misbehaves by converting every amount to nothing.
+type zeroProv struct{ countingProv }
+
+func (p *zeroProv) Convert(ctx context.Context, from, to string, amount int64) (int64, error) {
+	p.countingProv.Convert(ctx, from, to, amount)
+	return 0, nil
+}
+
+// blockingProv blocks until released and counts calls.
+type blockingProv struct {
+	countingProv
+	release chan struct{}
+}
+
+func (p *blockingProv) Convert(ctx context.Context, from, to string, amount int64) (int64, error) {
+	<-p.release
+	return p.countingProv.Convert(ctx, from, to, amount)
+}
+
+type metaProv struct{ ts time.Time }
+
+func (p *metaProv) Convert(ctx context.Context, from, to string, amount int64) (int64, error) {
+	return 5000, nil
+}
+
+func (p *metaProv) ConvertDetailed(ctx context.Context, from, to string, amount int64) (provider.ConvertResult, error) {
+	return provider.ConvertResult{ResultCents: 5000, Rate: 5, RateTimestamp: p.ts, Source: "bcb"}, nil
+}
+
+type staleProv struct{ ts time.Time }
+
+func (p *staleProv) Convert(ctx context.Context, from, to string, amount int64) (int64, error) {
+	return 20000, nil
+}
+
+func (p *staleProv) ConvertDetailed(ctx context.Context, from, to string, amount int64) (provider.ConvertResult, error) {
+	return provider.ConvertResult{ResultCents: 20000, RateTimestamp: p.ts, Stale: true}, nil
+}
+
+type failingProv struct{ err error }
+
+func (p *failingProv) Convert(ctx context.Context, from, to string, amount int64) (int64, error) {
+	return 0, p.err
+}
+
+func newTestService(cfg *config.Config, prov provider.Provider, c provider.Cache, fp fee.Provider) *Service {
+	lg := logger.New(logger.Options{Format: "text", Level: "debug", Out: &bytes.Buffer{}})
+	return New(cfg, prov, c, fp, lg)
+}
+
+func TestConvert(t *testing.T) {
+	ts := time.Date(2025, 9, 19, 12, 0, 0, 0, time.UTC)
+	svc := newTestService(&config.Config{CacheTTL: time.Minute}, &metaProv{ts: ts}, newMemCache(),
+		fee.NewEnvFeeProviderWithPercent(0.01))
+
+	for _, wantHit := range []bool{false, true} {
+		c, err := svc.Convert(context.Background(), ConvertRequest{From: "USD", To: "BRL", Amount: "10.00"})
+		if err != nil {
+			t.Fatalf("convert: %v", err)
+		}
+		if c.AmountCents != 1000 || c.Result.ResultCents != 5000 || c.Result.Rate != 5 || c.Result.Source != "bcb" ||
+			!c.Result.RateTimestamp.Equal(ts) || c.CacheHit != wantHit {
+			t.Fatalf("unexpected conversion %+v", c)
+		}
+		if c.Fee.Percent != 0.01 || c.FeeAmount.TotalCents != 50 || c.NetResultCents() != 4950 || c.FeeWaived != "" {
+			t.Fatalf("unexpected fee %+v %+v", c.Fee, c.FeeAmount)
+		}
+	}
+}
+
+func TestConvertValidation(t *testing.T) {
+	p := &countingProv{}
+	svc := newTestService(&config.Config{MaxAmountCents: 1000000}, p, newMemCache(), nil)
+
+	cases := []struct {
+		req  ConvertRequest
+		code string
+	}{
+		{ConvertRequest{From: "USD", To: "BRL"}, "MISSING_PARAMETERS"},
+		{ConvertRequest{From: "US", To: "BRL", Amount: "1000"}, "INVALID_CURRENCY"},
+		{ConvertRequest{From: "USD", To: "BRL", Amount: "abc"}, "INVALID_AMOUNT"},
+		{ConvertRequest{From: "USD", To: "BRL", Amount: "0"}, "INVALID_AMOUNT"},
+		{ConvertRequest{From: "USD", To: "BRL", Amount: "10.001"}, "INVALID_AMOUNT"},
+		{ConvertRequest{From: "USD", To: "BRL", Amount: "1000001"}, "AMOUNT_TOO_LARGE"},
+	}
+	for _, tc := range cases {
+		_, err := svc.Convert(context.Background(), tc.req)
+		var cerr *Error
+		if !errors.As(err, &cerr) || cerr.Kind != KindInvalid || cerr.Code != tc.code || cerr.Message == "" {
+			t.Fatalf("%+v: expected an invalid %s error, got %#v", tc.req, tc.code, err)
+		}
+		var verr *ValidationError
+		if !errors.As(err, &verr) {
+			t.Fatalf("%+v: expected a *ValidationError in the chain, got %#v", tc.req, cerr.Err)
+		}
+	}
+	if p.calls != 0 {
+		t.Fatalf("provider must not be called for rejected requests, got %d calls", p.calls)
+	}
+}
+
+func TestConvertFeeRounding(t *testing.T) {
+	for mode, want := range map[string]int64{config.FeeRoundingHalfUp: 1, config.FeeRoundingHalfEven: 0} {
+		// 0.01% of 5000 cents is half a cent
+		svc := newTestService(&config.Config{FeeRounding: mode}, &metaProv{}, newMemCache(), fee.NewEnvFeeProviderWithPercent(0.0001))
+		c, err := svc.Convert(context.Background(), ConvertRequest{From: "USD", To: "BRL", Amount: "1000", Unit: UnitCents})
+		if err != nil || c.FeeAmount.TotalCents != want || c.FeeAmount.Rounding != mode {
+			t.Fatalf("%s: expected a fee of %d cents, got %+v (%v)", mode, want, c.FeeAmount, err)
+		}
+	}
+}
+
+func TestConvertAmountUnit(t *testing.T) {
+	svc := newTestService(&config.Config{}, &countingProv{}, newMemCache(), nil)
+
+	cases := []struct {
+		amount, unit string
+		cents        int64
+		wantUnit     string
+		code         string
+	}{
+		{amount: "1000", unit: UnitCents, cents: 1000, wantUnit: UnitCents},
+		{amount: "10.00", unit: UnitCents, code: CodeInvalidAmount},
+		{amount: "10", unit: UnitMajor, cents: 1000, wantUnit: UnitMajor},
+		{amount: "10.50", unit: UnitMajor, cents: 1050, wantUnit: UnitMajor},
+		{amount: "1000", cents: 1000, wantUnit: UnitCents},
+		{amount: "10.50", cents: 1050, wantUnit: UnitMajor},
+		{amount: "10", unit: "dollars", code: CodeInvalidUnit},
+	}
+	for _, tc := range cases {
+		c, err := svc.Convert(context.Background(), ConvertRequest{From: "USD", To: "BRL", Amount: tc.amount, Unit: tc.unit})
+		if tc.code != "" {
+			var verr *ValidationError
+			if !errors.As(err, &verr) || verr.Code != tc.code {
+				t.Fatalf("%s %q: expected %s, got %v", tc.amount, tc.unit, tc.code, err)
+			}
+			continue
+		}
+		if err != nil || c.AmountCents != tc.cents || c.Unit != tc.wantUnit {
+			t.Fatalf("%s %q: expected %d cents in %s, got %+v (%v)", tc.amount, tc.unit, tc.cents, tc.wantUnit, c, err)
+		}
+	}
+
+	strict := newTestService(&config.Config{AmountUnitRequired: true}, &countingProv{}, newMemCache(), nil)
+	_, err := strict.Convert(context.Background(), ConvertRequest{From: "USD", To: "BRL", Amount: "1000"})
+	var verr *ValidationError
+	if !errors.As(err, &verr) || verr.Code != CodeMissingUnit {
+		t.Fatalf("expected %s without a unit, got %v", CodeMissingUnit, err)
+	}
+	if c, err := strict.Convert(context.Background(), ConvertRequest{From: "USD", To: "BRL", Amount: "10", Unit: UnitMajor}); err != nil || c.AmountCents != 1000 {
+		t.Fatalf("expected an explicit unit accepted, got %+v (%v)", c, err)
+	}
+}
+
+func TestConvertErrorKinds(t *testing.T) {
+	upstream := errors.New("dial tcp: connection refused")
+	cases := []struct {
+		name string
+		err  error
+		kind Kind
+		code string
+	}{
+		{"unsupported currency", fmt.Errorf("%w: XYZ (%w)", provider.ErrCurrencyNotSupported, upstream), KindUnsupported, CodeCurrencyNotSupported},
+		{"missing provider key", provider.MissingAPIKeyError{}, KindUnavailable, CodeProviderMissingAPIKey},
+		{"quota exceeded", fmt.Errorf("%w: exchangerate-api used 1500 of 1500", provider.ErrQuotaExceeded), KindUnavailable, CodeProviderQuotaExceeded},
+		{"provider error", upstream, KindInternal, CodeProviderError},
+		{"timeout", fmt.Errorf("bcb: %w", context.DeadlineExceeded), KindTimeout, CodeTimeout},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			svc := newTestService(&config.Config{CacheTTL: time.Minute}, &failingProv{tc.err}, newMemCache(), nil)
+			_, err := svc.Convert(context.Background(), ConvertRequest{From: "USD", To: "XYZ", Amount: "1000"})
+			var cerr *Error
+			if !errors.As(err, &cerr) || cerr.Kind != tc.kind || cerr.Code != tc.code || !errors.Is(err, tc.err) {
+				t.Fatalf("expected a %s error wrapping the provider error, got %#v", tc.code, err)
+			}
+			if strings.Contains(cerr.Message, "connection refused") {
+				t.Fatalf("provider error leaked into the message: %q", cerr.Message)
+			}
+		})
+	}
+}
+
+func TestConvertSharesCacheKeyAcrossAmountFormats(t *testing.T) {
+	p := &countingProv{}
+	c := newMemCache()
+	svc := newTestService(&config.Config{CacheTTL: time.Minute}, p, c, nil)
+
+	for _, amount := range []string{"10.00", "1000", "10.0"} {
+		if _, err := svc.Convert(context.Background(), ConvertRequest{From: "USD", To: "BRL", Amount: amount}); err != nil {
+			t.Fatalf("convert %s: %v", amount, err)
+		}
+	}
+	if p.calls != 1 || c.sets != 1 {
+		t.Fatalf("expected one provider call and one write, got calls=%d sets=%d", p.calls, c.sets)
+	}
+	if _, ok := c.m["convert:v1:USD:BRL:1000"]; !ok {
+		t.Fatalf("expected the key in integer cents, got %v", c.m)
+	}
+}
+
+func TestWithProviderKeepsItsOwnCacheKeys(t *testing.T) {
+	def, other := &countingProv{}, &metaProv{}
+	c := newMemCache()
+	svc := newTestService(&config.Config{CacheTTL: time.Minute}, def, c, nil)
+	bcb := svc.WithProvider("bcb", other)
+
+	req := ConvertRequest{From: "USD", To: "BRL", Amount: "1000"}
+	if _, err := svc.Convert(context.Background(), req); err != nil {
+		t.Fatalf("convert: %v", err)
+	}
+	conv, err := bcb.Convert(context.Background(), req)
+	if err != nil {
+		t.Fatalf("convert with bcb: %v", err)
+	}
+	if conv.CacheHit || conv.Result.ResultCents != 5000 {
+		t.Fatalf("expected a bcb miss, got %+v", conv)
+	}
+	if c.m["convert:v1:USD:BRL:1000"] == "" || c.m["convert:v1:bcb:USD:BRL:1000"] == "" || len(c.m) != 2 {
+		t.Fatalf("expected one key per provider, got %v", c.m)
+	}
+	if conv, _ := svc.Convert(context.Background(), req); !conv.CacheHit || conv.Result.ResultCents != 20000 || def.calls != 1 {
+		t.Fatalf("the override must not touch the default entry, got %+v (calls=%d)", conv, def.calls)
+	}
+}
+
+func TestConvertExpiresWithTheCacheEntry(t *testing.T) {
+	now := time.Date(2025, 9, 19, 12, 0, 0, 0, time.UTC)
+	c := newMemCache()
+	svc := newTestService(&config.Config{CacheTTL: 5 * time.Minute}, &countingProv{}, c, nil)
+	svc.now = func() time.Time { return now }
+	req := ConvertRequest{From: "USD", To: "BRL", Amount: "1000"}
+
+	miss, err := svc.Convert(context.Background(), req)
+	if err != nil {
+		t.Fatalf("convert: %v", err)
+	}
+	if want := now.Add(5 * time.Minute); !miss.Expires.Equal(want) {
+		t.Fatalf("expected the miss to expire at %v, got %v", want, miss.Expires)
+	}
+
+	// a later hit keeps the expiry of the stored entry
+	now = now.Add(90 * time.Second)
+	hit, err := svc.Convert(context.Background(), req)
+	if err != nil || !hit.CacheHit || !hit.Expires.Equal(miss.Expires) {
+		t.Fatalf("expected a hit expiring at %v, got %+v (%v)", miss.Expires, hit, err)
+	}
+
+	// uncached results and entries without stored_at never expire
+	dry := req
+	dry.SkipCacheRead, dry.SkipCacheWrite = true, true
+	if r, err := svc.Convert(context.Background(), dry); err != nil || !r.Expires.IsZero() {
+		t.Fatalf("expected no expiry without a cache write, got %+v (%v)", r, err)
+	}
+	c.m["convert:v1:USD:BRL:1000"] = `{"result_cents":20000}`
+	if r, err := svc.Convert(context.Background(), req); err != nil || !r.CacheHit || !r.Expires.IsZero() {
+		t.Fatalf("expected no expiry for a legacy entry, got %+v (%v)", r, err)
+	}
+}
+
+func TestCacheFreshness(t *testing.T) {
+	now := time.Date(2025, 9, 19, 12, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+	c := newMemCache()
+	c.now = clock
+	svc := newTestService(&config.Config{CacheTTL: 5 * time.Minute}, &countingProv{}, c, nil)
+	svc.now = clock
+
+	miss, err := svc.Convert(context.Background(), ConvertRequest{From: "USD", To: "BRL", Amount: "1000"})
+	if err != nil {
+		t.Fatalf("convert: %v", err)
+	}
+	if miss.FromCache || miss.RateAge != 0 || miss.CacheExpiresIn != 0 {
+		t.Fatalf("expected no freshness on a miss, got %+v", miss.CacheFreshness)
+	}
+	if _, err := svc.Rate(context.Background(), RateRequest{From: "USD", To: "BRL"}); err != nil {
+		t.Fatalf("rate: %v", err)
+	}
+
+	now = now.Add(90 * time.Second)
+	hit, err := svc.Convert(context.Background(), ConvertRequest{From: "USD", To: "BRL", Amount: "1000"})
+	if err != nil {
+		t.Fatalf("convert: %v", err)
+	}
+	want := CacheFreshness{FromCache: true, FetchedAt: now.Add(-90 * time.Second), RateAge: 90 * time.Second, CacheExpiresIn: 210 * time.Second}
+	if hit.CacheFreshness != want {
+		t.Fatalf("expected %+v on the hit, got %+v", want, hit.CacheFreshness)
+	}
+	rate, err := svc.Rate(context.Background(), RateRequest{From: "USD", To: "BRL"})
+	if err != nil || rate.CacheFreshness != want {
+		t.Fatalf("expected %+v on the rate hit, got %+v (%v)", want, rate.CacheFreshness, err)
+	}
+
+	// the expiry reported by the cache wins over the configured TTL
+	for k := range c.expires {
+		c.expires[k] = now.Add(time.Minute)
+	}
+	if hit, err := svc.Convert(context.Background(), ConvertRequest{From: "USD", To: "BRL", Amount: "1000"}); err != nil ||
+		hit.CacheExpiresIn != time.Minute || !hit.Expires.Equal(now.Add(time.Minute)) {
+		t.Fatalf("expected the cache TTL to be reported, got %+v (%v)", hit, err)
+	}
+}
+
+func TestCacheSchemaBumpIgnoresOldEntries(t *testing.T) {
+	prev := cacheSchemaVersion
+	t.Cleanup(func() { cacheSchemaVersion = prev })
+
+	p := &countingProv{}
+	c := newMemCache()
+	svc := newTestService(&config.Config{CacheTTL: time.Minute}, p, c, nil)
+	req := ConvertRequest{From: "USD", To: "BRL", Amount: "1000"}
+	// an entry in the old shape: the rendered response of an earlier release
+	c.m[svc.conversionKey("USD", "BRL", 1000)] = `{"result":20000,"fee":{"percent":0.01}}`
+
+	cacheSchemaVersion++
+	got, err := svc.Convert(context.Background(), req)
+	if err != nil || got.CacheHit || p.calls != 1 || got.Result.ResultCents != 20000 {
+		t.Fatalf("expected the old entry to be ignored, got %+v (%v) after %d provider calls", got, err, p.calls)
+	}
+	if _, ok := c.m["convert:v"+strconv.Itoa(cacheSchemaVersion)+":USD:BRL:1000"]; !ok || len(c.m) != 2 {
+		t.Fatalf("expected the result under the new version, got %v", c.m)
+	}
+	if got, err := svc.Convert(context.Background(), req); err != nil || !got.CacheHit || p.calls != 1 {
+		t.Fatalf("expected a hit on the new entry, got %+v (%v)", got, err)
+	}
+}
+
+func TestConvertDoesNotCacheStaleOrZeroResults(t *testing.T) {
+	for _, prov := range []provider.Provider{&staleProv{ts: time.Now()}, &zeroProv{}} {
+		c := newMemCache()
+		svc := newTestService(&config.Config{CacheTTL: time.Minute}, prov, c, nil)
+		for range 2 {
+			if _, err := svc.Convert(context.Background(), ConvertRequest{From: "USD", To: "BRL", Amount: "1000"}); err != nil {
+				t.Fatalf("%T: convert: %v", prov, err)
+			}
+		}
+		if c.sets != 0 {
+			t.Fatalf("%T: expected no cache writes, got %d", prov, c.sets)
+		}
+	}
+}
+
+func TestConvertCachePolicy(t *testing.T) {
+	p := &countingProv{}
+	c := newMemCache()
+	svc := newTestService(&config.Config{CacheTTL: time.Minute}, p, c, nil)
+	req := ConvertRequest{From: "USD", To: "BRL", Amount: "1000"}
+	if _, err := svc.Convert(context.Background(), req); err != nil {
+		t.Fatalf("warmup: %v", err)
+	}
+
+	cases := []struct {
+		name      string
+		read      bool
+		write     bool
+		wantCalls int
+		wantSets  int
+	}{
+		{"read and write", true, true, 0, 0},
+		{"no read", false, true, 1, 1},
+		{"no write", true, false, 0, 0},
+		{"neither", false, false, 1, 0},
+	}
+	for _, tc := range cases {
+		p.calls, c.sets = 0, 0
+		req.SkipCacheRead, req.SkipCacheWrite = !tc.read, !tc.write
+		got, err := svc.Convert(context.Background(), req)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if p.calls != tc.wantCalls || c.sets != tc.wantSets || got.CacheHit != (tc.wantCalls == 0) {
+			t.Fatalf("%s: got calls=%d sets=%d hit=%v", tc.name, p.calls, c.sets, got.CacheHit)
+		}
+	}
+}
+
+func TestCachedConversionAppliesCurrentFee(t *testing.T) {
+	p := &countingProv{}
+	svc := newTestService(&config.Config{CacheTTL: time.Minute}, p, newMemCache(), fee.NewEnvFeeProviderWithPercent(0.01))
+	req := ConvertRequest{From: "USD", To: "BRL", Amount: "1000"}
+
+	if c, err := svc.Convert(context.Background(), req); err != nil || c.FeeAmount.TotalCents != 200 || c.CacheHit {
+		t.Fatalf("unexpected first conversion %+v (%v)", c, err)
+	}
+	svc.fee = fee.NewEnvFeeProviderWithPercent(0.02)
+	c, err := svc.Convert(context.Background(), req)
+	if err != nil {
+		t.Fatalf("convert: %v", err)
+	}
+	if c.Fee.Percent != 0.02 || c.FeeAmount.TotalCents != 400 || c.NetResultCents() != 19600 {
+		t.Fatalf("expected the new fee on the cached conversion, got %+v", c)
+	}
+	if !c.CacheHit || p.calls != 1 {
+		t.Fatalf("expected the conversion to come from the cache, got hit=%v after %d provider calls", c.CacheHit, p.calls)
+	}
+}
+
+func TestConvertFeeWaivers(t *testing.T) {
+	var pairs config.FeePairSet
+	if err := pairs.UnmarshalText([]byte("USD-BRL")); err != nil {
+		t.Fatalf("parse pairs: %v", err)
+	}
+	svc := newTestService(&config.Config{CacheTTL: time.Minute, FeeExemptPairs: pairs}, &countingProv{}, newMemCache(),
+		fee.NewEnvFeeProviderWithPercent(0.01))
+
+	cases := []struct {
+		req  ConvertRequest
+		want string
+	}{
+		{ConvertRequest{From: "USD", To: "BRL", Amount: "1000"}, FeeWaivedExemptPair},
+		{ConvertRequest{From: "BRL", To: "USD", Amount: "1000", WaiveFee: true}, FeeWaivedRequest},
+		{ConvertRequest{From: "BRL", To: "USD", Amount: "1000"}, ""},
+	}
+	for _, tc := range cases {
+		c, err := svc.Convert(context.Background(), tc.req)
+		if err != nil {
+			t.Fatalf("%+v: %v", tc.req, err)
+		}
+		if c.FeeWaived != tc.want || (tc.want != "") != (c.FeeAmount.TotalCents == 0) {
+			t.Fatalf("%+v: expected waiver %q, got %q with fee %+v", tc.req, tc.want, c.FeeWaived, c.FeeAmount)
+		}
+	}
+}
+
+func TestConvertFeeUnavailable(t *testing.T) {
+	feeSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "down", http.StatusServiceUnavailable)
+	}))
+	defer feeSrv.Close()
+
+	for _, failOpen := range []bool{true, false} {
+		lg := logger.New(logger.Options{Format: "text", Level: "info", Out: &bytes.Buffer{}})
+		fp := fee.NewFeeAPIProvider(feeSrv.URL, nil, fee.FeeAPIOptions{MaxRetries: 1, Backoff: time.Millisecond}, lg)
+		c := newMemCache()
+		svc := newTestService(&config.Config{CacheTTL: time.Minute, FeeFailOpen: failOpen}, &countingProv{}, c, fp)
+
+		got, err := svc.Convert(context.Background(), ConvertRequest{From: "USD", To: "BRL", Amount: "1000"})
+		if !failOpen {
+			var cerr *Error
+			if !errors.As(err, &cerr) || cerr.Kind != KindUnavailable || cerr.Code != CodeFeeUnavailable {
+				t.Fatalf("fail closed: expected a fee unavailable error, got %#v", err)
+			}
+		} else if err != nil || !got.FeeUnavailable || got.FeeAmount.TotalCents != 0 {
+			t.Fatalf("fail open: expected a zero fee, got %+v (%v)", got, err)
+		}
+		// the provider result is cached either way; only the fee failed
+		if c.sets != 1 {
+			t.Fatalf("expected the conversion to be cached, got %d writes", c.sets)
+		}
+	}
+}
+
+func TestConvertAppliesFeeLimits(t *testing.T) {
+	cfg := &config.Config{CacheTTL: time.Minute,
+		FeeLimits: config.FeeLimits{config.FeeDefault: {FixedCents: 10, MinCents: 500, MaxCents: 15000}}}
+	svc := newTestService(cfg, &countingProv{}, newMemCache(), fee.NewEnvFeeProviderWithPercent(0.01))
+
+	c, err := svc.Convert(context.Background(), ConvertRequest{From: "USD", To: "BRL", Amount: "1000"})
+	if err != nil {
+		t.Fatalf("convert: %v", err)
+	}
+	// 1% of 20000 plus 10 fixed is 210, raised to the 500 minimum
+	if c.FeeAmount.TotalCents != 500 || c.NetResultCents() != 19500 {
+		t.Fatalf("expected the minimum fee, got %+v", c.FeeAmount)
+	}
+}
+
+func TestConcurrentMissesShareOneProviderCall(t *testing.T) {
+	p := &blockingProv{release: make(chan struct{})}
+	svc := newTestService(&config.Config{CacheTTL: time.Minute}, p, cache.NewMemory(), nil)
+
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := svc.Convert(context.Background(), ConvertRequest{From: "USD", To: "BRL", Amount: "1000"}); err != nil {
+				t.Errorf("convert: %v", err)
+			}
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(p.release)
+	wg.Wait()
+	if p.calls != 1 {
+		t.Fatalf("expected one provider call, got %d", p.calls)
+	}
+}
+
+func TestConvertSurvivesUnresponsiveRedis(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+	accepted := make(chan net.Conn, 16)
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			accepted <- c // held open, never answered
+		}
+	}()
+	defer func() {
+		for len(accepted) > 0 {
+			(<-accepted).Close()
+		}
+	}()
+
+	lg := logger.New(logger.Options{Format: "text", Level: "info", Out: &bytes.Buffer{}})
+	rc := cache.New(ln.Addr().String(), 0, "", "", "", 100*time.Millisecond, lg)
+	defer rc.Close()
+	p := &countingProv{}
+	svc := New(&config.Config{CacheTTL: time.Minute}, p, rc, nil, lg)
+
+	start := time.Now()
+	if _, err := svc.Convert(context.Background(), ConvertRequest{From: "USD", To: "BRL", Amount: "1000"}); err != nil {
+		t.Fatalf("expected a conversion despite the cache timing out, got %v", err)
+	}
+	if d := time.Since(start); d > 2*time.Second {
+		t.Fatalf("conversion was held by the cache for %s", d)
+	}
+	if p.calls != 1 {
+		t.Fatalf("expected the provider to serve the conversion, got %d calls", p.calls)
+	}
+}
+
+func TestRate(t *testing.T) {
+	ts := time.Date(2025, 9, 19, 12, 0, 0, 0, time.UTC)
+	svc := newTestService(&config.Config{CacheTTL: time.Minute}, &metaProv{ts: ts}, newMemCache(), nil)
+
+	// the second lookup is served from the conversion cache
+	for _, wantHit := range []bool{false, true} {
+		q, err := svc.Rate(context.Background(), RateRequest{From: "USD", To: "BRL"})
+		if err != nil {
+			t.Fatalf("rate: %v", err)
+		}
+		if q.Rate != 5 || q.Source != "bcb" || !q.RateTimestamp.Equal(ts) || q.CacheHit != wantHit {
+			t.Fatalf("unexpected rate %+v", q)
+		}
+	}
+
+	// providers without rate metadata get it derived from the result
+	svc = newTestService(&config.Config{CacheTTL: time.Minute}, &countingProv{}, newMemCache(), nil)
+	if q, err := svc.Rate(context.Background(), RateRequest{From: "USD", To: "BRL"}); err != nil || q.Rate != 0.02 {
+		t.Fatalf("expected a derived rate of 0.02, got %+v (%v)", q, err)
+	}
+
+	var cerr *Error
+	if _, err := svc.Rate(context.Background(), RateRequest{From: "USD", To: "R$"}); !errors.As(err, &cerr) ||
+		cerr.Kind != KindInvalid || cerr.Code != "INVALID_CURRENCY" {
+		t.Fatalf("expected an input error, got %#v", err)
+	}
+}
+
+// phaseTimer records the phases it was asked to time.
+type phaseTimer struct {
+	mu     sync.Mutex
+	phases []string
+}
+
+func (t *phaseTimer) Track(phase string) func() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.phases = append(t.phases, phase)
+	return func() {}
+}
+
+func TestConvertReportsPhasesToTimer(t *testing.T) {
+	svc := newTestService(&config.Config{CacheTTL: time.Minute}, &countingProv{}, newMemCache(),
+		fee.NewEnvFeeProviderWithPercent(0.01))
+	tm := &phaseTimer{}
+	ctx := WithTimer(context.Background(), tm)
+	if _, err := svc.Convert(ctx, ConvertRequest{From: "USD", To: "BRL", Amount: "1000"}); err != nil {
+		t.Fatalf("convert: %v", err)
+	}
+	if got := strings.Join(tm.phases, ","); got != "cache,provider,fee" {
+		t.Fatalf("unexpected phases %s", got)
+	}
+}
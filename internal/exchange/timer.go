@@ -0,0 +1,32 @@
+package exchange
+
+import "context"
+
+// Phases of a conversion reported to a Timer.
+const (
+	PhaseCache    = "cache"
+	PhaseProvider = "provider"
+	PhaseFee      = "fee"
+)
+
+// Timer accumulates the time spent in each phase of a conversion; the HTTP
+// server reports it in the Server-Timing header.
+type Timer interface {
+	// Track starts timing phase and returns the function that stops it.
+	Track(phase string) (stop func())
+}
+
+type timerCtxKey struct{}
+
+// WithTimer returns a context whose conversions report to t.
+func WithTimer(ctx context.Context, t Timer) context.Context {
+	return context.WithValue(ctx, timerCtxKey{}, t)
+}
+
+// track starts timing phase on the context's Timer, if any.
+func track(ctx context.Context, phase string) func() {
+	if t, ok := ctx.Value(timerCtxKey{}).(Timer); ok {
+		return t.Track(phase)
+	}
+	return func() {}
+}
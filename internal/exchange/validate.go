@@ -0,0 +1,166 @@
+package exchange
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/thiagozs/go-exchange/internal/currency"
+)
+
+// Validation error codes, shared by /convert and /convert/batch.
+const (
+	CodeMissingParams   = "missing_parameters"
+	CodeMissingCurrency = "missing_currency"
+	CodeInvalidCurrency = "invalid_currency"
+	CodeMissingAmount   = "missing_amount"
+	CodeInvalidAmount   = "invalid_amount"
+	CodeAmountTooLarge  = "amount_too_large"
+	CodeMissingUnit     = "missing_unit"
+	CodeInvalidUnit     = "invalid_unit"
+)
+
+// Amount units: the unit parameter says whether amount is in cents or in
+// major units. Without it the amount format decides (see ParseAmount).
+const (
+	UnitCents = "cents"
+	UnitMajor = "major"
+)
+
+// maxAmountDecimals is the number of decimal places accepted for code: its
+// minor units, capped at 2 because amounts are carried in hundredths.
+func maxAmountDecimals(code string) int {
+	if c, ok := currency.Lookup(code); ok {
+		return min(c.MinorUnits, 2)
+	}
+	return 2
+}
+
+var currencyCodeRe = regexp.MustCompile(`^[A-Za-z]{3}$`)
+
+// ValidationError is a rejected input with a machine-readable code.
+type ValidationError struct {
+	Code    string
+	Message string
+}
+
+func (e *ValidationError) Error() string { return e.Message }
+
+// ValidateCurrency checks that code looks like an ISO 4217 code. field names
+// the parameter in the error message.
+func ValidateCurrency(field, code string) error {
+	if code == "" {
+		return &ValidationError{Code: CodeMissingCurrency, Message: field + " is required"}
+	}
+	if !currencyCodeRe.MatchString(code) {
+		return &ValidationError{Code: CodeInvalidCurrency, Message: field + " must be a 3-letter currency code"}
+	}
+	return nil
+}
+
+// Validate checks req without converting and returns the amount in cents.
+// Convert runs it first; failures are *ValidationError.
+func (s *Service) Validate(req ConvertRequest) (int64, error) {
+	cents, _, err := s.validate(req)
+	return cents, err
+}
+
+// validate is Validate, also returning the unit the amount was read in. The
+// unit is required with AMOUNT_HEURISTIC=false.
+func (s *Service) validate(req ConvertRequest) (int64, string, error) {
+	if req.From == "" || req.To == "" || req.Amount == "" {
+		return 0, "", &ValidationError{Code: CodeMissingParams, Message: "from, to and amount are required"}
+	}
+	if err := ValidateCurrency("from", req.From); err != nil {
+		return 0, "", err
+	}
+	if err := ValidateCurrency("to", req.To); err != nil {
+		return 0, "", err
+	}
+	if err := CheckUnit(req.Unit, s.unitRequired); err != nil {
+		return 0, "", err
+	}
+	cents, unit, err := ParseAmountUnit(req.Amount, req.Unit, req.From)
+	if err != nil {
+		return 0, "", err
+	}
+	return cents, unit, CheckMaxAmount(cents, s.maxAmountCents)
+}
+
+// ParseAmountUnit parses s as cents (unit cents, integers only) or as major
+// units (unit major, 10 or 10.00 => 1000 cents) and returns the unit used.
+// An empty unit falls back to ParseAmount, the unit then depending on the
+// format of s.
+func ParseAmountUnit(s, unit, currency string) (int64, string, error) {
+	switch strings.ToLower(unit) {
+	case "":
+		unit = UnitCents
+		if strings.Contains(s, ".") {
+			unit = UnitMajor
+		}
+		cents, err := ParseAmount(s, currency)
+		return cents, unit, err
+	case UnitCents:
+		if strings.Contains(s, ".") {
+			return 0, "", &ValidationError{Code: CodeInvalidAmount, Message: "amount in cents must be an integer"}
+		}
+		cents, err := ParseAmount(s, currency)
+		return cents, UnitCents, err
+	case UnitMajor:
+		if s != "" && !strings.Contains(s, ".") {
+			s += ".0"
+		}
+		cents, err := ParseAmount(s, currency)
+		return cents, UnitMajor, err
+	}
+	return 0, "", &ValidationError{Code: CodeInvalidUnit, Message: "unit must be cents or major"}
+}
+
+// ParseAmount parses integer cents (1000 => 10.00) or decimal units (10.00)
+// into cents. Amounts must be positive, and decimal units may not have more
+// decimal places than currency allows (trailing zeros aside).
+func ParseAmount(s, currency string) (int64, error) {
+	if s == "" {
+		return 0, &ValidationError{Code: CodeMissingAmount, Message: "amount is required"}
+	}
+	var cents int64
+	if whole, frac, ok := strings.Cut(s, "."); ok {
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil || math.IsNaN(f) || math.IsInf(f, 0) || strings.ContainsAny(whole+frac, "eE") {
+			return 0, &ValidationError{Code: CodeInvalidAmount, Message: "invalid amount"}
+		}
+		if n := maxAmountDecimals(currency); len(strings.TrimRight(frac, "0")) > n {
+			return 0, &ValidationError{Code: CodeInvalidAmount,
+				Message: "amount has more than " + strconv.Itoa(n) + " decimal places for " + strings.ToUpper(currency)}
+		}
+		cents = int64(math.Round(f * 100.0))
+	} else {
+		ai, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return 0, &ValidationError{Code: CodeInvalidAmount, Message: "invalid amount"}
+		}
+		cents = ai
+	}
+	if cents <= 0 {
+		return 0, &ValidationError{Code: CodeInvalidAmount, Message: "amount must be positive"}
+	}
+	return cents, nil
+}
+
+// CheckUnit rejects a missing unit when it is required (AMOUNT_HEURISTIC=false).
+func CheckUnit(unit string, required bool) error {
+	if unit == "" && required {
+		return &ValidationError{Code: CodeMissingUnit, Message: "unit is required: cents or major"}
+	}
+	return nil
+}
+
+// CheckMaxAmount rejects cents above MAX_AMOUNT_CENTS (0 disables the bound).
+func CheckMaxAmount(cents, maxCents int64) error {
+	if maxCents > 0 && cents > maxCents {
+		return &ValidationError{Code: CodeAmountTooLarge,
+			Message: "amount exceeds the maximum of " + strconv.FormatInt(maxCents, 10) + " cents"}
+	}
+	return nil
+}
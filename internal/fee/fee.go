@@ -1,17 +1,30 @@
 package fee
 
 import (
+	"cmp"
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/thiagozs/go-exchange/internal/config"
+	"github.com/thiagozs/go-exchange/internal/health"
 	"github.com/thiagozs/go-exchange/internal/logger"
+	"golang.org/x/sync/singleflight"
 )
 
-// Provider returns fee percent as a float (e.g., 0.005 = 0.5%)
+// Provider returns fee percent as a float (e.g., 0.005 = 0.5%) for a
+// conversion from -> to whose gross converted amount, in cents of to, is
+// amountCents.
 type Provider interface {
-	FeePercent(from, to string) (float64, error)
+	FeePercent(ctx context.Context, from, to string, amountCents int64) (float64, error)
 }
 
 // EnvFeeProvider reads a default fee percent from env var EXCHANGE_FEE_PERCENT
@@ -23,51 +36,473 @@ func NewEnvFeeProviderWithPercent(pct float64) *EnvFeeProvider {
 	return &EnvFeeProvider{percent: pct}
 }
 
-func (e *EnvFeeProvider) FeePercent(from, to string) (float64, error) {
+func (e *EnvFeeProvider) FeePercent(ctx context.Context, from, to string, amountCents int64) (float64, error) {
 	return e.percent, nil
 }
 
+// ConfigFeeProvider applies per-pair fees from EXCHANGE_FEES/FEE_CONFIG_PATH.
+// Pairs are looked up case-insensitively, most specific rule first: FROM-TO,
+// FROM-*, *-TO, *-*, then the default (0 when absent).
+type ConfigFeeProvider struct {
+	rules config.FeeRules
+}
+
+func NewConfigFeeProvider(rules config.FeeRules) *ConfigFeeProvider {
+	return &ConfigFeeProvider{rules: rules}
+}
+
+func (c *ConfigFeeProvider) FeePercent(ctx context.Context, from, to string, amountCents int64) (float64, error) {
+	for _, key := range append(pairKeys(from, to), config.FeeDefault) {
+		if pct, ok := c.rules[key]; ok {
+			return pct, nil
+		}
+	}
+	return 0, nil
+}
+
+// pairKeys lists the rule keys matching a pair, most specific first.
+func pairKeys(from, to string) []string {
+	from, to = strings.ToUpper(from), strings.ToUpper(to)
+	return []string{from + "-" + to, from + "-*", "*-" + to, "*-*"}
+}
+
+// Exempt reports whether from -> to matches a FEE_EXEMPT_PAIRS entry.
+func Exempt(pairs config.FeePairSet, from, to string) bool {
+	for _, key := range pairKeys(from, to) {
+		if pairs[key] {
+			return true
+		}
+	}
+	return false
+}
+
+// Tier identifies the band a TieredProvider applied. Pair is the matching
+// per-pair override key, empty for the default schedule.
+type Tier struct {
+	Index     int
+	Name      string
+	FromCents int64
+	Pair      string
+}
+
+// TieredProvider is a Provider that can also report the tier applied.
+type TieredProvider interface {
+	Provider
+	FeeTier(ctx context.Context, from, to string, amountCents int64) (float64, Tier, error)
+}
+
+// TieredFeeProvider charges by gross converted amount: the tier with the
+// highest from_cents not above the amount applies, so thresholds are
+// inclusive lower bounds. Per-pair schedules are matched like
+// ConfigFeeProvider rules and replace the default schedule.
+type TieredFeeProvider struct {
+	tiers config.FeeTiers
+}
+
+// NewTieredFeeProvider returns a provider for tiers, which must have been
+// validated by config.Load.
+func NewTieredFeeProvider(tiers config.FeeTiers) *TieredFeeProvider {
+	return &TieredFeeProvider{tiers: tiers}
+}
+
+func (t *TieredFeeProvider) FeePercent(ctx context.Context, from, to string, amountCents int64) (float64, error) {
+	pct, _, err := t.FeeTier(ctx, from, to, amountCents)
+	return pct, err
+}
+
+func (t *TieredFeeProvider) FeeTier(ctx context.Context, from, to string, amountCents int64) (float64, Tier, error) {
+	schedule, pair := t.tiers.Tiers, ""
+	for _, key := range pairKeys(from, to) {
+		if tiers, ok := t.tiers.Pairs[key]; ok {
+			schedule, pair = tiers, key
+			break
+		}
+	}
+	i := 0
+	for i+1 < len(schedule) && amountCents >= schedule[i+1].FromCents {
+		i++
+	}
+	tier := schedule[i]
+	return tier.Percent, Tier{Index: i, Name: tier.Name, FromCents: tier.FromCents, Pair: pair}, nil
+}
+
+// FeeQuote is the full fee for a conversion: Percent of the gross amount
+// plus FixedCents, bounded by MinCents and MaxCents (0 means no upper bound).
+// Tier is set when a TieredProvider picked the percent.
+type FeeQuote struct {
+	Percent    float64
+	FixedCents int64
+	MinCents   int64
+	MaxCents   int64
+	Tier       *Tier
+}
+
+// FeeAmount breaks a fee down into its parts. Clamped is "min" or "max" when
+// a bound replaced PercentCents+FixedCents as TotalCents; Rounding is the
+// FEE_ROUNDING mode PercentCents was rounded with.
+type FeeAmount struct {
+	PercentCents int64
+	FixedCents   int64
+	TotalCents   int64
+	Clamped      string
+	Rounding     string
+}
+
+// Amount computes the fee on grossCents:
+// clamp(round(percent*gross) + fixed, min, max), rounding with one of the
+// config.FeeRounding modes; empty means half up.
+func (q FeeQuote) Amount(grossCents int64, rounding string) FeeAmount {
+	rounding = cmp.Or(rounding, config.FeeRoundingHalfUp)
+	a := FeeAmount{
+		PercentCents: percentCents(grossCents, q.Percent, rounding),
+		FixedCents:   q.FixedCents,
+		Rounding:     rounding,
+	}
+	a.TotalCents = a.PercentCents + a.FixedCents
+	switch {
+	case a.TotalCents < q.MinCents:
+		a.TotalCents, a.Clamped = q.MinCents, "min"
+	case q.MaxCents > 0 && a.TotalCents > q.MaxCents:
+		a.TotalCents, a.Clamped = q.MaxCents, "max"
+	}
+	return a
+}
+
+// percentCents is percent of grossCents rounded to whole cents. It works on
+// the shortest decimal form of percent, so 0.005 of 150 cents is exactly
+// 0.75 and ties are real ties rather than artifacts of float64.
+func percentCents(grossCents int64, percent float64, rounding string) int64 {
+	p, ok := new(big.Rat).SetString(strconv.FormatFloat(percent, 'f', -1, 64))
+	if !ok {
+		// NaN or infinite: nothing sensible to charge
+		return 0
+	}
+	x := p.Mul(p, new(big.Rat).SetInt64(grossCents))
+	// q is truncated toward zero; r has the sign of x
+	q, r := new(big.Int).QuoRem(x.Num(), x.Denom(), new(big.Int))
+	if r.Sign() == 0 {
+		return q.Int64()
+	}
+	away := false
+	switch rounding {
+	case config.FeeRoundingFloor:
+		away = x.Sign() < 0
+	case config.FeeRoundingCeil:
+		away = x.Sign() > 0
+	default:
+		half := new(big.Int).Abs(r)
+		switch half.Lsh(half, 1).Cmp(x.Denom()) {
+		case 1:
+			away = true
+		case 0:
+			away = rounding != config.FeeRoundingHalfEven || q.Bit(0) == 1
+		}
+	}
+	if away {
+		q.Add(q, big.NewInt(int64(x.Sign())))
+	}
+	return q.Int64()
+}
+
+// Quoter returns the full fee quote for a conversion whose gross converted
+// amount, in cents of to, is amountCents.
+type Quoter interface {
+	Quote(ctx context.Context, from, to string, amountCents int64) (FeeQuote, error)
+}
+
+// AsQuoter returns p itself when it is a Quoter, otherwise an adapter that
+// takes the percent from p (none when p is nil) and the fixed fee and bounds
+// from limits, matched like ConfigFeeProvider rules.
+func AsQuoter(p Provider, limits config.FeeLimits) Quoter {
+	if q, ok := p.(Quoter); ok {
+		return q
+	}
+	return percentQuoter{p: p, limits: limits}
+}
+
+type percentQuoter struct {
+	p      Provider
+	limits config.FeeLimits
+}
+
+func (q percentQuoter) Quote(ctx context.Context, from, to string, amountCents int64) (FeeQuote, error) {
+	var quote FeeQuote
+	for _, key := range append(pairKeys(from, to), config.FeeDefault) {
+		if l, ok := q.limits[key]; ok {
+			quote.FixedCents, quote.MinCents, quote.MaxCents = l.FixedCents, l.MinCents, l.MaxCents
+			break
+		}
+	}
+	switch p := q.p.(type) {
+	case nil:
+	case TieredProvider:
+		pct, tier, err := p.FeeTier(ctx, from, to, amountCents)
+		if err != nil {
+			return FeeQuote{}, err
+		}
+		quote.Percent, quote.Tier = pct, &tier
+	default:
+		pct, err := p.FeePercent(ctx, from, to, amountCents)
+		if err != nil {
+			return FeeQuote{}, err
+		}
+		quote.Percent = pct
+	}
+	return quote, nil
+}
+
+// ErrUnavailable is returned (wrapped) when the fee API could not produce a
+// percent after retries.
+var ErrUnavailable = errors.New("fee api unavailable")
+
+// FeeAPIOptions configures a FeeAPIProvider.
+type FeeAPIOptions struct {
+	// CacheTTL keeps percents per pair and amount band; 0 disables the cache.
+	CacheTTL time.Duration
+	// Timeout bounds each attempt when no client is given (default 5s).
+	Timeout time.Duration
+	// MaxRetries is the number of retries after the first attempt on network
+	// errors and 5xx responses.
+	MaxRetries int
+	// Backoff is the wait before the first retry, doubled for each further
+	// one (default 100ms).
+	Backoff time.Duration
+	// AuthHeader and AuthToken, when both set, are sent on every request.
+	AuthHeader string
+	AuthToken  string
+}
+
 // FeeAPIProvider queries an external API to get fee percent for a pair.
+// With a cache TTL, percents are kept per pair and amount band (see
+// amountBand) and refreshed on expiry; a failed refresh serves the last known percent instead of failing.
 type FeeAPIProvider struct {
 	baseURL string
 	client  *http.Client
+	opts    FeeAPIOptions
 	log     *logger.Logger
+
+	now     func() time.Time
+	mu      sync.Mutex
+	entries map[string]feeEntry
+	fetches singleflight.Group
+}
+
+// maxFeeEntries bounds the fee API cache. Expired entries are pruned when it
+// is full; percents that still do not fit are not cached.
+var maxFeeEntries = 10000
+
+type feeEntry struct {
+	percent float64
+	expires time.Time
 }
 
-func NewFeeAPIProvider(url string, lg *logger.Logger) *FeeAPIProvider {
-	if url == "" {
-		return &FeeAPIProvider{baseURL: "", client: http.DefaultClient, log: lg}
+// NewFeeAPIProvider returns a provider querying url. A nil client uses a
+// plain client with opts.Timeout.
+func NewFeeAPIProvider(url string, client *http.Client, opts FeeAPIOptions, lg *logger.Logger) *FeeAPIProvider {
+	if opts.Timeout <= 0 {
+		opts.Timeout = 5 * time.Second
+	}
+	if opts.Backoff <= 0 {
+		opts.Backoff = 100 * time.Millisecond
 	}
-	return &FeeAPIProvider{baseURL: url, client: &http.Client{Timeout: 5 * time.Second}, log: lg}
+	if client == nil {
+		client = &http.Client{Timeout: opts.Timeout}
+	}
+	return &FeeAPIProvider{baseURL: url, client: client, opts: opts, log: lg,
+		now: time.Now, entries: map[string]feeEntry{}}
 }
 
 type feeAPIResp struct {
 	Percent float64 `json:"percent"`
 }
 
-func (f *FeeAPIProvider) FeePercent(from, to string) (float64, error) {
+// FeePercent asks the fee API for the pair, forwarding the amount in cents
+// as the amount query parameter so the API can apply tiered fees. Cached
+// percents are per pair and amount band, so amounts that only differ by rate
+// moves share an entry while much larger ones get their own tier.
+func (f *FeeAPIProvider) FeePercent(ctx context.Context, from, to string, amountCents int64) (float64, error) {
 	if f.baseURL == "" {
 		return 0, nil
 	}
-	url := f.baseURL + "?from=" + from + "&to=" + to
-	req, _ := http.NewRequestWithContext(context.Background(), "GET", url, nil)
+	if f.opts.CacheTTL <= 0 {
+		return f.fetch(ctx, from, to, amountCents)
+	}
+	key := fmt.Sprintf("%s-%s:1e%d", strings.ToUpper(from), strings.ToUpper(to), amountBand(amountCents))
+	f.mu.Lock()
+	e, ok := f.entries[key]
+	f.mu.Unlock()
+	if ok && f.now().Before(e.expires) {
+		if f.log != nil {
+			f.log.WithContext(ctx).Debugf("fee cache hit for %s", key)
+		}
+		return e.percent, nil
+	}
+	if f.log != nil {
+		f.log.WithContext(ctx).Debugf("fee cache miss for %s", key)
+	}
+	// concurrent misses for a pair and band share one upstream request. It
+	// does not end with the context of the caller that started it, which may
+	// give up while others wait, but is bounded by the fetch timeout instead.
+	fill := f.fetches.DoChan(key, func() (any, error) {
+		fctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), f.fetchTimeout())
+		defer cancel()
+		pct, err := f.fetch(fctx, from, to, amountCents)
+		if err != nil {
+			return 0.0, err
+		}
+		f.store(key, pct)
+		return pct, nil
+	})
+	var v any
+	var err error
+	select {
+	case res := <-fill:
+		v, err = res.Val, res.Err
+	case <-ctx.Done():
+		err = fmt.Errorf("%w: %w", ErrUnavailable, ctx.Err())
+	}
+	if err != nil {
+		if ok {
+			if f.log != nil {
+				f.log.WithContext(ctx).Warnf("fee api refresh for %s failed, using last known percent %v: %v", key, e.percent, err)
+			}
+			return e.percent, nil
+		}
+		return 0, err
+	}
+	return v.(float64), nil
+}
+
+// amountBand is the decimal order of magnitude of amountCents, e.g. 3 from
+// 1000 to 9999 cents. Fee API tiers are expected to start at round amounts,
+// so a band is charged one percent, and a pair has at most 19 bands.
+func amountBand(amountCents int64) int {
+	band := 0
+	for ; amountCents >= 10; amountCents /= 10 {
+		band++
+	}
+	return band
+}
+
+// fetchTimeout bounds a shared fetch: the client timeout (opts.Timeout for
+// the default client) for each attempt, retries included.
+func (f *FeeAPIProvider) fetchTimeout() time.Duration {
+	timeout := f.client.Timeout
+	if timeout <= 0 {
+		timeout = f.opts.Timeout
+	}
+	return timeout * time.Duration(f.opts.MaxRetries+1)
+}
+
+// store caches pct under key, pruning expired entries when the cache is full.
+func (f *FeeAPIProvider) store(key string, pct float64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	now := f.now()
+	if _, ok := f.entries[key]; !ok && len(f.entries) >= maxFeeEntries {
+		for k, e := range f.entries {
+			if !now.Before(e.expires) {
+				delete(f.entries, k)
+			}
+		}
+		if len(f.entries) >= maxFeeEntries {
+			return
+		}
+	}
+	f.entries[key] = feeEntry{percent: pct, expires: now.Add(f.opts.CacheTTL)}
+}
+
+// fetch queries the fee API, retrying network errors and 5xx responses with
+// exponential backoff while the context allows. Failures wrap ErrUnavailable.
+func (f *FeeAPIProvider) fetch(ctx context.Context, from, to string, amountCents int64) (float64, error) {
+	q := url.Values{"from": {from}, "to": {to}, "amount": {strconv.FormatInt(amountCents, 10)}}
+	sep := "?"
+	if strings.Contains(f.baseURL, "?") {
+		sep = "&"
+	}
+	u := f.baseURL + sep + q.Encode()
+	wait := f.opts.Backoff
+	for attempt := 0; ; attempt++ {
+		pct, retry, err := f.attempt(ctx, u)
+		if err == nil {
+			return pct, nil
+		}
+		if !retry || attempt >= f.opts.MaxRetries || ctx.Err() != nil {
+			return 0, fmt.Errorf("%w: %w", ErrUnavailable, err)
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Now().Add(wait).After(deadline) {
+			return 0, fmt.Errorf("%w: %w", ErrUnavailable, err)
+		}
+		if f.log != nil {
+			f.log.WithContext(ctx).Warnf("retrying fee api request attempt=%d/%d in %v: %v", attempt+2, f.opts.MaxRetries+1, wait, err)
+		}
+		select {
+		case <-ctx.Done():
+			return 0, fmt.Errorf("%w: %w", ErrUnavailable, ctx.Err())
+		case <-time.After(wait):
+		}
+		wait *= 2
+	}
+}
+
+// HealthCheck sends one request to the fee API URL, without retries. Any
+// answer below 500 counts as reachable: the URL is not a fee query, so 4xx
+// is expected.
+func (f *FeeAPIProvider) HealthCheck(ctx context.Context) health.Result {
+	if f.baseURL == "" {
+		return health.OK(nil)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, f.baseURL, nil)
+	if err != nil {
+		return health.Fail(health.StatusUnhealthy, err)
+	}
+	if f.opts.AuthHeader != "" && f.opts.AuthToken != "" {
+		req.Header.Set(f.opts.AuthHeader, f.opts.AuthToken)
+	}
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return health.Fail(health.StatusUnhealthy, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 500 {
+		return health.Fail(health.StatusUnhealthy, fmt.Errorf("fee api: unexpected status %d", resp.StatusCode))
+	}
+	return health.OK(map[string]any{"status_code": resp.StatusCode})
+}
+
+// attempt performs one fee API request; retry reports whether a failure is
+// worth retrying.
+func (f *FeeAPIProvider) attempt(ctx context.Context, u string) (pct float64, retry bool, err error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", u, nil)
+	if err != nil {
+		return 0, false, err
+	}
+	if f.opts.AuthHeader != "" && f.opts.AuthToken != "" {
+		req.Header.Set(f.opts.AuthHeader, f.opts.AuthToken)
+	}
 	resp, err := f.client.Do(req)
 	if err != nil {
 		if f.log != nil {
-			f.log.Errorf("fee api request error: %v", err)
+			f.log.WithContext(ctx).Errorf("fee api request error: %v", err)
 		}
-		return 0, err
+		return 0, true, err
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		if f.log != nil {
+			f.log.WithContext(ctx).Errorf("fee api status: %d", resp.StatusCode)
+		}
+		return 0, resp.StatusCode >= 500, fmt.Errorf("fee api: unexpected status %d", resp.StatusCode)
+	}
 	var r feeAPIResp
 	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
 		if f.log != nil {
-			f.log.Errorf("fee api decode error: %v", err)
+			f.log.WithContext(ctx).Errorf("fee api decode error: %v", err)
 		}
-		return 0, err
+		return 0, false, err
 	}
 	if f.log != nil {
-		f.log.Debugf("fee api percent: %v", r.Percent)
+		f.log.WithContext(ctx).Debugf("fee api percent: %v", r.Percent)
 	}
-	return r.Percent, nil
+	return r.Percent, false, nil
 }
@@ -2,23 +2,33 @@ package fee
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/thiagozs/go-exchange/internal/config"
 	"github.com/thiagozs/go-exchange/internal/logger"
 )
 
 func TestEnvFeeProvider(t *testing.T) {
 	p := NewEnvFeeProviderWithPercent(0.005)
-	v, _ := p.FeePercent("USD", "BRL")
+	v, _ := p.FeePercent(context.Background(), "USD", "BRL", 1000)
 	if v != 0.005 {
 		t.Fatalf("expected 0.005 got %v", v)
 	}
 }
 
 func TestFeeAPIProvider(t *testing.T) {
+	var query url.Values
 	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query()
 		w.Header().Set("Content-Type", "application/json")
 		w.Write([]byte(`{"percent":0.01}`))
 	}))
@@ -26,12 +36,465 @@ func TestFeeAPIProvider(t *testing.T) {
 
 	var buf bytes.Buffer
 	lg := logger.New(logger.Options{Format: "text", Level: "debug", Out: &buf})
-	p := NewFeeAPIProvider(srv.URL, lg)
-	v, err := p.FeePercent("USD", "BRL")
+	p := NewFeeAPIProvider(srv.URL, nil, FeeAPIOptions{}, lg)
+	v, err := p.FeePercent(context.Background(), "USD", "BRL", 250000)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
 	if v != 0.01 {
 		t.Fatalf("expected 0.01 got %v", v)
 	}
+	if query.Get("from") != "USD" || query.Get("to") != "BRL" || query.Get("amount") != "250000" {
+		t.Fatalf("unexpected fee api query %v", query)
+	}
+}
+
+func TestFeeAPIProviderHonoursContext(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+
+	p := NewFeeAPIProvider(srv.URL, nil, FeeAPIOptions{}, nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := p.FeePercent(ctx, "USD", "BRL", 1000); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the request context deadline, got %v", err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Fatalf("fee request outlived its context: %s", d)
+	}
+}
+
+func TestFeeAPIProviderCachesPerPairAndAmountBand(t *testing.T) {
+	var calls atomic.Int32
+	var failing atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if failing.Load() {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		// tiered: amounts from 100000 cents pay half
+		if amount, _ := strconv.ParseInt(r.URL.Query().Get("amount"), 10, 64); amount >= 100000 {
+			w.Write([]byte(`{"percent":0.005}`))
+			return
+		}
+		w.Write([]byte(`{"percent":0.01}`))
+	}))
+	defer srv.Close()
+
+	var buf bytes.Buffer
+	lg := logger.New(logger.Options{Format: "text", Level: "debug", Out: &buf})
+	p := NewFeeAPIProvider(srv.URL, nil, FeeAPIOptions{CacheTTL: time.Minute}, lg)
+	now := time.Date(2025, 1, 2, 10, 0, 0, 0, time.UTC)
+	p.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	// gross amounts move with the rate; those in the same band share a percent
+	for i := range 50 {
+		if v, err := p.FeePercent(ctx, "usd", "BRL", 1000+int64(i)*37); err != nil || v != 0.01 {
+			t.Fatalf("conversion %d: %v, %v", i, v, err)
+		}
+	}
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("expected one upstream call within the TTL, got %d", n)
+	}
+	if !strings.Contains(buf.String(), "fee cache hit for USD-BRL:1e3") || !strings.Contains(buf.String(), "fee cache miss for USD-BRL:1e3") {
+		t.Fatalf("expected hit and miss logs, got %q", buf.String())
+	}
+
+	// an amount in another tier is not charged the cached tier
+	if v, err := p.FeePercent(ctx, "USD", "BRL", 250000); err != nil || v != 0.005 {
+		t.Fatalf("expected the upper tier for 250000 cents, got %v, %v", v, err)
+	}
+	if v, err := p.FeePercent(ctx, "USD", "BRL", 1000); err != nil || v != 0.01 {
+		t.Fatalf("expected the lower tier to stay cached for 1000 cents, got %v, %v", v, err)
+	}
+	if n := calls.Load(); n != 2 {
+		t.Fatalf("expected one call per amount band, got %d", n)
+	}
+
+	// a different pair has its own entry
+	_, _ = p.FeePercent(ctx, "EUR", "BRL", 1000)
+	if n := calls.Load(); n != 3 {
+		t.Fatalf("expected a call for the new pair, got %d", n)
+	}
+
+	// after expiry a failed refresh serves the last known percent
+	now = now.Add(time.Minute)
+	failing.Store(true)
+	if v, err := p.FeePercent(ctx, "USD", "BRL", 1000); err != nil || v != 0.01 {
+		t.Fatalf("expected the last known percent, got %v, %v", v, err)
+	}
+	if n := calls.Load(); n != 4 || !strings.Contains(buf.String(), "using last known percent") {
+		t.Fatalf("expected a refresh attempt with a warning, got %d calls", n)
+	}
+
+	// without a previous value the failure is returned
+	if _, err := p.FeePercent(ctx, "GBP", "BRL", 1000); err == nil {
+		t.Fatalf("expected an error without a cached percent")
+	}
+}
+
+func TestFeeAPIProviderCacheIsBounded(t *testing.T) {
+	prev := maxFeeEntries
+	maxFeeEntries = 2
+	t.Cleanup(func() { maxFeeEntries = prev })
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Write([]byte(`{"percent":0.01}`))
+	}))
+	defer srv.Close()
+	p := NewFeeAPIProvider(srv.URL, nil, FeeAPIOptions{CacheTTL: time.Minute}, nil)
+	now := time.Date(2025, 1, 2, 10, 0, 0, 0, time.UTC)
+	p.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	for _, amount := range []int64{1000, 10000, 100000} {
+		_, _ = p.FeePercent(ctx, "USD", "BRL", amount)
+	}
+	if len(p.entries) != 2 {
+		t.Fatalf("expected the cache capped at 2 entries, got %d", len(p.entries))
+	}
+	// expired entries make room
+	now = now.Add(time.Minute)
+	_, _ = p.FeePercent(ctx, "USD", "BRL", 100000)
+	if _, ok := p.entries["USD-BRL:1e5"]; !ok || len(p.entries) != 1 {
+		t.Fatalf("expected expired entries pruned, got %v", p.entries)
+	}
+}
+
+func TestFeeAPIProviderSharedFetchOutlivesLeader(t *testing.T) {
+	var calls atomic.Int32
+	started, release := make(chan struct{}), make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			close(started)
+		}
+		<-release
+		w.Write([]byte(`{"percent":0.01}`))
+	}))
+	defer srv.Close()
+	p := NewFeeAPIProvider(srv.URL, nil, FeeAPIOptions{CacheTTL: time.Minute}, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	leader := make(chan error, 1)
+	go func() {
+		_, err := p.FeePercent(ctx, "USD", "BRL", 1000)
+		leader <- err
+	}()
+	<-started
+	cancel()
+	if err := <-leader; !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the leader to give up with its context, got %v", err)
+	}
+
+	follower := make(chan float64, 1)
+	go func() {
+		v, err := p.FeePercent(context.Background(), "USD", "BRL", 1200)
+		if err != nil {
+			t.Errorf("follower: %v", err)
+		}
+		follower <- v
+	}()
+	close(release)
+	if v := <-follower; v != 0.01 {
+		t.Fatalf("expected the follower to get the fee, got %v", v)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("expected one upstream call, got %d", n)
+	}
+}
+
+func TestConfigFeeProviderPrecedence(t *testing.T) {
+	var rules config.FeeRules
+	if err := rules.UnmarshalText([]byte("USD-BRL=0.012, usd-eur=0.004, USD-*=0.008, *-JPY=0.02, default=0.01")); err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	p := NewConfigFeeProvider(rules)
+	cases := []struct {
+		from, to string
+		want     float64
+	}{
+		{"USD", "BRL", 0.012}, // exact
+		{"usd", "EUR", 0.004}, // case-insensitive
+		{"USD", "GBP", 0.008}, // FROM-* wildcard
+		{"USD", "JPY", 0.008}, // FROM-* beats *-TO
+		{"EUR", "JPY", 0.02},  // *-TO wildcard
+		{"EUR", "BRL", 0.01},  // default
+	}
+	for _, tc := range cases {
+		if got, _ := p.FeePercent(context.Background(), tc.from, tc.to, 1000); got != tc.want {
+			t.Errorf("%s-%s: expected %v got %v", tc.from, tc.to, tc.want, got)
+		}
+	}
+
+	// without a default unmatched pairs pay nothing
+	if got, _ := NewConfigFeeProvider(config.FeeRules{"USD-BRL": 0.012}).FeePercent(context.Background(), "EUR", "BRL", 1000); got != 0 {
+		t.Fatalf("expected 0 without a default, got %v", got)
+	}
+}
+
+func TestTieredFeeProviderBoundaries(t *testing.T) {
+	var tiers config.FeeTiers
+	err := tiers.UnmarshalText([]byte(`{
+		"tiers": [
+			{"name": "small", "from_cents": 0, "percent": 0.01},
+			{"name": "medium", "from_cents": 100000, "percent": 0.006},
+			{"name": "large", "from_cents": 5000000, "percent": 0.003}
+		],
+		"pairs": {"usd-*": [{"from_cents": 0, "percent": 0.002}]}
+	}`))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	p := NewTieredFeeProvider(tiers)
+	ctx := context.Background()
+	cases := []struct {
+		amount int64
+		want   float64
+		tier   string
+	}{
+		{0, 0.01, "small"},
+		{99999, 0.01, "small"},
+		{100000, 0.006, "medium"}, // thresholds are inclusive lower bounds
+		{4999999, 0.006, "medium"},
+		{5000000, 0.003, "large"},
+		{900000000, 0.003, "large"},
+	}
+	for _, tc := range cases {
+		pct, tier, err := p.FeeTier(ctx, "EUR", "BRL", tc.amount)
+		if err != nil || pct != tc.want || tier.Name != tc.tier || tier.Pair != "" {
+			t.Errorf("amount %d: expected %v (%s), got %v %+v %v", tc.amount, tc.want, tc.tier, pct, tier, err)
+		}
+	}
+
+	pct, tier, _ := p.FeeTier(ctx, "usd", "BRL", 5000000)
+	if pct != 0.002 || tier.Pair != "USD-*" || tier.Index != 0 {
+		t.Fatalf("expected the USD-* override, got %v %+v", pct, tier)
+	}
+}
+
+func TestFeeQuoteAmountClamps(t *testing.T) {
+	// max(0.9%, R$2.00) capped at R$150.00
+	q := FeeQuote{Percent: 0.009, MinCents: 200, MaxCents: 15000}
+	cases := []struct {
+		gross   int64
+		total   int64
+		clamped string
+	}{
+		{0, 200, "min"},
+		{10000, 200, "min"},     // 90 cents is below the minimum
+		{22000, 200, "min"},     // 198 cents, just below
+		{22222, 200, ""},        // rounds to exactly the minimum
+		{100000, 900, ""},       // percent applies
+		{1666667, 15000, ""},    // rounds to exactly the maximum
+		{1667000, 15000, "max"}, // 15003 cents, just above
+		{5000000, 15000, "max"},
+	}
+	for _, tc := range cases {
+		a := q.Amount(tc.gross, "")
+		if a.TotalCents != tc.total || a.Clamped != tc.clamped {
+			t.Errorf("gross %d: expected %d (%q), got %+v", tc.gross, tc.total, tc.clamped, a)
+		}
+	}
+
+	fixed := FeeQuote{Percent: 0.01, FixedCents: 50, MaxCents: 1000}.Amount(20000, "")
+	if fixed.PercentCents != 200 || fixed.FixedCents != 50 || fixed.TotalCents != 250 || fixed.Clamped != "" {
+		t.Fatalf("expected percent and fixed parts to add up, got %+v", fixed)
+	}
+}
+
+func TestFeeQuoteAmountRounding(t *testing.T) {
+	cases := []struct {
+		percent float64
+		gross   int64
+		// expected percent cents for half_up, half_even, floor and ceil
+		want [4]int64
+	}{
+		{0.005, 150, [4]int64{1, 1, 0, 1}},           // 0.75
+		{0.005, 100, [4]int64{1, 0, 0, 1}},           // 0.5, tie to even 0
+		{0.005, 300, [4]int64{2, 2, 1, 2}},           // 1.5, tie to even 2
+		{0.005, 500, [4]int64{3, 2, 2, 3}},           // 2.5
+		{0.005, 200, [4]int64{1, 1, 1, 1}},           // exact
+		{0.009, 1500, [4]int64{14, 14, 13, 14}},      // 13.5, 13.499999999999998 in float64
+		{0.011, 11500, [4]int64{127, 126, 126, 127}}, // 126.5, 126.49999999999999 in float64
+		{0.0049, 100, [4]int64{0, 0, 0, 1}},          // 0.49
+		{0.0051, 100, [4]int64{1, 1, 0, 1}},          // 0.51
+		{0, 12345, [4]int64{0, 0, 0, 0}},
+	}
+	modes := []string{config.FeeRoundingHalfUp, config.FeeRoundingHalfEven, config.FeeRoundingFloor, config.FeeRoundingCeil}
+	for _, tc := range cases {
+		for i, mode := range modes {
+			a := FeeQuote{Percent: tc.percent, FixedCents: 10}.Amount(tc.gross, mode)
+			if a.PercentCents != tc.want[i] || a.TotalCents != tc.want[i]+10 || a.Rounding != mode {
+				t.Errorf("%v of %d with %s: expected %d cents, got %+v", tc.percent, tc.gross, mode, tc.want[i], a)
+			}
+		}
+	}
+	if a := (FeeQuote{Percent: 0.005}).Amount(100, ""); a.PercentCents != 1 || a.Rounding != config.FeeRoundingHalfUp {
+		t.Fatalf("expected half up by default, got %+v", a)
+	}
+}
+
+func TestAsQuoterAppliesLimits(t *testing.T) {
+	limits := config.FeeLimits{
+		"USD-BRL":         {MinCents: 200, MaxCents: 15000},
+		config.FeeDefault: {FixedCents: 30},
+	}
+	q := AsQuoter(NewEnvFeeProviderWithPercent(0.009), limits)
+	got, err := q.Quote(context.Background(), "usd", "brl", 10000)
+	if err != nil || got.Percent != 0.009 || got.MinCents != 200 || got.MaxCents != 15000 || got.FixedCents != 0 {
+		t.Fatalf("expected the USD-BRL limits, got %+v, %v", got, err)
+	}
+	got, _ = q.Quote(context.Background(), "EUR", "BRL", 10000)
+	if got.FixedCents != 30 || got.MinCents != 0 || got.MaxCents != 0 {
+		t.Fatalf("expected the default limits, got %+v", got)
+	}
+
+	// limits alone charge a fixed fee without a percent provider
+	got, _ = AsQuoter(nil, limits).Quote(context.Background(), "EUR", "BRL", 10000)
+	if got.Percent != 0 || got.Amount(10000, "").TotalCents != 30 {
+		t.Fatalf("expected a fixed fee only, got %+v", got)
+	}
+
+	var tiers config.FeeTiers
+	_ = tiers.UnmarshalText([]byte(`{"tiers":[{"from_cents":0,"percent":0.01},{"from_cents":100000,"percent":0.006}]}`))
+	got, _ = AsQuoter(NewTieredFeeProvider(tiers), limits).Quote(context.Background(), "USD", "BRL", 100000)
+	if got.Percent != 0.006 || got.Tier == nil || got.Tier.Index != 1 || got.MinCents != 200 {
+		t.Fatalf("expected the tier and limits together, got %+v", got)
+	}
+}
+
+func TestTenantFeeProvider(t *testing.T) {
+	var plans config.FeePlans
+	if err := plans.UnmarshalText([]byte(`{"gold":{"fees":{"USD-BRL":0.004,"default":0.006}},` +
+		`"volume":{"tiers":{"tiers":[{"from_cents":0,"percent":0.008},{"from_cents":100000,"percent":0.002}]}}}`)); err != nil {
+		t.Fatalf("parse plans: %v", err)
+	}
+	p := NewTenantFeeProvider(NewEnvFeeProviderWithPercent(0.01), plans, config.FeeLimits{config.FeeDefault: {FixedCents: 30}})
+	ctx := func(plan string) context.Context {
+		return WithTenant(context.Background(), Tenant{Name: "partner", Plan: plan})
+	}
+
+	cases := []struct {
+		ctx  context.Context
+		to   string
+		want float64
+	}{
+		{context.Background(), "BRL", 0.01},
+		{ctx(""), "BRL", 0.01},
+		{ctx("unknown"), "BRL", 0.01},
+		{ctx("gold"), "BRL", 0.004},
+		{ctx("gold"), "EUR", 0.006},
+		{ctx("volume"), "BRL", 0.002},
+	}
+	for _, tc := range cases {
+		q, err := AsQuoter(p, nil).Quote(tc.ctx, "USD", tc.to, 150000)
+		if err != nil || q.Percent != tc.want || q.FixedCents != 30 {
+			t.Fatalf("USD-%s: expected %v plus the fixed fee, got %+v, %v", tc.to, tc.want, q, err)
+		}
+	}
+	if q, _ := p.Quote(ctx("volume"), "USD", "BRL", 150000); q.Tier == nil || q.Tier.Index != 1 {
+		t.Fatalf("expected the tier of the volume plan, got %+v", q)
+	}
+
+	// without a default provider, requests off the plans pay no percent
+	pct, err := NewTenantFeeProvider(nil, plans, nil).FeePercent(context.Background(), "USD", "BRL", 1000)
+	if err != nil || pct != 0 {
+		t.Fatalf("expected no fee, got %v, %v", pct, err)
+	}
+}
+
+func TestFeeAPIProviderRetries(t *testing.T) {
+	var calls atomic.Int32
+	var status atomic.Int32
+	var auth atomic.Value
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		auth.Store(r.Header.Get("X-Fee-Key"))
+		if code := int(status.Load()); code != http.StatusOK {
+			http.Error(w, "failing", code)
+			return
+		}
+		w.Write([]byte(`{"percent":0.01}`))
+	}))
+	defer srv.Close()
+
+	p := NewFeeAPIProvider(srv.URL, nil, FeeAPIOptions{
+		MaxRetries: 2, Backoff: time.Millisecond, AuthHeader: "X-Fee-Key", AuthToken: "secret",
+	}, nil)
+	ctx := context.Background()
+
+	status.Store(http.StatusBadGateway)
+	if _, err := p.FeePercent(ctx, "USD", "BRL", 1000); !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("expected ErrUnavailable, got %v", err)
+	}
+	if n := calls.Swap(0); n != 3 {
+		t.Fatalf("expected the first attempt plus 2 retries on 5xx, got %d calls", n)
+	}
+	if auth.Load() != "secret" {
+		t.Fatalf("expected the auth header on every attempt, got %q", auth.Load())
+	}
+
+	status.Store(http.StatusBadRequest)
+	if _, err := p.FeePercent(ctx, "USD", "BRL", 1000); !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("expected ErrUnavailable, got %v", err)
+	}
+	if n := calls.Swap(0); n != 1 {
+		t.Fatalf("expected 4xx not to be retried, got %d calls", n)
+	}
+
+	status.Store(http.StatusOK)
+	if v, err := p.FeePercent(ctx, "USD", "BRL", 1000); err != nil || v != 0.01 {
+		t.Fatalf("unexpected result %v, %v", v, err)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("expected a single call on success, got %d", n)
+	}
+}
+
+func TestFeeAPIProviderRetriesNetworkErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	u := srv.URL
+	srv.Close()
+
+	var buf bytes.Buffer
+	lg := logger.New(logger.Options{Format: "text", Level: "info", Out: &buf})
+	p := NewFeeAPIProvider(u, nil, FeeAPIOptions{MaxRetries: 1, Backoff: time.Millisecond}, lg)
+	if _, err := p.FeePercent(context.Background(), "USD", "BRL", 1000); !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("expected ErrUnavailable, got %v", err)
+	}
+	if !strings.Contains(buf.String(), "retrying fee api request attempt=2/2") {
+		t.Fatalf("expected a retry log, got %q", buf.String())
+	}
+}
+
+func TestExempt(t *testing.T) {
+	var pairs config.FeePairSet
+	if err := pairs.UnmarshalText([]byte("USD-BRL,eur<>gbp,*-JPY")); err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	cases := []struct {
+		from, to string
+		want     bool
+	}{
+		{"usd", "brl", true},
+		{"BRL", "USD", false}, // USD-BRL only covers one direction
+		{"EUR", "GBP", true},
+		{"gbp", "eur", true},
+		{"CHF", "JPY", true},
+		{"JPY", "CHF", false},
+	}
+	for _, tc := range cases {
+		if got := Exempt(pairs, tc.from, tc.to); got != tc.want {
+			t.Errorf("%s->%s: expected %v, got %v", tc.from, tc.to, tc.want, got)
+		}
+	}
+	if Exempt(nil, "USD", "BRL") {
+		t.Fatalf("expected no exemptions without configuration")
+	}
 }
@@ -0,0 +1,80 @@
+package fee
+
+import (
+	"context"
+
+	"github.com/thiagozs/go-exchange/internal/config"
+)
+
+// Tenant is the client a conversion is priced for: the API key name and
+// the fee plan it is on, empty for the default plan.
+type Tenant struct {
+	Name string
+	Plan string
+}
+
+type tenantCtxKey struct{}
+
+// WithTenant returns a copy of ctx carrying t, for TenantFeeProvider and the
+// conversion metrics.
+func WithTenant(ctx context.Context, t Tenant) context.Context {
+	return context.WithValue(ctx, tenantCtxKey{}, t)
+}
+
+// TenantFromContext returns the tenant set with WithTenant, if any.
+func TenantFromContext(ctx context.Context) (Tenant, bool) {
+	t, ok := ctx.Value(tenantCtxKey{}).(Tenant)
+	return t, ok
+}
+
+// TenantFeeProvider prices each conversion by the fee plan of the tenant in
+// its context: the per-pair or tiered schedule of the FEE_PLANS entry, or
+// the default provider for requests without a plan or on one not
+// configured. Fixed fees and bounds (limits) apply to every plan.
+type TenantFeeProvider struct {
+	def    Provider
+	limits config.FeeLimits
+	plans  map[string]Provider
+}
+
+// NewTenantFeeProvider returns a provider charging plans, validated by
+// config.Load, and falling back to def, which may be nil for no fee.
+func NewTenantFeeProvider(def Provider, plans config.FeePlans, limits config.FeeLimits) *TenantFeeProvider {
+	t := &TenantFeeProvider{def: def, limits: limits, plans: make(map[string]Provider, len(plans))}
+	for name, plan := range plans {
+		if plan.Tiers.Enabled() {
+			t.plans[name] = NewTieredFeeProvider(plan.Tiers)
+		} else {
+			t.plans[name] = NewConfigFeeProvider(plan.Fees)
+		}
+	}
+	return t
+}
+
+// Default returns the provider of the default plan.
+func (t *TenantFeeProvider) Default() Provider {
+	return t.def
+}
+
+// plan returns the provider for the tenant in ctx.
+func (t *TenantFeeProvider) plan(ctx context.Context) Provider {
+	if tenant, ok := TenantFromContext(ctx); ok {
+		if p, ok := t.plans[tenant.Plan]; ok {
+			return p
+		}
+	}
+	return t.def
+}
+
+func (t *TenantFeeProvider) FeePercent(ctx context.Context, from, to string, amountCents int64) (float64, error) {
+	p := t.plan(ctx)
+	if p == nil {
+		return 0, nil
+	}
+	return p.FeePercent(ctx, from, to, amountCents)
+}
+
+// Quote implements Quoter, so the tier of tiered plans is reported.
+func (t *TenantFeeProvider) Quote(ctx context.Context, from, to string, amountCents int64) (FeeQuote, error) {
+	return AsQuoter(t.plan(ctx), t.limits).Quote(ctx, from, to, amountCents)
+}
@@ -0,0 +1,4 @@
+// Package exchangepb holds the generated code of the gRPC API.
+package exchangepb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative exchange.proto
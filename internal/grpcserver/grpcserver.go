@@ -0,0 +1,201 @@
+// Package grpcserver serves the gRPC API (goexchange.v1.ExchangeService) on
+// top of the conversions of the HTTP server.
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/thiagozs/go-exchange/internal/config"
+	"github.com/thiagozs/go-exchange/internal/exchange"
+	"github.com/thiagozs/go-exchange/internal/grpcserver/exchangepb"
+	"github.com/thiagozs/go-exchange/internal/logger"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+)
+
+// errorDomain is the ErrorInfo domain of failed calls; the reason is the
+// error code /convert would answer with.
+const errorDomain = "go-exchange"
+
+// Service performs the conversions; *exchange.Service implements it, so the
+// gRPC API shares the provider, cache and fee wiring of /convert.
+type Service interface {
+	Convert(ctx context.Context, req exchange.ConvertRequest) (exchange.ConvertResponse, error)
+	Rate(ctx context.Context, req exchange.RateRequest) (exchange.RateResponse, error)
+}
+
+// Server is the gRPC server. GRPC_REFLECTION enables server reflection.
+type Server struct {
+	grpc *grpc.Server
+	log  *logger.Logger
+}
+
+// New builds the server for svc. Calls are traced and measured with the
+// global OTel providers.
+func New(cfg *config.Config, svc Service, lg *logger.Logger) *Server {
+	gs := grpc.NewServer(grpc.ChainUnaryInterceptor(newTelemetry(lg).unary))
+	exchangepb.RegisterExchangeServiceServer(gs, &exchangeService{svc: svc, log: lg})
+	if cfg.GRPCReflection {
+		reflection.Register(gs)
+	}
+	return &Server{grpc: gs, log: lg}
+}
+
+// Serve accepts connections on lis until Shutdown.
+func (s *Server) Serve(lis net.Listener) error {
+	s.log.WithContext(context.Background()).Infof("gRPC listening on %s", lis.Addr())
+	return s.grpc.Serve(lis)
+}
+
+// Shutdown stops accepting calls and waits for the pending ones, cancelling
+// them when ctx expires.
+func (s *Server) Shutdown(ctx context.Context) {
+	done := make(chan struct{})
+	go func() {
+		s.grpc.GracefulStop()
+		close(done)
+	}()
+	select {
+	case <-done:
+		s.log.WithContext(ctx).Infof("gRPC server gracefully stopped")
+	case <-ctx.Done():
+		s.log.WithContext(ctx).Warnf("gRPC graceful stop timed out, cancelling pending calls")
+		s.grpc.Stop()
+		<-done
+	}
+}
+
+// exchangeService implements exchangepb.ExchangeServiceServer.
+type exchangeService struct {
+	exchangepb.UnimplementedExchangeServiceServer
+	svc Service
+	log *logger.Logger
+}
+
+// amountUnitKey is the metadata key carrying the unit of ConvertRequest
+// amounts (cents or major), the unit parameter of /convert.
+const amountUnitKey = "amount-unit"
+
+// Header metadata keys of results read from the conversion cache, the
+// rate_age_seconds and cache_expires_in_seconds fields of /convert.
+const (
+	rateAgeKey        = "rate-age-seconds"
+	cacheExpiresInKey = "cache-expires-in-seconds"
+)
+
+// sendFreshness sends how fresh a cached result is as header metadata.
+func sendFreshness(ctx context.Context, f exchange.CacheFreshness) {
+	if !f.FromCache {
+		return
+	}
+	md := metadata.MD{}
+	if !f.FetchedAt.IsZero() {
+		md.Set(rateAgeKey, strconv.FormatInt(int64(f.RateAge/time.Second), 10))
+	}
+	if f.CacheExpiresIn > 0 {
+		md.Set(cacheExpiresInKey, strconv.FormatInt(int64(f.CacheExpiresIn/time.Second), 10))
+	}
+	if len(md) > 0 {
+		_ = grpc.SetHeader(ctx, md)
+	}
+}
+
+func (e *exchangeService) Convert(ctx context.Context, req *exchangepb.ConvertRequest) (*exchangepb.ConvertResponse, error) {
+	var unit string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if v := md.Get(amountUnitKey); len(v) > 0 {
+			unit = v[0]
+		}
+	}
+	c, err := e.svc.Convert(ctx, exchange.ConvertRequest{From: req.GetFrom(), To: req.GetTo(), Amount: req.GetAmount(), Unit: unit})
+	if err != nil {
+		return nil, e.fail(ctx, "convert", req.GetFrom(), req.GetTo(), err)
+	}
+	sendFreshness(ctx, c.CacheFreshness)
+	return &exchangepb.ConvertResponse{
+		From:            c.From,
+		To:              c.To,
+		AmountCents:     c.AmountCents,
+		ResultCents:     c.Result.ResultCents,
+		FeePercent:      c.Fee.Percent,
+		FeeAmountCents:  c.FeeAmount.TotalCents,
+		NetResultCents:  c.NetResultCents(),
+		Rate:            c.Result.Rate,
+		RateTimestamp:   formatTime(c.Result.RateTimestamp),
+		Source:          c.Result.Source,
+		CacheHit:        c.CacheHit,
+		Stale:           c.Result.Stale,
+		FeeWaivedReason: c.FeeWaived,
+		FeeUnavailable:  c.FeeUnavailable,
+	}, nil
+}
+
+func (e *exchangeService) GetRate(ctx context.Context, req *exchangepb.GetRateRequest) (*exchangepb.GetRateResponse, error) {
+	q, err := e.svc.Rate(ctx, exchange.RateRequest{From: req.GetFrom(), To: req.GetTo()})
+	if err != nil {
+		return nil, e.fail(ctx, "rate", req.GetFrom(), req.GetTo(), err)
+	}
+	sendFreshness(ctx, q.CacheFreshness)
+	return &exchangepb.GetRateResponse{
+		From:          q.From,
+		To:            q.To,
+		Rate:          q.Rate,
+		RateTimestamp: formatTime(q.RateTimestamp),
+		Source:        q.Source,
+		CacheHit:      q.CacheHit,
+		Stale:         q.Stale,
+	}, nil
+}
+
+// fail logs a failed call as /convert does and returns its status error.
+func (e *exchangeService) fail(ctx context.Context, op, from, to string, err error) error {
+	st := statusError(err)
+	fields := map[string]any{"from": from, "to": to, "grpc.code": st.Code().String()}
+	if st.Code() == codes.InvalidArgument {
+		e.log.WithContext(ctx).Warnf("grpc %s %s->%s rejected: %v", op, from, to, err)
+	} else {
+		e.log.ErrorCtx(ctx, err, "grpc "+op+" failed", fields)
+	}
+	return st.Err()
+}
+
+// statusError maps an *exchange.Error to a gRPC status: rejected input and
+// unsupported pairs are InvalidArgument, provider or fee API outages
+// Unavailable, timeouts DeadlineExceeded and anything else Internal. The /convert error code is
+// attached as an ErrorInfo reason.
+func statusError(err error) *status.Status {
+	var cerr *exchange.Error
+	if !errors.As(err, &cerr) {
+		return status.New(codes.Internal, "conversion failed")
+	}
+	code := codes.Internal
+	switch cerr.Kind {
+	case exchange.KindInvalid, exchange.KindUnsupported:
+		code = codes.InvalidArgument
+	case exchange.KindUnavailable:
+		code = codes.Unavailable
+	case exchange.KindTimeout:
+		code = codes.DeadlineExceeded
+	}
+	st := status.New(code, cerr.Message)
+	if withInfo, err := st.WithDetails(&errdetails.ErrorInfo{Reason: cerr.Code, Domain: errorDomain}); err == nil {
+		return withInfo
+	}
+	return st
+}
+
+// formatTime renders t as RFC 3339 in UTC, or "" for the zero time.
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,222 @@
+package grpcserver
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/thiagozs/go-exchange/internal/config"
+	"github.com/thiagozs/go-exchange/internal/exchange"
+	"github.com/thiagozs/go-exchange/internal/grpcserver/exchangepb"
+	"github.com/thiagozs/go-exchange/internal/logger"
+	"github.com/thiagozs/go-exchange/internal/server"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// startServer serves the gRPC API for a server converting with the BCB
+// provider against a PTAX stub, over an in-memory listener.
+func startServer(t *testing.T, cfg *config.Config) (*Server, exchangepb.ExchangeServiceClient) {
+	t.Helper()
+	ptax := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"value":[{"cotacaoCompra":5.3,"cotacaoVenda":5.4,"dataHoraCotacao":"2025-09-19 13:09:27.04"}]}`))
+	}))
+	t.Cleanup(ptax.Close)
+
+	cfg.HTTPAddr = ":0"
+	cfg.CacheBackend = config.CacheBackendMemory
+	cfg.CacheTTL = time.Minute
+	cfg.Provider = "ptax"
+	cfg.BCBAPIBaseURL = ptax.URL
+	cfg.BCBTimeout = time.Second
+	// the stub is plain http on loopback
+	cfg.OutboundAllowHTTP, cfg.OutboundAllowPrivate = true, true
+	lg := logger.New(logger.Options{Format: "text", Level: "info", Out: &bytes.Buffer{}})
+	svc, err := server.New(cfg, lg)
+	if err != nil {
+		t.Fatalf("new server: %v", err)
+	}
+
+	s := New(cfg, svc.Exchange(), lg)
+	lis := bufconn.Listen(1 << 20)
+	served := make(chan error, 1)
+	go func() { served <- s.Serve(lis) }()
+	t.Cleanup(func() {
+		s.Shutdown(context.Background())
+		if err := <-served; err != nil {
+			t.Errorf("serve: %v", err)
+		}
+	})
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return s, exchangepb.NewExchangeServiceClient(conn)
+}
+
+func TestConvertAndGetRate(t *testing.T) {
+	_, client := startServer(t, &config.Config{FeePercent: 0.01})
+	ctx := context.Background()
+
+	// the second call is served from the conversion cache
+	for _, wantHit := range []bool{false, true} {
+		var header metadata.MD
+		resp, err := client.Convert(ctx, &exchangepb.ConvertRequest{From: "USD", To: "BRL", Amount: "10.00"}, grpc.Header(&header))
+		if err != nil {
+			t.Fatalf("convert: %v", err)
+		}
+		if got := header.Get(rateAgeKey); (len(got) == 1) != wantHit || (wantHit && header.Get(cacheExpiresInKey)[0] != "59" && header.Get(cacheExpiresInKey)[0] != "60") {
+			t.Fatalf("expected the cache freshness only on the hit, got %v", header)
+		}
+		if resp.GetAmountCents() != 1000 || resp.GetResultCents() != 5400 || resp.GetFeeAmountCents() != 54 ||
+			resp.GetNetResultCents() != 5346 || resp.GetSource() != "bcb" ||
+			resp.GetRateTimestamp() != "2025-09-19T16:09:27Z" || resp.GetCacheHit() != wantHit {
+			t.Fatalf("unexpected conversion %v", resp)
+		}
+	}
+
+	rate, err := client.GetRate(ctx, &exchangepb.GetRateRequest{From: "USD", To: "BRL"})
+	if err != nil {
+		t.Fatalf("get rate: %v", err)
+	}
+	if rate.GetRate() != 5.4 || rate.GetSource() != "bcb" || rate.GetRateTimestamp() != "2025-09-19T16:09:27Z" {
+		t.Fatalf("unexpected rate %v", rate)
+	}
+}
+
+func TestInvalidArgumentCarriesErrorCode(t *testing.T) {
+	_, client := startServer(t, &config.Config{})
+	ctx := context.Background()
+
+	for name, call := range map[string]func() error{
+		"Convert": func() error {
+			_, err := client.Convert(ctx, &exchangepb.ConvertRequest{From: "USD", To: "BRL", Amount: "-1"})
+			return err
+		},
+		"GetRate": func() error {
+			_, err := client.GetRate(ctx, &exchangepb.GetRateRequest{From: "USD", To: "R$"})
+			return err
+		},
+	} {
+		st := status.Convert(call())
+		if st.Code() != codes.InvalidArgument {
+			t.Fatalf("%s: expected InvalidArgument, got %v", name, st)
+		}
+		var reason string
+		for _, d := range st.Details() {
+			if info, ok := d.(*errdetails.ErrorInfo); ok && info.GetDomain() == errorDomain {
+				reason = info.GetReason()
+			}
+		}
+		if reason == "" {
+			t.Fatalf("%s: expected an ErrorInfo reason, got %v", name, st.Details())
+		}
+	}
+}
+
+func TestConvertAmountUnitMetadata(t *testing.T) {
+	_, client := startServer(t, &config.Config{AmountUnitRequired: true})
+
+	_, err := client.Convert(context.Background(), &exchangepb.ConvertRequest{From: "USD", To: "BRL", Amount: "1000"})
+	if st := status.Convert(err); st.Code() != codes.InvalidArgument {
+		t.Fatalf("expected InvalidArgument without a unit, got %v", st)
+	}
+	ctx := metadata.AppendToOutgoingContext(context.Background(), amountUnitKey, "major")
+	resp, err := client.Convert(ctx, &exchangepb.ConvertRequest{From: "USD", To: "BRL", Amount: "10"})
+	if err != nil || resp.GetAmountCents() != 1000 {
+		t.Fatalf("expected 10 major units read as 1000 cents, got %v (%v)", resp, err)
+	}
+}
+
+func TestStatusError(t *testing.T) {
+	for _, tc := range []struct {
+		err  error
+		want codes.Code
+	}{
+		{&exchange.Error{Kind: exchange.KindUnsupported, Code: exchange.CodeCurrencyNotSupported}, codes.InvalidArgument},
+		{&exchange.Error{Kind: exchange.KindUnavailable, Code: exchange.CodeFeeUnavailable}, codes.Unavailable},
+		{&exchange.Error{Kind: exchange.KindInternal, Code: exchange.CodeProviderError}, codes.Internal},
+		{&exchange.Error{Kind: exchange.KindTimeout, Code: exchange.CodeTimeout}, codes.DeadlineExceeded},
+		{context.Canceled, codes.Internal},
+	} {
+		if got := statusError(tc.err).Code(); got != tc.want {
+			t.Fatalf("%v: got %v, want %v", tc.err, got, tc.want)
+		}
+	}
+}
+
+func TestCallsAreTraced(t *testing.T) {
+	rec := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec))
+	prevTP, prevProp := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prevTP)
+		otel.SetTextMapPropagator(prevProp)
+	})
+
+	_, client := startServer(t, &config.Config{})
+	parent := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{1},
+		SpanID:     trace.SpanID{2},
+		TraceFlags: trace.FlagsSampled,
+	})
+	md := metadata.MD{}
+	otel.GetTextMapPropagator().Inject(trace.ContextWithSpanContext(context.Background(), parent), metadataCarrier(md))
+	ctx := metadata.NewOutgoingContext(context.Background(), md)
+	if _, err := client.GetRate(ctx, &exchangepb.GetRateRequest{From: "USD", To: "BRL"}); err != nil {
+		t.Fatalf("get rate: %v", err)
+	}
+
+	var span sdktrace.ReadOnlySpan
+	for _, s := range rec.Ended() {
+		if s.Name() == "goexchange.v1.ExchangeService/GetRate" {
+			span = s
+		}
+	}
+	if span == nil {
+		t.Fatalf("no GetRate span among %d", len(rec.Ended()))
+	}
+	if span.SpanKind() != trace.SpanKindServer || span.Parent().SpanID() != parent.SpanID() ||
+		span.SpanContext().TraceID() != parent.TraceID() {
+		t.Fatalf("span does not continue the caller's trace: kind=%v parent=%v", span.SpanKind(), span.Parent())
+	}
+	attrs := map[string]string{}
+	for _, kv := range span.Attributes() {
+		attrs[string(kv.Key)] = kv.Value.Emit()
+	}
+	if attrs["rpc.system"] != "grpc" || attrs["rpc.service"] != "goexchange.v1.ExchangeService" ||
+		attrs["rpc.method"] != "GetRate" || attrs["rpc.grpc.status_code"] != "0" {
+		t.Fatalf("unexpected span attributes %v", attrs)
+	}
+}
+
+func TestReflectionIsOptIn(t *testing.T) {
+	const reflectionService = "grpc.reflection.v1.ServerReflection"
+	for _, enabled := range []bool{false, true} {
+		s, _ := startServer(t, &config.Config{GRPCReflection: enabled})
+		if _, ok := s.grpc.GetServiceInfo()[reflectionService]; ok != enabled {
+			t.Fatalf("GRPC_REFLECTION=%t: reflection registered=%t", enabled, ok)
+		}
+	}
+}
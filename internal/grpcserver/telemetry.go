@@ -0,0 +1,121 @@
+package grpcserver
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/thiagozs/go-exchange/internal/logger"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const instrumentationName = "github.com/thiagozs/go-exchange/internal/grpcserver"
+
+// telemetry traces and measures unary calls the way otelgrpc does: one
+// server span per call, named after the full method and continuing the
+// caller's trace from the request metadata, and an rpc.server.call.duration
+// histogram. Instruments are created on first use from the global providers,
+// so SetupOTel can install them after the server is built.
+type telemetry struct {
+	log *logger.Logger
+
+	once     sync.Once
+	duration metric.Float64Histogram
+}
+
+func newTelemetry(lg *logger.Logger) *telemetry {
+	return &telemetry{log: lg}
+}
+
+func (t *telemetry) unary(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	start := time.Now()
+	service, method := splitMethod(info.FullMethod)
+	attrs := []attribute.KeyValue{
+		attribute.String("rpc.system", "grpc"),
+		attribute.String("rpc.service", service),
+		attribute.String("rpc.method", method),
+	}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		ctx = otel.GetTextMapPropagator().Extract(ctx, metadataCarrier(md))
+	}
+	ctx, span := otel.Tracer(instrumentationName).Start(ctx, strings.TrimPrefix(info.FullMethod, "/"),
+		trace.WithSpanKind(trace.SpanKindServer), trace.WithAttributes(attrs...))
+	defer span.End()
+
+	resp, err := handler(ctx, req)
+
+	code := status.Code(err)
+	attrs = append(attrs, attribute.Int("rpc.grpc.status_code", int(code)))
+	span.SetAttributes(attrs[len(attrs)-1])
+	if serverFault(code) {
+		span.SetStatus(otelcodes.Error, status.Convert(err).Message())
+	}
+	t.init()
+	if t.duration != nil {
+		t.duration.Record(ctx, time.Since(start).Seconds(), metric.WithAttributes(attrs...))
+	}
+	return resp, err
+}
+
+func (t *telemetry) init() {
+	t.once.Do(func() {
+		meter := otel.GetMeterProvider().Meter(instrumentationName)
+		var err error
+		t.duration, err = meter.Float64Histogram("rpc.server.call.duration",
+			metric.WithDescription("Duration of gRPC server calls"),
+			metric.WithUnit("s"),
+		)
+		if err != nil {
+			t.log.WithContext(context.Background()).Warnf("grpc metrics disabled: %v", err)
+		}
+	})
+}
+
+// serverFault reports whether code is a server error, which marks the span
+// as failed; client errors such as InvalidArgument do not.
+func serverFault(code codes.Code) bool {
+	switch code {
+	case codes.Unknown, codes.DeadlineExceeded, codes.Unimplemented, codes.Internal,
+		codes.Unavailable, codes.DataLoss:
+		return true
+	}
+	return false
+}
+
+// splitMethod splits "/pkg.Service/Method" into service and method.
+func splitMethod(full string) (service, method string) {
+	full = strings.TrimPrefix(full, "/")
+	if i := strings.LastIndex(full, "/"); i >= 0 {
+		return full[:i], full[i+1:]
+	}
+	return "", full
+}
+
+// metadataCarrier adapts gRPC metadata to a propagation.TextMapCarrier.
+type metadataCarrier metadata.MD
+
+func (c metadataCarrier) Get(key string) string {
+	if v := metadata.MD(c).Get(key); len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+func (c metadataCarrier) Set(key, value string) { metadata.MD(c).Set(key, value) }
+
+func (c metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	return keys
+}
@@ -0,0 +1,121 @@
+// Package health aggregates component checks for the verbose health
+// endpoint. Components implement Checker; Run checks them concurrently, each
+// under its own timeout, and derives the overall status from their results
+// and severity.
+package health
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Status is the state of a component or of the whole service.
+type Status string
+
+const (
+	StatusOK        Status = "ok"
+	StatusDegraded  Status = "degraded"
+	StatusUnhealthy Status = "unhealthy"
+)
+
+// worse reports whether s is more severe than o.
+func (s Status) worse(o Status) bool {
+	rank := map[Status]int{StatusOK: 0, StatusDegraded: 1, StatusUnhealthy: 2}
+	return rank[s] > rank[o]
+}
+
+// Result is the outcome of one check. LatencyMS is filled in by Run.
+type Result struct {
+	Status    Status         `json:"status"`
+	LatencyMS float64        `json:"latency_ms"`
+	Error     string         `json:"error,omitempty"`
+	Details   map[string]any `json:"details,omitempty"`
+}
+
+// OK is a passing result with optional details.
+func OK(details map[string]any) Result {
+	return Result{Status: StatusOK, Details: details}
+}
+
+// Fail is a result with status and the error that caused it.
+func Fail(status Status, err error) Result {
+	return Result{Status: status, Error: err.Error()}
+}
+
+// Checker is implemented by components that can report their health. Checks
+// should honour ctx; Run stops waiting for them when it is done.
+type Checker interface {
+	HealthCheck(ctx context.Context) Result
+}
+
+// CheckerFunc adapts a function to Checker.
+type CheckerFunc func(ctx context.Context) Result
+
+func (f CheckerFunc) HealthCheck(ctx context.Context) Result { return f(ctx) }
+
+// Component is a named check. A failing critical component makes the service
+// unhealthy; any other failure only degrades it.
+type Component struct {
+	Name     string
+	Checker  Checker
+	Critical bool
+}
+
+// Report is the aggregated outcome of Run.
+type Report struct {
+	Status     Status            `json:"status"`
+	Components map[string]Result `json:"components"`
+}
+
+// Run checks components concurrently, giving each timeout (none when zero).
+// A check that does not return in time is reported unhealthy.
+func Run(ctx context.Context, timeout time.Duration, components []Component) Report {
+	results := make([]Result, len(components))
+	var wg sync.WaitGroup
+	for i, c := range components {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = check(ctx, timeout, c.Checker)
+		}()
+	}
+	wg.Wait()
+
+	rep := Report{Status: StatusOK, Components: make(map[string]Result, len(components))}
+	for i, c := range components {
+		res := results[i]
+		rep.Components[c.Name] = res
+		status := res.Status
+		if status == StatusUnhealthy && !c.Critical {
+			status = StatusDegraded
+		}
+		if status.worse(rep.Status) {
+			rep.Status = status
+		}
+	}
+	return rep
+}
+
+func check(ctx context.Context, timeout time.Duration, c Checker) Result {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	start := time.Now()
+	done := make(chan Result, 1)
+	go func() { done <- c.HealthCheck(ctx) }()
+	var res Result
+	select {
+	case res = <-done:
+	case <-ctx.Done():
+		res = Fail(StatusUnhealthy, fmt.Errorf("check did not finish: %w", ctx.Err()))
+	}
+	if res.Status == "" {
+		res.Status = StatusOK
+	}
+	res.LatencyMS = float64(time.Since(start).Microseconds()) / 1000
+	return res
+}
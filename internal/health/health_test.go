@@ -0,0 +1,61 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func fixed(status Status) Checker {
+	return CheckerFunc(func(context.Context) Result {
+		if status == StatusOK {
+			return OK(nil)
+		}
+		return Fail(status, errors.New("boom"))
+	})
+}
+
+func TestRunAggregatesBySeverity(t *testing.T) {
+	cases := []struct {
+		name       string
+		components []Component
+		want       Status
+	}{
+		{"all ok", []Component{{Name: "a", Checker: fixed(StatusOK), Critical: true}, {Name: "b", Checker: fixed(StatusOK)}}, StatusOK},
+		{"degraded", []Component{{Name: "a", Checker: fixed(StatusOK), Critical: true}, {Name: "b", Checker: fixed(StatusDegraded)}}, StatusDegraded},
+		{"non-critical down", []Component{{Name: "a", Checker: fixed(StatusOK), Critical: true}, {Name: "b", Checker: fixed(StatusUnhealthy)}}, StatusDegraded},
+		{"critical down", []Component{{Name: "a", Checker: fixed(StatusUnhealthy), Critical: true}, {Name: "b", Checker: fixed(StatusDegraded)}}, StatusUnhealthy},
+		{"none", nil, StatusOK},
+	}
+	for _, tc := range cases {
+		rep := Run(context.Background(), time.Second, tc.components)
+		if rep.Status != tc.want || len(rep.Components) != len(tc.components) {
+			t.Fatalf("%s: expected %s, got %+v", tc.name, tc.want, rep)
+		}
+	}
+}
+
+func TestRunTimesOutSlowChecks(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	hung := CheckerFunc(func(context.Context) Result {
+		<-release // ignores ctx
+		return OK(nil)
+	})
+	start := time.Now()
+	rep := Run(context.Background(), 20*time.Millisecond, []Component{
+		{Name: "hung", Checker: hung, Critical: true},
+		{Name: "fast", Checker: fixed(StatusOK)},
+	})
+	if time.Since(start) > time.Second {
+		t.Fatalf("Run waited for the hung check")
+	}
+	hungRes, fast := rep.Components["hung"], rep.Components["fast"]
+	if rep.Status != StatusUnhealthy || hungRes.Status != StatusUnhealthy || hungRes.Error == "" || hungRes.LatencyMS < 20 {
+		t.Fatalf("expected the hung check to time out, got %+v", rep)
+	}
+	if fast.Status != StatusOK {
+		t.Fatalf("expected the fast check to pass, got %+v", fast)
+	}
+}
@@ -0,0 +1,294 @@
+// Package httpclient builds the HTTP clients used for outbound calls to rate
+// and fee providers, enforcing an outbound URL policy so a tampered base URL
+// cannot be used to reach internal endpoints (SSRF).
+package httpclient
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/thiagozs/go-exchange/internal/config"
+	"github.com/thiagozs/go-exchange/internal/logger"
+	"github.com/thiagozs/go-exchange/internal/version"
+)
+
+// Policy restricts outbound destinations.
+//
+// By default only https URLs are allowed and the destination must not resolve
+// to a loopback, link-local (cloud metadata), unspecified or private
+// (RFC 1918 / ULA) address. AllowPrivate lifts the address restriction for
+// on-prem deployments; AllowedHosts lifts it for specific hosts only.
+// AllowHTTP permits plain http and is meant for tests and local setups.
+type Policy struct {
+	AllowHTTP    bool
+	AllowPrivate bool
+	AllowedHosts []string
+}
+
+// PolicyFromConfig returns the policy configured through OUTBOUND_* settings.
+func PolicyFromConfig(cfg *config.Config) Policy {
+	return Policy{
+		AllowHTTP:    cfg.OutboundAllowHTTP,
+		AllowPrivate: cfg.OutboundAllowPrivate,
+		AllowedHosts: cfg.OutboundAllowedHosts,
+	}
+}
+
+// PolicyError is returned when an outbound URL or address violates the policy.
+type PolicyError struct {
+	URL    string
+	Reason string
+}
+
+func (e *PolicyError) Error() string {
+	return "outbound request to " + e.URL + " blocked: " + e.Reason
+}
+
+// CheckURL validates the scheme and, for IP literals, the destination
+// address. Hostnames are checked again after resolution when dialing.
+func (p Policy) CheckURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return &PolicyError{URL: raw, Reason: "invalid URL"}
+	}
+	switch u.Scheme {
+	case "https":
+	case "http":
+		if !p.AllowHTTP {
+			return &PolicyError{URL: raw, Reason: "plain http is not allowed"}
+		}
+	default:
+		return &PolicyError{URL: raw, Reason: "scheme " + u.Scheme + " is not allowed"}
+	}
+	host := u.Hostname()
+	if host == "" {
+		return &PolicyError{URL: raw, Reason: "missing host"}
+	}
+	if p.privateAllowed(host) {
+		return nil
+	}
+	if ip := net.ParseIP(host); ip != nil && restrictedIP(ip) {
+		return &PolicyError{URL: raw, Reason: "destination " + ip.String() + " is a restricted address"}
+	}
+	return nil
+}
+
+func (p Policy) privateAllowed(host string) bool {
+	return p.AllowPrivate || slices.ContainsFunc(p.AllowedHosts, func(h string) bool {
+		return strings.EqualFold(strings.TrimSpace(h), host)
+	})
+}
+
+// restrictedIP reports whether ip is loopback, link-local, unspecified or
+// private.
+func restrictedIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsUnspecified() || ip.IsPrivate()
+}
+
+// Options tunes the client built by New. Zero values keep the
+// http.DefaultTransport settings; a zero Timeout means no overall timeout.
+type Options struct {
+	Timeout             time.Duration
+	MaxIdleConns        int
+	MaxIdleConnsPerHost int
+	TLSHandshakeTimeout time.Duration
+	// Proxy is the outbound proxy URL. When empty HTTPS_PROXY, HTTP_PROXY and
+	// NO_PROXY from the environment apply.
+	Proxy string
+	// Secrets are masked, like credential query parameters, in the URLs
+	// recorded on client spans.
+	Secrets []string
+	// PropagateTrace sends the trace context with every request. Leave it
+	// off for third-party APIs.
+	PropagateTrace bool
+	// UserAgent replaces Go's default User-Agent and Headers are set on every
+	// request, e.g. a token required by the egress proxy.
+	UserAgent string
+	Headers   map[string]string
+}
+
+// OptionsFromConfig returns the provider client options configured through
+// PROVIDER_HTTP_*, PROVIDER_USER_AGENT, PROVIDER_EXTRA_HEADERS and
+// OUTBOUND_PROXY settings.
+func OptionsFromConfig(cfg *config.Config) Options {
+	return Options{
+		Timeout:             cfg.ProviderHTTPTimeout,
+		MaxIdleConns:        cfg.ProviderMaxIdleConns,
+		MaxIdleConnsPerHost: cfg.ProviderMaxIdleConnsPerHost,
+		TLSHandshakeTimeout: cfg.ProviderTLSHandshakeTimeout,
+		Proxy:               cfg.OutboundProxy,
+		Secrets:             []string{cfg.ExchangeAPIKey},
+		UserAgent:           cmp.Or(cfg.ProviderUserAgent, "go-exchange/"+version.Get(cfg).Version),
+		Headers:             config.ParseKeyValues(cfg.ProviderExtraHeaders, false),
+	}
+}
+
+// New returns a client enforcing p on every request, including each redirect
+// hop, and on the resolved address of every connection it dials. Each request
+// is traced with a client span and carries o.UserAgent and o.Headers. The client owns its Transport, so it should
+// be built once and shared to reuse connections.
+func New(p Policy, o Options, lg *logger.Logger) *http.Client {
+	base := http.DefaultTransport.(*http.Transport).Clone()
+	if o.MaxIdleConns > 0 {
+		base.MaxIdleConns = o.MaxIdleConns
+	}
+	if o.MaxIdleConnsPerHost > 0 {
+		base.MaxIdleConnsPerHost = o.MaxIdleConnsPerHost
+	}
+	if o.TLSHandshakeTimeout > 0 {
+		base.TLSHandshakeTimeout = o.TLSHandshakeTimeout
+	}
+	// only an explicitly configured proxy may live at a private address;
+	// one from the environment is dialed under the policy like any host
+	base.Proxy = envProxy
+	var trusted func(*http.Request) (*url.URL, error)
+	if o.Proxy != "" {
+		if u, err := url.Parse(o.Proxy); err == nil && u.Host != "" {
+			base.Proxy = http.ProxyURL(u)
+			trusted = base.Proxy
+		} else if lg != nil {
+			lg.WithContext(context.Background()).Warnf("ignoring invalid outbound proxy %q", o.Proxy)
+		}
+	}
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	base.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		d := *dialer
+		if !p.AllowPrivate && !allowPrivateFrom(ctx) {
+			d.Control = func(_, address string, _ syscall.RawConn) error {
+				host, _, err := net.SplitHostPort(address)
+				if err != nil {
+					return err
+				}
+				if ip := net.ParseIP(host); ip != nil && restrictedIP(ip) {
+					return &PolicyError{URL: addr, Reason: "destination resolves to restricted address " + ip.String()}
+				}
+				return nil
+			}
+		}
+		return d.DialContext(ctx, network, addr)
+	}
+	return &http.Client{
+		Timeout: o.Timeout,
+		Transport: newTracingTransport(newHeaderTransport(o.UserAgent, o.Headers,
+			&policyTransport{policy: p, next: base, proxy: trusted, log: lg}),
+			o.Secrets, o.PropagateTrace),
+	}
+}
+
+// headerTransport sets the configured User-Agent, unless the request has its
+// own, and extra headers on each request before handing it on.
+type headerTransport struct {
+	userAgent string
+	headers   http.Header
+	next      http.RoundTripper
+}
+
+// newHeaderTransport returns next itself when there is nothing to set.
+func newHeaderTransport(userAgent string, headers map[string]string, next http.RoundTripper) http.RoundTripper {
+	if userAgent == "" && len(headers) == 0 {
+		return next
+	}
+	h := make(http.Header, len(headers))
+	for k, v := range headers {
+		h.Set(k, v)
+	}
+	return &headerTransport{userAgent: userAgent, headers: h, next: next}
+}
+
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// RoundTrippers must not modify the caller's request
+	req = req.Clone(req.Context())
+	if t.userAgent != "" && req.Header.Get("User-Agent") == "" {
+		req.Header.Set("User-Agent", t.userAgent)
+	}
+	for k, v := range t.headers {
+		req.Header[k] = v
+	}
+	return t.next.RoundTrip(req)
+}
+
+// envProxy picks the proxy from HTTPS_PROXY, HTTP_PROXY and NO_PROXY when
+// no proxy is configured. It is a variable so tests can stand in for the
+// environment, which net/http reads only once.
+var envProxy = http.ProxyFromEnvironment
+
+type allowPrivateCtxKey struct{}
+
+func allowPrivateFrom(ctx context.Context) bool {
+	v, _ := ctx.Value(allowPrivateCtxKey{}).(bool)
+	return v
+}
+
+// policyTransport checks each request URL before handing it to the
+// underlying transport. http.Client calls RoundTrip once per redirect hop,
+// so redirects are validated against the same policy.
+type policyTransport struct {
+	policy Policy
+	next   http.RoundTripper
+	// proxy mirrors the transport's selection of the configured proxy; nil
+	// when there is none
+	proxy func(*http.Request) (*url.URL, error)
+	log   *logger.Logger
+}
+
+func (t *policyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.policy.CheckURL(req.URL.String()); err != nil {
+		t.report(req.Context(), err)
+		return nil, err
+	}
+	// the configured proxy is trusted to be reachable wherever it lives;
+	// the target is still checked by URL above and resolved by the proxy
+	if t.policy.privateAllowed(req.URL.Hostname()) || t.proxied(req) {
+		req = req.WithContext(context.WithValue(req.Context(), allowPrivateCtxKey{}, true))
+	}
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		t.report(req.Context(), err)
+	}
+	return resp, err
+}
+
+func (t *policyTransport) proxied(req *http.Request) bool {
+	if t.proxy == nil {
+		return false
+	}
+	u, err := t.proxy(req)
+	return err == nil && u != nil
+}
+
+// report logs policy violations with a security tag so they can be alerted on.
+func (t *policyTransport) report(ctx context.Context, err error) {
+	var pe *PolicyError
+	if t.log == nil || !errors.As(err, &pe) {
+		return
+	}
+	t.log.WithContext(ctx).WithFields(logrus.Fields{
+		"security": "outbound_policy",
+		"url":      pe.URL,
+		"reason":   pe.Reason,
+	}).Error("outbound request blocked by policy")
+}
+
+// Validate checks a configured base URL against p, logging violations with
+// the same security tag. It is meant to surface misconfiguration at startup;
+// requests are checked again when they are made.
+func Validate(ctx context.Context, p Policy, lg *logger.Logger, name, raw string) error {
+	err := p.CheckURL(raw)
+	if err != nil && lg != nil {
+		lg.WithContext(ctx).WithFields(logrus.Fields{
+			"security": "outbound_policy",
+			"setting":  name,
+		}).Errorf("configured URL violates outbound policy: %v", err)
+	}
+	return err
+}
@@ -0,0 +1,227 @@
+package httpclient
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/thiagozs/go-exchange/internal/config"
+	"github.com/thiagozs/go-exchange/internal/logger"
+)
+
+func okServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func expectPolicyError(t *testing.T, err error) *PolicyError {
+	t.Helper()
+	var pe *PolicyError
+	if !errors.As(err, &pe) {
+		t.Fatalf("expected a PolicyError, got %v", err)
+	}
+	return pe
+}
+
+func TestCheckURL(t *testing.T) {
+	cases := []struct {
+		name   string
+		policy Policy
+		url    string
+		ok     bool
+	}{
+		{name: "https public", url: "https://api.exchangerate.host/latest", ok: true},
+		{name: "plain http", url: "http://api.exchangerate.host/latest"},
+		{name: "plain http allowed", policy: Policy{AllowHTTP: true}, url: "http://api.exchangerate.host/latest", ok: true},
+		{name: "other scheme", url: "file:///etc/passwd"},
+		{name: "metadata ip", url: "https://169.254.169.254/latest/meta-data"},
+		{name: "rfc1918", url: "https://10.0.0.5/fees"},
+		{name: "ipv6 loopback", url: "https://[::1]/"},
+		{name: "private allowed", policy: Policy{AllowPrivate: true}, url: "https://10.0.0.5/fees", ok: true},
+		{name: "host allowlisted", policy: Policy{AllowedHosts: []string{"10.0.0.5"}}, url: "https://10.0.0.5/fees", ok: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.policy.CheckURL(tc.url)
+			if tc.ok && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !tc.ok {
+				expectPolicyError(t, err)
+			}
+		})
+	}
+}
+
+func TestClientRejectsMetadataIPAndLogsSecurityEvent(t *testing.T) {
+	var buf bytes.Buffer
+	lg := logger.New(logger.Options{Format: "json", Level: "info", Out: &buf})
+	client := New(Policy{AllowHTTP: true}, Options{Timeout: time.Second}, lg)
+
+	_, err := client.Get("http://169.254.169.254/latest/meta-data/iam")
+	expectPolicyError(t, err)
+	if !strings.Contains(buf.String(), `"security":"outbound_policy"`) {
+		t.Fatalf("expected a security-tagged log entry, got %s", buf.String())
+	}
+}
+
+func TestClientRejectsHostnameResolvingToLoopback(t *testing.T) {
+	srv := okServer(t)
+	u, _ := url.Parse(srv.URL)
+	client := New(Policy{AllowHTTP: true}, Options{Timeout: time.Second}, nil)
+
+	_, err := client.Get("http://localhost:" + u.Port() + "/")
+	if pe := expectPolicyError(t, err); !strings.Contains(pe.Reason, "restricted address") {
+		t.Fatalf("expected a dial-time rejection, got %v", pe)
+	}
+}
+
+func TestClientRevalidatesRedirects(t *testing.T) {
+	target := okServer(t)
+	targetURL, _ := url.Parse(target.URL)
+	for _, loc := range []string{
+		"http://169.254.169.254/latest/meta-data",
+		"http://localhost:" + targetURL.Port() + "/",
+	} {
+		redirector := httptest.NewServer(http.RedirectHandler(loc, http.StatusFound))
+		// the first hop is explicitly allowlisted; the redirect target is not
+		client := New(Policy{AllowHTTP: true, AllowedHosts: []string{"127.0.0.1"}}, Options{Timeout: time.Second}, nil)
+		_, err := client.Get(redirector.URL)
+		redirector.Close()
+		expectPolicyError(t, err)
+	}
+}
+
+func TestClientAllowlistOverride(t *testing.T) {
+	srv := okServer(t)
+	for _, p := range []Policy{
+		{AllowHTTP: true, AllowPrivate: true},
+		{AllowHTTP: true, AllowedHosts: []string{"127.0.0.1"}},
+	} {
+		resp, err := New(p, Options{Timeout: time.Second}, nil).Get(srv.URL)
+		if err != nil {
+			t.Fatalf("policy %+v: unexpected error %v", p, err)
+		}
+		resp.Body.Close()
+	}
+}
+
+func TestClientTimesOut(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	client := New(Policy{AllowHTTP: true, AllowPrivate: true}, Options{Timeout: 50 * time.Millisecond}, nil)
+	start := time.Now()
+	_, err := client.Get(srv.URL)
+	if err == nil {
+		t.Fatalf("expected a timeout")
+	}
+	var ne net.Error
+	if !errors.As(err, &ne) || !ne.Timeout() {
+		t.Fatalf("expected a timeout error, got %v", err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Fatalf("request took %v, expected about 50ms", d)
+	}
+}
+
+func TestClientUsesConfiguredProxy(t *testing.T) {
+	var proxied atomic.Value
+	// a forward proxy on loopback; the target host is never resolved locally
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied.Store(r.URL.String())
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer proxy.Close()
+
+	client := New(Policy{AllowHTTP: true}, Options{Timeout: time.Second, Proxy: proxy.URL}, nil)
+	resp, err := client.Get("http://rates.example.invalid/latest")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp.Body.Close()
+	if got, _ := proxied.Load().(string); got != "http://rates.example.invalid/latest" {
+		t.Fatalf("expected the request to go through the proxy, got %q", got)
+	}
+
+	// the target URL is still checked
+	_, err = client.Get("http://169.254.169.254/latest/meta-data")
+	expectPolicyError(t, err)
+}
+
+func TestClientDialsEnvironmentProxyUnderPolicy(t *testing.T) {
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer proxy.Close()
+	u, _ := url.Parse(proxy.URL)
+	prev := envProxy
+	envProxy = http.ProxyURL(u)
+	t.Cleanup(func() { envProxy = prev })
+
+	// as if HTTP_PROXY pointed at a loopback address
+	_, err := New(Policy{AllowHTTP: true}, Options{Timeout: time.Second}, nil).Get("http://rates.example.invalid/latest")
+	expectPolicyError(t, err)
+}
+
+func TestClientSendsConfiguredHeaders(t *testing.T) {
+	var got atomic.Value
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/old" {
+			http.Redirect(w, r, "/new", http.StatusFound)
+			return
+		}
+		got.Store(r.Header.Clone())
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer upstream.Close()
+
+	cfg := &config.Config{ProviderUserAgent: "fx-bot/1.0", ProviderExtraHeaders: "X-Proxy-Token=egress1, X-Team=rates"}
+	client := New(Policy{AllowHTTP: true, AllowPrivate: true}, OptionsFromConfig(cfg), nil)
+
+	// redirect hops carry the headers too
+	req, _ := http.NewRequest(http.MethodGet, upstream.URL+"/old", nil)
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp.Body.Close()
+	h, _ := got.Load().(http.Header)
+	if h.Get("User-Agent") != "fx-bot/1.0" || h.Get("X-Proxy-Token") != "egress1" || h.Get("X-Team") != "rates" {
+		t.Fatalf("expected the configured headers, got %v", h)
+	}
+	if req.Header.Get("X-Proxy-Token") != "" {
+		t.Fatal("expected the caller's request to be left alone")
+	}
+
+	// a request's own User-Agent wins
+	req, _ = http.NewRequest(http.MethodGet, upstream.URL, nil)
+	req.Header.Set("User-Agent", "custom")
+	resp, err = client.Do(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp.Body.Close()
+	if h, _ := got.Load().(http.Header); h.Get("User-Agent") != "custom" {
+		t.Fatalf("expected the request User-Agent, got %q", h.Get("User-Agent"))
+	}
+
+	if ua := OptionsFromConfig(&config.Config{}).UserAgent; ua != "go-exchange/dev" {
+		t.Fatalf("expected the default User-Agent go-exchange/dev, got %q", ua)
+	}
+}
@@ -0,0 +1,48 @@
+package httpclient
+
+import (
+	"net/url"
+	"strings"
+)
+
+// Redacted replaces secrets in URLs that are logged or recorded on spans.
+const Redacted = "REDACTED"
+
+// credentialParams are query parameters that carry API keys.
+var credentialParams = []string{"access_key", "api_key", "apikey", "key", "token"}
+
+// RedactURL masks the userinfo password, credential query parameters and
+// every occurrence of the given secrets (e.g. an API key embedded in the
+// path) so raw can be logged.
+func RedactURL(raw string, secrets ...string) string {
+	out := raw
+	if u, err := url.Parse(raw); err == nil {
+		masked := false
+		if _, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(u.User.Username(), Redacted)
+			masked = true
+		}
+		if u.RawQuery != "" {
+			q := u.Query()
+			for _, name := range credentialParams {
+				if q.Has(name) {
+					q.Set(name, Redacted)
+					masked = true
+				}
+			}
+			if masked {
+				u.RawQuery = q.Encode()
+			}
+		}
+		if masked {
+			out = u.String()
+		}
+	}
+	for _, s := range secrets {
+		if s != "" {
+			out = strings.ReplaceAll(out, s, Redacted)
+			out = strings.ReplaceAll(out, url.PathEscape(s), Redacted)
+		}
+	}
+	return out
+}
@@ -0,0 +1,45 @@
+package httpclient
+
+import (
+	"net/http"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// newTracingTransport traces outbound requests with otelhttp: one client
+// span per round trip (so per redirect hop), child of the span in the
+// request context, ended when the response body is closed, plus the
+// http.client.* request metrics. With propagate the trace context is sent in
+// the request headers, which is only meant for services we run. The global
+// providers are used, so SetupOTel can install them after clients are built.
+func newTracingTransport(next http.RoundTripper, secrets []string, propagate bool) http.RoundTripper {
+	opts := []otelhttp.Option{otelhttp.WithSpanNameFormatter(spanName)}
+	if !propagate {
+		opts = append(opts, otelhttp.WithPropagators(propagation.NewCompositeTextMapPropagator()))
+	}
+	return otelhttp.NewTransport(&redactTransport{next: next, secrets: secrets}, opts...)
+}
+
+// redactTransport runs under otelhttp and replaces the url.full it recorded,
+// which only drops the userinfo, with the URL with secrets redacted.
+type redactTransport struct {
+	next    http.RoundTripper
+	secrets []string
+}
+
+func (t *redactTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if span := trace.SpanFromContext(req.Context()); span.IsRecording() {
+		span.SetAttributes(attribute.String("url.full", RedactURL(req.URL.String(), t.secrets...)))
+	}
+	return t.next.RoundTrip(req)
+}
+
+// spanName names client spans after the method and host, leaving out the
+// path and query: they can carry API keys and would make one span name per
+// currency pair.
+func spanName(_ string, req *http.Request) string {
+	return req.Method + " " + req.URL.Host
+}
@@ -0,0 +1,151 @@
+// Package lifecycle owns the background components of a process: pollers,
+// listeners next to the HTTP server, the cache connection and the telemetry
+// exporters. Components are started in the order they were added and
+// stopped in reverse, each under its own timeout, so one that hangs or fails
+// does not keep the others from stopping.
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/thiagozs/go-exchange/internal/logger"
+)
+
+type component struct {
+	name  string
+	start func(context.Context) error
+	stop  func(context.Context) error
+}
+
+// Manager starts and stops components. The zero value is not usable; build
+// it with New.
+type Manager struct {
+	timeout time.Duration
+	log     *logger.Logger
+
+	mu         sync.Mutex
+	components []component
+	// started counts the components started, a prefix of components
+	started int
+}
+
+// New returns a Manager giving every component at most timeout to stop;
+// zero leaves it to the context passed to Stop. lg may be nil.
+func New(timeout time.Duration, lg *logger.Logger) *Manager {
+	return &Manager{timeout: timeout, log: lg}
+}
+
+// Add registers a component. start must return once the component runs, and
+// stop once it stopped or its context is done; either may be nil. Components
+// added after Start are started by the next call to Start.
+func (m *Manager) Add(name string, start, stop func(context.Context) error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.components = append(m.components, component{name: name, start: start, stop: stop})
+}
+
+// Loop registers a component running run in its own goroutine until stopped:
+// stopping cancels the context of run and waits for it to return.
+func (m *Manager) Loop(name string, run func(context.Context)) {
+	var (
+		cancel context.CancelFunc
+		done   chan struct{}
+	)
+	m.Add(name, func(ctx context.Context) error {
+		// the loop outlives the start context, keeping its values
+		var loopCtx context.Context
+		loopCtx, cancel = context.WithCancel(context.WithoutCancel(ctx))
+		done = make(chan struct{})
+		go func() {
+			defer close(done)
+			run(loopCtx)
+		}()
+		return nil
+	}, func(ctx context.Context) error {
+		cancel()
+		select {
+		case <-done:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+}
+
+// Start starts the components not started yet, in the order they were added.
+// When one fails the components already started are stopped again and its
+// error is returned.
+func (m *Manager) Start(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for m.started < len(m.components) {
+		c := m.components[m.started]
+		if c.start != nil {
+			if err := c.start(ctx); err != nil {
+				err = fmt.Errorf("start %s: %w", c.name, err)
+				if serr := m.stopLocked(ctx); serr != nil {
+					err = errors.Join(err, serr)
+				}
+				return err
+			}
+		}
+		m.started++
+	}
+	return nil
+}
+
+// Stop stops the started components in reverse order, each bounded by the
+// timeout of the Manager and by ctx, and logs how long each took. A component
+// that fails or times out does not keep the others from stopping; their
+// errors are returned joined.
+func (m *Manager) Stop(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.stopLocked(ctx)
+}
+
+func (m *Manager) stopLocked(ctx context.Context) error {
+	var errs []error
+	for ; m.started > 0; m.started-- {
+		c := m.components[m.started-1]
+		if c.stop == nil {
+			continue
+		}
+		begin := time.Now()
+		err := m.stopOne(ctx, c)
+		took := time.Since(begin).Round(time.Millisecond)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("stop %s: %w", c.name, err))
+			if m.log != nil {
+				m.log.WithContext(ctx).Warnf("stopping %s failed after %s: %v", c.name, took, err)
+			}
+			continue
+		}
+		if m.log != nil {
+			m.log.WithContext(ctx).Infof("stopped %s in %s", c.name, took)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// stopOne runs the stop of c, giving up when its timeout expires even if the
+// stop function ignores its context.
+func (m *Manager) stopOne(ctx context.Context, c component) error {
+	if m.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, m.timeout)
+		defer cancel()
+	}
+	done := make(chan error, 1)
+	go func() { done <- c.stop(ctx) }()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("timed out: %w", ctx.Err())
+	}
+}
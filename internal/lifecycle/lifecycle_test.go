@@ -0,0 +1,130 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recorder records the order components start and stop in.
+type recorder struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (r *recorder) record(event string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+}
+
+func (r *recorder) add(m *Manager, name string, startErr, stopErr error) {
+	m.Add(name, func(context.Context) error {
+		r.record("start " + name)
+		return startErr
+	}, func(context.Context) error {
+		r.record("stop " + name)
+		return stopErr
+	})
+}
+
+func TestManagerStopsInReverseOrder(t *testing.T) {
+	var r recorder
+	m := New(time.Second, nil)
+	r.add(m, "cache", nil, nil)
+	r.add(m, "otel", nil, nil)
+	m.Add("no-op", nil, nil)
+	r.add(m, "grpc", nil, nil)
+
+	if err := m.Start(context.Background()); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	if err := m.Stop(context.Background()); err != nil {
+		t.Fatalf("stop: %v", err)
+	}
+	want := []string{"start cache", "start otel", "start grpc", "stop grpc", "stop otel", "stop cache"}
+	if !slices.Equal(r.events, want) {
+		t.Fatalf("expected %v, got %v", want, r.events)
+	}
+	// nothing is left to stop
+	if err := m.Stop(context.Background()); err != nil || len(r.events) != len(want) {
+		t.Fatalf("expected a second stop to do nothing, got %v (%v)", r.events, err)
+	}
+}
+
+func TestManagerStopsTheRestAfterFailures(t *testing.T) {
+	var r recorder
+	m := New(50*time.Millisecond, nil)
+	r.add(m, "cache", nil, nil)
+	r.add(m, "alerts", nil, errors.New("boom"))
+	m.Add("stuck", nil, func(context.Context) error {
+		r.record("stop stuck")
+		select {} // ignores its context
+	})
+	r.add(m, "grpc", nil, nil)
+	if err := m.Start(context.Background()); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+
+	begin := time.Now()
+	err := m.Stop(context.Background())
+	if took := time.Since(begin); took > time.Second {
+		t.Fatalf("expected the stuck component abandoned after its timeout, took %s", took)
+	}
+	want := []string{"start cache", "start alerts", "start grpc", "stop grpc", "stop stuck", "stop alerts", "stop cache"}
+	if !slices.Equal(r.events, want) {
+		t.Fatalf("expected %v, got %v", want, r.events)
+	}
+	if err == nil || !errors.Is(err, context.DeadlineExceeded) ||
+		!strings.Contains(err.Error(), "stop stuck: timed out") || !strings.Contains(err.Error(), "stop alerts: boom") {
+		t.Fatalf("expected the timeout and the failure joined, got %v", err)
+	}
+}
+
+func TestManagerStartFailureStopsStarted(t *testing.T) {
+	var r recorder
+	m := New(time.Second, nil)
+	r.add(m, "cache", nil, nil)
+	r.add(m, "grpc", errors.New("address in use"), nil)
+	r.add(m, "never", nil, nil)
+
+	err := m.Start(context.Background())
+	if err == nil || err.Error() != "start grpc: address in use" {
+		t.Fatalf("expected the start failure, got %v", err)
+	}
+	want := []string{"start cache", "start grpc", "stop cache"}
+	if !slices.Equal(r.events, want) {
+		t.Fatalf("expected %v, got %v", want, r.events)
+	}
+}
+
+func TestManagerLoop(t *testing.T) {
+	m := New(time.Second, nil)
+	exited := make(chan struct{})
+	m.Loop("poller", func(ctx context.Context) {
+		defer close(exited)
+		<-ctx.Done()
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := m.Start(ctx); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	cancel()
+	select {
+	case <-exited:
+		t.Fatal("expected the loop to outlive the context it was started with")
+	case <-time.After(20 * time.Millisecond):
+	}
+	if err := m.Stop(context.Background()); err != nil {
+		t.Fatalf("stop: %v", err)
+	}
+	select {
+	case <-exited:
+	default:
+		t.Fatal("expected the loop to return before Stop")
+	}
+}
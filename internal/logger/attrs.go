@@ -0,0 +1,320 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	otellog "go.opentelemetry.io/otel/log"
+)
+
+// Limits applied when converting nested fields, so a single log field cannot
+// turn into an arbitrarily large payload. Deeper values are stringified and
+// longer slices/maps are truncated.
+const (
+	maxFieldDepth = 4
+	maxFieldItems = 64
+)
+
+type fieldKind int
+
+const (
+	fieldString fieldKind = iota
+	fieldBool
+	fieldInt64
+	fieldFloat64
+	fieldBytes
+	fieldSlice
+	fieldMap
+)
+
+// fieldValue is the normalized form of a log/span field. It is shared by
+// span attributes (WithAttrs), span events and exported log records, so the
+// same field has the same shape everywhere it is emitted.
+type fieldValue struct {
+	kind  fieldKind
+	str   string
+	b     bool
+	i     int64
+	f     float64
+	bytes []byte
+	slice []fieldValue
+	m     []fieldKV // sorted by key
+}
+
+type fieldKV struct {
+	key string
+	val fieldValue
+}
+
+func stringField(s string) fieldValue { return fieldValue{kind: fieldString, str: s} }
+
+func floatField(f float64) fieldValue {
+	f2, ok, s := sanitizeFloat64(f)
+	if !ok {
+		return stringField(s)
+	}
+	return fieldValue{kind: fieldFloat64, f: f2}
+}
+
+// normalizeField converts an arbitrary Go value into a fieldValue.
+func normalizeField(v any) fieldValue { return normalizeFieldDepth(v, 0) }
+
+func normalizeFieldDepth(v any, depth int) fieldValue {
+	switch x := v.(type) {
+	case nil:
+		return stringField("")
+	case string:
+		return stringField(x)
+	case bool:
+		return fieldValue{kind: fieldBool, b: x}
+	case int:
+		return fieldValue{kind: fieldInt64, i: int64(x)}
+	case int8:
+		return fieldValue{kind: fieldInt64, i: int64(x)}
+	case int16:
+		return fieldValue{kind: fieldInt64, i: int64(x)}
+	case int32:
+		return fieldValue{kind: fieldInt64, i: int64(x)}
+	case int64:
+		return fieldValue{kind: fieldInt64, i: x}
+	case uint:
+		return uintField(uint64(x))
+	case uint8:
+		return fieldValue{kind: fieldInt64, i: int64(x)}
+	case uint16:
+		return fieldValue{kind: fieldInt64, i: int64(x)}
+	case uint32:
+		return fieldValue{kind: fieldInt64, i: int64(x)}
+	case uint64:
+		return uintField(x)
+	case float32:
+		return floatField(float64(x))
+	case float64:
+		return floatField(x)
+	case json.Number:
+		if n, err := x.Int64(); err == nil {
+			return fieldValue{kind: fieldInt64, i: n}
+		}
+		if f, err := x.Float64(); err == nil {
+			return floatField(f)
+		}
+		return stringField(x.String())
+	case time.Time:
+		return stringField(x.Format(time.RFC3339Nano))
+	case time.Duration:
+		return fieldValue{kind: fieldInt64, i: x.Milliseconds()}
+	case []byte:
+		return fieldValue{kind: fieldBytes, bytes: append([]byte(nil), x...)}
+	case fmt.Stringer:
+		return stringField(x.String())
+	case error:
+		return stringField(x.Error())
+	}
+
+	if depth >= maxFieldDepth {
+		return stringField(fmt.Sprint(v))
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if rv.IsNil() {
+			return stringField("")
+		}
+		return normalizeFieldDepth(rv.Elem().Interface(), depth)
+	case reflect.Slice, reflect.Array:
+		n := min(rv.Len(), maxFieldItems)
+		out := make([]fieldValue, n)
+		for i := range n {
+			out[i] = normalizeFieldDepth(rv.Index(i).Interface(), depth+1)
+		}
+		return fieldValue{kind: fieldSlice, slice: out}
+	case reflect.Map:
+		kvs := make([]fieldKV, 0, min(rv.Len(), maxFieldItems))
+		iter := rv.MapRange()
+		for iter.Next() {
+			kvs = append(kvs, fieldKV{
+				key: fmt.Sprint(iter.Key().Interface()),
+				val: normalizeFieldDepth(iter.Value().Interface(), depth+1),
+			})
+		}
+		sort.Slice(kvs, func(i, j int) bool { return kvs[i].key < kvs[j].key })
+		if len(kvs) > maxFieldItems {
+			kvs = kvs[:maxFieldItems]
+		}
+		return fieldValue{kind: fieldMap, m: kvs}
+	case reflect.Struct:
+		// go through JSON so field names and tags match what the JSON
+		// formatter would print
+		b, err := json.Marshal(v)
+		if err != nil {
+			return stringField(fmt.Sprint(v))
+		}
+		dec := json.NewDecoder(bytes.NewReader(b))
+		dec.UseNumber()
+		var generic any
+		if err := dec.Decode(&generic); err != nil {
+			return stringField(string(b))
+		}
+		return normalizeFieldDepth(generic, depth)
+	}
+	return stringField(fmt.Sprint(v))
+}
+
+func uintField(x uint64) fieldValue {
+	if x <= math.MaxInt64 {
+		return fieldValue{kind: fieldInt64, i: int64(x)}
+	}
+	return stringField(strconv.FormatUint(x, 10))
+}
+
+// plain returns v as a JSON-encodable Go value.
+func (v fieldValue) plain() any {
+	switch v.kind {
+	case fieldBool:
+		return v.b
+	case fieldInt64:
+		return v.i
+	case fieldFloat64:
+		return v.f
+	case fieldBytes:
+		return v.bytes
+	case fieldSlice:
+		out := make([]any, len(v.slice))
+		for i, e := range v.slice {
+			out[i] = e.plain()
+		}
+		return out
+	case fieldMap:
+		out := make(map[string]any, len(v.m))
+		for _, kv := range v.m {
+			out[kv.key] = kv.val.plain()
+		}
+		return out
+	default:
+		return v.str
+	}
+}
+
+// String renders scalars as text and composite values as JSON.
+func (v fieldValue) String() string {
+	switch v.kind {
+	case fieldString:
+		return v.str
+	case fieldBool:
+		return strconv.FormatBool(v.b)
+	case fieldInt64:
+		return strconv.FormatInt(v.i, 10)
+	case fieldFloat64:
+		return strconv.FormatFloat(v.f, 'g', -1, 64)
+	case fieldBytes:
+		return base64.StdEncoding.EncodeToString(v.bytes)
+	}
+	b, err := json.Marshal(v.plain())
+	if err != nil {
+		return fmt.Sprint(v.plain())
+	}
+	return string(b)
+}
+
+// attribute converts v to a span attribute. Attributes only support flat
+// homogeneous slices, so mixed or nested slices become string slices and
+// maps are encoded as JSON.
+func (v fieldValue) attribute(key string) attribute.KeyValue {
+	switch v.kind {
+	case fieldString:
+		return attribute.String(key, v.str)
+	case fieldBool:
+		return attribute.Bool(key, v.b)
+	case fieldInt64:
+		return attribute.Int64(key, v.i)
+	case fieldFloat64:
+		return attribute.Float64(key, v.f)
+	case fieldSlice:
+		return sliceAttribute(key, v.slice)
+	}
+	return attribute.String(key, v.String())
+}
+
+func sliceAttribute(key string, vs []fieldValue) attribute.KeyValue {
+	kind := fieldString
+	if len(vs) > 0 {
+		kind = vs[0].kind
+	}
+	for _, e := range vs {
+		if e.kind != kind {
+			kind = fieldString
+			break
+		}
+	}
+	switch kind {
+	case fieldBool:
+		out := make([]bool, len(vs))
+		for i, e := range vs {
+			out[i] = e.b
+		}
+		return attribute.BoolSlice(key, out)
+	case fieldInt64:
+		out := make([]int64, len(vs))
+		for i, e := range vs {
+			out[i] = e.i
+		}
+		return attribute.Int64Slice(key, out)
+	case fieldFloat64:
+		out := make([]float64, len(vs))
+		for i, e := range vs {
+			out[i] = e.f
+		}
+		return attribute.Float64Slice(key, out)
+	}
+	out := make([]string, len(vs))
+	for i, e := range vs {
+		out[i] = e.String()
+	}
+	return attribute.StringSlice(key, out)
+}
+
+// logValue converts v to a log record value, keeping slices and maps
+// structured.
+func (v fieldValue) logValue() otellog.Value {
+	switch v.kind {
+	case fieldBool:
+		return otellog.BoolValue(v.b)
+	case fieldInt64:
+		return otellog.Int64Value(v.i)
+	case fieldFloat64:
+		return otellog.Float64Value(v.f)
+	case fieldBytes:
+		return otellog.BytesValue(v.bytes)
+	case fieldSlice:
+		out := make([]otellog.Value, len(v.slice))
+		for i, e := range v.slice {
+			out[i] = e.logValue()
+		}
+		return otellog.SliceValue(out...)
+	case fieldMap:
+		out := make([]otellog.KeyValue, len(v.m))
+		for i, kv := range v.m {
+			out[i] = otellog.KeyValue{Key: kv.key, Value: kv.val.logValue()}
+		}
+		return otellog.MapValue(out...)
+	default:
+		return otellog.StringValue(v.str)
+	}
+}
+
+func toKV(key string, v any) (KeyValue, bool) {
+	return normalizeField(v).attribute(normalizeKey(key)), true
+}
+
+func toLogKeyValue(key string, v any) (otellog.KeyValue, bool) {
+	return otellog.KeyValue{Key: normalizeKey(key), Value: normalizeField(v).logValue()}, true
+}
@@ -0,0 +1,166 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	otellog "go.opentelemetry.io/otel/log"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+type attrsTestPayload struct {
+	Name  string   `json:"name"`
+	Codes []string `json:"codes"`
+}
+
+// plainLogValue turns a log value into plain Go values for comparison.
+func plainLogValue(v otellog.Value) any {
+	switch v.Kind() {
+	case otellog.KindBool:
+		return v.AsBool()
+	case otellog.KindInt64:
+		return v.AsInt64()
+	case otellog.KindFloat64:
+		return v.AsFloat64()
+	case otellog.KindSlice:
+		out := []any{}
+		for _, e := range v.AsSlice() {
+			out = append(out, plainLogValue(e))
+		}
+		return out
+	case otellog.KindMap:
+		out := map[string]any{}
+		for _, kv := range v.AsMap() {
+			out[kv.Key] = plainLogValue(kv.Value)
+		}
+		return out
+	default:
+		return v.AsString()
+	}
+}
+
+func TestFieldFidelityAcrossSignals(t *testing.T) {
+	fields := map[string]any{
+		"tags":   []string{"a", "b"},
+		"counts": []int{1, 2, 3},
+		"meta": map[string]any{
+			"region": "sa-east-1",
+			"ids":    []int{7, 8},
+			"inner":  map[string]any{"ok": true},
+		},
+		"payload": attrsTestPayload{Name: "x", Codes: []string{"USD"}},
+	}
+
+	spanExp := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSampler(sdktrace.AlwaysSample()), sdktrace.WithSyncer(spanExp))
+	t.Cleanup(func() { _ = tp.Shutdown(context.Background()) })
+	logExp := &testLogExporter{}
+	lp := sdklog.NewLoggerProvider(sdklog.WithProcessor(sdklog.NewSimpleProcessor(logExp)))
+	t.Cleanup(func() { _ = lp.Shutdown(context.Background()) })
+
+	l := New(Options{Format: "text", Level: "debug", Out: &bytes.Buffer{}})
+	l.tracer = tp.Tracer("test")
+	if err := l.SetupTelemetry(context.Background(), nil); err != nil {
+		t.Fatalf("setup telemetry: %v", err)
+	}
+	l.otelHook.setEmitter(lp.Logger("test"))
+
+	ctx, span := l.Start(context.Background(), "fidelity", fields)
+	l.WithContext(ctx).WithFields(logrus.Fields(fields)).Info("fields")
+	span.End()
+
+	spans := spanExp.GetSpans()
+	if len(spans) != 1 || len(spans[0].Events) != 1 {
+		t.Fatalf("expected one span with one event, got %v", spans)
+	}
+	spanAttrs := map[attribute.Key]attribute.Value{}
+	for _, kv := range spans[0].Attributes {
+		spanAttrs[kv.Key] = kv.Value
+	}
+	eventAttrs := map[attribute.Key]attribute.Value{}
+	for _, kv := range spans[0].Events[0].Attributes {
+		eventAttrs[kv.Key] = kv.Value
+	}
+	if len(logExp.records) == 0 {
+		t.Fatalf("expected log records to be exported")
+	}
+	logAttrs := map[string]any{}
+	logExp.records[len(logExp.records)-1].WalkAttributes(func(kv otellog.KeyValue) bool {
+		logAttrs[kv.Key] = plainLogValue(kv.Value)
+		return true
+	})
+
+	// span attributes and span events must agree exactly
+	for k := range fields {
+		key := attribute.Key(k)
+		if spanAttrs[key] != eventAttrs[key] {
+			t.Errorf("%s: span attribute %v != event attribute %v", k, spanAttrs[key].Emit(), eventAttrs[key].Emit())
+		}
+	}
+
+	if got := spanAttrs["tags"].AsStringSlice(); !reflect.DeepEqual(got, []string{"a", "b"}) {
+		t.Errorf("tags attribute = %v", got)
+	}
+	if got := spanAttrs["counts"].AsInt64Slice(); !reflect.DeepEqual(got, []int64{1, 2, 3}) {
+		t.Errorf("counts attribute = %v", got)
+	}
+	if got := spanAttrs["meta"].AsString(); got != `{"ids":[7,8],"inner":{"ok":true},"region":"sa-east-1"}` {
+		t.Errorf("meta attribute = %s", got)
+	}
+	if got := spanAttrs["payload"].AsString(); got != `{"codes":["USD"],"name":"x"}` {
+		t.Errorf("payload attribute = %s", got)
+	}
+
+	// log records keep the structure instead of stringifying it
+	want := map[string]any{
+		"tags":   []any{"a", "b"},
+		"counts": []any{int64(1), int64(2), int64(3)},
+		"meta": map[string]any{
+			"region": "sa-east-1",
+			"ids":    []any{int64(7), int64(8)},
+			"inner":  map[string]any{"ok": true},
+		},
+		"payload": map[string]any{"name": "x", "codes": []any{"USD"}},
+	}
+	for k, w := range want {
+		if !reflect.DeepEqual(logAttrs[k], w) {
+			t.Errorf("%s: log attribute = %#v, want %#v", k, logAttrs[k], w)
+		}
+	}
+}
+
+func TestNormalizeFieldLimits(t *testing.T) {
+	long := make([]int, 1000)
+	if v := normalizeField(long); len(v.slice) != maxFieldItems {
+		t.Fatalf("expected slice truncated to %d items, got %d", maxFieldItems, len(v.slice))
+	}
+
+	big := map[string]int{}
+	for i := range 1000 {
+		big[fmt.Sprintf("k%04d", i)] = i
+	}
+	if v := normalizeField(big); len(v.m) != maxFieldItems {
+		t.Fatalf("expected map truncated to %d entries, got %d", maxFieldItems, len(v.m))
+	}
+
+	var deep any = "leaf"
+	for range 10 {
+		deep = []any{deep}
+	}
+	v := normalizeField(deep)
+	depth := 0
+	for v.kind == fieldSlice {
+		v = v.slice[0]
+		depth++
+	}
+	if depth != maxFieldDepth || v.kind != fieldString {
+		t.Fatalf("expected nesting capped at depth %d, got %d (%v)", maxFieldDepth, depth, v.kind)
+	}
+}
@@ -0,0 +1,36 @@
+package logger
+
+import (
+	"io"
+	"os"
+
+	"github.com/thiagozs/go-exchange/internal/config"
+)
+
+// colorEnabled resolves LOG_COLOR for the writer the logger outputs to: auto
+// (or empty) colors only terminals, so files, pipes and buffers stay free of
+// escape sequences.
+func colorEnabled(mode string, out io.Writer) bool {
+	switch mode {
+	case config.LogColorAlways:
+		return true
+	case config.LogColorNever:
+		return false
+	default:
+		return isTerminal(out)
+	}
+}
+
+// isTerminal reports whether w is a file backed by a character device, which
+// is how a TTY shows up on every platform we build for.
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return fi.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,174 @@
+package logger
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// dedupDropField marks an entry the dedup hook suppressed; formatters
+	// and the OTel hook skip such entries.
+	dedupDropField = "log.dedup_drop"
+	// dedupCountField carries the count on summary lines, which are never
+	// deduplicated themselves.
+	dedupCountField = "suppressed"
+	// dedupMaxKeys bounds the tracked keys; entries beyond it pass through.
+	dedupMaxKeys = 1024
+)
+
+// dedupKeyFields are the fields that, with the level and the message
+// template, identify similar entries.
+var dedupKeyFields = []string{"file", logrus.ErrorKey, "component", "provider"}
+
+// dedupHook rate-limits similar entries: up to burst entries per key pass in
+// each interval, the rest are dropped and reported by one summary line per
+// key ("suppressed N similar messages in the last 30s") once the interval
+// after the first drop has elapsed. Fatal and panic entries always pass. A
+// zero interval disables it.
+type dedupHook struct {
+	log *logrus.Logger
+
+	mu       sync.Mutex
+	interval time.Duration
+	burst    int
+	now      func() time.Time
+	keys     map[string]*dedupState
+	// flushing is set while a summary flush is scheduled
+	flushing bool
+}
+
+type dedupState struct {
+	start      time.Time
+	count      int
+	suppressed int
+	level      logrus.Level
+	msg        string
+}
+
+func newDedupHook(log *logrus.Logger) *dedupHook {
+	return &dedupHook{log: log, now: time.Now, keys: map[string]*dedupState{}}
+}
+
+// configure sets the limits; it is called before the logger is used.
+func (h *dedupHook) configure(interval time.Duration, burst int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.interval, h.burst = interval, max(burst, 1)
+}
+
+func (h *dedupHook) Levels() []logrus.Level { return logrus.AllLevels }
+
+func (h *dedupHook) Fire(e *logrus.Entry) error {
+	if e.Level <= logrus.FatalLevel {
+		return nil
+	}
+	if _, ok := e.Data[dedupCountField]; ok {
+		return nil
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.interval <= 0 {
+		return nil
+	}
+	key := dedupKey(e)
+	now := h.now()
+	st, ok := h.keys[key]
+	if !ok {
+		if len(h.keys) >= dedupMaxKeys {
+			return nil
+		}
+		st = &dedupState{start: now, level: e.Level, msg: e.Message}
+		h.keys[key] = st
+	}
+	if now.Sub(st.start) >= h.interval && st.suppressed == 0 {
+		st.start, st.count = now, 0
+	}
+	st.count++
+	if st.count <= h.burst {
+		return nil
+	}
+	st.suppressed++
+	e.Data[dedupDropField] = true
+	if !h.flushing {
+		h.flushing = true
+		time.AfterFunc(h.interval, h.flush)
+	}
+	return nil
+}
+
+// flush logs a summary for every key with suppressed entries and forgets
+// idle keys.
+func (h *dedupHook) flush() {
+	type summary struct {
+		level logrus.Level
+		msg   string
+		n     int
+	}
+	h.mu.Lock()
+	now := h.now()
+	var out []summary
+	for key, st := range h.keys {
+		switch {
+		case st.suppressed > 0:
+			out = append(out, summary{st.level, st.msg, st.suppressed})
+			st.start, st.count, st.suppressed = now, 0, 0
+		case now.Sub(st.start) >= h.interval:
+			delete(h.keys, key)
+		}
+	}
+	h.flushing = false
+	interval := h.interval
+	h.mu.Unlock()
+
+	// logged without the lock: these entries go through Fire again
+	for _, s := range out {
+		logrus.NewEntry(h.log).WithFields(logrus.Fields{
+			dedupCountField: s.n,
+			"message":       s.msg,
+		}).Log(s.level, fmt.Sprintf("suppressed %d similar messages in the last %s", s.n, interval))
+	}
+}
+
+// dedupKey identifies similar entries by level, message template and the
+// dedupKeyFields values.
+func dedupKey(e *logrus.Entry) string {
+	var b strings.Builder
+	b.WriteString(e.Level.String())
+	b.WriteByte('|')
+	b.WriteString(messageTemplate(e.Message))
+	for _, k := range dedupKeyFields {
+		if v, ok := e.Data[k]; ok {
+			fmt.Fprintf(&b, "|%s=%v", k, v)
+		}
+	}
+	return b.String()
+}
+
+// messageTemplate replaces digit runs with "#", so messages differing only in
+// counts, ids or durations are considered similar.
+func messageTemplate(msg string) string {
+	var b strings.Builder
+	inDigits := false
+	for _, r := range msg {
+		if r >= '0' && r <= '9' {
+			if !inDigits {
+				b.WriteByte('#')
+			}
+			inDigits = true
+			continue
+		}
+		inDigits = false
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// suppressed reports whether the dedup hook dropped e.
+func suppressed(e *logrus.Entry) bool {
+	_, ok := e.Data[dedupDropField]
+	return ok
+}
@@ -0,0 +1,110 @@
+package logger
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func countLines(buf *bytes.Buffer) int {
+	return strings.Count(buf.String(), "\n")
+}
+
+func TestDedupSuppressesRepeatedErrors(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(Options{Format: "json", Level: "info", DedupInterval: 30 * time.Second, DedupBurst: 5, Out: &buf})
+	errDown := errors.New("dial tcp 127.0.0.1:6379: connect: connection refused")
+
+	var wg sync.WaitGroup
+	for range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 20 {
+				l.WithFields(logrus.Fields{logrus.ErrorKey: errDown}).Error("cache error")
+			}
+		}()
+	}
+	wg.Wait()
+	if n := countLines(&buf); n != 5 {
+		t.Fatalf("expected 5 lines within the burst, got %d:\n%s", n, buf.String())
+	}
+
+	buf.Reset()
+	l.dedup.flush()
+	if n := countLines(&buf); n != 1 {
+		t.Fatalf("expected one summary line, got %d:\n%s", n, buf.String())
+	}
+	if !strings.Contains(buf.String(), "suppressed 995 similar messages in the last 30s") {
+		t.Fatalf("unexpected summary: %s", buf.String())
+	}
+
+	// the window restarts after the summary
+	buf.Reset()
+	l.WithFields(logrus.Fields{logrus.ErrorKey: errDown}).Error("cache error")
+	if n := countLines(&buf); n != 1 {
+		t.Fatalf("expected the entry to pass after the summary, got %d lines", n)
+	}
+}
+
+func TestDedupKeysOnTemplateAndFields(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(Options{Format: "text", Level: "info", DedupInterval: time.Minute, DedupBurst: 1, Out: &buf})
+	for i := range 10 {
+		l.Infof("retry %d of 10", i) // same template: one passes
+	}
+	l.With(map[string]any{"provider": "bcb"}).Infof("retry 1 of 10") // other provider
+	l.Errorf("retry 1 of 10")                                        // other level
+	if n := countLines(&buf); n != 3 {
+		t.Fatalf("expected 3 lines, got %d:\n%s", n, buf.String())
+	}
+}
+
+func TestDedupWindowExpires(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(Options{Format: "text", Level: "info", DedupInterval: time.Second, DedupBurst: 2, Out: &buf})
+	now := time.Unix(0, 0)
+	l.dedup.now = func() time.Time { return now }
+	for range 2 {
+		l.Infof("tick")
+	}
+	now = now.Add(2 * time.Second)
+	for range 2 {
+		l.Infof("tick")
+	}
+	if n := countLines(&buf); n != 4 {
+		t.Fatalf("expected every entry in its own window to pass, got %d lines", n)
+	}
+}
+
+func TestDedupNeverSuppressesFatalOrPanic(t *testing.T) {
+	h := newDedupHook(logrus.New())
+	h.configure(time.Minute, 1)
+	for _, lvl := range []logrus.Level{logrus.PanicLevel, logrus.FatalLevel} {
+		for range 3 {
+			e := &logrus.Entry{Level: lvl, Message: "boom", Data: logrus.Fields{}}
+			if err := h.Fire(e); err != nil {
+				t.Fatalf("fire: %v", err)
+			}
+			if suppressed(e) {
+				t.Fatalf("%s entry suppressed", lvl)
+			}
+		}
+	}
+}
+
+func TestDedupOffByDefault(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(Options{Format: "text", Level: "info", Out: &buf})
+	for range 20 {
+		l.Errorf("cache error")
+	}
+	if n := countLines(&buf); n != 20 {
+		t.Fatalf("expected 20 lines, got %d", n)
+	}
+}
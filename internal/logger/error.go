@@ -0,0 +1,69 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ErrorCtx logs err at error level as "msg" with an error field plus fields,
+// and marks the span in ctx as failed (see RecordSpanError), so traces of
+// failed requests do not look healthy.
+func (l *Logger) ErrorCtx(ctx context.Context, err error, msg string, fields map[string]any) {
+	RecordSpanError(trace.SpanFromContext(ctx), err, msg)
+
+	f := make(logrus.Fields, len(fields)+2)
+	for k, v := range fields {
+		f[k] = v
+	}
+	if err != nil {
+		f[logrus.ErrorKey] = err.Error()
+		f[string(semconv.ExceptionTypeKey)] = errorType(err)
+	}
+	l.logrus.WithContext(ctx).WithFields(l.fillFields(f)).Error(msg)
+}
+
+// RecordSpanError marks a recording span as failed with msg as the status
+// description: err is recorded as an exception event, unless the span already
+// holds one with the same message, and its type is set as the exception.type
+// attribute.
+func RecordSpanError(span trace.Span, err error, msg string) {
+	if err == nil || span == nil || !span.IsRecording() {
+		return
+	}
+	if !hasException(span, err) {
+		span.RecordError(err)
+	}
+	span.SetStatus(codes.Error, msg)
+	span.SetAttributes(semconv.ExceptionTypeKey.String(errorType(err)))
+}
+
+// hasException reports whether span already recorded an exception event for
+// err. Only SDK spans expose their events; others are assumed not to.
+func hasException(span trace.Span, err error) bool {
+	ro, ok := span.(interface{ Events() []sdktrace.Event })
+	if !ok {
+		return false
+	}
+	for _, ev := range ro.Events() {
+		if ev.Name != semconv.ExceptionEventName {
+			continue
+		}
+		for _, kv := range ev.Attributes {
+			if kv.Key == semconv.ExceptionMessageKey && kv.Value.AsString() == err.Error() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// errorType names the dynamic type of err, e.g. "*url.Error".
+func errorType(err error) string {
+	return fmt.Sprintf("%T", err)
+}
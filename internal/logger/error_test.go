@@ -0,0 +1,81 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+type upstreamError struct{ status int }
+
+func (e *upstreamError) Error() string { return "upstream failed" }
+
+func TestErrorCtxMarksSpan(t *testing.T) {
+	exp := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exp))
+	defer func() { _ = tp.Shutdown(context.Background()) }()
+
+	var buf bytes.Buffer
+	l := New(Options{Format: "json", Level: "info", Out: &buf})
+	ctx, span := tp.Tracer("test").Start(context.Background(), "convert")
+	err := &upstreamError{status: 502}
+	l.ErrorCtx(ctx, err, "convert failed", map[string]any{"from": "USD"})
+	// a second report of the same error must not add another event
+	RecordSpanError(span, err, "convert failed")
+	span.End()
+
+	spans := exp.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("expected one span, got %d", len(spans))
+	}
+	got := spans[0]
+	if got.Status.Code != codes.Error || got.Status.Description != "convert failed" {
+		t.Fatalf("unexpected status %+v", got.Status)
+	}
+	var exceptions int
+	for _, ev := range got.Events {
+		if ev.Name == "exception" {
+			exceptions++
+		}
+	}
+	if exceptions != 1 {
+		t.Fatalf("expected a single exception event, got %+v", got.Events)
+	}
+	var typ string
+	for _, kv := range got.Attributes {
+		if kv.Key == "exception.type" {
+			typ = kv.Value.AsString()
+		}
+	}
+	if typ != "*logger.upstreamError" {
+		t.Fatalf("expected exception.type attribute, got %q", typ)
+	}
+
+	var entry map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("decode log %q: %v", buf.String(), err)
+	}
+	if entry["level"] != "ERROR" || entry["msg"] != "convert failed" || entry["error"] != "upstream failed" ||
+		entry["from"] != "USD" || entry["exception.type"] != "*logger.upstreamError" {
+		t.Fatalf("unexpected log entry %v", entry)
+	}
+	if file, _ := entry["file"].(string); !strings.HasPrefix(file, "error_test.go:") {
+		t.Fatalf("expected file to point at the caller, got %q", file)
+	}
+}
+
+func TestErrorCtxWithoutSpan(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(Options{Format: "json", Level: "info", Out: &buf})
+	l.ErrorCtx(context.Background(), errors.New("boom"), "failed", nil)
+	if !strings.Contains(buf.String(), `"error":"boom"`) {
+		t.Fatalf("expected the error to be logged, got %q", buf.String())
+	}
+}
@@ -0,0 +1,60 @@
+package logger
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"time"
+)
+
+// flushTimeout bounds Flush, so a dead collector cannot hold up an exit.
+const flushTimeout = 5 * time.Second
+
+// flushRegistry holds the telemetry flushers of a logger and its children.
+type flushRegistry struct {
+	mu       sync.Mutex
+	flushers []func(context.Context) error
+	// exit terminates the process after a fatal entry; stubbed in tests
+	exit func(int)
+}
+
+func newFlushRegistry() *flushRegistry {
+	return &flushRegistry{exit: os.Exit}
+}
+
+// exitAfterFlush is the logrus ExitFunc: fatal entries flush telemetry
+// before the process exits, so the last spans and the fatal record itself
+// are exported.
+func (r *flushRegistry) exitAfterFlush(code int) {
+	_ = r.flush(context.Background())
+	r.exit(code)
+}
+
+func (r *flushRegistry) flush(ctx context.Context) error {
+	r.mu.Lock()
+	flushers := append([]func(context.Context) error(nil), r.flushers...)
+	r.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(ctx, flushTimeout)
+	defer cancel()
+	var errs []error
+	for _, fn := range flushers {
+		errs = append(errs, fn(ctx))
+	}
+	return errors.Join(errs...)
+}
+
+// RegisterFlusher adds fn to the functions run by Flush and before a fatal
+// entry exits the process. SetupOTel registers the ForceFlush of its
+// providers.
+func (l *Logger) RegisterFlusher(fn func(context.Context) error) {
+	l.flush.mu.Lock()
+	defer l.flush.mu.Unlock()
+	l.flush.flushers = append(l.flush.flushers, fn)
+}
+
+// Flush runs the registered flushers, giving them together at most 5s.
+func (l *Logger) Flush(ctx context.Context) error {
+	return l.flush.flush(ctx)
+}
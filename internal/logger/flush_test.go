@@ -0,0 +1,31 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestFatalFlushesBeforeExit(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(Options{Format: "text", Level: "info", Out: &buf})
+	var events []string
+	l.RegisterFlusher(func(ctx context.Context) error {
+		if _, ok := ctx.Deadline(); !ok {
+			t.Errorf("expected a bounded flush context")
+		}
+		events = append(events, "flush")
+		return nil
+	})
+	l.flush.exit = func(code int) { events = append(events, "exit") }
+
+	l.With(map[string]any{"component": "test"}).WithContext(context.Background()).Fatal("shutting down")
+
+	if strings.Join(events, ",") != "flush,exit" {
+		t.Fatalf("expected flush before exit, got %v", events)
+	}
+	if !strings.Contains(buf.String(), "shutting down") {
+		t.Fatalf("expected the fatal entry to be written first, got %q", buf.String())
+	}
+}
@@ -2,15 +2,14 @@ package logger
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"io"
+	"maps"
 	"math"
 	"os"
-	"reflect"
+	"path"
 	"runtime"
 	"sort"
-	"strconv"
 	"strings"
 	"time"
 
@@ -33,6 +32,18 @@ type Logger struct {
 	name      string
 	formatter string
 	otelHook  *otelLogHook
+	// colors enables ANSI level colors in the text format
+	colors bool
+	// caller adds the file field with the calling file:line:func
+	caller bool
+	// redact hides secrets in fields and messages
+	redact *redactHook
+	// dedup rate-limits similar entries
+	dedup *dedupHook
+	// flush holds the telemetry flushers run before a fatal exit
+	flush *flushRegistry
+	// fields are bound by With and added to every entry
+	fields logrus.Fields
 }
 
 // Options for initializing the logger
@@ -40,26 +51,50 @@ type Options struct {
 	Format string // "text" or "json"
 	Level  string // info, debug, warn, error
 	Name   string // application name
-	Out    io.Writer
+	Color  string // auto (default), always or never; text format only
+	// DisableCaller omits the file field and the stack walk it costs
+	DisableCaller bool
+	// RedactKeys replaces DefaultRedactKeys when non-nil
+	RedactKeys []string
+	// DedupInterval and DedupBurst let at most DedupBurst similar entries
+	// through per interval; a zero interval disables deduplication
+	DedupInterval time.Duration
+	DedupBurst    int
+	Out           io.Writer
 }
 
 func NewLogger(name, format string) (*Logger, error) {
 	lg := logrus.New()
 
-	formatter := getFormatter(format, name)
+	colors := colorEnabled(config.LogColorAuto, lg.Out)
+	formatter := getFormatter(format, name, colors)
 	lg.SetFormatter(formatter)
 
+	// redaction runs first so the hooks below only see redacted entries
+	redact := newRedactHook(DefaultRedactKeys)
+	lg.AddHook(redact)
+	// dedup is off until configured by New
+	dedup := newDedupHook(lg)
+	lg.AddHook(dedup)
+
 	// ensure span->fields hook is always present so formatters can show span badges
 	lg.AddHook(spanFieldsHook{})
 	// create OTEL hook upfront so it can be wired once exporters are configured
 	otelHook := &otelLogHook{loggerName: name}
 	lg.AddHook(otelHook)
+	flush := newFlushRegistry()
+	lg.ExitFunc = flush.exitAfterFlush
 
 	return &Logger{logrus: lg,
 		tracer:    otel.Tracer(name),
 		name:      name,
 		formatter: format,
 		otelHook:  otelHook,
+		colors:    colors,
+		caller:    true,
+		redact:    redact,
+		dedup:     dedup,
+		flush:     flush,
 	}, nil
 }
 
@@ -74,19 +109,36 @@ func New(opts Options) *Logger {
 		if perr == nil {
 			tmp.SetLevel(lvl)
 		}
+		tmp.AddHook(newRedactHook(redactKeys(opts.RedactKeys)))
 		tmp.AddHook(spanFieldsHook{})
 		hook := &otelLogHook{loggerName: opts.Name}
 		tmp.AddHook(hook)
+		flush := newFlushRegistry()
+		tmp.ExitFunc = flush.exitAfterFlush
 		return &Logger{logrus: tmp,
 			tracer:    otel.Tracer(opts.Name),
 			name:      opts.Name,
 			formatter: opts.Format,
 			otelHook:  hook,
+			caller:    !opts.DisableCaller,
+			flush:     flush,
 		}
 	}
 	if opts.Out != nil {
 		l.logrus.SetOutput(opts.Out)
 	}
+	l.colors = colorEnabled(opts.Color, l.logrus.Out)
+	l.caller = !opts.DisableCaller
+	if opts.RedactKeys != nil {
+		// the hook is already registered first, so swap its keys in place
+		*l.redact = *newRedactHook(opts.RedactKeys)
+	}
+	if opts.DedupInterval > 0 {
+		l.dedup.configure(opts.DedupInterval, opts.DedupBurst)
+	}
+	if f, ok := l.logrus.Formatter.(*OTelAwareTextFormatter); ok {
+		f.EnableColors = l.colors
+	}
 	if opts.Level != "" {
 		if lvl, perr := logrus.ParseLevel(opts.Level); perr == nil {
 			l.logrus.SetLevel(lvl)
@@ -95,12 +147,12 @@ func New(opts Options) *Logger {
 	return l
 }
 
-func getFormatter(format string, name string) logrus.Formatter {
+func getFormatter(format string, name string, colors bool) logrus.Formatter {
 	if format == "json" {
 		return &OTelAwareJSONFormatter{
 			TimestampFormat: time.RFC3339Nano,
 			AppName:         name,
-			ShowTraceIDs:    false,
+			ShowTraceIDs:    true,
 			EnableSpanBadge: true,
 			SpanBadgeText:   "SPAN",
 		}
@@ -108,7 +160,7 @@ func getFormatter(format string, name string) logrus.Formatter {
 
 	return &OTelAwareTextFormatter{
 		TimestampFormat: time.RFC3339,
-		EnableColors:    true,
+		EnableColors:    colors,
 		AppName:         name,
 		ShowTraceIDs:    false,
 		EnableSpanBadge: true,
@@ -125,7 +177,7 @@ func (l *Logger) SetAppNameSlog(name string) {
 	} else if f, ok := l.logrus.Formatter.(*OTelAwareJSONFormatter); ok {
 		f.AppName = name
 	} else {
-		l.logrus.SetFormatter(getFormatter(l.formatter, name))
+		l.logrus.SetFormatter(getFormatter(l.formatter, name, l.colors))
 	}
 }
 
@@ -212,26 +264,41 @@ func getenvDefault(key, def string) string {
 	return def
 }
 
+// loggerDir is the directory of this package's sources; frames from its
+// non-test files are skipped when looking up the caller.
+var loggerDir = func() string {
+	_, file, _, _ := runtime.Caller(0)
+	return path.Dir(file)
+}()
+
+// getFileName reports the first caller outside this package as
+// "file.go:line:func", whichever helper built the entry.
 func getFileName() string {
-	// skip 3 frames to point to the caller of the logger helper
-	pc, file, line, ok := runtime.Caller(3)
-
-	filename := "unknown"
-	if ok {
-		parts := strings.Split(file, "/")
-		funcName := "unknown"
-		if f := runtime.FuncForPC(pc); f != nil {
+	var pcs [16]uintptr
+	n := runtime.Callers(2, pcs[:])
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		fr, more := frames.Next()
+		if path.Dir(fr.File) != loggerDir || strings.HasSuffix(fr.File, "_test.go") {
 			// return only the last part of the function path
-			fnParts := strings.Split(f.Name(), ".")
-			funcName = fnParts[len(fnParts)-1]
+			fnParts := strings.Split(fr.Function, ".")
+			return fmt.Sprintf("%s:%d:%s", path.Base(fr.File), fr.Line, fnParts[len(fnParts)-1])
+		}
+		if !more {
+			return "unknown"
 		}
-		filename = fmt.Sprintf("%s:%d:%s", parts[len(parts)-1], line, funcName)
 	}
-	return filename
 }
 
 func (l *Logger) fillFields(fields logrus.Fields) logrus.Fields {
-	fields["file"] = getFileName()
+	if l.caller {
+		fields["file"] = getFileName()
+	}
+	for k, v := range l.fields {
+		if _, ok := fields[k]; !ok {
+			fields[k] = v
+		}
+	}
 
 	if f, ok := l.logrus.Formatter.(*OTelAwareJSONFormatter); ok {
 		fields["origin"] = f.AppName
@@ -245,6 +312,21 @@ func (l *Logger) fillFields(fields logrus.Fields) logrus.Fields {
 	return fields
 }
 
+// With returns a child logger that adds fields to every entry it produces,
+// including those from WithContext and the printf helpers. The child shares
+// the logrus instance, tracer and hooks with l; nested calls accumulate and
+// fields passed per entry win over bound ones. A nil l yields nil.
+func (l *Logger) With(fields map[string]any) *Logger {
+	if l == nil {
+		return nil
+	}
+	child := *l
+	child.fields = make(logrus.Fields, len(l.fields)+len(fields))
+	maps.Copy(child.fields, l.fields)
+	maps.Copy(child.fields, fields)
+	return &child
+}
+
 func (l *Logger) Slog() *logrus.Entry {
 	return l.logrus.WithFields(l.fillFields(logrus.Fields{}))
 }
@@ -279,6 +361,34 @@ func (l *Logger) Debugf(format string, args ...any) {
 	l.WithContext(context.Background()).Debugf(format, args...)
 }
 
+// Level returns the current log level name, e.g. "info".
+func (l *Logger) Level() string {
+	return l.logrus.GetLevel().String()
+}
+
+// SetLevel changes the log level at runtime. The change is logged at info
+// while the more verbose of the two levels is active, so it is visible when
+// raising as well as when lowering verbosity.
+func (l *Logger) SetLevel(level string) error {
+	lvl, err := logrus.ParseLevel(strings.TrimSpace(level))
+	if err != nil {
+		return err
+	}
+	old := l.logrus.GetLevel()
+	if lvl == old {
+		return nil
+	}
+	entry := l.logrus.WithFields(logrus.Fields{"from": old.String(), "to": lvl.String()})
+	if lvl > old {
+		l.logrus.SetLevel(lvl)
+		entry.Info("log level changed")
+	} else {
+		entry.Info("log level changed")
+		l.logrus.SetLevel(lvl)
+	}
+	return nil
+}
+
 func (l *Logger) Span(ctx context.Context, name string, attr ...KeyValue) (context.Context, Span) {
 	if l.tracer == nil {
 		l.tracer = otel.Tracer("logger")
@@ -351,124 +461,6 @@ func sanitizeFloat64(f float64) (float64, bool, string) {
 	return f, true, ""
 }
 
-func toKV(key string, v any) (KeyValue, bool) {
-	key = normalizeKey(key)
-
-	switch x := v.(type) {
-	case string:
-		return attribute.String(key, x), true
-	case bool:
-		return attribute.Bool(key, x), true
-
-	case int:
-		return attribute.Int(key, x), true
-	case int8, int16, int32:
-		return attribute.Int(key, int(reflect.ValueOf(x).Int())), true
-	case int64:
-		return attribute.Int64(key, x), true
-
-	case uint, uint8, uint16, uint32:
-		return attribute.Int64(key, int64(reflect.ValueOf(x).Uint())), true
-	case uint64:
-		if x <= math.MaxInt64 {
-			return attribute.Int64(key, int64(x)), true
-		}
-		return attribute.String(key, strconv.FormatUint(x, 10)), true
-	case float32:
-		f2, ok, s := sanitizeFloat64(float64(x))
-		if ok {
-			return attribute.Float64(key, f2), true
-		}
-		return attribute.String(key, s), true
-	case float64:
-		f2, ok, s := sanitizeFloat64(x)
-		if ok {
-			return attribute.Float64(key, f2), true
-		}
-		return attribute.String(key, s), true
-	case time.Time:
-		return attribute.String(key, x.Format(time.RFC3339Nano)), true
-	case time.Duration:
-		return attribute.Int64(key, x.Milliseconds()), true
-	case fmt.Stringer:
-		return attribute.String(key, x.String()), true
-	case error:
-		return attribute.String(key, x.Error()), true
-
-	case []string:
-		return attribute.StringSlice(key, x), true
-	case []bool:
-		return attribute.BoolSlice(key, x), true
-	case []int:
-		return attribute.IntSlice(key, x), true
-	case []int32:
-		tmp := make([]int, len(x))
-		for i, n := range x {
-			tmp[i] = int(n)
-		}
-		return attribute.IntSlice(key, tmp), true
-	case []int64:
-		return attribute.Int64Slice(key, x), true
-	case []float32:
-		tmp := make([]float64, len(x))
-		for i, f := range x {
-			if f2, ok, _ := sanitizeFloat64(float64(f)); ok {
-				tmp[i] = f2
-			} else {
-				ss := make([]string, len(x))
-				for j := range x {
-					ss[j] = fmt.Sprint(x[j])
-				}
-				return attribute.StringSlice(key, ss), true
-			}
-		}
-		return attribute.Float64Slice(key, tmp), true
-	case []float64:
-		tmp := make([]float64, len(x))
-		for i, f := range x {
-			if f2, ok, _ := sanitizeFloat64(f); ok {
-				tmp[i] = f2
-			} else {
-				ss := make([]string, len(x))
-				for j := range x {
-					ss[j] = fmt.Sprint(x[j])
-				}
-				return attribute.StringSlice(key, ss), true
-			}
-		}
-		return attribute.Float64Slice(key, tmp), true
-	case []time.Time:
-		ss := make([]string, len(x))
-		for i := range x {
-			ss[i] = x[i].Format(time.RFC3339Nano)
-		}
-		return attribute.StringSlice(key, ss), true
-
-	case []any:
-		ss := make([]string, len(x))
-		for i := range x {
-			ss[i] = fmt.Sprint(x[i])
-		}
-		return attribute.StringSlice(key, ss), true
-
-	case map[string]any:
-		if b, err := json.Marshal(x); err == nil {
-			return attribute.String(key, string(b)), true
-		}
-		return attribute.String(key, fmt.Sprint(x)), true
-	}
-
-	rv := reflect.ValueOf(v)
-	if rv.Kind() == reflect.Ptr && !rv.IsNil() {
-		return toKV(key, rv.Elem().Interface())
-	}
-
-	if b, err := json.Marshal(v); err == nil {
-		return attribute.String(key, string(b)), true
-	}
-	return attribute.String(key, fmt.Sprint(v)), true
-}
-
 func FlatAttrs(m map[string]any) []string {
 	if len(m) == 0 {
 		return nil
@@ -4,10 +4,17 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
+	"math"
 	"os"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/sirupsen/logrus"
 	"go.opentelemetry.io/otel/trace/noop"
 )
 
@@ -32,3 +39,137 @@ func TestJSONIncludesTraceAndSpan(t *testing.T) {
 		}
 	}
 }
+
+func TestWithBindsFieldsInJSON(t *testing.T) {
+	var buf bytes.Buffer
+	lg := New(Options{Format: "json", Level: "debug", Out: &buf})
+	child := lg.With(map[string]any{"component": "http"})
+	grandchild := child.With(map[string]any{"provider": "bcb", "component": "rates"})
+
+	decode := func() map[string]any {
+		t.Helper()
+		var out map[string]any
+		if err := json.Unmarshal(buf.Bytes(), &out); err != nil {
+			t.Fatalf("failed to unmarshal json log %q: %v", buf.String(), err)
+		}
+		buf.Reset()
+		return out
+	}
+
+	child.Infof("printf helper")
+	if out := decode(); out["component"] != "http" {
+		t.Fatalf("expected component=http, got %v", out)
+	}
+
+	grandchild.WithContext(context.Background()).Info("with context")
+	out := decode()
+	if out["provider"] != "bcb" || out["component"] != "rates" {
+		t.Fatalf("expected accumulated fields with the inner value winning, got %v", out)
+	}
+
+	grandchild.SlogWithFields(context.Background(), map[string]any{"provider": "override"}).Info("per entry")
+	if out := decode(); out["provider"] != "override" {
+		t.Fatalf("expected per-entry fields to win over bound ones, got %v", out)
+	}
+
+	lg.Infof("parent")
+	if out := decode(); out["component"] != nil || out["provider"] != nil {
+		t.Fatalf("parent logger must not see child fields, got %v", out)
+	}
+
+	var nilLogger *Logger
+	if nilLogger.With(map[string]any{"a": 1}) != nil {
+		t.Fatalf("With on a nil logger should return nil")
+	}
+}
+
+type stringerID int
+
+func (s stringerID) String() string { return fmt.Sprintf("id-%d", int(s)) }
+
+func TestJSONFormatterKeepsNativeTypes(t *testing.T) {
+	f := &OTelAwareJSONFormatter{}
+	entry := logrus.NewEntry(logrus.New())
+	entry.Message = "request"
+	entry.Data = logrus.Fields{
+		"status":   200,
+		"duration": 0.012,
+		"cached":   true,
+		"missing":  nil,
+		"err":      errors.New("boom"),
+		"id":       stringerID(7),
+		"timeout":  1500 * time.Millisecond,
+		"pairs":    []string{"USD/BRL", "EUR/BRL"},
+		"fees":     map[string]float64{"USD": 0.5},
+		"nan":      math.NaN(),
+		"fn":       func() {},
+	}
+	b, err := f.Format(entry)
+	if err != nil {
+		t.Fatalf("format: %v", err)
+	}
+	if !bytes.Contains(b, []byte(`"status":200`)) || !bytes.Contains(b, []byte(`"duration":0.012`)) {
+		t.Fatalf("expected numeric status and duration, got %s", b)
+	}
+	var out map[string]any
+	if err := json.Unmarshal(b, &out); err != nil {
+		t.Fatalf("unmarshal %s: %v", b, err)
+	}
+	want := map[string]any{
+		"status":   float64(200),
+		"duration": 0.012,
+		"cached":   true,
+		"missing":  nil,
+		"err":      "boom",
+		"id":       "id-7",
+		"timeout":  "1.5s",
+		"pairs":    []any{"USD/BRL", "EUR/BRL"},
+		"fees":     map[string]any{"USD": 0.5},
+		"nan":      "NaN",
+	}
+	for k, v := range want {
+		if !reflect.DeepEqual(out[k], v) {
+			t.Fatalf("%s = %#v, want %#v (line %s)", k, out[k], v, b)
+		}
+	}
+	if _, ok := out["fn"].(string); !ok {
+		t.Fatalf("expected an unencodable value to be stringified, got %#v", out["fn"])
+	}
+}
+
+func TestFileFieldPointsAtCaller(t *testing.T) {
+	ctx := context.Background()
+	for name, emit := range map[string]func(*Logger){
+		"Slog":           func(l *Logger) { l.Slog().Info("m") },
+		"WithFields":     func(l *Logger) { l.WithFields(logrus.Fields{"k": 1}).Info("m") },
+		"WithContext":    func(l *Logger) { l.WithContext(ctx).Info("m") },
+		"SlogWithFields": func(l *Logger) { l.SlogWithFields(ctx, logrus.Fields{"k": 1}).Info("m") },
+		"Infof":          func(l *Logger) { l.Infof("m") },
+		"Errorf":         func(l *Logger) { l.Errorf("m") },
+		"Debugf":         func(l *Logger) { l.Debugf("m") },
+		"ErrorCtx":       func(l *Logger) { l.ErrorCtx(ctx, errors.New("boom"), "m", nil) },
+		"With":           func(l *Logger) { l.With(map[string]any{"k": 1}).Infof("m") },
+	} {
+		var buf bytes.Buffer
+		emit(New(Options{Format: "json", Level: "debug", Out: &buf}))
+		var out map[string]any
+		if err := json.Unmarshal(buf.Bytes(), &out); err != nil {
+			t.Fatalf("%s: invalid json: %v", name, err)
+		}
+		if file, _ := out["file"].(string); !strings.HasPrefix(file, "logger_json_test.go:") {
+			t.Fatalf("%s: expected file in logger_json_test.go, got %q", name, file)
+		}
+	}
+}
+
+func TestDisableCallerOmitsFile(t *testing.T) {
+	var buf bytes.Buffer
+	New(Options{Format: "json", Level: "info", DisableCaller: true, Out: &buf}).Infof("m")
+	var out map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &out); err != nil {
+		t.Fatalf("invalid json: %v", err)
+	}
+	if _, ok := out["file"]; ok {
+		t.Fatalf("expected no file field, got %v", out["file"])
+	}
+}
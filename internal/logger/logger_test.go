@@ -30,3 +30,41 @@ func TestInfofWithSpanAddsSpanTag(t *testing.T) {
 		t.Fatalf("expected span marker or span_id in log, got: %s", out)
 	}
 }
+
+func TestSetLevel(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(Options{Format: "text", Level: "info", Out: &buf})
+	if err := l.SetLevel("verbose"); err == nil {
+		t.Fatalf("expected an error for an unknown level")
+	}
+	if err := l.SetLevel("debug"); err != nil || l.Level() != "debug" {
+		t.Fatalf("SetLevel(debug): level=%s err=%v", l.Level(), err)
+	}
+	// lowering verbosity is logged before the switch, so it stays visible
+	buf.Reset()
+	if err := l.SetLevel("warn"); err != nil || l.Level() != "warning" {
+		t.Fatalf("SetLevel(warn): level=%s err=%v", l.Level(), err)
+	}
+	if !strings.Contains(buf.String(), "log level changed") {
+		t.Fatalf("expected the change to be logged, got %q", buf.String())
+	}
+}
+
+func TestTextColorsFollowLogColor(t *testing.T) {
+	for _, tc := range []struct {
+		color string
+		want  bool
+	}{
+		{"", false},
+		{"auto", false},
+		{"never", false},
+		{"always", true},
+	} {
+		var buf bytes.Buffer
+		l := New(Options{Format: "text", Level: "info", Color: tc.color, Out: &buf})
+		l.Infof("hello")
+		if got := strings.Contains(buf.String(), "\x1b["); got != tc.want {
+			t.Fatalf("LOG_COLOR=%q: escape sequences = %v, want %v: %q", tc.color, got, tc.want, buf.String())
+		}
+	}
+}
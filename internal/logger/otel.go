@@ -5,22 +5,28 @@ import (
 	"context"
 	"crypto/tls"
 	"crypto/x509"
+	"errors"
 	"fmt"
 	"net"
 	"net/url"
 	"os"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/thiagozs/go-exchange/internal/config"
+	"github.com/thiagozs/go-exchange/internal/version"
 	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
 	otlploggrpc "go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploggrpc"
 	otlpmetricgrpc "go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
 	otlptracegrpc "go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
 	otlptracehttp "go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
 	otellog "go.opentelemetry.io/otel/log"
+	"go.opentelemetry.io/otel/propagation"
 	sdklog "go.opentelemetry.io/otel/sdk/log"
 	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/exemplar"
 	sdkresource "go.opentelemetry.io/otel/sdk/resource"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
@@ -113,11 +119,13 @@ func (lg *Logger) InitTracer(ctx context.Context, collectorURL string, res *sdkr
 
 	bsp := sdktrace.NewBatchSpanProcessor(exporter)
 	tp := sdktrace.NewTracerProvider(
-		sdktrace.WithSampler(sdktrace.AlwaysSample()),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.AlwaysSample())),
 		sdktrace.WithResource(res),
 		sdktrace.WithSpanProcessor(bsp),
 	)
 	otel.SetTracerProvider(tp)
+	setPropagator()
+	lg.RegisterFlusher(tp.ForceFlush)
 
 	shutdown := func(ctx context.Context) error {
 		// give exporter up to 5s to flush
@@ -147,42 +155,30 @@ func (lg *Logger) SetupOTel(ctx context.Context, cfg *config.Config) (func(conte
 		return func(context.Context) error { return nil }, nil, nil
 	}
 
-	// choose endpoint: explicit OTLPEndpoint overrides OTelCollector
-	collector := strings.TrimSpace(cfg.OTLPEndpoint)
-	if collector == "" {
-		collector = strings.TrimSpace(cfg.OTelCollector)
+	// choose endpoints: per-signal overrides, then the shared collector
+	// (custom variables override the standard OTel ones)
+	traces := resolveSignal(cfg, cfg.OTLPTracesEndpoint, cfg.OTLPTracesHeaders, cfg.OTLPTracesProtocol)
+	metrics := resolveSignal(cfg, cfg.OTLPMetricsEndpoint, cfg.OTLPMetricsHeaders, cfg.OTLPMetricsProtocol)
+	logs := resolveSignal(cfg, cfg.OTLPLogsEndpoint, cfg.OTLPLogsHeaders, cfg.OTLPLogsProtocol)
+	var endpoints []string
+	for _, sig := range []otlpSignal{traces, metrics, logs} {
+		if !signalOff(sig.Endpoint) && !slices.Contains(endpoints, sig.Endpoint) {
+			endpoints = append(endpoints, sig.Endpoint)
+		}
 	}
-	if collector == "" {
+	if len(endpoints) == 0 {
 		lg.WithContext(ctx).Debugf("no OTLP collector configured; skipping OTEL setup")
 		return func(context.Context) error { return nil }, nil, nil
 	}
 
-	// parse headers from comma-separated KEY=VALUE pairs
-	headers := map[string]string{}
-	if strings.TrimSpace(cfg.OTLPHeaders) != "" {
-		for part := range strings.SplitSeq(cfg.OTLPHeaders, ",") {
-			parts := strings.SplitN(part, "=", 2)
-			if len(parts) == 2 {
-				headers[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
-			}
-		}
-	}
-
-	res, err := sdkresource.New(ctx,
-		sdkresource.WithAttributes(
-			semconv.ServiceNameKey.String(cfg.AppName),
-			semconv.ServiceVersionKey.String(cfg.AppVersion),
-			semconv.DeploymentEnvironmentKey.String(cfg.AppEnv),
-		),
-	)
+	res, err := otelResource(ctx, cfg)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	// inspect configured endpoint scheme and warn if mismatched with OTLPUseTLS
+	// inspect configured endpoint schemes and warn if mismatched with OTLPUseTLS
 	// parse to detect explicit scheme if present
-	epTrim := strings.TrimSpace(collector)
-	if epTrim != "" {
+	for _, epTrim := range endpoints {
 		if u, perr := url.Parse(epTrim); perr == nil {
 			scheme := strings.ToLower(u.Scheme)
 			if scheme != "" {
@@ -203,47 +199,59 @@ func (lg *Logger) SetupOTel(ctx context.Context, cfg *config.Config) (func(conte
 		return nil, nil, err
 	}
 
-	// Probe collector endpoint to detect protocol (helpful when debugging HTTP/2 frame errors)
-	if probeErr := func() error {
+	// Probe collector endpoints to detect protocol (helpful when debugging
+	// HTTP/2 frame errors) and check each signal's exporter against it.
+	probed := map[string]string{}
+	for _, collector := range endpoints {
 		// perform probe with short timeout
 		ctxp, cancel := context.WithTimeout(ctx, 2*time.Second)
-		defer cancel()
-		proto, err := probeOTLPProtocol(ctxp, strings.TrimSpace(collector), tlsCfg)
+		proto, err := probeOTLPProtocol(ctxp, collector, tlsCfg)
+		cancel()
 		if err != nil {
+			// probe failure is non-fatal; continue but keep debug info
 			lg.WithContext(ctx).Debugf("unable to probe OTLP endpoint protocol: %v", err)
-			return err
+			continue
 		}
 		lg.WithContext(ctx).Infof("detected OTLP collector protocol=%s for endpoint=%s (OTLP_USE_TLS=%t)", proto, collector, cfg.OTLPUseTLS)
-		return nil
-	}(); probeErr != nil {
-		// probe failure is non-fatal; continue but keep debug info
-		lg.WithContext(ctx).Debugf("continuing despite probe error: %v", probeErr)
+		probed[collector] = proto
+	}
+	tracesSwitched := reconcileProbe(ctx, lg, "traces", &traces, probed[traces.Endpoint], cfg.OTLPAutodetect)
+	metricsSwitched := reconcileProbe(ctx, lg, "metrics", &metrics, probed[metrics.Endpoint], cfg.OTLPAutodetect)
+	logsSwitched := reconcileProbe(ctx, lg, "logs", &logs, probed[logs.Endpoint], cfg.OTLPAutodetect)
+
+	sampler, err := traceSampler(cfg)
+	if err != nil {
+		return nil, nil, err
 	}
+	lg.WithContext(ctx).Infof("OTEL trace sampler: %s", sampler.Description())
+	export := exportSettings(cfg)
 
 	// Build trace provider
-	tp, traceShutdown, exporterInfo, err := buildTraceProvider(ctx, collector, headers, tlsCfg, res, lg)
+	tp, traceShutdown, exporterInfo, err := buildTraceProvider(ctx, traces.Endpoint, traces.Protocol, traces.Headers, tlsCfg, export, res, sampler, lg)
 	if err != nil {
 		return nil, nil, err
 	}
+	exporterInfo.ProtocolExplicit = exporterInfo.ProtocolExplicit && !tracesSwitched
 	lg.WithContext(ctx).Infof("OTEL trace exporter configured: %v", exporterInfo)
 
 	// Build metric provider
-	mp, metricShutdown, metricExporterInfo, err := buildMetricProvider(ctx, collector, headers, tlsCfg, res, lg)
+	mp, metricShutdown, metricExporterInfo, err := buildMetricProvider(ctx, metrics.Endpoint, metrics.Protocol, metrics.Headers, tlsCfg, export, res, exemplarFilter(cfg.MetricsExemplars), lg)
 	if err != nil {
 		// try to shutdown trace provider on error
 		_ = traceShutdown(ctx)
 		return nil, nil, err
 	}
+	metricExporterInfo.ProtocolExplicit = metricExporterInfo.ProtocolExplicit && !metricsSwitched
 	lg.WithContext(ctx).Infof("OTEL metric exporter configured: %v", metricExporterInfo)
 
 	// Build logger provider
-	logProvider, logShutdown, logExporterInfo, err := buildLoggerProvider(ctx, collector, headers, tlsCfg, res)
+	logProvider, logShutdown, logExporterInfo, err := buildLoggerProvider(ctx, logs.Endpoint, logs.Protocol, logs.Headers, tlsCfg, export, res, lg)
 	if err != nil {
 		_ = traceShutdown(ctx)
 		_ = metricShutdown(ctx)
 		return nil, nil, err
 	}
-	if lg.otelHook != nil && logProvider != nil {
+	if lg.otelHook != nil && logProvider != nil && !signalOff(logs.Endpoint) {
 		var opts []otellog.LoggerOption
 		if cfg != nil {
 			if v := strings.TrimSpace(cfg.AppVersion); v != "" {
@@ -252,6 +260,7 @@ func (lg *Logger) SetupOTel(ctx context.Context, cfg *config.Config) (func(conte
 		}
 		lg.otelHook.setEmitter(logProvider.Logger(lg.name, opts...))
 	}
+	logExporterInfo.ProtocolExplicit = logExporterInfo.ProtocolExplicit && !logsSwitched
 	lg.WithContext(ctx).Infof("OTEL log exporter configured: %v", logExporterInfo)
 
 	// set global providers (traces + metrics). Setting a global logger provider
@@ -259,6 +268,10 @@ func (lg *Logger) SetupOTel(ctx context.Context, cfg *config.Config) (func(conte
 	// provider available to callers but don't force a global replacement here.
 	otel.SetTracerProvider(tp)
 	otel.SetMeterProvider(mp)
+	setPropagator()
+	lg.RegisterFlusher(func(ctx context.Context) error {
+		return errors.Join(tp.ForceFlush(ctx), mp.ForceFlush(ctx), logProvider.ForceFlush(ctx))
+	})
 	lg.WithContext(ctx).Debugf("logger provider created and wired into logrus hook")
 
 	// composite shutdown
@@ -282,33 +295,84 @@ func (lg *Logger) SetupOTel(ctx context.Context, cfg *config.Config) (func(conte
 	return shutdown, infos, nil
 }
 
+// otelResource builds the SDK resource from the OTEL_RESOURCE_DETECTORS
+// detectors and the service identification in cfg. Later values win on
+// conflicting keys: detected attributes, then the service.instance.id
+// default (hostname-pid), then OTEL_RESOURCE_ATTRIBUTES, then the APP_*
+// values. A detector that only partially succeeds does not fail setup.
+func otelResource(ctx context.Context, cfg *config.Config) (*sdkresource.Resource, error) {
+	info := version.Get(cfg)
+	attrs := append([]attribute.KeyValue{semconv.ServiceInstanceIDKey.String(serviceInstanceID())},
+		resourceAttributes(cfg.OTelResourceAttributes)...)
+	attrs = append(attrs,
+		semconv.ServiceNameKey.String(cfg.AppName),
+		semconv.ServiceVersionKey.String(info.Version),
+		semconv.DeploymentEnvironmentKey.String(cfg.AppEnv),
+		attribute.String("service.commit", info.Commit),
+	)
+	opts := append(resourceDetectors(cfg.OTelResourceDetectors), sdkresource.WithAttributes(attrs...))
+	res, err := sdkresource.New(ctx, opts...)
+	if errors.Is(err, sdkresource.ErrPartialResource) {
+		return res, nil
+	}
+	return res, err
+}
+
 // ExporterInfo contains simple metadata about created exporters.
 type ExporterInfo struct {
 	Type     string
 	Endpoint string
-	Insecure bool
-	Headers  map[string]string
+	// Protocol is the transport scheme ("grpc" or "http") the endpoint was
+	// resolved to; ProtocolExplicit reports whether it came from
+	// configuration (OTLP_*PROTOCOL, OTEL_EXPORTER_OTLP_PROTOCOL) rather than
+	// scheme/port detection or the OTLP_AUTODETECT probe.
+	Protocol         string
+	ProtocolExplicit bool
+	Insecure         bool
+	Headers          map[string]string
+	// Compression is the exporter compression ("gzip" or "none").
+	Compression string
+	// Sampler describes the trace sampler; set on the trace exporter only.
+	Sampler string
 }
 
-func buildTraceProvider(ctx context.Context, endpoint string, headers map[string]string, tlsCfg *tls.Config, res *sdkresource.Resource, lg *Logger) (*sdktrace.TracerProvider, func(context.Context) error, ExporterInfo, error) {
-	// detect scheme/endpoint similar to existing logic
+// resolveOTLPScheme splits endpoint into the exporter scheme ("grpc" or
+// "http") and host:port. An explicit protocol is used as is and only the
+// host:port is taken from endpoint. Otherwise the scheme comes from the URL,
+// or for scheme-less endpoints from the default ports (4317 grpc, 4318 http)
+// falling back to fallback; grpcPortWins treats http:// URLs on 4317 as gRPC.
+func resolveOTLPScheme(endpoint, protocol, fallback string, grpcPortWins bool) (scheme, ep string, err error) {
 	trimmed := strings.TrimSpace(endpoint)
+	if protocol != "" {
+		if strings.Contains(trimmed, "://") {
+			u, err := url.Parse(trimmed)
+			if err != nil {
+				return "", "", err
+			}
+			return protocol, u.Host, nil
+		}
+		host, _, _ := strings.Cut(trimmed, "/")
+		return protocol, host, nil
+	}
+
 	u, err := url.Parse(trimmed)
 	if err != nil {
-		return nil, nil, ExporterInfo{}, err
+		return "", "", err
 	}
-	scheme := strings.ToLower(u.Scheme)
-	var ep string
+	scheme = strings.ToLower(u.Scheme)
 	if scheme == "" {
 		if u.Host != "" {
 			ep = u.Host
 		} else {
 			ep = u.Path
 		}
-		if strings.HasSuffix(ep, ":4317") {
-			scheme = "grpc"
-		} else {
+		switch {
+		case strings.HasSuffix(ep, ":4318"):
 			scheme = "http"
+		case strings.HasSuffix(ep, ":4317"):
+			scheme = "grpc"
+		default:
+			scheme = fallback
 		}
 	} else {
 		ep = u.Host
@@ -317,9 +381,24 @@ func buildTraceProvider(ctx context.Context, endpoint string, headers map[string
 	// If user provided an explicit http:// URL but used the gRPC port (4317),
 	// prefer gRPC to avoid sending HTTP/1.1 POSTs to a gRPC server which will
 	// respond with malformed bytes (observed as http2 frame errors).
-	if scheme == "http" && strings.HasSuffix(ep, ":4317") {
+	if grpcPortWins && scheme == "http" && strings.HasSuffix(ep, ":4317") {
 		scheme = "grpc"
 	}
+	return scheme, ep, nil
+}
+
+// buildTraceProvider creates the span exporter. A non-empty protocol ("grpc"
+// or "http") is used as is; otherwise it is detected from the endpoint.
+func buildTraceProvider(ctx context.Context, endpoint, protocol string, headers map[string]string, tlsCfg *tls.Config, export otlpExport, res *sdkresource.Resource, sampler sdktrace.Sampler, lg *Logger) (*sdktrace.TracerProvider, func(context.Context) error, ExporterInfo, error) {
+	if signalOff(endpoint) {
+		tp := sdktrace.NewTracerProvider(sdktrace.WithResource(res))
+		return tp, tp.Shutdown, ExporterInfo{Type: "disabled", Endpoint: endpoint}, nil
+	}
+	scheme, ep, err := resolveOTLPScheme(endpoint, protocol, "http", true)
+	if err != nil {
+		return nil, nil, ExporterInfo{}, err
+	}
+	explicit := protocol != ""
 
 	// prepare exporter options
 	var exporter sdktrace.SpanExporter
@@ -334,12 +413,15 @@ func buildTraceProvider(ctx context.Context, endpoint string, headers map[string
 		if len(headers) > 0 {
 			opts = append(opts, otlptracegrpc.WithHeaders(headers))
 		}
+		if export.gzip() {
+			opts = append(opts, otlptracegrpc.WithCompressor(config.OTLPCompressionGzip))
+		}
 		lg.WithContext(ctx).Debugf("creating otlp grpc trace exporter endpoint=%s useTLS=%t headers=%v", ep, tlsCfg != nil, headers)
 		exporter, err = otlptracegrpc.New(ctx, opts...)
 		if err != nil {
 			return nil, nil, ExporterInfo{}, err
 		}
-		exporterInfo = ExporterInfo{Type: "otlp-grpc", Endpoint: ep, Insecure: tlsCfg == nil, Headers: headers}
+		exporterInfo = ExporterInfo{Type: "otlp-grpc", Endpoint: ep, Protocol: scheme, ProtocolExplicit: explicit, Insecure: tlsCfg == nil, Headers: headers}
 	} else {
 		httpOpts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(ep)}
 		if tlsCfg == nil {
@@ -350,56 +432,50 @@ func buildTraceProvider(ctx context.Context, endpoint string, headers map[string
 		if len(headers) > 0 {
 			httpOpts = append(httpOpts, otlptracehttp.WithHeaders(headers))
 		}
+		if export.gzip() {
+			httpOpts = append(httpOpts, otlptracehttp.WithCompression(otlptracehttp.GzipCompression))
+		}
 		lg.WithContext(ctx).Debugf("creating otlp http trace exporter endpoint=%s useTLS=%t headers=%v", ep, tlsCfg != nil, headers)
 		exporter, err = otlptracehttp.New(ctx, httpOpts...)
 		if err != nil {
 			return nil, nil, ExporterInfo{}, err
 		}
-		exporterInfo = ExporterInfo{Type: "otlp-http", Endpoint: ep, Insecure: tlsCfg == nil, Headers: headers}
+		exporterInfo = ExporterInfo{Type: "otlp-http", Endpoint: ep, Protocol: scheme, ProtocolExplicit: explicit, Insecure: tlsCfg == nil, Headers: headers}
 	}
 
 	tp := sdktrace.NewTracerProvider(
-		sdktrace.WithSampler(sdktrace.AlwaysSample()),
+		sdktrace.WithSampler(sampler),
 		sdktrace.WithResource(res),
-		sdktrace.WithSpanProcessor(sdktrace.NewBatchSpanProcessor(exporter)),
+		sdktrace.WithSpanProcessor(sdktrace.NewBatchSpanProcessor(exporter, export.spanOptions()...)),
 	)
+	exporterInfo.Compression = export.compression()
+	exporterInfo.Sampler = sampler.Description()
 
 	shutdown := func(ctx context.Context) error { return tp.Shutdown(ctx) }
 	return tp, shutdown, exporterInfo, nil
 }
 
-func buildMetricProvider(ctx context.Context, endpoint string, headers map[string]string, tlsCfg *tls.Config, res *sdkresource.Resource, lg *Logger) (*sdkmetric.MeterProvider, func(context.Context) error, ExporterInfo, error) {
-	// parse endpoint to avoid passing URLs (like http://host:4318) to gRPC exporters
-	trimmed := strings.TrimSpace(endpoint)
-	u, err := url.Parse(trimmed)
-	if err != nil {
-		return nil, nil, ExporterInfo{}, err
+// exemplarFilter selects trace-based exemplars when enabled; otherwise
+// exemplars are never recorded.
+func exemplarFilter(enabled bool) exemplar.Filter {
+	if enabled {
+		return exemplar.TraceBasedFilter
 	}
-	scheme := strings.ToLower(u.Scheme)
-	var ep string
-	if scheme == "" {
-		if u.Host != "" {
-			ep = u.Host
-		} else {
-			ep = u.Path
-		}
-		// if no scheme, detect common ports
-		if strings.HasSuffix(ep, ":4318") {
-			scheme = "http"
-		} else if strings.HasSuffix(ep, ":4317") {
-			scheme = "grpc"
-		} else {
-			// default to grpc
-			scheme = "grpc"
-		}
-	} else {
-		ep = u.Host
+	return exemplar.AlwaysOffFilter
+}
+
+func buildMetricProvider(ctx context.Context, endpoint, protocol string, headers map[string]string, tlsCfg *tls.Config, export otlpExport, res *sdkresource.Resource, filter exemplar.Filter, lg *Logger) (*sdkmetric.MeterProvider, func(context.Context) error, ExporterInfo, error) {
+	if signalOff(endpoint) {
+		mp := sdkmetric.NewMeterProvider()
+		return mp, mp.Shutdown, ExporterInfo{Type: "disabled", Endpoint: endpoint}, nil
 	}
 
-	// If an explicit http:// URL uses the gRPC port, treat it as grpc.
-	if scheme == "http" && strings.HasSuffix(ep, ":4317") {
-		scheme = "grpc"
+	// parse endpoint to avoid passing URLs (like http://host:4318) to gRPC exporters
+	scheme, ep, err := resolveOTLPScheme(endpoint, protocol, "grpc", true)
+	if err != nil {
+		return nil, nil, ExporterInfo{}, err
 	}
+	explicit := protocol != ""
 
 	// If the endpoint scheme resolves to HTTP (explicit http:// or port 4318),
 	// it's likely the collector expects OTLP/HTTP (HTTP/1.1) and the metric
@@ -412,7 +488,7 @@ func buildMetricProvider(ctx context.Context, endpoint string, headers map[strin
 		}
 		mp := sdkmetric.NewMeterProvider()
 		shutdown := func(context.Context) error { return nil }
-		return mp, shutdown, ExporterInfo{Type: "disabled", Endpoint: ep, Insecure: tlsCfg == nil, Headers: headers}, nil
+		return mp, shutdown, ExporterInfo{Type: "disabled", Endpoint: ep, Protocol: scheme, ProtocolExplicit: explicit, Insecure: tlsCfg == nil, Headers: headers}, nil
 	}
 
 	// minimal metric exporter using otlpmetricgrpc
@@ -425,39 +501,45 @@ func buildMetricProvider(ctx context.Context, endpoint string, headers map[strin
 	if len(headers) > 0 {
 		opts = append(opts, otlpmetricgrpc.WithHeaders(headers))
 	}
+	if export.gzip() {
+		opts = append(opts, otlpmetricgrpc.WithCompressor(config.OTLPCompressionGzip))
+	}
 	exp, err := otlpmetricgrpc.New(ctx, opts...)
 	if err != nil {
 		return nil, nil, ExporterInfo{}, err
 	}
-	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(sdkmetric.NewPeriodicReader(exp)), sdkmetric.WithResource(res))
+	mp := sdkmetric.NewMeterProvider(
+		sdkmetric.WithReader(sdkmetric.NewPeriodicReader(exp)),
+		sdkmetric.WithResource(res),
+		sdkmetric.WithExemplarFilter(filter),
+	)
 	shutdown := func(ctx context.Context) error { return mp.Shutdown(ctx) }
-	return mp, shutdown, ExporterInfo{Type: "otlp-metric-grpc", Endpoint: ep, Insecure: tlsCfg == nil, Headers: headers}, nil
+	return mp, shutdown, ExporterInfo{Type: "otlp-metric-grpc", Endpoint: ep, Protocol: scheme, ProtocolExplicit: explicit, Insecure: tlsCfg == nil, Headers: headers, Compression: export.compression()}, nil
 }
 
-func buildLoggerProvider(ctx context.Context, endpoint string, headers map[string]string, tlsCfg *tls.Config, res *sdkresource.Resource) (*sdklog.LoggerProvider, func(context.Context) error, ExporterInfo, error) {
+func buildLoggerProvider(ctx context.Context, endpoint, protocol string, headers map[string]string, tlsCfg *tls.Config, export otlpExport, res *sdkresource.Resource, lg *Logger) (*sdklog.LoggerProvider, func(context.Context) error, ExporterInfo, error) {
+	if signalOff(endpoint) {
+		lp := sdklog.NewLoggerProvider()
+		return lp, lp.Shutdown, ExporterInfo{Type: "disabled", Endpoint: endpoint}, nil
+	}
+
 	// parse endpoint similar to metric builder
-	trimmed := strings.TrimSpace(endpoint)
-	u, err := url.Parse(trimmed)
+	scheme, ep, err := resolveOTLPScheme(endpoint, protocol, "grpc", false)
 	if err != nil {
 		return nil, nil, ExporterInfo{}, err
 	}
-	scheme := strings.ToLower(u.Scheme)
-	var ep string
-	if scheme == "" {
-		if u.Host != "" {
-			ep = u.Host
-		} else {
-			ep = u.Path
-		}
-		if strings.HasSuffix(ep, ":4318") {
-			scheme = "http"
-		} else if strings.HasSuffix(ep, ":4317") {
-			scheme = "grpc"
-		} else {
-			scheme = "grpc"
+	explicit := protocol != ""
+
+	// Like metrics, logs are only exported over gRPC; an explicit http
+	// protocol disables the exporter instead of sending gRPC to an HTTP
+	// collector. A detected http scheme keeps the gRPC exporter.
+	if explicit && scheme == "http" {
+		if lg != nil {
+			lg.WithContext(ctx).Warnf("OTLP log exporter disabled because OTLP protocol is http (logs require gRPC); endpoint=%s", endpoint)
 		}
-	} else {
-		ep = u.Host
+		lp := sdklog.NewLoggerProvider()
+		shutdown := func(context.Context) error { return nil }
+		return lp, shutdown, ExporterInfo{Type: "disabled", Endpoint: ep, Protocol: scheme, ProtocolExplicit: explicit, Insecure: tlsCfg == nil, Headers: headers}, nil
 	}
 
 	opts := []otlploggrpc.Option{otlploggrpc.WithEndpoint(ep)}
@@ -469,13 +551,16 @@ func buildLoggerProvider(ctx context.Context, endpoint string, headers map[strin
 	if len(headers) > 0 {
 		opts = append(opts, otlploggrpc.WithHeaders(headers))
 	}
+	if export.gzip() {
+		opts = append(opts, otlploggrpc.WithCompressor(config.OTLPCompressionGzip))
+	}
 	exp, err := otlploggrpc.New(ctx, opts...)
 	if err != nil {
 		return nil, nil, ExporterInfo{}, err
 	}
-	lp := sdklog.NewLoggerProvider(sdklog.WithProcessor(sdklog.NewBatchProcessor(exp)), sdklog.WithResource(res))
+	lp := sdklog.NewLoggerProvider(sdklog.WithProcessor(sdklog.NewBatchProcessor(exp, export.logOptions()...)), sdklog.WithResource(res))
 	shutdown := func(ctx context.Context) error { return lp.Shutdown(ctx) }
-	return lp, shutdown, ExporterInfo{Type: "otlp-log-grpc", Endpoint: ep, Insecure: tlsCfg == nil, Headers: headers}, nil
+	return lp, shutdown, ExporterInfo{Type: "otlp-log-grpc", Endpoint: ep, Protocol: "grpc", ProtocolExplicit: explicit, Insecure: tlsCfg == nil, Headers: headers, Compression: export.compression()}, nil
 }
 
 // buildTLSConfig reads TLS-related file paths from cfg and returns a configured *tls.Config
@@ -513,13 +598,21 @@ func buildTLSConfig(cfg *config.Config) (*tls.Config, error) {
 	return tlsCfg, nil
 }
 
+// Probe classifications returned by probeOTLPProtocol.
+const (
+	probeHTTP1   = "http1"
+	probeHTTP2   = "http2"
+	probeUnknown = "unknown"
+)
+
 // probeOTLPProtocol attempts a lightweight probe to infer whether the collector
-// endpoint speaks HTTP/1.1 (OTLP/HTTP) or HTTP/2 (gRPC). It returns "http1"
-// or "http2" on success. The probe is heuristic: it connects to common OTLP
+// endpoint speaks HTTP/1.1 (OTLP/HTTP) or HTTP/2 (gRPC). It returns "http1",
+// "http2" or "unknown". The probe is heuristic: it connects to common OTLP
 // ports (4317, 4318) when no port is provided, sends the HTTP/2 client preface
-// and inspects the server's immediate response. If the server responds with
-// an HTTP/1.x header the probe classifies it as http1; otherwise http2 is
-// assumed.
+// and inspects the server's immediate response. An HTTP/1.x status line means
+// http1, an HTTP/2 SETTINGS frame means http2, and silence, a closed
+// connection or anything else is unknown. An error is returned only when no
+// candidate address could be reached.
 func probeOTLPProtocol(ctx context.Context, endpoint string, tlsCfg *tls.Config) (string, error) {
 	trimmed := strings.TrimSpace(endpoint)
 	if trimmed == "" {
@@ -545,6 +638,10 @@ func probeOTLPProtocol(ctx context.Context, endpoint string, tlsCfg *tls.Config)
 	}
 
 	preface := []byte("PRI * HTTP/2.0\r\n\r\nSM\r\n\r\n")
+	deadline := time.Now().Add(1500 * time.Millisecond)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
 
 	var lastErr error
 	for _, addr := range candidates {
@@ -554,63 +651,96 @@ func probeOTLPProtocol(ctx context.Context, endpoint string, tlsCfg *tls.Config)
 			lastErr = err
 			continue
 		}
-		// ensure close
-		func() {
+		result, err := func() (string, error) {
 			defer conn.Close()
 			// wrap with TLS if provided
 			var c net.Conn = conn
 			if tlsCfg != nil {
 				tc := tls.Client(conn, tlsCfg)
-				if err := tc.Handshake(); err != nil {
-					lastErr = err
-					return
+				if err := tc.HandshakeContext(ctx); err != nil {
+					return "", err
 				}
 				c = tc
 			}
 			// write HTTP/2 preface
-			c.SetDeadline(time.Now().Add(1500 * time.Millisecond))
+			_ = c.SetDeadline(deadline)
 			if _, err := c.Write(preface); err != nil {
-				lastErr = err
-				return
+				return "", err
 			}
 			buf := make([]byte, 1024)
 			n, err := c.Read(buf)
-			if err != nil {
-				// read timeout or EOF likely means no HTTP/1 response; assume http2
-				lastErr = nil
-				return
-			}
-			data := buf[:n]
-			if bytes.Contains(data, []byte("HTTP/1.")) || bytes.HasPrefix(data, []byte("HTTP/")) {
-				lastErr = nil
-				// detected HTTP/1.x
-				host = addr // avoid unused warning
-				// return classification immediately by setting lastErr to nil and
-				// using a sentinel via panic is unnecessary; instead set a result
-				// by returning via outer scope after closing connection.
-				// we'll set lastErr==nil and use a special marker by returning
-				// using a named return value via closure is not possible; instead
-				// set a variable on outer scope and use it.
-				// To keep this simple, we'll treat this as a success and return
-				// http1 now by using a short-living goroutine; but in this
-				// non-parallel code we can just set lastErr=nil and set a
-				// workaround: close connection and set lastErr==nil and then
-				// return after loop by checking if data indicates http1.
-				// For simplicity, store detection in lastErr==nil and break.
+			if n == 0 && err != nil {
+				// timeout or EOF: the server did not answer either way
+				return probeUnknown, nil
 			}
+			return classifyProbeResponse(buf[:n]), nil
 		}()
-		// If lastErr is nil after probe, we assume http2 unless we detected http1 above.
-		// Since we didn't store explicit http1 marker, inspect quickly by attempting a
-		// cheap TCP read next iteration — to keep code maintainable return http2 when
-		// no explicit HTTP/1 detected.
-		if lastErr == nil {
-			return "http2", nil
+		if err != nil {
+			lastErr = err
+			continue
 		}
+		return result, nil
 	}
-	if lastErr != nil {
-		return "", lastErr
+	return "", lastErr
+}
+
+// classifyProbeResponse classifies the first bytes a server sent back after
+// the HTTP/2 client preface.
+func classifyProbeResponse(data []byte) string {
+	if bytes.HasPrefix(data, []byte("HTTP/1.")) {
+		return probeHTTP1
 	}
-	return "http2", nil
+	// HTTP/2 frame header: 3-byte length, 1-byte type (0x4 = SETTINGS), ...
+	if len(data) >= 9 && data[3] == 0x4 {
+		return probeHTTP2
+	}
+	return probeUnknown
+}
+
+// exporterScheme returns the transport ("grpc" or "http") the builder of the
+// given signal will use for sig.
+func exporterScheme(signal string, sig otlpSignal) string {
+	var scheme string
+	switch signal {
+	case "traces":
+		scheme, _, _ = resolveOTLPScheme(sig.Endpoint, sig.Protocol, "http", true)
+	case "metrics":
+		scheme, _, _ = resolveOTLPScheme(sig.Endpoint, sig.Protocol, "grpc", true)
+	default:
+		// the log exporter is gRPC unless http is set explicitly
+		scheme = "grpc"
+		if sig.Protocol == "http" {
+			scheme = "http"
+		}
+	}
+	return scheme
+}
+
+// reconcileProbe compares the probed transport of a signal's endpoint with the
+// exporter it would use. On a mismatch it warns or, when autodetect is on and
+// the protocol was not configured explicitly, switches the signal to the
+// probed protocol; switched reports the latter.
+func reconcileProbe(ctx context.Context, lg *Logger, signal string, sig *otlpSignal, probed string, autodetect bool) (switched bool) {
+	var want string
+	switch probed {
+	case probeHTTP1:
+		want = "http"
+	case probeHTTP2:
+		want = "grpc"
+	default:
+		return false
+	}
+	have := exporterScheme(signal, *sig)
+	if have == want {
+		return false
+	}
+	if autodetect && sig.Protocol == "" {
+		lg.WithContext(ctx).Warnf("OTLP %s exporter switched from %s to %s: collector at %s answered %s (OTLP_AUTODETECT=true)", signal, have, want, sig.Endpoint, probed)
+		sig.Protocol = want
+		return true
+	}
+	lg.WithContext(ctx).Warnf("OTLP %s exporter uses %s but collector at %s answered %s; set OTLP_%s_PROTOCOL or OTLP_AUTODETECT=true", signal, have, sig.Endpoint, probed, strings.ToUpper(signal))
+	return false
 }
 
 // StartSpan is a helper to start a span using the global tracer and returns ctx, span
@@ -619,3 +749,9 @@ func (lg *Logger) StartSpan(ctx context.Context, name string) (context.Context,
 	ctx2, span := tracer.Start(ctx, name)
 	return ctx2, func() { span.End() }
 }
+
+// setPropagator installs the W3C trace context and baggage propagators, used
+// to continue incoming traces and to send ours to the services we call.
+func setPropagator() {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+}
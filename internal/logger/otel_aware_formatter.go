@@ -38,6 +38,9 @@ var levelColors = map[logrus.Level]string{
 const resetColor = "\033[0m"
 
 func (f *OTelAwareTextFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	if suppressed(entry) {
+		return nil, nil
+	}
 	var b bytes.Buffer
 
 	ts := entry.Time.Format(f.ts())
@@ -2,6 +2,8 @@ package logger
 
 import (
 	"encoding/json"
+	"fmt"
+	"reflect"
 	"slices"
 	"sort"
 	"strings"
@@ -22,6 +24,9 @@ type OTelAwareJSONFormatter struct {
 }
 
 func (f *OTelAwareJSONFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	if suppressed(entry) {
+		return nil, nil
+	}
 	data := make(map[string]any, len(entry.Data)+6)
 
 	tsFmt := f.TimestampFormat
@@ -66,14 +71,17 @@ func (f *OTelAwareJSONFormatter) Format(entry *logrus.Entry) ([]byte, error) {
 	spanID, hasSpan := entry.Data["span_id"]
 
 	if f.ShowTraceIDs {
-		if hasTrace {
+		// skip the empty span_id marker set for noop/non-recording spans
+		if hasTrace && toString(traceID) != "" {
 			data["trace_id"] = toString(traceID)
 		}
 
-		if hasSpan {
+		if hasSpan && toString(spanID) != "" {
 			data["span_id"] = toString(spanID)
 		}
-	} else if f.EnableSpanBadge && (hasTrace || hasSpan) {
+	}
+
+	if f.EnableSpanBadge && (hasTrace || hasSpan) {
 		badge := f.spanBadge()
 		data["span"] = badge
 	}
@@ -93,7 +101,7 @@ func (f *OTelAwareJSONFormatter) Format(entry *logrus.Entry) ([]byte, error) {
 	sort.Strings(keys)
 
 	for _, k := range keys {
-		data[k] = toString(entry.Data[k])
+		data[k] = jsonValue(entry.Data[k])
 	}
 
 	b, err := json.Marshal(data)
@@ -112,3 +120,39 @@ func (f *OTelAwareJSONFormatter) spanBadge() string {
 
 	return f.SpanBadgeText
 }
+
+// jsonValue keeps JSON-native fields (nil, bools, numbers, strings) as they
+// are so that fields like status or duration stay queryable as numbers, and
+// nests maps and slices as JSON. Errors, Stringers, non-finite floats and
+// anything json cannot encode are stringified.
+func jsonValue(v any) any {
+	switch x := v.(type) {
+	case nil, string, bool,
+		int, int8, int16, int32, int64,
+		uint, uint8, uint16, uint32, uint64:
+		return v
+	case float32:
+		if _, ok, s := sanitizeFloat64(float64(x)); !ok {
+			return s
+		}
+		return v
+	case float64:
+		if _, ok, s := sanitizeFloat64(x); !ok {
+			return s
+		}
+		return v
+	case []byte:
+		return string(x)
+	case error:
+		return x.Error()
+	case fmt.Stringer:
+		return x.String()
+	}
+	switch reflect.ValueOf(v).Kind() {
+	case reflect.Map, reflect.Slice, reflect.Array:
+		if b, err := json.Marshal(v); err == nil {
+			return json.RawMessage(b)
+		}
+	}
+	return toString(v)
+}
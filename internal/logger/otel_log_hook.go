@@ -2,10 +2,7 @@ package logger
 
 import (
 	"context"
-	"fmt"
-	"math"
 	"sort"
-	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -39,6 +36,9 @@ func (h *otelLogHook) getEmitter() otellog.Logger {
 }
 
 func (h *otelLogHook) Fire(e *logrus.Entry) error {
+	if suppressed(e) {
+		return nil
+	}
 	ctx := e.Context
 	if ctx == nil {
 		ctx = context.Background()
@@ -68,48 +68,8 @@ func (h *otelLogHook) Fire(e *logrus.Entry) error {
 		if k == "trace_id" || k == "span_id" || k == "logger_name" {
 			continue
 		}
-		v := e.Data[k]
-		switch x := v.(type) {
-		case string:
-			attrs = append(attrs, attribute.String(k, x))
-		case bool:
-			attrs = append(attrs, attribute.Bool(k, x))
-		case int:
-			attrs = append(attrs, attribute.Int(k, x))
-		case int8:
-			attrs = append(attrs, attribute.Int(k, int(x)))
-		case int16:
-			attrs = append(attrs, attribute.Int(k, int(x)))
-		case int32:
-			attrs = append(attrs, attribute.Int(k, int(x)))
-		case int64:
-			attrs = append(attrs, attribute.Int64(k, x))
-		case uint:
-			attrs = append(attrs, attribute.Int64(k, int64(x)))
-		case uint8:
-			attrs = append(attrs, attribute.Int(k, int(x)))
-		case uint16:
-			attrs = append(attrs, attribute.Int(k, int(x)))
-		case uint32:
-			attrs = append(attrs, attribute.Int64(k, int64(x)))
-		case uint64:
-			if x <= math.MaxInt64 {
-				attrs = append(attrs, attribute.Int64(k, int64(x)))
-			} else {
-				attrs = append(attrs, attribute.String(k, strconv.FormatUint(x, 10)))
-			}
-		case float32:
-			attrs = append(attrs, attribute.Float64(k, float64(x)))
-		case float64:
-			attrs = append(attrs, attribute.Float64(k, x))
-		case time.Duration:
-			attrs = append(attrs, attribute.Int64(k, int64(x)))
-		case error:
-			attrs = append(attrs, attribute.String(k, x.Error()))
-		case fmt.Stringer:
-			attrs = append(attrs, attribute.String(k, x.String()))
-		default:
-			attrs = append(attrs, attribute.String(k, fmt.Sprint(v)))
+		if kv, ok := toKV(k, e.Data[k]); ok {
+			attrs = append(attrs, kv)
 		}
 	}
 
@@ -159,56 +119,6 @@ func toLogSeverity(level logrus.Level) otellog.Severity {
 	}
 }
 
-func toLogKeyValue(key string, value any) (otellog.KeyValue, bool) {
-	switch v := value.(type) {
-	case string:
-		return otellog.String(key, v), true
-	case bool:
-		return otellog.Bool(key, v), true
-	case int:
-		return otellog.Int64(key, int64(v)), true
-	case int8:
-		return otellog.Int64(key, int64(v)), true
-	case int16:
-		return otellog.Int64(key, int64(v)), true
-	case int32:
-		return otellog.Int64(key, int64(v)), true
-	case int64:
-		return otellog.Int64(key, v), true
-	case uint:
-		return otellog.Int64(key, int64(v)), true
-	case uint8:
-		return otellog.Int64(key, int64(v)), true
-	case uint16:
-		return otellog.Int64(key, int64(v)), true
-	case uint32:
-		return otellog.Int64(key, int64(v)), true
-	case uint64:
-		if v <= math.MaxInt64 {
-			return otellog.Int64(key, int64(v)), true
-		}
-		return otellog.String(key, strconv.FormatUint(v, 10)), true
-	case float32:
-		return otellog.Float64(key, float64(v)), true
-	case float64:
-		return otellog.Float64(key, v), true
-	case time.Duration:
-		return otellog.Int64(key, int64(v)), true
-	case time.Time:
-		return otellog.String(key, v.Format(time.RFC3339Nano)), true
-	case []byte:
-		return otellog.Bytes(key, append([]byte(nil), v...)), true
-	case fmt.Stringer:
-		return otellog.String(key, v.String()), true
-	case error:
-		return otellog.String(key, v.Error()), true
-	case nil:
-		return otellog.String(key, ""), true
-	default:
-		return otellog.String(key, fmt.Sprint(value)), true
-	}
-}
-
 func sortedKeys(fields logrus.Fields) []string {
 	if len(fields) == 0 {
 		return nil
@@ -130,3 +130,30 @@ func TestOtelLogHookEmitsLogRecord(t *testing.T) {
 		t.Fatalf("expected user_id attribute in exported record")
 	}
 }
+
+func TestOtelLogHookSkipsUnsampledSpan(t *testing.T) {
+	exp := &testExporter{}
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(0))),
+		sdktrace.WithSyncer(exp),
+	)
+	defer func() { _ = tp.Shutdown(context.Background()) }()
+
+	ctx, span := tp.Tracer("test").Start(context.Background(), "unsampled")
+	if span.IsRecording() {
+		t.Fatalf("expected a non-recording span")
+	}
+	entry := logrus.NewEntry(logrus.New()).WithContext(ctx).WithFields(logrus.Fields{"user_id": 42, "pair": "USD/BRL"})
+	entry.Message = "hello"
+	hook := &otelLogHook{loggerName: "test"}
+
+	// no emitter and no recording span: Fire must not build any attributes
+	allocs := testing.AllocsPerRun(100, func() { _ = hook.Fire(entry) })
+	if allocs != 0 {
+		t.Fatalf("expected no allocations for an unsampled span, got %v", allocs)
+	}
+	span.End()
+	if len(exp.events) != 0 {
+		t.Fatalf("expected no exported spans, got %v", exp.events)
+	}
+}
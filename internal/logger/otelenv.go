@@ -0,0 +1,259 @@
+package logger
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/thiagozs/go-exchange/internal/config"
+	"go.opentelemetry.io/otel/attribute"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	sdkresource "go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// otlpEndpoint resolves the collector endpoint: OTLP_ENDPOINT, then
+// OTEL_COLLECTOR_URL, then the standard OTEL_EXPORTER_OTLP_ENDPOINT.
+func otlpEndpoint(cfg *config.Config) string {
+	for _, v := range []string{cfg.OTLPEndpoint, cfg.OTelCollector, cfg.OTelExporterEndpoint} {
+		if v = strings.TrimSpace(v); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// otlpEndpointOff disables a signal when used as its OTLP_<SIGNAL>_ENDPOINT.
+const otlpEndpointOff = "off"
+
+// otlpSignal is the resolved exporter target of one signal.
+type otlpSignal struct {
+	Endpoint string
+	Protocol string
+	Headers  map[string]string
+}
+
+// resolveSignal applies a signal's endpoint, headers and protocol overrides
+// on top of the shared settings.
+func resolveSignal(cfg *config.Config, endpoint, headers, protocol string) otlpSignal {
+	sig := otlpSignal{
+		Endpoint: strings.TrimSpace(endpoint),
+		Protocol: otlpProtocol(cfg, protocol),
+		Headers:  otlpHeaders(cfg),
+	}
+	if sig.Endpoint == "" {
+		sig.Endpoint = otlpEndpoint(cfg)
+	}
+	if strings.TrimSpace(headers) != "" {
+		sig.Headers = config.ParseKeyValues(headers, false)
+	}
+	return sig
+}
+
+// signalOff reports whether a resolved endpoint leaves the signal without an
+// exporter: either nothing is configured or it is explicitly "off".
+func signalOff(endpoint string) bool {
+	return endpoint == "" || strings.EqualFold(endpoint, otlpEndpointOff)
+}
+
+// otlpHeaders resolves the exporter headers: OTLP_HEADERS when set, otherwise
+// the standard OTEL_EXPORTER_OTLP_HEADERS, whose keys and values may be
+// percent-encoded.
+func otlpHeaders(cfg *config.Config) map[string]string {
+	if strings.TrimSpace(cfg.OTLPHeaders) != "" {
+		return config.ParseKeyValues(cfg.OTLPHeaders, false)
+	}
+	return config.ParseKeyValues(cfg.OTelExporterHeaders, true)
+}
+
+// otlpProtocol resolves a signal's transport to the "grpc"/"http" scheme used
+// by the exporter builders: the per-signal value, then OTLP_PROTOCOL, then
+// OTEL_EXPORTER_OTLP_PROTOCOL. Empty means detect from the endpoint.
+func otlpProtocol(cfg *config.Config, signal string) string {
+	for _, v := range []string{signal, cfg.OTLPProtocol, cfg.OTelExporterProtocol} {
+		switch strings.TrimSpace(v) {
+		case config.OTLPProtocolGRPC:
+			return "grpc"
+		case config.OTLPProtocolHTTP:
+			return "http"
+		}
+	}
+	return ""
+}
+
+// resourceAttributes parses OTEL_RESOURCE_ATTRIBUTES (comma-separated
+// percent-encoded key=value pairs); malformed entries are ignored.
+func resourceAttributes(s string) []attribute.KeyValue {
+	kv := config.ParseKeyValues(s, true)
+	attrs := make([]attribute.KeyValue, 0, len(kv))
+	for k, v := range kv {
+		attrs = append(attrs, attribute.String(k, v))
+	}
+	return attrs
+}
+
+// resourceDetectors maps OTEL_RESOURCE_DETECTORS to SDK detector options; nil
+// selects config.DefaultResourceDetectors. The process detector leaves out
+// the command line, which may carry credentials.
+func resourceDetectors(names []string) []sdkresource.Option {
+	if names == nil {
+		names = config.DefaultResourceDetectors
+	}
+	var opts []sdkresource.Option
+	for _, n := range names {
+		switch n {
+		case config.ResourceDetectorEnv:
+			opts = append(opts, sdkresource.WithFromEnv())
+		case config.ResourceDetectorHost:
+			opts = append(opts, sdkresource.WithHost())
+		case config.ResourceDetectorProcess:
+			opts = append(opts,
+				sdkresource.WithProcessPID(),
+				sdkresource.WithProcessExecutableName(),
+				sdkresource.WithProcessExecutablePath(),
+				sdkresource.WithProcessOwner(),
+				sdkresource.WithProcessRuntimeName(),
+				sdkresource.WithProcessRuntimeVersion(),
+				sdkresource.WithProcessRuntimeDescription(),
+			)
+		case config.ResourceDetectorContainer:
+			opts = append(opts, sdkresource.WithContainer())
+		}
+	}
+	return opts
+}
+
+// serviceInstanceID is the default service.instance.id: hostname-pid.
+func serviceInstanceID() string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}
+
+// traceSampler builds the sampler selected by OTEL_TRACES_SAMPLER and
+// OTEL_TRACES_SAMPLER_ARG (default: parent-based, ratio 1).
+func traceSampler(cfg *config.Config) (sdktrace.Sampler, error) {
+	ratio, err := config.SamplerRatio(cfg.OTelTracesSampler, cfg.OTelTracesSamplerArg)
+	if err != nil {
+		return nil, err
+	}
+	switch cfg.OTelTracesSampler {
+	case config.SamplerAlwaysOn:
+		return sdktrace.AlwaysSample(), nil
+	case config.SamplerAlwaysOff:
+		return sdktrace.NeverSample(), nil
+	case config.SamplerTraceIDRatio:
+		return sdktrace.TraceIDRatioBased(ratio), nil
+	case config.SamplerParentBasedAlwaysOn:
+		return sdktrace.ParentBased(sdktrace.AlwaysSample()), nil
+	case config.SamplerParentBasedAlwaysOff:
+		return sdktrace.ParentBased(sdktrace.NeverSample()), nil
+	default:
+		return sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio)), nil
+	}
+}
+
+// otlpExport is the batch processor tuning and exporter compression shared
+// by the signals. Zero values keep the SDK defaults.
+type otlpExport struct {
+	QueueSize      int
+	MaxExportBatch int
+	ExportTimeout  time.Duration
+	Compression    string
+}
+
+// exportSettings reads the OTLP_BATCH_* and OTLP_COMPRESSION settings.
+func exportSettings(cfg *config.Config) otlpExport {
+	return otlpExport{
+		QueueSize:      cfg.OTLPBatchQueueSize,
+		MaxExportBatch: cfg.OTLPBatchMaxExportBatch,
+		ExportTimeout:  cfg.OTLPBatchExportTimeout,
+		Compression:    cfg.OTLPCompression,
+	}
+}
+
+func (e otlpExport) gzip() bool { return e.Compression == config.OTLPCompressionGzip }
+
+// compression names the compression for ExporterInfo.
+func (e otlpExport) compression() string {
+	if e.gzip() {
+		return config.OTLPCompressionGzip
+	}
+	return config.OTLPCompressionNone
+}
+
+// spanOptions tunes the BatchSpanProcessor.
+func (e otlpExport) spanOptions() []sdktrace.BatchSpanProcessorOption {
+	var opts []sdktrace.BatchSpanProcessorOption
+	if e.QueueSize > 0 {
+		opts = append(opts, sdktrace.WithMaxQueueSize(e.QueueSize))
+	}
+	if e.MaxExportBatch > 0 {
+		opts = append(opts, sdktrace.WithMaxExportBatchSize(e.MaxExportBatch))
+	}
+	if e.ExportTimeout > 0 {
+		opts = append(opts, sdktrace.WithExportTimeout(e.ExportTimeout))
+	}
+	return opts
+}
+
+// logOptions tunes the log BatchProcessor.
+func (e otlpExport) logOptions() []sdklog.BatchProcessorOption {
+	var opts []sdklog.BatchProcessorOption
+	if e.QueueSize > 0 {
+		opts = append(opts, sdklog.WithMaxQueueSize(e.QueueSize))
+	}
+	if e.MaxExportBatch > 0 {
+		opts = append(opts, sdklog.WithExportMaxBatchSize(e.MaxExportBatch))
+	}
+	if e.ExportTimeout > 0 {
+		opts = append(opts, sdklog.WithExportTimeout(e.ExportTimeout))
+	}
+	return opts
+}
+
+// PlannedExporters describes the exporters SetupOTel would build for cfg,
+// without building them or probing the collector: OTLP_AUTODETECT may still
+// switch their protocol. It is nil when no collector is configured or
+// ENVIRONMENT skips the OTel setup.
+func PlannedExporters(cfg *config.Config) []ExporterInfo {
+	env := strings.ToLower(strings.TrimSpace(cfg.Environment))
+	if env == "test" || env == "local" {
+		return nil
+	}
+	signals := []struct {
+		name string
+		sig  otlpSignal
+	}{
+		{"traces", resolveSignal(cfg, cfg.OTLPTracesEndpoint, cfg.OTLPTracesHeaders, cfg.OTLPTracesProtocol)},
+		{"metrics", resolveSignal(cfg, cfg.OTLPMetricsEndpoint, cfg.OTLPMetricsHeaders, cfg.OTLPMetricsProtocol)},
+		{"logs", resolveSignal(cfg, cfg.OTLPLogsEndpoint, cfg.OTLPLogsHeaders, cfg.OTLPLogsProtocol)},
+	}
+	var infos []ExporterInfo
+	enabled := false
+	for _, s := range signals {
+		if signalOff(s.sig.Endpoint) {
+			infos = append(infos, ExporterInfo{Type: "disabled", Endpoint: s.sig.Endpoint})
+			continue
+		}
+		enabled = true
+		// like the builders: gRPC, or HTTP for anything else
+		scheme := "http"
+		if exporterScheme(s.name, s.sig) == "grpc" {
+			scheme = "grpc"
+		}
+		_, ep, err := resolveOTLPScheme(s.sig.Endpoint, scheme, scheme, true)
+		if err != nil {
+			ep = s.sig.Endpoint
+		}
+		infos = append(infos, ExporterInfo{Type: "otlp-" + scheme, Endpoint: ep, Protocol: scheme, ProtocolExplicit: s.sig.Protocol != "",
+			Insecure: !cfg.OTLPUseTLS, Headers: s.sig.Headers, Compression: exportSettings(cfg).compression()})
+	}
+	if !enabled {
+		return nil
+	}
+	return infos
+}
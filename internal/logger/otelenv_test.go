@@ -0,0 +1,210 @@
+package logger
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/thiagozs/go-exchange/internal/config"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+func TestOTLPEndpointPrecedence(t *testing.T) {
+	cases := []struct {
+		name                   string
+		custom, collector, std string
+		want                   string
+	}{
+		{"none", "", "", "", ""},
+		{"standard only", "", "", "http://std:4318", "http://std:4318"},
+		{"collector over standard", "", "col:4317", "http://std:4318", "col:4317"},
+		{"custom over all", "custom:4317", "col:4317", "http://std:4318", "custom:4317"},
+		{"blank custom falls back", "  ", "", "http://std:4318", "http://std:4318"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := &config.Config{OTLPEndpoint: tc.custom, OTelCollector: tc.collector, OTelExporterEndpoint: tc.std}
+			if got := otlpEndpoint(cfg); got != tc.want {
+				t.Fatalf("endpoint = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestOTLPHeadersPrecedence(t *testing.T) {
+	cases := []struct {
+		name        string
+		custom, std string
+		want        map[string]string
+	}{
+		{"none", "", "", map[string]string{}},
+		{"standard only, decoded", "", "Authorization=Bearer%20abc,x-tenant=a%2Cb", map[string]string{"Authorization": "Bearer abc", "x-tenant": "a,b"}},
+		{"custom over standard", "X-Api-Key=k", "Authorization=Bearer%20abc", map[string]string{"X-Api-Key": "k"}},
+		{"custom kept verbatim", "X-Api-Key=a%20b", "", map[string]string{"X-Api-Key": "a%20b"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := &config.Config{OTLPHeaders: tc.custom, OTelExporterHeaders: tc.std}
+			if got := otlpHeaders(cfg); !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("headers = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestOTLPProtocolPrecedence(t *testing.T) {
+	cases := []struct {
+		name                string
+		signal, custom, std string
+		want                string
+	}{
+		{"none detects", "", "", "", ""},
+		{"standard only", "", "", "http/protobuf", "http"},
+		{"custom over standard", "", "grpc", "http/protobuf", "grpc"},
+		{"signal over custom", "http/protobuf", "grpc", "grpc", "http"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := &config.Config{OTLPProtocol: tc.custom, OTelExporterProtocol: tc.std}
+			if got := otlpProtocol(cfg, tc.signal); got != tc.want {
+				t.Fatalf("protocol = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestOTelResourceMergesStandardAttributes(t *testing.T) {
+	cfg := &config.Config{
+		AppName:                "go-exchange",
+		AppEnv:                 "staging",
+		OTelResourceAttributes: "service.name=other,team=fx%20core,k8s.namespace.name=prod,broken",
+	}
+	res, err := otelResource(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("resource: %v", err)
+	}
+	want := map[attribute.Key]string{
+		"service.name":           "go-exchange",
+		"deployment.environment": "staging",
+		"team":                   "fx core",
+		"k8s.namespace.name":     "prod",
+	}
+	for k, v := range want {
+		got, ok := res.Set().Value(k)
+		if !ok || got.AsString() != v {
+			t.Fatalf("%s = %q (present=%t), want %q", k, got.AsString(), ok, v)
+		}
+	}
+	if _, ok := res.Set().Value("broken"); ok {
+		t.Fatalf("malformed entry should be ignored")
+	}
+}
+
+func TestSetupOTel_StandardEnvProtocolHTTP(t *testing.T) {
+	l := New(Options{Format: "text", Level: "debug", Name: "test-std"})
+	cfg := &config.Config{
+		AppName:              "test-std",
+		OTelExporterEndpoint: "http://collector:4317",
+		OTelExporterProtocol: config.OTLPProtocolHTTP,
+	}
+	sd, infos, err := l.SetupOTel(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+	defer func() { _ = sd(context.Background()) }()
+	if len(infos) != 3 || infos[0].Type != "otlp-http" || infos[0].Endpoint != "collector:4317" {
+		t.Fatalf("expected http trace exporter on the standard endpoint, got %v", infos)
+	}
+	if infos[1].Type != "disabled" || infos[2].Type != "disabled" {
+		t.Fatalf("expected metric and log exporters disabled for http, got %v", infos)
+	}
+}
+
+func TestResolveSignalOverrides(t *testing.T) {
+	cfg := &config.Config{
+		OTLPEndpoint:       "http://collector:4318",
+		OTLPHeaders:        "X-Shared=1",
+		OTLPTracesEndpoint: "http://tempo:4318",
+		OTLPTracesHeaders:  "X-Scope-OrgID=tempo",
+		OTLPProtocol:       config.OTLPProtocolHTTP,
+	}
+	traces := resolveSignal(cfg, cfg.OTLPTracesEndpoint, cfg.OTLPTracesHeaders, "")
+	if traces.Endpoint != "http://tempo:4318" || !reflect.DeepEqual(traces.Headers, map[string]string{"X-Scope-OrgID": "tempo"}) || traces.Protocol != "http" {
+		t.Fatalf("unexpected traces signal %+v", traces)
+	}
+	metrics := resolveSignal(cfg, "", "", config.OTLPProtocolGRPC)
+	if metrics.Endpoint != "http://collector:4318" || !reflect.DeepEqual(metrics.Headers, map[string]string{"X-Shared": "1"}) || metrics.Protocol != "grpc" {
+		t.Fatalf("unexpected metrics signal %+v", metrics)
+	}
+	if logs := resolveSignal(cfg, "OFF", "", ""); !signalOff(logs.Endpoint) {
+		t.Fatalf("expected logs to be off, got %+v", logs)
+	}
+}
+
+func TestSetupOTel_PerSignalEndpoints(t *testing.T) {
+	l := New(Options{Format: "text", Level: "debug", Name: "test-signals"})
+	cfg := &config.Config{
+		AppName:             "test-signals",
+		OTLPTracesEndpoint:  "http://tempo.invalid:4318",
+		OTLPMetricsEndpoint: "grpc://mimir.invalid:4317",
+		OTLPLogsEndpoint:    "off",
+	}
+	sd, infos, err := l.SetupOTel(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+	defer func() { _ = sd(context.Background()) }()
+	want := []struct{ typ, endpoint string }{
+		{"otlp-http", "tempo.invalid:4318"},
+		{"otlp-metric-grpc", "mimir.invalid:4317"},
+		{"disabled", "off"},
+	}
+	if len(infos) != len(want) {
+		t.Fatalf("expected %d exporter infos, got %v", len(want), infos)
+	}
+	for i, w := range want {
+		if infos[i].Type != w.typ || infos[i].Endpoint != w.endpoint {
+			t.Fatalf("exporter %d: got %s %s, want %s %s", i, infos[i].Type, infos[i].Endpoint, w.typ, w.endpoint)
+		}
+	}
+}
+
+func TestSetupOTel_AllSignalsOffSkips(t *testing.T) {
+	l := New(Options{Format: "text", Level: "debug", Name: "test-off"})
+	cfg := &config.Config{
+		OTLPEndpoint:        "http://collector:4318",
+		OTLPTracesEndpoint:  "off",
+		OTLPMetricsEndpoint: "off",
+		OTLPLogsEndpoint:    "off",
+	}
+	_, infos, err := l.SetupOTel(context.Background(), cfg)
+	if err != nil || len(infos) != 0 {
+		t.Fatalf("expected setup to be skipped, got infos=%v err=%v", infos, err)
+	}
+}
+
+func TestTraceSampler(t *testing.T) {
+	cases := []struct {
+		sampler, arg string
+		want         string
+	}{
+		{"", "", "ParentBased{root:AlwaysOnSampler"},
+		{"parentbased_traceidratio", "0.25", "ParentBased{root:TraceIDRatioBased{0.25}"},
+		{"traceidratio", "0.1", "TraceIDRatioBased{0.1}"},
+		{"always_on", "", "AlwaysOnSampler"},
+		{"always_off", "0.5", "AlwaysOffSampler"},
+	}
+	for _, tc := range cases {
+		s, err := traceSampler(&config.Config{OTelTracesSampler: tc.sampler, OTelTracesSamplerArg: tc.arg})
+		if err != nil {
+			t.Fatalf("%s: %v", tc.sampler, err)
+		}
+		if !strings.HasPrefix(s.Description(), tc.want) {
+			t.Fatalf("%s(%s) = %s, want prefix %s", tc.sampler, tc.arg, s.Description(), tc.want)
+		}
+	}
+	if _, err := traceSampler(&config.Config{OTelTracesSampler: "traceidratio", OTelTracesSamplerArg: "half"}); err == nil {
+		t.Fatalf("expected an error for a non-numeric ratio")
+	}
+}
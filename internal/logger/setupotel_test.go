@@ -1,10 +1,21 @@
 package logger
 
 import (
+	"bytes"
 	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/thiagozs/go-exchange/internal/config"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"google.golang.org/grpc"
 )
 
 func TestSetupOTel_SkipEnvironment(t *testing.T) {
@@ -67,3 +78,264 @@ func TestSetupOTel_Grpc_TLS(t *testing.T) {
 		_ = sd(context.Background())
 	}
 }
+
+func TestResolveOTLPScheme(t *testing.T) {
+	cases := []struct {
+		endpoint, protocol string
+		wantScheme, wantEp string
+	}{
+		// explicit protocol: no port sniffing, any host:port form accepted
+		{"collector:9999", "http", "http", "collector:9999"},
+		{"http://collector:4317", "http", "http", "collector:4317"},
+		{"https://collector:4318/v1", "grpc", "grpc", "collector:4318"},
+		// detection
+		{"http://collector:4318", "", "http", "collector:4318"},
+		{"http://collector:4317", "", "grpc", "collector:4317"},
+		{"grpc://collector:9999", "", "grpc", "collector:9999"},
+	}
+	for _, tc := range cases {
+		scheme, ep, err := resolveOTLPScheme(tc.endpoint, tc.protocol, "grpc", true)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.endpoint, err)
+		}
+		if scheme != tc.wantScheme || ep != tc.wantEp {
+			t.Fatalf("%s (protocol %q): got %s %s, want %s %s", tc.endpoint, tc.protocol, scheme, ep, tc.wantScheme, tc.wantEp)
+		}
+	}
+}
+
+func TestSetupOTel_ExplicitHTTPOnNonStandardPort(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:9999")
+	if err != nil {
+		t.Skipf("port 9999 unavailable: %v", err)
+	}
+	var traces atomic.Int32
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost && r.URL.Path == "/v1/traces" {
+			traces.Add(1)
+		}
+		w.Header().Set("Content-Type", "application/x-protobuf")
+		w.WriteHeader(http.StatusOK)
+	}))
+	srv.Listener = ln
+	srv.Start()
+	defer srv.Close()
+
+	l := New(Options{Format: "text", Level: "debug", Name: "test-9999"})
+	cfg := &config.Config{
+		AppName:      "test-9999",
+		OTLPEndpoint: "127.0.0.1:9999",
+		OTLPProtocol: config.OTLPProtocolHTTP,
+	}
+	sd, infos, err := l.SetupOTel(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+	if len(infos) != 3 {
+		t.Fatalf("expected 3 exporter infos, got %v", infos)
+	}
+	for _, info := range infos {
+		if info.Protocol != "http" || !info.ProtocolExplicit || info.Endpoint != "127.0.0.1:9999" {
+			t.Fatalf("expected explicit http on 127.0.0.1:9999, got %+v", info)
+		}
+	}
+	if infos[0].Type != "otlp-http" {
+		t.Fatalf("expected otlp-http trace exporter, got %+v", infos[0])
+	}
+
+	_, span := otel.Tracer("test").Start(context.Background(), "op")
+	span.End()
+	if err := sd(context.Background()); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+	if traces.Load() == 0 {
+		t.Fatalf("collector on :9999 received no trace export")
+	}
+}
+
+func TestSetupOTel_DetectedProtocolIsReported(t *testing.T) {
+	l := New(Options{Format: "text", Level: "debug", Name: "test-detect"})
+	cfg := &config.Config{AppName: "test-detect", OTLPEndpoint: "http://localhost:4318"}
+	sd, infos, err := l.SetupOTel(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+	defer func() { _ = sd(context.Background()) }()
+	if infos[0].Protocol != "http" || infos[0].ProtocolExplicit {
+		t.Fatalf("expected detected http trace protocol, got %+v", infos[0])
+	}
+	if !strings.HasPrefix(infos[0].Sampler, "ParentBased{") {
+		t.Fatalf("expected the default parent-based sampler in the trace exporter info, got %q", infos[0].Sampler)
+	}
+}
+
+func TestProbeOTLPProtocol(t *testing.T) {
+	ctx := context.Background()
+
+	h1 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer h1.Close()
+	if got, err := probeOTLPProtocol(ctx, h1.URL, nil); err != nil || got != probeHTTP1 {
+		t.Fatalf("plain HTTP server: got %q err=%v, want http1", got, err)
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	gs := grpc.NewServer()
+	go func() { _ = gs.Serve(ln) }()
+	defer gs.Stop()
+	if got, err := probeOTLPProtocol(ctx, "grpc://"+ln.Addr().String(), nil); err != nil || got != probeHTTP2 {
+		t.Fatalf("gRPC server: got %q err=%v, want http2", got, err)
+	}
+
+	silent, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer silent.Close()
+	go func() {
+		for {
+			c, err := silent.Accept()
+			if err != nil {
+				return
+			}
+			defer c.Close()
+		}
+	}()
+	sctx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	if got, err := probeOTLPProtocol(sctx, "grpc://"+silent.Addr().String(), nil); err != nil || got != probeUnknown {
+		t.Fatalf("silent server: got %q err=%v, want unknown", got, err)
+	}
+
+	if _, err := probeOTLPProtocol(ctx, "grpc://"+h1.Listener.Addr().String()+"0", nil); err == nil {
+		t.Fatalf("expected an error for an unreachable endpoint")
+	}
+}
+
+func TestReconcileProbe(t *testing.T) {
+	l := New(Options{Format: "text", Level: "debug", Name: "test-reconcile", Out: &bytes.Buffer{}})
+	ctx := context.Background()
+
+	// detected grpc (port 4317) but the collector answers HTTP/1: warn only
+	sig := otlpSignal{Endpoint: "http://collector:4317"}
+	if reconcileProbe(ctx, l, "traces", &sig, probeHTTP1, false) || sig.Protocol != "" {
+		t.Fatalf("expected no switch without OTLP_AUTODETECT, got %+v", sig)
+	}
+	// autodetect switches
+	if !reconcileProbe(ctx, l, "traces", &sig, probeHTTP1, true) || sig.Protocol != "http" {
+		t.Fatalf("expected a switch to http, got %+v", sig)
+	}
+	// explicit protocol is never overridden
+	sig = otlpSignal{Endpoint: "http://collector:9999", Protocol: "grpc"}
+	if reconcileProbe(ctx, l, "metrics", &sig, probeHTTP1, true) || sig.Protocol != "grpc" {
+		t.Fatalf("expected the explicit protocol to be kept, got %+v", sig)
+	}
+	// matching or unknown results change nothing
+	sig = otlpSignal{Endpoint: "http://collector:4318"}
+	if reconcileProbe(ctx, l, "traces", &sig, probeHTTP1, true) || reconcileProbe(ctx, l, "traces", &sig, probeUnknown, true) {
+		t.Fatalf("expected no switch, got %+v", sig)
+	}
+}
+
+func TestSetupOTel_GzipCompression(t *testing.T) {
+	var gzipped atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/traces" && r.Header.Get("Content-Encoding") == "gzip" {
+			gzipped.Add(1)
+		}
+		w.Header().Set("Content-Type", "application/x-protobuf")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	l := New(Options{Format: "text", Level: "info", Name: "test-gzip", Out: &bytes.Buffer{}})
+	cfg := &config.Config{
+		AppName:         "test-gzip",
+		OTLPEndpoint:    srv.URL,
+		OTLPProtocol:    config.OTLPProtocolHTTP,
+		OTLPCompression: config.OTLPCompressionGzip,
+	}
+	sd, infos, err := l.SetupOTel(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+	if infos[0].Compression != config.OTLPCompressionGzip {
+		t.Fatalf("expected gzip in the trace exporter info, got %+v", infos[0])
+	}
+	_, span := otel.Tracer("test").Start(context.Background(), "op")
+	span.End()
+	if err := sd(context.Background()); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+	if gzipped.Load() == 0 {
+		t.Fatalf("collector received no gzip-encoded trace export")
+	}
+}
+
+func TestOTLPExportSpanOptions(t *testing.T) {
+	apply := func(e otlpExport) sdktrace.BatchSpanProcessorOptions {
+		var o sdktrace.BatchSpanProcessorOptions
+		for _, opt := range e.spanOptions() {
+			opt(&o)
+		}
+		return o
+	}
+	if got := apply(exportSettings(&config.Config{})); got != (sdktrace.BatchSpanProcessorOptions{}) {
+		t.Fatalf("expected no overrides by default, got %+v", got)
+	}
+	got := apply(exportSettings(&config.Config{
+		OTLPBatchQueueSize:      8192,
+		OTLPBatchMaxExportBatch: 1024,
+		OTLPBatchExportTimeout:  10 * time.Second,
+	}))
+	if got.MaxQueueSize != 8192 || got.MaxExportBatchSize != 1024 || got.ExportTimeout != 10*time.Second {
+		t.Fatalf("unexpected processor options %+v", got)
+	}
+	if c := exportSettings(&config.Config{}).compression(); c != config.OTLPCompressionNone {
+		t.Fatalf("expected no compression by default, got %q", c)
+	}
+}
+
+func TestOTelResourceDetectsHostAndInstance(t *testing.T) {
+	res, err := otelResource(context.Background(), &config.Config{AppName: "test-res"})
+	if err != nil {
+		t.Fatalf("resource: %v", err)
+	}
+	if v, ok := res.Set().Value("host.name"); !ok || v.AsString() == "" {
+		t.Fatalf("expected host.name in the resource, got %v", res.Attributes())
+	}
+	if v, ok := res.Set().Value("process.pid"); !ok || v.AsInt64() == 0 {
+		t.Fatalf("expected process.pid in the resource, got %v", res.Attributes())
+	}
+	if _, ok := res.Set().Value("process.command_args"); ok {
+		t.Fatalf("command line must not be exported")
+	}
+	if v, _ := res.Set().Value("service.instance.id"); v.AsString() != serviceInstanceID() {
+		t.Fatalf("expected default service.instance.id %q, got %q", serviceInstanceID(), v.AsString())
+	}
+}
+
+func TestOTelResourcePrefersConfiguredValues(t *testing.T) {
+	res, err := otelResource(context.Background(), &config.Config{
+		AppName:                "test-res",
+		OTelResourceAttributes: "host.name=configured,service.instance.id=pod-1",
+	})
+	if err != nil {
+		t.Fatalf("resource: %v", err)
+	}
+	for k, want := range map[string]string{"host.name": "configured", "service.instance.id": "pod-1"} {
+		if v, _ := res.Set().Value(attribute.Key(k)); v.AsString() != want {
+			t.Fatalf("%s = %q, want %q", k, v.AsString(), want)
+		}
+	}
+
+	res, err = otelResource(context.Background(), &config.Config{AppName: "test-res", OTelResourceDetectors: []string{}})
+	if err != nil {
+		t.Fatalf("resource: %v", err)
+	}
+	if _, ok := res.Set().Value("host.name"); ok {
+		t.Fatalf("expected no detected attributes with OTEL_RESOURCE_DETECTORS=none")
+	}
+}
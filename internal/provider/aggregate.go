@@ -0,0 +1,160 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/thiagozs/go-exchange/internal/logger"
+)
+
+const nameAggregate = "aggregate"
+
+// Aggregation methods.
+const (
+	AggregateMedian = "median"
+	AggregateMean   = "mean"
+)
+
+// aggregateProbeCents is the amount each source converts to derive its rate,
+// large enough that cent rounding does not distort it.
+const aggregateProbeCents = 1_000_000
+
+// AggregateSource is a named provider queried by AggregateProvider.
+type AggregateSource struct {
+	Name     string
+	Provider Provider
+}
+
+// AggregateProvider converts with the median (or mean) of the rates reported
+// by several providers, queried concurrently. It fails when fewer than quorum
+// sources succeed within the per-source timeout.
+type AggregateProvider struct {
+	log     *logger.Logger
+	sources []AggregateSource
+	quorum  int
+	method  string
+	timeout time.Duration
+}
+
+// NewAggregateProvider constructs an AggregateProvider. A quorum <= 0 means a
+// majority of sources, an unknown method means median and a zero timeout
+// leaves sources bounded only by the request context.
+func NewAggregateProvider(lg *logger.Logger, sources []AggregateSource, quorum int, method string, timeout time.Duration) *AggregateProvider {
+	lg = lg.With(map[string]any{"provider": "aggregate"})
+	if quorum <= 0 {
+		quorum = len(sources)/2 + 1
+	}
+	if method != AggregateMean {
+		method = AggregateMedian
+	}
+	return &AggregateProvider{log: lg, sources: sources, quorum: quorum, method: method, timeout: timeout}
+}
+
+// sourceRate is one source's answer.
+type sourceRate struct {
+	name string
+	res  ConvertResult
+	err  error
+}
+
+func (a *AggregateProvider) Convert(ctx context.Context, from, to string, amount int64) (int64, error) {
+	res, err := a.ConvertDetailed(ctx, from, to, amount)
+	return res.ResultCents, err
+}
+
+func (a *AggregateProvider) ConvertDetailed(ctx context.Context, from, to string, amount int64) (ConvertResult, error) {
+	answers := make([]sourceRate, len(a.sources))
+	var wg sync.WaitGroup
+	for i, src := range a.sources {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sctx := ctx
+			if a.timeout > 0 {
+				var cancel context.CancelFunc
+				sctx, cancel = context.WithTimeout(ctx, a.timeout)
+				defer cancel()
+			}
+			res, err := convertRate(sctx, src.Provider, from, to)
+			answers[i] = sourceRate{name: src.Name, res: res, err: err}
+		}()
+	}
+	wg.Wait()
+
+	var ok []sourceRate
+	var errs []error
+	for _, ans := range answers {
+		if ans.err != nil {
+			if a.log != nil {
+				a.log.WithContext(ctx).Warnf("aggregate source %s failed for %s->%s: %v", ans.name, from, to, ans.err)
+			}
+			errs = append(errs, fmt.Errorf("%s: %w", ans.name, ans.err))
+			continue
+		}
+		ok = append(ok, ans)
+	}
+	if len(ok) < a.quorum {
+		return ConvertResult{}, fmt.Errorf("aggregate: %d of %d sources succeeded, quorum is %d: %w",
+			len(ok), len(a.sources), a.quorum, errors.Join(errs...))
+	}
+
+	rates := make([]float64, len(ok))
+	out := ConvertResult{Source: nameAggregate, CacheHit: true}
+	for i, ans := range ok {
+		rates[i] = ans.res.Rate
+		out.Sources = append(out.Sources, ans.name)
+		out.Stale = out.Stale || ans.res.Stale
+		out.CacheHit = out.CacheHit && ans.res.CacheHit
+		// report the oldest contributing quote
+		if ts := ans.res.RateTimestamp; !ts.IsZero() && (out.RateTimestamp.IsZero() || ts.Before(out.RateTimestamp)) {
+			out.RateTimestamp = ts
+		}
+	}
+	slices.Sort(rates)
+	out.Spread = rates[len(rates)-1] - rates[0]
+	if a.method == AggregateMean {
+		var sum float64
+		for _, r := range rates {
+			sum += r
+		}
+		out.Rate = sum / float64(len(rates))
+	} else {
+		out.Rate = median(rates)
+	}
+	out.ResultCents = int64(math.Round(float64(amount) * out.Rate))
+	return out, nil
+}
+
+// convertRate asks p for the from->to rate, using the reported rate when p
+// is a DetailedProvider and deriving it from a probe conversion otherwise.
+func convertRate(ctx context.Context, p Provider, from, to string) (ConvertResult, error) {
+	if dp, ok := p.(DetailedProvider); ok {
+		res, err := dp.ConvertDetailed(ctx, from, to, aggregateProbeCents)
+		if err != nil {
+			return ConvertResult{}, err
+		}
+		if res.Rate == 0 {
+			res.Rate = float64(res.ResultCents) / aggregateProbeCents
+		}
+		return res, nil
+	}
+	cents, err := p.Convert(ctx, from, to, aggregateProbeCents)
+	if err != nil {
+		return ConvertResult{}, err
+	}
+	return ConvertResult{ResultCents: cents, Rate: float64(cents) / aggregateProbeCents}, nil
+}
+
+// median returns the median of sorted, non-empty rates.
+func median(sorted []float64) float64 {
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
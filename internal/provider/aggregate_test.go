@@ -0,0 +1,141 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/thiagozs/go-exchange/internal/config"
+)
+
+// rateProv converts at a fixed rate, optionally after a delay or failing.
+type rateProv struct {
+	rate  float64
+	delay time.Duration
+	err   error
+}
+
+func (p *rateProv) Convert(ctx context.Context, from, to string, amount int64) (int64, error) {
+	select {
+	case <-time.After(p.delay):
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	}
+	if p.err != nil {
+		return 0, p.err
+	}
+	return int64(float64(amount) * p.rate), nil
+}
+
+func sources(ps ...Provider) []AggregateSource {
+	out := make([]AggregateSource, len(ps))
+	for i, p := range ps {
+		out[i] = AggregateSource{Name: string(rune('a' + i)), Provider: p}
+	}
+	return out
+}
+
+func TestAggregateProvider(t *testing.T) {
+	down := errors.New("down")
+	cases := []struct {
+		name        string
+		sources     []AggregateSource
+		quorum      int
+		method      string
+		wantCents   int64
+		wantSources []string
+		wantSpread  float64
+		wantErr     bool
+	}{
+		{name: "median of three", sources: sources(&rateProv{rate: 5.0}, &rateProv{rate: 5.5}, &rateProv{rate: 5.2}),
+			wantCents: 520, wantSources: []string{"a", "b", "c"}, wantSpread: 0.5},
+		{name: "median of two is their mean", sources: sources(&rateProv{rate: 5.0}, &rateProv{rate: 5.4}),
+			wantCents: 520, wantSources: []string{"a", "b"}, wantSpread: 0.4},
+		{name: "mean", sources: sources(&rateProv{rate: 5.0}, &rateProv{rate: 5.0}, &rateProv{rate: 5.6}), method: AggregateMean,
+			wantCents: 520, wantSources: []string{"a", "b", "c"}, wantSpread: 0.6},
+		{name: "failure below quorum is tolerated", sources: sources(&rateProv{rate: 5.0}, &rateProv{err: down}, &rateProv{rate: 5.2}),
+			wantCents: 510, wantSources: []string{"a", "c"}, wantSpread: 0.2},
+		{name: "default quorum is a majority", sources: sources(&rateProv{rate: 5.0}, &rateProv{err: down}, &rateProv{err: down}),
+			wantErr: true},
+		{name: "explicit quorum", sources: sources(&rateProv{rate: 5.0}, &rateProv{err: down}, &rateProv{err: down}), quorum: 1,
+			wantCents: 500, wantSources: []string{"a"}},
+		{name: "no sources", wantErr: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			p := NewAggregateProvider(nil, tc.sources, tc.quorum, tc.method, time.Second)
+			res, err := p.ConvertDetailed(context.Background(), "USD", "BRL", 100)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", res)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("convert: %v", err)
+			}
+			if res.ResultCents != tc.wantCents || !slices.Equal(res.Sources, tc.wantSources) ||
+				res.Source != "aggregate" || absDiff(res.Spread, tc.wantSpread) > 1e-9 {
+				t.Fatalf("unexpected result %+v", res)
+			}
+		})
+	}
+}
+
+func absDiff(a, b float64) float64 {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
+
+func TestAggregateProviderSlowSourceDoesNotBlockQuorum(t *testing.T) {
+	p := NewAggregateProvider(nil, sources(&rateProv{rate: 5.0}, &rateProv{rate: 5.2}, &rateProv{rate: 9, delay: time.Minute}),
+		2, "", 50*time.Millisecond)
+	start := time.Now()
+	res, err := p.ConvertDetailed(context.Background(), "USD", "BRL", 100)
+	if err != nil {
+		t.Fatalf("convert: %v", err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Fatalf("slow source blocked the result for %v", d)
+	}
+	if res.ResultCents != 510 || !slices.Equal(res.Sources, []string{"a", "b"}) {
+		t.Fatalf("unexpected result %+v", res)
+	}
+}
+
+func TestAggregateProviderFromConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rates.json")
+	if err := os.WriteFile(path, []byte(`{"USD":{"BRL":5.0}}`), 0o600); err != nil {
+		t.Fatalf("write rates: %v", err)
+	}
+	cfg := &config.Config{Provider: "aggregate", AggregateSources: []string{"static", " bcb"}, StaticRatesPath: path}
+	prov, err := NewProviderFromConfig(cfg, nil, nil)
+	if err != nil {
+		t.Fatalf("new provider: %v", err)
+	}
+	p, ok := prov.(*AggregateProvider)
+	if !ok {
+		t.Fatalf("expected an AggregateProvider")
+	}
+	var names []string
+	for _, s := range p.sources {
+		names = append(names, s.Name)
+	}
+	if !slices.Equal(names, []string{"static", "bcb"}) || p.quorum != 2 || p.method != AggregateMedian {
+		t.Fatalf("unexpected aggregate %v quorum=%d method=%s", names, p.quorum, p.method)
+	}
+}
+
+func TestAggregateProviderRejectsUnknownSource(t *testing.T) {
+	cfg := &config.Config{Provider: "aggregate", AggregateSources: []string{"bcb", "awesomeapi"}}
+	var unknown UnknownProviderError
+	if _, err := NewProviderFromConfig(cfg, nil, nil); !errors.As(err, &unknown) || unknown.Name != "awesomeapi" {
+		t.Fatalf("expected an unknown provider error, got %v", err)
+	}
+}
@@ -4,13 +4,14 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"io"
 	"math"
 	"net/http"
 	"strings"
 	"time"
 
+	"github.com/thiagozs/go-exchange/internal/config"
 	"github.com/thiagozs/go-exchange/internal/logger"
+	"golang.org/x/sync/errgroup"
 )
 
 // BCBProvider queries PTAX endpoints from Central
@@ -18,161 +19,433 @@ import (
 type BCBProvider struct {
 	log         *logger.Logger
 	timeout     time.Duration
-	cache       Cache
+	rates       *rateCache
 	baseURL     string
 	maxRetries  int
 	maxBackDays int
+	clock       *SkewClock
+	// holidays are extra non-business days ("2006-01-02" in São Paulo time)
+	holidays map[string]bool
+	// side is the PTAX rate used: buy (compra), sell (venda) or mid
+	side string
+	// bulletin restricts quotes to one PTAX bulletin, or latest for the most
+	// recent one published
+	bulletin string
+	now      func() time.Time
+	client   *http.Client
+	// bodyLimit caps how much of an upstream body is logged or put in errors
+	bodyLimit int
 }
 
 // NewBCBProvider constructs a new BCBProvider. If baseURL is empty a sensible default is used.
-func NewBCBProvider(lg *logger.Logger, baseURL string, timeout time.Duration, maxRetries, maxBackDays int, c Cache) *BCBProvider {
+// Upstream rates are cached for ratesTTL; zero disables rate caching. Requests
+// go through client's Transport with timeout as the per-request limit; a nil
+// client uses the default transport.
+func NewBCBProvider(lg *logger.Logger, baseURL string, timeout time.Duration, maxRetries, maxBackDays int, c Cache, ratesTTL time.Duration, client *http.Client) *BCBProvider {
+	lg = lg.With(map[string]any{"provider": nameBCB})
 	if baseURL == "" {
-		baseURL = "https://olinda.bcb.gov.br/olinda/servico/PTAX/versao/v1/odata/"
+		baseURL = bcbURL
 	}
-	return &BCBProvider{baseURL: strings.TrimRight(baseURL, "/") + "/", log: lg, timeout: timeout, maxRetries: maxRetries, maxBackDays: maxBackDays, cache: c}
+	bc := &http.Client{Timeout: timeout}
+	if client != nil {
+		// shallow copy: shares the Transport (and its idle connections)
+		cp := *client
+		cp.Timeout = timeout
+		bc = &cp
+	}
+	return &BCBProvider{baseURL: strings.TrimRight(baseURL, "/") + "/", log: lg, timeout: timeout, maxRetries: maxRetries, maxBackDays: maxBackDays,
+		rates: newRateCache(c, lg, 0, ratesTTL), side: BCBSideSell, bulletin: BCBBulletinLatest,
+		now: time.Now, client: bc}
+}
+
+// PTAX rate sides.
+const (
+	BCBSideBuy  = config.BCBSideBuy
+	BCBSideSell = config.BCBSideSell
+	BCBSideMid  = config.BCBSideMid
+)
+
+// PTAX bulletins. BCBBulletinLatest selects the most recent bulletin of the day.
+const (
+	BCBBulletinOpening      = config.BCBBulletinOpening
+	BCBBulletinIntermediate = config.BCBBulletinIntermediate
+	BCBBulletinClosing      = config.BCBBulletinClosing
+	BCBBulletinLatest       = config.BCBBulletinLatest
+)
+
+// ParseBCBRateSide validates a BCB_RATE_SIDE value.
+func ParseBCBRateSide(s string) (string, bool) {
+	switch s = strings.ToLower(strings.TrimSpace(s)); s {
+	case BCBSideBuy, BCBSideSell, BCBSideMid:
+		return s, true
+	}
+	return "", false
+}
+
+// ParseBCBBulletin validates a BCB_BULLETIN value.
+func ParseBCBBulletin(s string) (string, bool) {
+	switch s = normalizeBulletin(s); s {
+	case BCBBulletinOpening, BCBBulletinIntermediate, BCBBulletinClosing, BCBBulletinLatest:
+		return s, true
+	}
+	return "", false
+}
+
+type bcbQuote struct {
+	CotacaoCompra float64 `json:"cotacaoCompra"`
+	CotacaoVenda  float64 `json:"cotacaoVenda"`
+	DataHora      string  `json:"dataHoraCotacao"`
+	TipoBoletim   string  `json:"tipoBoletim"`
 }
 
 type bcbResponse struct {
-	Value []struct {
-		CotacaoCompra float64 `json:"cotacaoCompra"`
-		CotacaoVenda  float64 `json:"cotacaoVenda"`
-		DataHora      string  `json:"dataHoraCotacao"`
-	} `json:"value"`
+	Value []bcbQuote `json:"value"`
+}
+
+// normalizeBulletin lowercases s and drops the accent BCB uses in
+// "Intermediário".
+func normalizeBulletin(s string) string {
+	return strings.ReplaceAll(strings.ToLower(strings.TrimSpace(s)), "á", "a")
+}
+
+// bulletin returns the bulletin a quote belongs to. CotacaoDolarPeriodo has
+// no tipoBoletim and only publishes the closing rate; values such as
+// "Fechamento PTAX" are matched by prefix.
+func (q bcbQuote) bulletin() string {
+	t := normalizeBulletin(q.TipoBoletim)
+	if t == "" {
+		return BCBBulletinClosing
+	}
+	for _, name := range []string{BCBBulletinOpening, BCBBulletinIntermediate, BCBBulletinClosing} {
+		if strings.HasPrefix(t, name) {
+			return name
+		}
+	}
+	return t
+}
+
+// value returns the quote for side.
+func (q bcbQuote) value(side string) float64 {
+	switch side {
+	case BCBSideBuy:
+		return q.CotacaoCompra
+	case BCBSideMid:
+		return (q.CotacaoCompra + q.CotacaoVenda) / 2
+	default:
+		return q.CotacaoVenda
+	}
+}
+
+// selectQuote picks the quote for bulletin from a day's quotes. When several
+// match (intermediate bulletins, or any bulletin for latest) the most recent
+// one wins.
+func selectQuote(quotes []bcbQuote, bulletin string) (bcbQuote, bool) {
+	var best bcbQuote
+	var bestTS time.Time
+	found := false
+	for _, q := range quotes {
+		if bulletin != BCBBulletinLatest && q.bulletin() != bulletin {
+			continue
+		}
+		ts, _ := parseBCBTime(q.DataHora)
+		// on equal or unparsable timestamps later entries win, as BCB lists
+		// bulletins in publication order
+		if !found || !ts.Before(bestTS) {
+			best, bestTS, found = q, ts, true
+		}
+	}
+	return best, found
+}
+
+// bcbZone is the PTAX publication zone (Brasília time, no DST since 2019).
+var bcbZone = time.FixedZone("BRT", -3*60*60)
+
+// saoPaulo is the zone PTAX bulletins are dated in. Without tzdata on the host
+// it falls back to the fixed BRT offset, which matches since DST was dropped.
+var saoPaulo = func() *time.Location {
+	if loc, err := time.LoadLocation("America/Sao_Paulo"); err == nil {
+		return loc
+	}
+	return bcbZone
+}()
+
+// parseBCBHolidays parses "2006-01-02" dates into the set used to skip bank
+// holidays when walking back over business days. Invalid entries are returned
+// in bad.
+func parseBCBHolidays(dates []string) (holidays map[string]bool, bad []string) {
+	holidays = make(map[string]bool, len(dates))
+	for _, d := range dates {
+		d = strings.TrimSpace(d)
+		if d == "" {
+			continue
+		}
+		if _, err := time.Parse(time.DateOnly, d); err != nil {
+			bad = append(bad, d)
+			continue
+		}
+		holidays[d] = true
+	}
+	return holidays, bad
+}
+
+// isBusinessDay reports whether BCB publishes bulletins on day.
+func (b *BCBProvider) isBusinessDay(day time.Time) bool {
+	switch day.Weekday() {
+	case time.Saturday, time.Sunday:
+		return false
+	}
+	return !b.holidays[day.Format(time.DateOnly)]
+}
+
+// businessDays returns today and the previous n business days in São Paulo,
+// most recent first. Today is only included when it is a business day.
+func (b *BCBProvider) businessDays(n int) []time.Time {
+	day := b.now().In(saoPaulo)
+	days := make([]time.Time, 0, n+1)
+	// bound the walk so a misconfigured holiday list cannot loop forever
+	for i := 0; len(days) <= n && i < 366; i++ {
+		if b.isBusinessDay(day) {
+			days = append(days, day)
+		}
+		day = day.AddDate(0, 0, -1)
+	}
+	return days
+}
+
+// parseBCBTime parses dataHoraCotacao values such as "2025-09-19 13:09:27.04".
+func parseBCBTime(s string) (time.Time, bool) {
+	for _, layout := range []string{"2006-01-02 15:04:05.999", "2006-01-02T15:04:05.999"} {
+		if t, err := time.ParseInLocation(layout, strings.TrimSpace(s), bcbZone); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// checkFresh validates the quote timestamp against the skew-corrected clock.
+func (b *BCBProvider) checkFresh(ctx context.Context, dataHora string) error {
+	ts, ok := parseBCBTime(dataHora)
+	if !ok {
+		return nil
+	}
+	b.clock.ObserveRateTime(ctx, ts)
+	return b.clock.CheckFresh(ts)
 }
 
 func (b *BCBProvider) buildURL(currency string, date time.Time) string {
 	cur := strings.ToUpper(currency)
 	d := date.Format("01-02-2006")
-	if cur == "USD" {
+	// the dollar endpoint only has the closing rate; other bulletins come
+	// from the per-currency endpoint like any other currency
+	if cur == "USD" && (b.bulletin == BCBBulletinLatest || b.bulletin == BCBBulletinClosing) {
 		return fmt.Sprintf(b.baseURL+"CotacaoDolarPeriodo(dataInicial=@dataInicial,dataFinalCotacao=@dataFinalCotacao)?@dataInicial='%s'&@dataFinalCotacao='%s'&$top=100&$format=json&$select=cotacaoCompra,cotacaoVenda,dataHoraCotacao", d, d)
 	}
 	return fmt.Sprintf(b.baseURL+"CotacaoMoedaAberturaOuIntermediario(codigoMoeda=@codigoMoeda,dataCotacao=@dataCotacao)?@codigoMoeda='%s'&@dataCotacao='%s'&$format=json&$select=cotacaoCompra,cotacaoVenda,dataHoraCotacao,tipoBoletim", cur, d)
 }
 
-// Convert converts amount (cents) from 'from' to 'to' using BCB PTAX rates.
-// BCB provides BRL per unit of currency (venda). We use BRL as intermediary when needed.
-func (b *BCBProvider) Convert(ctx context.Context, from, to string, amount int64) (int64, error) {
-	fromU := strings.ToUpper(from)
-	toU := strings.ToUpper(to)
-	if fromU == toU {
-		return amount, nil
-	}
+// bcbRate is a PTAX rate (BRL per unit of currency) for the configured side.
+type bcbRate struct {
+	value    float64
+	bulletin string
+	ts       time.Time
+	stale    bool
+	cacheHit bool
+}
 
-	// get venda rate BRL per unit for currency
-	getRate := func(currency string) (float64, error) {
-		cacheKey := "rates:bcb:" + strings.ToUpper(currency)
-		if b.cache != nil {
-			if cached, err := b.cache.Get(ctx, cacheKey); err == nil && cached != "" {
-				if b.log != nil {
-					b.log.WithContext(ctx).Debugf("using cached bcb rates for %s", currency)
-				}
-				var br bcbResponse
-				if err := json.Unmarshal([]byte(cached), &br); err == nil && len(br.Value) > 0 {
-					return br.Value[0].CotacaoVenda, nil
-				}
-			}
+// fetchRates looks up the latest PTAX quote for currency, walking back up to
+// maxBackDays business days, and returns the decoded JSON payload. It is safe
+// to call concurrently: http.Client is safe for concurrent use and the cache
+// is only touched through rateCache.
+func (b *BCBProvider) fetchRates(ctx context.Context, currency string) ([]byte, error) {
+	client := b.client
+	// total time spent backing off is capped by the provider timeout
+	var backoff time.Duration
+	for i, tryDate := range b.businessDays(b.maxBackDays) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		url := b.buildURL(currency, tryDate)
+		if b.log != nil {
+			b.log.WithContext(ctx).Debugf("bcb request url=%s", url)
 		}
 
-		client := &http.Client{Timeout: b.timeout}
-		for i := 0; i <= b.maxBackDays; i++ {
-			tryDate := time.Now().AddDate(0, 0, -i)
-			url := b.buildURL(currency, tryDate)
-			if b.log != nil {
-				b.log.WithContext(ctx).Debugf("bcb request url=%s", url)
+		var res *fetchResult
+		var err error
+		for attempt := 0; attempt <= b.maxRetries; attempt++ {
+			if err := ctx.Err(); err != nil {
+				return nil, err
 			}
-
-			var resp *http.Response
-			var err error
-			for attempt := 0; attempt <= b.maxRetries; attempt++ {
-				req, _ := http.NewRequestWithContext(ctx, "GET", url, nil)
-				resp, err = client.Do(req)
-				if err != nil {
-					return 0, err
-				}
-				if resp.StatusCode == http.StatusOK {
-					break
-				}
-				if resp.StatusCode >= 500 && attempt < b.maxRetries {
-					resp.Body.Close()
-					time.Sleep(time.Duration(math.Pow(2, float64(attempt))) * time.Second)
-					continue
+			res, err = fetch(ctx, client, url, b.clock)
+			if err != nil {
+				if ctxErr := ctx.Err(); ctxErr != nil {
+					return nil, ctxErr
 				}
-				body, _ := io.ReadAll(resp.Body)
-				resp.Body.Close()
-				return 0, fmt.Errorf("bcb returned status=%d body=%s", resp.StatusCode, string(body))
+				return nil, err
 			}
-
-			if resp == nil {
+			if res.Status == http.StatusOK {
+				break
+			}
+			wait := time.Duration(math.Pow(2, float64(attempt))) * time.Second
+			if res.Status >= 500 && attempt < b.maxRetries && (b.timeout <= 0 || backoff+wait <= b.timeout) {
+				backoff += wait
+				observationFrom(ctx).addRetry()
+				select {
+				case <-ctx.Done():
+					return nil, ctx.Err()
+				case <-time.After(wait):
+				}
 				continue
 			}
+			body, truncated := logBody(res.Body, b.bodyLimit)
+			return nil, fmt.Errorf("bcb returned status=%d body=%s truncated=%t", res.Status, body, truncated)
+		}
 
-			bodyBytes, err := io.ReadAll(resp.Body)
-			resp.Body.Close()
-			if err != nil {
-				return 0, err
-			}
+		if res == nil {
+			continue
+		}
 
-			var br bcbResponse
-			if err := json.Unmarshal(bodyBytes, &br); err != nil {
-				s := strings.TrimSpace(string(bodyBytes))
-				if strings.HasPrefix(s, "/*") && strings.HasSuffix(s, "*/") {
-					s = strings.TrimPrefix(s, "/*")
-					s = strings.TrimSuffix(s, "*/")
-					s = strings.TrimSpace(s)
-					if err2 := json.Unmarshal([]byte(s), &br); err2 != nil {
-						return 0, err
-					}
-				} else {
-					return 0, err
-				}
-			}
+		bodyBytes := res.Body
 
-			if len(br.Value) == 0 {
-				if i < b.maxBackDays {
-					continue
+		var br bcbResponse
+		if err := json.Unmarshal(bodyBytes, &br); err != nil {
+			s := strings.TrimSpace(string(bodyBytes))
+			if strings.HasPrefix(s, "/*") && strings.HasSuffix(s, "*/") {
+				s = strings.TrimPrefix(s, "/*")
+				s = strings.TrimSuffix(s, "*/")
+				s = strings.TrimSpace(s)
+				if err2 := json.Unmarshal([]byte(s), &br); err2 != nil {
+					return nil, err
 				}
-				return 0, fmt.Errorf("no bcb rate found for currency %s", currency)
+				// cache the unwrapped payload so later reads decode directly
+				bodyBytes = []byte(s)
+			} else {
+				return nil, err
 			}
+		}
 
-			if b.cache != nil {
-				_ = b.cache.Set(ctx, cacheKey, string(bodyBytes), 20*time.Minute)
+		// a day without the configured bulletin counts as a day without quotes
+		if _, ok := selectQuote(br.Value, b.bulletin); !ok {
+			if i < b.maxBackDays {
+				continue
 			}
-			return br.Value[0].CotacaoVenda, nil
+			return nil, fmt.Errorf("no bcb %s rate found for currency %s", b.bulletin, currency)
 		}
-		return 0, fmt.Errorf("no bcb rate found for %s in last %d days", currency, b.maxBackDays)
+		return bodyBytes, nil
 	}
+	return nil, fmt.Errorf("no bcb rate found for %s in last %d business days", currency, b.maxBackDays)
+}
+
+// getRate returns the rate (BRL per unit) for currency on the configured side
+// and bulletin.
+func (b *BCBProvider) getRate(ctx context.Context, currency string) (bcbRate, error) {
+	cacheKey := "rates:bcb:" + strings.ToUpper(currency)
+	if b.bulletin != BCBBulletinLatest {
+		// the payload depends on the bulletin (endpoint and lookback day)
+		cacheKey += ":" + b.bulletin
+	}
+	loaded, err := b.rates.load(ctx, cacheKey, func(ctx context.Context) ([]byte, error) {
+		return b.fetchRates(ctx, currency)
+	})
+	if err != nil {
+		return bcbRate{}, err
+	}
+	observationFrom(ctx).recordLookup(loaded.CacheHit)
+	if loaded.CacheHit && b.log != nil {
+		b.log.WithContext(ctx).Debugf("using cached bcb rates for %s stale=%t", currency, loaded.Stale)
+	}
+
+	var br bcbResponse
+	if err := json.Unmarshal(loaded.Body, &br); err != nil {
+		return bcbRate{}, err
+	}
+	q, ok := selectQuote(br.Value, b.bulletin)
+	if !ok {
+		return bcbRate{}, fmt.Errorf("no bcb %s rate found for currency %s", b.bulletin, currency)
+	}
+	if err := b.checkFresh(ctx, q.DataHora); err != nil {
+		return bcbRate{}, err
+	}
+	ts, ok := parseBCBTime(q.DataHora)
+	if !ok {
+		ts = loaded.FetchedAt
+	}
+	return bcbRate{value: q.value(b.side), bulletin: q.bulletin(), ts: ts, stale: loaded.Stale, cacheHit: loaded.CacheHit}, nil
+}
+
+// Convert converts amount (cents) from 'from' to 'to' using BCB PTAX rates.
+// BCB provides BRL per unit of currency. We use BRL as intermediary when needed.
+func (b *BCBProvider) Convert(ctx context.Context, from, to string, amount int64) (int64, error) {
+	res, err := b.ConvertDetailed(ctx, from, to, amount)
+	return res.ResultCents, err
+}
+
+func (b *BCBProvider) ConvertDetailed(ctx context.Context, from, to string, amount int64) (_ ConvertResult, err error) {
+	ctx, obs := startConvert(ctx, nameBCB, from, to)
+	defer func() { obs.end(ctx, err) }()
+
+	fromU := strings.ToUpper(from)
+	toU := strings.ToUpper(to)
+	if fromU == toU {
+		return ConvertResult{ResultCents: amount, Rate: 1, RateTimestamp: time.Now(), Source: nameBCB}, nil
+	}
+
+	amountUnits := float64(amount) / 100.0
 
 	// convert using BRL as intermediary
 	if fromU == "BRL" {
-		toBRL, err := getRate(toU)
+		toBRL, err := b.getRate(ctx, toU)
 		if err != nil {
-			return 0, err
+			return ConvertResult{}, err
 		}
-		amountUnits := float64(amount) / 100.0
-		toUnits := amountUnits / toBRL
-		return int64(math.Round(toUnits * 100.0)), nil
+		toUnits := amountUnits / toBRL.value
+		return ConvertResult{ResultCents: int64(math.Round(toUnits * 100.0)), Rate: 1 / toBRL.value, RateTimestamp: toBRL.ts, Stale: toBRL.stale,
+			Source: nameBCB, CacheHit: toBRL.cacheHit, RateSide: b.side, Bulletin: toBRL.bulletin}, nil
 	}
 	if toU == "BRL" {
-		fromBRL, err := getRate(fromU)
+		fromBRL, err := b.getRate(ctx, fromU)
 		if err != nil {
-			return 0, err
+			return ConvertResult{}, err
 		}
-		amountUnits := float64(amount) / 100.0
-		brlUnits := amountUnits * fromBRL
-		return int64(math.Round(brlUnits * 100.0)), nil
+		brlUnits := amountUnits * fromBRL.value
+		return ConvertResult{ResultCents: int64(math.Round(brlUnits * 100.0)), Rate: fromBRL.value, RateTimestamp: fromBRL.ts, Stale: fromBRL.stale,
+			Source: nameBCB, CacheHit: fromBRL.cacheHit, RateSide: b.side, Bulletin: fromBRL.bulletin}, nil
 	}
 
-	fromBRL, err := getRate(fromU)
-	if err != nil {
-		return 0, err
-	}
-	toBRL, err := getRate(toU)
-	if err != nil {
-		return 0, err
+	// fetch both legs concurrently; the first failure cancels the other
+	var fromBRL, toBRL bcbRate
+	g, gctx := errgroup.WithContext(ctx)
+	g.Go(func() (err error) {
+		fromBRL, err = b.getRate(gctx, fromU)
+		return err
+	})
+	g.Go(func() (err error) {
+		toBRL, err = b.getRate(gctx, toU)
+		return err
+	})
+	if err := g.Wait(); err != nil {
+		return ConvertResult{}, err
 	}
 
-	rate := fromBRL / toBRL
-	amountUnits := float64(amount) / 100.0
+	rate := fromBRL.value / toBRL.value
 	resultUnits := amountUnits * rate
-	return int64(math.Round(resultUnits * 100.0)), nil
+	// report the older of the two quotes
+	ts := fromBRL.ts
+	if toBRL.ts.Before(ts) {
+		ts = toBRL.ts
+	}
+	// with latest the legs may come from different bulletins
+	bulletin := fromBRL.bulletin
+	if toBRL.bulletin != bulletin {
+		bulletin += "/" + toBRL.bulletin
+	}
+	return ConvertResult{
+		ResultCents:   int64(math.Round(resultUnits * 100.0)),
+		Rate:          rate,
+		RateTimestamp: ts,
+		Stale:         fromBRL.stale || toBRL.stale,
+		Source:        nameBCB,
+		CacheHit:      fromBRL.cacheHit && toBRL.cacheHit,
+		RateSide:      b.side,
+		Bulletin:      bulletin,
+	}, nil
 }
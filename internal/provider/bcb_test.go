@@ -2,21 +2,25 @@ package provider
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"sync"
 	"testing"
 	"time"
 )
 
-// fakeCache is a simple in-memory cache for tests.
+// fakeCache is a simple in-memory cache for tests. It records the TTL of
+// each write.
 type fakeCache struct {
-	mu sync.Mutex
-	m  map[string]string
+	mu   sync.Mutex
+	m    map[string]string
+	ttls map[string]time.Duration
 }
 
 func newFakeCache() *fakeCache {
-	return &fakeCache{m: make(map[string]string)}
+	return &fakeCache{m: make(map[string]string), ttls: make(map[string]time.Duration)}
 }
 
 func (f *fakeCache) Get(ctx context.Context, key string) (string, error) {
@@ -25,13 +29,51 @@ func (f *fakeCache) Get(ctx context.Context, key string) (string, error) {
 	return f.m[key], nil
 }
 
+func (f *fakeCache) GetWithTTL(ctx context.Context, key string) (string, time.Duration, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.m[key], f.ttls[key], nil
+}
+
 func (f *fakeCache) Set(ctx context.Context, key string, value string, ttl time.Duration) error {
 	f.mu.Lock()
 	defer f.mu.Unlock()
 	f.m[key] = value
+	f.ttls[key] = ttl
+	return nil
+}
+
+func (f *fakeCache) GetOrSet(ctx context.Context, key string, ttl time.Duration, fill func(ctx context.Context) (string, error)) (string, error) {
+	if v, _ := f.Get(ctx, key); v != "" {
+		return v, nil
+	}
+	v, err := fill(ctx)
+	if err == nil && v != "" {
+		_ = f.Set(ctx, key, v, ttl)
+	}
+	return v, err
+}
+
+func (f *fakeCache) Delete(ctx context.Context, key string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.m, key)
 	return nil
 }
 
+func (f *fakeCache) DeleteByPrefix(ctx context.Context, prefix string) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var n int64
+	for k := range f.m {
+		if strings.HasPrefix(k, prefix) {
+			delete(f.m, k)
+			n++
+		}
+	}
+	return n, nil
+}
+
 func TestBCBProvider_ParsePlainJSONAndCache(t *testing.T) {
 	// prepare a test server that returns a plain JSON
 	body := `{"value":[{"cotacaoCompra":4.0,"cotacaoVenda":4.2,"dataHoraCotacao":"2025-09-19T12:00:00"}]}`
@@ -42,7 +84,7 @@ func TestBCBProvider_ParsePlainJSONAndCache(t *testing.T) {
 	defer srv.Close()
 
 	cache := newFakeCache()
-	p := NewBCBProvider(nil, srv.URL+"/", 2*time.Second, 1, 1, cache)
+	p := NewBCBProvider(nil, srv.URL+"/", 2*time.Second, 1, 1, cache, 20*time.Minute, nil)
 
 	// convert from BRL to USD (BRL -> USD uses rate = BRL per unit of USD)
 	// amount 10000 cents = 100 BRL. rate 4.2 BRL per USD => result = 100/4.2 = ~23.8095 USD -> 2381 cents
@@ -77,7 +119,7 @@ func TestBCBProvider_ParseWrappedJSONAndLookback(t *testing.T) {
 	defer srv.Close()
 
 	cache := newFakeCache()
-	p := NewBCBProvider(nil, srv.URL+"/", 2*time.Second, 1, 2, cache)
+	p := NewBCBProvider(nil, srv.URL+"/", 2*time.Second, 1, 2, cache, 20*time.Minute, nil)
 
 	// Convert USD -> BRL: amount 100 USD = 10000 cents; rate 5.5 BRL per USD => 100*5.5 = 550 BRL -> 55000 cents
 	got, err := p.Convert(context.Background(), "USD", "BRL", 10000)
@@ -88,3 +130,233 @@ func TestBCBProvider_ParseWrappedJSONAndLookback(t *testing.T) {
 		t.Fatalf("unexpected result: %d", got)
 	}
 }
+
+func TestBCBProvider_RetryStopsOnCancel(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	p := NewBCBProvider(nil, srv.URL+"/", 30*time.Second, 5, 3, nil, 0, nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := p.Convert(ctx, "USD", "BRL", 10000)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context deadline error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("convert kept retrying after cancellation: %v", elapsed)
+	}
+}
+
+func TestBCBProvider_CrossRateFetchesInParallel(t *testing.T) {
+	const delay = 100 * time.Millisecond
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(delay)
+		venda := "5.0"
+		if strings.Contains(r.URL.RawQuery, "EUR") {
+			venda = "6.0"
+		}
+		_, _ = w.Write([]byte(`{"value":[{"cotacaoCompra":1,"cotacaoVenda":` + venda + `,"dataHoraCotacao":"2025-09-19 13:00:00.0"}]}`))
+	}))
+	defer srv.Close()
+
+	cache := newFakeCache()
+	p := NewBCBProvider(nil, srv.URL+"/", 2*time.Second, 1, 0, cache, 20*time.Minute, nil)
+
+	start := time.Now()
+	got, err := p.Convert(context.Background(), "USD", "EUR", 6000)
+	elapsed := time.Since(start)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// 60.00 USD * 5.0 BRL/USD / 6.0 BRL/EUR = 50.00 EUR
+	if got != 5000 {
+		t.Fatalf("unexpected result: %d", got)
+	}
+	if elapsed >= 2*delay {
+		t.Fatalf("expected both legs to be fetched concurrently, took %v", elapsed)
+	}
+	for _, key := range []string{"rates:bcb:USD", "rates:bcb:EUR"} {
+		if v, _ := cache.Get(context.Background(), key); v == "" {
+			t.Fatalf("expected %s to be cached", key)
+		}
+	}
+}
+
+func TestBCBProvider_CrossRateReturnsFirstError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.RawQuery, "EUR") {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		time.Sleep(time.Second)
+	}))
+	defer srv.Close()
+
+	p := NewBCBProvider(nil, srv.URL+"/", 5*time.Second, 0, 0, nil, 0, nil)
+	start := time.Now()
+	if _, err := p.Convert(context.Background(), "USD", "EUR", 100); err == nil || !strings.Contains(err.Error(), "status=404") {
+		t.Fatalf("expected the EUR failure, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("failure should cancel the other leg, took %v", elapsed)
+	}
+}
+
+func TestBCBProvider_BusinessDayWindow(t *testing.T) {
+	cases := []struct {
+		name     string
+		now      time.Time
+		backDays int
+		holidays []string
+		want     []string // requested dates, MM-DD-YYYY
+	}{
+		{
+			// 01:00 UTC on Friday is still Thursday evening in São Paulo
+			name: "uses São Paulo date", now: time.Date(2025, 9, 19, 1, 0, 0, 0, time.UTC),
+			backDays: 0, want: []string{"09-18-2025"},
+		},
+		{
+			name: "skips weekend", now: time.Date(2025, 9, 22, 2, 0, 0, 0, time.UTC), // Sunday 23:00 in SP
+			backDays: 1, want: []string{"09-19-2025", "09-18-2025"},
+		},
+		{
+			name: "monday walks back to friday", now: time.Date(2025, 9, 22, 15, 0, 0, 0, time.UTC),
+			backDays: 1, want: []string{"09-22-2025", "09-19-2025"},
+		},
+		{
+			name: "skips configured holiday", now: time.Date(2025, 11, 21, 15, 0, 0, 0, time.UTC), // Friday
+			backDays: 2, holidays: []string{"2025-11-20"}, want: []string{"11-21-2025", "11-19-2025", "11-18-2025"},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var got []string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				got = append(got, r.URL.Query().Get("@dataInicial")[1:11])
+				mu.Unlock()
+				_, _ = w.Write([]byte(`{"value":[]}`))
+			}))
+			defer srv.Close()
+
+			p := NewBCBProvider(nil, srv.URL+"/", 2*time.Second, 0, tc.backDays, nil, 0, nil)
+			p.now = func() time.Time { return tc.now }
+			p.holidays, _ = parseBCBHolidays(tc.holidays)
+			if _, err := p.Convert(context.Background(), "USD", "BRL", 100); err == nil {
+				t.Fatalf("expected no rate to be found")
+			}
+			if strings.Join(got, ",") != strings.Join(tc.want, ",") {
+				t.Fatalf("requested dates %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestParseBCBHolidays(t *testing.T) {
+	h, bad := parseBCBHolidays([]string{"2025-12-25", " 2025-11-20 ", "25/12/2025", ""})
+	if !h["2025-12-25"] || !h["2025-11-20"] || len(h) != 2 {
+		t.Fatalf("unexpected holidays %v", h)
+	}
+	if len(bad) != 1 || bad[0] != "25/12/2025" {
+		t.Fatalf("unexpected invalid entries %v", bad)
+	}
+}
+
+func TestBCBProvider_SelectsRateSideAndBulletin(t *testing.T) {
+	// canned CotacaoMoedaAberturaOuIntermediario payload, in publication order
+	moeda := `{"value":[
+		{"cotacaoCompra":5.00,"cotacaoVenda":5.10,"dataHoraCotacao":"2025-09-19 10:08:00.0","tipoBoletim":"Abertura"},
+		{"cotacaoCompra":5.20,"cotacaoVenda":5.30,"dataHoraCotacao":"2025-09-19 11:07:00.0","tipoBoletim":"Intermediário"},
+		{"cotacaoCompra":5.40,"cotacaoVenda":5.50,"dataHoraCotacao":"2025-09-19 12:05:00.0","tipoBoletim":"Intermediário"},
+		{"cotacaoCompra":5.60,"cotacaoVenda":5.80,"dataHoraCotacao":"2025-09-19 13:09:00.0","tipoBoletim":"Fechamento PTAX"}
+	]}`
+	// CotacaoDolarPeriodo has no tipoBoletim: it is the closing bulletin
+	dolar := `{"value":[{"cotacaoCompra":4.90,"cotacaoVenda":5.00,"dataHoraCotacao":"2025-09-19 13:04:00.0"}]}`
+
+	cases := []struct {
+		name         string
+		currency     string
+		side         string
+		bulletin     string
+		wantCents    int64
+		wantBulletin string
+		wantEndpoint string
+	}{
+		{name: "default sell latest", currency: "EUR", side: BCBSideSell, bulletin: BCBBulletinLatest, wantCents: 580, wantBulletin: BCBBulletinClosing, wantEndpoint: "CotacaoMoeda"},
+		{name: "buy opening", currency: "EUR", side: BCBSideBuy, bulletin: BCBBulletinOpening, wantCents: 500, wantBulletin: BCBBulletinOpening, wantEndpoint: "CotacaoMoeda"},
+		{name: "mid closing", currency: "EUR", side: BCBSideMid, bulletin: BCBBulletinClosing, wantCents: 570, wantBulletin: BCBBulletinClosing, wantEndpoint: "CotacaoMoeda"},
+		{name: "latest intermediate wins", currency: "EUR", side: BCBSideSell, bulletin: BCBBulletinIntermediate, wantCents: 550, wantBulletin: BCBBulletinIntermediate, wantEndpoint: "CotacaoMoeda"},
+		{name: "usd latest uses dollar endpoint", currency: "USD", side: BCBSideMid, bulletin: BCBBulletinLatest, wantCents: 495, wantBulletin: BCBBulletinClosing, wantEndpoint: "CotacaoDolarPeriodo"},
+		{name: "usd opening uses currency endpoint", currency: "USD", side: BCBSideBuy, bulletin: BCBBulletinOpening, wantCents: 500, wantBulletin: BCBBulletinOpening, wantEndpoint: "CotacaoMoeda"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var path string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				path = r.URL.Path
+				if strings.Contains(r.URL.Path, "CotacaoDolarPeriodo") {
+					_, _ = w.Write([]byte(dolar))
+					return
+				}
+				_, _ = w.Write([]byte(moeda))
+			}))
+			defer srv.Close()
+
+			p := NewBCBProvider(nil, srv.URL, time.Second, 0, 0, nil, 0, nil)
+			p.side, p.bulletin = tc.side, tc.bulletin
+			res, err := p.ConvertDetailed(context.Background(), tc.currency, "BRL", 100)
+			if err != nil {
+				t.Fatalf("convert: %v", err)
+			}
+			if !strings.Contains(path, tc.wantEndpoint) {
+				t.Fatalf("expected %s endpoint, got %s", tc.wantEndpoint, path)
+			}
+			if res.ResultCents != tc.wantCents || res.RateSide != tc.side || res.Bulletin != tc.wantBulletin {
+				t.Fatalf("expected %d cents side=%s bulletin=%s, got %+v", tc.wantCents, tc.side, tc.wantBulletin, res)
+			}
+		})
+	}
+}
+
+func TestBCBProvider_MissingBulletinWalksBack(t *testing.T) {
+	// today only has the opening bulletin; yesterday has the closing one
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			_, _ = w.Write([]byte(`{"value":[{"cotacaoCompra":5.0,"cotacaoVenda":5.1,"dataHoraCotacao":"2025-09-19 10:08:00.0","tipoBoletim":"Abertura"}]}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"value":[{"cotacaoCompra":5.2,"cotacaoVenda":5.3,"dataHoraCotacao":"2025-09-18 13:08:00.0","tipoBoletim":"Fechamento"}]}`))
+	}))
+	defer srv.Close()
+
+	p := NewBCBProvider(nil, srv.URL, time.Second, 0, 1, nil, 0, nil)
+	p.now = func() time.Time { return time.Date(2025, 9, 19, 12, 0, 0, 0, saoPaulo) }
+	p.bulletin = BCBBulletinClosing
+	res, err := p.ConvertDetailed(context.Background(), "EUR", "BRL", 100)
+	if err != nil {
+		t.Fatalf("convert: %v", err)
+	}
+	if calls != 2 || res.ResultCents != 530 || res.Bulletin != BCBBulletinClosing {
+		t.Fatalf("expected previous day's closing rate, got %+v after %d calls", res, calls)
+	}
+}
+
+func TestParseBCBRateSideAndBulletin(t *testing.T) {
+	for in, want := range map[string]string{"buy": BCBSideBuy, " SELL ": BCBSideSell, "mid": BCBSideMid, "ask": ""} {
+		if got, ok := ParseBCBRateSide(in); got != want || ok != (want != "") {
+			t.Errorf("ParseBCBRateSide(%q) = %q, %t", in, got, ok)
+		}
+	}
+	for in, want := range map[string]string{"Abertura": BCBBulletinOpening, "intermediário": BCBBulletinIntermediate, "fechamento": BCBBulletinClosing, "latest": BCBBulletinLatest, "close": ""} {
+		if got, ok := ParseBCBBulletin(in); got != want || ok != (want != "") {
+			t.Errorf("ParseBCBBulletin(%q) = %q, %t", in, got, ok)
+		}
+	}
+}
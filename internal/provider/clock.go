@@ -0,0 +1,141 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/thiagozs/go-exchange/internal/logger"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// StaleRateError is returned when the upstream rate is older than the
+// configured maximum rate age.
+type StaleRateError struct {
+	Age    time.Duration
+	MaxAge time.Duration
+}
+
+func (e StaleRateError) Error() string {
+	return fmt.Sprintf("exchange rate is stale: age=%s max=%s", e.Age.Round(time.Second), e.MaxAge)
+}
+
+// SkewClock measures the offset between the local clock and the clocks of
+// upstream providers (HTTP Date headers and rate timestamps) and uses it to
+// make freshness decisions that survive a drifting local clock.
+//
+// The skew is defined as local - remote: a positive value means the local
+// clock is ahead. Corrections are only applied when the measured skew exceeds
+// the threshold and never by more than the measured value, so stale data can
+// at most look fresher by the skew itself.
+type SkewClock struct {
+	log       *logger.Logger
+	threshold time.Duration
+	maxAge    time.Duration
+	now       func() time.Time
+	gauge     metric.Float64Gauge
+
+	mu   sync.RWMutex
+	skew time.Duration
+}
+
+// NewSkewClock creates a SkewClock. A maxAge of zero disables freshness checks.
+func NewSkewClock(lg *logger.Logger, threshold, maxAge time.Duration) *SkewClock {
+	g, _ := otel.Meter("github.com/thiagozs/go-exchange/internal/provider").Float64Gauge(
+		"clock_skew_seconds",
+		metric.WithDescription("Measured offset between the local clock and upstream provider clocks (local - remote)"),
+		metric.WithUnit("s"),
+	)
+	return &SkewClock{log: lg, threshold: threshold, maxAge: maxAge, now: time.Now, gauge: g}
+}
+
+// ObserveDate records the skew reported by an HTTP Date response header.
+func (c *SkewClock) ObserveDate(ctx context.Context, h http.Header) {
+	if c == nil || h == nil {
+		return
+	}
+	v := h.Get("Date")
+	if v == "" {
+		return
+	}
+	remote, err := http.ParseTime(v)
+	if err != nil {
+		return
+	}
+	c.observe(ctx, remote, "date_header")
+}
+
+// ObserveRateTime records skew implied by a provider rate timestamp. Rate
+// timestamps are publication times, so they only prove the local clock is
+// behind when they lie in the local future.
+func (c *SkewClock) ObserveRateTime(ctx context.Context, ts time.Time) {
+	if c == nil || ts.IsZero() {
+		return
+	}
+	if ts.After(c.now()) {
+		c.observe(ctx, ts, "rate_timestamp")
+	}
+}
+
+func (c *SkewClock) observe(ctx context.Context, remote time.Time, source string) {
+	skew := c.now().Sub(remote)
+
+	c.mu.Lock()
+	c.skew = skew
+	c.mu.Unlock()
+
+	if c.gauge != nil {
+		c.gauge.Record(ctx, skew.Seconds())
+	}
+	if c.exceeds(skew) && c.log != nil {
+		c.log.WithContext(ctx).WithFields(map[string]any{
+			"skew_seconds": skew.Seconds(),
+			"source":       source,
+		}).Warnf("clock skew against upstream provider exceeds %s", c.threshold)
+	}
+}
+
+func (c *SkewClock) exceeds(skew time.Duration) bool {
+	if skew < 0 {
+		skew = -skew
+	}
+	return skew > c.threshold
+}
+
+// Skew returns the last measured skew (local - remote).
+func (c *SkewClock) Skew() time.Duration {
+	if c == nil {
+		return 0
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.skew
+}
+
+// Now returns the local time corrected by the measured skew when it exceeds
+// the threshold.
+func (c *SkewClock) Now() time.Time {
+	if c == nil {
+		return time.Now()
+	}
+	now := c.now()
+	if skew := c.Skew(); c.exceeds(skew) {
+		return now.Add(-skew)
+	}
+	return now
+}
+
+// CheckFresh returns a StaleRateError when ts is older than the configured
+// maximum rate age according to the skew-corrected clock.
+func (c *SkewClock) CheckFresh(ts time.Time) error {
+	if c == nil || c.maxAge <= 0 || ts.IsZero() {
+		return nil
+	}
+	if age := c.Now().Sub(ts); age > c.maxAge {
+		return StaleRateError{Age: age, MaxAge: c.maxAge}
+	}
+	return nil
+}
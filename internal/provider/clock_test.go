@@ -0,0 +1,106 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/thiagozs/go-exchange/internal/logger"
+)
+
+// dateServer returns an upstream stub that reports remote as its Date header.
+func dateServer(t *testing.T, remote time.Time) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Date", remote.UTC().Format(http.TimeFormat))
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestFetchDetectsClockSkew(t *testing.T) {
+	local := time.Date(2025, 9, 19, 15, 0, 0, 0, time.UTC)
+	remote := local.Add(-2 * time.Hour) // local clock is two hours ahead
+
+	var buf bytes.Buffer
+	lg := logger.New(logger.Options{Format: "text", Level: "warn", Out: &buf})
+	clock := NewSkewClock(lg, 5*time.Second, 10*time.Minute)
+	clock.now = func() time.Time { return local }
+
+	srv := dateServer(t, remote)
+	if _, err := fetch(context.Background(), srv.Client(), srv.URL, clock); err != nil {
+		t.Fatalf("fetch: %v", err)
+	}
+
+	if got := clock.Skew(); got != 2*time.Hour {
+		t.Fatalf("expected skew of 2h, got %s", got)
+	}
+	if !strings.Contains(buf.String(), "clock skew") || !strings.Contains(buf.String(), "skew_seconds=7200") {
+		t.Fatalf("expected skew warning in logs, got: %s", buf.String())
+	}
+
+	// published one minute ago by the remote clock: fresh once corrected
+	if err := clock.CheckFresh(remote.Add(-time.Minute)); err != nil {
+		t.Fatalf("expected fresh rate with corrected clock, got %v", err)
+	}
+	// stale by the remote clock too: must remain stale
+	var stale StaleRateError
+	if err := clock.CheckFresh(remote.Add(-11 * time.Minute)); !errors.As(err, &stale) {
+		t.Fatalf("expected StaleRateError, got %v", err)
+	}
+}
+
+func TestSkewBelowThresholdIsNotCorrected(t *testing.T) {
+	local := time.Date(2025, 9, 19, 15, 0, 0, 0, time.UTC)
+	remote := local.Add(-3 * time.Second)
+
+	clock := NewSkewClock(nil, 5*time.Second, time.Minute)
+	clock.now = func() time.Time { return local }
+
+	srv := dateServer(t, remote)
+	if _, err := fetch(context.Background(), srv.Client(), srv.URL, clock); err != nil {
+		t.Fatalf("fetch: %v", err)
+	}
+	if got := clock.Now(); !got.Equal(local) {
+		t.Fatalf("expected uncorrected clock %s, got %s", local, got)
+	}
+	if err := clock.CheckFresh(local.Add(-61 * time.Second)); err == nil {
+		t.Fatalf("expected stale rate without correction")
+	}
+}
+
+func TestSkewClockLocalBehindFromRateTimestamp(t *testing.T) {
+	local := time.Date(2025, 9, 19, 15, 0, 0, 0, time.UTC)
+	clock := NewSkewClock(nil, 5*time.Second, time.Minute)
+	clock.now = func() time.Time { return local }
+
+	// a rate published "in the future" proves the local clock is behind
+	clock.ObserveRateTime(context.Background(), local.Add(30*time.Minute))
+	if got := clock.Skew(); got != -30*time.Minute {
+		t.Fatalf("expected skew of -30m, got %s", got)
+	}
+	// correcting a clock that is behind can only make data look older
+	if err := clock.CheckFresh(local); err == nil {
+		t.Fatalf("expected rate to be stale against the corrected clock")
+	}
+}
+
+func TestExchangeRateAPI_RejectsStaleRates(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"result":"success","time_last_update_unix":1000,"conversion_rates":{"BRL":5}}`))
+	}))
+	defer srv.Close()
+
+	p := &ExchangeRateAPI{baseURL: srv.URL, apiKey: "k", clock: NewSkewClock(nil, 5*time.Second, time.Hour)}
+	_, err := p.Convert(context.Background(), "USD", "BRL", 100)
+	var stale StaleRateError
+	if !errors.As(err, &stale) {
+		t.Fatalf("expected StaleRateError, got %v", err)
+	}
+}
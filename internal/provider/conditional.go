@@ -0,0 +1,64 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+)
+
+// errNotModified is returned by rate fetches answered 304 Not Modified: the
+// cached payload they revalidated is still current.
+var errNotModified = errors.New("upstream rates not modified")
+
+// validators are the upstream cache validators of a rates payload.
+type validators struct {
+	ETag         string
+	LastModified string
+}
+
+// conditionalFetch carries validators between rateCache and fetch: prev is
+// sent as If-None-Match and If-Modified-Since, got receives those of the
+// response.
+type conditionalFetch struct {
+	prev validators
+
+	mu  sync.Mutex
+	got validators
+}
+
+type conditionalCtxKey struct{}
+
+// conditionalFrom returns the validators of the revalidated entry; nil (a
+// plain request) outside a conditional fetch.
+func conditionalFrom(ctx context.Context) *conditionalFetch {
+	c, _ := ctx.Value(conditionalCtxKey{}).(*conditionalFetch)
+	return c
+}
+
+func (c *conditionalFetch) apply(req *http.Request) {
+	if c == nil {
+		return
+	}
+	if c.prev.ETag != "" {
+		req.Header.Set("If-None-Match", c.prev.ETag)
+	}
+	if c.prev.LastModified != "" {
+		req.Header.Set("If-Modified-Since", c.prev.LastModified)
+	}
+}
+
+func (c *conditionalFetch) record(h http.Header) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.got = validators{ETag: h.Get("ETag"), LastModified: h.Get("Last-Modified")}
+}
+
+func (c *conditionalFetch) validators() validators {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.got
+}
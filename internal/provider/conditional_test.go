@@ -0,0 +1,133 @@
+package provider
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const lastModified = "Thu, 15 Oct 2026 00:00:00 GMT"
+
+// conditionalUpstream serves body with an ETag and Last-Modified, answering
+// 304 to requests that revalidate them. Revalidations without the validators
+// fail the test.
+func conditionalUpstream(t *testing.T, body string) (*httptest.Server, *atomic.Int32, *atomic.Int32) {
+	t.Helper()
+	var full, notModified atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Last-Modified", lastModified)
+		if full.Load() > 0 {
+			if r.Header.Get("If-None-Match") != `"v1"` || r.Header.Get("If-Modified-Since") != lastModified {
+				t.Errorf("expected the cached validators, got If-None-Match=%q If-Modified-Since=%q",
+					r.Header.Get("If-None-Match"), r.Header.Get("If-Modified-Since"))
+			}
+			notModified.Add(1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		full.Add(1)
+		_, _ = w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &full, &notModified
+}
+
+func TestConditionalRevalidation(t *testing.T) {
+	cases := []struct {
+		name string
+		body string
+		key  string
+		new  func(c Cache, baseURL string) (Provider, *rateCache)
+	}{
+		{name: nameExchangerateHost, body: `{"success":true,"rates":{"BRL":5}}`, key: "rates:exchangerate.host:USD",
+			new: func(c Cache, baseURL string) (Provider, *rateCache) {
+				p := NewExchangerateHost(nil, "", c, 10*time.Minute, nil)
+				p.baseURL = baseURL
+				return p, p.rates
+			}},
+		{name: nameExchangeRateAPI, body: `{"result":"success","base_code":"USD","conversion_rates":{"BRL":5}}`, key: "rates:exchangerate-api:USD",
+			new: func(c Cache, baseURL string) (Provider, *rateCache) {
+				p := NewExchangeRateAPI(nil, "k", c, 10*time.Minute, nil)
+				p.baseURL = baseURL
+				return p, p.rates
+			}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			srv, full, notModified := conditionalUpstream(t, tc.body)
+			c := newFakeCache()
+			p, rc := tc.new(c, srv.URL)
+			start := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+			now := start
+			rc.now = func() time.Time { return now }
+			ctx := context.Background()
+
+			if got, err := p.Convert(ctx, "USD", "BRL", 100); err != nil || got != 500 {
+				t.Fatalf("expected 500, got %d (%v)", got, err)
+			}
+			cached, _ := c.Get(ctx, tc.key)
+			if e, ok := decodeRateEntry(cached); !ok || e.ETag != `"v1"` || e.LastModified != lastModified {
+				t.Fatalf("expected the validators stored with the rates, got %+v", e)
+			}
+
+			// past the TTL the entry is revalidated, not downloaded again
+			now = start.Add(11 * time.Minute)
+			if got, err := p.Convert(ctx, "USD", "BRL", 100); err != nil || got != 500 {
+				t.Fatalf("expected 500 from the revalidated rates, got %d (%v)", got, err)
+			}
+			if full.Load() != 1 || notModified.Load() != 1 {
+				t.Fatalf("expected one download and one revalidation, got %d and %d", full.Load(), notModified.Load())
+			}
+			cached, _ = c.Get(ctx, tc.key)
+			if e, _ := decodeRateEntry(cached); !e.FetchedAt.Equal(now) || e.ETag != `"v1"` {
+				t.Fatalf("expected the entry renewed, got %+v", e)
+			}
+
+			// the renewed entry is fresh again
+			if _, err := p.Convert(ctx, "USD", "BRL", 100); err != nil || notModified.Load() != 1 {
+				t.Fatalf("expected a cache hit, got %d revalidations (%v)", notModified.Load(), err)
+			}
+			if res := rc.fetches.result(tc.name); res.Status != "ok" {
+				t.Fatalf("expected a 304 to count as a successful fetch, got %+v", res)
+			}
+		})
+	}
+}
+
+func TestConditionalBackgroundRefresh(t *testing.T) {
+	srv, full, notModified := conditionalUpstream(t, `{"success":true,"rates":{"BRL":5}}`)
+	c := newFakeCache()
+	p := NewExchangerateHost(nil, "", c, 10*time.Minute, nil)
+	p.baseURL, p.rates.softTTL = srv.URL, time.Minute
+	start := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	p.rates.now = func() time.Time { return start }
+	ctx := context.Background()
+
+	if _, err := p.Convert(ctx, "USD", "BRL", 100); err != nil {
+		t.Fatal(err)
+	}
+	later := start.Add(5 * time.Minute)
+	p.rates.now = func() time.Time { return later }
+	res, err := p.ConvertDetailed(ctx, "USD", "BRL", 100)
+	if err != nil || !res.Stale {
+		t.Fatalf("expected stale rates while revalidating, got %+v (%v)", res, err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		cached, _ := c.Get(ctx, "rates:exchangerate.host:USD")
+		if e, _ := decodeRateEntry(cached); e.FetchedAt.Equal(later) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("background revalidation did not renew the entry")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if full.Load() != 1 || notModified.Load() != 1 {
+		t.Fatalf("expected one download and one revalidation, got %d and %d", full.Load(), notModified.Load())
+	}
+}
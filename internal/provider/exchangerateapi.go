@@ -4,7 +4,6 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"io"
 	"math"
 	"net/http"
 	"time"
@@ -13,14 +12,26 @@ import (
 )
 
 type ExchangeRateAPI struct {
-	log     *logger.Logger
-	cache   Cache
-	baseURL string
-	apiKey  string
+	log      *logger.Logger
+	rates    *rateCache
+	fallback *baseFallback
+	baseURL  string
+	apiKey   string
+	clock    *SkewClock
+	client   *http.Client
+	retry    RetryPolicy
+	// bodyLimit caps how much of an upstream body is logged
+	bodyLimit int
 }
 
-func NewExchangeRateAPI(lg *logger.Logger, apiKey string, c Cache) *ExchangeRateAPI {
-	return &ExchangeRateAPI{baseURL: "https://v6.exchangerate-api.com/v6", log: lg, apiKey: apiKey, cache: c}
+// NewExchangeRateAPI constructs the exchangerate-api.com provider. Upstream
+// rates are cached for ratesTTL, then revalidated with conditional requests;
+// zero disables rate caching. A nil client means
+// http.DefaultClient.
+func NewExchangeRateAPI(lg *logger.Logger, apiKey string, c Cache, ratesTTL time.Duration, client *http.Client) *ExchangeRateAPI {
+	lg = lg.With(map[string]any{"provider": nameExchangeRateAPI})
+	return &ExchangeRateAPI{baseURL: exchangeRateAPIURL, log: lg, apiKey: apiKey,
+		rates: newConditionalRateCache(c, lg, ratesTTL), client: client}
 }
 
 type eraResponse struct {
@@ -30,99 +41,155 @@ type eraResponse struct {
 	TimeLastUpdate  int64              `json:"time_last_update_unix"`
 	BaseCode        string             `json:"base_code"`
 	ConversionRates map[string]float64 `json:"conversion_rates"`
+	ErrorType       string             `json:"error-type"`
 }
 
+// eraUnsupportedCode is the error-type of a base currency exchangerate-api
+// does not serve.
+const eraUnsupportedCode = "unsupported-code"
+
 func (p *ExchangeRateAPI) Convert(ctx context.Context, from, to string, amount int64) (int64, error) {
+	res, err := p.ConvertDetailed(ctx, from, to, amount)
+	return res.ResultCents, err
+}
+
+func (p *ExchangeRateAPI) ConvertDetailed(ctx context.Context, from, to string, amount int64) (_ ConvertResult, err error) {
+	ctx, obs := startConvert(ctx, nameExchangeRateAPI, from, to)
+	defer func() { obs.end(ctx, err) }()
+
+	er, loaded, rateTS, base, err := p.table(ctx, from)
+	if err != nil {
+		return ConvertResult{}, err
+	}
+
+	// find the target rate
+	rate, derived, ok := crossRate(er.ConversionRates, base, from, to)
+	if !ok {
+		err := fmt.Errorf("currency %s not found in exchange rates", missingCode(er.ConversionRates, from, to, derived))
+		if p.log != nil {
+			p.log.ErrorCtx(ctx, err, "currency not found in conversion rates", map[string]any{"from": from, "to": to, "base": base})
+		}
+		return ConvertResult{}, err
+	}
+
+	// amount units = amount cents / 100; multiply by rate to get target units
+	amountUnits := float64(amount) / 100.0
+	resultUnits := amountUnits * rate
+	if p.log != nil {
+		p.log.WithContext(ctx).Debugf("exchange convert computed: units=%v rate=%v result=%v", amountUnits, rate, resultUnits)
+	}
+	resultCents := int64(math.Round(resultUnits * 100.0))
+	return ConvertResult{ResultCents: resultCents, Rate: rate, RateTimestamp: rateTS, Stale: loaded.Stale,
+		Source: nameExchangeRateAPI, CacheHit: loaded.CacheHit, Derived: derived}, nil
+}
+
+// table returns the rates that convert from: the table of from, or the one of
+// FALLBACK_BASE when the upstream does not serve from as a base.
+func (p *ExchangeRateAPI) table(ctx context.Context, from string) (er eraResponse, loaded rateLoad, rateTS time.Time, base string, err error) {
+	base = p.fallback.baseFor(from)
+	er, loaded, rateTS, err = p.latest(ctx, base)
+	if base == from && p.fallback.retry(ctx, from, err) {
+		base = p.fallback.base
+		er, loaded, rateTS, err = p.latest(ctx, base)
+	}
+	return er, loaded, rateTS, base, err
+}
+
+// latest returns the validated rates for base currency from, served from the
+// rate cache when possible, with the time they were published.
+func (p *ExchangeRateAPI) latest(ctx context.Context, from string) (eraResponse, rateLoad, time.Time, error) {
 	if p.apiKey == "" {
-		return 0, MissingAPIKeyError{Info: "api key not provided for exchangerate-api"}
+		return eraResponse{}, rateLoad{}, time.Time{}, MissingAPIKeyError{Info: "api key not provided for exchangerate-api"}
 	}
 
-	// Try cache of rates per base currency to avoid repeated upstream calls.
-	cacheKey := "rates:exchangerate-api:" + from
-	var raw []byte
-	if p.cache != nil {
-		if cached, err := p.cache.Get(ctx, cacheKey); err == nil && cached != "" {
-			if p.log != nil {
-				p.log.WithContext(ctx).Debugf("using cached rates for base=%s", from)
-			}
+	// Rates are cached per base currency to avoid repeated upstream calls.
+	loaded, err := p.rates.load(ctx, "rates:exchangerate-api:"+from, p.fetchLatest(from))
+	if err != nil {
+		return eraResponse{}, rateLoad{}, time.Time{}, err
+	}
+	observationFrom(ctx).recordLookup(loaded.CacheHit)
+	if loaded.CacheHit && p.log != nil {
+		p.log.WithContext(ctx).Debugf("using cached rates for base=%s stale=%t", from, loaded.Stale)
+	}
+	raw := loaded.Body
 
-			raw = []byte(cached)
+	var er eraResponse
+	if err := json.Unmarshal(raw, &er); err != nil {
+		if p.log != nil {
+			p.log.ErrorCtx(ctx, err, "decode exchange response error", nil)
 		}
+		return eraResponse{}, rateLoad{}, time.Time{}, err
 	}
-	if raw == nil {
-		url := fmt.Sprintf("%s/%s/latest/%s", p.baseURL, p.apiKey, from)
-		req, _ := http.NewRequestWithContext(ctx, "GET", url, nil)
-		resp, err := http.DefaultClient.Do(req)
-		if err != nil {
-			if p.log != nil {
-				p.log.WithContext(ctx).Errorf("exchange request error: %v", err)
-			}
 
-			return 0, err
+	if er.Result != "success" {
+		// exchange-rate-api returns result != "success" for invalid/missing API key
+		var err error = MissingAPIKeyError{Info: "upstream returned non-success result"}
+		if er.ErrorType == eraUnsupportedCode {
+			err = BaseNotSupportedError{Base: from, Code: er.ErrorType}
 		}
+		if p.log != nil {
+			p.log.ErrorCtx(ctx, err, "exchange response not successful", map[string]any{"result": er.Result})
+		}
+		return eraResponse{}, rateLoad{}, time.Time{}, err
+	}
 
-		defer resp.Body.Close()
-
-		if resp.StatusCode != http.StatusOK {
-			body, _ := io.ReadAll(resp.Body)
+	rateTS := loaded.FetchedAt
+	if er.TimeLastUpdate > 0 {
+		rateTS = time.Unix(er.TimeLastUpdate, 0)
+		p.clock.ObserveRateTime(ctx, rateTS)
+		if err := p.clock.CheckFresh(rateTS); err != nil {
 			if p.log != nil {
-				p.log.WithContext(ctx).Errorf("exchange request failed status=%d body=%s", resp.StatusCode, string(body))
+				p.log.ErrorCtx(ctx, err, "exchange rates rejected", map[string]any{"base": from})
 			}
-
-			return 0, fmt.Errorf("exchange request failed status=%d", resp.StatusCode)
+			return eraResponse{}, rateLoad{}, time.Time{}, err
 		}
+	}
+	return er, loaded, rateTS, nil
+}
 
-		r, err := io.ReadAll(resp.Body)
+// fetchLatest returns the upstream fetch of the rates for base from.
+func (p *ExchangeRateAPI) fetchLatest(from string) func(ctx context.Context) ([]byte, error) {
+	return func(ctx context.Context) ([]byte, error) {
+		url := fmt.Sprintf("%s/%s/latest/%s", p.baseURL, p.apiKey, from)
+		res, err := fetchWithRetry(ctx, p.client, url, p.clock, p.retry, p.log, p.apiKey)
 		if err != nil {
 			if p.log != nil {
-				p.log.WithContext(ctx).Errorf("failed reading exchange response body: %v", err)
+				p.log.ErrorCtx(ctx, err, "exchange request error", nil)
 			}
-			return 0, err
-		}
-
-		raw = r
 
-		if p.cache != nil {
-			// cache raw rates for 20 minutes
-			_ = p.cache.Set(ctx, cacheKey, string(raw), 20*time.Minute)
+			return nil, err
 		}
 
-		if p.log != nil {
-			p.log.WithContext(ctx).Debugf("exchange raw response for url=%s body=%s", url, string(raw))
-		}
-	}
-
-	var er eraResponse
-	if err := json.Unmarshal(raw, &er); err != nil {
-		if p.log != nil {
-			p.log.WithContext(ctx).Errorf("decode exchange response error: %v", err)
+		if res.Status == http.StatusNotModified {
+			return nil, errNotModified
 		}
-		return 0, err
-	}
+		if res.Status != http.StatusOK {
+			var failed eraResponse
+			if json.Unmarshal(res.Body, &failed) == nil && failed.ErrorType == eraUnsupportedCode {
+				return nil, BaseNotSupportedError{Base: from, Code: failed.ErrorType}
+			}
+			err := fmt.Errorf("exchange request failed status=%d", res.Status)
+			if p.log != nil {
+				body, truncated := logBody(res.Body, p.bodyLimit)
+				p.log.ErrorCtx(ctx, err, "exchange request failed", map[string]any{"status": res.Status, "body": body, "truncated": truncated})
+			}
 
-	if er.Result != "success" {
-		if p.log != nil {
-			p.log.WithContext(ctx).Errorf("exchange response not successful: result=%s", er.Result)
+			return nil, err
 		}
-		// exchange-rate-api returns result != "success" for invalid/missing API key
-		return 0, MissingAPIKeyError{Info: "upstream returned non-success result"}
-	}
 
-	// find the target rate
-	rate, ok := er.ConversionRates[to]
-	if !ok {
 		if p.log != nil {
-			p.log.WithContext(ctx).Errorf("target currency %s not found in conversion rates", to)
+			body, truncated := logBody(res.Body, p.bodyLimit)
+			p.log.WithContext(ctx).Debugf("exchange raw response for url=%s body=%s truncated=%t", redactURL(url, p.apiKey), body, truncated)
 		}
-		return 0, fmt.Errorf("currency %s not found in exchange rates", to)
+		return res.Body, nil
 	}
+}
 
-	// amount units = amount cents / 100; multiply by rate to get target units
-	amountUnits := float64(amount) / 100.0
-	resultUnits := amountUnits * rate
-	if p.log != nil {
-		p.log.WithContext(ctx).Debugf("exchange convert computed: units=%v rate=%v result=%v", amountUnits, rate, resultUnits)
+// Currencies lists the currency codes served for base.
+func (p *ExchangeRateAPI) Currencies(ctx context.Context, base string) ([]string, error) {
+	er, _, _, tableBase, err := p.table(ctx, base)
+	if err != nil {
+		return nil, err
 	}
-	resultCents := int64(math.Round(resultUnits * 100.0))
-	return resultCents, nil
+	return rateCodes(tableBase, er.ConversionRates), nil
 }
@@ -0,0 +1,98 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+
+	"github.com/thiagozs/go-exchange/internal/logger"
+)
+
+// BaseNotSupportedError is returned when the upstream refuses to serve rates
+// for Base, like the base currencies restricted to paid plans. It matches
+// ErrCurrencyNotSupported.
+type BaseNotSupportedError struct {
+	Base string
+	// Code is the upstream error code.
+	Code string
+}
+
+func (e BaseNotSupportedError) Error() string {
+	return "base currency " + e.Base + " not supported upstream (" + e.Code + ")"
+}
+
+func (e BaseNotSupportedError) Unwrap() error { return ErrCurrencyNotSupported }
+
+// baseFallback derives cross rates from the table of a fallback base
+// (FALLBACK_BASE) for base currencies the upstream refuses. Refused bases are
+// remembered, so later conversions go straight to the fallback table instead
+// of spending an upstream call, and quota, on a known refusal.
+type baseFallback struct {
+	base string // empty disables the fallback
+	log  *logger.Logger
+
+	mu          sync.Mutex
+	unsupported map[string]bool
+}
+
+func newBaseFallback(base string, lg *logger.Logger) *baseFallback {
+	return &baseFallback{base: strings.ToUpper(base), log: lg, unsupported: map[string]bool{}}
+}
+
+// baseFor is the base whose table converts from: from itself, or the
+// fallback base once the upstream refused from.
+func (f *baseFallback) baseFor(from string) string {
+	if f == nil || f.base == "" {
+		return from
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.unsupported[from] {
+		return f.base
+	}
+	return from
+}
+
+// retry reports whether err, from fetching the table of from, is a refused
+// base that the fallback base can stand in for, and remembers the refusal.
+func (f *baseFallback) retry(ctx context.Context, from string, err error) bool {
+	if f == nil || f.base == "" || from == f.base || !errors.As(err, new(BaseNotSupportedError)) {
+		return false
+	}
+	f.mu.Lock()
+	f.unsupported[from] = true
+	f.mu.Unlock()
+	if f.log != nil {
+		f.log.WithContext(ctx).Infof("base %s not supported upstream, deriving its rates from %s: %v", from, f.base, err)
+	}
+	return true
+}
+
+// crossRate returns the rate from->to out of rates, the table of base. When
+// base is not from, the rate is derived as rate(to)/rate(from).
+func crossRate(rates map[string]float64, base, from, to string) (rate float64, derived, ok bool) {
+	if base == from {
+		rate, ok = rates[to]
+		return rate, false, ok
+	}
+	rateFrom, ok := rates[from]
+	if !ok || rateFrom == 0 {
+		return 0, true, false
+	}
+	rateTo := 1.0
+	if to != base {
+		if rateTo, ok = rates[to]; !ok {
+			return 0, true, false
+		}
+	}
+	return rateTo / rateFrom, true, true
+}
+
+// missingCode names the currency crossRate did not find.
+func missingCode(rates map[string]float64, from, to string, derived bool) string {
+	if _, ok := rates[from]; derived && !ok {
+		return from
+	}
+	return to
+}
@@ -0,0 +1,101 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fallbackUpstream serves USD rates and refuses any other base with body,
+// counting the requests per base.
+func fallbackUpstream(t *testing.T, base func(r *http.Request) string, usd string, status int, refusal string) (*httptest.Server, map[string]int) {
+	t.Helper()
+	var mu sync.Mutex
+	calls := map[string]int{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b := base(r)
+		mu.Lock()
+		calls[b]++
+		mu.Unlock()
+		if b == "USD" {
+			_, _ = w.Write([]byte(usd))
+			return
+		}
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(refusal))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, calls
+}
+
+func TestExchangeRateAPIDerivesRefusedBases(t *testing.T) {
+	srv, calls := fallbackUpstream(t,
+		func(r *http.Request) string { return r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:] },
+		`{"result":"success","base_code":"USD","conversion_rates":{"USD":1,"BRL":5.4321,"EUR":0.9123}}`,
+		http.StatusNotFound, `{"result":"error","error-type":"unsupported-code"}`)
+	p := NewExchangeRateAPI(nil, "k", nil, 0, nil)
+	p.baseURL, p.fallback = srv.URL, newBaseFallback("usd", nil)
+
+	direct, err := p.ConvertDetailed(context.Background(), "USD", "BRL", 1_000_000)
+	if err != nil || direct.Derived || direct.Rate != 5.4321 {
+		t.Fatalf("expected the direct USD rate, got %+v (%v)", direct, err)
+	}
+	inverse, err := p.ConvertDetailed(context.Background(), "BRL", "USD", 1_000_000)
+	if err != nil || !inverse.Derived {
+		t.Fatalf("expected a derived BRL->USD rate, got %+v (%v)", inverse, err)
+	}
+	if math.Abs(inverse.Rate*direct.Rate-1) > 1e-12 || inverse.ResultCents != int64(math.Round(1_000_000/5.4321)) {
+		t.Fatalf("expected the inverse of %v, got %+v", direct.Rate, inverse)
+	}
+	cross, err := p.ConvertDetailed(context.Background(), "BRL", "EUR", 1000)
+	if err != nil || !cross.Derived || math.Abs(cross.Rate-0.9123/5.4321) > 1e-12 || cross.ResultCents != 168 {
+		t.Fatalf("expected BRL->EUR as rate(EUR)/rate(BRL), got %+v (%v)", cross, err)
+	}
+	// the refusal is remembered: BRL is asked for once
+	if calls["BRL"] != 1 {
+		t.Fatalf("expected one BRL request, got %v", calls)
+	}
+
+	codes, err := p.Currencies(context.Background(), "BRL")
+	if err != nil || strings.Join(codes, ",") != "BRL,EUR,USD" {
+		t.Fatalf("expected the codes of the USD table, got %v (%v)", codes, err)
+	}
+}
+
+func TestExchangerateHostDerivesRefusedBases(t *testing.T) {
+	srv, calls := fallbackUpstream(t,
+		func(r *http.Request) string { return r.URL.Query().Get("base") },
+		`{"success":true,"rates":{"BRL":5,"EUR":0.8}}`,
+		http.StatusOK, `{"success":false,"error":{"code":105,"type":"base_currency_access_restricted"}}`)
+	p := &ExchangerateHost{baseURL: srv.URL, fallback: newBaseFallback("USD", nil)}
+
+	res, err := p.ConvertDetailed(context.Background(), "EUR", "BRL", 1000)
+	if err != nil || !res.Derived || res.Rate != 5/0.8 || res.ResultCents != 6250 {
+		t.Fatalf("expected EUR->BRL derived from USD, got %+v (%v)", res, err)
+	}
+	if _, err := p.ConvertDetailed(context.Background(), "EUR", "GBP", 1000); err == nil || !strings.Contains(err.Error(), "GBP") {
+		t.Fatalf("expected GBP to be reported missing, got %v", err)
+	}
+	if calls["EUR"] != 1 || calls["USD"] != 2 {
+		t.Fatalf("expected one EUR request and the USD table afterwards, got %v", calls)
+	}
+}
+
+func TestRefusedBaseWithoutFallback(t *testing.T) {
+	srv, _ := fallbackUpstream(t,
+		func(r *http.Request) string { return r.URL.Query().Get("base") },
+		`{"success":true,"rates":{"BRL":5}}`,
+		http.StatusOK, `{"success":false,"error":{"code":105,"type":"base_currency_access_restricted"}}`)
+	p := &ExchangerateHost{baseURL: srv.URL, fallback: newBaseFallback("", nil)}
+
+	_, err := p.ConvertDetailed(context.Background(), "EUR", "BRL", 1000)
+	var berr BaseNotSupportedError
+	if !errors.As(err, &berr) || berr.Base != "EUR" || !errors.Is(err, ErrCurrencyNotSupported) {
+		t.Fatalf("expected a base not supported error, got %v", err)
+	}
+}
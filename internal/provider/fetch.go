@@ -0,0 +1,48 @@
+package provider
+
+import (
+	"context"
+	"io"
+	"net/http"
+)
+
+// fetchResult is the raw outcome of an upstream GET request.
+type fetchResult struct {
+	Status int
+	Header http.Header
+	Body   []byte
+}
+
+// fetch performs a GET request against url and reads the whole body. The
+// response Date header is fed into clock (if any) so skew is tracked on every
+// upstream call, and the request is counted against the provider quota.
+// Within a conditional fetch the request carries the cached entry's
+// validators. Non-200 statuses are not treated as errors here. A nil client
+// means http.DefaultClient.
+func fetch(ctx context.Context, client *http.Client, url string, clock *SkewClock) (*fetchResult, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	cond := conditionalFrom(ctx)
+	cond.apply(req)
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	quotaFrom(ctx).count(ctx)
+
+	clock.ObserveDate(ctx, resp.Header)
+	cond.record(resp.Header)
+	observationFrom(ctx).setStatus(resp.StatusCode)
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	return &fetchResult{Status: resp.StatusCode, Header: resp.Header, Body: body}, nil
+}
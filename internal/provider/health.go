@@ -0,0 +1,125 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/thiagozs/go-exchange/internal/health"
+)
+
+// fetchHealth remembers the outcome of the latest upstream rate fetches, so
+// health checks can report on the provider without calling it.
+type fetchHealth struct {
+	mu          sync.Mutex
+	lastSuccess time.Time
+	lastFailure time.Time
+	lastErr     error
+}
+
+// observe wraps fetch to record its outcome.
+func (h *fetchHealth) observe(fetch func(ctx context.Context) ([]byte, error)) func(ctx context.Context) ([]byte, error) {
+	return func(ctx context.Context) ([]byte, error) {
+		body, err := fetch(ctx)
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		// a 304 is a successful revalidation
+		if err != nil && !errors.Is(err, errNotModified) {
+			h.lastFailure, h.lastErr = time.Now(), err
+		} else {
+			h.lastSuccess = time.Now()
+		}
+		return body, err
+	}
+}
+
+// result is ok until a fetch fails. A failure after a success is degraded,
+// since cached rates may still be served; failing without ever succeeding is
+// unhealthy. No fetch at all yet (nothing converted) is ok.
+func (h *fetchHealth) result(name string) health.Result {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	details := map[string]any{"provider": name}
+	if !h.lastSuccess.IsZero() {
+		details["last_fetch"] = h.lastSuccess.UTC()
+	}
+	if h.lastErr == nil || h.lastSuccess.After(h.lastFailure) {
+		return health.OK(details)
+	}
+	res := health.Fail(health.StatusDegraded, h.lastErr)
+	if h.lastSuccess.IsZero() {
+		res.Status = health.StatusUnhealthy
+	}
+	details["last_failure"] = h.lastFailure.UTC()
+	res.Details = details
+	return res
+}
+
+// HealthCheck reports the latest rate fetches.
+func (p *ExchangerateHost) HealthCheck(ctx context.Context) health.Result {
+	return p.rates.fetches.result(nameExchangerateHost)
+}
+
+// HealthCheck reports the latest rate fetches.
+func (p *ExchangeRateAPI) HealthCheck(ctx context.Context) health.Result {
+	return p.rates.fetches.result(nameExchangeRateAPI)
+}
+
+// HealthCheck reports the latest rate fetches.
+func (p *BCBProvider) HealthCheck(ctx context.Context) health.Result {
+	return p.rates.fetches.result(nameBCB)
+}
+
+// HealthCheck reloads the rates file when it changed. Rates that fail to
+// reload are still served, so that only degrades the provider.
+func (s *StaticProvider) HealthCheck(ctx context.Context) health.Result {
+	err := s.reload(ctx)
+	s.mu.RLock()
+	loaded, modTime := s.rates != nil, s.modTime
+	s.mu.RUnlock()
+	if !loaded {
+		if err == nil {
+			err = errors.New("no rates loaded")
+		}
+		return health.Fail(health.StatusUnhealthy, err)
+	}
+	details := map[string]any{"provider": "static", "rates_modified": modTime.UTC()}
+	if err != nil {
+		res := health.Fail(health.StatusDegraded, err)
+		res.Details = details
+		return res
+	}
+	return health.OK(details)
+}
+
+// HealthCheck checks the sources that implement health.Checker; the others
+// count as healthy. It is unhealthy when fewer than quorum sources are
+// healthy or degraded, and degraded when any of them is not ok.
+func (a *AggregateProvider) HealthCheck(ctx context.Context) health.Result {
+	components := make([]health.Component, 0, len(a.sources))
+	for _, src := range a.sources {
+		c, ok := As[health.Checker](src.Provider)
+		if !ok {
+			c = health.CheckerFunc(func(context.Context) health.Result { return health.OK(nil) })
+		}
+		components = append(components, health.Component{Name: src.Name, Checker: c, Critical: true})
+	}
+	rep := health.Run(ctx, a.timeout, components)
+	up := 0
+	for _, res := range rep.Components {
+		if res.Status != health.StatusUnhealthy {
+			up++
+		}
+	}
+	res := health.Result{Status: rep.Status, Details: map[string]any{"provider": "aggregate", "sources": rep.Components}}
+	switch {
+	case up < a.quorum:
+		res.Status = health.StatusUnhealthy
+		res.Error = fmt.Sprintf("%d of %d sources healthy, quorum is %d", up, len(a.sources), a.quorum)
+	case rep.Status == health.StatusUnhealthy:
+		res.Status = health.StatusDegraded
+	}
+	return res
+}
@@ -0,0 +1,38 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/thiagozs/go-exchange/internal/health"
+)
+
+func TestProviderHealthFollowsFetches(t *testing.T) {
+	// no rate cache, so every load fetches
+	p := NewExchangerateHost(nil, "", nil, 0, nil)
+	if res := p.HealthCheck(context.Background()); res.Status != health.StatusOK || res.Details["last_fetch"] != nil {
+		t.Fatalf("expected ok before any fetch, got %+v", res)
+	}
+
+	fail := func(context.Context) ([]byte, error) { return nil, errors.New("upstream down") }
+	ok := func(context.Context) ([]byte, error) { return []byte(`{}`), nil }
+	steps := []struct {
+		fetch func(context.Context) ([]byte, error)
+		want  health.Status
+	}{
+		{fail, health.StatusUnhealthy},
+		{ok, health.StatusOK},
+		{fail, health.StatusDegraded},
+	}
+	for i, step := range steps {
+		_, _ = p.rates.load(context.Background(), "rates:USD", step.fetch)
+		res := p.HealthCheck(context.Background())
+		if res.Status != step.want {
+			t.Fatalf("step %d: expected %s, got %+v", i, step.want, res)
+		}
+		if step.want != health.StatusUnhealthy && res.Details["last_fetch"] == nil {
+			t.Fatalf("step %d: expected the last fetch time, got %+v", i, res)
+		}
+	}
+}
@@ -0,0 +1,236 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/thiagozs/go-exchange/internal/logger"
+)
+
+// RatesRefresher is implemented by providers that cache rates per base
+// currency and can refetch them ahead of expiry.
+type RatesRefresher interface {
+	// RefreshRates refetches the rates for base unless they stay cached for
+	// longer than lead, and returns when the cached rates expire. The zero
+	// time means rates are not cached.
+	RefreshRates(ctx context.Context, base string, lead time.Duration) (time.Time, error)
+}
+
+// RefreshRates refetches the rates for base close to their expiry.
+func (p *ExchangerateHost) RefreshRates(ctx context.Context, base string, lead time.Duration) (time.Time, error) {
+	from := p.fallback.baseFor(strings.ToUpper(base))
+	return p.rates.refresh(ctx, "rates:exchangerate.host:"+from, p.fetchLatest(from), lead)
+}
+
+// RefreshRates refetches the rates for base close to their expiry.
+func (p *ExchangeRateAPI) RefreshRates(ctx context.Context, base string, lead time.Duration) (time.Time, error) {
+	from := p.fallback.baseFor(strings.ToUpper(base))
+	return p.rates.refresh(ctx, "rates:exchangerate-api:"+from, p.fetchLatest(from), lead)
+}
+
+// RefreshRates refreshes every source that caches rates per base, and
+// returns the earliest expiry among them.
+func (a *AggregateProvider) RefreshRates(ctx context.Context, base string, lead time.Duration) (time.Time, error) {
+	var (
+		expires time.Time
+		errs    []error
+	)
+	for _, src := range a.sources {
+		r, ok := As[RatesRefresher](src.Provider)
+		if !ok {
+			continue
+		}
+		exp, err := r.RefreshRates(ctx, base, lead)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", src.Name, err))
+			continue
+		}
+		if !exp.IsZero() && (expires.IsZero() || exp.Before(expires)) {
+			expires = exp
+		}
+	}
+	return expires, errors.Join(errs...)
+}
+
+// hotRetry is how long a hot base waits after a failed refresh, or when its
+// rates are not cached, before it is tried again.
+const hotRetry = 30 * time.Second
+
+// HotBase is a base currency tracked by HotRefresher.
+type HotBase struct {
+	Base string `json:"base"`
+	// Score is the decayed request count.
+	Score float64 `json:"score"`
+	Hot   bool    `json:"hot"`
+	// NextRefresh is omitted for bases that are not hot.
+	NextRefresh *time.Time `json:"next_refresh,omitempty"`
+	LastRefresh *time.Time `json:"last_refresh,omitempty"`
+	LastError   string     `json:"last_error,omitempty"`
+}
+
+type hotBase struct {
+	score       float64
+	updated     time.Time
+	next        time.Time
+	lastRefresh time.Time
+	lastErr     error
+}
+
+// HotRefresher refreshes the rates of the most requested base currencies
+// shortly before they expire, so their requests keep hitting the cache.
+// Requests are counted per base with an exponential decay of halfLife; bases
+// scoring at least threshold are hot. Refreshes go through the rate cache of
+// the provider, sharing the fetches already in flight for the same rates.
+type HotRefresher struct {
+	refresher RatesRefresher
+	threshold float64
+	halfLife  time.Duration
+	lead      time.Duration
+	interval  time.Duration
+	log       *logger.Logger
+	now       func() time.Time
+
+	mu    sync.Mutex
+	bases map[string]*hotBase
+}
+
+// NewHotRefresher constructs a HotRefresher checking every interval for hot
+// bases whose rates expire within lead.
+func NewHotRefresher(r RatesRefresher, threshold float64, halfLife, lead, interval time.Duration, lg *logger.Logger) *HotRefresher {
+	return &HotRefresher{refresher: r, threshold: threshold, halfLife: halfLife, lead: lead, interval: interval,
+		log: lg, now: time.Now, bases: map[string]*hotBase{}}
+}
+
+// decayed returns the score of b at now.
+func (h *HotRefresher) decayed(b *hotBase, now time.Time) float64 {
+	if h.halfLife <= 0 {
+		return b.score
+	}
+	return b.score * math.Exp2(-float64(now.Sub(b.updated))/float64(h.halfLife))
+}
+
+// Record counts a request for the rates of base.
+func (h *HotRefresher) Record(base string) {
+	if h == nil {
+		return
+	}
+	now := h.now()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	b, ok := h.bases[base]
+	if !ok {
+		b = &hotBase{}
+		h.bases[base] = b
+	}
+	b.score, b.updated = h.decayed(b, now)+1, now
+}
+
+// Run checks for due refreshes every interval until ctx is done.
+func (h *HotRefresher) Run(ctx context.Context) {
+	t := time.NewTicker(h.interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		h.Tick(ctx)
+	}
+}
+
+// Tick refreshes the hot bases that are due, concurrently, and forgets the
+// bases that cooled down to nothing.
+func (h *HotRefresher) Tick(ctx context.Context) {
+	now := h.now()
+	var due []string
+	h.mu.Lock()
+	for base, b := range h.bases {
+		score := h.decayed(b, now)
+		switch {
+		case score >= h.threshold:
+			if !b.next.After(now) {
+				due = append(due, base)
+			}
+		case score < 0.01:
+			delete(h.bases, base)
+		default:
+			// cooled down: schedule from scratch if it heats up again
+			b.next = time.Time{}
+		}
+	}
+	h.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, base := range due {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			h.refresh(ctx, base)
+		}()
+	}
+	wg.Wait()
+}
+
+func (h *HotRefresher) refresh(ctx context.Context, base string) {
+	expires, err := h.refresher.RefreshRates(ctx, base, h.lead)
+	now := h.now()
+	next := expires.Add(-h.lead)
+	if err != nil || expires.IsZero() || !next.After(now) {
+		next = now.Add(hotRetry)
+	}
+	if err != nil && h.log != nil {
+		h.log.WithContext(ctx).Warnf("refresh of hot base %s failed: %v", base, err)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	b, ok := h.bases[base]
+	if !ok {
+		return
+	}
+	b.next, b.lastRefresh, b.lastErr = next, now, err
+}
+
+// Hot reports the tracked bases, hottest first.
+func (h *HotRefresher) Hot() []HotBase {
+	if h == nil {
+		return nil
+	}
+	now := h.now()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	out := make([]HotBase, 0, len(h.bases))
+	for base, b := range h.bases {
+		hb := HotBase{Base: base, Score: math.Round(h.decayed(b, now)*100) / 100}
+		hb.Hot = h.decayed(b, now) >= h.threshold
+		if hb.Hot {
+			next := b.next
+			if next.Before(now) {
+				next = now
+			}
+			hb.NextRefresh = &next
+		}
+		if !b.lastRefresh.IsZero() {
+			last := b.lastRefresh
+			hb.LastRefresh = &last
+		}
+		if b.lastErr != nil {
+			hb.LastError = b.lastErr.Error()
+		}
+		out = append(out, hb)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Score != out[j].Score {
+			return out[i].Score > out[j].Score
+		}
+		return out[i].Base < out[j].Base
+	})
+	return out
+}
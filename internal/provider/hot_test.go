@@ -0,0 +1,142 @@
+package provider
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// clockRefresher caches rates for ttl from every refresh, on the clock of
+// now, and records when each base was refreshed.
+type clockRefresher struct {
+	now func() time.Time
+	ttl time.Duration
+
+	mu        sync.Mutex
+	expires   map[string]time.Time
+	refreshed map[string][]time.Time
+}
+
+func (r *clockRefresher) RefreshRates(_ context.Context, base string, lead time.Duration) (time.Time, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := r.now()
+	if exp, ok := r.expires[base]; ok && now.Before(exp.Add(-lead)) {
+		return exp, nil
+	}
+	r.expires[base] = now.Add(r.ttl)
+	r.refreshed[base] = append(r.refreshed[base], now)
+	return r.expires[base], nil
+}
+
+func TestHotRefresherRefreshesHotBasesBeforeExpiry(t *testing.T) {
+	start := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	now := start
+	clock := func() time.Time { return now }
+	r := &clockRefresher{now: clock, ttl: 10 * time.Minute, expires: map[string]time.Time{}, refreshed: map[string][]time.Time{}}
+	h := NewHotRefresher(r, 5, time.Minute, 30*time.Second, 10*time.Second, nil)
+	h.now = clock
+	ctx := context.Background()
+
+	for range 10 {
+		h.Record("USD")
+	}
+	h.Record("EUR")
+	// keep USD hot while the clock runs 25 minutes, checking every 10s
+	for now.Before(start.Add(25 * time.Minute)) {
+		h.Record("USD")
+		h.Tick(ctx)
+		now = now.Add(10 * time.Second)
+	}
+
+	usd := r.refreshed["USD"]
+	if len(usd) != 3 {
+		t.Fatalf("expected USD refreshed 3 times, got %v", usd)
+	}
+	for i := 1; i < len(usd); i++ {
+		expiry := usd[i-1].Add(r.ttl)
+		if !usd[i].Before(expiry) || usd[i].Before(expiry.Add(-30*time.Second)) {
+			t.Fatalf("refresh %d at %s, expected within 30s before the expiry %s", i, usd[i], expiry)
+		}
+	}
+	if len(r.refreshed["EUR"]) != 0 {
+		t.Fatalf("expected cold EUR never refreshed, got %v", r.refreshed["EUR"])
+	}
+
+	hot := h.Hot()
+	if len(hot) != 1 || hot[0].Base != "USD" || !hot[0].Hot || hot[0].NextRefresh == nil {
+		t.Fatalf("expected only USD tracked, EUR cooled down and forgotten, got %+v", hot)
+	}
+
+	// once requests stop USD cools down and is not refreshed anymore
+	now = now.Add(5 * time.Minute)
+	h.Tick(ctx)
+	if len(r.refreshed["USD"]) != 3 {
+		t.Fatalf("expected no refresh of a cooled down base, got %v", r.refreshed["USD"])
+	}
+	if hot := h.Hot(); len(hot) != 1 || hot[0].Hot || hot[0].NextRefresh != nil {
+		t.Fatalf("expected USD cooled down, got %+v", hot)
+	}
+}
+
+func TestRateCacheRefreshSkipsFreshAndSharesFetches(t *testing.T) {
+	var calls atomic.Int32
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		<-release
+		_, _ = w.Write([]byte(`{"success":true,"rates":{"BRL":5}}`))
+	}))
+	t.Cleanup(srv.Close)
+	c := newFakeCache()
+	p := NewExchangerateHost(nil, "", c, 10*time.Minute, nil)
+	p.baseURL = srv.URL
+	start := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	p.rates.now = func() time.Time { return start }
+	ctx := context.Background()
+
+	// concurrent refreshes of a missing entry share one fetch
+	var wg sync.WaitGroup
+	expiries := make([]time.Time, 3)
+	for i := range expiries {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			exp, err := p.RefreshRates(ctx, "usd", time.Minute)
+			if err != nil {
+				t.Error(err)
+			}
+			expiries[i] = exp
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	for _, exp := range expiries {
+		if !exp.Equal(start.Add(10 * time.Minute)) {
+			t.Fatalf("expected every refresh to report the new expiry, got %v", expiries)
+		}
+	}
+	if calls.Load() != 1 {
+		t.Fatalf("expected one upstream fetch, got %d", calls.Load())
+	}
+
+	// an entry valid for longer than lead is left alone
+	p.rates.now = func() time.Time { return start.Add(8 * time.Minute) }
+	if _, err := p.RefreshRates(ctx, "USD", time.Minute); err != nil || calls.Load() != 1 {
+		t.Fatalf("expected no fetch of fresh rates, got %d (%v)", calls.Load(), err)
+	}
+	// within lead of its expiry it is refetched
+	p.rates.now = func() time.Time { return start.Add(9*time.Minute + 30*time.Second) }
+	exp, err := p.RefreshRates(ctx, "USD", time.Minute)
+	if err != nil || calls.Load() != 2 || !exp.Equal(start.Add(19*time.Minute+30*time.Second)) {
+		t.Fatalf("expected a refetch expiring at 12:19:30, got %d fetches and %v (%v)", calls.Load(), exp, err)
+	}
+	if _, err := p.Convert(ctx, "USD", "BRL", 100); err != nil || calls.Load() != 2 {
+		t.Fatalf("expected conversions to hit the refreshed rates, got %d fetches (%v)", calls.Load(), err)
+	}
+}
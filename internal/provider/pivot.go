@@ -0,0 +1,124 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"strings"
+)
+
+// Wrapper is implemented by decorators around a Provider, so the optional
+// interfaces of the provider they wrap stay reachable through As.
+type Wrapper interface {
+	Unwrap() Provider
+}
+
+// As returns the first provider of p's decorator chain, p included, that
+// implements T.
+func As[T any](p Provider) (T, bool) {
+	for p != nil {
+		if t, ok := p.(T); ok {
+			return t, true
+		}
+		w, ok := p.(Wrapper)
+		if !ok {
+			break
+		}
+		p = w.Unwrap()
+	}
+	var zero T
+	return zero, false
+}
+
+// pivotProbeCents is the amount each leg converts to derive its rate, for
+// providers that do not report one.
+const pivotProbeCents = 1_000_000
+
+// PivotProvider converts pairs the wrapped provider has no direct rate for
+// through a pivot currency: from->pivot->to. Only failures matching
+// ErrCurrencyNotSupported are retried through the pivot; any other error is
+// returned as is. It can wrap, and be wrapped by, any other Provider.
+type PivotProvider struct {
+	inner Provider
+	pivot string
+}
+
+// NewPivotProvider wraps inner with pivot (USD when empty).
+func NewPivotProvider(inner Provider, pivot string) *PivotProvider {
+	if pivot == "" {
+		pivot = "USD"
+	}
+	return &PivotProvider{inner: inner, pivot: strings.ToUpper(pivot)}
+}
+
+// Unwrap returns the wrapped provider.
+func (p *PivotProvider) Unwrap() Provider { return p.inner }
+
+func (p *PivotProvider) Convert(ctx context.Context, from, to string, amount int64) (int64, error) {
+	res, err := p.ConvertDetailed(ctx, from, to, amount)
+	return res.ResultCents, err
+}
+
+// ConvertDetailed converts directly when the wrapped provider can, and
+// through the pivot otherwise. A two-leg result is Derived, Stale when either
+// leg is, a cache hit only when both are, and dated by the older leg.
+func (p *PivotProvider) ConvertDetailed(ctx context.Context, from, to string, amount int64) (ConvertResult, error) {
+	from, to = strings.ToUpper(from), strings.ToUpper(to)
+	if from == to {
+		return ConvertResult{ResultCents: amount, Rate: 1}, nil
+	}
+	res, err := p.convert(ctx, from, to, amount)
+	if err == nil || from == p.pivot || to == p.pivot || !errors.Is(err, ErrCurrencyNotSupported) {
+		return res, err
+	}
+
+	first, err := p.leg(ctx, from, p.pivot)
+	if err != nil {
+		return ConvertResult{}, err
+	}
+	second, err := p.leg(ctx, p.pivot, to)
+	if err != nil {
+		return ConvertResult{}, err
+	}
+	cents, rate := crossAmount(amount, first.Rate, second.Rate)
+	ts := first.RateTimestamp
+	if ts.IsZero() || (!second.RateTimestamp.IsZero() && second.RateTimestamp.Before(ts)) {
+		ts = second.RateTimestamp
+	}
+	return ConvertResult{ResultCents: cents, Rate: rate, RateTimestamp: ts,
+		Stale: first.Stale || second.Stale, Source: first.Source,
+		CacheHit: first.CacheHit && second.CacheHit, Derived: true}, nil
+}
+
+// leg converts the probe amount from->to, filling Rate in for providers that
+// do not report it.
+func (p *PivotProvider) leg(ctx context.Context, from, to string) (ConvertResult, error) {
+	res, err := p.convert(ctx, from, to, pivotProbeCents)
+	if err == nil && res.Rate == 0 {
+		res.Rate = float64(res.ResultCents) / pivotProbeCents
+	}
+	return res, err
+}
+
+func (p *PivotProvider) convert(ctx context.Context, from, to string, amount int64) (ConvertResult, error) {
+	if dp, ok := p.inner.(DetailedProvider); ok {
+		return dp.ConvertDetailed(ctx, from, to, amount)
+	}
+	cents, err := p.inner.Convert(ctx, from, to, amount)
+	return ConvertResult{ResultCents: cents}, err
+}
+
+// crossAmount multiplies amount cents by both rates exactly, rounding half
+// away from zero to cents once at the end rather than once per leg. rate is
+// the combined rate.
+func crossAmount(amount int64, first, second float64) (cents int64, rate float64) {
+	r := new(big.Rat).SetFloat64(first)
+	r.Mul(r, new(big.Rat).SetFloat64(second))
+	rate, _ = r.Float64()
+
+	v := new(big.Rat).Mul(r, new(big.Rat).SetInt64(amount))
+	// round(v) = floor(v + 1/2) for the positive amounts converted here
+	v.Add(v, big.NewRat(1, 2))
+	q := new(big.Int).Quo(v.Num(), v.Denom())
+	return q.Int64(), rate
+}
@@ -0,0 +1,153 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/thiagozs/go-exchange/internal/config"
+	"github.com/thiagozs/go-exchange/internal/logger"
+)
+
+// pairProvider quotes only the pairs in rates, keyed "FROM:TO", and records
+// every pair it is asked for.
+type pairProvider struct {
+	rates map[string]ConvertResult
+	calls []string
+}
+
+func (p *pairProvider) Convert(ctx context.Context, from, to string, amount int64) (int64, error) {
+	res, err := p.ConvertDetailed(ctx, from, to, amount)
+	return res.ResultCents, err
+}
+
+func (p *pairProvider) ConvertDetailed(_ context.Context, from, to string, amount int64) (ConvertResult, error) {
+	p.calls = append(p.calls, from+":"+to)
+	res, ok := p.rates[from+":"+to]
+	if !ok {
+		return ConvertResult{}, fmt.Errorf("%w: %s:%s", ErrCurrencyNotSupported, from, to)
+	}
+	res.ResultCents = int64(float64(amount)*res.Rate + 0.5)
+	return res, nil
+}
+
+type failingProvider struct {
+	err   error
+	calls int
+}
+
+func (p *failingProvider) Convert(context.Context, string, string, int64) (int64, error) {
+	p.calls++
+	return 0, p.err
+}
+
+func TestPivotProviderSameCurrency(t *testing.T) {
+	inner := &pairProvider{}
+	res, err := NewPivotProvider(inner, "USD").ConvertDetailed(context.Background(), "brl", "BRL", 1234)
+	if err != nil || res.ResultCents != 1234 || res.Rate != 1 || res.Derived {
+		t.Fatalf("expected 1234 at rate 1, got %+v (%v)", res, err)
+	}
+	if len(inner.calls) != 0 {
+		t.Fatalf("expected no upstream call, got %v", inner.calls)
+	}
+}
+
+func TestPivotProviderDirectPair(t *testing.T) {
+	inner := &pairProvider{rates: map[string]ConvertResult{"EUR:BRL": {Rate: 6}}}
+	res, err := NewPivotProvider(inner, "USD").ConvertDetailed(context.Background(), "EUR", "BRL", 100)
+	if err != nil || res.ResultCents != 600 || res.Derived {
+		t.Fatalf("expected the direct rate, got %+v (%v)", res, err)
+	}
+	if len(inner.calls) != 1 {
+		t.Fatalf("expected a single call, got %v", inner.calls)
+	}
+}
+
+func TestPivotProviderPivotLegs(t *testing.T) {
+	inner := &pairProvider{rates: map[string]ConvertResult{"USD:BRL": {Rate: 5.5}, "JPY:USD": {Rate: 0.0067}}}
+	p := NewPivotProvider(inner, "USD")
+
+	res, err := p.ConvertDetailed(context.Background(), "USD", "BRL", 100)
+	if err != nil || res.ResultCents != 550 || res.Derived {
+		t.Fatalf("from == pivot: expected the direct rate, got %+v (%v)", res, err)
+	}
+	res, err = p.ConvertDetailed(context.Background(), "JPY", "USD", 100_000)
+	if err != nil || res.ResultCents != 670 || res.Derived {
+		t.Fatalf("to == pivot: expected the direct rate, got %+v (%v)", res, err)
+	}
+	// a pair touching the pivot has no other route
+	inner.calls = nil
+	if _, err := p.ConvertDetailed(context.Background(), "USD", "EUR", 100); !errors.Is(err, ErrCurrencyNotSupported) {
+		t.Fatalf("expected ErrCurrencyNotSupported, got %v", err)
+	}
+	if len(inner.calls) != 1 {
+		t.Fatalf("expected no pivot legs, got %v", inner.calls)
+	}
+}
+
+func TestPivotProviderCrossesThroughPivot(t *testing.T) {
+	older, newer := time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC), time.Date(2026, 1, 3, 0, 0, 0, 0, time.UTC)
+	inner := &pairProvider{rates: map[string]ConvertResult{
+		"BRL:EUR": {Rate: 0.17}, // unused: no direct BRL:JPY
+		"BRL:USD": {Rate: 0.18181, RateTimestamp: newer, CacheHit: true},
+		"EUR:JPY": {Rate: 160},
+		"EUR:USD": {Rate: 1.1},
+		"USD:JPY": {Rate: 149.87, RateTimestamp: older, Stale: true, Source: "fake"},
+	}}
+	p := NewPivotProvider(inner, "usd")
+
+	res, err := p.ConvertDetailed(context.Background(), "BRL", "JPY", 12_345)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 12345 * 0.18181 * 149.87 = 336374.89..., rounded once; rounding the
+	// first leg to cents (2244) would give 336308
+	if res.ResultCents != 336375 {
+		t.Fatalf("expected 336375, got %d", res.ResultCents)
+	}
+	if want := 0.18181 * 149.87; res.Rate != want {
+		t.Fatalf("expected rate %v, got %v", want, res.Rate)
+	}
+	if !res.Derived || !res.Stale || res.CacheHit || !res.RateTimestamp.Equal(older) {
+		t.Fatalf("expected derived, stale, uncached and dated by the older leg, got %+v", res)
+	}
+	if want := []string{"BRL:JPY", "BRL:USD", "USD:JPY"}; fmt.Sprint(inner.calls) != fmt.Sprint(want) {
+		t.Fatalf("expected calls %v, got %v", want, inner.calls)
+	}
+
+	// a missing leg fails the conversion
+	if _, err := p.ConvertDetailed(context.Background(), "BRL", "GBP", 100); !errors.Is(err, ErrCurrencyNotSupported) {
+		t.Fatalf("expected ErrCurrencyNotSupported, got %v", err)
+	}
+}
+
+func TestPivotProviderKeepsOtherErrors(t *testing.T) {
+	inner := &failingProvider{err: errors.New("upstream down")}
+	if _, err := NewPivotProvider(inner, "USD").Convert(context.Background(), "BRL", "JPY", 100); !errors.Is(err, inner.err) || inner.calls != 1 {
+		t.Fatalf("expected the upstream error without pivot legs, got %v after %d calls", err, inner.calls)
+	}
+}
+
+func TestRegisteredProviderWrappedWithPivot(t *testing.T) {
+	inner := &pairProvider{rates: map[string]ConvertResult{"BRL:USD": {Rate: 0.2}, "USD:EUR": {Rate: 0.9}}}
+	registerForTest(t, "pivot-test", func(*config.Config, *logger.Logger, Cache) Provider { return inner })
+
+	p, err := NewProviderFromConfig(&config.Config{Provider: "pivot-test", PivotCurrency: "USD"}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := p.Convert(context.Background(), "BRL", "EUR", 1000)
+	if err != nil || got != 180 {
+		t.Fatalf("expected 180 through USD, got %d (%v)", got, err)
+	}
+	if _, ok := As[*pairProvider](p); !ok {
+		t.Fatalf("expected the registered provider to be reachable through As")
+	}
+
+	p, err = NewProviderFromConfig(&config.Config{Provider: "pivot-test", PivotCurrency: "OFF"}, nil, nil)
+	if err != nil || p != Provider(inner) {
+		t.Fatalf("expected the bare provider with PIVOT_CURRENCY=off, got %T (%v)", p, err)
+	}
+}
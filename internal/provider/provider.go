@@ -4,12 +4,13 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"io"
 	"math"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/thiagozs/go-exchange/internal/config"
+	"github.com/thiagozs/go-exchange/internal/httpclient"
 	"github.com/thiagozs/go-exchange/internal/logger"
 )
 
@@ -19,18 +20,43 @@ type Provider interface {
 
 type Cache interface {
 	Get(ctx context.Context, key string) (string, error)
+	// GetWithTTL is Get that also reports how long the value has left; the
+	// TTL is zero for misses and values stored without expiry.
+	GetWithTTL(ctx context.Context, key string) (string, time.Duration, error)
 	Set(ctx context.Context, key string, value string, ttl time.Duration) error
+	// GetOrSet returns the value stored under key, calling fill and storing
+	// its result for ttl on a miss. Implementations make sure concurrent
+	// callers, possibly on other instances, do not all run fill at once.
+	// Nothing is stored when fill fails or returns ""; its value and error
+	// are returned as is.
+	GetOrSet(ctx context.Context, key string, ttl time.Duration, fill func(ctx context.Context) (string, error)) (string, error)
+	Delete(ctx context.Context, key string) error
+	// DeleteByPrefix removes every key starting with prefix and reports how
+	// many were removed.
+	DeleteByPrefix(ctx context.Context, prefix string) (int64, error)
 }
 
 type ExchangerateHost struct {
-	baseURL string
-	log     *logger.Logger
-	apiKey  string
-	cache   Cache
+	baseURL  string
+	log      *logger.Logger
+	apiKey   string
+	rates    *rateCache
+	fallback *baseFallback
+	clock    *SkewClock
+	client   *http.Client
+	retry    RetryPolicy
+	// bodyLimit caps how much of an upstream body is logged
+	bodyLimit int
 }
 
-func NewExchangerateHost(lg *logger.Logger, apiKey string, c Cache) *ExchangerateHost {
-	return &ExchangerateHost{baseURL: "https://api.exchangerate.host", log: lg, apiKey: apiKey, cache: c}
+// NewExchangerateHost constructs the exchangerate.host provider. Upstream rates
+// are cached for ratesTTL, then revalidated with conditional requests; zero
+// disables rate caching. A nil client means
+// http.DefaultClient.
+func NewExchangerateHost(lg *logger.Logger, apiKey string, c Cache, ratesTTL time.Duration, client *http.Client) *ExchangerateHost {
+	lg = lg.With(map[string]any{"provider": nameExchangerateHost})
+	return &ExchangerateHost{baseURL: exchangerateHostURL, log: lg, apiKey: apiKey,
+		rates: newConditionalRateCache(c, lg, ratesTTL), client: client}
 }
 
 type MissingAPIKeyError struct {
@@ -40,117 +66,225 @@ type MissingAPIKeyError struct {
 func (e MissingAPIKeyError) Error() string { return "missing exchange provider API key: " + e.Info }
 
 func (p *ExchangerateHost) Convert(ctx context.Context, from, to string, amount int64) (int64, error) {
-	cacheKey := "rates:exchangerate.host:" + from
+	res, err := p.ConvertDetailed(ctx, from, to, amount)
+	return res.ResultCents, err
+}
 
-	var raw []byte
+func (p *ExchangerateHost) ConvertDetailed(ctx context.Context, from, to string, amount int64) (_ ConvertResult, err error) {
+	ctx, obs := startConvert(ctx, nameExchangerateHost, from, to)
+	defer func() { obs.end(ctx, err) }()
 
-	if p.cache != nil {
-		if cached, err := p.cache.Get(ctx, cacheKey); err == nil && cached != "" {
+	er, loaded, rateTS, base, err := p.table(ctx, from)
+	if err != nil {
+		return ConvertResult{}, err
+	}
+	rate, derived, ok := crossRate(er.Rates, base, from, to)
+	if !ok {
+		err := fmt.Errorf("currency %s not found in exchange rates", missingCode(er.Rates, from, to, derived))
+		if p.log != nil {
+			p.log.ErrorCtx(ctx, err, "currency not found in rates", map[string]any{"from": from, "to": to, "base": base})
+		}
+		return ConvertResult{}, err
+	}
+	amountUnits := float64(amount) / 100.0
+	resultUnits := amountUnits * rate
+	resultCents := int64(math.Round(resultUnits * 100.0))
+	if p.log != nil {
+		p.log.WithContext(ctx).Debugf("exchange convert computed: units=%v rate=%v result=%v", amountUnits, rate, resultUnits)
+	}
+	return ConvertResult{ResultCents: resultCents, Rate: rate, RateTimestamp: rateTS, Stale: loaded.Stale,
+		Source: nameExchangerateHost, CacheHit: loaded.CacheHit, Derived: derived}, nil
+}
+
+// table returns the rates that convert from: the table of from, or the one of
+// FALLBACK_BASE when the upstream does not serve from as a base.
+func (p *ExchangerateHost) table(ctx context.Context, from string) (er erhResponse, loaded rateLoad, rateTS time.Time, base string, err error) {
+	base = p.fallback.baseFor(from)
+	er, loaded, rateTS, err = p.latest(ctx, base)
+	if base == from && p.fallback.retry(ctx, from, err) {
+		base = p.fallback.base
+		er, loaded, rateTS, err = p.latest(ctx, base)
+	}
+	return er, loaded, rateTS, base, err
+}
+
+// erhResponse is the exchangerate.host /latest payload.
+type erhResponse struct {
+	Success   bool               `json:"success"`
+	Timestamp int64              `json:"timestamp"`
+	Rates     map[string]float64 `json:"rates"`
+	Error     map[string]any     `json:"error"`
+}
+
+// latest returns the validated rates for base currency from, served from the
+// rate cache when possible, with the time they were published.
+func (p *ExchangerateHost) latest(ctx context.Context, from string) (erhResponse, rateLoad, time.Time, error) {
+	loaded, err := p.rates.load(ctx, "rates:exchangerate.host:"+from, p.fetchLatest(from))
+	if err != nil {
+		return erhResponse{}, rateLoad{}, time.Time{}, err
+	}
+	observationFrom(ctx).recordLookup(loaded.CacheHit)
+	if loaded.CacheHit && p.log != nil {
+		p.log.WithContext(ctx).Debugf("using cached rates for base=%s stale=%t", from, loaded.Stale)
+	}
+	raw := loaded.Body
+
+	var er erhResponse
+	if err := json.Unmarshal(raw, &er); err != nil {
+		if p.log != nil {
+			p.log.ErrorCtx(ctx, err, "decode exchange response error", nil)
+		}
+		return erhResponse{}, rateLoad{}, time.Time{}, err
+	}
+	if !er.Success {
+		err := fmt.Errorf("exchange response not successful")
+		// detect missing_access_key if present
+		if er.Error != nil {
+			t, _ := er.Error["type"].(string)
+			switch t {
+			case "missing_access_key":
+				info := ""
+				if v, ok := er.Error["info"].(string); ok {
+					info = v
+				}
+				err = MissingAPIKeyError{Info: info}
+			case "base_currency_access_restricted", "invalid_base_currency":
+				err = BaseNotSupportedError{Base: from, Code: t}
+			}
+		}
+		if p.log != nil {
+			p.log.ErrorCtx(ctx, err, "exchange response not successful", map[string]any{"response": fmt.Sprintf("%+v", er)})
+		}
+		return erhResponse{}, rateLoad{}, time.Time{}, err
+	}
+	rateTS := loaded.FetchedAt
+	if er.Timestamp > 0 {
+		rateTS = time.Unix(er.Timestamp, 0)
+		p.clock.ObserveRateTime(ctx, rateTS)
+		if err := p.clock.CheckFresh(rateTS); err != nil {
 			if p.log != nil {
-				p.log.WithContext(ctx).Debugf("using cached rates for base=%s", from)
+				p.log.ErrorCtx(ctx, err, "exchange rates rejected", map[string]any{"base": from})
 			}
-			raw = []byte(cached)
+			return erhResponse{}, rateLoad{}, time.Time{}, err
 		}
 	}
-	if raw == nil {
+	return er, loaded, rateTS, nil
+}
+
+// fetchLatest returns the upstream fetch of the rates for base from.
+func (p *ExchangerateHost) fetchLatest(from string) func(ctx context.Context) ([]byte, error) {
+	return func(ctx context.Context) ([]byte, error) {
 		// fetch latest rates for base currency
 		url := fmt.Sprintf("%s/latest?base=%s", p.baseURL, from)
 		if p.apiKey != "" {
 			url = url + fmt.Sprintf("&access_key=%s", p.apiKey)
 		}
 
-		req, _ := http.NewRequestWithContext(ctx, "GET", url, nil)
-		resp, err := http.DefaultClient.Do(req)
+		res, err := fetchWithRetry(ctx, p.client, url, p.clock, p.retry, p.log, p.apiKey)
 		if err != nil {
 			if p.log != nil {
-				p.log.WithContext(ctx).Errorf("exchange request error: %v", err)
+				p.log.ErrorCtx(ctx, err, "exchange request error", nil)
 			}
-			return 0, err
+			return nil, err
 		}
 
-		defer resp.Body.Close()
-
-		if resp.StatusCode != http.StatusOK {
-			body, _ := io.ReadAll(resp.Body)
-			if p.log != nil {
-				p.log.WithContext(ctx).Errorf("exchange request failed status=%d body=%s", resp.StatusCode, string(body))
-			}
-			return 0, fmt.Errorf("exchange request failed status=%d", resp.StatusCode)
+		if res.Status == http.StatusNotModified {
+			return nil, errNotModified
 		}
-
-		r, err := io.ReadAll(resp.Body)
-		if err != nil {
+		if res.Status != http.StatusOK {
+			err := fmt.Errorf("exchange request failed status=%d", res.Status)
 			if p.log != nil {
-				p.log.WithContext(ctx).Errorf("failed reading exchange response body: %v", err)
+				body, truncated := logBody(res.Body, p.bodyLimit)
+				p.log.ErrorCtx(ctx, err, "exchange request failed", map[string]any{"status": res.Status, "body": body, "truncated": truncated})
 			}
-
-			return 0, err
-		}
-
-		raw = r
-
-		if p.cache != nil {
-			_ = p.cache.Set(ctx, cacheKey, string(raw), 20*time.Minute)
+			return nil, err
 		}
 
 		if p.log != nil {
-			p.log.WithContext(ctx).Debugf("exchange raw response for url=%s body=%s", url, string(raw))
+			body, truncated := logBody(res.Body, p.bodyLimit)
+			p.log.WithContext(ctx).Debugf("exchange raw response for url=%s body=%s truncated=%t", redactURL(url, p.apiKey), body, truncated)
 		}
+		return res.Body, nil
 	}
+}
 
-	// parse response - reuse erResponse structure but note the latest endpoint
-	var er struct {
-		Success bool               `json:"success"`
-		Rates   map[string]float64 `json:"rates"`
-		Error   map[string]any     `json:"error"`
+// Currencies lists the currency codes served for base.
+func (p *ExchangerateHost) Currencies(ctx context.Context, base string) ([]string, error) {
+	er, _, _, tableBase, err := p.table(ctx, base)
+	if err != nil {
+		return nil, err
 	}
-	if err := json.Unmarshal(raw, &er); err != nil {
-		if p.log != nil {
-			p.log.WithContext(ctx).Errorf("decode exchange response error: %v", err)
-		}
-		return 0, err
+	return rateCodes(tableBase, er.Rates), nil
+}
+
+// NewProviderFromConfig creates the Provider selected by EXCHANGE_PROVIDER.
+// Registered providers are consulted before the built-in ones; an empty name
+// selects exchangerate.host and any other unknown name is an error.
+func NewProviderFromConfig(cfg *config.Config, lg *logger.Logger, c Cache) (Provider, error) {
+	policy := httpclient.PolicyFromConfig(cfg)
+	d := &providerDeps{
+		clock:  NewSkewClock(lg, cfg.ClockSkewThreshold, cfg.MaxRateAge),
+		policy: policy,
+		// one client (and Transport) for every upstream call, so connections
+		// are reused across requests
+		client: httpclient.New(policy, httpclient.OptionsFromConfig(cfg), lg),
+		retry:  retryPolicyFromConfig(cfg),
 	}
-	if !er.Success {
-		if p.log != nil {
-			p.log.WithContext(ctx).Errorf("exchange response not successful: %+v", er)
-		}
 
-		// detect missing_access_key if present
-		if er.Error != nil {
-			if t, ok := er.Error["type"].(string); ok && t == "missing_access_key" {
-				info := ""
-				if v, ok := er.Error["info"].(string); ok {
-					info = v
-				}
-				return 0, MissingAPIKeyError{Info: info}
-			}
-		}
-		return 0, fmt.Errorf("exchange response not successful")
+	name := cfg.Provider
+	if name == "" {
+		name = "exchangerate.host"
 	}
-	rate, ok := er.Rates[to]
-	if !ok {
-		if p.log != nil {
-			p.log.WithContext(ctx).Errorf("target currency %s not found in rates", to)
+	if _, ok := registered(name); !ok && name == "aggregate" {
+		var sources []AggregateSource
+		for _, src := range cfg.AggregateSources {
+			src = strings.TrimSpace(src)
+			p, err := newProvider(src, cfg, lg, c, d)
+			if err != nil {
+				return nil, fmt.Errorf("AGGREGATE_SOURCES: %w", err)
+			}
+			sources = append(sources, AggregateSource{Name: src, Provider: p})
 		}
-		return 0, fmt.Errorf("currency %s not found in exchange rates", to)
+		return NewAggregateProvider(lg, sources, cfg.AggregateQuorum, cfg.AggregateMethod, cfg.AggregateSourceTimeout), nil
 	}
-	amountUnits := float64(amount) / 100.0
-	resultUnits := amountUnits * rate
-	resultCents := int64(math.Round(resultUnits * 100.0))
-	if p.log != nil {
-		p.log.WithContext(ctx).Debugf("exchange convert computed: units=%v rate=%v result=%v", amountUnits, rate, resultUnits)
-	}
-	return resultCents, nil
+	return newProvider(name, cfg, lg, c, d)
 }
 
-// NewProviderFromConfig creates a Provider based on config.
-func NewProviderFromConfig(cfg *config.Config, lg *logger.Logger, c Cache) Provider {
-	// for now we only support exchangerate.host as default
-	switch cfg.Provider {
+// providerDeps are shared by every provider built from one config.
+type providerDeps struct {
+	clock  *SkewClock
+	policy httpclient.Policy
+	client *http.Client
+	retry  RetryPolicy
+}
+
+// newProvider builds the provider called name, registered or built in.
+func newProvider(name string, cfg *config.Config, lg *logger.Logger, c Cache, d *providerDeps) (Provider, error) {
+	if factory, ok := registered(name); ok {
+		p := factory(cfg, lg, c)
+		if p == nil {
+			return nil, fmt.Errorf("provider factory %q returned nil", name)
+		}
+		// the built-in providers pivot on their own (FALLBACK_BASE,
+		// STATIC_RATES_PIVOT, BRL for BCB)
+		if pivot := cfg.PivotCurrency; pivot != "" && !strings.EqualFold(pivot, "off") {
+			return NewPivotProvider(p, pivot), nil
+		}
+		return p, nil
+	}
+	switch name {
 	case "exchangerate.host":
-		return NewExchangerateHost(lg, cfg.ExchangeAPIKey, c)
+		p := NewExchangerateHost(lg, cfg.ExchangeAPIKey, c, ratesTTL(cfg.ExchangerateHostRatesCacheTTL, cfg.RatesCacheTTL), d.client)
+		p.clock, p.rates.softTTL, p.retry, p.bodyLimit = d.clock, cfg.RatesSoftTTL, d.retry, cfg.ProviderLogBodyLimit
+		p.fallback = newBaseFallback(fallbackBase(cfg), p.log)
+		p.rates.quota = newQuotaTracker(nameExchangerateHost, cfg, c, lg)
+		return p, nil
 	case "exchangerate-api", "exchangerate-api.com", "exchange-rate-api":
-		return NewExchangeRateAPI(lg, cfg.ExchangeAPIKey, c)
+		p := NewExchangeRateAPI(lg, cfg.ExchangeAPIKey, c, ratesTTL(cfg.ExchangeRateAPIRatesCacheTTL, cfg.RatesCacheTTL), d.client)
+		p.clock, p.rates.softTTL, p.retry, p.bodyLimit = d.clock, cfg.RatesSoftTTL, d.retry, cfg.ProviderLogBodyLimit
+		p.fallback = newBaseFallback(fallbackBase(cfg), p.log)
+		p.rates.quota = newQuotaTracker(nameExchangeRateAPI, cfg, c, lg)
+		return p, nil
 	case "bcb", "ptax":
 		base := cfg.BCBAPIBaseURL
 		timeout := cfg.BCBTimeout
@@ -165,8 +299,47 @@ func NewProviderFromConfig(cfg *config.Config, lg *logger.Logger, c Cache) Provi
 		// if maxBack == 0 {
 		// 	maxBack = 1
 		// }
-		return NewBCBProvider(lg, base, timeout, maxRetries, maxBack, c)
-	default:
-		return NewExchangerateHost(lg, cfg.ExchangeAPIKey, c)
+		p := NewBCBProvider(lg, base, timeout, maxRetries, maxBack, c, ratesTTL(cfg.BCBRatesCacheTTL, cfg.RatesCacheTTL), d.client)
+		p.clock, p.rates.softTTL, p.bodyLimit = d.clock, cfg.RatesSoftTTL, cfg.ProviderLogBodyLimit
+		p.rates.quota = newQuotaTracker(nameBCB, cfg, c, lg)
+		holidays, bad := parseBCBHolidays(cfg.BCBHolidays)
+		if len(bad) > 0 && lg != nil {
+			lg.WithContext(context.Background()).Warnf("ignoring invalid BCB_HOLIDAYS entries (want YYYY-MM-DD): %v", bad)
+		}
+		p.holidays = holidays
+		// config.Load rejects invalid values; an empty one keeps the default
+		if side, ok := ParseBCBRateSide(cfg.BCBRateSide); ok {
+			p.side = side
+		}
+		if bulletin, ok := ParseBCBBulletin(cfg.BCBBulletin); ok {
+			p.bulletin = bulletin
+		}
+		if err := httpclient.Validate(context.Background(), d.policy, lg, "BCB_API_BASE_URL", p.baseURL); err != nil {
+			return nil, fmt.Errorf("BCB_API_BASE_URL: %w", err)
+		}
+		return p, nil
+	case "static":
+		p := NewStaticProvider(lg, cfg.StaticRatesPath, cfg.StaticRatesPivot)
+		if err := p.reload(context.Background()); err != nil && lg != nil {
+			lg.WithContext(context.Background()).Errorf("static rates not loaded from STATIC_RATES_PATH=%q: %v", cfg.StaticRatesPath, err)
+		}
+		return p, nil
+	}
+	return nil, UnknownProviderError{Name: name}
+}
+
+// fallbackBase is FALLBACK_BASE, or "" when it is off.
+func fallbackBase(cfg *config.Config) string {
+	if strings.EqualFold(cfg.FallbackBase, "off") {
+		return ""
+	}
+	return cfg.FallbackBase
+}
+
+// ratesTTL returns the per-provider override when set, def otherwise.
+func ratesTTL(override *time.Duration, def time.Duration) time.Duration {
+	if override != nil {
+		return *override
 	}
+	return def
 }
@@ -2,9 +2,16 @@ package provider
 
 import (
 	"context"
+	"fmt"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	"github.com/thiagozs/go-exchange/internal/httpclient"
 )
 
 func TestExchangerateHost_Convert(t *testing.T) {
@@ -15,7 +22,7 @@ func TestExchangerateHost_Convert(t *testing.T) {
 		w.Write([]byte(`{"success":true,"rates":{"BRL":12.345}}`))
 	}))
 	defer srv.Close()
-	p := &ExchangerateHost{baseURL: srv.URL, log: nil, apiKey: "", cache: nil}
+	p := &ExchangerateHost{baseURL: srv.URL, log: nil, apiKey: ""}
 	// amount 10.00 => 1000 cents
 	res, err := p.Convert(context.Background(), "USD", "BRL", 1000)
 	if err != nil {
@@ -25,3 +32,107 @@ func TestExchangerateHost_Convert(t *testing.T) {
 		t.Fatalf("expected 12345 got %v", res)
 	}
 }
+
+func TestProvidersShareTransport(t *testing.T) {
+	var conns atomic.Int32
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"result":"success","conversion_rates":{"BRL":5}}`))
+	}))
+	srv.Config.ConnState = func(_ net.Conn, st http.ConnState) {
+		if st == http.StateNew {
+			conns.Add(1)
+		}
+	}
+	srv.Start()
+	defer srv.Close()
+
+	client := httpclient.New(httpclient.Policy{AllowHTTP: true, AllowPrivate: true}, httpclient.Options{Timeout: time.Second}, nil)
+	api := NewExchangeRateAPI(nil, "k", nil, 0, client)
+	api.baseURL = srv.URL
+	bcb := NewBCBProvider(nil, srv.URL, 5*time.Second, 0, 0, nil, 0, client)
+	if bcb.client.Transport != client.Transport || bcb.client.Timeout != 5*time.Second {
+		t.Fatalf("bcb should share the transport with its own timeout")
+	}
+
+	for range 3 {
+		if _, err := api.Convert(context.Background(), "USD", "BRL", 100); err != nil {
+			t.Fatalf("convert: %v", err)
+		}
+	}
+	if n := conns.Load(); n != 1 {
+		t.Fatalf("expected one reused connection, got %d", n)
+	}
+}
+
+func TestConvertDetailedReportsRateMetadata(t *testing.T) {
+	published := time.Date(2025, 9, 19, 0, 0, 1, 0, time.UTC)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.Contains(r.URL.Path, "Cotacao"):
+			_, _ = w.Write([]byte(`{"value":[{"cotacaoCompra":4.9,"cotacaoVenda":5.0,"dataHoraCotacao":"2025-09-19 13:09:27.04"}]}`))
+		case strings.Contains(r.URL.Path, "/latest/"):
+			fmt.Fprintf(w, `{"result":"success","time_last_update_unix":%d,"conversion_rates":{"BRL":5}}`, published.Unix())
+		default:
+			fmt.Fprintf(w, `{"success":true,"timestamp":%d,"rates":{"BRL":5}}`, published.Unix())
+		}
+	}))
+	defer srv.Close()
+
+	host := NewExchangerateHost(nil, "", newFakeCache(), time.Minute, nil)
+	host.baseURL = srv.URL
+	api := NewExchangeRateAPI(nil, "k", newFakeCache(), time.Minute, nil)
+	api.baseURL = srv.URL
+	bcb := NewBCBProvider(nil, srv.URL, time.Second, 0, 0, newFakeCache(), time.Minute, nil)
+
+	cases := []struct {
+		p      DetailedProvider
+		from   string
+		to     string
+		rate   float64
+		ts     time.Time
+		source string
+	}{
+		{p: host, from: "USD", to: "BRL", rate: 5, ts: published, source: "exchangerate.host"},
+		{p: api, from: "USD", to: "BRL", rate: 5, ts: published, source: "exchangerate-api"},
+		{p: bcb, from: "BRL", to: "USD", rate: 0.2, ts: time.Date(2025, 9, 19, 16, 9, 27, 40e6, time.UTC), source: "bcb"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.source, func(t *testing.T) {
+			for _, wantHit := range []bool{false, true} {
+				res, err := tc.p.ConvertDetailed(context.Background(), tc.from, tc.to, 100)
+				if err != nil {
+					t.Fatalf("convert: %v", err)
+				}
+				if res.Rate != tc.rate || !res.RateTimestamp.Equal(tc.ts) || res.Source != tc.source || res.CacheHit != wantHit {
+					t.Fatalf("unexpected metadata %+v (want cache hit %t)", res, wantHit)
+				}
+			}
+		})
+	}
+}
+
+func TestCurrenciesListsServedCodes(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "/latest/") {
+			_, _ = w.Write([]byte(`{"result":"success","conversion_rates":{"USD":1,"JPY":150,"BRL":5}}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"success":true,"rates":{"JPY":150,"BRL":5}}`))
+	}))
+	defer srv.Close()
+
+	host := NewExchangerateHost(nil, "", newFakeCache(), time.Minute, nil)
+	host.baseURL = srv.URL
+	api := NewExchangeRateAPI(nil, "k", newFakeCache(), time.Minute, nil)
+	api.baseURL = srv.URL
+
+	for _, p := range []CurrencyLister{host, api} {
+		codes, err := p.Currencies(context.Background(), "USD")
+		if err != nil {
+			t.Fatalf("%T: %v", p, err)
+		}
+		if got := strings.Join(codes, ","); got != "BRL,JPY,USD" {
+			t.Fatalf("%T: got %s", p, got)
+		}
+	}
+}
@@ -0,0 +1,244 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/thiagozs/go-exchange/internal/config"
+	"github.com/thiagozs/go-exchange/internal/logger"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// ErrQuotaExceeded is returned instead of calling an upstream whose
+// PROVIDER_MONTHLY_QUOTA is used up for the month.
+var ErrQuotaExceeded = errors.New("provider monthly quota exceeded")
+
+// Counter is implemented by caches that increment counters atomically, like
+// Redis INCR, so every instance sharing the cache counts against the same
+// quota. The ttl applies when the counter is created.
+type Counter interface {
+	Incr(ctx context.Context, key string, ttl time.Duration) (int64, error)
+}
+
+// Quota states reported by QuotaUsage.
+const (
+	QuotaOK        = "ok"
+	QuotaSoftLimit = "soft_limit"
+	QuotaExceeded  = "exceeded"
+)
+
+// QuotaUsage is the upstream usage of a provider in the current month (UTC).
+type QuotaUsage struct {
+	Provider string `json:"provider"`
+	Month    string `json:"month"`
+	Used     int64  `json:"used"`
+	// Quota and SoftLimit are omitted without PROVIDER_MONTHLY_QUOTA.
+	Quota     int64  `json:"quota,omitempty"`
+	SoftLimit int64  `json:"soft_limit,omitempty"`
+	State     string `json:"state"`
+}
+
+// QuotaReporter is implemented by providers that count their upstream
+// requests.
+type QuotaReporter interface {
+	QuotaUsage(ctx context.Context) []QuotaUsage
+}
+
+// quotaTracker counts the requests a provider sends upstream in a monthly
+// counter stored in the cache. Past the soft limit it logs warnings and
+// stretches the rate cache TTLs; at the quota it refuses to call upstream. A
+// nil quotaTracker counts nothing.
+type quotaTracker struct {
+	provider string
+	quota    int64   // 0: count only
+	soft     int64   // 0: never stretch
+	stretch  float64 // TTL multiplier past soft
+	cache    Cache
+	log      *logger.Logger
+	now      func() time.Time
+
+	mu    sync.Mutex
+	month string
+	// used is the last usage seen for month: the local count when the cache
+	// is not a Counter, the shared one otherwise
+	used int64
+}
+
+func newQuotaTracker(name string, cfg *config.Config, c Cache, lg *logger.Logger) *quotaTracker {
+	q := &quotaTracker{provider: name, quota: cfg.ProviderMonthlyQuota[name], stretch: max(cfg.ProviderQuotaTTLMultiplier, 1),
+		cache: c, log: lg, now: time.Now}
+	if q.quota > 0 && cfg.ProviderQuotaSoftThreshold > 0 {
+		q.soft = int64(math.Ceil(float64(q.quota) * cfg.ProviderQuotaSoftThreshold))
+	}
+	return q
+}
+
+// period returns the current month and the counter key and TTL for it. The
+// counter outlives the month by a day, for instances with skewed clocks.
+func (q *quotaTracker) period() (month, key string, ttl time.Duration) {
+	now := q.now().UTC()
+	month = now.Format("2006-01")
+	next := time.Date(now.Year(), now.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+	return month, "quota:" + q.provider + ":" + month, next.Sub(now) + 24*time.Hour
+}
+
+// observe records n as the usage of month.
+func (q *quotaTracker) observe(month string, n int64, add bool) int64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.month != month {
+		q.month, q.used = month, 0
+	}
+	if add {
+		q.used += n
+	} else {
+		q.used = n
+	}
+	return q.used
+}
+
+// count records one request sent upstream.
+func (q *quotaTracker) count(ctx context.Context) {
+	if q == nil {
+		return
+	}
+	month, key, ttl := q.period()
+	var used int64
+	counted := false
+	if c, ok := q.cache.(Counter); ok {
+		n, err := c.Incr(ctx, key, ttl)
+		if err == nil {
+			used, counted = q.observe(month, n, false), true
+		} else if q.log != nil {
+			q.log.WithContext(ctx).Warnf("counting %s upstream request locally: %v", q.provider, err)
+		}
+	}
+	if !counted {
+		used = q.observe(month, 1, true)
+	}
+
+	initConvertMetrics()
+	if convertMetrics.upstream != nil {
+		convertMetrics.upstream.Add(ctx, 1, metric.WithAttributes(attribute.String("provider.name", q.provider)))
+	}
+	if q.soft > 0 && used >= q.soft && q.log != nil {
+		q.log.WithContext(ctx).Warnf("%s used %d of its %d monthly upstream requests (soft limit %d), rate cache TTLs stretched %gx",
+			q.provider, used, q.quota, q.soft, q.stretch)
+	}
+}
+
+// load returns the usage of the current month, read from the cache when it
+// is shared.
+func (q *quotaTracker) load(ctx context.Context) (month string, used int64) {
+	month, key, _ := q.period()
+	if _, ok := q.cache.(Counter); ok {
+		if v, err := q.cache.Get(ctx, key); err == nil {
+			n, _ := strconv.ParseInt(v, 10, 64)
+			return month, q.observe(month, n, false)
+		}
+	}
+	return month, q.observe(month, 0, true)
+}
+
+// check fails with ErrQuotaExceeded once the quota is used up.
+func (q *quotaTracker) check(ctx context.Context) error {
+	if q == nil || q.quota <= 0 {
+		return nil
+	}
+	month, used := q.load(ctx)
+	if used >= q.quota {
+		return fmt.Errorf("%w: %s used %d of %d requests in %s", ErrQuotaExceeded, q.provider, used, q.quota, month)
+	}
+	return nil
+}
+
+// guard wraps fetch to refuse it once the quota is used up, and lets fetch
+// count the requests it sends.
+func (q *quotaTracker) guard(fetch func(ctx context.Context) ([]byte, error)) func(ctx context.Context) ([]byte, error) {
+	if q == nil {
+		return fetch
+	}
+	return func(ctx context.Context) ([]byte, error) {
+		if err := q.check(ctx); err != nil {
+			return nil, err
+		}
+		return fetch(context.WithValue(ctx, quotaCtxKey{}, q))
+	}
+}
+
+// ttls stretches the rate cache TTLs once the soft limit is passed. It uses
+// the last usage seen, refreshed on every upstream request.
+func (q *quotaTracker) ttls(soft, hard time.Duration) (time.Duration, time.Duration) {
+	if q == nil || q.soft <= 0 {
+		return soft, hard
+	}
+	month, _, _ := q.period()
+	q.mu.Lock()
+	over := q.month == month && q.used >= q.soft
+	q.mu.Unlock()
+	if !over {
+		return soft, hard
+	}
+	return time.Duration(float64(soft) * q.stretch), time.Duration(float64(hard) * q.stretch)
+}
+
+// usage reports the current month.
+func (q *quotaTracker) usage(ctx context.Context) QuotaUsage {
+	month, used := q.load(ctx)
+	u := QuotaUsage{Provider: q.provider, Month: month, Used: used, Quota: q.quota, SoftLimit: q.soft, State: QuotaOK}
+	switch {
+	case q.quota > 0 && used >= q.quota:
+		u.State = QuotaExceeded
+	case q.soft > 0 && used >= q.soft:
+		u.State = QuotaSoftLimit
+	}
+	return u
+}
+
+type quotaCtxKey struct{}
+
+// quotaFrom returns the tracker counting the requests of ctx; nil (counting
+// nothing) outside a guarded fetch.
+func quotaFrom(ctx context.Context) *quotaTracker {
+	q, _ := ctx.Value(quotaCtxKey{}).(*quotaTracker)
+	return q
+}
+
+// QuotaUsage reports the upstream requests of the month.
+func (p *ExchangerateHost) QuotaUsage(ctx context.Context) []QuotaUsage {
+	return p.rates.quotaUsage(ctx)
+}
+
+// QuotaUsage reports the upstream requests of the month.
+func (p *ExchangeRateAPI) QuotaUsage(ctx context.Context) []QuotaUsage {
+	return p.rates.quotaUsage(ctx)
+}
+
+// QuotaUsage reports the upstream requests of the month.
+func (p *BCBProvider) QuotaUsage(ctx context.Context) []QuotaUsage {
+	return p.rates.quotaUsage(ctx)
+}
+
+// QuotaUsage reports the sources that count their upstream requests.
+func (a *AggregateProvider) QuotaUsage(ctx context.Context) []QuotaUsage {
+	var out []QuotaUsage
+	for _, src := range a.sources {
+		if qr, ok := As[QuotaReporter](src.Provider); ok {
+			out = append(out, qr.QuotaUsage(ctx)...)
+		}
+	}
+	return out
+}
+
+func (rc *rateCache) quotaUsage(ctx context.Context) []QuotaUsage {
+	if rc == nil || rc.quota == nil {
+		return nil
+	}
+	return []QuotaUsage{rc.quota.usage(ctx)}
+}
@@ -0,0 +1,135 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/thiagozs/go-exchange/internal/config"
+)
+
+// counterCache is a fakeCache that also counts, like Redis INCR.
+type counterCache struct{ *fakeCache }
+
+func (c counterCache) Incr(ctx context.Context, key string, ttl time.Duration) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n, _ := strconv.ParseInt(c.m[key], 10, 64)
+	n++
+	c.m[key] = strconv.FormatInt(n, 10)
+	if _, ok := c.ttls[key]; !ok {
+		c.ttls[key] = ttl
+	}
+	return n, nil
+}
+
+// quotaUpstream serves USD rates to exchangerate-api requests and counts them.
+func quotaUpstream(t *testing.T) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		_, _ = w.Write([]byte(`{"result":"success","base_code":"USD","conversion_rates":{"USD":1,"BRL":5}}`))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &calls
+}
+
+func TestQuotaStretchesTTLsAndRefusesUpstream(t *testing.T) {
+	srv, calls := quotaUpstream(t)
+	c := counterCache{newFakeCache()}
+	p := NewExchangeRateAPI(nil, "k", c, 10*time.Minute, nil)
+	p.baseURL = srv.URL
+	now := time.Date(2026, 10, 31, 12, 0, 0, 0, time.UTC)
+	p.rates.quota = newQuotaTracker(nameExchangeRateAPI, &config.Config{
+		ProviderMonthlyQuota:       map[string]int64{nameExchangeRateAPI: 4},
+		ProviderQuotaSoftThreshold: 0.5,
+		ProviderQuotaTTLMultiplier: 3,
+	}, c, nil)
+	p.rates.quota.now = func() time.Time { return now }
+	ctx := context.Background()
+	rateKey, counterKey := "rates:exchangerate-api:USD", "quota:exchangerate-api:2026-10"
+
+	// convert drops the cached rates first, so every conversion is fetched
+	convert := func() error {
+		_ = c.Delete(ctx, rateKey)
+		_, err := p.Convert(ctx, "USD", "BRL", 100)
+		return err
+	}
+
+	if err := convert(); err != nil {
+		t.Fatal(err)
+	}
+	// entries are kept for twice the TTL, the second half for revalidation
+	if c.ttls[rateKey] != 20*time.Minute {
+		t.Fatalf("expected the configured TTL below the soft limit, got %v", c.ttls[rateKey])
+	}
+	// the counter expires a day after the month ends
+	if want := 12*time.Hour + 24*time.Hour; c.ttls[counterKey] != want {
+		t.Fatalf("expected the counter to expire in %v, got %v", want, c.ttls[counterKey])
+	}
+
+	// the second request reaches the soft limit: the rates fetched after it
+	// are cached 3x longer
+	if err := convert(); err != nil {
+		t.Fatal(err)
+	}
+	if err := convert(); err != nil {
+		t.Fatal(err)
+	}
+	if c.ttls[rateKey] != 60*time.Minute {
+		t.Fatalf("expected the TTL stretched 3x past the soft limit, got %v", c.ttls[rateKey])
+	}
+	if u := p.QuotaUsage(ctx)[0]; u.Used != 3 || u.SoftLimit != 2 || u.State != QuotaSoftLimit || u.Month != "2026-10" {
+		t.Fatalf("unexpected usage %+v", u)
+	}
+
+	// the quota is used up: upstream is not called anymore
+	if err := convert(); err != nil {
+		t.Fatal(err)
+	}
+	if err := convert(); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("expected ErrQuotaExceeded, got %v", err)
+	}
+	if calls.Load() != 4 || c.m[counterKey] != "4" {
+		t.Fatalf("expected 4 upstream calls counted, got %d calls and counter %q", calls.Load(), c.m[counterKey])
+	}
+	if u := p.QuotaUsage(ctx)[0]; u.State != QuotaExceeded {
+		t.Fatalf("expected the quota exceeded, got %+v", u)
+	}
+
+	// a new month starts from zero
+	now = now.Add(24 * time.Hour)
+	if err := convert(); err != nil {
+		t.Fatalf("expected the new month to reach upstream, got %v", err)
+	}
+	if u := p.QuotaUsage(ctx)[0]; u.Used != 1 || u.State != QuotaOK || u.Month != "2026-11" || c.ttls[rateKey] != 20*time.Minute {
+		t.Fatalf("expected a fresh month with the configured TTL, got %+v and %v", u, c.ttls[rateKey])
+	}
+}
+
+func TestQuotaCountsLocallyWithoutCounter(t *testing.T) {
+	srv, _ := quotaUpstream(t)
+	p := NewExchangeRateAPI(nil, "k", nil, 0, nil)
+	p.baseURL = srv.URL
+	p.rates.quota = newQuotaTracker(nameExchangeRateAPI, &config.Config{
+		ProviderMonthlyQuota: map[string]int64{nameExchangeRateAPI: 2},
+	}, nil, nil)
+
+	for range 2 {
+		if _, err := p.Convert(context.Background(), "USD", "BRL", 100); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := p.Convert(context.Background(), "USD", "BRL", 100); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("expected ErrQuotaExceeded, got %v", err)
+	}
+	if u := p.QuotaUsage(context.Background())[0]; u.Used != 2 || u.SoftLimit != 0 {
+		t.Fatalf("expected 2 requests and no soft limit, got %+v", u)
+	}
+}
@@ -0,0 +1,278 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/thiagozs/go-exchange/internal/logger"
+	"golang.org/x/sync/singleflight"
+)
+
+// rateEntry is the cache envelope for raw upstream rate payloads, with the
+// upstream validators used to revalidate them.
+type rateEntry struct {
+	FetchedAt    time.Time `json:"fetched_at"`
+	Body         string    `json:"body"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+}
+
+func (e rateEntry) validators() validators {
+	return validators{ETag: e.ETag, LastModified: e.LastModified}
+}
+
+// rateLoad is the outcome of rateCache.load.
+type rateLoad struct {
+	Body      []byte
+	FetchedAt time.Time
+	CacheHit  bool
+	Stale     bool
+}
+
+// rateCache adds stale-while-revalidate semantics on top of Cache. Entries
+// younger than softTTL are served as is; older entries are served marked as
+// stale while a background refresh runs; entries expire after hardTTL, after
+// which a synchronous fetch is required. A zero softTTL disables stale
+// serving (entries stay fresh until hardTTL); a zero hardTTL disables caching
+// altogether.
+//
+// With conditional set, refreshes send the validators of the cached entry and
+// a 304 answer renews it without downloading the rates again. Entries are
+// then kept for another hardTTL past expiry, so they can still be
+// revalidated.
+type rateCache struct {
+	cache       Cache
+	log         *logger.Logger
+	softTTL     time.Duration
+	hardTTL     time.Duration
+	conditional bool
+	now         func() time.Time
+
+	mu         sync.Mutex
+	refreshing map[string]bool
+	// flight shares the refetches of a key, in background or ahead of
+	// expiry, so they never run twice at once
+	flight singleflight.Group
+	// fetches tracks upstream fetches for the provider health check
+	fetches fetchHealth
+	// quota counts upstream requests against PROVIDER_MONTHLY_QUOTA
+	quota *quotaTracker
+}
+
+func newRateCache(c Cache, lg *logger.Logger, softTTL, hardTTL time.Duration) *rateCache {
+	return &rateCache{cache: c, log: lg, softTTL: softTTL, hardTTL: hardTTL, now: time.Now, refreshing: map[string]bool{}}
+}
+
+// newConditionalRateCache returns a rateCache revalidating its entries with
+// conditional requests.
+func newConditionalRateCache(c Cache, lg *logger.Logger, hardTTL time.Duration) *rateCache {
+	rc := newRateCache(c, lg, 0, hardTTL)
+	rc.conditional = true
+	return rc
+}
+
+// load returns the payload stored under key, calling fetch when it is
+// missing or expired and refreshing it in background when it is stale.
+func (rc *rateCache) load(ctx context.Context, key string, fetch func(ctx context.Context) ([]byte, error)) (rateLoad, error) {
+	if rc != nil {
+		fetch = rc.wrap(fetch)
+	}
+	if rc == nil || rc.cache == nil || rc.hardTTL <= 0 {
+		body, err := fetch(ctx)
+		if err != nil {
+			return rateLoad{}, err
+		}
+		return rateLoad{Body: body, FetchedAt: time.Now()}, nil
+	}
+
+	softTTL, hardTTL := rc.ttls()
+
+	// GetOrSet keeps concurrent misses, here and on other instances, from
+	// all hitting the upstream at once.
+	var fresh *rateEntry
+	cached, err := rc.cache.GetOrSet(ctx, key, rc.storeTTL(hardTTL), func(ctx context.Context) (string, error) {
+		e, _, err := rc.fetchEntry(ctx, fetch, rateEntry{})
+		if err != nil {
+			return "", err
+		}
+		fresh = &e
+		return encodeRateEntry(e)
+	})
+	if fresh != nil {
+		// encoding errors only mean the payload was not stored
+		return rateLoad{Body: []byte(fresh.Body), FetchedAt: fresh.FetchedAt}, nil
+	}
+	if err != nil {
+		return rateLoad{}, err
+	}
+
+	e, ok := decodeRateEntry(cached)
+	if ok {
+		age := rc.now().Sub(e.FetchedAt)
+		if age < hardTTL {
+			res := rateLoad{Body: []byte(e.Body), FetchedAt: e.FetchedAt, CacheHit: true}
+			if softTTL > 0 && age >= softTTL {
+				res.Stale = true
+				rc.refreshAsync(ctx, key, fetch, e)
+			}
+			return res, nil
+		}
+	}
+
+	// a legacy or expired entry is in the way: revalidate or fetch it, and
+	// overwrite it
+	e, _, err = rc.fetchEntry(ctx, fetch, e)
+	if err != nil {
+		return rateLoad{}, err
+	}
+	rc.store(ctx, key, e)
+	return rateLoad{Body: []byte(e.Body), FetchedAt: e.FetchedAt}, nil
+}
+
+// wrap adds the health and quota tracking to fetch.
+func (rc *rateCache) wrap(fetch func(ctx context.Context) ([]byte, error)) func(ctx context.Context) ([]byte, error) {
+	return rc.fetches.observe(rc.quota.guard(fetch))
+}
+
+// refresh refetches key unless its entry is cached for longer than lead,
+// and returns when the cached entry expires. The zero time means rates are
+// not cached.
+func (rc *rateCache) refresh(ctx context.Context, key string, fetch func(ctx context.Context) ([]byte, error), lead time.Duration) (time.Time, error) {
+	if rc == nil || rc.cache == nil || rc.hardTTL <= 0 {
+		return time.Time{}, nil
+	}
+	_, hardTTL := rc.ttls()
+	cached, _ := rc.cache.Get(ctx, key)
+	prev, ok := decodeRateEntry(cached)
+	if expires := prev.FetchedAt.Add(hardTTL); ok && rc.now().Before(expires.Add(-lead)) {
+		return expires, nil
+	}
+	e, err := rc.refetch(ctx, key, rc.wrap(fetch), prev)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return e.FetchedAt.Add(hardTTL), nil
+}
+
+// refetch fetches key again, revalidating prev when possible, and stores
+// it. Concurrent refetches of key share one fetch.
+func (rc *rateCache) refetch(ctx context.Context, key string, fetch func(ctx context.Context) ([]byte, error), prev rateEntry) (rateEntry, error) {
+	v, err, _ := rc.flight.Do(key, func() (any, error) {
+		e, notModified, err := rc.fetchEntry(ctx, fetch, prev)
+		if err != nil {
+			return rateEntry{}, err
+		}
+		rc.store(ctx, key, e)
+		if rc.log != nil {
+			rc.log.WithContext(ctx).Debugf("refresh of %s completed not_modified=%t", key, notModified)
+		}
+		return e, nil
+	})
+	return v.(rateEntry), err
+}
+
+// fetchEntry fetches a new entry. With conditional set and a cached prev, the
+// request carries prev's validators and a 304 answer renews prev instead;
+// notModified reports it.
+func (rc *rateCache) fetchEntry(ctx context.Context, fetch func(ctx context.Context) ([]byte, error), prev rateEntry) (e rateEntry, notModified bool, err error) {
+	if !rc.conditional {
+		body, err := fetch(ctx)
+		if err != nil {
+			return rateEntry{}, false, err
+		}
+		return rateEntry{FetchedAt: rc.now(), Body: string(body)}, false, nil
+	}
+	cond := &conditionalFetch{}
+	if prev.Body != "" {
+		cond.prev = prev.validators()
+	}
+	body, err := fetch(context.WithValue(ctx, conditionalCtxKey{}, cond))
+	got := cond.validators()
+	if errors.Is(err, errNotModified) && prev.Body != "" {
+		prev.FetchedAt = rc.now()
+		// a 304 may carry updated validators
+		if got.ETag != "" {
+			prev.ETag = got.ETag
+		}
+		if got.LastModified != "" {
+			prev.LastModified = got.LastModified
+		}
+		return prev, true, nil
+	}
+	if err != nil {
+		return rateEntry{}, false, err
+	}
+	return rateEntry{FetchedAt: rc.now(), Body: string(body), ETag: got.ETag, LastModified: got.LastModified}, false, nil
+}
+
+// ttls returns the soft and hard TTLs, stretched once the provider is past
+// the soft limit of its monthly quota.
+func (rc *rateCache) ttls() (soft, hard time.Duration) {
+	return rc.quota.ttls(rc.softTTL, rc.hardTTL)
+}
+
+// storeTTL is how long entries stay in the cache: hard, plus another hard for
+// revalidation when conditional.
+func (rc *rateCache) storeTTL(hard time.Duration) time.Duration {
+	if rc.conditional {
+		return 2 * hard
+	}
+	return hard
+}
+
+// decodeRateEntry parses a cached envelope. Entries written before the
+// envelope existed are treated as misses.
+func decodeRateEntry(cached string) (rateEntry, bool) {
+	if cached == "" {
+		return rateEntry{}, false
+	}
+	var e rateEntry
+	if err := json.Unmarshal([]byte(cached), &e); err != nil || e.Body == "" {
+		return rateEntry{}, false
+	}
+	return e, true
+}
+
+func encodeRateEntry(e rateEntry) (string, error) {
+	b, err := json.Marshal(e)
+	return string(b), err
+}
+
+func (rc *rateCache) store(ctx context.Context, key string, e rateEntry) {
+	v, err := encodeRateEntry(e)
+	if err != nil {
+		return
+	}
+	_, hardTTL := rc.ttls()
+	_ = rc.cache.Set(ctx, key, v, rc.storeTTL(hardTTL))
+}
+
+// refreshAsync refetches key in background, revalidating prev when possible,
+// at most once at a time per key. Failures keep the stale entry in place
+// until it reaches the hard TTL.
+func (rc *rateCache) refreshAsync(ctx context.Context, key string, fetch func(ctx context.Context) ([]byte, error), prev rateEntry) {
+	rc.mu.Lock()
+	if rc.refreshing[key] {
+		rc.mu.Unlock()
+		return
+	}
+	rc.refreshing[key] = true
+	rc.mu.Unlock()
+
+	// detach from the request so the refresh survives the response
+	bg, cancel := context.WithTimeout(context.WithoutCancel(ctx), time.Minute)
+	go func() {
+		defer cancel()
+		defer func() {
+			rc.mu.Lock()
+			delete(rc.refreshing, key)
+			rc.mu.Unlock()
+		}()
+		if _, err := rc.refetch(bg, key, fetch, prev); err != nil && rc.log != nil {
+			rc.log.WithContext(bg).Warnf("background refresh of %s failed, serving stale data: %v", key, err)
+		}
+	}()
+}
@@ -0,0 +1,185 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/thiagozs/go-exchange/internal/config"
+)
+
+func TestRateCacheStaleWhileRevalidate(t *testing.T) {
+	var calls atomic.Int32
+	refreshed := make(chan struct{}, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := calls.Add(1)
+		fmt.Fprintf(w, `{"result":"success","conversion_rates":{"BRL":%d}}`, 4+n)
+		if n == 2 {
+			refreshed <- struct{}{}
+		}
+	}))
+	defer srv.Close()
+
+	c := newFakeCache()
+	p := NewExchangeRateAPI(nil, "k", c, 10*time.Minute, nil)
+	p.baseURL = srv.URL
+	p.rates.softTTL = time.Minute
+	start := time.Date(2025, 9, 19, 12, 0, 0, 0, time.UTC)
+	now := start
+	p.rates.now = func() time.Time { return now }
+
+	ctx := context.Background()
+	res, err := p.ConvertDetailed(ctx, "USD", "BRL", 100)
+	if err != nil || res.ResultCents != 500 || res.Stale {
+		t.Fatalf("first call: res=%+v err=%v", res, err)
+	}
+
+	// within the soft TTL: served from cache, not stale
+	now = start.Add(30 * time.Second)
+	if res, _ = p.ConvertDetailed(ctx, "USD", "BRL", 100); res.Stale || calls.Load() != 1 {
+		t.Fatalf("expected fresh cached result, got %+v after %d calls", res, calls.Load())
+	}
+
+	// past the soft TTL: old rate served immediately, flagged stale
+	now = start.Add(2 * time.Minute)
+	res, err = p.ConvertDetailed(ctx, "USD", "BRL", 100)
+	if err != nil || !res.Stale || res.ResultCents != 500 {
+		t.Fatalf("expected stale result, got %+v err=%v", res, err)
+	}
+	if !res.RateTimestamp.Equal(start) {
+		t.Fatalf("expected rate timestamp %v, got %v", start, res.RateTimestamp)
+	}
+
+	select {
+	case <-refreshed:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("background refresh did not run")
+	}
+	// wait for the refreshed entry to be stored
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		res, _ = p.ConvertDetailed(ctx, "USD", "BRL", 100)
+		if !res.Stale || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if res.Stale || res.ResultCents != 600 {
+		t.Fatalf("expected refreshed rate, got %+v", res)
+	}
+}
+
+func TestRateCacheHardTTLFetchesSynchronously(t *testing.T) {
+	c := newFakeCache()
+	rc := newRateCache(c, nil, time.Minute, 10*time.Minute)
+	start := time.Date(2025, 9, 19, 12, 0, 0, 0, time.UTC)
+	now := start
+	rc.now = func() time.Time { return now }
+
+	var calls int
+	fetchFn := func(ctx context.Context) ([]byte, error) {
+		calls++
+		return []byte(fmt.Sprintf("v%d", calls)), nil
+	}
+	if _, err := rc.load(context.Background(), "k", fetchFn); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	now = start.Add(11 * time.Minute)
+	res, err := rc.load(context.Background(), "k", fetchFn)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if res.Stale || res.CacheHit || string(res.Body) != "v2" || calls != 2 {
+		t.Fatalf("expected synchronous refetch past hard TTL, got %+v calls=%d", res, calls)
+	}
+}
+
+func TestRateCacheKeepsStaleEntryOnRefreshFailure(t *testing.T) {
+	c := newFakeCache()
+	rc := newRateCache(c, nil, time.Minute, 10*time.Minute)
+	start := time.Date(2025, 9, 19, 12, 0, 0, 0, time.UTC)
+	rc.now = func() time.Time { return start }
+	ctx := context.Background()
+	if _, err := rc.load(ctx, "k", func(context.Context) ([]byte, error) { return []byte("v1"), nil }); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	rc.now = func() time.Time { return start.Add(5 * time.Minute) }
+	done := make(chan struct{})
+	res, err := rc.load(ctx, "k", func(context.Context) ([]byte, error) {
+		defer close(done)
+		return nil, errors.New("upstream down")
+	})
+	if err != nil || !res.Stale || string(res.Body) != "v1" {
+		t.Fatalf("expected stale v1, got %+v err=%v", res, err)
+	}
+	<-done
+
+	cached, _ := c.Get(ctx, "k")
+	if e, ok := decodeRateEntry(cached); !ok || e.Body != "v1" || !e.FetchedAt.Equal(start) {
+		t.Fatalf("stale entry should be kept after a failed refresh, got %+v", e)
+	}
+}
+
+func TestRatesCacheTTLFromConfig(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "Cotacao") {
+			_, _ = w.Write([]byte(`{"value":[{"cotacaoCompra":5.0,"cotacaoVenda":5.0,"dataHoraCotacao":"2025-09-19 13:00:00.0"}]}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"success":true,"result":"success","rates":{"BRL":5},"conversion_rates":{"BRL":5}}`))
+	}))
+	defer srv.Close()
+
+	override := 2 * time.Minute
+	zero := time.Duration(0)
+	// exchangerate.host and exchangerate-api keep entries for twice the TTL,
+	// the second half for revalidation
+	cases := []struct {
+		name    string
+		cfg     config.Config
+		key     string
+		wantTTL time.Duration
+		cached  bool
+	}{
+		{name: "global", cfg: config.Config{Provider: "exchangerate.host", RatesCacheTTL: 5 * time.Minute},
+			key: "rates:exchangerate.host:USD", wantTTL: 10 * time.Minute, cached: true},
+		{name: "override", cfg: config.Config{Provider: "exchangerate-api", ExchangeAPIKey: "k", RatesCacheTTL: 5 * time.Minute, ExchangeRateAPIRatesCacheTTL: &override},
+			key: "rates:exchangerate-api:USD", wantTTL: 2 * override, cached: true},
+		{name: "bcb override disables", cfg: config.Config{Provider: "bcb", BCBAPIBaseURL: srv.URL, RatesCacheTTL: 5 * time.Minute, BCBRatesCacheTTL: &zero},
+			key: "rates:bcb:USD"},
+		{name: "global zero disables", cfg: config.Config{Provider: "exchangerate.host"},
+			key: "rates:exchangerate.host:USD"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			// the stub upstream is plain http on loopback
+			tc.cfg.OutboundAllowHTTP, tc.cfg.OutboundAllowPrivate = true, true
+			c := newFakeCache()
+			p, err := NewProviderFromConfig(&tc.cfg, nil, c)
+			if err != nil {
+				t.Fatalf("new provider: %v", err)
+			}
+			switch pp := p.(type) {
+			case *ExchangerateHost:
+				pp.baseURL = srv.URL
+			case *ExchangeRateAPI:
+				pp.baseURL = srv.URL
+			}
+			if _, err := p.Convert(context.Background(), "USD", "BRL", 100); err != nil {
+				t.Fatalf("convert: %v", err)
+			}
+			ttl, ok := c.ttls[tc.key]
+			if ok != tc.cached || ttl != tc.wantTTL {
+				t.Fatalf("expected cached=%t ttl=%v, got cached=%t ttl=%v", tc.cached, tc.wantTTL, ok, ttl)
+			}
+		})
+	}
+}
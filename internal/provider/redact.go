@@ -0,0 +1,40 @@
+package provider
+
+import (
+	"net/url"
+
+	"github.com/thiagozs/go-exchange/internal/httpclient"
+)
+
+// defaultLogBodyLimit is how many bytes of an upstream body are logged when
+// PROVIDER_LOG_BODY_LIMIT is not set.
+const defaultLogBodyLimit = 512
+
+// redactURL masks credential query parameters and every occurrence of the
+// given secrets (e.g. an API key embedded in the path) so raw can be logged.
+func redactURL(raw string, secrets ...string) string {
+	return httpclient.RedactURL(raw, secrets...)
+}
+
+// redactError redacts the request URL that net/http puts in transport
+// errors, since those messages end up in logs and client responses.
+func redactError(err error, secrets ...string) error {
+	if ue, ok := err.(*url.Error); ok {
+		c := *ue
+		c.URL = redactURL(c.URL, secrets...)
+		return &c
+	}
+	return err
+}
+
+// logBody returns at most limit bytes of body for logging and whether it was
+// truncated. A limit <= 0 uses defaultLogBodyLimit.
+func logBody(body []byte, limit int) (string, bool) {
+	if limit <= 0 {
+		limit = defaultLogBodyLimit
+	}
+	if len(body) <= limit {
+		return string(body), false
+	}
+	return string(body[:limit]), true
+}
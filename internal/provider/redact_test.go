@@ -0,0 +1,87 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/thiagozs/go-exchange/internal/logger"
+)
+
+const testSecret = "s3cr3t-key-0123456789"
+
+func TestRedactURL(t *testing.T) {
+	cases := map[string]string{
+		"https://api.exchangerate.host/latest?base=USD&access_key=" + testSecret: "https://api.exchangerate.host/latest?access_key=REDACTED&base=USD",
+		"https://v6.exchangerate-api.com/v6/" + testSecret + "/latest/USD":       "https://v6.exchangerate-api.com/v6/REDACTED/latest/USD",
+		"https://olinda.bcb.gov.br/odata/CotacaoDolarPeriodo?$format=json":       "https://olinda.bcb.gov.br/odata/CotacaoDolarPeriodo?$format=json",
+	}
+	for in, want := range cases {
+		if got := redactURL(in, testSecret); got != want {
+			t.Errorf("redactURL(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestLogBody(t *testing.T) {
+	if got, truncated := logBody([]byte("short"), 10); got != "short" || truncated {
+		t.Fatalf("unexpected %q %t", got, truncated)
+	}
+	if got, truncated := logBody(bytes.Repeat([]byte("x"), 600), 0); len(got) != defaultLogBodyLimit || !truncated {
+		t.Fatalf("expected the default limit, got %d bytes truncated=%t", len(got), truncated)
+	}
+}
+
+// TestProviderLogsNeverContainAPIKey drives both keyed providers through a
+// large successful body, an upstream error and a transport error, capturing
+// debug logs.
+func TestProviderLogsNeverContainAPIKey(t *testing.T) {
+	big := `{"success":true,"result":"success","rates":{"BRL":5},"conversion_rates":{"BRL":5},"pad":"` + strings.Repeat("x", 4096) + `"}`
+	fail := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail {
+			w.WriteHeader(http.StatusBadGateway)
+			_, _ = w.Write([]byte(strings.Repeat("e", 2048)))
+			return
+		}
+		_, _ = w.Write([]byte(big))
+	}))
+	defer srv.Close()
+
+	var buf bytes.Buffer
+	lg := logger.New(logger.Options{Format: "text", Level: "debug", Out: &buf})
+	host := NewExchangerateHost(lg, testSecret, nil, 0, nil)
+	api := NewExchangeRateAPI(lg, testSecret, nil, 0, nil)
+	ctx := context.Background()
+
+	for _, p := range []Provider{host, api} {
+		host.baseURL, api.baseURL = srv.URL, srv.URL
+		fail = false
+		if _, err := p.Convert(ctx, "USD", "BRL", 100); err != nil {
+			t.Fatalf("convert: %v", err)
+		}
+		fail = true
+		if _, err := p.Convert(ctx, "USD", "BRL", 100); err == nil || strings.Contains(err.Error(), testSecret) {
+			t.Fatalf("expected a redacted upstream error, got %v", err)
+		}
+		// nothing listens here: the transport error carries the URL
+		host.baseURL, api.baseURL = "http://127.0.0.1:1", "http://127.0.0.1:1"
+		if _, err := p.Convert(ctx, "USD", "BRL", 100); err == nil || strings.Contains(err.Error(), testSecret) {
+			t.Fatalf("expected a redacted transport error, got %v", err)
+		}
+	}
+
+	out := buf.String()
+	if strings.Contains(out, testSecret) {
+		t.Fatalf("API key leaked into logs:\n%s", out)
+	}
+	if !strings.Contains(out, "REDACTED") || !strings.Contains(out, "truncated=true") {
+		t.Fatalf("expected redacted URLs and truncated bodies in logs:\n%s", out)
+	}
+	if strings.Contains(out, strings.Repeat("x", defaultLogBodyLimit+1)) {
+		t.Fatalf("logged body exceeds the limit")
+	}
+}
@@ -0,0 +1,106 @@
+package provider
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/thiagozs/go-exchange/internal/config"
+	"github.com/thiagozs/go-exchange/internal/logger"
+)
+
+// Factory builds a registered provider.
+//
+// It is called once per NewProviderFromConfig, i.e. when the server is
+// constructed, with the full configuration, the application logger and the
+// shared cache. Either lg or c may be nil (tests, no Redis), so factories must
+// nil-check them. A factory should not block on network I/O; connectivity
+// problems belong in Convert, which receives the request context. Returning a
+// nil Provider is reported as a configuration error. The returned provider
+// may also implement DetailedProvider to expose rate metadata.
+type Factory func(cfg *config.Config, lg *logger.Logger, c Cache) Provider
+
+var registry = struct {
+	sync.RWMutex
+	factories map[string]Factory
+}{factories: map[string]Factory{}}
+
+// builtinProviders are the EXCHANGE_PROVIDER values handled by newProvider
+// and NewProviderFromConfig, with their aliases.
+var builtinProviders = []string{
+	"exchangerate.host",
+	"exchangerate-api (exchangerate-api.com, exchange-rate-api)",
+	"bcb (ptax)",
+	"static",
+	"aggregate",
+}
+
+// Register makes a provider selectable with EXCHANGE_PROVIDER=name and usable
+// as an AGGREGATE_SOURCES entry. Registered names take precedence over the
+// built-in providers. Like database/sql.Register it is meant to be called from
+// init and panics on an empty name, a nil factory or a duplicate name.
+func Register(name string, factory Factory) {
+	if name == "" || factory == nil {
+		panic("provider: Register called with an empty name or nil factory")
+	}
+	registry.Lock()
+	defer registry.Unlock()
+	if _, dup := registry.factories[name]; dup {
+		panic("provider: Register called twice for " + name)
+	}
+	registry.factories[name] = factory
+}
+
+func registered(name string) (Factory, bool) {
+	registry.RLock()
+	defer registry.RUnlock()
+	f, ok := registry.factories[name]
+	return f, ok
+}
+
+// UnknownProviderError is returned for a provider name that is neither
+// registered nor built in.
+type UnknownProviderError struct {
+	Name string
+}
+
+func (e UnknownProviderError) Error() string {
+	registry.RLock()
+	names := make([]string, 0, len(registry.factories))
+	for name := range registry.factories {
+		names = append(names, name)
+	}
+	registry.RUnlock()
+	slices.Sort(names)
+	msg := fmt.Sprintf("unknown exchange provider %q (built-in: %s", e.Name, strings.Join(builtinProviders, ", "))
+	if len(names) > 0 {
+		msg += "; registered: " + strings.Join(names, ", ")
+	}
+	return msg + ")"
+}
+
+// Default upstream APIs of the built-in providers.
+const (
+	exchangerateHostURL = "https://api.exchangerate.host"
+	exchangeRateAPIURL  = "https://v6.exchangerate-api.com/v6"
+	bcbURL              = "https://olinda.bcb.gov.br/olinda/servico/PTAX/versao/v1/odata/"
+)
+
+// BaseURL is the upstream API the built-in provider name calls, "" for those
+// without one (static, aggregate) and for registered providers.
+func BaseURL(name string, cfg *config.Config) string {
+	if _, ok := registered(name); ok {
+		return ""
+	}
+	switch name {
+	case "exchangerate.host":
+		return exchangerateHostURL
+	case "exchangerate-api", "exchangerate-api.com", "exchange-rate-api":
+		return exchangeRateAPIURL
+	case "bcb", "ptax":
+		return cmp.Or(cfg.BCBAPIBaseURL, bcbURL)
+	}
+	return ""
+}
@@ -0,0 +1,131 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/thiagozs/go-exchange/internal/config"
+	"github.com/thiagozs/go-exchange/internal/httpclient"
+	"github.com/thiagozs/go-exchange/internal/logger"
+)
+
+// registerForTest registers factory under name and removes it when the test ends.
+func registerForTest(t *testing.T, name string, factory Factory) {
+	t.Helper()
+	Register(name, factory)
+	t.Cleanup(func() {
+		registry.Lock()
+		delete(registry.factories, name)
+		registry.Unlock()
+	})
+}
+
+type fakeRatesService struct{ apiKey string }
+
+func (f *fakeRatesService) Convert(ctx context.Context, from, to string, amount int64) (int64, error) {
+	return amount * 2, nil
+}
+
+func TestRegisteredProviderSelectedByConfig(t *testing.T) {
+	var gotCache Cache
+	registerForTest(t, "internal-rates", func(cfg *config.Config, lg *logger.Logger, c Cache) Provider {
+		gotCache = c
+		return &fakeRatesService{apiKey: cfg.ExchangeAPIKey}
+	})
+
+	c := newFakeCache()
+	p, err := NewProviderFromConfig(&config.Config{Provider: "internal-rates", ExchangeAPIKey: "secret"}, nil, c)
+	if err != nil {
+		t.Fatalf("new provider: %v", err)
+	}
+	fp, ok := p.(*fakeRatesService)
+	if !ok || fp.apiKey != "secret" || gotCache != c {
+		t.Fatalf("expected the registered provider built from config, got %#v", p)
+	}
+	if got, _ := p.Convert(context.Background(), "USD", "BRL", 100); got != 200 {
+		t.Fatalf("unexpected conversion %d", got)
+	}
+}
+
+func TestRegisteredProviderOverridesBuiltin(t *testing.T) {
+	registerForTest(t, "bcb", func(*config.Config, *logger.Logger, Cache) Provider { return &fakeRatesService{} })
+	p, err := NewProviderFromConfig(&config.Config{Provider: "bcb"}, nil, nil)
+	if _, ok := p.(*fakeRatesService); err != nil || !ok {
+		t.Fatalf("expected the registered bcb provider, got %T err=%v", p, err)
+	}
+}
+
+func TestNewProviderFromConfigErrors(t *testing.T) {
+	registerForTest(t, "broken", func(*config.Config, *logger.Logger, Cache) Provider { return nil })
+
+	var unknown UnknownProviderError
+	_, err := NewProviderFromConfig(&config.Config{Provider: "exchangerate.hots"}, nil, nil)
+	if !errors.As(err, &unknown) || !strings.Contains(err.Error(), "exchangerate.host") || !strings.Contains(err.Error(), "broken") {
+		t.Fatalf("expected an unknown provider error listing the choices, got %v", err)
+	}
+	if _, err := NewProviderFromConfig(&config.Config{Provider: "broken"}, nil, nil); err == nil {
+		t.Fatalf("expected an error for a factory returning nil")
+	}
+	// an empty name keeps the historical default
+	if p, err := NewProviderFromConfig(&config.Config{}, nil, nil); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	} else if _, ok := p.(*ExchangerateHost); !ok {
+		t.Fatalf("expected exchangerate.host by default, got %T", p)
+	}
+}
+
+func TestNewProviderFromConfigRejectsRestrictedBCBURL(t *testing.T) {
+	_, err := NewProviderFromConfig(&config.Config{Provider: "bcb", BCBAPIBaseURL: "https://169.254.169.254/odata/"}, nil, nil)
+	var perr *httpclient.PolicyError
+	if !errors.As(err, &perr) || !strings.Contains(err.Error(), "BCB_API_BASE_URL") {
+		t.Fatalf("expected a BCB_API_BASE_URL policy error, got %v", err)
+	}
+}
+
+func TestRegisterPanicsOnDuplicate(t *testing.T) {
+	factory := func(*config.Config, *logger.Logger, Cache) Provider { return &fakeRatesService{} }
+	registerForTest(t, "dup", factory)
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("expected a panic on duplicate registration")
+		}
+	}()
+	Register("dup", factory)
+}
+
+func TestNewProviderFromConfigNames(t *testing.T) {
+	cases := []struct {
+		name string
+		want string
+	}{
+		{name: "exchangerate.host", want: "*provider.ExchangerateHost"},
+		{name: "exchangerate-api", want: "*provider.ExchangeRateAPI"},
+		{name: "exchangerate-api.com", want: "*provider.ExchangeRateAPI"},
+		{name: "bcb", want: "*provider.BCBProvider"},
+		{name: "ptax", want: "*provider.BCBProvider"},
+		{name: "static", want: "*provider.StaticProvider"},
+		{name: "aggregate", want: "*provider.AggregateProvider"},
+		{name: "exchangeratehost"},
+		{name: "BCB"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			p, err := NewProviderFromConfig(&config.Config{Provider: tc.name}, nil, nil)
+			if tc.want == "" {
+				if err == nil || !strings.Contains(err.Error(), "bcb (ptax)") {
+					t.Fatalf("expected an error listing valid providers, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := fmt.Sprintf("%T", p); got != tc.want {
+				t.Fatalf("expected %s, got %s", tc.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package provider
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ConvertResult is a conversion together with metadata about the rate used.
+type ConvertResult struct {
+	ResultCents int64
+	// Rate is the applied rate: units of the target currency per unit of the
+	// source currency.
+	Rate float64
+	// RateTimestamp is when the rate was published upstream (or fetched, when
+	// the provider does not report it).
+	RateTimestamp time.Time
+	// Stale is set when the rate was served past its soft TTL while a
+	// background refresh is in flight.
+	Stale bool
+	// Source names the provider the rate came from.
+	Source string
+	// CacheHit is set when the rate came from the rate cache instead of an
+	// upstream call.
+	CacheHit bool
+	// RateSide and Bulletin report which PTAX quote was used; empty for
+	// providers without that choice.
+	RateSide string
+	Bulletin string
+	// Sources lists the providers that contributed to an aggregated rate and
+	// Spread is the difference between the highest and lowest of their rates.
+	Sources []string
+	Spread  float64
+	// Derived is set when the rate was not quoted directly: derived from the
+	// FALLBACK_BASE table because the upstream did not serve the source
+	// currency as a base, or crossed through PIVOT_CURRENCY.
+	Derived bool
+}
+
+// DetailedProvider is implemented by providers that can report rate metadata
+// alongside the converted amount.
+type DetailedProvider interface {
+	ConvertDetailed(ctx context.Context, from, to string, amount int64) (ConvertResult, error)
+}
+
+// CurrencyLister is implemented by providers that can report which currency
+// codes they serve for a base currency.
+type CurrencyLister interface {
+	Currencies(ctx context.Context, base string) ([]string, error)
+}
+
+// rateCodes returns the sorted codes of a rates map, including base.
+func rateCodes(base string, rates map[string]float64) []string {
+	codes := []string{strings.ToUpper(base)}
+	for code := range rates {
+		if code = strings.ToUpper(code); code != codes[0] {
+			codes = append(codes, code)
+		}
+	}
+	slices.Sort(codes)
+	return codes
+}
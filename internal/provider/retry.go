@@ -0,0 +1,116 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/thiagozs/go-exchange/internal/config"
+	"github.com/thiagozs/go-exchange/internal/logger"
+)
+
+// RetryPolicy controls how upstream rate requests are retried. Only network
+// errors, 5xx and 429 responses are retried. The zero value makes a single
+// attempt.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first.
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// Jitter is the fraction (0..1) of each backoff that is randomized.
+	Jitter float64
+}
+
+// retryPolicyFromConfig returns the policy configured through
+// PROVIDER_RETRY_* settings.
+func retryPolicyFromConfig(cfg *config.Config) RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    cfg.ProviderRetryMaxAttempts,
+		InitialBackoff: cfg.ProviderRetryInitialBackoff,
+		MaxBackoff:     cfg.ProviderRetryMaxBackoff,
+		Jitter:         cfg.ProviderRetryJitter,
+	}
+}
+
+// backoff returns the wait before retry number n (1-based): exponential from
+// InitialBackoff, capped at MaxBackoff, minus up to Jitter of itself.
+func (rp RetryPolicy) backoff(n int) time.Duration {
+	d := rp.InitialBackoff
+	for i := 1; i < n && (rp.MaxBackoff <= 0 || d < rp.MaxBackoff); i++ {
+		d *= 2
+	}
+	if rp.MaxBackoff > 0 && d > rp.MaxBackoff {
+		d = rp.MaxBackoff
+	}
+	if j := min(max(rp.Jitter, 0), 1); j > 0 {
+		d -= time.Duration(rand.Float64() * j * float64(d))
+	}
+	return d
+}
+
+func retryableStatus(status int) bool {
+	return status >= 500 || status == http.StatusTooManyRequests
+}
+
+// retryAfter parses a Retry-After header given in seconds or as an HTTP date.
+func retryAfter(h http.Header, now time.Time) (time.Duration, bool) {
+	v := h.Get("Retry-After")
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return max(t.Sub(now), 0), true
+	}
+	return 0, false
+}
+
+// fetchWithRetry is fetch with retries according to rp. It returns the last
+// response (or error) once attempts run out, the failure is not retryable, or
+// waiting would run past the context deadline. secrets are redacted from the
+// URL in returned errors.
+func fetchWithRetry(ctx context.Context, client *http.Client, url string, clock *SkewClock, rp RetryPolicy, lg *logger.Logger, secrets ...string) (*fetchResult, error) {
+	for attempt := 1; ; attempt++ {
+		res, err := fetch(ctx, client, url, clock)
+		err = redactError(err, secrets...)
+		if ctxErr := ctx.Err(); err != nil && ctxErr != nil {
+			return nil, ctxErr
+		}
+		var reason string
+		switch {
+		case err != nil:
+			reason = err.Error()
+		case retryableStatus(res.Status):
+			reason = fmt.Sprintf("status=%d", res.Status)
+		default:
+			return res, nil
+		}
+		if attempt >= rp.MaxAttempts {
+			return res, err
+		}
+
+		wait := rp.backoff(attempt)
+		if res != nil {
+			if d, ok := retryAfter(res.Header, time.Now()); ok {
+				wait = d
+			}
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Now().Add(wait).After(deadline) {
+			return res, err
+		}
+		if lg != nil {
+			lg.WithContext(ctx).Warnf("retrying upstream request attempt=%d/%d in %v: %s", attempt+1, rp.MaxAttempts, wait, reason)
+		}
+		observationFrom(ctx).addRetry()
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
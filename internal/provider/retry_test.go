@@ -0,0 +1,123 @@
+package provider
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// scriptedServer answers with statuses in order, then with body.
+func scriptedServer(t *testing.T, body string, statuses ...int) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(calls.Add(1))
+		if n <= len(statuses) {
+			w.WriteHeader(statuses[n-1])
+			return
+		}
+		_, _ = w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &calls
+}
+
+var testRetry = RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond, Jitter: 0.5}
+
+func TestRetryRecoversFromTransientFailures(t *testing.T) {
+	t.Run("exchangerate.host", func(t *testing.T) {
+		srv, calls := scriptedServer(t, `{"success":true,"rates":{"BRL":5}}`, http.StatusBadGateway, http.StatusServiceUnavailable)
+		p := NewExchangerateHost(nil, "", nil, 0, nil)
+		p.baseURL, p.retry = srv.URL, testRetry
+		got, err := p.Convert(context.Background(), "USD", "BRL", 100)
+		if err != nil || got != 500 || calls.Load() != 3 {
+			t.Fatalf("expected 500 after 3 requests, got %d err=%v requests=%d", got, err, calls.Load())
+		}
+	})
+	t.Run("exchangerate-api", func(t *testing.T) {
+		srv, calls := scriptedServer(t, `{"result":"success","conversion_rates":{"BRL":5}}`, http.StatusBadGateway, http.StatusTooManyRequests)
+		p := NewExchangeRateAPI(nil, "k", nil, 0, nil)
+		p.baseURL, p.retry = srv.URL, testRetry
+		got, err := p.Convert(context.Background(), "USD", "BRL", 100)
+		if err != nil || got != 500 || calls.Load() != 3 {
+			t.Fatalf("expected 500 after 3 requests, got %d err=%v requests=%d", got, err, calls.Load())
+		}
+	})
+}
+
+func TestRetryPolicyLimits(t *testing.T) {
+	cases := []struct {
+		name      string
+		statuses  []int
+		wantCalls int32
+		wantErr   bool
+	}{
+		{name: "client error is not retried", statuses: []int{http.StatusBadRequest}, wantCalls: 1, wantErr: true},
+		{name: "gives up after max attempts", statuses: []int{500, 502, 503, 504}, wantCalls: 3, wantErr: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			srv, calls := scriptedServer(t, `{"success":true,"rates":{"BRL":5}}`, tc.statuses...)
+			res, err := fetchWithRetry(context.Background(), nil, srv.URL, nil, testRetry, nil)
+			if err != nil {
+				t.Fatalf("fetch: %v", err)
+			}
+			if calls.Load() != tc.wantCalls || (res.Status != http.StatusOK) != tc.wantErr {
+				t.Fatalf("got status=%d after %d requests", res.Status, calls.Load())
+			}
+		})
+	}
+}
+
+func TestRetryHonorsRetryAfterWithinDeadline(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Retry-After", "1")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	// Retry-After (1s) does not fit in the deadline: give up right away
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	res, err := fetchWithRetry(ctx, nil, srv.URL, nil, testRetry, nil)
+	if err != nil || res.Status != http.StatusTooManyRequests || calls.Load() != 1 {
+		t.Fatalf("expected a single 429, got %+v err=%v requests=%d", res, err, calls.Load())
+	}
+	if d := time.Since(start); d > 150*time.Millisecond {
+		t.Fatalf("should not wait past the deadline, took %v", d)
+	}
+}
+
+func TestRetryAfterParsing(t *testing.T) {
+	now := time.Date(2025, 9, 19, 12, 0, 0, 0, time.UTC)
+	for v, want := range map[string]time.Duration{
+		"3":                             3 * time.Second,
+		"Fri, 19 Sep 2025 12:00:10 GMT": 10 * time.Second,
+		"Fri, 19 Sep 2025 11:00:00 GMT": 0,
+	} {
+		h := http.Header{"Retry-After": {v}}
+		if got, ok := retryAfter(h, now); !ok || got != want {
+			t.Errorf("retryAfter(%q) = %v, %t; want %v", v, got, ok, want)
+		}
+	}
+	if _, ok := retryAfter(http.Header{"Retry-After": {"soon"}}, now); ok {
+		t.Errorf("expected invalid Retry-After to be ignored")
+	}
+}
+
+func TestRetryBackoffIsCappedAndJittered(t *testing.T) {
+	rp := RetryPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second, Jitter: 0.2}
+	for n, base := range map[int]time.Duration{1: 100 * time.Millisecond, 3: 400 * time.Millisecond, 10: time.Second} {
+		for range 20 {
+			if d := rp.backoff(n); d > base || d < base*8/10 {
+				t.Fatalf("backoff(%d) = %v, want within 20%% below %v", n, d, base)
+			}
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/thiagozs/go-exchange/internal/logger"
+)
+
+// ErrCurrencyNotSupported is returned when a provider has no rate for one of
+// the requested currencies.
+var ErrCurrencyNotSupported = errors.New("currency not supported")
+
+const nameStatic = "static"
+
+// StaticProvider serves rates from a local JSON file, for development and
+// tests without API keys. The file maps a base currency to target rates:
+//
+//	{"USD":{"BRL":5.43,"EUR":0.92}}
+//
+// It is re-read when its modification time changes. Pairs missing from the
+// file are derived from the inverse rate or through the pivot currency.
+type StaticProvider struct {
+	path  string
+	pivot string
+	log   *logger.Logger
+
+	mu      sync.RWMutex
+	rates   map[string]map[string]float64
+	modTime time.Time
+}
+
+// NewStaticProvider constructs a StaticProvider reading path. The file is
+// loaded on first use; pivot defaults to USD.
+func NewStaticProvider(lg *logger.Logger, path, pivot string) *StaticProvider {
+	lg = lg.With(map[string]any{"provider": "static"})
+	if pivot == "" {
+		pivot = "USD"
+	}
+	return &StaticProvider{path: path, pivot: strings.ToUpper(pivot), log: lg}
+}
+
+// reload re-reads the rates file when its modification time changed. On
+// failure the previously loaded rates are kept.
+func (s *StaticProvider) reload(ctx context.Context) error {
+	fi, err := os.Stat(s.path)
+	if err != nil {
+		return err
+	}
+	s.mu.RLock()
+	current := s.rates != nil && fi.ModTime().Equal(s.modTime)
+	s.mu.RUnlock()
+	if current {
+		return nil
+	}
+
+	b, err := os.ReadFile(s.path)
+	if err != nil {
+		return err
+	}
+	var raw map[string]map[string]float64
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return fmt.Errorf("decode static rates %s: %w", s.path, err)
+	}
+	rates := make(map[string]map[string]float64, len(raw))
+	for base, targets := range raw {
+		m := make(map[string]float64, len(targets))
+		for to, r := range targets {
+			if r > 0 {
+				m[strings.ToUpper(to)] = r
+			}
+		}
+		rates[strings.ToUpper(base)] = m
+	}
+
+	s.mu.Lock()
+	s.rates, s.modTime = rates, fi.ModTime()
+	s.mu.Unlock()
+	if s.log != nil {
+		s.log.WithContext(ctx).Infof("loaded static rates from %s (%d base currencies)", s.path, len(rates))
+	}
+	return nil
+}
+
+// pair returns the direct or inverse rate for from->to. Callers hold s.mu.
+func (s *StaticProvider) pair(from, to string) (float64, bool) {
+	if from == to {
+		return 1, true
+	}
+	if r, ok := s.rates[from][to]; ok {
+		return r, true
+	}
+	if r, ok := s.rates[to][from]; ok {
+		return 1 / r, true
+	}
+	return 0, false
+}
+
+func (s *StaticProvider) rate(from, to string) (float64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if r, ok := s.pair(from, to); ok {
+		return r, nil
+	}
+	toPivot, ok1 := s.pair(from, s.pivot)
+	fromPivot, ok2 := s.pair(s.pivot, to)
+	if ok1 && ok2 {
+		return toPivot * fromPivot, nil
+	}
+	return 0, fmt.Errorf("%w: no static rate for %s->%s", ErrCurrencyNotSupported, from, to)
+}
+
+func (s *StaticProvider) Convert(ctx context.Context, from, to string, amount int64) (int64, error) {
+	res, err := s.ConvertDetailed(ctx, from, to, amount)
+	return res.ResultCents, err
+}
+
+func (s *StaticProvider) ConvertDetailed(ctx context.Context, from, to string, amount int64) (ConvertResult, error) {
+	if err := s.reload(ctx); err != nil {
+		s.mu.RLock()
+		loaded := s.rates != nil
+		s.mu.RUnlock()
+		if !loaded {
+			return ConvertResult{}, err
+		}
+		if s.log != nil {
+			s.log.WithContext(ctx).Warnf("static rates reload failed, keeping previous rates: %v", err)
+		}
+	}
+	rate, err := s.rate(strings.ToUpper(from), strings.ToUpper(to))
+	if err != nil {
+		return ConvertResult{}, err
+	}
+	s.mu.RLock()
+	ts := s.modTime
+	s.mu.RUnlock()
+	resultUnits := float64(amount) / 100.0 * rate
+	return ConvertResult{ResultCents: int64(math.Round(resultUnits * 100.0)), Rate: rate, RateTimestamp: ts, Source: nameStatic}, nil
+}
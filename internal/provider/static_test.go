@@ -0,0 +1,102 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/thiagozs/go-exchange/internal/logger"
+)
+
+func writeRates(t *testing.T, path, body string, mtime time.Time) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(body), 0o600); err != nil {
+		t.Fatalf("write rates: %v", err)
+	}
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatalf("chtimes: %v", err)
+	}
+}
+
+func TestStaticProviderRates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rates.json")
+	writeRates(t, path, `{"USD":{"BRL":5.0,"EUR":0.8},"GBP":{"USD":1.25}}`, time.Now())
+	p := NewStaticProvider(nil, path, "USD")
+
+	cases := []struct {
+		name     string
+		from, to string
+		want     int64
+		err      error
+	}{
+		{name: "direct", from: "USD", to: "BRL", want: 50000},
+		{name: "inverse", from: "BRL", to: "USD", want: 2000},
+		{name: "lowercase", from: "usd", to: "eur", want: 8000},
+		{name: "through pivot", from: "EUR", to: "BRL", want: 62500},
+		{name: "pivot with inverse legs", from: "GBP", to: "BRL", want: 62500},
+		{name: "same currency", from: "JPY", to: "JPY", want: 10000},
+		{name: "unknown currency", from: "USD", to: "XYZ", err: ErrCurrencyNotSupported},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := p.Convert(context.Background(), tc.from, tc.to, 10000)
+			if !errors.Is(err, tc.err) || got != tc.want {
+				t.Fatalf("got %d err=%v, want %d err=%v", got, err, tc.want, tc.err)
+			}
+		})
+	}
+}
+
+func TestStaticProviderReloadsOnModification(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rates.json")
+	start := time.Now().Add(-time.Hour).Truncate(time.Second)
+	writeRates(t, path, `{"USD":{"BRL":5.0}}`, start)
+	p := NewStaticProvider(nil, path, "")
+	ctx := context.Background()
+
+	res, err := p.ConvertDetailed(ctx, "USD", "BRL", 100)
+	if err != nil || res.ResultCents != 500 || !res.RateTimestamp.Equal(start) || res.Source != "static" {
+		t.Fatalf("unexpected first result %+v err=%v", res, err)
+	}
+
+	writeRates(t, path, `{"USD":{"BRL":6.0}}`, start.Add(time.Minute))
+	if res, _ = p.ConvertDetailed(ctx, "USD", "BRL", 100); res.ResultCents != 600 {
+		t.Fatalf("expected reloaded rate, got %+v", res)
+	}
+
+	// a broken file keeps the last good rates
+	writeRates(t, path, `{"USD":`, start.Add(2*time.Minute))
+	if res, err = p.ConvertDetailed(ctx, "USD", "BRL", 100); err != nil || res.ResultCents != 600 {
+		t.Fatalf("expected previous rates on a bad reload, got %+v err=%v", res, err)
+	}
+}
+
+func TestStaticProviderMissingFile(t *testing.T) {
+	p := NewStaticProvider(nil, filepath.Join(t.TempDir(), "missing.json"), "")
+	if _, err := p.Convert(context.Background(), "USD", "BRL", 100); err == nil {
+		t.Fatalf("expected an error without a rates file")
+	}
+}
+
+func TestStaticProviderLogsBoundProviderField(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rates.json")
+	writeRates(t, path, `{"USD":{"BRL":5.0}}`, time.Now())
+	var buf bytes.Buffer
+	lg := logger.New(logger.Options{Format: "json", Level: "info", Out: &buf})
+	p := NewStaticProvider(lg, path, "USD")
+	if _, err := p.Convert(context.Background(), "USD", "BRL", 100); err != nil {
+		t.Fatalf("convert: %v", err)
+	}
+	var entry map[string]any
+	if err := json.Unmarshal(bytes.SplitN(buf.Bytes(), []byte("\n"), 2)[0], &entry); err != nil {
+		t.Fatalf("decode log %q: %v", buf.String(), err)
+	}
+	if entry["provider"] != "static" {
+		t.Fatalf("expected provider=static on the load log, got %v", entry)
+	}
+}
@@ -0,0 +1,150 @@
+package provider
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/thiagozs/go-exchange/internal/logger"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/thiagozs/go-exchange/internal/provider"
+
+// Provider names reported on spans and metrics.
+const (
+	nameExchangerateHost = "exchangerate.host"
+	nameExchangeRateAPI  = "exchangerate-api"
+	nameBCB              = "bcb"
+)
+
+// convertMetrics holds the provider instruments, created on first use from
+// the global MeterProvider so SetupOTel can install it after the providers
+// are built.
+var convertMetrics struct {
+	once     sync.Once
+	duration metric.Float64Histogram
+	errors   metric.Int64Counter
+	upstream metric.Int64Counter
+}
+
+func initConvertMetrics() {
+	convertMetrics.once.Do(func() {
+		meter := otel.GetMeterProvider().Meter(instrumentationName)
+		convertMetrics.duration, _ = meter.Float64Histogram("provider.convert.duration",
+			metric.WithDescription("Duration of provider conversions, including rate fetches"),
+			metric.WithUnit("s"),
+		)
+		convertMetrics.errors, _ = meter.Int64Counter("provider.convert.errors",
+			metric.WithDescription("Failed provider conversions"),
+		)
+		convertMetrics.upstream, _ = meter.Int64Counter("provider.upstream.requests",
+			metric.WithDescription("Requests sent to the upstream rate APIs, retries included, as counted against PROVIDER_MONTHLY_QUOTA"),
+		)
+	})
+}
+
+// convertObservation collects what happened during one conversion. The fetch
+// helpers find it in the context to report upstream status and retries.
+type convertObservation struct {
+	span     trace.Span
+	provider string
+	start    time.Time
+
+	mu      sync.Mutex
+	lookups int
+	misses  int
+	status  int
+	retries int
+	ended   bool
+}
+
+type observationCtxKey struct{}
+
+// startConvert starts the provider span for a conversion. The returned
+// observation must be ended with the conversion error.
+func startConvert(ctx context.Context, provider, from, to string) (context.Context, *convertObservation) {
+	ctx, span := otel.Tracer(instrumentationName).Start(ctx, "provider.convert", trace.WithAttributes(
+		attribute.String("provider.name", provider),
+		attribute.String("exchange.from", from),
+		attribute.String("exchange.to", to),
+	))
+	o := &convertObservation{span: span, provider: provider, start: time.Now()}
+	return context.WithValue(ctx, observationCtxKey{}, o), o
+}
+
+// observationFrom returns the conversion observation; nil (a no-op) outside
+// a conversion.
+func observationFrom(ctx context.Context) *convertObservation {
+	o, _ := ctx.Value(observationCtxKey{}).(*convertObservation)
+	return o
+}
+
+// recordLookup records a rate lookup. A conversion with several lookups (BCB
+// cross rates) is a cache hit only if all of them were.
+func (o *convertObservation) recordLookup(hit bool) {
+	if o == nil {
+		return
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.lookups++
+	if !hit {
+		o.misses++
+	}
+}
+
+func (o *convertObservation) setStatus(status int) {
+	if o == nil {
+		return
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.status = status
+}
+
+func (o *convertObservation) addRetry() {
+	if o == nil {
+		return
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.retries++
+}
+
+// end finishes the span and records the metrics. Background refreshes may
+// still hold the context afterwards; their updates are ignored.
+func (o *convertObservation) end(ctx context.Context, err error) {
+	o.mu.Lock()
+	if o.ended {
+		o.mu.Unlock()
+		return
+	}
+	o.ended = true
+	attrs := []attribute.KeyValue{
+		attribute.Bool("exchange.cache_hit", o.lookups > 0 && o.misses == 0),
+		attribute.Int("exchange.retries", o.retries),
+	}
+	if o.status != 0 {
+		attrs = append(attrs, attribute.Int("http.response.status_code", o.status))
+	}
+	o.mu.Unlock()
+
+	o.span.SetAttributes(attrs...)
+	if err != nil {
+		logger.RecordSpanError(o.span, err, err.Error())
+	}
+	o.span.End()
+
+	initConvertMetrics()
+	name := metric.WithAttributes(attribute.String("provider.name", o.provider))
+	if convertMetrics.duration != nil {
+		convertMetrics.duration.Record(ctx, time.Since(o.start).Seconds(), name)
+	}
+	if err != nil && convertMetrics.errors != nil {
+		convertMetrics.errors.Add(ctx, 1, name)
+	}
+}
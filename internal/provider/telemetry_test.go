@@ -0,0 +1,168 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/thiagozs/go-exchange/internal/logger"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// useTelemetry installs in-memory trace and metric pipelines for a test. The
+// provider instruments are recreated against the new MeterProvider.
+func useTelemetry(t *testing.T) (*tracetest.InMemoryExporter, *sdkmetric.ManualReader) {
+	t.Helper()
+	exp := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exp))
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	prevTP, prevMP := otel.GetTracerProvider(), otel.GetMeterProvider()
+	otel.SetTracerProvider(tp)
+	otel.SetMeterProvider(mp)
+	convertMetrics.once = sync.Once{}
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prevTP)
+		otel.SetMeterProvider(prevMP)
+		convertMetrics.once = sync.Once{}
+		_ = tp.Shutdown(context.Background())
+		_ = mp.Shutdown(context.Background())
+	})
+	return exp, reader
+}
+
+func spanAttrs(s tracetest.SpanStub) map[attribute.Key]attribute.Value {
+	m := map[attribute.Key]attribute.Value{}
+	for _, kv := range s.Attributes {
+		m[kv.Key] = kv.Value
+	}
+	return m
+}
+
+func collectMetrics(t *testing.T, reader *sdkmetric.ManualReader) map[string]metricdata.Aggregation {
+	t.Helper()
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatalf("collect: %v", err)
+	}
+	out := map[string]metricdata.Aggregation{}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			out[m.Name] = m.Data
+		}
+	}
+	return out
+}
+
+func TestConvertSpanAndMetrics(t *testing.T) {
+	exp, reader := useTelemetry(t)
+	srv, _ := scriptedServer(t, `{"result":"success","conversion_rates":{"BRL":5}}`, http.StatusBadGateway)
+
+	p := NewExchangeRateAPI(nil, "k", newFakeCache(), 10*time.Minute, nil)
+	p.baseURL, p.retry = srv.URL, testRetry
+	ctx := context.Background()
+	for range 2 {
+		if _, err := p.Convert(ctx, "USD", "BRL", 100); err != nil {
+			t.Fatalf("convert: %v", err)
+		}
+	}
+
+	spans := exp.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 provider spans, got %d", len(spans))
+	}
+	cases := []struct {
+		span     tracetest.SpanStub
+		cacheHit bool
+		retries  int64
+		status   int64
+	}{
+		{span: spans[0], cacheHit: false, retries: 1, status: http.StatusOK},
+		{span: spans[1], cacheHit: true},
+	}
+	for i, tc := range cases {
+		a := spanAttrs(tc.span)
+		if tc.span.Name != "provider.convert" || a["provider.name"].AsString() != nameExchangeRateAPI ||
+			a["exchange.from"].AsString() != "USD" || a["exchange.to"].AsString() != "BRL" {
+			t.Fatalf("span %d: unexpected name/attributes %s %v", i, tc.span.Name, a)
+		}
+		if a["exchange.cache_hit"].AsBool() != tc.cacheHit || a["exchange.retries"].AsInt64() != tc.retries ||
+			a["http.response.status_code"].AsInt64() != tc.status {
+			t.Fatalf("span %d: unexpected attributes %v", i, a)
+		}
+	}
+
+	m := collectMetrics(t, reader)
+	hist, ok := m["provider.convert.duration"].(metricdata.Histogram[float64])
+	if !ok || len(hist.DataPoints) != 1 || hist.DataPoints[0].Count != 2 {
+		t.Fatalf("expected 2 duration observations, got %+v", m["provider.convert.duration"])
+	}
+	if _, ok := m["provider.convert.errors"]; ok {
+		t.Fatalf("no errors expected")
+	}
+}
+
+func TestConvertErrorIsRecorded(t *testing.T) {
+	exp, reader := useTelemetry(t)
+	srv, _ := scriptedServer(t, "", http.StatusBadRequest)
+
+	p := NewExchangerateHost(nil, "", nil, 0, nil)
+	p.baseURL = srv.URL
+	if _, err := p.Convert(context.Background(), "USD", "BRL", 100); err == nil {
+		t.Fatalf("expected an error")
+	}
+
+	spans := exp.GetSpans()
+	if len(spans) != 1 || spans[0].Status.Code != codes.Error {
+		t.Fatalf("expected one errored span, got %+v", spans)
+	}
+	if got := spanAttrs(spans[0])["http.response.status_code"].AsInt64(); got != http.StatusBadRequest {
+		t.Fatalf("expected upstream status 400, got %d", got)
+	}
+	sum, ok := collectMetrics(t, reader)["provider.convert.errors"].(metricdata.Sum[int64])
+	if !ok || len(sum.DataPoints) != 1 || sum.DataPoints[0].Value != 1 {
+		t.Fatalf("expected one error counted, got %+v", sum)
+	}
+	if v, _ := sum.DataPoints[0].Attributes.Value("provider.name"); v.AsString() != nameExchangerateHost {
+		t.Fatalf("expected provider.name attribute, got %v", v)
+	}
+}
+
+func TestConvertErrorLoggedOnceOnSpan(t *testing.T) {
+	exp, _ := useTelemetry(t)
+	srv, _ := scriptedServer(t, "", http.StatusBadRequest)
+
+	lg := logger.New(logger.Options{Format: "json", Level: "info", Out: &bytes.Buffer{}})
+	p := NewExchangerateHost(lg, "", nil, 0, nil)
+	p.baseURL = srv.URL
+	if _, err := p.Convert(context.Background(), "USD", "BRL", 100); err == nil {
+		t.Fatalf("expected an error")
+	}
+
+	spans := exp.GetSpans()
+	if len(spans) != 1 || spans[0].Status.Code != codes.Error {
+		t.Fatalf("expected one errored span, got %+v", spans)
+	}
+	// logged by ErrorCtx and returned to obs.end: a single exception event
+	var exceptions int
+	for _, ev := range spans[0].Events {
+		if ev.Name == "exception" {
+			exceptions++
+		}
+	}
+	if exceptions != 1 {
+		t.Fatalf("expected one exception event, got %d: %+v", exceptions, spans[0].Events)
+	}
+	if got := spanAttrs(spans[0])["exception.type"].AsString(); got != "*errors.errorString" {
+		t.Fatalf("expected exception.type attribute, got %q", got)
+	}
+}
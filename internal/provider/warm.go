@@ -0,0 +1,80 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/thiagozs/go-exchange/internal/logger"
+)
+
+// Warmer is implemented by providers that cache rates per base currency and
+// can load them before the first conversion asks for them.
+type Warmer interface {
+	Warm(ctx context.Context, base string) error
+}
+
+// Warm loads the rates for base into the rate cache.
+func (p *ExchangerateHost) Warm(ctx context.Context, base string) error {
+	_, _, _, err := p.latest(ctx, base)
+	return err
+}
+
+// Warm loads the rates for base into the rate cache.
+func (p *ExchangeRateAPI) Warm(ctx context.Context, base string) error {
+	_, _, _, err := p.latest(ctx, base)
+	return err
+}
+
+// Warm warms every source that caches rates per base.
+func (a *AggregateProvider) Warm(ctx context.Context, base string) error {
+	var errs []error
+	for _, src := range a.sources {
+		if w, ok := As[Warmer](src.Provider); ok {
+			if err := w.Warm(ctx, base); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", src.Name, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// WarmBases prefetches the rates for bases concurrently, using the same cache
+// keys conversions read. Failures are logged and joined into the returned
+// error; providers that are not a Warmer have nothing to prefetch.
+func WarmBases(ctx context.Context, p Provider, bases []string, lg *logger.Logger) error {
+	w, ok := As[Warmer](p)
+	if !ok {
+		if lg != nil && len(bases) > 0 {
+			lg.WithContext(ctx).Infof("warmup skipped: provider %T does not cache rates per base", p)
+		}
+		return nil
+	}
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+	for _, base := range bases {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := w.Warm(ctx, base); err != nil {
+				if lg != nil {
+					lg.WithContext(ctx).Warnf("warmup for %s failed: %v", base, err)
+				}
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("warmup %s: %w", base, err))
+				mu.Unlock()
+				return
+			}
+			if lg != nil {
+				lg.WithContext(ctx).Infof("warmed up rates for %s", base)
+			}
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
@@ -0,0 +1,59 @@
+package provider
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWarmBasesFillsRateCache(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if r.URL.Query().Get("base") == "XXX" {
+			http.Error(w, "unknown base", http.StatusBadRequest)
+			return
+		}
+		_, _ = w.Write([]byte(`{"success":true,"rates":{"BRL":5}}`))
+	}))
+	defer srv.Close()
+
+	c := newFakeCache()
+	p := NewExchangerateHost(nil, "", c, time.Minute, nil)
+	p.baseURL = srv.URL
+
+	if err := WarmBases(context.Background(), p, []string{"USD", "EUR", "BRL"}, nil); err != nil {
+		t.Fatalf("warmup: %v", err)
+	}
+	for _, base := range []string{"USD", "EUR", "BRL"} {
+		if c.m["rates:exchangerate.host:"+base] == "" {
+			t.Fatalf("expected rates for %s in the cache, got keys %v", base, c.m)
+		}
+	}
+
+	// conversions read the warmed entries
+	before := calls.Load()
+	if _, err := p.Convert(context.Background(), "EUR", "BRL", 100); err != nil {
+		t.Fatalf("convert: %v", err)
+	}
+	if calls.Load() != before {
+		t.Fatal("expected the conversion to hit the warmed cache")
+	}
+
+	if err := WarmBases(context.Background(), p, []string{"GBP", "XXX"}, nil); err == nil || !strings.Contains(err.Error(), "warmup XXX") {
+		t.Fatalf("expected the XXX failure, got %v", err)
+	}
+	if c.m["rates:exchangerate.host:GBP"] == "" {
+		t.Fatal("a failed base must not stop the others")
+	}
+}
+
+func TestWarmBasesSkipsProvidersWithoutRateCache(t *testing.T) {
+	if err := WarmBases(context.Background(), NewStaticProvider(nil, "", "USD"), []string{"USD"}, nil); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+}
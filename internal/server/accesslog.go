@@ -0,0 +1,244 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/thiagozs/go-exchange/internal/logger"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"golang.org/x/time/rate"
+)
+
+// accessFields collects the fields handlers add to the access log of their
+// request; instrumentHandler wraps them and never sees their context.
+type accessFields struct {
+	mu     sync.Mutex
+	fields map[string]any
+}
+
+type accessFieldsCtxKey struct{}
+
+// withAccessFields returns a copy of ctx collecting access log fields in f.
+func withAccessFields(ctx context.Context, f *accessFields) context.Context {
+	return context.WithValue(ctx, accessFieldsCtxKey{}, f)
+}
+
+// addAccessFields adds fields to the access log entry of the request of ctx.
+func addAccessFields(ctx context.Context, fields map[string]any) {
+	f, ok := ctx.Value(accessFieldsCtxKey{}).(*accessFields)
+	if !ok {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.fields == nil {
+		f.fields = map[string]any{}
+	}
+	maps.Copy(f.fields, fields)
+}
+
+// copyTo adds the collected fields to fields.
+func (f *accessFields) copyTo(fields map[string]any) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	maps.Copy(fields, f.fields)
+}
+
+// accessLogThrottle limits access-log volume. Excluded paths are never
+// logged. Successful requests are kept with probability sampleRate, and up
+// to the configured rate every remaining request is logged; above it only one
+// in sampleN successful requests is logged. Errors and slow requests are
+// always logged. Entries that are sampled away are aggregated by route, so
+// requests to random paths cannot grow the aggregate, and reported
+// periodically by flush; every suppressed entry is counted.
+type accessLogThrottle struct {
+	limiter    *rate.Limiter // nil disables throttling
+	sampleN    uint64
+	slow       time.Duration
+	exclude    map[string]bool
+	sampleRate float64 // 0 or 1 disables sampling
+	now        func() time.Time
+	rand       func() float64
+
+	suppressedOnce sync.Once
+	suppressed     metric.Int64Counter
+
+	mu       sync.Mutex
+	seq      uint64
+	dropped  int64
+	statuses map[int]int64
+	routes   map[string]int64
+}
+
+func newAccessLogThrottle(limit float64, burst, sampleN int, slow time.Duration) *accessLogThrottle {
+	t := &accessLogThrottle{
+		slow:     slow,
+		now:      time.Now,
+		rand:     rand.Float64,
+		statuses: map[int]int64{},
+		routes:   map[string]int64{},
+	}
+	if limit > 0 {
+		if burst <= 0 {
+			burst = int(limit)
+		}
+		t.limiter = rate.NewLimiter(rate.Limit(limit), max(burst, 1))
+	}
+	t.sampleN = uint64(max(sampleN, 1))
+	return t
+}
+
+// filter excludes paths from the access log and samples successful requests
+// at sampleRate.
+func (t *accessLogThrottle) filter(paths []string, sampleRate float64) *accessLogThrottle {
+	t.exclude = make(map[string]bool, len(paths))
+	for _, p := range paths {
+		t.exclude[p] = true
+	}
+	t.sampleRate = sampleRate
+	return t
+}
+
+// sampling reports whether successful requests are sampled at all.
+func (t *accessLogThrottle) sampling() bool {
+	return t.sampleRate > 0 && t.sampleRate < 1
+}
+
+// admit reports whether the access entry of a request to path, matched by
+// route, should be written. The second value is the sampling factor to record
+// on sampled entries (0 when not sampled).
+func (t *accessLogThrottle) admit(path, route string, status int, duration time.Duration) (bool, uint64) {
+	if t == nil {
+		return true, 0
+	}
+	if t.exclude[path] {
+		t.countSuppressed("excluded")
+		return false, 0
+	}
+	always := status >= http.StatusBadRequest || (t.slow > 0 && duration >= t.slow)
+	var factor uint64
+	if !always && t.sampling() {
+		if t.rand() >= t.sampleRate {
+			t.drop(route, status, "sampled")
+			return false, 0
+		}
+		factor = uint64(math.Round(1 / t.sampleRate))
+	}
+	if t.limiter == nil {
+		return true, factor
+	}
+	// every request consumes a token so the limiter tracks the real rate
+	if t.limiter.AllowN(t.now(), 1) || always {
+		return true, factor
+	}
+
+	t.mu.Lock()
+	t.seq++
+	keep := t.seq%t.sampleN == 0
+	t.mu.Unlock()
+	if keep {
+		return true, max(factor, 1) * t.sampleN
+	}
+	t.drop(route, status, "throttled")
+	return false, 0
+}
+
+// drop aggregates a sampled-away entry for the next summary.
+func (t *accessLogThrottle) drop(route string, status int, reason string) {
+	t.countSuppressed(reason)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.dropped++
+	t.statuses[status]++
+	t.routes[route]++
+}
+
+// countSuppressed adds to http.server.access_log.suppressed, created on first
+// use like the httpMetrics instruments.
+func (t *accessLogThrottle) countSuppressed(reason string) {
+	t.suppressedOnce.Do(func() {
+		t.suppressed, _ = otel.GetMeterProvider().Meter(meterName).Int64Counter("http.server.access_log.suppressed",
+			metric.WithDescription("Access log entries not written, by reason: excluded, sampled or throttled"))
+	})
+	if t.suppressed != nil {
+		t.suppressed.Add(context.Background(), 1, metric.WithAttributes(attribute.String("reason", reason)))
+	}
+}
+
+// flush emits one aggregate line for the entries dropped since the last call
+// and resets the aggregation. Nothing is logged when nothing was dropped.
+func (t *accessLogThrottle) flush(ctx context.Context, lg *logger.Logger) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	dropped, statuses, routes := t.dropped, t.statuses, t.routes
+	t.dropped = 0
+	t.statuses = map[int]int64{}
+	t.routes = map[string]int64{}
+	t.mu.Unlock()
+
+	if dropped == 0 {
+		return
+	}
+
+	statusFields := make(map[string]int64, len(statuses))
+	for code, n := range statuses {
+		statusFields[strconv.Itoa(code)] = n
+	}
+	lg.WithContext(ctx).WithFields(map[string]any{
+		"dropped":   dropped,
+		"statuses":  statusFields,
+		"top_paths": topPaths(routes, 5),
+	}).Info("access log summary")
+}
+
+// run flushes the aggregate every interval until ctx is done.
+func (t *accessLogThrottle) run(ctx context.Context, lg *logger.Logger, interval time.Duration) {
+	if t == nil || (t.limiter == nil && !t.sampling()) || interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			t.flush(context.Background(), lg)
+			return
+		case <-ticker.C:
+			t.flush(ctx, lg)
+		}
+	}
+}
+
+// topPaths returns up to n "path=count" pairs ordered by count.
+func topPaths(paths map[string]int64, n int) []string {
+	keys := make([]string, 0, len(paths))
+	for p := range paths {
+		keys = append(keys, p)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if paths[keys[i]] != paths[keys[j]] {
+			return paths[keys[i]] > paths[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	if len(keys) > n {
+		keys = keys[:n]
+	}
+	out := make([]string, len(keys))
+	for i, p := range keys {
+		out[i] = fmt.Sprintf("%s=%d", p, paths[p])
+	}
+	return out
+}
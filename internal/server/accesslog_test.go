@@ -0,0 +1,248 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/thiagozs/go-exchange/internal/config"
+	"github.com/thiagozs/go-exchange/internal/logger"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func jsonLogLines(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var out []map[string]any
+	for _, line := range bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+		var m map[string]any
+		if err := json.Unmarshal(line, &m); err != nil {
+			t.Fatalf("invalid json log line %q: %v", line, err)
+		}
+		out = append(out, m)
+	}
+	return out
+}
+
+func countMsg(lines []map[string]any, msg string) int {
+	n := 0
+	for _, l := range lines {
+		if l["msg"] == msg {
+			n++
+		}
+	}
+	return n
+}
+
+func TestAccessLogThrottleSamplesBursts(t *testing.T) {
+	cfg := &config.Config{
+		HTTPAddr:               ":0",
+		AccessLogRateLimit:     10,
+		AccessLogBurst:         10,
+		AccessLogSampleN:       5,
+		AccessLogSlowThreshold: time.Second,
+	}
+	var buf bytes.Buffer
+	lg := logger.New(logger.Options{Format: "json", Level: "info", Out: &buf})
+	srv := newTestServer(t, cfg, lg)
+	// freeze the limiter clock so no tokens are refilled during the burst
+	frozen := time.Now()
+	srv.accessLog.now = func() time.Time { return frozen }
+
+	ok := srv.instrumentHandler(srv.handleHealth)
+	for range 100 {
+		ok(httptest.NewRecorder(), httptest.NewRequest("GET", "/health", nil))
+	}
+	failing := srv.instrumentHandler(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "boom", http.StatusInternalServerError)
+	})
+	for range 3 {
+		failing(httptest.NewRecorder(), httptest.NewRequest("GET", "/convert", nil))
+	}
+
+	lines := jsonLogLines(t, &buf)
+	// 10 within the burst, then 1 in 5 of the remaining 90, plus every error
+	if got := countMsg(lines, "access"); got != 10+18+3 {
+		t.Fatalf("expected 31 access entries, got %d", got)
+	}
+
+	buf.Reset()
+	srv.accessLog.flush(context.Background(), lg)
+	lines = jsonLogLines(t, &buf)
+	if len(lines) != 1 || lines[0]["msg"] != "access log summary" {
+		t.Fatalf("expected one summary line, got %v", lines)
+	}
+	if lines[0]["dropped"] != 72.0 {
+		t.Fatalf("expected 72 dropped entries, got %v", lines[0]["dropped"])
+	}
+
+	// exact counters are kept regardless of sampling
+	snap := srv.stats.snapshot()
+	if snap.Total != 103 || snap.Statuses[http.StatusOK] != 100 || snap.Statuses[http.StatusInternalServerError] != 3 {
+		t.Fatalf("unexpected stats snapshot: %+v", snap)
+	}
+
+	// a second flush has nothing to report
+	buf.Reset()
+	srv.accessLog.flush(context.Background(), lg)
+	if buf.Len() != 0 {
+		t.Fatalf("expected no summary after reset, got %s", buf.String())
+	}
+}
+
+func TestAccessLogThrottleAlwaysLogsSlowRequests(t *testing.T) {
+	th := newAccessLogThrottle(1, 1, 1000, 50*time.Millisecond)
+	frozen := time.Now()
+	th.now = func() time.Time { return frozen }
+
+	if ok, _ := th.admit("/convert", "/convert", http.StatusOK, time.Millisecond); !ok {
+		t.Fatalf("first request within burst should be logged")
+	}
+	if ok, _ := th.admit("/convert", "/convert", http.StatusOK, time.Millisecond); ok {
+		t.Fatalf("fast request above the rate should be sampled away")
+	}
+	if ok, _ := th.admit("/convert", "/convert", http.StatusOK, 100*time.Millisecond); !ok {
+		t.Fatalf("slow request must always be logged")
+	}
+}
+
+func TestAccessLogThrottleAggregatesByRoute(t *testing.T) {
+	th := newAccessLogThrottle(1, 1, 1000, 0)
+	frozen := time.Now()
+	th.now = func() time.Time { return frozen }
+
+	for i := range 500 {
+		th.admit(fmt.Sprintf("/assets/%d", i), "/assets/", http.StatusOK, time.Millisecond)
+	}
+	if len(th.routes) != 1 || th.routes["/assets/"] != 499 {
+		t.Fatalf("expected the dropped entries aggregated under their route, got %d keys", len(th.routes))
+	}
+}
+
+// suppressedByReason collects http.server.access_log.suppressed by reason.
+func suppressedByReason(t *testing.T, reader *sdkmetric.ManualReader) map[string]int64 {
+	t.Helper()
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatalf("collect: %v", err)
+	}
+	out := map[string]int64{}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name != "http.server.access_log.suppressed" {
+				continue
+			}
+			for _, dp := range m.Data.(metricdata.Sum[int64]).DataPoints {
+				reason, _ := dp.Attributes.Value("reason")
+				out[reason.AsString()] = dp.Value
+			}
+		}
+	}
+	return out
+}
+
+func TestAccessLogExcludesPaths(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	spans := tracetest.NewSpanRecorder()
+	useProviders(t, sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans)),
+		sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)))
+
+	var buf bytes.Buffer
+	lg := logger.New(logger.Options{Format: "json", Level: "info", Out: &buf})
+	srv := newTestServer(t, &config.Config{HTTPAddr: ":0", CacheTTL: time.Minute,
+		AccessLogExcludePaths: []string{"/health", "/ready"}, AccessLogSampleRate: 1}, lg)
+	useDeps(srv, &mockProv{}, newMemCache(), nil)
+
+	for _, target := range []string{"/health", "/health", "/ready", "/convert?from=USD&to=BRL&amount=1000"} {
+		srv.mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", target, nil))
+	}
+
+	var paths []any
+	for _, l := range jsonLogLines(t, &buf) {
+		if l["msg"] == "access" {
+			paths = append(paths, l["path"])
+		}
+	}
+	if len(paths) != 1 || paths[0] != "/convert" {
+		t.Fatalf("expected only the /convert entry, got %v", paths)
+	}
+	// excluded requests are still traced and counted
+	if got := len(spans.Ended()); got < 4 {
+		t.Fatalf("expected a span per request, got %d", got)
+	}
+	if got := suppressedByReason(t, reader); got["excluded"] != 3 {
+		t.Fatalf("expected 3 excluded entries, got %v", got)
+	}
+}
+
+func TestAccessLogSampleRateAlwaysLogsErrors(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	useProviders(t, sdktrace.NewTracerProvider(), sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)))
+
+	var buf bytes.Buffer
+	lg := logger.New(logger.Options{Format: "json", Level: "info", Out: &buf})
+	srv := newTestServer(t, &config.Config{HTTPAddr: ":0", AccessLogSampleRate: 0.25,
+		AccessLogSlowThreshold: time.Second}, lg)
+	// a deterministic draw: one in four is under the rate
+	var draws int
+	srv.accessLog.rand = func() float64 {
+		draws++
+		if draws%4 == 0 {
+			return 0.1
+		}
+		return 0.9
+	}
+
+	ok := srv.instrumentHandler(srv.handleHealth)
+	for range 40 {
+		ok(httptest.NewRecorder(), httptest.NewRequest("GET", "/health", nil))
+	}
+	for _, status := range []int{http.StatusBadRequest, http.StatusNotFound, http.StatusBadGateway} {
+		h := srv.instrumentHandler(func(w http.ResponseWriter, r *http.Request) {
+			writeError(w, status, "X", "x", nil)
+		})
+		for range 5 {
+			h(httptest.NewRecorder(), httptest.NewRequest("GET", "/convert", nil))
+		}
+	}
+
+	var ok2xx, errs int
+	for _, l := range jsonLogLines(t, &buf) {
+		if l["msg"] != "access" {
+			continue
+		}
+		if l["status"] == float64(http.StatusOK) {
+			ok2xx++
+			if l["sample_rate"] != float64(4) {
+				t.Fatalf("expected sample_rate 4 on a sampled entry, got %v", l)
+			}
+		} else {
+			errs++
+		}
+	}
+	if ok2xx != 10 || errs != 15 {
+		t.Fatalf("expected 10 sampled successes and all 15 errors, got %d and %d", ok2xx, errs)
+	}
+	if draws != 40 {
+		t.Fatalf("errors must not be sampled, drew %d times", draws)
+	}
+	if got := suppressedByReason(t, reader); got["sampled"] != 30 {
+		t.Fatalf("expected 30 sampled-away entries, got %v", got)
+	}
+
+	buf.Reset()
+	srv.accessLog.flush(context.Background(), lg)
+	if lines := jsonLogLines(t, &buf); len(lines) != 1 || lines[0]["dropped"] != float64(30) {
+		t.Fatalf("expected a summary of 30 dropped entries, got %v", lines)
+	}
+}
@@ -0,0 +1,146 @@
+package server
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/thiagozs/go-exchange/internal/alert"
+	"github.com/thiagozs/go-exchange/internal/config"
+)
+
+// requireAdmin restricts next to API keys holding the admin permission:
+// anonymous requests get 401 and keys without the permission 403.
+func (s *Server) requireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key, ok := apiKeyFromContext(r.Context())
+		if !ok {
+			writeError(w, http.StatusUnauthorized, errCodeUnauthorized, "API key required", nil)
+			return
+		}
+		if !key.Has(config.PermAdmin) {
+			s.log.WithContext(r.Context()).Warnf("API key %s denied admin access path=%s", key.Name, r.URL.Path)
+			writeError(w, http.StatusForbidden, errCodeForbidden, "admin permission required", nil)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// adminCacheParams are the query parameters of handleAdminCache.
+var adminCacheParams = queryParams{"prefix"}
+
+// handleAdminCache flushes cached entries: DELETE /admin/cache?prefix=convert:
+// removes every key starting with prefix. An empty prefix is rejected so the
+// whole Redis database cannot be wiped by accident.
+func (s *Server) handleAdminCache(w http.ResponseWriter, r *http.Request) {
+	prefix := r.URL.Query().Get("prefix")
+	if prefix == "" {
+		writeError(w, http.StatusBadRequest, errCodeMissingParameters, "prefix is required", nil)
+		return
+	}
+
+	ctx := r.Context()
+	deleted, err := s.cache.DeleteByPrefix(ctx, prefix)
+	if err != nil {
+		s.log.ErrorCtx(ctx, err, "cache flush failed", map[string]any{"prefix": prefix, "deleted": deleted})
+		writeError(w, http.StatusInternalServerError, errCodeCacheFlushFailed, "cache flush failed", map[string]any{"deleted": deleted})
+		return
+	}
+	key, _ := apiKeyFromContext(ctx)
+	s.log.WithContext(ctx).Infof("cache flushed by %s prefix=%s deleted=%d", key.Name, prefix, deleted)
+
+	writeJSON(w, http.StatusOK, map[string]any{"prefix": prefix, "deleted": deleted})
+}
+
+// handleAdminLogLevel reads (GET) or changes (PUT {"level":"debug"}) the log
+// level of the running process, so debug logs can be enabled without a
+// restart.
+func (s *Server) handleAdminLogLevel(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		writeJSON(w, http.StatusOK, map[string]any{"level": s.log.Level()})
+	case http.MethodPut:
+		var req struct {
+			Level string `json:"level"`
+		}
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<10)).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, errCodeInvalidJSON, "invalid JSON body", nil)
+			return
+		}
+		if req.Level == "" {
+			writeError(w, http.StatusBadRequest, errCodeMissingParameters, "level is required", nil)
+			return
+		}
+		previous := s.log.Level()
+		if err := s.log.SetLevel(req.Level); err != nil {
+			writeError(w, http.StatusBadRequest, errCodeInvalidParameter, err.Error(), nil)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"level": s.log.Level(), "previous": previous})
+	}
+}
+
+// providerSwitch records a PUT /admin/provider.
+type providerSwitch struct {
+	From string    `json:"from"`
+	To   string    `json:"to"`
+	By   string    `json:"by"`
+	At   time.Time `json:"at"`
+}
+
+// handleAdminProvider reads (GET) or switches (PUT {"provider":"bcb"}) the
+// provider conversions use, so an upstream outage can be routed around
+// without a rollout. The provider must be EXCHANGE_PROVIDER or one of
+// ALLOWED_PROVIDER_OVERRIDES; conversions in flight finish with the old one.
+func (s *Server) handleAdminProvider(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		name, _ := s.svc.Provider()
+		writeJSON(w, http.StatusOK, map[string]any{"provider": name})
+	case http.MethodPut:
+		var req struct {
+			Provider string `json:"provider"`
+		}
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<10)).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, errCodeInvalidJSON, "invalid JSON body", nil)
+			return
+		}
+		if req.Provider == "" {
+			writeError(w, http.StatusBadRequest, errCodeMissingParameters, "provider is required", nil)
+			return
+		}
+		p, ok := s.overrides[req.Provider]
+		if configured := cmp.Or(s.cfg.Provider, "exchangerate.host"); req.Provider == configured {
+			p, ok = s.prov, true
+		}
+		if !ok {
+			writeError(w, http.StatusBadRequest, errCodeInvalidProvider,
+				fmt.Sprintf("provider %q is not allowed: expected EXCHANGE_PROVIDER or one of ALLOWED_PROVIDER_OVERRIDES", req.Provider), nil)
+			return
+		}
+
+		ctx := r.Context()
+		key, _ := apiKeyFromContext(ctx)
+		s.switchMu.Lock()
+		previous := s.svc.SetProvider(req.Provider, p)
+		sw := &providerSwitch{From: previous, To: req.Provider, By: key.Name, At: time.Now().UTC()}
+		s.switched = sw
+		s.switchMu.Unlock()
+		s.log.WithContext(ctx).Infof("provider switched by %s from %s to %s", sw.By, sw.From, sw.To)
+
+		writeJSON(w, http.StatusOK, map[string]any{"provider": req.Provider, "previous": previous})
+	}
+}
+
+// handleAdminAlerts lists the state of every RATE_ALERTS alert: GET
+// /admin/alerts. The list is empty when no alert is configured.
+func (s *Server) handleAdminAlerts(w http.ResponseWriter, r *http.Request) {
+	statuses := []alert.Status{}
+	if s.alerts != nil {
+		statuses = s.alerts.Statuses()
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"alerts": statuses})
+}
@@ -0,0 +1,263 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/thiagozs/go-exchange/internal/alert"
+	"github.com/thiagozs/go-exchange/internal/config"
+	"github.com/thiagozs/go-exchange/internal/logger"
+	"github.com/thiagozs/go-exchange/internal/provider"
+)
+
+// adminKeys gives ops the admin permission and partner only cache_bypass.
+const adminKeys = "ops:opskey:admin,partner:partnerkey:cache_bypass"
+
+func TestAdminCacheFlushByPrefix(t *testing.T) {
+	srv, c := newKeyedTestServer(t, &config.Config{AdminEnabled: true}, adminKeys, nil)
+	c.m = map[string]string{
+		"convert:USD:BRL:1000": "a",
+		"convert:EUR:BRL:500":  "b",
+		"rates:bcb:USD":        "c",
+	}
+
+	w := doAs(srv, "opskey", http.MethodDelete, "/admin/cache?prefix=convert:")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body)
+	}
+	var out struct {
+		Prefix  string `json:"prefix"`
+		Deleted int64  `json:"deleted"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if out.Prefix != "convert:" || out.Deleted != 2 {
+		t.Fatalf("unexpected response %+v", out)
+	}
+	if len(c.m) != 1 || c.m["rates:bcb:USD"] != "c" {
+		t.Fatalf("expected only the rates key to survive, got %v", c.m)
+	}
+}
+
+func TestAdminCacheRejections(t *testing.T) {
+	srv, c := newKeyedTestServer(t, &config.Config{AdminEnabled: true}, adminKeys, nil)
+	c.m = map[string]string{"convert:USD:BRL:1000": "a"}
+
+	cases := []struct {
+		name   string
+		method string
+		target string
+		apiKey string
+		status int
+	}{
+		{"anonymous", http.MethodDelete, "/admin/cache?prefix=convert:", "", http.StatusUnauthorized},
+		{"unknown key", http.MethodDelete, "/admin/cache?prefix=convert:", "nope", http.StatusUnauthorized},
+		{"no admin permission", http.MethodDelete, "/admin/cache?prefix=convert:", "partnerkey", http.StatusForbidden},
+		{"wrong method", http.MethodGet, "/admin/cache?prefix=convert:", "opskey", http.StatusMethodNotAllowed},
+		{"missing prefix", http.MethodDelete, "/admin/cache", "opskey", http.StatusBadRequest},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if w := doAs(srv, tc.apiKey, tc.method, tc.target); w.Code != tc.status {
+				t.Fatalf("expected %d, got %d: %s", tc.status, w.Code, w.Body)
+			}
+		})
+	}
+	if len(c.m) != 1 {
+		t.Fatalf("rejected requests must not delete keys, got %v", c.m)
+	}
+}
+
+func doAdminLogLevel(srv *Server, method, body, apiKey string) *httptest.ResponseRecorder {
+	return sendAs(srv, apiKey, httptest.NewRequest(method, "/admin/loglevel", strings.NewReader(body)))
+}
+
+func TestAdminLogLevelEnablesDebug(t *testing.T) {
+	var buf bytes.Buffer
+	var keys config.APIKeys
+	if err := keys.UnmarshalText([]byte("ops:opskey:admin")); err != nil {
+		t.Fatalf("parse keys: %v", err)
+	}
+	cfg := &config.Config{HTTPAddr: ":0", CacheTTL: time.Minute, APIKeys: keys, AdminEnabled: true}
+	lg := logger.New(logger.Options{Format: "text", Level: "info", Out: &buf})
+	srv := newTestServer(t, cfg, lg)
+
+	lg.Debugf("hidden before")
+	if strings.Contains(buf.String(), "hidden before") {
+		t.Fatalf("debug entry logged at info level")
+	}
+
+	w := doAdminLogLevel(srv, http.MethodPut, `{"level":"debug"}`, "opskey")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body)
+	}
+	var out struct{ Level, Previous string }
+	if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if out.Level != "debug" || out.Previous != "info" {
+		t.Fatalf("unexpected response %+v", out)
+	}
+	if !strings.Contains(buf.String(), "log level changed") {
+		t.Fatalf("expected the level change to be logged, got %q", buf.String())
+	}
+
+	lg.Debugf("visible after")
+	if !strings.Contains(buf.String(), "visible after") {
+		t.Fatalf("expected debug entries after the change, got %q", buf.String())
+	}
+
+	w = doAdminLogLevel(srv, http.MethodGet, "", "opskey")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"level":"debug"`) {
+		t.Fatalf("GET: expected level debug, got %d: %s", w.Code, w.Body)
+	}
+}
+
+func TestAdminLogLevelRejections(t *testing.T) {
+	srv, _ := newKeyedTestServer(t, &config.Config{AdminEnabled: true}, adminKeys, nil)
+	cases := []struct {
+		name, method, body, apiKey string
+		status                     int
+	}{
+		{"anonymous", http.MethodPut, `{"level":"debug"}`, "", http.StatusUnauthorized},
+		{"no admin permission", http.MethodPut, `{"level":"debug"}`, "partnerkey", http.StatusForbidden},
+		{"wrong method", http.MethodPost, `{"level":"debug"}`, "opskey", http.StatusMethodNotAllowed},
+		{"invalid JSON", http.MethodPut, `{level}`, "opskey", http.StatusBadRequest},
+		{"missing level", http.MethodPut, `{}`, "opskey", http.StatusBadRequest},
+		{"unknown level", http.MethodPut, `{"level":"verbose"}`, "opskey", http.StatusBadRequest},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if w := doAdminLogLevel(srv, tc.method, tc.body, tc.apiKey); w.Code != tc.status {
+				t.Fatalf("expected %d, got %d: %s", tc.status, w.Code, w.Body)
+			}
+		})
+	}
+	if got := srv.log.Level(); got != "info" {
+		t.Fatalf("rejected requests must not change the level, got %s", got)
+	}
+}
+
+func TestAdminAlertsListsStates(t *testing.T) {
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer hook.Close()
+	var alerts config.RateAlerts
+	if err := alerts.UnmarshalText([]byte(`[{"pair":"USD-BRL","direction":"above","threshold":5.8,
+		"webhook_url":"` + hook.URL + `/token123"}]`)); err != nil {
+		t.Fatalf("parse alerts: %v", err)
+	}
+	cfg := &config.Config{AdminEnabled: true, RateAlerts: alerts, AlertCheckInterval: time.Minute,
+		AlertWebhookTimeout: time.Second, OutboundAllowHTTP: true, OutboundAllowPrivate: true}
+	srv, _ := newKeyedTestServer(t, cfg, adminKeys, &scriptedProv{rates: []float64{5.9}})
+	useDeps(srv, nil, &stubCache{}, nil)
+	srv.alerts = alert.New(cfg, srv.svc, nil, srv.log)
+	srv.alerts.Check(context.Background())
+
+	get := func(method, apiKey string) *httptest.ResponseRecorder {
+		return doAs(srv, apiKey, method, "/admin/alerts")
+	}
+
+	w := get(http.MethodGet, "opskey")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body)
+	}
+	if strings.Contains(w.Body.String(), "token123") {
+		t.Fatalf("webhook URL leaked: %s", w.Body)
+	}
+	var out struct{ Alerts []alert.Status }
+	if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(out.Alerts) != 1 || out.Alerts[0].Name != "USD-BRL above 5.8" || out.Alerts[0].State != alert.StateFired ||
+		out.Alerts[0].Rate != 5.9 {
+		t.Fatalf("unexpected alerts %+v", out.Alerts)
+	}
+
+	if w := get(http.MethodDelete, "opskey"); w.Code != http.StatusMethodNotAllowed || w.Header().Get("Allow") != "GET, HEAD" {
+		t.Fatalf("expected 405 with Allow: GET, HEAD, got %d", w.Code)
+	}
+	if w := get(http.MethodGet, "partnerkey"); w.Code != http.StatusForbidden {
+		t.Fatalf("expected 403, got %d", w.Code)
+	}
+
+	srv.alerts = nil
+	if w := get(http.MethodGet, "opskey"); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"alerts":[]`) {
+		t.Fatalf("expected an empty list, got %d: %s", w.Code, w.Body)
+	}
+}
+
+// gateProv converts at rate 3 once release is closed, signalling on started
+// when a conversion begins.
+type gateProv struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (g *gateProv) Convert(ctx context.Context, from, to string, amount int64) (int64, error) {
+	g.started <- struct{}{}
+	<-g.release
+	return amount * 3, nil
+}
+
+func TestAdminProviderSwitch(t *testing.T) {
+	srv, _ := newKeyedTestServer(t, &config.Config{AdminEnabled: true}, adminKeys, nil)
+	old := &gateProv{started: make(chan struct{}, 1), release: make(chan struct{})}
+	useDeps(srv, old, nil, nil)
+	srv.cfg.ProviderOverrides = []string{"bcb"}
+	srv.overrides = map[string]provider.Provider{"bcb": &quotingProv{}}
+	switchTo := func(name, apiKey string) *httptest.ResponseRecorder {
+		return sendAs(srv, apiKey, httptest.NewRequest(http.MethodPut, "/admin/provider", strings.NewReader(`{"provider":"`+name+`"}`)))
+	}
+	convert := func() *httptest.ResponseRecorder {
+		return doAs(srv, "", http.MethodGet, "/convert?from=USD&to=BRL&amount=1000&unit=cents")
+	}
+
+	// a conversion in flight when the provider is switched finishes with the old one
+	inflight := make(chan *httptest.ResponseRecorder)
+	go func() { inflight <- convert() }()
+	<-old.started
+	w := switchTo("bcb", "opskey")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"previous":"exchangerate.host"`) {
+		t.Fatalf("expected the switch to bcb, got %d: %s", w.Code, w.Body)
+	}
+	close(old.release)
+	if w := <-inflight; w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"result_cents":3000`) {
+		t.Fatalf("expected the in-flight conversion from the old provider, got %d: %s", w.Code, w.Body)
+	}
+	if w := convert(); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"result_cents":5000`) {
+		t.Fatalf("expected the next conversion from bcb, got %d: %s", w.Code, w.Body)
+	}
+
+	w = doAs(srv, "", http.MethodGet, "/status")
+	var status statusBody
+	if err := json.Unmarshal(w.Body.Bytes(), &status); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if sw := status.Provider.LastSwitch; status.Provider.Active != "bcb" || sw == nil || sw.By != "ops" ||
+		sw.From != "exchangerate.host" || sw.To != "bcb" || sw.At.IsZero() {
+		t.Fatalf("unexpected status provider %+v", status.Provider)
+	}
+	doc := servedOpenAPI(t, srv)
+	assertMatches(t, doc, doc.responseSchema(t, "/status", "GET", "200"), w)
+
+	if w := switchTo("exchangerate.host", "opskey"); w.Code != http.StatusOK {
+		t.Fatalf("expected the switch back, got %d: %s", w.Code, w.Body)
+	}
+	if w := convert(); !strings.Contains(w.Body.String(), `"result_cents":3000`) {
+		t.Fatalf("expected the configured provider again, got %s", w.Body)
+	}
+
+	if w := switchTo("static", "opskey"); w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), errCodeInvalidProvider) {
+		t.Fatalf("expected a provider outside the allowlist rejected, got %d: %s", w.Code, w.Body)
+	}
+	if w := switchTo("bcb", "partnerkey"); w.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 without the admin permission, got %d", w.Code)
+	}
+}
@@ -0,0 +1,54 @@
+package server
+
+import (
+	"cmp"
+	"context"
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/thiagozs/go-exchange/internal/config"
+	"github.com/thiagozs/go-exchange/internal/fee"
+)
+
+type apiKeyCtxKey struct{}
+
+// apiKeyFromContext returns the API key that authenticated the request, if any.
+func apiKeyFromContext(ctx context.Context) (config.APIKey, bool) {
+	k, ok := ctx.Value(apiKeyCtxKey{}).(config.APIKey)
+	return k, ok
+}
+
+// requestAPIKey extracts the credential from X-API-Key or a Bearer token.
+func requestAPIKey(r *http.Request) string {
+	if k := strings.TrimSpace(r.Header.Get("X-API-Key")); k != "" {
+		return k
+	}
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		return strings.TrimSpace(strings.TrimPrefix(auth, "Bearer "))
+	}
+	return ""
+}
+
+// authenticate resolves the request API key and its tenant, whose fee plan
+// prices the conversions. Requests without credentials stay anonymous on the
+// default plan; requests presenting an unknown key are rejected with 401.
+func (s *Server) authenticate(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		presented := requestAPIKey(r)
+		if presented == "" {
+			next(w, r)
+			return
+		}
+		for _, k := range s.cfg.APIKeys {
+			if subtle.ConstantTimeCompare([]byte(k.Key), []byte(presented)) == 1 {
+				ctx := fee.WithTenant(context.WithValue(r.Context(), apiKeyCtxKey{}, k), fee.Tenant{Name: k.Name, Plan: k.Plan})
+				addAccessFields(ctx, map[string]any{"tenant": k.Name, "fee_plan": cmp.Or(k.Plan, config.DefaultFeePlan)})
+				next(w, r.WithContext(ctx))
+				return
+			}
+		}
+		s.log.WithContext(r.Context()).Warnf("rejected request with unknown API key path=%s", r.URL.Path)
+		writeError(w, http.StatusUnauthorized, errCodeInvalidAPIKey, "invalid API key", nil)
+	}
+}
@@ -0,0 +1,224 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/thiagozs/go-exchange/internal/exchange"
+)
+
+// maxBatchItems bounds the number of conversions in a single batch request.
+const maxBatchItems = 100
+
+type batchRequest struct {
+	Items []batchItem `json:"items"`
+}
+
+// batchItem mirrors the /convert query parameters. amount may be a JSON
+// number or string and follows the same unit rules.
+type batchItem struct {
+	From   string          `json:"from"`
+	To     string          `json:"to"`
+	Amount json.RawMessage `json:"amount"`
+	Unit   string          `json:"unit"`
+}
+
+// itemError describes why a batch item was not converted. Code is the one
+// /convert answers for the same failure.
+type itemError struct {
+	Index   int    `json:"index"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+type batchResult struct {
+	Index      int             `json:"index"`
+	Conversion json.RawMessage `json:"conversion,omitempty"`
+	Error      *itemError      `json:"error,omitempty"`
+}
+
+type batchSummary struct {
+	Requested int `json:"requested"`
+	Succeeded int `json:"succeeded"`
+	Failed    int `json:"failed"`
+}
+
+type batchResponse struct {
+	Error   *errorBody    `json:"error,omitempty"`
+	Results []batchResult `json:"results,omitempty"`
+	Errors  []itemError   `json:"errors,omitempty"`
+	Summary batchSummary  `json:"summary"`
+}
+
+// validBatchItem is an item that passed validation.
+type validBatchItem struct {
+	index    int
+	from, to string
+	amount   string
+	unit     string
+}
+
+// batchParams are the query parameters of handleConvertBatch.
+var batchParams = queryParams{"strict", "dry_run", "format"}
+
+// handleConvertBatch converts several amounts in one request.
+//
+// By default invalid items are rejected individually and the valid ones are
+// still converted (200 with per-item errors). With strict=true any invalid
+// item fails the whole batch with 400 listing every invalid item, and no
+// conversion is performed. A batch with no valid item is always a 400.
+//
+// Results are JSON or, with format csv, a header row and one row per item.
+func (s *Server) handleConvertBatch(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	format, ok := negotiateFormat(w, r, formatJSON, formatCSV)
+	if !ok {
+		return
+	}
+	strict, _ := strconv.ParseBool(r.URL.Query().Get("strict"))
+
+	var req batchRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, errCodeInvalidJSON, "invalid JSON body", nil)
+		return
+	}
+	if len(req.Items) == 0 {
+		writeError(w, http.StatusBadRequest, errCodeInvalidBatch, "items must not be empty", nil)
+		return
+	}
+	if len(req.Items) > maxBatchItems {
+		writeError(w, http.StatusBadRequest, errCodeInvalidBatch, "too many items (max "+strconv.Itoa(maxBatchItems)+")", nil)
+		return
+	}
+
+	var valid []validBatchItem
+	var invalid []itemError
+	for i, it := range req.Items {
+		v, err := validateBatchItem(i, it, s.cfg.MaxAmountCents, s.cfg.AmountUnitRequired)
+		if err != nil {
+			var verr *exchange.ValidationError
+			if !errors.As(err, &verr) {
+				verr = &exchange.ValidationError{Code: exchange.CodeInvalidAmount, Message: err.Error()}
+			}
+			invalid = append(invalid, itemError{Index: i, Code: strings.ToUpper(verr.Code), Message: verr.Message})
+			continue
+		}
+		valid = append(valid, v)
+	}
+
+	summary := batchSummary{Requested: len(req.Items)}
+	if len(invalid) > 0 {
+		s.log.WithContext(ctx).Warnf("batch: %d of %d items failed validation strict=%t", len(invalid), len(req.Items), strict)
+		if strict || len(valid) == 0 {
+			summary.Failed = len(req.Items)
+			writeJSON(w, http.StatusBadRequest, batchResponse{Error: newErrorBody(w, errCodeInvalidBatchItems, "invalid batch items", nil), Errors: invalid, Summary: summary})
+			return
+		}
+	}
+
+	results := make([]batchResult, len(req.Items))
+	bodies := make([]map[string]any, len(req.Items))
+	for _, e := range invalid {
+		results[e.Index] = batchResult{Index: e.Index, Error: &e}
+	}
+	policy := s.responseCachePolicy(r)
+	for _, v := range valid {
+		req := exchange.ConvertRequest{From: v.from, To: v.to, Amount: v.amount, Unit: v.unit}
+		policy.apply(&req)
+		c, err := s.svc.Convert(ctx, req)
+		if err != nil {
+			var cerr *exchange.Error
+			if !errors.As(err, &cerr) {
+				cerr = exchange.Classify(v.from, v.to, err)
+			}
+			if convertErrorStatus(cerr) >= http.StatusInternalServerError {
+				s.log.ErrorCtx(ctx, cerr.Err, "batch item failed", map[string]any{"index": v.index, "code": cerr.Code})
+			} else {
+				s.log.WithContext(ctx).Warnf("batch item %d %s->%s rejected code=%s: %v", v.index, v.from, v.to, cerr.Code, cerr.Err)
+			}
+			results[v.index] = batchResult{Index: v.index, Error: &itemError{Index: v.index, Code: cerr.Code, Message: cerr.Message}}
+			continue
+		}
+		bodies[v.index] = conversionBody(c)
+		b, _ := json.Marshal(bodies[v.index])
+		results[v.index] = batchResult{Index: v.index, Conversion: b}
+	}
+
+	for _, res := range results {
+		if res.Error != nil {
+			summary.Failed++
+		} else {
+			summary.Succeeded++
+		}
+	}
+	if format == formatCSV {
+		writeCSV(w, http.StatusOK, batchColumns, batchRows(results, bodies))
+		return
+	}
+	writeJSON(w, http.StatusOK, batchResponse{Results: results, Summary: summary})
+}
+
+// batchColumns are the CSV columns of a batch: the item index, its
+// conversion and, for failed items, the error.
+var batchColumns = append(append([]string{"index"}, conversionColumns...), "error_code", "error_message")
+
+// batchRows are the CSV rows of results, whose conversions are in bodies.
+func batchRows(results []batchResult, bodies []map[string]any) [][]string {
+	rows := make([][]string, len(results))
+	for i, res := range results {
+		row := append([]string{strconv.Itoa(res.Index)}, conversionRow(bodies[i])...)
+		if res.Error != nil {
+			row = append(row, res.Error.Code, res.Error.Message)
+		} else {
+			row = append(row, "", "")
+		}
+		rows[i] = row
+	}
+	return rows
+}
+
+func validateBatchItem(i int, it batchItem, maxCents int64, unitRequired bool) (validBatchItem, error) {
+	if err := exchange.ValidateCurrency("from", it.From); err != nil {
+		return validBatchItem{}, err
+	}
+	if err := exchange.ValidateCurrency("to", it.To); err != nil {
+		return validBatchItem{}, err
+	}
+	if err := exchange.CheckUnit(it.Unit, unitRequired); err != nil {
+		return validBatchItem{}, err
+	}
+	amount := batchAmount(it.Amount)
+	cents, _, err := exchange.ParseAmountUnit(amount, it.Unit, it.From)
+	if err != nil {
+		return validBatchItem{}, err
+	}
+	if err := exchange.CheckMaxAmount(cents, maxCents); err != nil {
+		return validBatchItem{}, err
+	}
+	return validBatchItem{index: i, from: it.From, to: it.To, amount: amount, unit: it.Unit}, nil
+}
+
+// batchAmount returns the textual form of a JSON number or string amount.
+func batchAmount(raw json.RawMessage) string {
+	t := strings.TrimSpace(string(raw))
+	if t == "" || t == "null" {
+		return ""
+	}
+	if strings.HasPrefix(t, `"`) {
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return t
+		}
+		return strings.TrimSpace(s)
+	}
+	return t
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
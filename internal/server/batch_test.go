@@ -0,0 +1,159 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/thiagozs/go-exchange/internal/config"
+	"github.com/thiagozs/go-exchange/internal/exchange"
+	"github.com/thiagozs/go-exchange/internal/logger"
+	"github.com/thiagozs/go-exchange/internal/provider"
+)
+
+func doBatch(t *testing.T, srv *Server, query, body string) (int, batchResponse) {
+	t.Helper()
+	req := httptest.NewRequest("POST", "/convert/batch"+query, strings.NewReader(body))
+	w := httptest.NewRecorder()
+	srv.handleConvertBatch(w, req)
+	var out batchResponse
+	if err := json.NewDecoder(w.Result().Body).Decode(&out); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	return w.Code, out
+}
+
+const mixedBatch = `{"items":[
+	{"from":"USD","to":"BRL","amount":1000},
+	{"from":"US","to":"BRL","amount":1000},
+	{"from":"USD","to":"BRL","amount":"abc"},
+	{"from":"EUR","to":"BRL","amount":"10.50"}
+]}`
+
+func TestBatchMixedLenient(t *testing.T) {
+	srv, _, p := newBypassTestServer(t)
+	status, out := doBatch(t, srv, "", mixedBatch)
+	if status != http.StatusOK {
+		t.Fatalf("expected 200, got %d", status)
+	}
+	if out.Summary != (batchSummary{Requested: 4, Succeeded: 2, Failed: 2}) {
+		t.Fatalf("unexpected summary %+v", out.Summary)
+	}
+	if p.calls != 2 {
+		t.Fatalf("expected valid items to be converted, got %d provider calls", p.calls)
+	}
+	if len(out.Results) != 4 {
+		t.Fatalf("expected 4 results, got %d", len(out.Results))
+	}
+	for i, want := range []string{"", "INVALID_CURRENCY", "INVALID_AMOUNT", ""} {
+		res := out.Results[i]
+		if res.Index != i {
+			t.Fatalf("result %d has index %d", i, res.Index)
+		}
+		if want == "" {
+			if res.Error != nil || len(res.Conversion) == 0 {
+				t.Fatalf("item %d: expected a conversion, got %+v", i, res)
+			}
+			continue
+		}
+		if res.Error == nil || res.Error.Code != want || res.Error.Index != i {
+			t.Fatalf("item %d: expected error %s, got %+v", i, want, res.Error)
+		}
+	}
+
+	var conv map[string]any
+	if err := json.Unmarshal(out.Results[3].Conversion, &conv); err != nil || conv["amount_cents"] != 1050.0 {
+		t.Fatalf("unexpected conversion %s (%v)", out.Results[3].Conversion, err)
+	}
+}
+
+func TestBatchMixedStrict(t *testing.T) {
+	srv, _, p := newBypassTestServer(t)
+	status, out := doBatch(t, srv, "?strict=true", mixedBatch)
+	if status != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", status)
+	}
+	if p.calls != 0 {
+		t.Fatalf("strict mode must not convert anything, got %d provider calls", p.calls)
+	}
+	if out.Error == nil || out.Error.Code != errCodeInvalidBatchItems {
+		t.Fatalf("expected an %s error, got %+v", errCodeInvalidBatchItems, out.Error)
+	}
+	if len(out.Errors) != 2 || out.Errors[0].Index != 1 || out.Errors[1].Index != 2 {
+		t.Fatalf("expected every invalid item listed, got %+v", out.Errors)
+	}
+	if out.Summary != (batchSummary{Requested: 4, Succeeded: 0, Failed: 4}) {
+		t.Fatalf("unexpected summary %+v", out.Summary)
+	}
+}
+
+func TestBatchAllInvalid(t *testing.T) {
+	body := `{"items":[{"from":"","to":"BRL","amount":1},{"from":"USD","to":"BRL","amount":-5}]}`
+	for _, query := range []string{"", "?strict=true"} {
+		srv, _, p := newBypassTestServer(t)
+		status, out := doBatch(t, srv, query, body)
+		if status != http.StatusBadRequest || p.calls != 0 {
+			t.Fatalf("%q: expected 400 without conversions, got %d (calls=%d)", query, status, p.calls)
+		}
+		if len(out.Errors) != 2 || out.Errors[0].Code != "MISSING_CURRENCY" || out.Errors[1].Code != "INVALID_AMOUNT" {
+			t.Fatalf("%q: unexpected errors %+v", query, out.Errors)
+		}
+		if out.Summary != (batchSummary{Requested: 2, Failed: 2}) {
+			t.Fatalf("%q: unexpected summary %+v", query, out.Summary)
+		}
+	}
+}
+
+func TestBatchRejectsAmountAboveMaximum(t *testing.T) {
+	srv, _, p := newBypassTestServer(t)
+	srv.cfg.MaxAmountCents = 1000
+	body := `{"items":[{"from":"USD","to":"BRL","amount":1000},{"from":"USD","to":"BRL","amount":1001},{"from":"USD","to":"BRL","amount":0}]}`
+	status, out := doBatch(t, srv, "", body)
+	if status != http.StatusOK || p.calls != 1 {
+		t.Fatalf("expected the valid item to convert, got %d (calls=%d)", status, p.calls)
+	}
+	if len(out.Results) != 3 || out.Results[1].Error == nil || out.Results[1].Error.Code != "AMOUNT_TOO_LARGE" ||
+		out.Results[2].Error == nil || out.Results[2].Error.Code != "INVALID_AMOUNT" {
+		t.Fatalf("unexpected results %+v", out.Results)
+	}
+}
+
+func TestBatchConversionErrorsUseConvertCodes(t *testing.T) {
+	cases := []struct {
+		name  string
+		err   error
+		code  string
+		level string
+	}{
+		{"unsupported currency", fmt.Errorf("%w: XYZ", provider.ErrCurrencyNotSupported), exchange.CodeCurrencyNotSupported, "WARNING"},
+		{"missing provider key", provider.MissingAPIKeyError{}, exchange.CodeProviderMissingAPIKey, "ERROR"},
+		{"provider error", errors.New("upstream down"), exchange.CodeProviderError, "ERROR"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var logs bytes.Buffer
+			lg := logger.New(logger.Options{Format: "json", Level: "info", Out: &logs})
+			srv := newTestServer(t, &config.Config{HTTPAddr: ":0", CacheTTL: time.Minute}, lg)
+			useDeps(srv, &failingProv{tc.err}, nil, nil)
+			status, out := doBatch(t, srv, "", `{"items":[{"from":"USD","to":"BRL","amount":1000}]}`)
+			if status != http.StatusOK || len(out.Results) != 1 || out.Results[0].Error == nil || out.Results[0].Error.Code != tc.code {
+				t.Fatalf("expected a %s item error, got %d %+v", tc.code, status, out.Results)
+			}
+			var levels []any
+			for _, l := range jsonLogLines(t, &logs) {
+				if msg, _ := l["msg"].(string); strings.HasPrefix(msg, "batch item") {
+					levels = append(levels, l["level"])
+				}
+			}
+			if len(levels) != 1 || levels[0] != tc.level {
+				t.Fatalf("expected one %s log line, got %v", tc.level, levels)
+			}
+		})
+	}
+}
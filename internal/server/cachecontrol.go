@@ -0,0 +1,75 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/thiagozs/go-exchange/internal/config"
+	"github.com/thiagozs/go-exchange/internal/exchange"
+)
+
+// responseCachePolicy says whether a request may read from and write to the
+// rendered-response cache.
+//
+// Combinations:
+//   - no directive:           read + write (dry_run: read only)
+//   - Cache-Control no-cache: skip read, revalidate against the provider, write
+//     (dry_run: skip write)
+//   - Cache-Control no-store: skip read and write
+//
+// Directives are only honoured for API keys holding the cache_bypass
+// permission; anonymous or unprivileged requests are served as if no
+// directive had been sent, so cache-busting cannot be used to hammer the
+// upstream provider.
+type responseCachePolicy struct {
+	read      bool
+	write     bool
+	directive string // honoured Cache-Control directive, if any
+}
+
+// apply sets the cache flags of req.
+func (p responseCachePolicy) apply(req *exchange.ConvertRequest) {
+	req.SkipCacheRead, req.SkipCacheWrite = !p.read, !p.write
+}
+
+func (s *Server) responseCachePolicy(r *http.Request) responseCachePolicy {
+	p := responseCachePolicy{read: true, write: true}
+
+	if dry, _ := strconv.ParseBool(r.URL.Query().Get("dry_run")); dry {
+		p.write = false
+	}
+
+	directive := cacheControlDirective(r.Header.Get("Cache-Control"))
+	if directive == "" {
+		return p
+	}
+	key, ok := apiKeyFromContext(r.Context())
+	if !ok || !key.Has(config.PermCacheBypass) {
+		s.log.WithContext(r.Context()).Debugf("ignoring Cache-Control %s from unprivileged client", directive)
+		return p
+	}
+
+	p.directive = directive
+	p.read = false
+	if directive == "no-store" {
+		p.write = false
+	}
+	s.stats.recordBypass(key.Name, directive)
+	return p
+}
+
+// cacheControlDirective returns "no-store" or "no-cache" when present in the
+// header value; no-store wins because it is the stricter directive.
+func cacheControlDirective(v string) string {
+	found := ""
+	for d := range strings.SplitSeq(v, ",") {
+		switch strings.ToLower(strings.TrimSpace(d)) {
+		case "no-store":
+			return "no-store"
+		case "no-cache":
+			found = "no-cache"
+		}
+	}
+	return found
+}
@@ -0,0 +1,176 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/thiagozs/go-exchange/internal/config"
+)
+
+// memCache is an in-memory cache recording reads and writes.
+type memCache struct {
+	mu   sync.Mutex
+	m    map[string]string
+	gets int
+	sets int
+}
+
+func newMemCache() *memCache { return &memCache{m: map[string]string{}} }
+
+func (c *memCache) Get(ctx context.Context, key string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gets++
+	return c.m[key], nil
+}
+
+func (c *memCache) GetWithTTL(ctx context.Context, key string) (string, time.Duration, error) {
+	v, err := c.Get(ctx, key)
+	return v, 0, err
+}
+
+func (c *memCache) Set(ctx context.Context, key string, value string, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sets++
+	c.m[key] = value
+	return nil
+}
+
+func (c *memCache) GetOrSet(ctx context.Context, key string, ttl time.Duration, fill func(ctx context.Context) (string, error)) (string, error) {
+	if v, _ := c.Get(ctx, key); v != "" {
+		return v, nil
+	}
+	v, err := fill(ctx)
+	if err == nil && v != "" {
+		_ = c.Set(ctx, key, v, ttl)
+	}
+	return v, err
+}
+
+func (c *memCache) Delete(ctx context.Context, key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.m, key)
+	return nil
+}
+
+func (c *memCache) DeleteByPrefix(ctx context.Context, prefix string) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var n int64
+	for k := range c.m {
+		if strings.HasPrefix(k, prefix) {
+			delete(c.m, k)
+			n++
+		}
+	}
+	return n, nil
+}
+
+// countingProv returns a fixed result and counts calls.
+type countingProv struct {
+	mu    sync.Mutex
+	calls int
+}
+
+func (p *countingProv) Convert(ctx context.Context, from, to string, amount int64) (int64, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.calls++
+	return 20000, nil
+}
+
+func newBypassTestServer(t *testing.T) (*Server, *memCache, *countingProv) {
+	t.Helper()
+	p := &countingProv{}
+	srv, c := newKeyedTestServer(t, &config.Config{}, "ops:opskey:cache_bypass,partner:partnerkey", p)
+	return srv, c, p
+}
+
+func doConvert(srv *Server, query, apiKey, cacheControl string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/convert?from=USD&to=BRL&amount=1000"+query, nil)
+	if cacheControl != "" {
+		req.Header.Set("Cache-Control", cacheControl)
+	}
+	return sendAs(srv, apiKey, req)
+}
+
+func TestCacheControlMatrix(t *testing.T) {
+	cases := []struct {
+		name         string
+		apiKey       string
+		cacheControl string
+		query        string
+		wantCalls    int // provider calls for a request made against a warm cache
+		wantSets     int // cache writes made by that request
+	}{
+		{name: "no directive", wantCalls: 0, wantSets: 0},
+		{name: "no-cache privileged", apiKey: "opskey", cacheControl: "no-cache", wantCalls: 1, wantSets: 1},
+		{name: "no-store privileged", apiKey: "opskey", cacheControl: "no-store", wantCalls: 1, wantSets: 0},
+		{name: "no-cache + dry_run", apiKey: "opskey", cacheControl: "no-cache", query: "&dry_run=true", wantCalls: 1, wantSets: 0},
+		{name: "no-store + no-cache", apiKey: "opskey", cacheControl: "no-cache, no-store", wantCalls: 1, wantSets: 0},
+		{name: "no-cache anonymous", cacheControl: "no-cache", wantCalls: 0, wantSets: 0},
+		{name: "no-store without permission", apiKey: "partnerkey", cacheControl: "no-store", wantCalls: 0, wantSets: 0},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			srv, c, p := newBypassTestServer(t)
+			// warm the cache
+			if res := doConvert(srv, "", "", ""); res.Code != http.StatusOK {
+				t.Fatalf("warmup status %d", res.Code)
+			}
+			p.calls, c.sets = 0, 0
+
+			if res := doConvert(srv, tc.query, tc.apiKey, tc.cacheControl); res.Code != http.StatusOK {
+				t.Fatalf("status %d", res.Code)
+			}
+			if p.calls != tc.wantCalls {
+				t.Fatalf("expected %d provider calls, got %d", tc.wantCalls, p.calls)
+			}
+			if c.sets != tc.wantSets {
+				t.Fatalf("expected %d cache writes, got %d", tc.wantSets, c.sets)
+			}
+		})
+	}
+}
+
+func TestDryRunDoesNotStore(t *testing.T) {
+	srv, c, p := newBypassTestServer(t)
+	doConvert(srv, "&dry_run=true", "", "")
+	doConvert(srv, "&dry_run=true", "", "")
+	if c.sets != 0 || p.calls != 2 {
+		t.Fatalf("expected no writes and two provider calls, got sets=%d calls=%d", c.sets, p.calls)
+	}
+}
+
+func TestCacheBypassRecordedPerKey(t *testing.T) {
+	srv, _, _ := newBypassTestServer(t)
+	doConvert(srv, "", "opskey", "no-cache")
+	doConvert(srv, "", "opskey", "no-store")
+	doConvert(srv, "", "opskey", "no-store")
+	doConvert(srv, "", "partnerkey", "no-store") // not permitted: not recorded
+
+	snap := srv.stats.snapshot()
+	if snap.Bypass["ops"]["no-cache"] != 1 || snap.Bypass["ops"]["no-store"] != 2 {
+		t.Fatalf("unexpected bypass stats: %v", snap.Bypass)
+	}
+	if _, ok := snap.Bypass["partner"]; ok {
+		t.Fatalf("unprivileged key must not be recorded: %v", snap.Bypass)
+	}
+}
+
+func TestUnknownAPIKeyRejected(t *testing.T) {
+	srv, _, p := newBypassTestServer(t)
+	if res := doConvert(srv, "", "nope", ""); res.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401, got %d", res.Code)
+	}
+	if p.calls != 0 {
+		t.Fatalf("provider must not be called for rejected requests")
+	}
+}
@@ -0,0 +1,147 @@
+package server
+
+import (
+	"compress/gzip"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// compressExempt are the routes never gzipped: rate streams must reach the
+// client event by event, and the probes answer a few bytes.
+var compressExempt = map[string]bool{
+	"/stream/rates": true,
+	"/health":       true,
+	"/ready":        true,
+}
+
+var gzipWriters = sync.Pool{New: func() any { return gzip.NewWriter(nil) }}
+
+// acceptsGzip reports whether an Accept-Encoding header allows gzip.
+func acceptsGzip(header string) bool {
+	for part := range strings.SplitSeq(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name != "gzip" && name != "*" {
+			continue
+		}
+		if q, ok := strings.CutPrefix(strings.ReplaceAll(params, " ", ""), "q="); ok {
+			if v, err := strconv.ParseFloat(q, 64); err == nil && v == 0 {
+				continue
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// gzipWriter holds the response back until minSize bytes were written, then
+// either compresses it or, when the handler finishes first, writes it as is.
+type gzipWriter struct {
+	http.ResponseWriter
+	minSize int
+
+	status int
+	buf    []byte
+	// decided is set once the header went out
+	decided    bool
+	compressed bool
+	gz         *gzip.Writer
+	// size counts the uncompressed bytes
+	size int
+}
+
+func (g *gzipWriter) WriteHeader(code int) {
+	if g.status == 0 {
+		g.status = code
+	}
+}
+
+func (g *gzipWriter) Write(b []byte) (int, error) {
+	g.size += len(b)
+	if g.decided {
+		if g.gz != nil {
+			return g.gz.Write(b)
+		}
+		return g.ResponseWriter.Write(b)
+	}
+	g.buf = append(g.buf, b...)
+	if len(g.buf) < g.minSize {
+		return len(b), nil
+	}
+	if err := g.flushBuffer(true); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// flushBuffer sends the header and the buffered body, compressed when
+// compress is set and the handler did not encode the body itself.
+func (g *gzipWriter) flushBuffer(compress bool) error {
+	g.decided = true
+	h := g.Header()
+	if h.Get("Content-Encoding") != "" || strings.HasPrefix(h.Get("Content-Type"), "text/event-stream") {
+		compress = false
+	}
+	if compress {
+		g.compressed = true
+		h.Set("Content-Encoding", "gzip")
+		h.Del("Content-Length")
+		g.gz = gzipWriters.Get().(*gzip.Writer)
+		g.gz.Reset(g.ResponseWriter)
+	}
+	if g.status != 0 {
+		g.ResponseWriter.WriteHeader(g.status)
+	}
+	if len(g.buf) == 0 {
+		return nil
+	}
+	var err error
+	if g.gz != nil {
+		_, err = g.gz.Write(g.buf)
+	} else {
+		_, err = g.ResponseWriter.Write(g.buf)
+	}
+	g.buf = nil
+	return err
+}
+
+// close finishes the response: a body still under minSize goes out
+// uncompressed.
+func (g *gzipWriter) close() {
+	if !g.decided {
+		_ = g.flushBuffer(false)
+		return
+	}
+	if g.gz != nil {
+		_ = g.gz.Close()
+		g.gz.Reset(nil)
+		gzipWriters.Put(g.gz)
+		g.gz = nil
+	}
+}
+
+// compress gzips the responses of the route registered as pattern that
+// reach GZIP_MIN_SIZE bytes when the client accepts it. The access log keeps
+// the compressed size and reports the original as uncompressed_size.
+// A handler panic skips close, so a body still held back is dropped and
+// recoverHandler, which wraps this middleware, can answer 500 instead.
+func (s *Server) compress(pattern string, next http.HandlerFunc) http.HandlerFunc {
+	if s.cfg.GzipMinSize <= 0 || compressExempt[pattern] {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		if !acceptsGzip(r.Header.Get("Accept-Encoding")) {
+			next(w, r)
+			return
+		}
+		g := &gzipWriter{ResponseWriter: w, minSize: s.cfg.GzipMinSize}
+		next(g, r)
+		g.close()
+		if rw, ok := w.(*respWriter); ok && g.compressed {
+			rw.uncompressed = g.size
+		}
+	}
+}
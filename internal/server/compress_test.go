@@ -0,0 +1,144 @@
+package server
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/thiagozs/go-exchange/internal/config"
+	"github.com/thiagozs/go-exchange/internal/logger"
+)
+
+func TestAcceptsGzip(t *testing.T) {
+	cases := map[string]bool{
+		"":                      false,
+		"gzip":                  true,
+		"deflate, gzip;q=0.8":   true,
+		"GZIP":                  true,
+		"br, *":                 true,
+		"gzip;q=0":              false,
+		"gzip; q=0, deflate":    false,
+		"identity":              false,
+		"x-gzip-custom, br;q=1": false,
+	}
+	for header, want := range cases {
+		if got := acceptsGzip(header); got != want {
+			t.Errorf("acceptsGzip(%q) = %t, want %t", header, got, want)
+		}
+	}
+}
+
+func TestGzipBatchDecodesToSameJSON(t *testing.T) {
+	var logs bytes.Buffer
+	lg := logger.New(logger.Options{Format: "json", Level: "info", Out: &logs})
+	srv := newTestServer(t, &config.Config{HTTPAddr: ":0", CacheTTL: time.Minute, GzipMinSize: 512}, lg)
+	// no conversion cache, so both requests answer the same "miss" body
+	useDeps(srv, &mockProv{}, &stubCache{}, nil)
+	h := srv.instrumentHandler(srv.compress("/convert/batch", srv.handleConvertBatch))
+
+	var items []string
+	for i := range 40 {
+		items = append(items, fmt.Sprintf(`{"from":"USD","to":"BRL","amount":%d}`, 1000+i))
+	}
+	body := `{"items":[` + strings.Join(items, ",") + `]}`
+	post := func(acceptEncoding string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/convert/batch", strings.NewReader(body))
+		if acceptEncoding != "" {
+			req.Header.Set("Accept-Encoding", acceptEncoding)
+		}
+		w := httptest.NewRecorder()
+		h(w, req)
+		return w
+	}
+
+	plain := post("")
+	if plain.Code != http.StatusOK || plain.Header().Get("Content-Encoding") != "" {
+		t.Fatalf("expected an uncompressed 200, got %d %v", plain.Code, plain.Header())
+	}
+	zipped := post("gzip, deflate")
+	if zipped.Code != http.StatusOK || zipped.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("expected a gzipped 200, got %d %v", zipped.Code, zipped.Header())
+	}
+	for _, w := range []*httptest.ResponseRecorder{plain, zipped} {
+		if v := w.Header().Get("Vary"); v != "Accept-Encoding" {
+			t.Fatalf("expected Vary: Accept-Encoding, got %q", v)
+		}
+	}
+	if zipped.Body.Len() >= plain.Body.Len() {
+		t.Fatalf("compressed body (%d bytes) not smaller than the plain one (%d bytes)", zipped.Body.Len(), plain.Body.Len())
+	}
+
+	zr, err := gzip.NewReader(bytes.NewReader(zipped.Body.Bytes()))
+	if err != nil {
+		t.Fatalf("gzip reader: %v", err)
+	}
+	unzipped, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatalf("gunzip: %v", err)
+	}
+	var a, b any
+	if err := json.Unmarshal(plain.Body.Bytes(), &a); err != nil {
+		t.Fatalf("decode plain: %v", err)
+	}
+	if err := json.Unmarshal(unzipped, &b); err != nil {
+		t.Fatalf("decode gunzipped: %v", err)
+	}
+	if !reflect.DeepEqual(a, b) {
+		t.Fatalf("bodies differ:\nplain:   %s\ngzipped: %s", plain.Body, unzipped)
+	}
+
+	// the access log keeps the wire size and the original one
+	lines := jsonLogLines(t, &logs)
+	var access []map[string]any
+	for _, l := range lines {
+		if l["msg"] == "access" {
+			access = append(access, l)
+		}
+	}
+	if len(access) != 2 {
+		t.Fatalf("expected 2 access entries, got %d", len(access))
+	}
+	if _, ok := access[0]["uncompressed_size"]; ok {
+		t.Fatalf("unexpected uncompressed_size on the plain response: %v", access[0])
+	}
+	if access[1]["size"] != float64(zipped.Body.Len()) || access[1]["uncompressed_size"] != float64(len(unzipped)) {
+		t.Fatalf("expected size %d and uncompressed_size %d, got %v", zipped.Body.Len(), len(unzipped), access[1])
+	}
+}
+
+func TestGzipSkipsSmallAndExemptResponses(t *testing.T) {
+	lg := logger.New(logger.Options{Format: "text", Level: "info", Out: &bytes.Buffer{}})
+	srv := newTestServer(t, &config.Config{HTTPAddr: ":0", CacheTTL: time.Minute, GzipMinSize: 512}, lg)
+	useDeps(srv, &mockProv{}, newMemCache(), nil)
+
+	get := func(pattern, target string, h http.HandlerFunc) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", target, nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		w := httptest.NewRecorder()
+		srv.instrumentHandler(srv.compress(pattern, h))(w, req)
+		return w
+	}
+
+	// a single conversion is under GZIP_MIN_SIZE
+	w := get("/convert", "/convert?from=USD&to=BRL&amount=1000", srv.handleConvert)
+	if w.Code != http.StatusOK || w.Header().Get("Content-Encoding") != "" || !json.Valid(w.Body.Bytes()) {
+		t.Fatalf("expected a plain JSON response, got %d %v: %s", w.Code, w.Header(), w.Body)
+	}
+	// error statuses survive the buffering
+	w = get("/convert", "/convert?from=USD", srv.handleConvert)
+	if w.Code != http.StatusBadRequest || decodeError(t, w).Code != errCodeMissingParameters {
+		t.Fatalf("expected 400 %s, got %d: %s", errCodeMissingParameters, w.Code, w.Body)
+	}
+	w = get("/health", "/health", srv.handleHealth)
+	if w.Header().Get("Content-Encoding") != "" || w.Header().Get("Vary") != "" || w.Body.String() != `{"status":"ok"}` {
+		t.Fatalf("expected /health untouched, got %v: %s", w.Header(), w.Body)
+	}
+}
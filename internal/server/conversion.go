@@ -0,0 +1,86 @@
+package server
+
+import (
+	"time"
+
+	"github.com/thiagozs/go-exchange/internal/exchange"
+)
+
+// conversionBody is the /convert response body.
+func conversionBody(c exchange.ConvertResponse) map[string]any {
+	conv, quote, feeAmt := c.Result, c.Fee, c.FeeAmount
+	netCents := c.NetResultCents()
+	out := map[string]any{"from": c.From,
+		"to": c.To, "amount_cents": c.AmountCents, "unit": c.Unit,
+		"result_cents":      conv.ResultCents,
+		"result":            float64(conv.ResultCents) / 100.0,
+		"fee_percent":       quote.Percent,
+		"fee_percent_cents": feeAmt.PercentCents,
+		"fee_fixed_cents":   feeAmt.FixedCents,
+		"fee_amount_cents":  feeAmt.TotalCents,
+		"fee_rounding":      feeAmt.Rounding,
+		"net_result_cents":  netCents,
+		"net_result":        float64(netCents) / 100.0,
+	}
+	if quote.MinCents > 0 {
+		out["fee_min_cents"] = quote.MinCents
+	}
+	if quote.MaxCents > 0 {
+		out["fee_max_cents"] = quote.MaxCents
+	}
+	if feeAmt.Clamped != "" {
+		out["fee_clamped"] = feeAmt.Clamped
+	}
+	if c.FeeUnavailable {
+		out["fee_unavailable"] = true
+	}
+	if c.FeeWaived != "" {
+		out["fee_waived"] = true
+		out["fee_waived_reason"] = c.FeeWaived
+	}
+	if feeTier := quote.Tier; feeTier != nil {
+		tier := map[string]any{"index": feeTier.Index, "from_cents": feeTier.FromCents}
+		if feeTier.Name != "" {
+			tier["name"] = feeTier.Name
+		}
+		if feeTier.Pair != "" {
+			tier["pair"] = feeTier.Pair
+		}
+		out["fee_tier"] = tier
+	}
+	if conv.Rate != 0 {
+		out["rate"] = conv.Rate
+	}
+	if !conv.RateTimestamp.IsZero() {
+		out["rate_timestamp"] = conv.RateTimestamp.UTC().Format(time.RFC3339)
+	}
+	if conv.Source != "" {
+		out["source"] = conv.Source
+	}
+	out["cache"] = cacheStatus(c.CacheHit)
+	if c.FromCache {
+		if !c.FetchedAt.IsZero() {
+			out["rate_age_seconds"] = int64(c.RateAge / time.Second)
+		}
+		if c.CacheExpiresIn > 0 {
+			out["cache_expires_in_seconds"] = int64(c.CacheExpiresIn / time.Second)
+		}
+	}
+	if conv.Stale {
+		out["stale"] = true
+	}
+	if conv.RateSide != "" {
+		out["rate_side"] = conv.RateSide
+	}
+	if conv.Bulletin != "" {
+		out["bulletin"] = conv.Bulletin
+	}
+	if len(conv.Sources) > 0 {
+		out["sources"] = conv.Sources
+		out["rate_spread"] = conv.Spread
+	}
+	if conv.Derived {
+		out["derived"] = true
+	}
+	return out
+}
@@ -0,0 +1,48 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/thiagozs/go-exchange/internal/exchange"
+)
+
+// ConvertError is a failed Convert with the status, code and message /convert
+// would answer with.
+type ConvertError struct {
+	Status  int
+	Code    string
+	Message string
+	// Input reports a rejected argument rather than a provider or fee
+	// failure.
+	Input bool
+	Err   error
+}
+
+func (e *ConvertError) Error() string { return e.Message }
+
+func (e *ConvertError) Unwrap() error { return e.Err }
+
+// Convert performs one conversion outside of HTTP, as GET /convert does for
+// an anonymous caller, and returns the same JSON body. amount is read in unit
+// (cents or major), or as cents or decimal units by its format when unit is
+// empty. Failures are *ConvertError.
+func (s *Server) Convert(ctx context.Context, from, to, amount, unit string) ([]byte, error) {
+	c, err := s.svc.Convert(ctx, exchange.ConvertRequest{From: from, To: to, Amount: amount, Unit: unit})
+	if err != nil {
+		var cerr *exchange.Error
+		if !errors.As(err, &cerr) {
+			return nil, err
+		}
+		return nil, &ConvertError{Status: convertErrorStatus(cerr), Code: cerr.Code, Message: cerr.Message,
+			Input: cerr.Kind == exchange.KindInvalid, Err: cerr.Err}
+	}
+	b, _ := json.Marshal(conversionBody(c))
+	return b, nil
+}
+
+// Exchange returns the conversion service behind /convert, for the gRPC API.
+func (s *Server) Exchange() *exchange.Service {
+	return s.svc
+}
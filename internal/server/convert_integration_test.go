@@ -1,14 +1,23 @@
 package server
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/thiagozs/go-exchange/internal/config"
 	"github.com/thiagozs/go-exchange/internal/logger"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type httpMockProv struct{ url string }
@@ -41,12 +50,11 @@ func TestConvertIntegration(t *testing.T) {
 
 	cfg := &config.Config{HTTPAddr: ":0", RedisAddr: "", RedisDB: 0, CacheTTL: 0}
 	lg := logger.New(logger.Options{Format: "text", Level: "debug"})
-	srv := New(cfg, lg)
+	srv := newTestServer(t, cfg, lg)
 
 	// replace provider with a mock implementation that calls the mock server
-	srv.prov = &httpMockProv{url: mock.URL}
-	// use stub cache to avoid attempting Redis in tests
-	srv.cache = &stubCache{}
+	// and use a stub cache to avoid attempting Redis in tests
+	useDeps(srv, &httpMockProv{url: mock.URL}, &stubCache{}, nil)
 
 	// perform request
 	req := httptest.NewRequest("GET", "/convert?from=USD&to=BRL&amount=10.00", nil)
@@ -64,3 +72,151 @@ func TestConvertIntegration(t *testing.T) {
 		t.Fatalf("expected 42.42 got %v", out["result"])
 	}
 }
+
+// TestConvertIntegrationBCB selects the BCB provider through config and
+// converts against a PTAX stub.
+func TestConvertIntegrationBCB(t *testing.T) {
+	var paths []string
+	ptax := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"value":[{"cotacaoCompra":5.3,"cotacaoVenda":5.4,"dataHoraCotacao":"2025-09-19 13:09:27.04"}]}`))
+	}))
+	defer ptax.Close()
+
+	cfg := &config.Config{HTTPAddr: ":0", Provider: "ptax", BCBAPIBaseURL: ptax.URL, BCBTimeout: time.Second,
+		// the stub is plain http on loopback
+		OutboundAllowHTTP: true, OutboundAllowPrivate: true}
+	lg := logger.New(logger.Options{Format: "text", Level: "info", Out: &bytes.Buffer{}})
+	srv := newTestServer(t, cfg, lg)
+	useDeps(srv, nil, &stubCache{}, nil)
+
+	w := httptest.NewRecorder()
+	srv.handleConvert(w, httptest.NewRequest("GET", "/convert?from=USD&to=BRL&amount=10.00", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 got %d: %s", w.Code, w.Body.String())
+	}
+	var out map[string]any
+	if err := json.NewDecoder(w.Body).Decode(&out); err != nil {
+		t.Fatalf("decode err: %v", err)
+	}
+	if out["result_cents"] != 5400.0 || out["source"] != "bcb" || out["rate_timestamp"] != "2025-09-19T16:09:27Z" {
+		t.Fatalf("unexpected response %v", out)
+	}
+	if len(paths) != 1 || !strings.Contains(paths[0], "CotacaoDolarPeriodo") {
+		t.Fatalf("expected one PTAX dollar request, got %v", paths)
+	}
+}
+
+// TestConvertTracesUpstreamCalls checks that provider and fee API calls get
+// client spans under the handler span, and that only the fee API, which is
+// ours, receives the trace context.
+func TestConvertTracesUpstreamCalls(t *testing.T) {
+	exp := tracetest.NewInMemoryExporter()
+	useProviders(t, sdktrace.NewTracerProvider(sdktrace.WithSyncer(exp)), sdkmetric.NewMeterProvider())
+	prevProp := otel.GetTextMapPropagator()
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() { otel.SetTextMapPropagator(prevProp) })
+
+	var ptaxTrace, feeTrace string
+	ptax := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ptaxTrace = r.Header.Get("traceparent")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"value":[{"cotacaoCompra":5.3,"cotacaoVenda":5.4,"dataHoraCotacao":"2025-09-19 13:09:27.04"}]}`))
+	}))
+	defer ptax.Close()
+	feeAPI := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		feeTrace = r.Header.Get("traceparent")
+		w.Write([]byte(`{"percent":0.01}`))
+	}))
+	defer feeAPI.Close()
+
+	cfg := &config.Config{HTTPAddr: ":0", Provider: "ptax", BCBAPIBaseURL: ptax.URL, BCBTimeout: time.Second,
+		FeeAPIURL: feeAPI.URL, FeeAPITimeout: time.Second, OutboundAllowHTTP: true, OutboundAllowPrivate: true}
+	lg := logger.New(logger.Options{Format: "text", Level: "info", Out: &bytes.Buffer{}})
+	srv := newTestServer(t, cfg, lg)
+	useDeps(srv, nil, &stubCache{}, nil)
+
+	w := httptest.NewRecorder()
+	srv.mux.ServeHTTP(w, httptest.NewRequest("GET", "/convert?from=USD&to=BRL&amount=10.00", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 got %d: %s", w.Code, w.Body.String())
+	}
+
+	spans := exp.GetSpans()
+	byID := map[trace.SpanID]tracetest.SpanStub{}
+	var handler tracetest.SpanStub
+	for _, s := range spans {
+		byID[s.SpanContext.SpanID()] = s
+		if s.Name == "/convert" {
+			handler = s
+		}
+	}
+	if !handler.SpanContext.IsValid() {
+		t.Fatalf("handler span not found in %d spans", len(spans))
+	}
+	// descends reports whether s is below the handler span
+	descends := func(s tracetest.SpanStub) bool {
+		for s.Parent.IsValid() {
+			if s.Parent.SpanID() == handler.SpanContext.SpanID() {
+				return true
+			}
+			s = byID[s.Parent.SpanID()]
+		}
+		return false
+	}
+	clients := map[string]tracetest.SpanStub{}
+	for _, s := range spans {
+		if s.SpanKind == trace.SpanKindClient {
+			clients[s.Name] = s
+		}
+	}
+	for _, upstream := range []*httptest.Server{ptax, feeAPI} {
+		name := "GET " + strings.TrimPrefix(upstream.URL, "http://")
+		s, ok := clients[name]
+		if !ok || !descends(s) {
+			t.Fatalf("expected a client span %q under the handler span, got %v", name, clients)
+		}
+	}
+	if ptaxTrace != "" {
+		t.Fatalf("expected no trace context sent to the third-party provider, got %q", ptaxTrace)
+	}
+	if !strings.Contains(feeTrace, handler.SpanContext.TraceID().String()) {
+		t.Fatalf("expected the fee API to receive the conversion trace, got %q", feeTrace)
+	}
+}
+
+// TestConvertSendsProviderHeaders checks that the provider and the fee API
+// receive PROVIDER_USER_AGENT and PROVIDER_EXTRA_HEADERS.
+func TestConvertSendsProviderHeaders(t *testing.T) {
+	var ptaxHeader, feeHeader http.Header
+	ptax := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ptaxHeader = r.Header.Clone()
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"value":[{"cotacaoCompra":5.3,"cotacaoVenda":5.4,"dataHoraCotacao":"2025-09-19 13:09:27.04"}]}`))
+	}))
+	defer ptax.Close()
+	feeAPI := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		feeHeader = r.Header.Clone()
+		w.Write([]byte(`{"percent":0.01}`))
+	}))
+	defer feeAPI.Close()
+
+	cfg := &config.Config{HTTPAddr: ":0", Provider: "ptax", BCBAPIBaseURL: ptax.URL, BCBTimeout: time.Second,
+		FeeAPIURL: feeAPI.URL, FeeAPITimeout: time.Second, OutboundAllowHTTP: true, OutboundAllowPrivate: true,
+		ProviderUserAgent: "go-exchange/test", ProviderExtraHeaders: "X-Proxy-Token=egress1"}
+	lg := logger.New(logger.Options{Format: "text", Level: "info", Out: &bytes.Buffer{}})
+	srv := newTestServer(t, cfg, lg)
+	useDeps(srv, nil, &stubCache{}, nil)
+
+	w := httptest.NewRecorder()
+	srv.handleConvert(w, httptest.NewRequest("GET", "/convert?from=USD&to=BRL&amount=10.00", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 got %d: %s", w.Code, w.Body.String())
+	}
+	for name, h := range map[string]http.Header{"provider": ptaxHeader, "fee API": feeHeader} {
+		if h.Get("User-Agent") != "go-exchange/test" || h.Get("X-Proxy-Token") != "egress1" {
+			t.Fatalf("expected the %s to receive the configured headers, got %v", name, h)
+		}
+	}
+}
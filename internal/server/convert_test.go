@@ -0,0 +1,46 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/thiagozs/go-exchange/internal/config"
+	"github.com/thiagozs/go-exchange/internal/exchange"
+	"github.com/thiagozs/go-exchange/internal/logger"
+	"github.com/thiagozs/go-exchange/internal/provider"
+)
+
+func TestServerConvert(t *testing.T) {
+	lg := logger.New(logger.Options{Format: "text", Level: "info", Out: &bytes.Buffer{}})
+	srv := newTestServer(t, &config.Config{HTTPAddr: ":0", CacheBackend: config.CacheBackendMemory, FeePercent: 0.01}, lg)
+	useDeps(srv, &mockProv{}, nil, nil)
+
+	b, err := srv.Convert(context.Background(), "USD", "BRL", "10.00", "")
+	if err != nil {
+		t.Fatalf("convert: %v", err)
+	}
+	var out map[string]any
+	if err := json.Unmarshal(b, &out); err != nil {
+		t.Fatalf("decode: %v (%s)", err, b)
+	}
+	if out["result_cents"] != float64(20000) || out["fee_amount_cents"] != float64(200) || out["net_result_cents"] != float64(19800) {
+		t.Fatalf("unexpected conversion %v", out)
+	}
+
+	var cerr *ConvertError
+	if _, err := srv.Convert(context.Background(), "USD", "BRL", "-1", ""); !errors.As(err, &cerr) || !cerr.Input ||
+		cerr.Status != http.StatusBadRequest || cerr.Code != "INVALID_AMOUNT" {
+		t.Fatalf("expected an input error, got %#v", err)
+	}
+
+	useDeps(srv, &failingProv{err: fmt.Errorf("%w: XYZ", provider.ErrCurrencyNotSupported)}, nil, nil)
+	if _, err := srv.Convert(context.Background(), "USD", "XYZ", "1000", ""); !errors.As(err, &cerr) || cerr.Input ||
+		cerr.Code != exchange.CodeCurrencyNotSupported || !errors.Is(err, provider.ErrCurrencyNotSupported) {
+		t.Fatalf("expected a provider error, got %#v", err)
+	}
+}
@@ -0,0 +1,102 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/thiagozs/go-exchange/internal/currency"
+	"github.com/thiagozs/go-exchange/internal/exchange"
+	"github.com/thiagozs/go-exchange/internal/provider"
+)
+
+// defaultCurrencyBase is the base checked by /currencies when none is given.
+const defaultCurrencyBase = "USD"
+
+// currenciesTTL is how long the provider's currency list is cached.
+const currenciesTTL = time.Hour
+
+// currenciesParams are the query parameters of handleCurrencies.
+var currenciesParams = queryParams{"base"}
+
+// handleCurrencies lists the ISO 4217 currencies the service can convert.
+// When the provider can list the codes it serves, the table is narrowed to
+// those available for ?base= (USD by default).
+func (s *Server) handleCurrencies(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	base := r.URL.Query().Get("base")
+	if base == "" {
+		base = defaultCurrencyBase
+	}
+	if err := exchange.ValidateCurrency("base", base); err != nil {
+		writeValidationError(w, err)
+		return
+	}
+	base = strings.ToUpper(base)
+
+	lister, ok := provider.As[provider.CurrencyLister](s.prov)
+	if !ok {
+		writeJSON(w, http.StatusOK, currency.All())
+		return
+	}
+	codes, err := s.providerCurrencies(ctx, lister, base)
+	if err != nil {
+		s.writeCurrenciesError(ctx, w, base, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, intersectCurrencies(currency.All(), codes))
+}
+
+// providerCurrencies returns the codes the provider serves for base, cached
+// for currenciesTTL.
+func (s *Server) providerCurrencies(ctx context.Context, lister provider.CurrencyLister, base string) ([]string, error) {
+	val, err := s.cache.GetOrSet(ctx, "currencies:"+base, currenciesTTL, func(ctx context.Context) (string, error) {
+		codes, err := lister.Currencies(ctx, base)
+		if err != nil {
+			return "", err
+		}
+		b, err := json.Marshal(codes)
+		return string(b), err
+	})
+	if err != nil {
+		return nil, err
+	}
+	var codes []string
+	if err := json.Unmarshal([]byte(val), &codes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// intersectCurrencies keeps the currencies in table whose code is in codes,
+// preserving the table order.
+func intersectCurrencies(table []currency.Currency, codes []string) []currency.Currency {
+	served := make(map[string]bool, len(codes))
+	for _, code := range codes {
+		served[strings.ToUpper(code)] = true
+	}
+	out := make([]currency.Currency, 0, len(codes))
+	for _, c := range table {
+		if served[c.Code] {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// writeCurrenciesError logs a failed currency listing and writes its error
+// response.
+func (s *Server) writeCurrenciesError(ctx context.Context, w http.ResponseWriter, base string, err error) {
+	if errors.Is(err, provider.ErrCurrencyNotSupported) {
+		s.log.WithContext(ctx).Warnf("currencies for %s rejected: %v", base, err)
+		writeError(w, http.StatusBadRequest, exchange.CodeCurrencyNotSupported,
+			"base currency "+base+" is not supported by the provider", nil)
+		return
+	}
+	cerr := exchange.Classify(base, base, err)
+	s.log.ErrorCtx(ctx, err, "currencies failed", map[string]any{"base": base, "code": cerr.Code})
+	writeError(w, convertErrorStatus(cerr), cerr.Code, cerr.Message, nil)
+}
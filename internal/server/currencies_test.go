@@ -0,0 +1,130 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/thiagozs/go-exchange/internal/config"
+	"github.com/thiagozs/go-exchange/internal/currency"
+	"github.com/thiagozs/go-exchange/internal/exchange"
+	"github.com/thiagozs/go-exchange/internal/logger"
+	"github.com/thiagozs/go-exchange/internal/provider"
+)
+
+// listingProv serves a fixed set of codes per base and counts listings.
+type listingProv struct {
+	mockProv
+	mu    sync.Mutex
+	calls int
+	codes map[string][]string
+}
+
+func (p *listingProv) Currencies(ctx context.Context, base string) ([]string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.calls++
+	codes, ok := p.codes[base]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", provider.ErrCurrencyNotSupported, base)
+	}
+	return codes, nil
+}
+
+func newCurrenciesTestServer(t *testing.T) *Server {
+	t.Helper()
+	lg := logger.New(logger.Options{Format: "text", Level: "info", Out: &bytes.Buffer{}})
+	srv := newTestServer(t, &config.Config{HTTPAddr: ":0"}, lg)
+	useDeps(srv, nil, newMemCache(), nil)
+	return srv
+}
+
+func getCurrencies(t *testing.T, srv *Server, query string) ([]currency.Currency, *httptest.ResponseRecorder) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	srv.handleCurrencies(w, httptest.NewRequest("GET", "/currencies"+query, nil))
+	if w.Code != http.StatusOK {
+		return nil, w
+	}
+	var out []currency.Currency
+	if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil {
+		t.Fatalf("decode: %v (%s)", err, w.Body.String())
+	}
+	return out, w
+}
+
+func TestCurrenciesIntersectsProviderCodes(t *testing.T) {
+	srv := newCurrenciesTestServer(t)
+	p := &listingProv{codes: map[string][]string{
+		"USD": {"USD", "BRL", "JPY", "BTC"},
+		"EUR": {"EUR", "kwd"},
+	}}
+	useDeps(srv, p, nil, nil)
+
+	for range 2 {
+		got, w := getCurrencies(t, srv, "")
+		if got == nil {
+			t.Fatalf("status %d: %s", w.Code, w.Body.String())
+		}
+		want := []currency.Currency{
+			{Code: "BRL", Name: "Brazilian Real", MinorUnits: 2},
+			{Code: "JPY", Name: "Yen", MinorUnits: 0},
+			{Code: "USD", Name: "US Dollar", MinorUnits: 2},
+		}
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+	}
+	if p.calls != 1 {
+		t.Fatalf("expected the provider list to be cached, got %d calls", p.calls)
+	}
+
+	got, _ := getCurrencies(t, srv, "?base=eur")
+	if len(got) != 2 || got[0].Code != "EUR" || got[1].Code != "KWD" || got[1].MinorUnits != 3 {
+		t.Fatalf("unexpected EUR currencies: %v", got)
+	}
+	if p.calls != 2 {
+		t.Fatalf("expected one listing per base, got %d calls", p.calls)
+	}
+}
+
+func TestCurrenciesFallsBackToISOTable(t *testing.T) {
+	srv := newCurrenciesTestServer(t)
+	useDeps(srv, &mockProv{}, nil, nil)
+
+	got, w := getCurrencies(t, srv, "?base=EUR")
+	if got == nil {
+		t.Fatalf("status %d: %s", w.Code, w.Body.String())
+	}
+	if len(got) != len(currency.All()) {
+		t.Fatalf("expected the full ISO table, got %d currencies", len(got))
+	}
+}
+
+func TestCurrenciesRejectsBase(t *testing.T) {
+	srv := newCurrenciesTestServer(t)
+	useDeps(srv, &listingProv{codes: map[string][]string{"USD": {"USD"}}}, nil, nil)
+
+	cases := []struct {
+		query  string
+		status int
+		code   string
+	}{
+		{"?base=US", http.StatusBadRequest, "INVALID_CURRENCY"},
+		{"?base=GBP", http.StatusBadRequest, exchange.CodeCurrencyNotSupported},
+	}
+	for _, tc := range cases {
+		_, w := getCurrencies(t, srv, tc.query)
+		if w.Code != tc.status {
+			t.Fatalf("%s: expected %d, got %d: %s", tc.query, tc.status, w.Code, w.Body.String())
+		}
+		if e := decodeError(t, w); e.Code != tc.code {
+			t.Fatalf("%s: expected code %s, got %s", tc.query, tc.code, e.Code)
+		}
+	}
+}
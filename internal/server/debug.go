@@ -0,0 +1,63 @@
+package server
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"time"
+)
+
+// debugHandler serves the net/http/pprof profiles under /debug/pprof/ and
+// runtime stats at /debug/vars. It is only mounted on the DEBUG_ADDR
+// listener, never on the public mux.
+func (s *Server) debugHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.HandleFunc("/debug/vars", s.handleDebugVars)
+	return mux
+}
+
+// handleDebugVars reports goroutines, heap, GC pauses and uptime.
+func (s *Server) handleDebugVars(w http.ResponseWriter, r *http.Request) {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	lastPause := time.Duration(0)
+	if m.NumGC > 0 {
+		lastPause = time.Duration(m.PauseNs[(m.NumGC+255)%256])
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"goroutines":        runtime.NumGoroutine(),
+		"heap_alloc_bytes":  m.HeapAlloc,
+		"heap_sys_bytes":    m.HeapSys,
+		"heap_objects":      m.HeapObjects,
+		"gc_count":          m.NumGC,
+		"gc_pause_total_ms": float64(m.PauseTotalNs) / 1e6,
+		"gc_pause_last_ms":  float64(lastPause) / 1e6,
+		"uptime_seconds":    time.Since(s.started).Seconds(),
+		"go_version":        runtime.Version(),
+		"gomaxprocs":        runtime.GOMAXPROCS(0),
+	})
+}
+
+// startDebug serves the debug endpoints on DEBUG_ADDR until the returned
+// server is closed. Its Addr is the address actually bound.
+func (s *Server) startDebug() (*http.Server, error) {
+	ln, err := net.Listen("tcp", s.cfg.DebugAddr)
+	if err != nil {
+		return nil, err
+	}
+	srv := &http.Server{Addr: ln.Addr().String(), Handler: s.debugHandler()}
+	go func() {
+		s.log.WithContext(context.Background()).Infof("debug endpoints listening on %s", srv.Addr)
+		if err := srv.Serve(ln); err != nil && err != http.ErrServerClosed {
+			s.log.WithContext(context.Background()).Errorf("debug server error: %v", err)
+		}
+	}()
+	return srv, nil
+}
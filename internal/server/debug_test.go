@@ -0,0 +1,73 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/thiagozs/go-exchange/internal/config"
+	"github.com/thiagozs/go-exchange/internal/logger"
+)
+
+func TestDebugEndpointsStayOffThePublicMux(t *testing.T) {
+	lg := logger.New(logger.Options{Format: "text", Level: "info", Out: &bytes.Buffer{}})
+	srv := newTestServer(t, &config.Config{HTTPAddr: ":0", DebugEndpoints: true}, lg)
+	for _, target := range []string{"/debug/pprof/", "/debug/pprof/goroutine", "/debug/vars"} {
+		w := httptest.NewRecorder()
+		srv.mux.ServeHTTP(w, httptest.NewRequest("GET", target, nil))
+		if w.Code != http.StatusNotFound || decodeError(t, w).Code != errCodeNotFound {
+			t.Fatalf("%s: expected 404 on the public mux, got %d", target, w.Code)
+		}
+	}
+}
+
+func TestDebugListenerServesProfilesAndVars(t *testing.T) {
+	lg := logger.New(logger.Options{Format: "text", Level: "info", Out: &bytes.Buffer{}})
+	srv := newTestServer(t, &config.Config{HTTPAddr: ":0", DebugEndpoints: true, DebugAddr: "127.0.0.1:0"}, lg)
+	dbg, err := srv.startDebug()
+	if err != nil {
+		t.Fatalf("start debug listener: %v", err)
+	}
+	base := "http://" + dbg.Addr
+
+	get := func(path string) (int, []byte) {
+		t.Helper()
+		resp, err := http.Get(base + path)
+		if err != nil {
+			t.Fatalf("GET %s: %v", path, err)
+		}
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, b
+	}
+
+	if code, body := get("/debug/pprof/goroutine?debug=1"); code != http.StatusOK || !strings.Contains(string(body), "goroutine profile") {
+		t.Fatalf("expected a goroutine profile, got %d: %.200s", code, body)
+	}
+	code, body := get("/debug/vars")
+	var vars map[string]any
+	if code != http.StatusOK || json.Unmarshal(body, &vars) != nil {
+		t.Fatalf("expected JSON vars, got %d: %s", code, body)
+	}
+	for _, k := range []string{"goroutines", "heap_alloc_bytes", "gc_count", "gc_pause_total_ms", "uptime_seconds"} {
+		if _, ok := vars[k]; !ok {
+			t.Fatalf("missing %s in %v", k, vars)
+		}
+	}
+	if vars["goroutines"].(float64) < 1 {
+		t.Fatalf("unexpected goroutines %v", vars["goroutines"])
+	}
+
+	// the public routes are not on the debug listener, and closing it stops it
+	if code, _ := get("/convert"); code != http.StatusNotFound {
+		t.Fatalf("expected 404 for /convert on the debug listener, got %d", code)
+	}
+	dbg.Close()
+	if _, err := http.Get(base + "/debug/vars"); err == nil {
+		t.Fatalf("expected the debug listener to be closed")
+	}
+}
@@ -0,0 +1,86 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strings"
+
+	"github.com/thiagozs/go-exchange/internal/exchange"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Machine-readable codes for error responses, next to the conversion codes
+// of package exchange. Clients should branch on these rather than on
+// messages.
+const (
+	errCodeMissingParameters   = "MISSING_PARAMETERS"
+	errCodeUnauthorized        = "UNAUTHORIZED"
+	errCodeForbidden           = "FORBIDDEN"
+	errCodeInvalidAPIKey       = "INVALID_API_KEY"
+	errCodeInvalidParameter    = "INVALID_PARAMETER"
+	errCodeUnknownParameters   = "UNKNOWN_PARAMETERS"
+	errCodeMethodNotAllowed    = "METHOD_NOT_ALLOWED"
+	errCodeNotFound            = "NOT_FOUND"
+	errCodeInvalidProvider     = "INVALID_PROVIDER"
+	errCodeInvalidJSON         = "INVALID_JSON"
+	errCodeInvalidBatch        = "INVALID_BATCH"
+	errCodeInvalidBatchItems   = "INVALID_BATCH_ITEMS"
+	errCodeCacheFlushFailed    = "CACHE_FLUSH_FAILED"
+	errCodeTooManyStreams      = "TOO_MANY_STREAMS"
+	errCodeOverloaded          = "OVERLOADED"
+	errCodeIdempotencyConflict = "IDEMPOTENCY_KEY_CONFLICT"
+	errCodeInternal            = "INTERNAL_ERROR"
+)
+
+// requestIDHeader carries the request id set by instrumentHandler; error
+// responses repeat it so clients can quote it when reporting problems.
+const requestIDHeader = "X-Request-ID"
+
+// errorBody is the error object of every error response:
+//
+//	{"error":{"code":"CURRENCY_NOT_SUPPORTED","message":"...","request_id":"..."}}
+type errorBody struct {
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	RequestID string `json:"request_id,omitempty"`
+	Details   any    `json:"details,omitempty"`
+}
+
+// newErrorBody builds an error object for the response being written to w.
+func newErrorBody(w http.ResponseWriter, code, message string, details any) *errorBody {
+	return &errorBody{Code: code, Message: message, RequestID: w.Header().Get(requestIDHeader), Details: details}
+}
+
+// writeError writes a JSON error response, or a text one for txt requests. message is shown to clients, so
+// it must never carry upstream error text; that belongs in the logs.
+func writeError(w http.ResponseWriter, status int, code, message string, details any) {
+	if _, ok := w.(*textErrorWriter); ok {
+		writeText(w, status, code+": "+message+"\n")
+		return
+	}
+	writeJSON(w, status, struct {
+		Error *errorBody `json:"error"`
+	}{newErrorBody(w, code, message, details)})
+}
+
+// writeValidationError writes a rejected input, using its upper-cased
+// validation code.
+func writeValidationError(w http.ResponseWriter, err error) {
+	code, msg := strings.ToUpper(exchange.CodeInvalidAmount), err.Error()
+	if verr, ok := err.(*exchange.ValidationError); ok {
+		code, msg = strings.ToUpper(verr.Code), verr.Message
+	}
+	writeError(w, validationStatus(err), code, msg, nil)
+}
+
+// requestID identifies a request in error responses: the trace id when the
+// request is traced, a random id otherwise.
+func requestID(sc trace.SpanContext) string {
+	if sc.IsValid() {
+		return sc.TraceID().String()
+	}
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
@@ -0,0 +1,104 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/thiagozs/go-exchange/internal/config"
+	"github.com/thiagozs/go-exchange/internal/logger"
+	"github.com/thiagozs/go-exchange/internal/provider"
+)
+
+type failingProv struct{ err error }
+
+func (p *failingProv) Convert(ctx context.Context, from, to string, amount int64) (int64, error) {
+	return 0, p.err
+}
+
+type errorResponse struct {
+	Error errorBody `json:"error"`
+}
+
+func decodeError(t *testing.T, w *httptest.ResponseRecorder) errorBody {
+	t.Helper()
+	if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+		t.Fatalf("expected a JSON error, got Content-Type %q: %s", ct, w.Body.String())
+	}
+	var out errorResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil {
+		t.Fatalf("decode: %v (%s)", err, w.Body.String())
+	}
+	return out.Error
+}
+
+func TestHandleConvertErrorsAreJSON(t *testing.T) {
+	upstream := errors.New(`upstream said {"secret":"s3cr3t"}`)
+	cases := []struct {
+		name   string
+		query  string
+		prov   provider.Provider
+		status int
+		code   string
+	}{
+		{"missing parameters", "from=USD&to=BRL", &mockProv{}, http.StatusBadRequest, "MISSING_PARAMETERS"},
+		{"invalid currency", "from=US&to=BRL&amount=1000", &mockProv{}, http.StatusBadRequest, "INVALID_CURRENCY"},
+		{"invalid amount", "from=USD&to=BRL&amount=abc", &mockProv{}, http.StatusBadRequest, "INVALID_AMOUNT"},
+		{"unsupported currency", "from=USD&to=XYZ&amount=1000",
+			&failingProv{fmt.Errorf("%w: XYZ (%w)", provider.ErrCurrencyNotSupported, upstream)}, http.StatusBadRequest, "CURRENCY_NOT_SUPPORTED"},
+		{"missing provider key", "from=USD&to=BRL&amount=1000",
+			&failingProv{provider.MissingAPIKeyError{}}, http.StatusBadGateway, "PROVIDER_MISSING_API_KEY"},
+		{"provider error", "from=USD&to=BRL&amount=1000", &failingProv{upstream}, http.StatusInternalServerError, "PROVIDER_ERROR"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var logs bytes.Buffer
+			lg := logger.New(logger.Options{Format: "text", Level: "info", Out: &logs})
+			srv := newTestServer(t, &config.Config{HTTPAddr: ":0", CacheTTL: time.Minute}, lg)
+			useDeps(srv, tc.prov, nil, nil)
+			w := httptest.NewRecorder()
+			srv.instrumentHandler(srv.handleConvert)(w, httptest.NewRequest("GET", "/convert?"+tc.query, nil))
+
+			if w.Code != tc.status {
+				t.Fatalf("expected %d, got %d", tc.status, w.Code)
+			}
+			body := decodeError(t, w)
+			if body.Code != tc.code || body.Message == "" {
+				t.Fatalf("expected code %s with a message, got %+v", tc.code, body)
+			}
+			if body.RequestID == "" || body.RequestID != w.Header().Get("X-Request-ID") {
+				t.Fatalf("expected the request id to match X-Request-ID, got %q and %q", body.RequestID, w.Header().Get("X-Request-ID"))
+			}
+			if strings.Contains(w.Body.String(), "s3cr3t") {
+				t.Fatalf("upstream error leaked to the client: %s", w.Body.String())
+			}
+			if fp, ok := tc.prov.(*failingProv); ok && errors.Is(fp.err, upstream) && !strings.Contains(logs.String(), "s3cr3t") {
+				t.Fatalf("expected the upstream error in the logs")
+			}
+		})
+	}
+}
+
+func TestAuthAndAdminErrorsAreJSON(t *testing.T) {
+	srv, _ := newKeyedTestServer(t, &config.Config{AdminEnabled: true}, adminKeys, nil)
+
+	w := doAs(srv, "wrong", http.MethodDelete, "/admin/cache?prefix=convert:")
+	if w.Code != http.StatusUnauthorized || decodeError(t, w).Code != "INVALID_API_KEY" {
+		t.Fatalf("expected INVALID_API_KEY, got %d %s", w.Code, w.Body.String())
+	}
+	w = doAs(srv, "partnerkey", http.MethodDelete, "/admin/cache?prefix=convert:")
+	if w.Code != http.StatusForbidden || decodeError(t, w).Code != "FORBIDDEN" {
+		t.Fatalf("expected FORBIDDEN, got %d %s", w.Code, w.Body.String())
+	}
+	w = doAs(srv, "opskey", http.MethodGet, "/admin/cache?prefix=convert:")
+	if w.Code != http.StatusMethodNotAllowed || decodeError(t, w).Code != "METHOD_NOT_ALLOWED" {
+		t.Fatalf("expected METHOD_NOT_ALLOWED, got %d %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,65 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/thiagozs/go-exchange/internal/exchange"
+)
+
+// conversionETag is a weak ETag of a /convert body rendered in format. The
+// cache fields are left out so a hit validates the response of the miss that
+// stored it, however old the entry.
+func conversionETag(body map[string]any, format string) string {
+	b := make(map[string]any, len(body)+1)
+	for k, v := range body {
+		switch k {
+		case "cache", "rate_age_seconds", "cache_expires_in_seconds":
+		default:
+			b[k] = v
+		}
+	}
+	if format != formatJSON {
+		b["format"] = format
+	}
+	// map keys are marshalled sorted, so equal bodies hash the same
+	raw, _ := json.Marshal(b)
+	sum := sha256.Sum256(raw)
+	return `W/"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// etagMatches reports whether an If-None-Match header matches etag, using
+// the weak comparison RFC 9110 prescribes for it.
+func etagMatches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	want := strings.TrimPrefix(etag, "W/")
+	for tag := range strings.SplitSeq(ifNoneMatch, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == want {
+			return true
+		}
+	}
+	return false
+}
+
+// conversionCacheControl lets clients and CDNs reuse a conversion until its
+// conversion cache entry expires. Results that were not cached must be
+// revalidated, and responses that depend on the caller (fee waivers and fee
+// plans) stay out of shared caches.
+func conversionCacheControl(c exchange.ConvertResponse, now time.Time, private bool) string {
+	scope := "public"
+	if private {
+		scope = "private"
+	}
+	remaining := c.Expires.Sub(now).Round(time.Second)
+	if c.Expires.IsZero() || remaining <= 0 {
+		return scope + ", no-cache"
+	}
+	return scope + ", max-age=" + strconv.Itoa(int(remaining/time.Second))
+}
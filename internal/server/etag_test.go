@@ -0,0 +1,159 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/thiagozs/go-exchange/internal/config"
+	"github.com/thiagozs/go-exchange/internal/exchange"
+	"github.com/thiagozs/go-exchange/internal/logger"
+)
+
+func TestEtagMatches(t *testing.T) {
+	const etag = `W/"abc"`
+	cases := map[string]bool{
+		``:                  false,
+		`W/"abc"`:           true,
+		`"abc"`:             true,
+		`"xyz", W/"abc"`:    true,
+		`*`:                 true,
+		`W/"abcd"`:          false,
+		`W/"xyz" , "other"`: false,
+	}
+	for header, want := range cases {
+		if got := etagMatches(header, etag); got != want {
+			t.Errorf("etagMatches(%q) = %t, want %t", header, got, want)
+		}
+	}
+}
+
+func TestConversionCacheControl(t *testing.T) {
+	now := time.Date(2025, 9, 19, 12, 0, 0, 0, time.UTC)
+	cases := []struct {
+		expires time.Time
+		private bool
+		want    string
+	}{
+		{now.Add(5 * time.Minute), false, "public, max-age=300"},
+		{now.Add(200*time.Second + 300*time.Millisecond), false, "public, max-age=200"},
+		{now.Add(5 * time.Minute), true, "private, max-age=300"},
+		{time.Time{}, false, "public, no-cache"},
+		{now.Add(-time.Second), false, "public, no-cache"},
+	}
+	for _, tc := range cases {
+		if got := conversionCacheControl(exchange.ConvertResponse{Expires: tc.expires}, now, tc.private); got != tc.want {
+			t.Errorf("expires %v private %t: got %q, want %q", tc.expires, tc.private, got, tc.want)
+		}
+	}
+}
+
+func TestConvertETagAndMaxAge(t *testing.T) {
+	lg := logger.New(logger.Options{Format: "text", Level: "info", Out: &bytes.Buffer{}})
+	srv := newTestServer(t, &config.Config{HTTPAddr: ":0", CacheTTL: 5 * time.Minute}, lg)
+	c := newMemCache()
+	useDeps(srv, &mockProv{}, c, nil)
+
+	get := func(target, ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", target, nil)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		w := httptest.NewRecorder()
+		srv.handleConvert(w, req)
+		return w
+	}
+	const target = "/convert?from=USD&to=BRL&amount=1000"
+
+	// a miss may be reused for the whole CACHE_TTL
+	miss := get(target, "")
+	etag := miss.Header().Get("ETag")
+	if miss.Code != http.StatusOK || miss.Header().Get("Cache-Control") != "public, max-age=300" || len(etag) < 5 || etag[:3] != `W/"` {
+		t.Fatalf("unexpected miss: %d %v", miss.Code, miss.Header())
+	}
+
+	// the hit carries the same ETag, so a revalidation gets 304
+	hit := get(target, etag)
+	if hit.Code != http.StatusNotModified || hit.Body.Len() != 0 || hit.Header().Get("ETag") != etag ||
+		hit.Header().Get("X-Cache") != "HIT" {
+		t.Fatalf("expected an empty 304 hit with the same ETag, got %d %v: %s", hit.Code, hit.Header(), hit.Body)
+	}
+	if w := get(target, `W/"other"`); w.Code != http.StatusOK || w.Header().Get("ETag") != etag {
+		t.Fatalf("expected 200 for a stale ETag, got %d", w.Code)
+	}
+
+	// an entry stored 100s ago has 200s left
+	c.m["convert:v1:USD:BRL:1000"] = fmt.Sprintf(`{"result_cents":20000,"stored_at":%q}`,
+		time.Now().Add(-100*time.Second).UTC().Format(time.RFC3339Nano))
+	if w := get(target, ""); w.Header().Get("Cache-Control") != "public, max-age=200" || w.Header().Get("ETag") != etag {
+		t.Fatalf("expected max-age=200 with the same ETag, got %v", w.Header())
+	}
+
+	// a different amount is a different representation
+	if w := get("/convert?from=USD&to=BRL&amount=2000", etag); w.Code != http.StatusOK || w.Header().Get("ETag") == etag {
+		t.Fatalf("expected a new ETag for another amount, got %d %v", w.Code, w.Header())
+	}
+}
+
+func TestConvertCacheControlForWaivedFee(t *testing.T) {
+	srv := newFeeWaiverTestServer(t, "")
+	w := doAs(srv, "reconkey", http.MethodGet, "/convert?from=USD&to=BRL&amount=1000&include_fee=false")
+	if w.Code != http.StatusOK || w.Header().Get("Cache-Control") != "private, max-age=60" {
+		t.Fatalf("expected a private response, got %d %v", w.Code, w.Header())
+	}
+}
+
+func TestConvertReportsCacheFreshness(t *testing.T) {
+	var buf bytes.Buffer
+	lg := logger.New(logger.Options{Format: "json", Level: "info", Out: &buf})
+	srv := newTestServer(t, &config.Config{HTTPAddr: ":0", CacheTTL: 5 * time.Minute}, lg)
+	c := newMemCache()
+	useDeps(srv, &mockProv{}, c, nil)
+	const target = "/convert?from=USD&to=BRL&amount=1000"
+	get := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		srv.mux.ServeHTTP(w, httptest.NewRequest("GET", target, nil))
+		return w
+	}
+
+	miss := get()
+	body := decodeResponse(t, miss)
+	if _, ok := body["rate_age_seconds"]; ok {
+		t.Fatalf("expected no rate age on a miss, got %v", body)
+	}
+	if _, ok := body["cache_expires_in_seconds"]; ok {
+		t.Fatalf("expected no cache expiry on a miss, got %v", body)
+	}
+
+	// an entry stored 100s ago is 100s old with 200s left
+	c.m["convert:v1:USD:BRL:1000"] = fmt.Sprintf(`{"result_cents":20000,"stored_at":%q}`,
+		time.Now().Add(-100*time.Second).UTC().Format(time.RFC3339Nano))
+	buf.Reset()
+	hit := get()
+	body = decodeResponse(t, hit)
+	if age, _ := body["rate_age_seconds"].(float64); age < 100 || age > 101 {
+		t.Fatalf("expected a rate age of 100s, got %v", body["rate_age_seconds"])
+	}
+	if left, _ := body["cache_expires_in_seconds"].(float64); left < 199 || left > 200 {
+		t.Fatalf("expected 200s left in the cache, got %v", body["cache_expires_in_seconds"])
+	}
+	if hit.Header().Get("ETag") != miss.Header().Get("ETag") {
+		t.Fatalf("expected the ages to leave the ETag alone, got %q and %q", hit.Header().Get("ETag"), miss.Header().Get("ETag"))
+	}
+	var logged bool
+	for _, l := range jsonLogLines(t, &buf) {
+		if l["msg"] != "access" {
+			continue
+		}
+		if age, ok := l["cache_age"].(float64); !ok || age < 100 || age > 101 {
+			t.Fatalf("expected a numeric cache_age of 100s, got %v", l["cache_age"])
+		}
+		logged = true
+	}
+	if !logged {
+		t.Fatalf("expected an access log entry, got %s", buf.String())
+	}
+}
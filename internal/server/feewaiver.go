@@ -0,0 +1,44 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/thiagozs/go-exchange/internal/config"
+)
+
+// feeWaiverError rejects an include_fee parameter with the given status.
+type feeWaiverError struct {
+	status int
+	code   string
+	msg    string
+}
+
+func (e *feeWaiverError) Error() string { return e.msg }
+
+// requestFeeWaiver reports whether the request asked for include_fee=false.
+// Only API keys holding the internal permission may skip fees; anyone else
+// gets an error instead of a silently charged fee, since callers relying on
+// the raw conversion would otherwise misread the result.
+func requestFeeWaiver(r *http.Request) (bool, error) {
+	v := r.URL.Query().Get("include_fee")
+	if v == "" {
+		return false, nil
+	}
+	include, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, &feeWaiverError{http.StatusBadRequest, errCodeInvalidParameter, fmt.Sprintf("invalid include_fee %q", v)}
+	}
+	if include {
+		return false, nil
+	}
+	key, ok := apiKeyFromContext(r.Context())
+	if !ok {
+		return false, &feeWaiverError{http.StatusUnauthorized, errCodeUnauthorized, "include_fee=false requires an API key"}
+	}
+	if !key.Has(config.PermInternal) {
+		return false, &feeWaiverError{http.StatusForbidden, errCodeForbidden, "include_fee=false requires an internal API key"}
+	}
+	return true, nil
+}
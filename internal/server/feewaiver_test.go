@@ -0,0 +1,123 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/thiagozs/go-exchange/internal/config"
+	"github.com/thiagozs/go-exchange/internal/logger"
+)
+
+func newFeeWaiverTestServer(t *testing.T, exempt string) *Server {
+	t.Helper()
+	var pairs config.FeePairSet
+	if err := pairs.UnmarshalText([]byte(exempt)); err != nil {
+		t.Fatalf("parse pairs: %v", err)
+	}
+	cfg := &config.Config{FeePercent: 0.01, FeeExemptPairs: pairs}
+	srv, _ := newKeyedTestServer(t, cfg, "recon:reconkey:internal,partner:partnerkey:cache_bypass", &mockProv{}) // 20000 cents gross
+	return srv
+}
+
+func decodeResponse(t *testing.T, w *httptest.ResponseRecorder) map[string]any {
+	t.Helper()
+	var out map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil {
+		t.Fatalf("decode: %v (%d %s)", err, w.Code, w.Body.String())
+	}
+	return out
+}
+
+func TestIncludeFeeRequiresInternalKey(t *testing.T) {
+	srv := newFeeWaiverTestServer(t, "")
+	const target = "/convert?from=USD&to=BRL&amount=1000&include_fee=false"
+
+	if w := doAs(srv, "", http.MethodGet, target); w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without a key, got %d", w.Code)
+	}
+	if w := doAs(srv, "partnerkey", http.MethodGet, target); w.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for a non-internal key, got %d", w.Code)
+	}
+	if w := doAs(srv, "reconkey", http.MethodGet, "/convert?from=USD&to=BRL&amount=1000&include_fee=nope"); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an invalid value, got %d", w.Code)
+	}
+
+	out := decodeResponse(t, doAs(srv, "reconkey", http.MethodGet, target))
+	if out["fee_percent"] != float64(0) || out["fee_amount_cents"] != float64(0) ||
+		out["net_result_cents"] != out["result_cents"] ||
+		out["fee_waived"] != true || out["fee_waived_reason"] != "include_fee=false" {
+		t.Fatalf("expected a waived fee, got %v", out)
+	}
+
+	// the waived response is cached apart from the regular one
+	out = decodeResponse(t, doAs(srv, "partnerkey", http.MethodGet, "/convert?from=USD&to=BRL&amount=1000"))
+	if out["fee_amount_cents"] != float64(200) || out["fee_waived"] != nil {
+		t.Fatalf("expected the regular fee for other callers, got %v", out)
+	}
+
+	// include_fee=true needs no privileges
+	if w := doAs(srv, "", http.MethodGet, "/convert?from=USD&to=BRL&amount=1000&include_fee=true"); w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+}
+
+func TestExemptPairWaivesFee(t *testing.T) {
+	srv := newFeeWaiverTestServer(t, "usd-brl")
+
+	out := decodeResponse(t, doAs(srv, "", http.MethodGet, "/convert?from=USD&to=BRL&amount=1000"))
+	if out["fee_amount_cents"] != float64(0) || out["net_result_cents"] != out["result_cents"] ||
+		out["fee_waived"] != true || out["fee_waived_reason"] != "exempt_pair" {
+		t.Fatalf("expected an exempt pair, got %v", out)
+	}
+	out = decodeResponse(t, doAs(srv, "", http.MethodGet, "/convert?from=BRL&to=USD&amount=1000"))
+	if out["fee_amount_cents"] == float64(0) || out["fee_waived"] != nil {
+		t.Fatalf("expected the reverse direction to pay the fee, got %v", out)
+	}
+}
+
+func TestConvertFeePlans(t *testing.T) {
+	var keys config.APIKeys
+	if err := keys.UnmarshalText([]byte("gold:goldkey::plan=gold,volume:volumekey::plan=volume,plain:plainkey")); err != nil {
+		t.Fatalf("parse keys: %v", err)
+	}
+	var plans config.FeePlans
+	if err := plans.UnmarshalText([]byte(`{"gold":{"fees":{"default":0.005}},` +
+		`"volume":{"tiers":{"tiers":[{"from_cents":0,"percent":0.02},{"from_cents":100000,"percent":0.001}]}}}`)); err != nil {
+		t.Fatalf("parse plans: %v", err)
+	}
+	var buf bytes.Buffer
+	lg := logger.New(logger.Options{Format: "json", Level: "info", Out: &buf})
+	srv := newTestServer(t, &config.Config{HTTPAddr: ":0", CacheTTL: time.Minute, APIKeys: keys,
+		FeePercent: 0.01, FeePlans: plans}, lg)
+	useDeps(srv, &mockProv{}, newMemCache(), nil) // 20000 cents gross
+
+	for key, want := range map[string]float64{"goldkey": 100, "volumekey": 400, "plainkey": 200, "": 200} {
+		req := httptest.NewRequest("GET", "/convert?from=USD&to=BRL&amount=1000", nil)
+		if key != "" {
+			req.Header.Set("X-API-Key", key)
+		}
+		w := httptest.NewRecorder()
+		srv.mux.ServeHTTP(w, req)
+		if out := decodeResponse(t, w); out["fee_amount_cents"] != want {
+			t.Fatalf("key %q: expected a fee of %v cents, got %v", key, want, out["fee_amount_cents"])
+		}
+		if private := strings.HasPrefix(w.Header().Get("Cache-Control"), "private"); private != (key == "goldkey" || key == "volumekey") {
+			t.Fatalf("key %q: unexpected Cache-Control %q", key, w.Header().Get("Cache-Control"))
+		}
+	}
+
+	plansLogged := map[string]string{}
+	for _, l := range jsonLogLines(t, &buf) {
+		if l["msg"] == "access" && l["tenant"] != nil {
+			plansLogged[l["tenant"].(string)] = l["fee_plan"].(string)
+		}
+	}
+	if plansLogged["gold"] != "gold" || plansLogged["volume"] != "volume" || plansLogged["plain"] != "default" || len(plansLogged) != 3 {
+		t.Fatalf("expected the tenants and plans in the access log, got %v", plansLogged)
+	}
+}
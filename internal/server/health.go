@@ -0,0 +1,74 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/thiagozs/go-exchange/internal/fee"
+	"github.com/thiagozs/go-exchange/internal/health"
+	"github.com/thiagozs/go-exchange/internal/provider"
+	"github.com/thiagozs/go-exchange/internal/version"
+)
+
+// verboseHealth is the body of GET /health?verbose=true.
+type verboseHealth struct {
+	health.Report
+	UptimeSeconds float64          `json:"uptime_seconds"`
+	Version       version.Info     `json:"version"`
+	CacheHitRatio hitRatioSnapshot `json:"cache_hit_ratio"`
+}
+
+// healthComponents lists what the verbose health output checks. The
+// provider conversions use is critical; the cache is not, since conversions still reach the
+// provider without it; the fee API is critical only when a fee failure fails
+// conversions (FEE_FAIL_OPEN=false).
+func (s *Server) healthComponents() []health.Component {
+	name, prov := s.svc.Provider()
+	components := []health.Component{
+		{Name: "cache", Checker: checkerOr(s.cache, map[string]any{"backend": s.backend.Cache})},
+		{Name: "provider", Checker: providerChecker(prov, map[string]any{"provider": name}), Critical: true},
+	}
+	fp := s.fee
+	if t, ok := fp.(*fee.TenantFeeProvider); ok {
+		fp = t.Default()
+	}
+	if fc, ok := fp.(health.Checker); ok {
+		components = append(components, health.Component{Name: "fee_api", Checker: fc, Critical: !s.cfg.FeeFailOpen})
+	}
+	return components
+}
+
+// checkerOr is v's own check, or an always ok one reporting details for
+// components that cannot check themselves.
+func checkerOr(v any, details map[string]any) health.Checker {
+	if c, ok := v.(health.Checker); ok {
+		return c
+	}
+	return health.CheckerFunc(func(context.Context) health.Result { return health.OK(details) })
+}
+
+// providerChecker is checkerOr for the first provider of p's decorator chain
+// that checks itself.
+func providerChecker(p provider.Provider, details map[string]any) health.Checker {
+	if c, ok := provider.As[health.Checker](p); ok {
+		return c
+	}
+	return checkerOr(nil, details)
+}
+
+// handleHealthVerbose checks every component, each bounded by
+// HEALTH_CHECK_TIMEOUT, and answers 503 when the service is unhealthy.
+func (s *Server) handleHealthVerbose(w http.ResponseWriter, r *http.Request) {
+	rep := health.Run(r.Context(), s.cfg.HealthCheckTimeout, s.healthComponents())
+	status := http.StatusOK
+	if rep.Status == health.StatusUnhealthy {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, verboseHealth{
+		Report:        rep,
+		UptimeSeconds: time.Since(s.started).Seconds(),
+		Version:       version.Get(s.cfg),
+		CacheHitRatio: s.hits.snapshot(),
+	})
+}
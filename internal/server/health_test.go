@@ -0,0 +1,104 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/thiagozs/go-exchange/internal/config"
+	"github.com/thiagozs/go-exchange/internal/fee"
+	"github.com/thiagozs/go-exchange/internal/health"
+	"github.com/thiagozs/go-exchange/internal/logger"
+)
+
+// checkedProv is mockProv with a health check reporting status.
+type checkedProv struct {
+	mockProv
+	status health.Status
+}
+
+func (p *checkedProv) HealthCheck(ctx context.Context) health.Result {
+	if p.status == health.StatusOK {
+		return health.OK(map[string]any{"provider": "mock"})
+	}
+	return health.Fail(p.status, errors.New("upstream down"))
+}
+
+// checkedFee is a fixed fee with a health check reporting status.
+type checkedFee struct {
+	*fee.EnvFeeProvider
+	status health.Status
+}
+
+func (f *checkedFee) HealthCheck(ctx context.Context) health.Result {
+	if f.status == health.StatusOK {
+		return health.OK(nil)
+	}
+	return health.Fail(f.status, errors.New("fee api down"))
+}
+
+func TestVerboseHealth(t *testing.T) {
+	lg := logger.New(logger.Options{Format: "text", Level: "info", Out: &bytes.Buffer{}})
+	cfg := &config.Config{HTTPAddr: ":0", CacheTTL: time.Minute, FeeFailOpen: true, HealthCheckTimeout: time.Second}
+	srv := newTestServer(t, cfg, lg)
+	prov := &checkedProv{status: health.StatusOK}
+	fp := &checkedFee{EnvFeeProvider: fee.NewEnvFeeProviderWithPercent(0.01), status: health.StatusOK}
+	useDeps(srv, prov, newMemCache(), fp)
+
+	get := func(target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		srv.mux.ServeHTTP(w, httptest.NewRequest("GET", target, nil))
+		return w
+	}
+	verbose := func() (int, verboseHealth) {
+		w := get("/health?verbose=true")
+		var out verboseHealth
+		if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil {
+			t.Fatalf("decode %s: %v", w.Body, err)
+		}
+		return w.Code, out
+	}
+
+	if w := get("/health"); w.Code != http.StatusOK || w.Body.String() != `{"status":"ok"}` {
+		t.Fatalf("expected the plain health answer, got %d: %s", w.Code, w.Body)
+	}
+	for range 2 {
+		get("/convert?from=USD&to=BRL&amount=1000")
+	}
+
+	code, out := verbose()
+	if code != http.StatusOK || out.Status != health.StatusOK || len(out.Components) != 3 {
+		t.Fatalf("expected ok with cache, provider and fee_api, got %d %+v", code, out)
+	}
+	if out.Components["provider"].Details["provider"] != "mock" || out.Components["cache"].Details["backend"] != "memory" {
+		t.Fatalf("unexpected component details %+v", out.Components)
+	}
+	if r := out.CacheHitRatio; r.Hits != 1 || r.Misses != 1 || r.Ratio != 0.5 {
+		t.Fatalf("expected one hit and one miss, got %+v", r)
+	}
+	if out.UptimeSeconds <= 0 || out.Version.App == "" {
+		t.Fatalf("expected uptime and version, got %+v", out)
+	}
+
+	// FEE_FAIL_OPEN: conversions go on without the fee API
+	fp.status = health.StatusUnhealthy
+	if code, out := verbose(); code != http.StatusOK || out.Status != health.StatusDegraded ||
+		out.Components["fee_api"].Error == "" {
+		t.Fatalf("expected degraded with the fee API down, got %d %+v", code, out)
+	}
+	cfg.FeeFailOpen = false
+	if code, out := verbose(); code != http.StatusServiceUnavailable || out.Status != health.StatusUnhealthy {
+		t.Fatalf("expected unhealthy with the fee API down and FEE_FAIL_OPEN=false, got %d %+v", code, out)
+	}
+
+	fp.status = health.StatusOK
+	prov.status = health.StatusUnhealthy
+	if code, out := verbose(); code != http.StatusServiceUnavailable || out.Status != health.StatusUnhealthy {
+		t.Fatalf("expected unhealthy with the provider down, got %d %+v", code, out)
+	}
+}
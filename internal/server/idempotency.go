@@ -0,0 +1,150 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+)
+
+const (
+	idempotencyKeyHeader    = "Idempotency-Key"
+	idempotentReplayHeader  = "Idempotent-Replay"
+	idempotencyKeyMaxLength = 255
+	// idempotencyCachePrefix namespaces stored responses in the cache.
+	idempotencyCachePrefix = "idem:"
+)
+
+// storedResponse is a response kept under an Idempotency-Key, with the
+// fingerprint of the request that produced it.
+type storedResponse struct {
+	Fingerprint string      `json:"fingerprint"`
+	Status      int         `json:"status"`
+	Header      http.Header `json:"header"`
+	Body        []byte      `json:"body"`
+}
+
+// responseRecorder buffers a response so it can be stored before it is
+// written.
+type responseRecorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (r *responseRecorder) Header() http.Header { return r.header }
+
+func (r *responseRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	r.WriteHeader(http.StatusOK)
+	return r.body.Write(b)
+}
+
+// idempotent gives requests carrying an Idempotency-Key exactly-once
+// semantics: the first response is stored under the key for
+// IDEMPOTENCY_TTL and replayed, with Idempotent-Replay: true, to retries of
+// the same request even if rates changed since. Reusing a key for another
+// request is answered with 422. Concurrent retries share the atomic fill of
+// the cache, so the handler runs once per key. Server errors are not stored,
+// leaving the retry free to succeed.
+func (s *Server) idempotent(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(idempotencyKeyHeader)
+		if key == "" || s.cfg.IdempotencyTTL <= 0 || s.cache == nil {
+			next(w, r)
+			return
+		}
+		if len(key) > idempotencyKeyMaxLength {
+			writeError(w, http.StatusBadRequest, errCodeInvalidParameter, "Idempotency-Key must be at most 255 characters", nil)
+			return
+		}
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 1<<20))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, errCodeInvalidJSON, "invalid JSON body", nil)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		fingerprint := requestFingerprint(r, body)
+
+		var fresh *responseRecorder
+		val, err := s.cache.GetOrSet(r.Context(), idempotencyCachePrefix+key, s.cfg.IdempotencyTTL, func(ctx context.Context) (string, error) {
+			fresh = &responseRecorder{header: w.Header().Clone()}
+			next(fresh, r)
+			if fresh.status == 0 {
+				fresh.status = http.StatusOK
+			}
+			if fresh.status >= http.StatusInternalServerError {
+				return "", nil
+			}
+			kept := fresh.header.Clone()
+			kept.Del(requestIDHeader)
+			raw, err := json.Marshal(storedResponse{Fingerprint: fingerprint, Status: fresh.status, Header: kept, Body: fresh.body.Bytes()})
+			return string(raw), err
+		})
+		if fresh != nil {
+			// this request ran the handler, whether or not the response was stored
+			writeRecorded(w, fresh.header, fresh.status, fresh.body.Bytes())
+			return
+		}
+		if err == nil && val == "" {
+			// a concurrent request with the key failed and stored nothing
+			next(w, r)
+			return
+		}
+		var stored storedResponse
+		if err == nil {
+			err = json.Unmarshal([]byte(val), &stored)
+		}
+		if err != nil {
+			s.log.WithContext(r.Context()).Errorf("idempotency key lookup failed: %v", err)
+			writeError(w, http.StatusInternalServerError, errCodeInternal, "internal server error", nil)
+			return
+		}
+		if stored.Fingerprint != fingerprint {
+			writeError(w, http.StatusUnprocessableEntity, errCodeIdempotencyConflict,
+				"Idempotency-Key was already used for a different request", nil)
+			return
+		}
+		w.Header().Set(idempotentReplayHeader, "true")
+		writeRecorded(w, stored.Header, stored.Status, stored.Body)
+	}
+}
+
+// requestFingerprint identifies a request for the idempotency check: its
+// method, path, query, body and the API key that sent it, so a key reused by
+// another client is a conflict rather than a replay of someone else's
+// response.
+func requestFingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	var client string
+	if k, ok := apiKeyFromContext(r.Context()); ok {
+		client = k.Name
+	}
+	for _, part := range []string{r.Method, r.URL.Path, r.URL.RawQuery, client} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// writeRecorded writes a buffered response to w, keeping the headers w
+// already has.
+func writeRecorded(w http.ResponseWriter, h http.Header, status int, body []byte) {
+	for k, v := range h {
+		if k == requestIDHeader {
+			continue
+		}
+		w.Header()[k] = v
+	}
+	w.WriteHeader(status)
+	_, _ = w.Write(body)
+}
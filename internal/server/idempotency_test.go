@@ -0,0 +1,145 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/thiagozs/go-exchange/internal/cache"
+	"github.com/thiagozs/go-exchange/internal/config"
+	"github.com/thiagozs/go-exchange/internal/logger"
+	"github.com/thiagozs/go-exchange/internal/provider"
+)
+
+// ratedProv converts at a rate tests can move, counting calls.
+type ratedProv struct {
+	rate  atomic.Int64
+	calls atomic.Int64
+}
+
+func (p *ratedProv) Convert(ctx context.Context, from, to string, amount int64) (int64, error) {
+	p.calls.Add(1)
+	return amount * p.rate.Load(), nil
+}
+
+func newIdempotencyTestServer(t *testing.T, ttl time.Duration, c provider.Cache) (*Server, *ratedProv) {
+	t.Helper()
+	lg := logger.New(logger.Options{Format: "text", Level: "debug", Out: &bytes.Buffer{}})
+	srv := newTestServer(t, &config.Config{HTTPAddr: ":0", IdempotencyTTL: ttl}, lg)
+	p := &ratedProv{}
+	p.rate.Store(5)
+	useDeps(srv, p, c, nil)
+	return srv, p
+}
+
+func postBatch(srv *Server, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", "/convert/batch", strings.NewReader(body))
+	if key != "" {
+		req.Header.Set(idempotencyKeyHeader, key)
+	}
+	w := httptest.NewRecorder()
+	srv.mux.ServeHTTP(w, req)
+	return w
+}
+
+const singleBatch = `{"items":[{"from":"USD","to":"BRL","amount":1000}]}`
+
+func TestIdempotencyKeyReplay(t *testing.T) {
+	srv, p := newIdempotencyTestServer(t, time.Hour, newMemCache())
+
+	first := postBatch(srv, "order-1", singleBatch)
+	if first.Code != http.StatusOK || first.Header().Get(idempotentReplayHeader) != "" {
+		t.Fatalf("expected a fresh response, got %d %v", first.Code, first.Header())
+	}
+	calls := p.calls.Load()
+	p.rate.Store(7)
+
+	again := postBatch(srv, "order-1", singleBatch)
+	if again.Code != http.StatusOK || again.Header().Get(idempotentReplayHeader) != "true" {
+		t.Fatalf("expected a replay, got %d %v", again.Code, again.Header())
+	}
+	if again.Body.String() != first.Body.String() || !strings.Contains(again.Body.String(), `"net_result_cents":5000`) {
+		t.Fatalf("expected the stored response, got %s, first %s", again.Body.String(), first.Body.String())
+	}
+	if again.Header().Get("Content-Type") != first.Header().Get("Content-Type") {
+		t.Fatalf("expected the stored Content-Type, got %q", again.Header().Get("Content-Type"))
+	}
+	if again.Header().Get(requestIDHeader) == first.Header().Get(requestIDHeader) {
+		t.Fatal("expected the replay to keep its own request ID")
+	}
+	if p.calls.Load() != calls {
+		t.Fatalf("expected no provider call on replay, got %d more", p.calls.Load()-calls)
+	}
+}
+
+func TestIdempotencyKeyConcurrentRetries(t *testing.T) {
+	srv, p := newIdempotencyTestServer(t, time.Hour, cache.NewMemory())
+
+	var (
+		wg      sync.WaitGroup
+		replays atomic.Int64
+	)
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w := postBatch(srv, "order-2", singleBatch)
+			if w.Code != http.StatusOK {
+				t.Errorf("expected 200, got %d %s", w.Code, w.Body.String())
+			}
+			if w.Header().Get(idempotentReplayHeader) == "true" {
+				replays.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	if p.calls.Load() != 1 || replays.Load() != 7 {
+		t.Fatalf("expected one conversion and 7 replays, got %d calls and %d replays", p.calls.Load(), replays.Load())
+	}
+}
+
+func TestIdempotencyKeyConflict(t *testing.T) {
+	srv, _ := newIdempotencyTestServer(t, time.Hour, newMemCache())
+
+	if w := postBatch(srv, "order-3", singleBatch); w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	w := postBatch(srv, "order-3", `{"items":[{"from":"USD","to":"BRL","amount":2000}]}`)
+	if w.Code != http.StatusUnprocessableEntity || !strings.Contains(w.Body.String(), errCodeIdempotencyConflict) {
+		t.Fatalf("expected 422 for a different body, got %d %s", w.Code, w.Body.String())
+	}
+	if w := postBatch(srv, strings.Repeat("k", idempotencyKeyMaxLength+1), singleBatch); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected an oversized key rejected, got %d", w.Code)
+	}
+}
+
+func TestIdempotencyKeyExpiry(t *testing.T) {
+	srv, p := newIdempotencyTestServer(t, 50*time.Millisecond, cache.NewMemory())
+
+	if w := postBatch(srv, "order-4", singleBatch); w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	time.Sleep(100 * time.Millisecond)
+	calls := p.calls.Load()
+	w := postBatch(srv, "order-4", `{"items":[{"from":"USD","to":"BRL","amount":2000}]}`)
+	if w.Code != http.StatusOK || w.Header().Get(idempotentReplayHeader) != "" || p.calls.Load() == calls {
+		t.Fatalf("expected the expired key to be reusable, got %d %v", w.Code, w.Header())
+	}
+}
+
+func TestIdempotencyKeyDisabled(t *testing.T) {
+	srv, p := newIdempotencyTestServer(t, 0, newMemCache())
+
+	postBatch(srv, "order-5", singleBatch)
+	calls := p.calls.Load()
+	if w := postBatch(srv, "order-5", `{"items":[{"from":"USD","to":"BRL","amount":2000}]}`); w.Code != http.StatusOK ||
+		w.Header().Get(idempotentReplayHeader) != "" || p.calls.Load() == calls {
+		t.Fatalf("expected the key ignored with IDEMPOTENCY_TTL=0, got %d %v", w.Code, w.Header())
+	}
+}
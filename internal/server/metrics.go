@@ -0,0 +1,78 @@
+package server
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+const meterName = "github.com/thiagozs/go-exchange/internal/server"
+
+// httpMetrics holds the HTTP server instruments. They are created on first
+// use from the global MeterProvider, so SetupOTel (or a test) can install the
+// provider after the server has been constructed.
+type httpMetrics struct {
+	once     sync.Once
+	duration metric.Float64Histogram
+	requests metric.Int64Counter
+	active   metric.Int64UpDownCounter
+	size     metric.Int64Histogram
+}
+
+func (m *httpMetrics) init() {
+	m.once.Do(func() {
+		meter := otel.GetMeterProvider().Meter(meterName)
+		m.duration, _ = meter.Float64Histogram("http.server.request.duration",
+			metric.WithDescription("Duration of HTTP server requests"),
+			metric.WithUnit("s"),
+		)
+		m.requests, _ = meter.Int64Counter("http.server.request.count",
+			metric.WithDescription("HTTP server requests served"),
+			metric.WithUnit("{request}"),
+		)
+		m.active, _ = meter.Int64UpDownCounter("http.server.active_requests",
+			metric.WithDescription("HTTP server requests in flight"),
+			metric.WithUnit("{request}"),
+		)
+		m.size, _ = meter.Int64Histogram("http.server.response.body.size",
+			metric.WithDescription("Size of HTTP server response bodies as sent"),
+			metric.WithUnit("By"),
+		)
+	})
+}
+
+// start counts a request in flight until the returned func is called.
+func (m *httpMetrics) start(ctx context.Context, method, route string) func() {
+	m.init()
+	if m.active == nil {
+		return func() {}
+	}
+	attrs := metric.WithAttributes(
+		attribute.String("http.request.method", method),
+		attribute.String("http.route", route),
+	)
+	m.active.Add(ctx, 1, attrs)
+	return func() { m.active.Add(ctx, -1, attrs) }
+}
+
+// record observes a finished request. ctx must carry the request span so the
+// SDK can attach it as an exemplar when exemplars are enabled and the span is
+// sampled.
+func (m *httpMetrics) record(ctx context.Context, method, route string, status, size int, d time.Duration) {
+	m.init()
+	if m.duration == nil {
+		return
+	}
+	attrs := metric.WithAttributes(
+		attribute.String("http.request.method", method),
+		attribute.String("http.route", route),
+		attribute.Int("http.response.status_code", status),
+	)
+	m.duration.Record(ctx, d.Seconds(), attrs)
+	m.requests.Add(ctx, 1, attrs)
+	m.size.Record(ctx, int64(size), attrs)
+}
@@ -0,0 +1,183 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/thiagozs/go-exchange/internal/config"
+	"github.com/thiagozs/go-exchange/internal/logger"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/exemplar"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// useProviders installs global tracer/meter providers for the duration of a test.
+func useProviders(t *testing.T, tp *sdktrace.TracerProvider, mp *sdkmetric.MeterProvider) {
+	t.Helper()
+	prevTP, prevMP := otel.GetTracerProvider(), otel.GetMeterProvider()
+	otel.SetTracerProvider(tp)
+	otel.SetMeterProvider(mp)
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prevTP)
+		otel.SetMeterProvider(prevMP)
+		_ = tp.Shutdown(context.Background())
+		_ = mp.Shutdown(context.Background())
+	})
+}
+
+func durationExemplars(t *testing.T, reader *sdkmetric.ManualReader) []metricdata.Exemplar[float64] {
+	t.Helper()
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatalf("collect: %v", err)
+	}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name != "http.server.request.duration" {
+				continue
+			}
+			hist, ok := m.Data.(metricdata.Histogram[float64])
+			if !ok {
+				t.Fatalf("unexpected data type %T", m.Data)
+			}
+			var out []metricdata.Exemplar[float64]
+			for _, dp := range hist.DataPoints {
+				out = append(out, dp.Exemplars...)
+			}
+			return out
+		}
+	}
+	t.Fatalf("http.server.request.duration not recorded")
+	return nil
+}
+
+func TestDurationExemplarsFollowSampling(t *testing.T) {
+	cases := []struct {
+		name    string
+		sampler sdktrace.Sampler
+		want    bool
+	}{
+		{name: "sampled", sampler: sdktrace.AlwaysSample(), want: true},
+		{name: "not sampled", sampler: sdktrace.NeverSample(), want: false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			reader := sdkmetric.NewManualReader()
+			mp := sdkmetric.NewMeterProvider(
+				sdkmetric.WithReader(reader),
+				sdkmetric.WithExemplarFilter(exemplar.TraceBasedFilter),
+			)
+			tp := sdktrace.NewTracerProvider(sdktrace.WithSampler(tc.sampler))
+			useProviders(t, tp, mp)
+
+			lg := logger.New(logger.Options{Format: "text", Level: "info", Out: &bytes.Buffer{}})
+			srv := newTestServer(t, &config.Config{HTTPAddr: ":0"}, lg)
+			srv.instrumentHandler(srv.handleHealth)(httptest.NewRecorder(), httptest.NewRequest("GET", "/health", nil))
+
+			exemplars := durationExemplars(t, reader)
+			if !tc.want {
+				if len(exemplars) != 0 {
+					t.Fatalf("expected no exemplars for unsampled request, got %v", exemplars)
+				}
+				return
+			}
+			if len(exemplars) != 1 {
+				t.Fatalf("expected one exemplar, got %v", exemplars)
+			}
+			var zero [16]byte
+			if len(exemplars[0].TraceID) != 16 || [16]byte(exemplars[0].TraceID) == zero {
+				t.Fatalf("expected a valid trace id on the exemplar, got %x", exemplars[0].TraceID)
+			}
+		})
+	}
+}
+
+// collectMetrics gathers every metric recorded on reader by name.
+func collectMetrics(t *testing.T, reader *sdkmetric.ManualReader) map[string]metricdata.Aggregation {
+	t.Helper()
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatalf("collect: %v", err)
+	}
+	out := map[string]metricdata.Aggregation{}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			out[m.Name] = m.Data
+		}
+	}
+	return out
+}
+
+// routeStatus keys a data point by its http.route and status code.
+func routeStatus(set attribute.Set) string {
+	route, _ := set.Value("http.route")
+	status, _ := set.Value("http.response.status_code")
+	return fmt.Sprintf("%s %d", route.AsString(), status.AsInt64())
+}
+
+func TestServerRecordsRequestMetrics(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	useProviders(t, sdktrace.NewTracerProvider(), sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)))
+
+	lg := logger.New(logger.Options{Format: "text", Level: "info", Out: &bytes.Buffer{}})
+	srv := newTestServer(t, &config.Config{HTTPAddr: ":0"}, lg)
+	for _, target := range []string{"/health", "/health", "/convert?from=USD", "/wp-admin/"} {
+		srv.mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", target, nil))
+	}
+
+	data := collectMetrics(t, reader)
+	counts := map[string]int64{}
+	for _, dp := range data["http.server.request.count"].(metricdata.Sum[int64]).DataPoints {
+		counts[routeStatus(dp.Attributes)] = dp.Value
+	}
+	want := map[string]int64{"/health 200": 2, "/convert 400": 1, "/ 404": 1}
+	if !reflect.DeepEqual(counts, want) {
+		t.Fatalf("request counts: got %v, want %v", counts, want)
+	}
+	for _, dp := range data["http.server.request.duration"].(metricdata.Histogram[float64]).DataPoints {
+		if key := routeStatus(dp.Attributes); dp.Count != uint64(want[key]) {
+			t.Fatalf("duration of %s: got %d observations, want %d", key, dp.Count, want[key])
+		}
+	}
+	for _, dp := range data["http.server.response.body.size"].(metricdata.Histogram[int64]).DataPoints {
+		if routeStatus(dp.Attributes) == "/health 200" && dp.Sum != int64(2*len(`{"status":"ok"}`)) {
+			t.Fatalf("unexpected /health body size sum %d", dp.Sum)
+		}
+	}
+
+	// a request in flight is counted until it finishes
+	unblock, entered := make(chan struct{}), make(chan struct{})
+	h := srv.instrumentHandler(func(w http.ResponseWriter, r *http.Request) {
+		close(entered)
+		<-unblock
+	})
+	done := make(chan struct{})
+	go func() {
+		h(httptest.NewRecorder(), httptest.NewRequest("GET", "/slow", nil))
+		close(done)
+	}()
+	<-entered
+	active := func() int64 {
+		var n int64
+		for _, dp := range collectMetrics(t, reader)["http.server.active_requests"].(metricdata.Sum[int64]).DataPoints {
+			n += dp.Value
+		}
+		return n
+	}
+	if n := active(); n != 1 {
+		t.Fatalf("expected 1 active request, got %d", n)
+	}
+	close(unblock)
+	<-done
+	if n := active(); n != 0 {
+		t.Fatalf("expected no active requests, got %d", n)
+	}
+}
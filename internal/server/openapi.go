@@ -0,0 +1,483 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/thiagozs/go-exchange/internal/config"
+	"github.com/thiagozs/go-exchange/internal/exchange"
+	"github.com/thiagozs/go-exchange/internal/provider"
+	"github.com/thiagozs/go-exchange/internal/version"
+)
+
+// The OpenAPI 3 document served at /openapi.json is maintained by hand as
+// the Go tree below. Keep it in step with the handlers: the tests check the
+// bodies they emit against it.
+
+type openAPIDoc struct {
+	OpenAPI    string               `json:"openapi"`
+	Info       openAPIInfo          `json:"info"`
+	Paths      map[string]*pathItem `json:"paths"`
+	Components openAPIComponents    `json:"components"`
+}
+
+type openAPIInfo struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+type openAPIComponents struct {
+	Schemas         map[string]*schema         `json:"schemas"`
+	SecuritySchemes map[string]*securityScheme `json:"securitySchemes,omitempty"`
+}
+
+type securityScheme struct {
+	Type string `json:"type"`
+	In   string `json:"in"`
+	Name string `json:"name"`
+}
+
+type pathItem struct {
+	Get  *operation `json:"get,omitempty"`
+	Post *operation `json:"post,omitempty"`
+}
+
+type operation struct {
+	OperationID string                `json:"operationId"`
+	Summary     string                `json:"summary"`
+	Description string                `json:"description,omitempty"`
+	Parameters  []*parameter          `json:"parameters,omitempty"`
+	RequestBody *requestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]*response  `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+}
+
+type parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *schema `json:"schema"`
+}
+
+type requestBody struct {
+	Required bool                  `json:"required"`
+	Content  map[string]*mediaType `json:"content"`
+}
+
+type response struct {
+	Description string                `json:"description"`
+	Headers     map[string]*header    `json:"headers,omitempty"`
+	Content     map[string]*mediaType `json:"content,omitempty"`
+}
+
+type header struct {
+	Description string  `json:"description,omitempty"`
+	Schema      *schema `json:"schema"`
+}
+
+type mediaType struct {
+	Schema *schema `json:"schema"`
+}
+
+// schema is the subset of the OpenAPI schema object the document uses.
+type schema struct {
+	Ref         string             `json:"$ref,omitempty"`
+	Type        string             `json:"type,omitempty"`
+	Format      string             `json:"format,omitempty"`
+	Description string             `json:"description,omitempty"`
+	Enum        []string           `json:"enum,omitempty"`
+	Properties  map[string]*schema `json:"properties,omitempty"`
+	Required    []string           `json:"required,omitempty"`
+	Items       *schema            `json:"items,omitempty"`
+	OneOf       []*schema          `json:"oneOf,omitempty"`
+	Minimum     *float64           `json:"minimum,omitempty"`
+	MaxItems    int                `json:"maxItems,omitempty"`
+	MaxLength   int                `json:"maxLength,omitempty"`
+}
+
+func specRef(name string) *schema { return &schema{Ref: "#/components/schemas/" + name} }
+
+func specType(typ, description string) *schema { return &schema{Type: typ, Description: description} }
+
+func specObject(required []string, props map[string]*schema) *schema {
+	return &schema{Type: "object", Required: required, Properties: props}
+}
+
+func specJSON(s *schema) map[string]*mediaType {
+	return map[string]*mediaType{"application/json": {Schema: s}}
+}
+
+func specError(description string) *response {
+	return &response{Description: description, Content: specJSON(specRef("Error"))}
+}
+
+// specFormat is the format query parameter of an endpoint supporting formats,
+// the first being the default.
+func specFormat(formats ...string) *parameter {
+	return &parameter{Name: "format", In: "query", Description: "response format, overriding the Accept header; errors stay JSON, or text in txt",
+		Schema: &schema{Type: "string", Enum: formats}}
+}
+
+// specFormats is the JSON content s plus the text formats.
+func specFormats(s *schema, formats ...string) map[string]*mediaType {
+	content := specJSON(s)
+	for _, f := range formats {
+		mt, _, _ := strings.Cut(formatMediaTypes[f], ";")
+		content[mt] = &mediaType{Schema: &schema{Type: "string"}}
+	}
+	return content
+}
+
+func specQuery(name, typ, description string, required bool) *parameter {
+	return &parameter{Name: name, In: "query", Description: description, Required: required, Schema: &schema{Type: typ}}
+}
+
+var (
+	specAPIKeyOptional = []map[string][]string{{}, {"apiKey": {}}}
+	specZero           = 0.0
+)
+
+// openAPISpec describes the public endpoints; admin endpoints are left out.
+func (s *Server) openAPISpec() *openAPIDoc {
+	currencyParam := func(name string) *parameter {
+		return &parameter{Name: name, In: "query", Required: true, Description: "ISO 4217 code, case-insensitive",
+			Schema: &schema{Type: "string", Format: "currency"}}
+	}
+
+	doc := &openAPIDoc{
+		OpenAPI: "3.0.3",
+		Info: openAPIInfo{Title: "go-exchange", Version: version.Get(s.cfg).Version,
+			Description: "Currency conversion with cached provider rates and configurable fees."},
+		Paths: map[string]*pathItem{
+			"/convert": {Get: &operation{
+				OperationID: "convert",
+				Summary:     "Convert an amount between two currencies",
+				Parameters: []*parameter{
+					currencyParam("from"),
+					currencyParam("to"),
+					{Name: "amount", In: "query", Required: true,
+						Description: `In unit; without it cents ("1000") or decimal units ("10.00"). Positive and within MAX_AMOUNT_CENTS`,
+						Schema:      &schema{Type: "string"}},
+					{Name: "unit", In: "query", Required: false,
+						Description: "cents or major; required with AMOUNT_HEURISTIC=false, otherwise the amount format decides (deprecated)",
+						Schema:      &schema{Type: "string", Enum: []string{exchange.UnitCents, exchange.UnitMajor}}},
+					specQuery("include_fee", "boolean", "false skips the fee; needs an API key with the internal permission", false),
+					specQuery("dry_run", "boolean", "convert without writing to the cache", false),
+					specQuery("provider", "string", "convert with one of the ALLOWED_PROVIDER_OVERRIDES providers instead of EXCHANGE_PROVIDER", false),
+					specFormat(formatJSON, formatTxt, formatCSV),
+					{Name: "Cache-Control", In: "header", Description: "no-cache or no-store, honoured for API keys with the cache_bypass permission",
+						Schema: &schema{Type: "string"}},
+					{Name: "If-None-Match", In: "header", Description: "ETag of a previous response", Schema: &schema{Type: "string"}},
+				},
+				Responses: map[string]*response{
+					"200": {Description: "The conversion; in txt the net result alone, in csv a header row and one row",
+						Content: specFormats(specRef("Conversion"), formatTxt, formatCSV),
+						Headers: map[string]*header{
+							"ETag":          {Description: "Weak ETag of the body", Schema: &schema{Type: "string"}},
+							"Cache-Control": {Description: "public or private, with max-age until the cached rate expires", Schema: &schema{Type: "string"}},
+							"X-Cache":       {Schema: &schema{Type: "string", Enum: []string{"HIT", "MISS"}}},
+						}},
+					"304": {Description: "If-None-Match matched the ETag"},
+					"400": specError("Missing or invalid parameters, a provider that is not allowed, or an unsupported currency pair"),
+					"401": specError("An API key is required for include_fee=false"),
+					"403": specError("The API key lacks the permission for include_fee=false"),
+					"405": specError("Only GET and HEAD are allowed"),
+					"422": specError("The amount exceeds MAX_AMOUNT_CENTS"),
+					"500": specError("The provider failed"),
+					"502": specError("The provider is misconfigured or the fee service is unavailable"),
+					"503": specError("The server is overloaded"),
+					"504": specError("The request ran out of REQUEST_TIMEOUT"),
+				},
+				Security: specAPIKeyOptional,
+			}},
+			"/convert/batch": {Post: &operation{
+				OperationID: "convertBatch",
+				Summary:     "Convert up to 100 amounts",
+				Description: "Invalid items are rejected individually and the valid ones converted, unless strict=true.",
+				Parameters: []*parameter{
+					specQuery("strict", "boolean", "fail the whole batch when any item is invalid", false),
+					specQuery("dry_run", "boolean", "convert without writing to the cache", false),
+					specFormat(formatJSON, formatCSV),
+					{Name: idempotencyKeyHeader, In: "header", Description: "retries sent with the same key within " +
+						"IDEMPOTENCY_TTL get the first response replayed", Schema: &schema{Type: "string", MaxLength: idempotencyKeyMaxLength}},
+				},
+				RequestBody: &requestBody{Required: true, Content: specJSON(specRef("BatchRequest"))},
+				Responses: map[string]*response{
+					"200": {Description: "Per-item results; in csv a header row and one row per item",
+						Headers: map[string]*header{idempotentReplayHeader: {
+							Description: "true when the response is the one stored for the Idempotency-Key",
+							Schema:      &schema{Type: "boolean"}}},
+						Content: specFormats(specRef("BatchResponse"), formatCSV)},
+					"400": {Description: "Invalid JSON, an empty or oversized batch, or invalid items", Content: specJSON(&schema{
+						OneOf: []*schema{specRef("Error"), specRef("BatchResponse")}})},
+					"405": specError("Only POST is allowed"),
+					"422": specError("The Idempotency-Key was already used for a different request"),
+					"503": specError("The server is overloaded"),
+				},
+				Security: specAPIKeyOptional,
+			}},
+			"/currencies": {Get: &operation{
+				OperationID: "currencies",
+				Summary:     "List the currencies that can be converted",
+				Parameters: []*parameter{{Name: "base", In: "query", Description: "narrow the list to the codes served for base (default USD)",
+					Schema: &schema{Type: "string", Format: "currency"}}},
+				Responses: map[string]*response{
+					"200": {Description: "The currencies, sorted by code", Content: specJSON(&schema{Type: "array", Items: specRef("Currency")})},
+					"400": specError("Invalid or unsupported base"),
+					"500": specError("The provider failed"),
+				},
+			}},
+			"/stream/rates": {Get: &operation{
+				OperationID: "streamRates",
+				Summary:     "Stream rate updates as Server-Sent Events",
+				Description: `Sends a "rate" event {from,to,rate,rate_timestamp,source,stale} per pair when known and on each change, ` +
+					`and an "error" event {from,to,code,message} when the provider fails.`,
+				Parameters: []*parameter{
+					specQuery("pairs", "string", "comma-separated FROM-TO pairs, e.g. USD-BRL,EUR-BRL", true),
+					specQuery("interval", "string", "poll interval as a Go duration (default 30s, at least STREAM_MIN_INTERVAL)", false),
+				},
+				Responses: map[string]*response{
+					"200": {Description: "The event stream", Content: map[string]*mediaType{"text/event-stream": {Schema: &schema{Type: "string"}}}},
+					"400": specError("Missing or invalid pairs or interval"),
+					"503": specError("Too many open streams"),
+				},
+			}},
+			"/health": {Get: &operation{
+				OperationID: "health",
+				Summary:     "Liveness probe",
+				Description: "Answers without checking anything, unless verbose=true asks for the component checks.",
+				Parameters: []*parameter{specQuery("verbose", "boolean",
+					"check the cache, provider and fee API, each bounded by HEALTH_CHECK_TIMEOUT", false)},
+				Responses: map[string]*response{
+					"200": {Description: "The process is up, or healthy or degraded with verbose=true",
+						Content: specJSON(&schema{OneOf: []*schema{
+							specObject([]string{"status"}, map[string]*schema{"status": {Type: "string", Enum: []string{"ok"}}}),
+							specRef("HealthReport"),
+						}})},
+					"503": {Description: "Unhealthy (verbose=true only)", Content: specJSON(specRef("HealthReport"))},
+				},
+			}},
+			"/ready": {Get: &operation{
+				OperationID: "ready",
+				Summary:     "Readiness probe",
+				Responses:   map[string]*response{"200": {Description: "The server is ready", Content: specJSON(specRef("Ready"))}},
+			}},
+			"/status": {Get: &operation{
+				OperationID: "status",
+				Summary:     "Upstream usage, hot bases and startup summary",
+				Responses:   map[string]*response{"200": {Description: "The month's usage per provider and the bases refreshed ahead of expiry", Content: specJSON(specRef("Status"))}},
+			}},
+			"/version": {Get: &operation{
+				OperationID: "version",
+				Summary:     "Running build",
+				Responses:   map[string]*response{"200": {Description: "The build", Content: specJSON(specRef("Version"))}},
+			}},
+		},
+		Components: openAPIComponents{
+			SecuritySchemes: map[string]*securityScheme{"apiKey": {Type: "apiKey", In: "header", Name: "X-API-Key"}},
+			Schemas: map[string]*schema{
+				"Conversion": specObject([]string{"from", "to", "amount_cents", "unit", "result_cents", "result", "fee_percent",
+					"fee_percent_cents", "fee_fixed_cents", "fee_amount_cents", "net_result_cents", "net_result", "cache"},
+					map[string]*schema{
+						"from":              specType("string", ""),
+						"to":                specType("string", ""),
+						"amount_cents":      specType("integer", "the converted amount, in cents of from"),
+						"unit":              {Type: "string", Enum: []string{exchange.UnitCents, exchange.UnitMajor}, Description: "the unit amount was read in"},
+						"result_cents":      specType("integer", "the gross result, in cents of to"),
+						"result":            specType("number", ""),
+						"fee_percent":       specType("number", "e.g. 0.01 for 1%"),
+						"fee_percent_cents": specType("integer", ""),
+						"fee_fixed_cents":   specType("integer", ""),
+						"fee_amount_cents":  specType("integer", "the fee charged, after fee_min_cents/fee_max_cents"),
+						"fee_min_cents":     specType("integer", ""),
+						"fee_max_cents":     specType("integer", ""),
+						"fee_clamped":       {Type: "string", Enum: []string{"min", "max"}},
+						"fee_rounding": {Type: "string", Description: "FEE_ROUNDING, how fee_percent_cents was rounded to whole cents",
+							Enum: []string{config.FeeRoundingHalfUp, config.FeeRoundingHalfEven, config.FeeRoundingFloor, config.FeeRoundingCeil}},
+						"fee_unavailable":   specType("boolean", "no fee was charged because the fee service was unavailable"),
+						"fee_waived":        specType("boolean", ""),
+						"fee_waived_reason": {Type: "string", Enum: []string{exchange.FeeWaivedExemptPair, exchange.FeeWaivedRequest}},
+						"fee_tier": specObject([]string{"index", "from_cents"}, map[string]*schema{
+							"index":      specType("integer", ""),
+							"from_cents": specType("integer", ""),
+							"name":       specType("string", ""),
+							"pair":       specType("string", ""),
+						}),
+						"net_result_cents": specType("integer", "result_cents minus fee_amount_cents"),
+						"net_result":       specType("number", ""),
+						"rate":             specType("number", "units of to per unit of from"),
+						"rate_timestamp":   {Type: "string", Format: "date-time"},
+						"source":           specType("string", "the provider"),
+						"provider":         specType("string", "the provider= override that served the conversion"),
+						"cache":            {Type: "string", Enum: []string{"hit", "miss"}},
+						"rate_age_seconds": specType("integer",
+							"seconds since the rate was fetched, for results read from the conversion cache"),
+						"cache_expires_in_seconds": specType("integer",
+							"seconds the conversion cache entry has left, for results read from it"),
+						"stale":       specType("boolean", "served after RATES_SOFT_TTL while a refresh is on its way"),
+						"rate_side":   specType("string", ""),
+						"bulletin":    specType("string", ""),
+						"sources":     {Type: "array", Items: specType("string", "")},
+						"rate_spread": specType("number", ""),
+						"derived":     specType("boolean", "the rate was not quoted directly but derived from the FALLBACK_BASE table or crossed through PIVOT_CURRENCY"),
+					}),
+				"Error": specObject([]string{"error"}, map[string]*schema{"error": specRef("ErrorBody")}),
+				"ErrorBody": specObject([]string{"code", "message"}, map[string]*schema{
+					"code":       specType("string", "stable machine-readable code, e.g. INVALID_CURRENCY"),
+					"message":    specType("string", ""),
+					"request_id": specType("string", "repeats the X-Request-ID header"),
+					"details":    specType("object", ""),
+				}),
+				"BatchRequest": specObject([]string{"items"}, map[string]*schema{
+					"items": {Type: "array", MaxItems: maxBatchItems, Items: specRef("BatchItem")},
+				}),
+				"BatchItem": specObject([]string{"from", "to", "amount"}, map[string]*schema{
+					"from":   specType("string", ""),
+					"to":     specType("string", ""),
+					"amount": {OneOf: []*schema{{Type: "integer", Minimum: &specZero}, {Type: "string"}}, Description: "in unit, like /convert"},
+					"unit":   {Type: "string", Enum: []string{exchange.UnitCents, exchange.UnitMajor}},
+				}),
+				"BatchResponse": specObject([]string{"summary"}, map[string]*schema{
+					"error":   specRef("ErrorBody"),
+					"results": {Type: "array", Items: specRef("BatchResult")},
+					"errors":  {Type: "array", Items: specRef("ItemError")},
+					"summary": specObject([]string{"requested", "succeeded", "failed"}, map[string]*schema{
+						"requested": specType("integer", ""),
+						"succeeded": specType("integer", ""),
+						"failed":    specType("integer", ""),
+					}),
+				}),
+				"BatchResult": specObject([]string{"index"}, map[string]*schema{
+					"index":      specType("integer", ""),
+					"conversion": specRef("Conversion"),
+					"error":      specRef("ItemError"),
+				}),
+				"ItemError": specObject([]string{"index", "code", "message"}, map[string]*schema{
+					"index":   specType("integer", ""),
+					"code":    specType("string", "the code /convert answers for the same failure, e.g. INVALID_CURRENCY or PROVIDER_ERROR"),
+					"message": specType("string", ""),
+				}),
+				"Currency": specObject([]string{"code", "name", "minor_units"}, map[string]*schema{
+					"code":        specType("string", ""),
+					"name":        specType("string", ""),
+					"minor_units": specType("integer", ""),
+				}),
+				"HealthReport": specObject([]string{"status", "components", "uptime_seconds", "version", "cache_hit_ratio"}, map[string]*schema{
+					"status": {Type: "string", Enum: []string{"ok", "degraded", "unhealthy"}},
+					"components": specType("object",
+						"cache, provider and fee_api (when a fee API is configured), each with status, latency_ms and optional error and details"),
+					"uptime_seconds": specType("number", ""),
+					"version":        specRef("Version"),
+					"cache_hit_ratio": specObject([]string{"window_seconds", "hits", "misses", "ratio"}, map[string]*schema{
+						"window_seconds": specType("number", ""),
+						"hits":           specType("integer", "/convert responses served from the conversion cache"),
+						"misses":         specType("integer", ""),
+						"ratio":          specType("number", "0 without requests"),
+					}),
+				}),
+				"Ready": specObject([]string{"status", "cache", "redis_startup"}, map[string]*schema{
+					"status":        {Type: "string", Enum: []string{"ready"}},
+					"cache":         {Type: "string", Enum: []string{"redis", "memory"}},
+					"redis_startup": {Type: "string", Enum: []string{"required", "optional"}},
+					"degraded":      specType("boolean", "REDIS_STARTUP=optional fell back to memory"),
+				}),
+				"Status": specObject([]string{"provider", "upstream_quota", "hot_bases"}, map[string]*schema{
+					"provider": specObject([]string{"active", "configured"}, map[string]*schema{
+						"active":     specType("string", "the provider conversions use"),
+						"configured": specType("string", "EXCHANGE_PROVIDER"),
+						"last_switch": specObject([]string{"from", "to", "by", "at"}, map[string]*schema{
+							"from": specType("string", ""),
+							"to":   specType("string", ""),
+							"by":   specType("string", "name of the API key that made the switch"),
+							"at":   {Type: "string", Format: "date-time"},
+						}),
+					}),
+					"startup": specObject([]string{"version", "commit", "provider", "cache", "fee_source", "otel_exporters", "http_addr", "features", "warnings"}, map[string]*schema{
+						"version": specType("string", ""),
+						"commit":  specType("string", ""),
+						"provider": specObject([]string{"name"}, map[string]*schema{
+							"name":     specType("string", "EXCHANGE_PROVIDER"),
+							"base_url": specType("string", "upstream API, omitted for static and aggregate"),
+							"sources":  {Type: "array", Items: specType("string", ""), Description: "AGGREGATE_SOURCES"},
+						}),
+						"cache": specObject([]string{"backend"}, map[string]*schema{
+							"backend":  {Type: "string", Enum: []string{"redis", "memory"}},
+							"addr":     specType("string", "REDIS_ADDR, credentials redacted"),
+							"degraded": specType("boolean", "REDIS_STARTUP=optional fell back to memory"),
+						}),
+						"fee_source": {Type: "string", Enum: []string{"api", "tiers", "pairs", "percent", "none"}},
+						"otel_exporters": {Type: "array", Items: specObject([]string{"type", "insecure"}, map[string]*schema{
+							"type":        specType("string", "otlp-grpc, otlp-http or disabled"),
+							"endpoint":    specType("string", ""),
+							"protocol":    specType("string", ""),
+							"insecure":    specType("boolean", ""),
+							"compression": specType("string", ""),
+							"headers":     {Type: "array", Items: specType("string", ""), Description: "header names; values are never reported"},
+						})},
+						"http_addr": specType("string", ""),
+						"grpc_addr": specType("string", ""),
+						"features":  {Type: "array", Items: specType("string", ""), Description: "optional middlewares and features turned on"},
+						"warnings":  {Type: "array", Items: specType("string", ""), Description: "settings that are valid but likely mistakes"},
+					}),
+					"hot_bases": {Type: "array", Items: specObject([]string{"base", "score", "hot"}, map[string]*schema{
+						"base":         specType("string", ""),
+						"score":        specType("number", "requests for the base, decayed with HOT_REFRESH_HALF_LIFE"),
+						"hot":          specType("boolean", "score at least HOT_REFRESH_THRESHOLD: its rates are refreshed before they expire"),
+						"next_refresh": {Type: "string", Format: "date-time", Description: "omitted for bases that are not hot"},
+						"last_refresh": {Type: "string", Format: "date-time"},
+						"last_error":   specType("string", "why the last refresh failed"),
+					})},
+					"upstream_quota": {Type: "array", Items: specObject([]string{"provider", "month", "used", "state"}, map[string]*schema{
+						"provider":   specType("string", ""),
+						"month":      specType("string", "YYYY-MM, UTC"),
+						"used":       specType("integer", "requests sent upstream this month, retries included"),
+						"quota":      specType("integer", "PROVIDER_MONTHLY_QUOTA, omitted when unlimited"),
+						"soft_limit": specType("integer", "usage past which rate cache TTLs are stretched"),
+						"state":      {Type: "string", Enum: []string{provider.QuotaOK, provider.QuotaSoftLimit, provider.QuotaExceeded}},
+					})},
+				}),
+				"Version": specObject([]string{"app", "version", "commit", "build_date", "go_version"}, map[string]*schema{
+					"app":        specType("string", ""),
+					"version":    specType("string", ""),
+					"commit":     specType("string", ""),
+					"build_date": specType("string", ""),
+					"go_version": specType("string", ""),
+					"provider":   specType("string", ""),
+				}),
+			},
+		},
+	}
+	return doc
+}
+
+// handleOpenAPI serves the OpenAPI document.
+func (s *Server) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.openAPISpec())
+}
+
+// swaggerUIVersion is the swagger-ui-dist release /docs loads.
+const swaggerUIVersion = "5.17.14"
+
+// handleDocs serves a Swagger UI page for /openapi.json. The UI assets are
+// loaded from the swagger-ui-dist package on unpkg, so the browser needs
+// access to it.
+func (s *Server) handleDocs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprintf(w, `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>go-exchange API</title>
+<link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@%[1]s/swagger-ui.css">
+</head>
+<body>
+<div id="swagger-ui"></div>
+<script src="https://unpkg.com/swagger-ui-dist@%[1]s/swagger-ui-bundle.js"></script>
+<script>window.ui = SwaggerUIBundle({url: "/openapi.json", dom_id: "#swagger-ui"});</script>
+</body>
+</html>
+`, swaggerUIVersion)
+}
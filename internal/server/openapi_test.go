@@ -0,0 +1,355 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/thiagozs/go-exchange/internal/config"
+	"github.com/thiagozs/go-exchange/internal/exchange"
+	"github.com/thiagozs/go-exchange/internal/fee"
+	"github.com/thiagozs/go-exchange/internal/logger"
+	"github.com/thiagozs/go-exchange/internal/provider"
+)
+
+// servedOpenAPI decodes the document as /openapi.json serves it.
+func servedOpenAPI(t *testing.T, srv *Server) *openAPIDoc {
+	t.Helper()
+	w := httptest.NewRecorder()
+	srv.handleOpenAPI(w, httptest.NewRequest("GET", "/openapi.json", nil))
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "application/json") {
+		t.Fatalf("expected a JSON 200, got %d %v", w.Code, w.Header())
+	}
+	var doc openAPIDoc
+	dec := json.NewDecoder(w.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&doc); err != nil {
+		t.Fatalf("decode /openapi.json: %v", err)
+	}
+	return &doc
+}
+
+// resolve follows a local $ref.
+func (d *openAPIDoc) resolve(s *schema) (*schema, error) {
+	if s.Ref == "" {
+		return s, nil
+	}
+	name, ok := strings.CutPrefix(s.Ref, "#/components/schemas/")
+	target := d.Components.Schemas[name]
+	if !ok || target == nil {
+		return nil, fmt.Errorf("unresolved $ref %q", s.Ref)
+	}
+	return target, nil
+}
+
+// checkSchema reports the structural problems of s and its subschemas.
+func (d *openAPIDoc) checkSchema(path string, s *schema) []string {
+	if s == nil {
+		return []string{path + ": missing schema"}
+	}
+	if s.Ref != "" {
+		if _, err := d.resolve(s); err != nil {
+			return []string{path + ": " + err.Error()}
+		}
+		return nil
+	}
+	var errs []string
+	types := []string{"", "object", "array", "string", "integer", "number", "boolean"}
+	if !slices.Contains(types, s.Type) {
+		errs = append(errs, fmt.Sprintf("%s: unknown type %q", path, s.Type))
+	}
+	if s.Type == "" && len(s.OneOf) == 0 {
+		errs = append(errs, path+": neither type nor oneOf")
+	}
+	if s.Type == "array" && s.Items == nil {
+		errs = append(errs, path+": array without items")
+	}
+	for _, name := range s.Required {
+		if _, ok := s.Properties[name]; !ok {
+			errs = append(errs, fmt.Sprintf("%s: required property %q is not declared", path, name))
+		}
+	}
+	for name, p := range s.Properties {
+		errs = append(errs, d.checkSchema(path+"."+name, p)...)
+	}
+	for i, alt := range s.OneOf {
+		errs = append(errs, d.checkSchema(fmt.Sprintf("%s.oneOf[%d]", path, i), alt)...)
+	}
+	if s.Items != nil {
+		errs = append(errs, d.checkSchema(path+"[]", s.Items)...)
+	}
+	return errs
+}
+
+// validate reports where v, a decoded JSON value, does not match s.
+// Objects may only carry the properties s declares.
+func (d *openAPIDoc) validate(path string, s *schema, v any) []string {
+	s, err := d.resolve(s)
+	if err != nil {
+		return []string{path + ": " + err.Error()}
+	}
+	if len(s.OneOf) > 0 {
+		matched := 0
+		for _, alt := range s.OneOf {
+			if len(d.validate(path, alt, v)) == 0 {
+				matched++
+			}
+		}
+		if matched != 1 {
+			return []string{fmt.Sprintf("%s: %v matches %d of the oneOf schemas", path, v, matched)}
+		}
+		return nil
+	}
+	mismatch := []string{fmt.Sprintf("%s: %v is not of type %s", path, v, s.Type)}
+	switch s.Type {
+	case "object":
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return mismatch
+		}
+		var errs []string
+		for _, name := range s.Required {
+			if _, ok := obj[name]; !ok {
+				errs = append(errs, fmt.Sprintf("%s: missing required property %q", path, name))
+			}
+		}
+		if s.Properties == nil {
+			return errs
+		}
+		for name, pv := range obj {
+			ps, ok := s.Properties[name]
+			if !ok {
+				errs = append(errs, fmt.Sprintf("%s: undeclared property %q", path, name))
+				continue
+			}
+			errs = append(errs, d.validate(path+"."+name, ps, pv)...)
+		}
+		return errs
+	case "array":
+		arr, ok := v.([]any)
+		if !ok {
+			return mismatch
+		}
+		if s.MaxItems > 0 && len(arr) > s.MaxItems {
+			return []string{fmt.Sprintf("%s: %d items, at most %d allowed", path, len(arr), s.MaxItems)}
+		}
+		var errs []string
+		for i, item := range arr {
+			errs = append(errs, d.validate(fmt.Sprintf("%s[%d]", path, i), s.Items, item)...)
+		}
+		return errs
+	case "string":
+		str, ok := v.(string)
+		if !ok {
+			return mismatch
+		}
+		if len(s.Enum) > 0 && !slices.Contains(s.Enum, str) {
+			return []string{fmt.Sprintf("%s: %q is not one of %v", path, str, s.Enum)}
+		}
+		if s.Format == "date-time" {
+			if _, err := time.Parse(time.RFC3339, str); err != nil {
+				return []string{fmt.Sprintf("%s: %q is not a date-time", path, str)}
+			}
+		}
+	case "integer", "number":
+		n, ok := v.(float64)
+		if !ok || (s.Type == "integer" && n != math.Trunc(n)) {
+			return mismatch
+		}
+		if s.Minimum != nil && n < *s.Minimum {
+			return []string{fmt.Sprintf("%s: %v is below %v", path, n, *s.Minimum)}
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return mismatch
+		}
+	}
+	return nil
+}
+
+// responseSchema is the JSON schema of a documented response.
+func (d *openAPIDoc) responseSchema(t *testing.T, path, method, status string) *schema {
+	t.Helper()
+	item := d.Paths[path]
+	if item == nil {
+		t.Fatalf("%s is not documented", path)
+	}
+	op := item.Get
+	if method == "POST" {
+		op = item.Post
+	}
+	if op == nil || op.Responses[status] == nil || op.Responses[status].Content["application/json"] == nil {
+		t.Fatalf("%s %s has no JSON %s response", method, path, status)
+	}
+	return op.Responses[status].Content["application/json"].Schema
+}
+
+// assertMatches fails unless the JSON body of w matches s.
+func assertMatches(t *testing.T, doc *openAPIDoc, s *schema, w *httptest.ResponseRecorder) {
+	t.Helper()
+	var v any
+	if err := json.Unmarshal(w.Body.Bytes(), &v); err != nil {
+		t.Fatalf("decode: %v (%s)", err, w.Body)
+	}
+	if errs := doc.validate("$", s, v); len(errs) > 0 {
+		t.Fatalf("body does not match the schema:\n%s\nbody: %s", strings.Join(errs, "\n"), w.Body)
+	}
+}
+
+func TestOpenAPIDocumentIsValid(t *testing.T) {
+	lg := logger.New(logger.Options{Format: "text", Level: "info", Out: &bytes.Buffer{}})
+	srv := newTestServer(t, &config.Config{HTTPAddr: ":0"}, lg)
+	doc := servedOpenAPI(t, srv)
+
+	if !strings.HasPrefix(doc.OpenAPI, "3.") || doc.Info.Title == "" || doc.Info.Version == "" {
+		t.Fatalf("bad header: openapi %q info %+v", doc.OpenAPI, doc.Info)
+	}
+	var errs []string
+	for name, s := range doc.Components.Schemas {
+		errs = append(errs, doc.checkSchema("#/components/schemas/"+name, s)...)
+	}
+	ids := map[string]bool{}
+	for path, item := range doc.Paths {
+		if !strings.HasPrefix(path, "/") {
+			errs = append(errs, fmt.Sprintf("path %q does not start with /", path))
+		}
+		for method, op := range map[string]*operation{"get": item.Get, "post": item.Post} {
+			if op == nil {
+				continue
+			}
+			where := method + " " + path
+			if op.OperationID == "" || ids[op.OperationID] {
+				errs = append(errs, fmt.Sprintf("%s: missing or duplicate operationId %q", where, op.OperationID))
+			}
+			ids[op.OperationID] = true
+			if len(op.Responses) == 0 {
+				errs = append(errs, where+": no responses")
+			}
+			for status, resp := range op.Responses {
+				if len(status) != 3 || status[0] < '1' || status[0] > '5' {
+					errs = append(errs, fmt.Sprintf("%s: bad status %q", where, status))
+				}
+				if resp.Description == "" {
+					errs = append(errs, fmt.Sprintf("%s %s: missing description", where, status))
+				}
+				for ct, mt := range resp.Content {
+					errs = append(errs, doc.checkSchema(fmt.Sprintf("%s %s %s", where, status, ct), mt.Schema)...)
+				}
+				for name, h := range resp.Headers {
+					errs = append(errs, doc.checkSchema(fmt.Sprintf("%s %s header %s", where, status, name), h.Schema)...)
+				}
+			}
+			for _, p := range op.Parameters {
+				if p.Name == "" || !slices.Contains([]string{"query", "header", "path", "cookie"}, p.In) {
+					errs = append(errs, fmt.Sprintf("%s: bad parameter %+v", where, p))
+				}
+				errs = append(errs, doc.checkSchema(where+" parameter "+p.Name, p.Schema)...)
+			}
+			if op.RequestBody != nil {
+				for ct, mt := range op.RequestBody.Content {
+					errs = append(errs, doc.checkSchema(where+" request "+ct, mt.Schema)...)
+				}
+			}
+			for _, req := range op.Security {
+				for name := range req {
+					if doc.Components.SecuritySchemes[name] == nil {
+						errs = append(errs, fmt.Sprintf("%s: unknown security scheme %q", where, name))
+					}
+				}
+			}
+		}
+	}
+	if len(errs) > 0 {
+		t.Fatalf("invalid document:\n%s", strings.Join(errs, "\n"))
+	}
+
+	for _, path := range []string{"/convert", "/convert/batch", "/currencies", "/stream/rates", "/health", "/ready", "/version"} {
+		if doc.Paths[path] == nil {
+			t.Errorf("%s is not documented", path)
+		}
+	}
+}
+
+func TestOpenAPIMatchesResponses(t *testing.T) {
+	lg := logger.New(logger.Options{Format: "text", Level: "info", Out: &bytes.Buffer{}})
+	srv := newTestServer(t, &config.Config{HTTPAddr: ":0", CacheTTL: time.Minute}, lg)
+	useDeps(srv, &mockProv{}, newMemCache(), nil)
+	doc := servedOpenAPI(t, srv)
+	conversion := doc.responseSchema(t, "/convert", "GET", "200")
+
+	w := httptest.NewRecorder()
+	srv.handleConvert(w, httptest.NewRequest("GET", "/convert?from=USD&to=BRL&amount=1000", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body)
+	}
+	assertMatches(t, doc, conversion, w)
+
+	// every optional field of a conversion
+	full := httptest.NewRecorder()
+	writeJSON(full, http.StatusOK, conversionBody(exchange.ConvertResponse{
+		From: "USD", To: "BRL", AmountCents: 1000, Unit: exchange.UnitMajor, CacheHit: true,
+		Result: provider.ConvertResult{ResultCents: 5000, Rate: 5, RateTimestamp: time.Now(), Stale: true,
+			Source: "bcb", RateSide: "sell", Bulletin: "closing", Sources: []string{"bcb", "frankfurter"}, Spread: 0.01, Derived: true},
+		Fee:            fee.FeeQuote{Percent: 0.01, FixedCents: 10, MinCents: 100, MaxCents: 1000, Tier: &fee.Tier{Index: 1, Name: "large", FromCents: 500, Pair: "USD-BRL"}},
+		FeeAmount:      fee.FeeAmount{PercentCents: 50, FixedCents: 10, TotalCents: 100, Clamped: "min", Rounding: config.FeeRoundingHalfEven},
+		FeeUnavailable: true,
+		FeeWaived:      exchange.FeeWaivedExemptPair,
+	}))
+	assertMatches(t, doc, conversion, full)
+
+	w = httptest.NewRecorder()
+	srv.handleConvert(w, httptest.NewRequest("GET", "/convert?from=USD", nil))
+	assertMatches(t, doc, doc.responseSchema(t, "/convert", "GET", "400"), w)
+
+	w = httptest.NewRecorder()
+	srv.handleConvertBatch(w, httptest.NewRequest("POST", "/convert/batch",
+		strings.NewReader(`{"items":[{"from":"USD","to":"BRL","amount":"10.00"},{"from":"USD","to":"XXX","amount":100}]}`)))
+	assertMatches(t, doc, doc.responseSchema(t, "/convert/batch", "POST", "200"), w)
+
+	w = httptest.NewRecorder()
+	srv.handleConvertBatch(w, httptest.NewRequest("POST", "/convert/batch?strict=true",
+		strings.NewReader(`{"items":[{"from":"USD","to":"XXX","amount":100}]}`)))
+	assertMatches(t, doc, doc.responseSchema(t, "/convert/batch", "POST", "400"), w)
+
+	for path, h := range map[string]http.HandlerFunc{
+		"/currencies": srv.handleCurrencies,
+		"/health":     srv.handleHealth,
+		"/ready":      srv.handleReady,
+		"/version":    srv.handleVersion,
+	} {
+		w := httptest.NewRecorder()
+		h(w, httptest.NewRequest("GET", path, nil))
+		assertMatches(t, doc, doc.responseSchema(t, path, "GET", "200"), w)
+	}
+
+	verbose := httptest.NewRecorder()
+	srv.handleHealth(verbose, httptest.NewRequest("GET", "/health?verbose=true", nil))
+	assertMatches(t, doc, doc.responseSchema(t, "/health", "GET", "200"), verbose)
+
+	// the validator rejects fields the document does not declare
+	extra := httptest.NewRecorder()
+	writeJSON(extra, http.StatusOK, map[string]any{"status": "ok", "uptime": 1})
+	var v any
+	_ = json.Unmarshal(extra.Body.Bytes(), &v)
+	if errs := doc.validate("$", doc.responseSchema(t, "/health", "GET", "200"), v); len(errs) != 1 {
+		t.Fatalf("expected the undeclared field to be reported, got %v", errs)
+	}
+}
+
+func TestDocsLoadsOpenAPIDocument(t *testing.T) {
+	lg := logger.New(logger.Options{Format: "text", Level: "info", Out: &bytes.Buffer{}})
+	srv := newTestServer(t, &config.Config{HTTPAddr: ":0"}, lg)
+	w := httptest.NewRecorder()
+	srv.handleDocs(w, httptest.NewRequest("GET", "/docs", nil))
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/html") ||
+		!strings.Contains(w.Body.String(), `url: "/openapi.json"`) {
+		t.Fatalf("unexpected /docs response: %d %v", w.Code, w.Header())
+	}
+}
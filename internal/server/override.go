@@ -0,0 +1,53 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/thiagozs/go-exchange/internal/config"
+	"github.com/thiagozs/go-exchange/internal/exchange"
+	"github.com/thiagozs/go-exchange/internal/logger"
+	"github.com/thiagozs/go-exchange/internal/provider"
+)
+
+// providerHeader names the provider picked with ?provider=; the access log
+// reports it.
+const providerHeader = "X-Provider"
+
+// newProviderOverrides builds each ALLOWED_PROVIDER_OVERRIDES provider once,
+// with the same settings and cache as EXCHANGE_PROVIDER. Like it, an unknown
+// name is a configuration error.
+func newProviderOverrides(cfg *config.Config, lg *logger.Logger, c provider.Cache) (map[string]provider.Provider, error) {
+	provs := map[string]provider.Provider{}
+	for _, name := range cfg.ProviderOverrides {
+		name = strings.TrimSpace(name)
+		if _, ok := provs[name]; ok || name == "" {
+			continue
+		}
+		ocfg := *cfg
+		ocfg.Provider = name
+		p, err := provider.NewProviderFromConfig(&ocfg, lg, c)
+		if err != nil {
+			return nil, fmt.Errorf("ALLOWED_PROVIDER_OVERRIDES: %w", err)
+		}
+		provs[name] = p
+	}
+	return provs, nil
+}
+
+// conversionService returns the service for the provider parameter of r and
+// the provider name it asked for, empty without one. A name missing from
+// ALLOWED_PROVIDER_OVERRIDES gets a 400 and ok false.
+func (s *Server) conversionService(w http.ResponseWriter, r *http.Request) (svc *exchange.Service, name string, ok bool) {
+	name = r.URL.Query().Get("provider")
+	if name == "" {
+		return s.svc, "", true
+	}
+	p, allowed := s.overrides[name]
+	if !allowed {
+		writeError(w, http.StatusBadRequest, errCodeInvalidProvider, fmt.Sprintf("provider %q is not allowed", name), nil)
+		return nil, "", false
+	}
+	return s.svc.WithProvider(name, p), name, true
+}
@@ -0,0 +1,78 @@
+package server
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/thiagozs/go-exchange/internal/config"
+	"github.com/thiagozs/go-exchange/internal/logger"
+)
+
+func TestConvertProviderOverride(t *testing.T) {
+	var logs bytes.Buffer
+	lg := logger.New(logger.Options{Format: "json", Level: "info", Out: &logs})
+	cfg := &config.Config{HTTPAddr: ":0", CacheTTL: time.Minute, Provider: "static",
+		ProviderOverrides: []string{"static", " bcb"}}
+	srv := newTestServer(t, cfg, lg)
+	if len(srv.overrides) != 2 || srv.overrides["bcb"] == nil {
+		t.Fatalf("expected static and bcb to be built, got %v", srv.overrides)
+	}
+	c := newMemCache()
+	useDeps(srv, &mockProv{}, c, nil)
+	srv.overrides["bcb"] = &metaProv{ts: time.Now()}
+
+	get := func(target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		srv.mux.ServeHTTP(w, httptest.NewRequest("GET", target, nil))
+		return w
+	}
+
+	def := get("/convert?from=USD&to=BRL&amount=1000")
+	if def.Code != http.StatusOK || def.Header().Get(providerHeader) != "" || decodeResponse(t, def)["provider"] != nil {
+		t.Fatalf("expected the default provider, got %d %v: %s", def.Code, def.Header(), def.Body)
+	}
+	bcb := get("/convert?from=USD&to=BRL&amount=1000&provider=bcb")
+	body := decodeResponse(t, bcb)
+	if bcb.Code != http.StatusOK || bcb.Header().Get(providerHeader) != "bcb" || body["provider"] != "bcb" ||
+		body["result_cents"] != float64(5000) || body["cache"] != "miss" {
+		t.Fatalf("expected a bcb miss, got %d %v: %s", bcb.Code, bcb.Header(), bcb.Body)
+	}
+	if bcb.Header().Get("ETag") == def.Header().Get("ETag") {
+		t.Fatalf("expected different ETags per provider")
+	}
+	if c.m["convert:v1:USD:BRL:1000"] == "" || c.m["convert:v1:bcb:USD:BRL:1000"] == "" {
+		t.Fatalf("expected one cache entry per provider, got %v", c.m)
+	}
+	if w := get("/convert?from=USD&to=BRL&amount=1000"); decodeResponse(t, w)["result_cents"] != float64(20000) {
+		t.Fatalf("the override poisoned the default entry: %s", w.Body)
+	}
+
+	for _, name := range []string{"exchangerate-api", "nope"} {
+		w := get("/convert?from=USD&to=BRL&amount=1000&provider=" + name)
+		if w.Code != http.StatusBadRequest || decodeError(t, w).Code != errCodeInvalidProvider {
+			t.Fatalf("%s: expected 400 %s, got %d: %s", name, errCodeInvalidProvider, w.Code, w.Body)
+		}
+	}
+
+	var providers []any
+	for _, l := range jsonLogLines(t, &logs) {
+		if l["msg"] == "access" {
+			providers = append(providers, l["provider"])
+		}
+	}
+	if len(providers) != 5 || providers[0] != nil || providers[1] != "bcb" {
+		t.Fatalf("expected the provider in the access log of the override, got %v", providers)
+	}
+}
+
+func TestProviderOverridesRejectUnknownNames(t *testing.T) {
+	lg := logger.New(logger.Options{Format: "text", Level: "info", Out: &bytes.Buffer{}})
+	cfg := &config.Config{HTTPAddr: ":0", Provider: "static", ProviderOverrides: []string{"nope"}}
+	if _, err := New(cfg, lg); err == nil || !strings.Contains(err.Error(), "ALLOWED_PROVIDER_OVERRIDES") {
+		t.Fatalf("expected an ALLOWED_PROVIDER_OVERRIDES error, got %v", err)
+	}
+}
@@ -0,0 +1,84 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// queryParams are the query parameters a handler reads. Each handler declares
+// its set next to it; with STRICT_QUERY_PARAMS any other is rejected.
+type queryParams []string
+
+// unknownParam is one rejected parameter, with the closest known name when
+// it looks like a typo.
+type unknownParam struct {
+	Name       string `json:"name"`
+	Suggestion string `json:"suggestion,omitempty"`
+}
+
+// strictQuery answers 400 UNKNOWN_PARAMETERS to requests carrying query
+// parameters outside known, so a misspelled one is reported instead of being
+// read as missing. It returns next unchanged unless STRICT_QUERY_PARAMS is
+// set.
+func (s *Server) strictQuery(known queryParams, next http.HandlerFunc) http.HandlerFunc {
+	if !s.cfg.StrictQueryParams {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		var unknown []unknownParam
+		for name := range r.URL.Query() {
+			if !slices.Contains(known, name) {
+				unknown = append(unknown, unknownParam{Name: name, Suggestion: suggestParam(name, known)})
+			}
+		}
+		if len(unknown) == 0 {
+			next(w, r)
+			return
+		}
+		slices.SortFunc(unknown, func(a, b unknownParam) int { return strings.Compare(a.Name, b.Name) })
+		msgs := make([]string, len(unknown))
+		for i, u := range unknown {
+			msgs[i] = fmt.Sprintf("%q", u.Name)
+			if u.Suggestion != "" {
+				msgs[i] += fmt.Sprintf(" (did you mean %q?)", u.Suggestion)
+			}
+		}
+		writeError(w, http.StatusBadRequest, errCodeUnknownParameters,
+			"unknown query parameters: "+strings.Join(msgs, ", "), unknown)
+	}
+}
+
+// suggestParam returns the known name closest to name, if it is within two
+// edits and closer than name is long; "" otherwise.
+func suggestParam(name string, known queryParams) string {
+	best, bestDist := "", 3
+	for _, k := range known {
+		if d := levenshtein(name, k); d < bestDist && d < len(name) {
+			best, bestDist = k, d
+		}
+	}
+	return best
+}
+
+// levenshtein is the edit distance between a and b.
+func levenshtein(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
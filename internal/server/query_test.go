@@ -0,0 +1,113 @@
+package server
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/thiagozs/go-exchange/internal/config"
+	"github.com/thiagozs/go-exchange/internal/logger"
+)
+
+func TestStrictQueryParams(t *testing.T) {
+	lg := logger.New(logger.Options{Format: "text", Level: "info", Out: &bytes.Buffer{}})
+	cfg := &config.Config{HTTPAddr: ":0", CacheTTL: time.Minute, StrictQueryParams: true}
+	srv := newTestServer(t, cfg, lg)
+	useDeps(srv, &mockProv{}, newMemCache(), nil)
+	get := func(target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		srv.mux.ServeHTTP(w, httptest.NewRequest("GET", target, nil))
+		return w
+	}
+
+	w := get("/convert?from=USD&to=BRL&ammount=1000&zzz=1")
+	e := decodeError(t, w)
+	if w.Code != http.StatusBadRequest || e.Code != errCodeUnknownParameters {
+		t.Fatalf("expected 400 %s, got %d: %s", errCodeUnknownParameters, w.Code, w.Body)
+	}
+	want := []any{
+		map[string]any{"name": "ammount", "suggestion": "amount"},
+		map[string]any{"name": "zzz"},
+	}
+	if !reflect.DeepEqual(e.Details, want) {
+		t.Fatalf("expected ammount->amount and zzz without a suggestion, got %v", e.Details)
+	}
+	if e.Message != `unknown query parameters: "ammount" (did you mean "amount"?), "zzz"` {
+		t.Fatalf("unexpected message %q", e.Message)
+	}
+
+	for _, target := range []string{"/convert?from=USD&to=BRL&amount=1000&dry_run=true", "/health?verbose=false", "/currencies?base=USD"} {
+		if w := get(target); w.Code != http.StatusOK {
+			t.Fatalf("%s: expected the known parameters to pass, got %d: %s", target, w.Code, w.Body)
+		}
+	}
+	if w := get("/version?verbose=true"); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected /version to take no parameters, got %d", w.Code)
+	}
+
+	// off by default: unknown parameters are ignored
+	srv = newTestServer(t, &config.Config{HTTPAddr: ":0", CacheTTL: time.Minute}, lg)
+	useDeps(srv, &mockProv{}, newMemCache(), nil)
+	w = httptest.NewRecorder()
+	srv.mux.ServeHTTP(w, httptest.NewRequest("GET", "/convert?from=USD&to=BRL&amount=1000&ammount=5", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected pass-through without STRICT_QUERY_PARAMS, got %d: %s", w.Code, w.Body)
+	}
+}
+
+func TestSuggestParam(t *testing.T) {
+	known := convertParams
+	cases := map[string]string{
+		"ammount":    "amount",
+		"form":       "from",
+		"too":        "to",
+		"includefee": "include_fee",
+		"providr":    "provider",
+		"x":          "",
+		"currency":   "",
+	}
+	for name, want := range cases {
+		if got := suggestParam(name, known); got != want {
+			t.Errorf("%s: expected %q, got %q", name, want, got)
+		}
+	}
+}
+
+// The parameter sets of the handlers and the OpenAPI document describe the
+// same endpoints; they must agree.
+func TestQueryParamsMatchOpenAPI(t *testing.T) {
+	lg := logger.New(logger.Options{Format: "text", Level: "info", Out: &bytes.Buffer{}})
+	srv := newTestServer(t, &config.Config{HTTPAddr: ":0"}, lg)
+	doc := servedOpenAPI(t, srv)
+
+	for path, known := range map[string]queryParams{
+		"/convert":       convertParams,
+		"/convert/batch": batchParams,
+		"/currencies":    currenciesParams,
+		"/stream/rates":  streamParams,
+		"/health":        healthParams,
+		"/ready":         nil,
+		"/version":       nil,
+	} {
+		item := doc.Paths[path]
+		op := item.Get
+		if op == nil {
+			op = item.Post
+		}
+		var documented []string
+		for _, p := range op.Parameters {
+			if p.In == "query" {
+				documented = append(documented, p.Name)
+			}
+		}
+		slices.Sort(documented)
+		handled := slices.Sorted(slices.Values(known))
+		if !slices.Equal(documented, handled) {
+			t.Errorf("%s: OpenAPI documents %v, the handler reads %v", path, documented, handled)
+		}
+	}
+}
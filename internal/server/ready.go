@@ -0,0 +1,67 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/thiagozs/go-exchange/internal/cache"
+	"github.com/thiagozs/go-exchange/internal/config"
+	"github.com/thiagozs/go-exchange/internal/logger"
+	"github.com/thiagozs/go-exchange/internal/provider"
+)
+
+// cacheBackend describes the cache chosen at startup, reported by /ready.
+type cacheBackend struct {
+	Cache        string `json:"cache"` // redis or memory
+	RedisStartup string `json:"redis_startup"`
+	// Degraded is set when REDIS_STARTUP=optional fell back to memory.
+	Degraded bool `json:"degraded,omitempty"`
+}
+
+// openCache connects to Redis according to REDIS_STARTUP, unless
+// CACHE_BACKEND=memory selects the in-process cache. With required a
+// failed PING is a startup error; with optional the server logs a warning and
+// uses an in-process cache instead of paying a Redis timeout per request.
+func openCache(cfg *config.Config, lg *logger.Logger) (provider.Cache, cacheBackend, error) {
+	mode := cfg.RedisStartup
+	if mode != config.RedisStartupRequired {
+		mode = config.RedisStartupOptional
+	}
+	ctx := context.Background()
+	if cfg.CacheBackend == config.CacheBackendMemory {
+		lg.WithContext(ctx).Infof("CACHE_BACKEND=memory: using in-memory cache")
+		return cache.NewMemory(), cacheBackend{Cache: "memory", RedisStartup: mode}, nil
+	}
+	if cfg.RedisAddr == "" {
+		lg.WithContext(ctx).Infof("REDIS_ADDR is empty: using in-memory cache")
+		return cache.NewMemory(), cacheBackend{Cache: "memory", RedisStartup: mode}, nil
+	}
+
+	rc := cache.New(cfg.RedisAddr, cfg.RedisDB,
+		cfg.RedisUsername, cfg.RedisPassword, cfg.CacheKeyPrefix, cfg.RedisOpTimeout, lg)
+	pingCtx := ctx
+	if cfg.RedisStartupTimeout > 0 {
+		var cancel context.CancelFunc
+		pingCtx, cancel = context.WithTimeout(ctx, cfg.RedisStartupTimeout)
+		defer cancel()
+	}
+	err := rc.Ping(pingCtx)
+	if err == nil {
+		lg.WithContext(ctx).Infof("redis %s reachable (REDIS_STARTUP=%s)", cfg.RedisAddr, mode)
+		return rc, cacheBackend{Cache: "redis", RedisStartup: mode}, nil
+	}
+	_ = rc.Close()
+	if mode == config.RedisStartupRequired {
+		return nil, cacheBackend{}, fmt.Errorf("redis %s unavailable (REDIS_STARTUP=required): %w", cfg.RedisAddr, err)
+	}
+	lg.WithContext(ctx).Warnf("redis %s unavailable (REDIS_STARTUP=optional), using in-memory cache: %v", cfg.RedisAddr, err)
+	return cache.NewMemory(), cacheBackend{Cache: "memory", RedisStartup: mode, Degraded: true}, nil
+}
+
+func (s *Server) handleReady(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, struct {
+		Status string `json:"status"`
+		cacheBackend
+	}{Status: "ready", cacheBackend: s.backend})
+}
@@ -0,0 +1,96 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/thiagozs/go-exchange/internal/cache"
+	"github.com/thiagozs/go-exchange/internal/config"
+	"github.com/thiagozs/go-exchange/internal/logger"
+)
+
+// closedAddr returns a local address nothing listens on.
+func closedAddr(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+	return addr
+}
+
+func readyBody(t *testing.T, srv *Server) map[string]any {
+	t.Helper()
+	w := httptest.NewRecorder()
+	srv.handleReady(w, httptest.NewRequest("GET", "/ready", nil))
+	var out map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil {
+		t.Fatalf("decode /ready: %v", err)
+	}
+	return out
+}
+
+func TestRedisStartupRequiredFails(t *testing.T) {
+	addr := closedAddr(t)
+	cfg := &config.Config{HTTPAddr: ":0", RedisAddr: addr,
+		RedisStartup: config.RedisStartupRequired, RedisStartupTimeout: 300 * time.Millisecond}
+	lg := logger.New(logger.Options{Format: "text", Level: "info", Out: &bytes.Buffer{}})
+
+	_, err := New(cfg, lg)
+	if err == nil || !strings.Contains(err.Error(), "REDIS_STARTUP=required") || !strings.Contains(err.Error(), addr) {
+		t.Fatalf("expected a startup error naming the policy and address, got %v", err)
+	}
+}
+
+func TestRedisStartupOptionalFallsBackToMemory(t *testing.T) {
+	cfg := &config.Config{HTTPAddr: ":0", RedisAddr: closedAddr(t),
+		RedisStartup: config.RedisStartupOptional, RedisStartupTimeout: 300 * time.Millisecond}
+	var logs bytes.Buffer
+	lg := logger.New(logger.Options{Format: "text", Level: "info", Out: &logs})
+
+	srv := newTestServer(t, cfg, lg)
+	if _, ok := srv.cache.(*cache.MemoryCache); !ok {
+		t.Fatalf("expected the in-memory cache, got %T", srv.cache)
+	}
+	if !strings.Contains(logs.String(), "REDIS_STARTUP=optional") {
+		t.Fatalf("expected the startup mode in the log, got:\n%s", logs.String())
+	}
+	out := readyBody(t, srv)
+	if out["status"] != "ready" || out["cache"] != "memory" || out["redis_startup"] != "optional" || out["degraded"] != true {
+		t.Fatalf("unexpected /ready body %v", out)
+	}
+}
+
+func TestReadyWithoutRedis(t *testing.T) {
+	lg := logger.New(logger.Options{Format: "text", Level: "info", Out: &bytes.Buffer{}})
+	srv := newTestServer(t, &config.Config{HTTPAddr: ":0"}, lg)
+	out := readyBody(t, srv)
+	if out["cache"] != "memory" || out["redis_startup"] != "optional" {
+		t.Fatalf("unexpected /ready body %v", out)
+	}
+	if _, ok := out["degraded"]; ok {
+		t.Fatalf("an empty REDIS_ADDR is not a degraded start: %v", out)
+	}
+}
+
+func TestCacheBackendMemorySkipsRedis(t *testing.T) {
+	// REDIS_STARTUP=required would fail against a closed port
+	cfg := &config.Config{HTTPAddr: ":0", RedisAddr: closedAddr(t),
+		RedisStartup: config.RedisStartupRequired, CacheBackend: config.CacheBackendMemory}
+	lg := logger.New(logger.Options{Format: "text", Level: "info", Out: &bytes.Buffer{}})
+
+	srv := newTestServer(t, cfg, lg)
+	if _, ok := srv.cache.(*cache.MemoryCache); !ok {
+		t.Fatalf("expected the in-memory cache, got %T", srv.cache)
+	}
+	if out := readyBody(t, srv); out["cache"] != "memory" {
+		t.Fatalf("unexpected /ready body %v", out)
+	}
+}
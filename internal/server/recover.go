@@ -0,0 +1,53 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"runtime/debug"
+
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// recoverHandler turns a panic inside next into a logged, traced 500 JSON
+// response instead of a dropped connection.
+func (s *Server) recoverHandler(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+			if v == http.ErrAbortHandler {
+				// deliberate abort: let net/http handle it silently
+				panic(v)
+			}
+
+			ctx := r.Context()
+			err := fmt.Errorf("panic: %v", v)
+			stack := string(debug.Stack())
+
+			span := trace.SpanFromContext(ctx)
+			span.RecordError(err, trace.WithStackTrace(true))
+			span.SetStatus(codes.Error, err.Error())
+
+			s.log.WithContext(ctx).WithFields(map[string]any{
+				"panic":  fmt.Sprint(v),
+				"stack":  stack,
+				"method": r.Method,
+				"path":   r.URL.Path,
+			}).Error("panic recovered in handler")
+
+			rw, ok := w.(*respWriter)
+			if ok {
+				rw.panicked = true
+			}
+			if ok && rw.wroteHeader {
+				// response already started; nothing sensible left to send
+				return
+			}
+			writeError(w, http.StatusInternalServerError, errCodeInternal, "internal server error", nil)
+		}()
+		next(w, r)
+	}
+}
@@ -0,0 +1,160 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/thiagozs/go-exchange/internal/config"
+	"github.com/thiagozs/go-exchange/internal/logger"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestRecoverHandlerReturnsJSON500(t *testing.T) {
+	exp := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exp))
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(tp)
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prev)
+		_ = tp.Shutdown(context.Background())
+	})
+
+	var buf bytes.Buffer
+	lg := logger.New(logger.Options{Format: "json", Level: "info", Out: &buf})
+	srv := newTestServer(t, &config.Config{HTTPAddr: ":0"}, lg)
+
+	h := srv.instrumentHandler(srv.recoverHandler(func(w http.ResponseWriter, r *http.Request) {
+		var p *struct{ name string }
+		_ = p.name // nil dereference
+	}))
+	w := httptest.NewRecorder()
+	h(w, httptest.NewRequest("GET", "/convert", nil))
+
+	res := w.Result()
+	if res.StatusCode != http.StatusInternalServerError {
+		t.Fatalf("expected 500, got %d", res.StatusCode)
+	}
+	if ct := res.Header.Get("Content-Type"); ct != "application/json" {
+		t.Fatalf("expected JSON content type, got %q", ct)
+	}
+	var body map[string]any
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		t.Fatalf("decode body: %v", err)
+	}
+	if body["error"] == nil {
+		t.Fatalf("expected error field in body, got %v", body)
+	}
+
+	var panicLine map[string]any
+	for _, l := range jsonLogLines(t, &buf) {
+		if l["msg"] == "panic recovered in handler" {
+			panicLine = l
+		}
+	}
+	if panicLine == nil {
+		t.Fatalf("expected panic log entry, got: %s", buf.String())
+	}
+	if stack, _ := panicLine["stack"].(string); !strings.Contains(stack, "TestRecoverHandlerReturnsJSON500") {
+		t.Fatalf("expected stack trace pointing at the handler, got %q", stack)
+	}
+	if tid, _ := panicLine["trace_id"].(string); tid == "" {
+		t.Fatalf("expected trace_id on the panic log entry")
+	}
+
+	spans := exp.GetSpans()
+	if len(spans) != 1 || spans[0].Status.Code != codes.Error {
+		t.Fatalf("expected one errored span, got %+v", spans)
+	}
+	if len(spans[0].Events) == 0 || spans[0].Events[0].Name != "exception" {
+		t.Fatalf("expected exception event on span, got %+v", spans[0].Events)
+	}
+}
+
+func TestRecoverHandlerFlushesTelemetry(t *testing.T) {
+	lg := logger.New(logger.Options{Format: "json", Level: "info", Out: &bytes.Buffer{}})
+	flushed := make(chan struct{}, 1)
+	lg.RegisterFlusher(func(context.Context) error {
+		flushed <- struct{}{}
+		return nil
+	})
+	srv := newTestServer(t, &config.Config{HTTPAddr: ":0"}, lg)
+
+	h := srv.instrumentHandler(srv.recoverHandler(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}))
+	h(httptest.NewRecorder(), httptest.NewRequest("GET", "/convert", nil))
+
+	select {
+	case <-flushed:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("expected telemetry to be flushed after a recovered panic")
+	}
+}
+
+// TestRecoverHandlerWithDefaultMiddlewares registers a panicking route with
+// the default config, so REQUEST_TIMEOUT and gzip wrap the response writer.
+func TestRecoverHandlerWithDefaultMiddlewares(t *testing.T) {
+	t.Setenv("CACHE_BACKEND", "memory")
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.RequestTimeout <= 0 || cfg.GzipMinSize <= 0 {
+		t.Fatalf("expected the timeout and gzip middlewares enabled by default, got %v and %d", cfg.RequestTimeout, cfg.GzipMinSize)
+	}
+	lg := logger.New(logger.Options{Format: "json", Level: "info", Out: &bytes.Buffer{}})
+	flushed := make(chan struct{}, 4)
+	lg.RegisterFlusher(func(context.Context) error {
+		flushed <- struct{}{}
+		return nil
+	})
+	srv := newTestServer(t, cfg, lg)
+	srv.handle("/panic", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("partial"))
+		panic("boom")
+	}, http.MethodGet)
+	srv.handle("/panic-early", func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}, http.MethodGet)
+
+	cases := []struct {
+		target, acceptEncoding string
+		wantCode               int
+		wantBody               string
+	}{
+		// the response had started: nothing is appended to it
+		{"/panic", "", http.StatusOK, "partial"},
+		{"/panic-early", "", http.StatusInternalServerError, errCodeInternal},
+		// gzip still held the partial body back, so it is dropped for the 500
+		{"/panic", "gzip", http.StatusInternalServerError, errCodeInternal},
+		{"/panic-early", "gzip", http.StatusInternalServerError, errCodeInternal},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest("GET", tc.target, nil)
+		if tc.acceptEncoding != "" {
+			req.Header.Set("Accept-Encoding", tc.acceptEncoding)
+		}
+		w := httptest.NewRecorder()
+		srv.mux.ServeHTTP(w, req)
+
+		if w.Code != tc.wantCode || !strings.Contains(w.Body.String(), tc.wantBody) ||
+			(tc.wantCode == http.StatusOK) != strings.Contains(w.Body.String(), "partial") {
+			t.Fatalf("%s (Accept-Encoding %q): expected %d %q, got %d %q",
+				tc.target, tc.acceptEncoding, tc.wantCode, tc.wantBody, w.Code, w.Body.String())
+		}
+		select {
+		case <-flushed:
+		case <-time.After(2 * time.Second):
+			t.Fatalf("%s: expected telemetry to be flushed after a recovered panic", tc.target)
+		}
+	}
+}
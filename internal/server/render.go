@@ -0,0 +1,113 @@
+package server
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Response formats of the conversion endpoints, picked with the format query
+// parameter or the Accept header. Errors stay JSON, except in txt where they
+// are a plain "CODE: message" line.
+const (
+	formatJSON = "json"
+	formatTxt  = "txt"
+	formatCSV  = "csv"
+)
+
+// formatMediaTypes are the Content-Types of each format; the Accept header is
+// matched against them, ignoring parameters.
+var formatMediaTypes = map[string]string{
+	formatJSON: "application/json",
+	formatTxt:  "text/plain; charset=utf-8",
+	formatCSV:  "text/csv; charset=utf-8",
+}
+
+// negotiateFormat picks the response format of r among supported, the first
+// being the default. A format query parameter wins over the Accept header; an
+// unsupported one is answered with 400 and ok false. Accept headers naming
+// none of the supported formats get the default.
+func negotiateFormat(w http.ResponseWriter, r *http.Request, supported ...string) (format string, ok bool) {
+	w.Header().Add("Vary", "Accept")
+	if v := r.URL.Query().Get("format"); v != "" {
+		if !slices.Contains(supported, strings.ToLower(v)) {
+			writeError(w, http.StatusBadRequest, errCodeInvalidParameter,
+				fmt.Sprintf("invalid format %q: expected one of %s", v, strings.Join(supported, ", ")), nil)
+			return "", false
+		}
+		return strings.ToLower(v), true
+	}
+	format, bestQ := supported[0], 0.0
+	for part := range strings.SplitSeq(r.Header.Get("Accept"), ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.ReplaceAll(params, " ", ""), "q="); ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				q = f
+			}
+		}
+		for _, f := range supported {
+			mt, _, _ := strings.Cut(formatMediaTypes[f], ";")
+			if name == mt && q > bestQ {
+				format, bestQ = f, q
+			}
+		}
+	}
+	return format, true
+}
+
+// textErrorWriter makes writeError answer in plain text, for requests that
+// asked for the txt format.
+type textErrorWriter struct {
+	http.ResponseWriter
+}
+
+func (t *textErrorWriter) Unwrap() http.ResponseWriter {
+	return t.ResponseWriter
+}
+
+// writeText writes a plain text response.
+func writeText(w http.ResponseWriter, status int, text string) {
+	w.Header().Set("Content-Type", formatMediaTypes[formatTxt])
+	w.WriteHeader(status)
+	_, _ = w.Write([]byte(text))
+}
+
+// writeCSV writes a CSV response of a header row and rows, quoting fields as
+// RFC 4180 requires.
+func writeCSV(w http.ResponseWriter, status int, header []string, rows [][]string) {
+	w.Header().Set("Content-Type", formatMediaTypes[formatCSV])
+	w.WriteHeader(status)
+	cw := csv.NewWriter(w)
+	_ = cw.Write(header)
+	_ = cw.WriteAll(rows)
+}
+
+// conversionColumns are the CSV columns of a conversion, keys of its
+// conversionBody.
+var conversionColumns = []string{"from", "to", "amount_cents", "unit", "result_cents", "fee_amount_cents",
+	"net_result_cents", "net_result", "rate", "rate_timestamp", "source", "cache"}
+
+// conversionRow is the CSV row of a conversionBody; absent fields are empty.
+func conversionRow(body map[string]any) []string {
+	row := make([]string, len(conversionColumns))
+	for i, col := range conversionColumns {
+		switch v := body[col].(type) {
+		case nil:
+		case float64:
+			row[i] = strconv.FormatFloat(v, 'f', -1, 64)
+		default:
+			row[i] = fmt.Sprint(v)
+		}
+	}
+	return row
+}
+
+// netResultText is the txt form of a conversionBody: the net result alone.
+func netResultText(netCents int64) string {
+	return strconv.FormatFloat(float64(netCents)/100, 'f', 2, 64) + "\n"
+}
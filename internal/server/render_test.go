@@ -0,0 +1,146 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/thiagozs/go-exchange/internal/config"
+	"github.com/thiagozs/go-exchange/internal/logger"
+	"github.com/thiagozs/go-exchange/internal/provider"
+)
+
+// quotingProv names its source with characters CSV must quote.
+type quotingProv struct{}
+
+func (p *quotingProv) Convert(ctx context.Context, from, to string, amount int64) (int64, error) {
+	return amount * 5, nil
+}
+
+func (p *quotingProv) ConvertDetailed(ctx context.Context, from, to string, amount int64) (provider.ConvertResult, error) {
+	return provider.ConvertResult{ResultCents: amount * 5, Rate: 5, Source: `ecb, "reference"`}, nil
+}
+
+func newFormatTestServer(t *testing.T) *Server {
+	t.Helper()
+	lg := logger.New(logger.Options{Format: "text", Level: "debug", Out: &bytes.Buffer{}})
+	srv := newTestServer(t, &config.Config{HTTPAddr: ":0"}, lg)
+	useDeps(srv, &quotingProv{}, newMemCache(), nil)
+	return srv
+}
+
+func TestConvertFormats(t *testing.T) {
+	srv := newFormatTestServer(t)
+	get := func(query, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/convert?from=USD&to=BRL&"+query, nil)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		w := httptest.NewRecorder()
+		srv.handleConvert(w, req)
+		return w
+	}
+
+	w := get("amount=10.50&unit=major&format=txt", "")
+	if w.Code != http.StatusOK || w.Body.String() != "52.50\n" || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain") {
+		t.Fatalf("expected the net result alone, got %d %q %q", w.Code, w.Header().Get("Content-Type"), w.Body.String())
+	}
+
+	w = get("amount=1050&unit=cents", "application/json;q=0.5, text/csv")
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/csv") {
+		t.Fatalf("expected csv from the Accept header, got %d %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	raw := w.Body.String()
+	if !strings.Contains(raw, `,"ecb, ""reference""",`) {
+		t.Fatalf("expected the source quoted, got %q", raw)
+	}
+	rows, err := csv.NewReader(strings.NewReader(raw)).ReadAll()
+	if err != nil || len(rows) != 2 {
+		t.Fatalf("expected a header and a row, got %q (%v)", rows, err)
+	}
+	if strings.Join(rows[0], ",") != strings.Join(conversionColumns, ",") {
+		t.Fatalf("unexpected header %q", rows[0])
+	}
+	row := map[string]string{}
+	for i, col := range rows[0] {
+		row[col] = rows[1][i]
+	}
+	if row["amount_cents"] != "1050" || row["net_result"] != "52.5" || row["source"] != `ecb, "reference"` {
+		t.Fatalf("unexpected row %v", row)
+	}
+
+	w = get("amount=1050&unit=cents&format=json", "text/csv")
+	var body map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || body["net_result_cents"] != 5250.0 {
+		t.Fatalf("expected format to win over Accept, got %q (%v)", w.Body.String(), err)
+	}
+	if got := w.Header().Values("Vary"); !strings.Contains(strings.Join(got, ","), "Accept") {
+		t.Fatalf("expected Vary: Accept, got %v", got)
+	}
+}
+
+func TestConvertFormatErrors(t *testing.T) {
+	srv := newFormatTestServer(t)
+	get := func(query string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		srv.handleConvert(w, httptest.NewRequest("GET", "/convert?from=USD&to=BRL&"+query, nil))
+		return w
+	}
+
+	w := get("amount=abc&format=txt")
+	if w.Code != http.StatusBadRequest || w.Body.String() != "INVALID_AMOUNT: invalid amount\n" ||
+		!strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain") {
+		t.Fatalf("expected a text error, got %d %q %q", w.Code, w.Header().Get("Content-Type"), w.Body.String())
+	}
+	w = get("amount=abc&format=csv")
+	if w.Code != http.StatusBadRequest || !strings.HasPrefix(w.Header().Get("Content-Type"), "application/json") {
+		t.Fatalf("expected a JSON error for csv, got %d %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	w = get("amount=1000&format=xml")
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), errCodeInvalidParameter) {
+		t.Fatalf("expected an unsupported format rejected, got %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestBatchCSV(t *testing.T) {
+	srv := newFormatTestServer(t)
+	post := func(query string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		srv.handleConvertBatch(w, httptest.NewRequest("POST", "/convert/batch"+query, strings.NewReader(mixedBatch)))
+		return w
+	}
+
+	w := post("?format=csv")
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/csv") {
+		t.Fatalf("expected csv, got %d %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	rows, err := csv.NewReader(w.Body).ReadAll()
+	if err != nil || len(rows) != 5 || strings.Join(rows[0], ",") != strings.Join(batchColumns, ",") {
+		t.Fatalf("expected a header and 4 rows, got %q (%v)", rows, err)
+	}
+	last, source := len(batchColumns)-1, slices.Index(batchColumns, "source")
+	for i, want := range []string{"", "INVALID_CURRENCY", "INVALID_AMOUNT", ""} {
+		row := rows[i+1]
+		if row[0] != strconv.Itoa(i) || row[last-1] != want {
+			t.Fatalf("row %d: expected error %q, got %q", i, want, row)
+		}
+		if want == "" && (row[source] != `ecb, "reference"` || row[last] != "") {
+			t.Fatalf("row %d: expected a conversion, got %q", i, row)
+		}
+	}
+
+	if w := post("?format=txt"); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected txt rejected for batches, got %d", w.Code)
+	}
+	if w := post("?format=csv&strict=true"); w.Code != http.StatusBadRequest ||
+		!strings.HasPrefix(w.Header().Get("Content-Type"), "application/json") {
+		t.Fatalf("expected a JSON error for a strict csv batch, got %d %q", w.Code, w.Header().Get("Content-Type"))
+	}
+}
@@ -0,0 +1,56 @@
+package server
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// routes registers the endpoints on s.mux with the methods and query
+// parameters each accepts.
+func (s *Server) routes() {
+	get, post := http.MethodGet, http.MethodPost
+	s.handle("/convert", s.strictQuery(convertParams, s.handleConvert), get)
+	s.handle("/convert/batch", s.strictQuery(batchParams, s.idempotent(s.handleConvertBatch)), post)
+	s.handle("/currencies", s.strictQuery(currenciesParams, s.handleCurrencies), get)
+	s.handle("/health", s.strictQuery(healthParams, s.handleHealth), get)
+	s.handle("/ready", s.strictQuery(nil, s.handleReady), get)
+	s.handle("/version", s.strictQuery(nil, s.handleVersion), get)
+	s.handle("/status", s.strictQuery(nil, s.handleStatus), get)
+	s.handle("/openapi.json", s.strictQuery(nil, s.handleOpenAPI), get)
+	if s.cfg.DocsEnabled {
+		s.handle("/docs", s.strictQuery(nil, s.handleDocs), get)
+	}
+	if s.cfg.StreamMaxSubscribers > 0 {
+		s.handle("/stream/rates", s.strictQuery(streamParams, s.handleStreamRates), get)
+	}
+	if s.cfg.AdminEnabled {
+		s.handle("/admin/cache", s.requireAdmin(s.strictQuery(adminCacheParams, s.handleAdminCache)), http.MethodDelete)
+		s.handle("/admin/loglevel", s.requireAdmin(s.strictQuery(nil, s.handleAdminLogLevel)), get, http.MethodPut)
+		s.handle("/admin/alerts", s.requireAdmin(s.strictQuery(nil, s.handleAdminAlerts)), get)
+		s.handle("/admin/provider", s.requireAdmin(s.strictQuery(nil, s.handleAdminProvider)), get, http.MethodPut)
+	}
+	// everything else, logged so probing traffic shows up
+	s.mux.HandleFunc("/", s.instrumentHandler(s.handleNotFound))
+}
+
+// allowMethods answers 405 with an Allow header to requests whose method is
+// not in methods. GET routes also accept HEAD.
+func (s *Server) allowMethods(methods []string, next http.HandlerFunc) http.HandlerFunc {
+	if slices.Contains(methods, http.MethodGet) {
+		methods = append(methods, http.MethodHead)
+	}
+	allow := strings.Join(methods, ", ")
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !slices.Contains(methods, r.Method) {
+			w.Header().Set("Allow", allow)
+			writeError(w, http.StatusMethodNotAllowed, errCodeMethodNotAllowed, "method not allowed", nil)
+			return
+		}
+		next(w, r)
+	}
+}
+
+func (s *Server) handleNotFound(w http.ResponseWriter, r *http.Request) {
+	writeError(w, http.StatusNotFound, errCodeNotFound, "not found", nil)
+}
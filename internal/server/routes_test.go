@@ -0,0 +1,79 @@
+package server
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/thiagozs/go-exchange/internal/config"
+	"github.com/thiagozs/go-exchange/internal/logger"
+)
+
+func TestRoutesRejectWrongMethods(t *testing.T) {
+	lg := logger.New(logger.Options{Format: "text", Level: "info", Out: &bytes.Buffer{}})
+	srv := newTestServer(t, &config.Config{HTTPAddr: ":0", CacheTTL: time.Minute}, lg)
+	useDeps(srv, &mockProv{}, newMemCache(), nil)
+
+	cases := []struct {
+		method, target, allow string
+	}{
+		{http.MethodPost, "/convert?from=USD&to=BRL&amount=1000", "GET, HEAD"},
+		{http.MethodDelete, "/currencies", "GET, HEAD"},
+		{http.MethodGet, "/convert/batch", "POST"},
+		{http.MethodPut, "/health", "GET, HEAD"},
+	}
+	for _, tc := range cases {
+		w := httptest.NewRecorder()
+		srv.mux.ServeHTTP(w, httptest.NewRequest(tc.method, tc.target, strings.NewReader(`{}`)))
+		if w.Code != http.StatusMethodNotAllowed || w.Header().Get("Allow") != tc.allow {
+			t.Fatalf("%s %s: expected 405 with Allow %q, got %d %v", tc.method, tc.target, tc.allow, w.Code, w.Header())
+		}
+		if e := decodeError(t, w); e.Code != errCodeMethodNotAllowed || e.RequestID == "" {
+			t.Fatalf("%s %s: unexpected error %+v", tc.method, tc.target, e)
+		}
+	}
+
+	for _, method := range []string{http.MethodGet, http.MethodHead} {
+		w := httptest.NewRecorder()
+		srv.mux.ServeHTTP(w, httptest.NewRequest(method, "/convert?from=USD&to=BRL&amount=1000", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s /convert: expected 200, got %d: %s", method, w.Code, w.Body)
+		}
+	}
+}
+
+func TestUnknownPathIsJSON404(t *testing.T) {
+	var logs bytes.Buffer
+	lg := logger.New(logger.Options{Format: "json", Level: "info", Out: &logs})
+	srv := newTestServer(t, &config.Config{HTTPAddr: ":0"}, lg)
+
+	for _, target := range []string{"/wp-login.php", "/.env", "/convert/extra"} {
+		w := httptest.NewRecorder()
+		srv.mux.ServeHTTP(w, httptest.NewRequest("GET", target, nil))
+		if w.Code != http.StatusNotFound || w.Header().Get("Content-Type") != "application/json" {
+			t.Fatalf("%s: expected a JSON 404, got %d %v", target, w.Code, w.Header())
+		}
+		if e := decodeError(t, w); e.Code != errCodeNotFound || e.RequestID != w.Header().Get(requestIDHeader) {
+			t.Fatalf("%s: unexpected error %+v", target, e)
+		}
+	}
+
+	// each probe is in the access log, but they share one route in the stats
+	var paths []any
+	for _, l := range jsonLogLines(t, &logs) {
+		if l["msg"] == "access" && l["status"] == float64(http.StatusNotFound) {
+			paths = append(paths, l["path"])
+		}
+	}
+	if len(paths) != 3 || paths[0] != "/wp-login.php" {
+		t.Fatalf("expected 3 access entries for the probes, got %v", paths)
+	}
+	srv.stats.mu.Lock()
+	defer srv.stats.mu.Unlock()
+	if srv.stats.paths["/"] != 3 || srv.stats.paths["/.env"] != 0 {
+		t.Fatalf("expected the probes under the / route, got %v", srv.stats.paths)
+	}
+}
@@ -3,74 +3,281 @@ package server
 import (
 	"context"
 	"encoding/json"
-	"math"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
 	"os"
 	"os/signal"
 	"strconv"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
-	"github.com/thiagozs/go-exchange/internal/cache"
+	"github.com/thiagozs/go-exchange/internal/alert"
 	"github.com/thiagozs/go-exchange/internal/config"
+	"github.com/thiagozs/go-exchange/internal/exchange"
 	"github.com/thiagozs/go-exchange/internal/fee"
+	"github.com/thiagozs/go-exchange/internal/httpclient"
+	"github.com/thiagozs/go-exchange/internal/lifecycle"
 	"github.com/thiagozs/go-exchange/internal/logger"
 	"github.com/thiagozs/go-exchange/internal/provider"
+	"github.com/thiagozs/go-exchange/internal/version"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type Server struct {
-	cfg   *config.Config
-	cache provider.Cache
-	prov  provider.Provider
-	fee   fee.Provider
-	log   *logger.Logger
+	cfg       *config.Config
+	cache     provider.Cache
+	backend   cacheBackend
+	prov      provider.Provider
+	overrides map[string]provider.Provider // ALLOWED_PROVIDER_OVERRIDES by name
+	svc       *exchange.Service
+	streams   *rateHub
+	alerts    *alert.Checker         // nil unless RATE_ALERTS is set
+	hot       *provider.HotRefresher // nil unless HOT_REFRESH_THRESHOLD is set
+	log       *logger.Logger
+	fee       fee.Provider // nil when no fee is configured
+	stats     *requestStats
+	hits      *hitRatio // conversion cache hit ratio for /health?verbose=true
+	accessLog *accessLogThrottle
+	shedder   *loadShedder // nil unless MAX_INFLIGHT_REQUESTS is set
+	metrics   httpMetrics
+	mux       *http.ServeMux
+	started   time.Time
+	// summary is reported by /status; nil until SetStartupSummary
+	summary *StartupSummary
+	// switchMu serializes PUT /admin/provider; switched is its last switch,
+	// nil until the first
+	switchMu sync.Mutex
+	switched *providerSwitch
+	// life owns the background components; they stop after the HTTP server
+	life *lifecycle.Manager
 }
 
-// respWriter captures HTTP status and size
+// respWriter captures HTTP status and size, and finalizes the request timing
+// right before the header goes out.
 type respWriter struct {
 	http.ResponseWriter
-	status int
-	size   int
+	status       int
+	size         int
+	wroteHeader  bool
+	uncompressed int // body size before gzip, 0 when not compressed
+	timing       *timingRecorder
+	emitTiming   bool
+	// panicked is set by recoverHandler
+	panicked bool
 }
 
 func (rw *respWriter) WriteHeader(code int) {
+	if !rw.wroteHeader {
+		rw.timing.finalize(rw.Header(), rw.emitTiming)
+	}
 	rw.status = code
+	rw.wroteHeader = true
 	rw.ResponseWriter.WriteHeader(code)
 }
 
 func (rw *respWriter) Write(b []byte) (int, error) {
+	if !rw.wroteHeader {
+		rw.WriteHeader(http.StatusOK)
+	}
 	n, err := rw.ResponseWriter.Write(b)
 	rw.size += n
 	return n, err
 }
 
-func New(cfg *config.Config, lg *logger.Logger) *Server {
-	c := cache.New(cfg.RedisAddr, cfg.RedisDB,
-		cfg.RedisUsername, cfg.RedisPassword, lg)
+// Unwrap gives http.ResponseController access to the underlying writer, so
+// streams can flush.
+func (rw *respWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
 
-	prov := provider.NewProviderFromConfig(cfg, lg, c)
+// New builds the server. It fails when EXCHANGE_PROVIDER names an unknown
+// provider, when REDIS_STARTUP=required and Redis is unavailable, or when
+// BCB_API_BASE_URL, FEE_API_URL or a RATE_ALERTS webhook violates the
+// outbound HTTP policy.
+func New(cfg *config.Config, lg *logger.Logger) (*Server, error) {
+	c, backend, err := openCache(cfg, lg)
+	if err != nil {
+		return nil, err
+	}
 
-	var fprov fee.Provider
+	prov, err := provider.NewProviderFromConfig(cfg, lg, c)
+	if err != nil {
+		return nil, err
+	}
+
+	overrides, err := newProviderOverrides(cfg, lg, c)
+	if err != nil {
+		return nil, err
+	}
 
+	fprov, err := newFeeProvider(cfg, lg)
+	if err != nil {
+		return nil, err
+	}
+	svc := exchange.New(cfg, prov, c, fprov, lg)
+	alerts, err := newAlertChecker(cfg, svc, lg)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{cfg: cfg, cache: c, backend: backend,
+		prov: prov, overrides: overrides, svc: svc, fee: fprov, log: lg.With(map[string]any{"component": "http"}),
+		streams: newRateHub(cfg, svc, lg.With(map[string]any{"component": "stream"})),
+		alerts:  alerts,
+		hot:     newHotRefresher(cfg, prov, svc, lg),
+		stats:   newRequestStats(),
+		hits:    newHitRatio(),
+		shedder: newLoadShedder(cfg),
+		accessLog: newAccessLogThrottle(cfg.AccessLogRateLimit, cfg.AccessLogBurst,
+			cfg.AccessLogSampleN, cfg.AccessLogSlowThreshold).filter(cfg.AccessLogExcludePaths, cfg.AccessLogSampleRate),
+		mux:     http.NewServeMux(),
+		started: time.Now(),
+		life:    lifecycle.New(componentStopTimeout, lg.With(map[string]any{"component": "lifecycle"})),
+	}
+	if cl, ok := c.(io.Closer); ok {
+		// added first, so it stops after everything that uses it
+		s.life.Add("cache", nil, func(context.Context) error { return cl.Close() })
+	}
+	s.routes()
+	return s, nil
+}
+
+// newFeeProvider builds the fee provider selected by configuration, in order
+// of precedence: FEE_API_URL, FEE_TIERS, EXCHANGE_FEES, EXCHANGE_FEE_PERCENT.
+// With FEE_PLANS it becomes the default plan of a fee.TenantFeeProvider. It
+// returns nil when no fee is configured, and fails when FEE_API_URL violates
+// the outbound HTTP policy.
+func newFeeProvider(cfg *config.Config, lg *logger.Logger) (fee.Provider, error) {
+	var fprov fee.Provider
 	if cfg.FeeAPIURL != "" {
-		fprov = fee.NewFeeAPIProvider(cfg.FeeAPIURL, lg)
+		policy := httpclient.PolicyFromConfig(cfg)
+		if err := httpclient.Validate(context.Background(), policy, lg, "FEE_API_URL", cfg.FeeAPIURL); err != nil {
+			return nil, fmt.Errorf("FEE_API_URL: %w", err)
+		}
+		// the fee API is ours, so its spans join the trace of the conversion;
+		// it is identified like the providers
+		provOpts := httpclient.OptionsFromConfig(cfg)
+		client := httpclient.New(policy, httpclient.Options{Timeout: cfg.FeeAPITimeout, PropagateTrace: true,
+			UserAgent: provOpts.UserAgent, Headers: provOpts.Headers}, lg)
+		fprov = fee.NewFeeAPIProvider(cfg.FeeAPIURL, client, fee.FeeAPIOptions{
+			CacheTTL:   cfg.FeeCacheTTL,
+			MaxRetries: cfg.FeeAPIMaxRetries,
+			AuthHeader: cfg.FeeAPIAuthHeader,
+			AuthToken:  cfg.FeeAPIAuthToken,
+		}, lg)
+	} else if cfg.FeeTiers.Enabled() {
+		fprov = fee.NewTieredFeeProvider(cfg.FeeTiers)
+	} else if len(cfg.Fees) > 0 {
+		fprov = fee.NewConfigFeeProvider(cfg.Fees)
 	} else if cfg.FeePercent > 0 {
 		fprov = fee.NewEnvFeeProviderWithPercent(cfg.FeePercent)
 	}
+	if len(cfg.FeePlans) > 0 {
+		return fee.NewTenantFeeProvider(fprov, cfg.FeePlans, cfg.FeeLimits), nil
+	}
+	return fprov, nil
+}
+
+// newAlertChecker builds the RATE_ALERTS checker, or returns nil when no
+// alert is configured. Webhooks go through the outbound HTTP policy like the
+// fee API, and one that violates it fails startup.
+func newAlertChecker(cfg *config.Config, svc *exchange.Service, lg *logger.Logger) (*alert.Checker, error) {
+	if len(cfg.RateAlerts) == 0 {
+		return nil, nil
+	}
+	policy := httpclient.PolicyFromConfig(cfg)
+	for _, a := range cfg.RateAlerts {
+		if err := httpclient.Validate(context.Background(), policy, lg, "RATE_ALERTS", a.WebhookURL); err != nil {
+			return nil, fmt.Errorf("RATE_ALERTS: %w", err)
+		}
+	}
+	client := httpclient.New(policy, httpclient.Options{Timeout: cfg.AlertWebhookTimeout}, lg)
+	return alert.New(cfg, svc, client, lg), nil
+}
 
-	return &Server{cfg: cfg, cache: c,
-		prov: prov, fee: fprov, log: lg,
+// newHotRefresher builds the HOT_REFRESH_THRESHOLD refresher and has svc
+// report its conversions to it. It returns nil when disabled or when prov
+// does not cache rates per base.
+func newHotRefresher(cfg *config.Config, prov provider.Provider, svc *exchange.Service, lg *logger.Logger) *provider.HotRefresher {
+	if cfg.HotRefreshThreshold <= 0 {
+		return nil
+	}
+	r, ok := provider.As[provider.RatesRefresher](prov)
+	if !ok {
+		lg.WithContext(context.Background()).Infof("hot refresh disabled: provider %T does not cache rates per base", prov)
+		return nil
 	}
+	h := provider.NewHotRefresher(r, cfg.HotRefreshThreshold, cfg.HotRefreshHalfLife, cfg.HotRefreshLead,
+		cfg.HotRefreshInterval, lg.With(map[string]any{"component": "hot-refresh"}))
+	svc.TrackDemand(h)
+	return h
 }
 
-func (s *Server) Run() error {
-	http.HandleFunc("/convert", s.instrumentHandler(s.handleConvert))
-	http.HandleFunc("/health", s.instrumentHandler(s.handleHealth))
+// warmupTimeout bounds the startup prefetch of WARMUP_BASES.
+const warmupTimeout = 30 * time.Second
+
+// Warmup prefetches the provider rates for bases into the cache, so the first
+// conversions after a deploy hit. Failures are logged and returned joined.
+func (s *Server) Warmup(ctx context.Context, bases []string) error {
+	return provider.WarmBases(ctx, s.prov, bases, s.log)
+}
+
+// componentStopTimeout bounds the stop of each background component.
+const componentStopTimeout = 5 * time.Second
+
+// Lifecycle returns the manager of the background components. Run starts
+// them before serving and, once the HTTP server stopped, stops them in
+// reverse order; components such as the gRPC API or the OTel exporters must
+// be added before Run.
+func (s *Server) Lifecycle() *lifecycle.Manager {
+	return s.life
+}
 
+func (s *Server) Run() error {
 	srv := &http.Server{
 		Addr:    s.cfg.HTTPAddr,
-		Handler: nil, // default mux
+		Handler: s.mux,
+	}
+	// open rate streams never go idle; end them so Shutdown can finish
+	srv.RegisterOnShutdown(s.streams.close)
+
+	// background access-log summaries for sampled-away entries
+	s.life.Loop("access_log_summary", func(ctx context.Context) {
+		s.accessLog.run(ctx, s.log, s.cfg.AccessLogSummaryInterval)
+	})
+	if s.alerts != nil {
+		s.life.Loop("rate_alerts", s.alerts.Run)
+	}
+	if s.hot != nil {
+		s.life.Loop("hot_refresh", s.hot.Run)
+	}
+	// prefetch WARMUP_BASES while the listener comes up; failures only log
+	if len(s.cfg.WarmupBases) > 0 {
+		s.life.Loop("warmup", func(ctx context.Context) {
+			ctx, cancel := context.WithTimeout(ctx, warmupTimeout)
+			defer cancel()
+			_ = s.Warmup(ctx, s.cfg.WarmupBases)
+		})
+	}
+	if s.cfg.DebugEndpoints {
+		var dbg *http.Server
+		s.life.Add("debug_endpoints", func(context.Context) error {
+			var err error
+			if dbg, err = s.startDebug(); err != nil {
+				return fmt.Errorf("debug listener on %s: %w", s.cfg.DebugAddr, err)
+			}
+			return nil
+		}, func(context.Context) error {
+			// profiles in flight are cut short
+			return dbg.Close()
+		})
+	}
+	if err := s.life.Start(context.Background()); err != nil {
+		return err
 	}
 
 	// start server
@@ -93,13 +300,15 @@ func (s *Server) Run() error {
 		// attempt graceful shutdown with timeout
 		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 		defer cancel()
-		if err := srv.Shutdown(ctx); err != nil {
+		err := errors.Join(srv.Shutdown(ctx), s.life.Stop(ctx))
+		if err != nil {
 			s.log.WithContext(context.Background()).Errorf("graceful shutdown failed: %v", err)
 			return err
 		}
 		s.log.WithContext(context.Background()).Infof("server gracefully stopped")
 		return nil
 	case err := <-errCh:
+		_ = s.life.Stop(context.Background())
 		if err != nil {
 			s.log.WithContext(context.Background()).Errorf("server error: %v", err)
 			return err
@@ -108,131 +317,228 @@ func (s *Server) Run() error {
 	}
 }
 
+// handle registers h for pattern on s.mux wrapped by the common middlewares,
+// answering 405 to methods not listed. recoverHandler sits right under
+// instrumentHandler so it sees the respWriter and also catches panics in the
+// other middlewares.
+func (s *Server) handle(pattern string, h http.HandlerFunc, methods ...string) {
+	s.mux.HandleFunc(pattern, s.instrumentHandler(s.recoverHandler(s.allowMethods(methods,
+		s.compress(pattern, s.shed(pattern, s.withTimeout(pattern, s.authenticate(h))))))))
+}
+
 func (s *Server) instrumentHandler(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
 		ctx, end := s.log.StartSpan(r.Context(), r.URL.Path)
-		defer end()
+		timing := newTimingRecorder(start, trace.SpanFromContext(ctx))
+		w.Header().Set(requestIDHeader, requestID(trace.SpanFromContext(ctx).SpanContext()))
 		// pass context with span to request handlers
-		r = r.WithContext(ctx)
+		extra := &accessFields{}
+		r = r.WithContext(withAccessFields(withTiming(ctx, timing), extra))
 		rw := &respWriter{ResponseWriter: w,
-			status: http.StatusOK,
+			status:     http.StatusOK,
+			timing:     timing,
+			emitTiming: s.cfg.ServerTiming,
 		}
+		// the route, not the path, so probes of unknown paths all count as "/"
+		route := r.Pattern
+		if route == "" {
+			route = r.URL.Path
+		}
+		done := s.metrics.start(ctx, r.Method, route)
+		defer func() {
+			done()
+			end()
+			if rw.panicked {
+				// export the failed request's span and logs now, in case a
+				// later panic takes the process down
+				go func() { _ = s.log.Flush(context.Background()) }()
+			}
+		}()
 
 		next(rw, r)
+		if !rw.wroteHeader {
+			rw.WriteHeader(http.StatusOK)
+		}
 
 		duration := time.Since(start)
 
+		s.metrics.record(ctx, r.Method, route, rw.status, rw.size, duration)
+		s.stats.record(route, rw.status)
+		xc := rw.Header().Get("X-Cache")
+		if xc != "" {
+			s.hits.record(xc == "HIT")
+		}
+		logIt, sampled := s.accessLog.admit(r.URL.Path, route, rw.status, duration)
+		if !logIt {
+			return
+		}
+
 		// structured access log
-		entry := s.log.WithContext(ctx).WithFields(map[string]any{
+		fields := map[string]any{
 			"method":   r.Method,
 			"path":     r.URL.Path,
 			"status":   rw.status,
 			"duration": duration.Seconds(),
 			"size":     rw.size,
-		})
+		}
+		if rw.uncompressed > 0 {
+			fields["uncompressed_size"] = rw.uncompressed
+		}
 
-		// add trace_id/span_id if present
-		span := s.log.WithContext(ctx)
-		if v, ok := span.Data["trace_id"]; ok {
-			entry = entry.WithField("trace_id", v)
+		// add trace_id/span_id straight from the span context; the span
+		// fields hook only fills them when the entry fires.
+		if sc := trace.SpanFromContext(ctx).SpanContext(); sc.IsValid() {
+			fields["trace_id"] = sc.TraceID().String()
+			fields["span_id"] = sc.SpanID().String()
 		}
-		if v, ok := span.Data["span_id"]; ok {
-			entry = entry.WithField("span_id", v)
+		if sampled > 0 {
+			fields["sample_rate"] = sampled
 		}
+		if xc != "" {
+			fields["cache_hit"] = xc == "HIT"
+		}
+		if p := rw.Header().Get(providerHeader); p != "" {
+			fields["provider"] = p
+		}
+		extra.copyTo(fields)
 
-		entry.Info("access")
+		s.log.WithContext(ctx).WithFields(fields).Info("access")
 	}
 }
 
+// healthParams are the query parameters of handleHealth.
+var healthParams = queryParams{"verbose"}
+
 func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	if verbose, _ := strconv.ParseBool(r.URL.Query().Get("verbose")); verbose {
+		s.handleHealthVerbose(w, r)
+		return
+	}
 	w.Header().Set("Content-Type", "application/json")
 	w.Write([]byte(`{"status":"ok"}`))
 }
 
+// handleVersion reports the running build.
+func (s *Server) handleVersion(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, version.Get(s.cfg))
+}
+
+// convertParams are the query parameters of handleConvert, including those
+// read by the fee waiver, the cache policy and the provider override.
+var convertParams = queryParams{"from", "to", "amount", "unit", "include_fee", "dry_run", "provider", "format"}
+
+// handleConvert converts one amount. The response is JSON, the net result
+// alone (txt) or a CSV row under a header row (csv).
 func (s *Server) handleConvert(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
-	from := r.URL.Query().Get("from")
-	to := r.URL.Query().Get("to")
-	amountStr := r.URL.Query().Get("amount")
-	if from == "" || to == "" || amountStr == "" {
-		http.Error(w, "missing parameters", http.StatusBadRequest)
+	format, ok := negotiateFormat(w, r, formatJSON, formatTxt, formatCSV)
+	if !ok {
 		return
 	}
-	// amount can be integer cents (1000 => 10.00) or decimal units (10.00)
-	var amountInt int64
-	if strings.Contains(amountStr, ".") {
-		f, err := strconv.ParseFloat(amountStr, 64)
-		if err != nil {
-			http.Error(w, "invalid amount", http.StatusBadRequest)
-			return
-		}
-
-		amountInt = int64(math.Round(f * 100.0))
-	} else {
-		ai, err := strconv.ParseInt(amountStr, 10, 64)
-		if err != nil {
-			http.Error(w, "invalid amount", http.StatusBadRequest)
-			return
-		}
-
-		amountInt = ai
+	if format == formatTxt {
+		w = &textErrorWriter{w}
 	}
-
-	// normalize cache key to use integer cents to avoid duplicates
-	key := "convert:" + from + ":" + to + ":" + strconv.FormatInt(amountInt, 10)
-	if val, err := s.cache.Get(ctx, key); err == nil && val != "" {
-		w.Header().Set("Content-Type", "application/json")
-		w.Write([]byte(val))
+	req := exchange.ConvertRequest{
+		From:   r.URL.Query().Get("from"),
+		To:     r.URL.Query().Get("to"),
+		Amount: r.URL.Query().Get("amount"),
+		Unit:   r.URL.Query().Get("unit"),
+	}
+	svc, provName, ok := s.conversionService(w, r)
+	if !ok {
 		return
 	}
-	resCents, err := s.prov.Convert(ctx, from, to, amountInt)
+	if provName != "" {
+		w.Header().Set(providerHeader, provName)
+	}
+	if _, err := svc.Validate(req); err != nil {
+		writeValidationError(w, err)
+		return
+	}
+
+	waiveFee, err := requestFeeWaiver(r)
 	if err != nil {
-		// if upstream complains about missing API key, return a clearer status
-		if mae, ok := err.(interface{ Error() string }); ok {
-			// check error message content for the specific MissingAPIKeyError
-			if _, isMissing := err.(provider.MissingAPIKeyError); isMissing {
-				s.log.Errorf("provider missing API key: %v", err)
-				http.Error(w, "exchange provider requires an API key. Set EXCHANGE_API_KEY.", http.StatusBadGateway)
-				return
-			}
-			_ = mae
+		var ferr *feeWaiverError
+		if !errors.As(err, &ferr) {
+			ferr = &feeWaiverError{http.StatusBadRequest, errCodeInvalidParameter, err.Error()}
 		}
-		s.log.Errorf("provider error: %v", err)
-		http.Error(w, "provider error: "+err.Error(), http.StatusInternalServerError)
+		writeError(w, ferr.status, ferr.code, ferr.msg, nil)
 		return
 	}
+	req.WaiveFee = waiveFee
+	s.responseCachePolicy(r).apply(&req)
 
-	// apply fee (if configured)
-	var feePct float64
-	if s.fee != nil {
-		feePct, _ = s.fee.FeePercent(from, to)
+	c, err := svc.Convert(ctx, req)
+	if err != nil {
+		s.writeConvertError(ctx, w, req.From, req.To, err)
+		return
 	}
 
-	// feeAmt in cents
-	feeAmt := int64(math.Round(float64(resCents) * feePct))
-
-	netCents := resCents - feeAmt
+	if c.FromCache && !c.FetchedAt.IsZero() {
+		addAccessFields(ctx, map[string]any{"cache_age": c.RateAge.Seconds()})
+	}
+	body := conversionBody(c)
+	if provName != "" {
+		body["provider"] = provName
+	}
+	etag := conversionETag(body, format)
+	w.Header().Set("ETag", etag)
+	tenant, _ := fee.TenantFromContext(ctx)
+	w.Header().Set("Cache-Control", conversionCacheControl(c, time.Now(), waiveFee || tenant.Plan != ""))
+	w.Header().Set("X-Cache", strings.ToUpper(cacheStatus(c.CacheHit)))
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
 
-	out := map[string]any{"from": from,
-		"to": to, "amount_cents": amountInt,
-		"result_cents":     resCents,
-		"result":           float64(resCents) / 100.0,
-		"fee_percent":      feePct,
-		"fee_amount_cents": feeAmt,
-		"net_result_cents": netCents,
-		"net_result":       float64(netCents) / 100.0,
+	switch format {
+	case formatTxt:
+		writeText(w, http.StatusOK, netResultText(c.NetResultCents()))
+	case formatCSV:
+		writeCSV(w, http.StatusOK, conversionColumns, [][]string{conversionRow(body)})
+	default:
+		b, _ := json.Marshal(body)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(b)
 	}
+}
 
-	b, _ := json.Marshal(out)
+// convertErrorStatus is the response status for a failed conversion.
+func convertErrorStatus(err *exchange.Error) int {
+	switch err.Kind {
+	case exchange.KindInvalid:
+		return validationStatus(err.Err)
+	case exchange.KindUnsupported:
+		return http.StatusBadRequest
+	case exchange.KindUnavailable:
+		return http.StatusBadGateway
+	case exchange.KindTimeout:
+		return http.StatusGatewayTimeout
+	default:
+		return http.StatusInternalServerError
+	}
+}
 
-	// avoid caching zero results which are likely from a failed provider call
-	if resCents == 0 {
-		s.log.Errorf("not caching zero conversion result for %s->%s amount=%d", from, to, amountInt)
+// writeConvertError logs a conversion failure and writes its error response.
+// The provider or fee API error itself only goes to the logs.
+func (s *Server) writeConvertError(ctx context.Context, w http.ResponseWriter, from, to string, err error) {
+	var cerr *exchange.Error
+	if !errors.As(err, &cerr) {
+		cerr = exchange.Classify(from, to, err)
+	}
+	status := convertErrorStatus(cerr)
+	if status >= http.StatusInternalServerError {
+		s.log.ErrorCtx(ctx, cerr.Err, "convert failed", map[string]any{"from": from, "to": to, "code": cerr.Code})
 	} else {
-		s.cache.Set(ctx, key, string(b), s.cfg.CacheTTL)
+		s.log.WithContext(ctx).Warnf("convert %s->%s rejected code=%s: %v", from, to, cerr.Code, cerr.Err)
 	}
+	writeError(w, status, cerr.Code, cerr.Message, nil)
+}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.Write(b)
+func cacheStatus(hit bool) string {
+	if hit {
+		return "hit"
+	}
+	return "miss"
 }
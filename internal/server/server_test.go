@@ -4,15 +4,118 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/thiagozs/go-exchange/internal/config"
+	"github.com/thiagozs/go-exchange/internal/exchange"
+	"github.com/thiagozs/go-exchange/internal/fee"
+	"github.com/thiagozs/go-exchange/internal/httpclient"
 	"github.com/thiagozs/go-exchange/internal/logger"
+	"github.com/thiagozs/go-exchange/internal/provider"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 )
 
+// newTestServer builds a server, failing the test on configuration errors.
+func newTestServer(t *testing.T, cfg *config.Config, lg *logger.Logger) *Server {
+	t.Helper()
+	srv, err := New(cfg, lg)
+	if err != nil {
+		t.Fatalf("new server: %v", err)
+	}
+	return srv
+}
+
+// useDeps swaps in test doubles for the provider and cache of srv (nil keeps
+// the current one) and rebuilds the conversion service around them, with fp
+// as fee provider (nil: the configured one).
+func useDeps(srv *Server, prov provider.Provider, c provider.Cache, fp fee.Provider) {
+	if prov != nil {
+		srv.prov = prov
+	}
+	if c != nil {
+		srv.cache = c
+	}
+	if fp == nil {
+		fp, _ = newFeeProvider(srv.cfg, srv.log)
+	}
+	srv.fee = fp
+	srv.svc = exchange.New(srv.cfg, srv.prov, srv.cache, fp, srv.log)
+	srv.streams.svc = srv.svc
+}
+
+// newKeyedTestServer builds a server for cfg with the API keys in keys
+// ("name:key:perm,..."), prov as provider (nil: the configured one) and an
+// in-memory cache, which it returns. HTTPAddr and CacheTTL get defaults.
+func newKeyedTestServer(t *testing.T, cfg *config.Config, keys string, prov provider.Provider) (*Server, *memCache) {
+	t.Helper()
+	if err := cfg.APIKeys.UnmarshalText([]byte(keys)); err != nil {
+		t.Fatalf("parse keys: %v", err)
+	}
+	if cfg.HTTPAddr == "" {
+		cfg.HTTPAddr = ":0"
+	}
+	if cfg.CacheTTL == 0 {
+		cfg.CacheTTL = time.Minute
+	}
+	lg := logger.New(logger.Options{Format: "text", Level: "info", Out: &bytes.Buffer{}})
+	srv := newTestServer(t, cfg, lg)
+	c := newMemCache()
+	useDeps(srv, prov, c, nil)
+	return srv, c
+}
+
+// doAs serves a request without body through the routes of srv, sent with
+// key as X-API-Key (none when empty).
+func doAs(srv *Server, key, method, target string) *httptest.ResponseRecorder {
+	return sendAs(srv, key, httptest.NewRequest(method, target, nil))
+}
+
+// sendAs is doAs for a request built by the caller, to set a body or headers.
+func sendAs(srv *Server, key string, req *http.Request) *httptest.ResponseRecorder {
+	if key != "" {
+		req.Header.Set("X-API-Key", key)
+	}
+	w := httptest.NewRecorder()
+	srv.mux.ServeHTTP(w, req)
+	return w
+}
+
+func TestNewRejectsUnknownProvider(t *testing.T) {
+	lg := logger.New(logger.Options{Format: "text", Level: "info", Out: &bytes.Buffer{}})
+	if _, err := New(&config.Config{HTTPAddr: ":0", Provider: "exchangeratehost"}, lg); err == nil ||
+		!strings.Contains(err.Error(), `unknown exchange provider "exchangeratehost"`) {
+		t.Fatalf("expected startup to fail on an unknown provider, got %v", err)
+	}
+}
+
+func TestNewRejectsRestrictedOutboundURLs(t *testing.T) {
+	lg := logger.New(logger.Options{Format: "text", Level: "info", Out: &bytes.Buffer{}})
+	for name, cfg := range map[string]*config.Config{
+		"FEE_API_URL": {HTTPAddr: ":0", FeeAPIURL: "https://169.254.169.254/fee"},
+		"RATE_ALERTS": {HTTPAddr: ":0", RateAlerts: config.RateAlerts{
+			{Pair: "USD-BRL", Direction: "above", Threshold: 6, WebhookURL: "https://10.0.0.8/hook"},
+		}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := New(cfg, lg)
+			var perr *httpclient.PolicyError
+			if !errors.As(err, &perr) || !strings.Contains(err.Error(), name) {
+				t.Fatalf("expected startup to fail on %s, got %v", name, err)
+			}
+		})
+	}
+}
+
 type mockProv struct{}
 
 func (m *mockProv) Convert(ctx context.Context, from, to string, amount int64) (int64, error) {
@@ -23,17 +126,27 @@ func (m *mockProv) Convert(ctx context.Context, from, to string, amount int64) (
 type stubCache struct{}
 
 func (s *stubCache) Get(ctx context.Context, key string) (string, error) { return "", nil }
+func (s *stubCache) GetWithTTL(ctx context.Context, key string) (string, time.Duration, error) {
+	return "", 0, nil
+}
 func (s *stubCache) Set(ctx context.Context, key string, value string, ttl time.Duration) error {
 	return nil
 }
+func (s *stubCache) GetOrSet(ctx context.Context, key string, ttl time.Duration, fill func(ctx context.Context) (string, error)) (string, error) {
+	return fill(ctx)
+}
+func (s *stubCache) Delete(ctx context.Context, key string) error { return nil }
+func (s *stubCache) DeleteByPrefix(ctx context.Context, prefix string) (int64, error) {
+	return 0, nil
+}
 
 func TestHandleConvert(t *testing.T) {
 	cfg := &config.Config{HTTPAddr: ":0", RedisAddr: "", RedisDB: 0, CacheTTL: 0}
 	var buf bytes.Buffer
 	lg := logger.New(logger.Options{Format: "text", Level: "debug", Out: &buf})
-	srv := New(cfg, lg)
+	srv := newTestServer(t, cfg, lg)
 	// inject mocks
-	srv.prov = &mockProv{}
+	useDeps(srv, &mockProv{}, nil, nil)
 	// create request
 	// amount=1000 (10.00 units)
 	req := httptest.NewRequest("GET", "/convert?from=USD&to=BRL&amount=1000", nil)
@@ -51,3 +164,316 @@ func TestHandleConvert(t *testing.T) {
 		t.Fatalf("expected result 200.0 got %v", out["result"])
 	}
 }
+
+func TestHandleConvertAmountUnit(t *testing.T) {
+	lg := logger.New(logger.Options{Format: "text", Level: "debug", Out: &bytes.Buffer{}})
+	srv := newTestServer(t, &config.Config{HTTPAddr: ":0", AmountUnitRequired: true}, lg)
+	useDeps(srv, &mockProv{}, nil, nil)
+
+	get := func(query string) (int, map[string]any) {
+		w := httptest.NewRecorder()
+		srv.handleConvert(w, httptest.NewRequest("GET", "/convert?from=USD&to=BRL&"+query, nil))
+		var out map[string]any
+		_ = json.NewDecoder(w.Body).Decode(&out)
+		return w.Code, out
+	}
+	code, out := get("amount=10.50&unit=major")
+	if code != http.StatusOK || out["amount_cents"] != 1050.0 || out["unit"] != "major" {
+		t.Fatalf("expected 1050 cents read as major, got %d %v", code, out)
+	}
+	code, out = get("amount=1000")
+	if errBody, _ := out["error"].(map[string]any); code != http.StatusBadRequest || errBody["code"] != "MISSING_UNIT" {
+		t.Fatalf("expected MISSING_UNIT without a unit, got %d %v", code, out)
+	}
+}
+
+// staleProv reports its result as built from a stale rate.
+type staleProv struct{ ts time.Time }
+
+func (p *staleProv) Convert(ctx context.Context, from, to string, amount int64) (int64, error) {
+	return 20000, nil
+}
+
+func (p *staleProv) ConvertDetailed(ctx context.Context, from, to string, amount int64) (provider.ConvertResult, error) {
+	return provider.ConvertResult{ResultCents: 20000, RateTimestamp: p.ts, Stale: true}, nil
+}
+
+func TestHandleConvertFlagsStaleRates(t *testing.T) {
+	lg := logger.New(logger.Options{Format: "text", Level: "debug", Out: &bytes.Buffer{}})
+	srv := newTestServer(t, &config.Config{HTTPAddr: ":0", CacheTTL: time.Minute}, lg)
+	useDeps(srv, &staleProv{ts: time.Date(2025, 9, 19, 12, 0, 0, 0, time.UTC)}, newMemCache(), nil)
+
+	w := httptest.NewRecorder()
+	srv.handleConvert(w, httptest.NewRequest("GET", "/convert?from=USD&to=BRL&amount=1000", nil))
+	var out map[string]any
+	if err := json.NewDecoder(w.Result().Body).Decode(&out); err != nil {
+		t.Fatalf("decode err: %v", err)
+	}
+	if out["stale"] != true || out["rate_timestamp"] != "2025-09-19T12:00:00Z" {
+		t.Fatalf("expected stale flag and rate timestamp, got %v", out)
+	}
+}
+
+// metaProv reports full rate metadata.
+type metaProv struct{ ts time.Time }
+
+func (p *metaProv) Convert(ctx context.Context, from, to string, amount int64) (int64, error) {
+	return 5000, nil
+}
+
+func (p *metaProv) ConvertDetailed(ctx context.Context, from, to string, amount int64) (provider.ConvertResult, error) {
+	return provider.ConvertResult{ResultCents: 5000, Rate: 5, RateTimestamp: p.ts, Source: "bcb", CacheHit: false}, nil
+}
+
+func TestHandleConvertIncludesRateMetadata(t *testing.T) {
+	lg := logger.New(logger.Options{Format: "text", Level: "debug", Out: &bytes.Buffer{}})
+	srv := newTestServer(t, &config.Config{HTTPAddr: ":0", CacheTTL: time.Minute}, lg)
+	useDeps(srv, &metaProv{ts: time.Date(2025, 9, 19, 13, 9, 0, 0, time.FixedZone("BRT", -3*60*60))}, newMemCache(), nil)
+
+	// the second request is served from the response cache
+	for _, wantCache := range []string{"miss", "hit"} {
+		w := httptest.NewRecorder()
+		srv.handleConvert(w, httptest.NewRequest("GET", "/convert?from=USD&to=BRL&amount=1000", nil))
+		var out map[string]any
+		if err := json.NewDecoder(w.Result().Body).Decode(&out); err != nil {
+			t.Fatalf("decode err: %v", err)
+		}
+		if out["rate"] != 5.0 || out["rate_timestamp"] != "2025-09-19T16:09:00Z" || out["source"] != "bcb" || out["cache"] != wantCache {
+			t.Fatalf("expected rate metadata with cache=%s, got %v", wantCache, out)
+		}
+		if _, ok := out["stale"]; ok {
+			t.Fatalf("fresh rates must not be flagged stale, got %v", out)
+		}
+	}
+}
+
+func TestHandleConvertWithStaticProvider(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rates.json")
+	if err := os.WriteFile(path, []byte(`{"USD":{"BRL":5.43}}`), 0o600); err != nil {
+		t.Fatalf("write rates: %v", err)
+	}
+	lg := logger.New(logger.Options{Format: "text", Level: "info", Out: &bytes.Buffer{}})
+	srv := newTestServer(t, &config.Config{HTTPAddr: ":0", Provider: "static", StaticRatesPath: path}, lg)
+	useDeps(srv, nil, newMemCache(), nil)
+
+	w := httptest.NewRecorder()
+	srv.handleConvert(w, httptest.NewRequest("GET", "/convert?from=USD&to=BRL&amount=1000", nil))
+	var out map[string]any
+	if err := json.NewDecoder(w.Result().Body).Decode(&out); err != nil {
+		t.Fatalf("decode err: %v", err)
+	}
+	if out["result_cents"] != 5430.0 || out["source"] != "static" {
+		t.Fatalf("unexpected response %v", out)
+	}
+
+	w = httptest.NewRecorder()
+	srv.handleConvert(w, httptest.NewRequest("GET", "/convert?from=USD&to=XYZ&amount=1000", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an unsupported currency, got %d", w.Code)
+	}
+}
+
+func TestAccessLogIncludesTraceIDs(t *testing.T) {
+	exp := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exp))
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(tp)
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prev)
+		_ = tp.Shutdown(context.Background())
+	})
+
+	cfg := &config.Config{HTTPAddr: ":0", CacheTTL: 0}
+	var buf bytes.Buffer
+	lg := logger.New(logger.Options{Format: "json", Level: "info", Out: &buf})
+	srv := newTestServer(t, cfg, lg)
+
+	h := srv.instrumentHandler(srv.handleHealth)
+	w := httptest.NewRecorder()
+	h(w, httptest.NewRequest("GET", "/health", nil))
+
+	var access map[string]any
+	for _, line := range bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n")) {
+		var m map[string]any
+		if err := json.Unmarshal(line, &m); err != nil {
+			t.Fatalf("invalid json log line %q: %v", line, err)
+		}
+		if m["msg"] == "access" {
+			access = m
+		}
+	}
+	if access == nil {
+		t.Fatalf("access log entry not found in: %s", buf.String())
+	}
+
+	traceID, _ := access["trace_id"].(string)
+	if traceID == "" || traceID == strings.Repeat("0", 32) {
+		t.Fatalf("expected non-zero trace_id, got %v", access["trace_id"])
+	}
+	spans := exp.GetSpans()
+	if len(spans) != 1 || spans[0].SpanContext.TraceID().String() != traceID {
+		t.Fatalf("trace_id %s does not match recorded span %v", traceID, spans)
+	}
+	if _, ok := access["status"].(float64); !ok {
+		t.Fatalf("expected numeric status, got %T", access["status"])
+	}
+	if _, ok := access["duration"].(float64); !ok {
+		t.Fatalf("expected numeric duration, got %T", access["duration"])
+	}
+}
+
+func TestHandleConvertXCacheHeader(t *testing.T) {
+	cases := []struct {
+		name  string
+		cache provider.Cache
+		want  []string
+	}{
+		{name: "uncached", cache: &stubCache{}, want: []string{"MISS", "MISS"}},
+		{name: "cached", cache: newMemCache(), want: []string{"MISS", "HIT"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			lg := logger.New(logger.Options{Format: "json", Level: "info", Out: &buf})
+			srv := newTestServer(t, &config.Config{HTTPAddr: ":0", CacheTTL: time.Minute}, lg)
+			useDeps(srv, &mockProv{}, tc.cache, nil)
+			h := srv.instrumentHandler(srv.handleConvert)
+
+			for i, want := range tc.want {
+				w := httptest.NewRecorder()
+				h(w, httptest.NewRequest("GET", "/convert?from=USD&to=BRL&amount=1000", nil))
+				if got := w.Header().Get("X-Cache"); got != want {
+					t.Fatalf("request %d: expected X-Cache %s, got %q", i, want, got)
+				}
+				var body map[string]any
+				if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+					t.Fatalf("decode: %v", err)
+				}
+				if body["cache"] != strings.ToLower(want) {
+					t.Fatalf("request %d: body cache %v does not match header %s", i, body["cache"], want)
+				}
+			}
+
+			var hits []any
+			for _, line := range bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n")) {
+				var m map[string]any
+				if err := json.Unmarshal(line, &m); err == nil && m["msg"] == "access" {
+					hits = append(hits, m["cache_hit"])
+				}
+			}
+			if len(hits) != len(tc.want) {
+				t.Fatalf("expected %d access entries, got %d", len(tc.want), len(hits))
+			}
+			for i, want := range tc.want {
+				if hits[i] != (want == "HIT") {
+					t.Fatalf("access entry %d: expected cache_hit=%t, got %v", i, want == "HIT", hits[i])
+				}
+			}
+		})
+	}
+}
+
+func TestNewPrefersPerPairFees(t *testing.T) {
+	lg := logger.New(logger.Options{Format: "text", Level: "info", Out: &bytes.Buffer{}})
+	cfg := &config.Config{HTTPAddr: ":0", FeePercent: 0.05, Fees: config.FeeRules{"USD-BRL": 0.012}}
+	fp, err := newFeeProvider(cfg, lg)
+	if _, ok := fp.(*fee.ConfigFeeProvider); err != nil || !ok {
+		t.Fatalf("expected EXCHANGE_FEES to take precedence over EXCHANGE_FEE_PERCENT, got %T", fp)
+	}
+}
+
+func TestHandleConvertReportsFeeTier(t *testing.T) {
+	lg := logger.New(logger.Options{Format: "text", Level: "info", Out: &bytes.Buffer{}})
+	cfg := &config.Config{HTTPAddr: ":0", FeeTiers: config.FeeTiers{Tiers: []config.FeeTier{
+		{Name: "small", FromCents: 0, Percent: 0.01},
+		{Name: "medium", FromCents: 20000, Percent: 0.006},
+	}}}
+	srv := newTestServer(t, cfg, lg)
+	useDeps(srv, &mockProv{}, nil, nil) // 20000 cents: exactly at the medium threshold
+
+	w := httptest.NewRecorder()
+	srv.handleConvert(w, httptest.NewRequest("GET", "/convert?from=USD&to=BRL&amount=1000", nil))
+	var out map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	tier, _ := out["fee_tier"].(map[string]any)
+	if out["fee_percent"] != 0.006 || tier["name"] != "medium" || tier["from_cents"] != float64(20000) || out["fee_amount_cents"] != float64(120) {
+		t.Fatalf("expected the medium tier on the gross converted amount, got %v", out)
+	}
+}
+
+func TestHandleConvertBreaksDownCappedFee(t *testing.T) {
+	lg := logger.New(logger.Options{Format: "text", Level: "info", Out: &bytes.Buffer{}})
+	cfg := &config.Config{HTTPAddr: ":0", FeePercent: 0.009,
+		FeeLimits: config.FeeLimits{config.FeeDefault: {FixedCents: 10, MinCents: 500, MaxCents: 15000}}}
+	srv := newTestServer(t, cfg, lg)
+	useDeps(srv, &mockProv{}, nil, nil) // 20000 cents gross
+
+	w := httptest.NewRecorder()
+	srv.handleConvert(w, httptest.NewRequest("GET", "/convert?from=USD&to=BRL&amount=1000", nil))
+	var out map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	// 180 (0.9%) + 10 fixed is below the 500 minimum
+	if out["fee_percent_cents"] != float64(180) || out["fee_fixed_cents"] != float64(10) ||
+		out["fee_amount_cents"] != float64(500) || out["fee_clamped"] != "min" ||
+		out["fee_min_cents"] != float64(500) || out["net_result_cents"] != float64(19500) {
+		t.Fatalf("unexpected fee breakdown %v", out)
+	}
+}
+
+func TestHandleConvertFeeUnavailable(t *testing.T) {
+	feeSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "down", http.StatusServiceUnavailable)
+	}))
+	defer feeSrv.Close()
+
+	for _, failOpen := range []bool{true, false} {
+		lg := logger.New(logger.Options{Format: "text", Level: "info", Out: &bytes.Buffer{}})
+		srv := newTestServer(t, &config.Config{HTTPAddr: ":0", FeeFailOpen: failOpen}, lg)
+		useDeps(srv, &mockProv{}, nil, fee.NewFeeAPIProvider(feeSrv.URL, nil, fee.FeeAPIOptions{MaxRetries: 1, Backoff: time.Millisecond}, lg))
+
+		w := httptest.NewRecorder()
+		srv.handleConvert(w, httptest.NewRequest("GET", "/convert?from=USD&to=BRL&amount=1000", nil))
+		if !failOpen {
+			if w.Code != http.StatusBadGateway || decodeError(t, w).Code != exchange.CodeFeeUnavailable {
+				t.Fatalf("fail closed: expected 502 FEE_UNAVAILABLE, got %d %s", w.Code, w.Body.String())
+			}
+			continue
+		}
+		var out map[string]any
+		if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil {
+			t.Fatalf("fail open: expected a conversion, got %d %s", w.Code, w.Body.String())
+		}
+		if out["fee_amount_cents"] != float64(0) || out["fee_unavailable"] != true {
+			t.Fatalf("fail open: expected a zero fee, got %v", out)
+		}
+	}
+}
+
+func TestHandleVersion(t *testing.T) {
+	lg := logger.New(logger.Options{Format: "text", Level: "info", Out: &bytes.Buffer{}})
+	srv := newTestServer(t, &config.Config{HTTPAddr: ":0", AppName: "go-exchange", AppVersion: "1.0.0", Provider: "static"}, lg)
+
+	w := httptest.NewRecorder()
+	srv.handleVersion(w, httptest.NewRequest("GET", "/version", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	var out map[string]string
+	if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil {
+		t.Fatalf("decode: %v (%s)", err, w.Body.String())
+	}
+	want := map[string]string{"app": "go-exchange", "version": "1.0.0", "commit": "unknown",
+		"build_date": "unknown", "go_version": runtime.Version(), "provider": "static"}
+	if len(out) != len(want) {
+		t.Fatalf("unexpected fields: %v", out)
+	}
+	for k, v := range want {
+		if out[k] != v {
+			t.Fatalf("%s: expected %q, got %q", k, v, out[k])
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package server
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/thiagozs/go-exchange/internal/config"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// shedExempt are the routes served even when the server is saturated:
+// probes must keep answering so a busy instance is not restarted, and rate
+// streams are long-lived and bounded by STREAM_MAX_SUBSCRIBERS instead.
+var shedExempt = map[string]bool{
+	"/health":       true,
+	"/ready":        true,
+	"/stream/rates": true,
+}
+
+// loadShedder bounds the requests served at once so a burst on a cold cache
+// cannot fan out into an unbounded number of provider calls. Requests beyond
+// the limit wait in a bounded queue for up to maxWait.
+type loadShedder struct {
+	slots     chan struct{}
+	maxQueued int64
+	maxWait   time.Duration
+
+	inflight atomic.Int64
+	queued   atomic.Int64
+
+	// gauges are registered on first use, like httpMetrics
+	gauges sync.Once
+}
+
+// newLoadShedder returns nil when MAX_INFLIGHT_REQUESTS is 0.
+func newLoadShedder(cfg *config.Config) *loadShedder {
+	if cfg.MaxInflightRequests <= 0 {
+		return nil
+	}
+	return &loadShedder{slots: make(chan struct{}, cfg.MaxInflightRequests),
+		maxQueued: int64(cfg.MaxQueuedRequests), maxWait: cfg.MaxQueueWait}
+}
+
+func (l *loadShedder) initGauges() {
+	l.gauges.Do(func() {
+		meter := otel.GetMeterProvider().Meter(meterName)
+		_, _ = meter.Int64ObservableGauge("http.server.admitted_requests",
+			metric.WithDescription("HTTP requests holding a MAX_INFLIGHT_REQUESTS slot"),
+			metric.WithUnit("{request}"),
+			metric.WithInt64Callback(func(_ context.Context, o metric.Int64Observer) error {
+				o.Observe(l.inflight.Load())
+				return nil
+			}),
+		)
+		_, _ = meter.Int64ObservableGauge("http.server.queued_requests",
+			metric.WithDescription("HTTP requests waiting for a free slot"),
+			metric.WithUnit("{request}"),
+			metric.WithInt64Callback(func(_ context.Context, o metric.Int64Observer) error {
+				o.Observe(l.queued.Load())
+				return nil
+			}),
+		)
+	})
+}
+
+// acquire takes a slot, waiting in the queue when none is free. It reports
+// false when the queue is full, the wait expired or ctx was cancelled.
+func (l *loadShedder) acquire(ctx context.Context) bool {
+	l.initGauges()
+	select {
+	case l.slots <- struct{}{}:
+		l.inflight.Add(1)
+		return true
+	default:
+	}
+
+	if l.queued.Add(1) > l.maxQueued {
+		l.queued.Add(-1)
+		return false
+	}
+	defer l.queued.Add(-1)
+	t := time.NewTimer(l.maxWait)
+	defer t.Stop()
+	select {
+	case l.slots <- struct{}{}:
+		l.inflight.Add(1)
+		return true
+	case <-t.C:
+		return false
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (l *loadShedder) release() {
+	l.inflight.Add(-1)
+	<-l.slots
+}
+
+// retryAfter is the Retry-After value of a shed request, in whole seconds.
+func (l *loadShedder) retryAfter() string {
+	return strconv.Itoa(max(1, int(math.Ceil(l.maxWait.Seconds()))))
+}
+
+// shed applies MAX_INFLIGHT_REQUESTS to the route registered as pattern.
+// Requests that find no slot get 503 with Retry-After.
+func (s *Server) shed(pattern string, next http.HandlerFunc) http.HandlerFunc {
+	if s.shedder == nil || shedExempt[pattern] {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !s.shedder.acquire(r.Context()) {
+			w.Header().Set("Retry-After", s.shedder.retryAfter())
+			writeError(w, http.StatusServiceUnavailable, errCodeOverloaded, "server overloaded, retry later", nil)
+			return
+		}
+		defer s.shedder.release()
+		next(w, r)
+	}
+}
@@ -0,0 +1,131 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/thiagozs/go-exchange/internal/config"
+	"github.com/thiagozs/go-exchange/internal/logger"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// gaugeValue collects the current value of an int64 gauge.
+func gaugeValue(t *testing.T, reader *sdkmetric.ManualReader, name string) int64 {
+	t.Helper()
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatalf("collect: %v", err)
+	}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name == name {
+				return m.Data.(metricdata.Gauge[int64]).DataPoints[0].Value
+			}
+		}
+	}
+	t.Fatalf("%s not recorded", name)
+	return 0
+}
+
+// waitFor polls cond until it holds.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(2 * time.Second); !cond(); {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestShedSaturatedServer(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	useProviders(t, sdktrace.NewTracerProvider(), sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)))
+
+	lg := logger.New(logger.Options{Format: "text", Level: "info", Out: &bytes.Buffer{}})
+	srv := newTestServer(t, &config.Config{HTTPAddr: ":0", MaxInflightRequests: 2, MaxQueuedRequests: 1,
+		MaxQueueWait: 100 * time.Millisecond}, lg)
+
+	unblock := make(chan struct{})
+	slow := srv.shed("/convert", func(w http.ResponseWriter, r *http.Request) {
+		<-unblock
+		writeJSON(w, http.StatusOK, map[string]any{"ok": true})
+	})
+	serve := func(h http.HandlerFunc) chan *httptest.ResponseRecorder {
+		done := make(chan *httptest.ResponseRecorder, 1)
+		go func() {
+			w := httptest.NewRecorder()
+			h(w, httptest.NewRequest("GET", "/convert", nil))
+			done <- w
+		}()
+		return done
+	}
+
+	first, second := serve(slow), serve(slow)
+	waitFor(t, "two requests in flight", func() bool { return srv.shedder.inflight.Load() == 2 })
+	queued := serve(slow)
+	waitFor(t, "a queued request", func() bool { return srv.shedder.queued.Load() == 1 })
+	if n := gaugeValue(t, reader, "http.server.admitted_requests"); n != 2 {
+		t.Fatalf("expected 2 active requests, got %d", n)
+	}
+	if n := gaugeValue(t, reader, "http.server.queued_requests"); n != 1 {
+		t.Fatalf("expected 1 queued request, got %d", n)
+	}
+
+	// the queue is full: rejected right away
+	w := <-serve(slow)
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") != "1" ||
+		decodeError(t, w).Code != errCodeOverloaded {
+		t.Fatalf("expected 503 OVERLOADED with Retry-After, got %d %v: %s", w.Code, w.Header(), w.Body)
+	}
+	// the queued request gives up after MAX_QUEUE_WAIT
+	if w := <-queued; w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected the queued request to time out, got %d", w.Code)
+	}
+
+	// probes are never shed
+	health := httptest.NewRecorder()
+	srv.shed("/health", srv.handleHealth)(health, httptest.NewRequest("GET", "/health", nil))
+	if health.Code != http.StatusOK {
+		t.Fatalf("expected /health to bypass the limiter, got %d", health.Code)
+	}
+
+	// a queued request gets the slot of a finished one
+	queued = serve(slow)
+	waitFor(t, "a queued request", func() bool { return srv.shedder.queued.Load() == 1 })
+	unblock <- struct{}{}
+	waitFor(t, "the queued request to start", func() bool { return srv.shedder.queued.Load() == 0 })
+	close(unblock)
+	for _, done := range []chan *httptest.ResponseRecorder{first, second, queued} {
+		if w := <-done; w.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d", w.Code)
+		}
+	}
+
+	// recovered: all slots are free again
+	if w := <-serve(slow); w.Code != http.StatusOK {
+		t.Fatalf("expected 200 after recovery, got %d", w.Code)
+	}
+	if n := gaugeValue(t, reader, "http.server.admitted_requests"); n != 0 {
+		t.Fatalf("expected no active request, got %d", n)
+	}
+}
+
+func TestShedDisabled(t *testing.T) {
+	lg := logger.New(logger.Options{Format: "text", Level: "info", Out: &bytes.Buffer{}})
+	srv := newTestServer(t, &config.Config{HTTPAddr: ":0"}, lg)
+	if srv.shedder != nil {
+		t.Fatalf("expected no limiter with MAX_INFLIGHT_REQUESTS=0")
+	}
+	w := httptest.NewRecorder()
+	srv.shed("/convert", srv.handleHealth)(w, httptest.NewRequest("GET", "/convert", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+}
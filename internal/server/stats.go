@@ -0,0 +1,129 @@
+package server
+
+import (
+	"maps"
+	"sync"
+	"time"
+)
+
+// requestStats keeps exact request counters. It is fed by every request,
+// independently of any access-log sampling.
+type requestStats struct {
+	mu       sync.Mutex
+	total    int64
+	statuses map[int]int64
+	paths    map[string]int64
+	// cache bypass usage per API key name and directive
+	bypass map[string]map[string]int64
+}
+
+// statsSnapshot is a point-in-time copy of requestStats.
+type statsSnapshot struct {
+	Total    int64                       `json:"total"`
+	Statuses map[int]int64               `json:"statuses"`
+	Paths    map[string]int64            `json:"paths"`
+	Bypass   map[string]map[string]int64 `json:"cache_bypass"`
+}
+
+func newRequestStats() *requestStats {
+	return &requestStats{
+		statuses: map[int]int64{},
+		paths:    map[string]int64{},
+		bypass:   map[string]map[string]int64{},
+	}
+}
+
+func (s *requestStats) record(path string, status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.total++
+	s.statuses[status]++
+	s.paths[path]++
+}
+
+func (s *requestStats) recordBypass(keyName, directive string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.bypass[keyName] == nil {
+		s.bypass[keyName] = map[string]int64{}
+	}
+	s.bypass[keyName][directive]++
+}
+
+func (s *requestStats) snapshot() statsSnapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	bypass := make(map[string]map[string]int64, len(s.bypass))
+	for k, v := range s.bypass {
+		bypass[k] = maps.Clone(v)
+	}
+	return statsSnapshot{
+		Total:    s.total,
+		Statuses: maps.Clone(s.statuses),
+		Paths:    maps.Clone(s.paths),
+		Bypass:   bypass,
+	}
+}
+
+// hitRatioWindow is how far back the verbose health output looks for the
+// conversion cache hit ratio.
+const hitRatioWindow = 5 * time.Minute
+
+// hitRatio counts conversion cache hits and misses in one-minute buckets
+// covering hitRatioWindow.
+type hitRatio struct {
+	mu      sync.Mutex
+	now     func() time.Time
+	buckets [int(hitRatioWindow / time.Minute)]hitBucket
+}
+
+type hitBucket struct {
+	minute int64
+	hits   int64
+	misses int64
+}
+
+// hitRatioSnapshot is the hit ratio over the window; Ratio is zero without
+// requests.
+type hitRatioSnapshot struct {
+	WindowSeconds float64 `json:"window_seconds"`
+	Hits          int64   `json:"hits"`
+	Misses        int64   `json:"misses"`
+	Ratio         float64 `json:"ratio"`
+}
+
+func newHitRatio() *hitRatio {
+	return &hitRatio{now: time.Now}
+}
+
+func (h *hitRatio) record(hit bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	minute := h.now().Unix() / 60
+	b := &h.buckets[minute%int64(len(h.buckets))]
+	if b.minute != minute {
+		*b = hitBucket{minute: minute}
+	}
+	if hit {
+		b.hits++
+	} else {
+		b.misses++
+	}
+}
+
+func (h *hitRatio) snapshot() hitRatioSnapshot {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	minute := h.now().Unix() / 60
+	out := hitRatioSnapshot{WindowSeconds: hitRatioWindow.Seconds()}
+	for _, b := range h.buckets {
+		if minute-b.minute < int64(len(h.buckets)) {
+			out.Hits += b.hits
+			out.Misses += b.misses
+		}
+	}
+	if total := out.Hits + out.Misses; total > 0 {
+		out.Ratio = float64(out.Hits) / float64(total)
+	}
+	return out
+}
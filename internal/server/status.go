@@ -0,0 +1,66 @@
+package server
+
+import (
+	"cmp"
+	"net/http"
+
+	"github.com/thiagozs/go-exchange/internal/provider"
+)
+
+// statusBody is the body of GET /status.
+type statusBody struct {
+	Provider statusProvider `json:"provider"`
+	// UpstreamQuota is the month's upstream usage of every provider that
+	// counts it, the provider= overrides included.
+	UpstreamQuota []provider.QuotaUsage `json:"upstream_quota"`
+	// HotBases are the base currencies tracked by HOT_REFRESH_THRESHOLD,
+	// with the next refresh of the hot ones.
+	HotBases []provider.HotBase `json:"hot_bases"`
+	// Startup is what the instance was configured to do, when known.
+	Startup *StartupSummary `json:"startup,omitempty"`
+}
+
+// statusProvider is the provider conversions use, and the last switch made
+// with PUT /admin/provider.
+type statusProvider struct {
+	Active     string          `json:"active"`
+	Configured string          `json:"configured"`
+	LastSwitch *providerSwitch `json:"last_switch,omitempty"`
+}
+
+// handleStatus reports the active provider, upstream usage against PROVIDER_MONTHLY_QUOTA, the
+// hot bases and the startup summary. The counters are shared through the
+// cache, so every instance reports the same usage; hot bases are counted per
+// instance.
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	body := statusBody{UpstreamQuota: []provider.QuotaUsage{}, HotBases: []provider.HotBase{}}
+	body.Provider.Active, _ = s.svc.Provider()
+	body.Provider.Configured = cmp.Or(s.cfg.Provider, "exchangerate.host")
+	s.switchMu.Lock()
+	body.Provider.LastSwitch = s.switched
+	s.switchMu.Unlock()
+	if s.hot != nil {
+		body.HotBases = s.hot.Hot()
+	}
+	body.Startup = s.summary
+	seen := map[string]bool{}
+	provs := []provider.Provider{s.prov}
+	for _, name := range s.cfg.ProviderOverrides {
+		if p, ok := s.overrides[name]; ok {
+			provs = append(provs, p)
+		}
+	}
+	for _, p := range provs {
+		qr, ok := provider.As[provider.QuotaReporter](p)
+		if !ok {
+			continue
+		}
+		for _, u := range qr.QuotaUsage(r.Context()) {
+			if !seen[u.Provider] {
+				seen[u.Provider] = true
+				body.UpstreamQuota = append(body.UpstreamQuota, u)
+			}
+		}
+	}
+	writeJSON(w, http.StatusOK, body)
+}
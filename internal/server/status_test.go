@@ -0,0 +1,123 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/thiagozs/go-exchange/internal/config"
+	"github.com/thiagozs/go-exchange/internal/logger"
+	"github.com/thiagozs/go-exchange/internal/provider"
+)
+
+// quotaProv reports a fixed upstream usage.
+type quotaProv struct {
+	mockProv
+	usage provider.QuotaUsage
+}
+
+func (q *quotaProv) QuotaUsage(context.Context) []provider.QuotaUsage {
+	return []provider.QuotaUsage{q.usage}
+}
+
+func TestStatusReportsUpstreamQuota(t *testing.T) {
+	lg := logger.New(logger.Options{Format: "text", Level: "info", Out: &bytes.Buffer{}})
+	srv := newTestServer(t, &config.Config{HTTPAddr: ":0", CacheTTL: time.Minute}, lg)
+	era := provider.QuotaUsage{Provider: "exchangerate-api", Month: "2026-10", Used: 1300, Quota: 1500, SoftLimit: 1200, State: provider.QuotaSoftLimit}
+	useDeps(srv, provider.NewPivotProvider(&quotaProv{usage: era}, "USD"), newMemCache(), nil)
+	bcb := provider.QuotaUsage{Provider: "bcb", Month: "2026-10", Used: 12, State: provider.QuotaOK}
+	// an override of the same provider shares its counter: reported once
+	srv.cfg.ProviderOverrides = []string{"bcb", "dup"}
+	srv.overrides = map[string]provider.Provider{"bcb": &quotaProv{usage: bcb}, "dup": &quotaProv{usage: era}}
+
+	w := httptest.NewRecorder()
+	srv.mux.ServeHTTP(w, httptest.NewRequest("GET", "/status", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body)
+	}
+	var out statusBody
+	if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil {
+		t.Fatal(err)
+	}
+	if len(out.UpstreamQuota) != 2 || out.UpstreamQuota[0] != era || out.UpstreamQuota[1] != bcb {
+		t.Fatalf("unexpected usage %+v", out.UpstreamQuota)
+	}
+	doc := servedOpenAPI(t, srv)
+	assertMatches(t, doc, doc.responseSchema(t, "/status", "GET", "200"), w)
+
+	// providers that do not count report an empty list
+	useDeps(srv, &mockProv{}, newMemCache(), nil)
+	srv.overrides = nil
+	w = httptest.NewRecorder()
+	srv.mux.ServeHTTP(w, httptest.NewRequest("GET", "/status", nil))
+	if strings.TrimSpace(w.Body.String()) != `{"provider":{"active":"exchangerate.host","configured":"exchangerate.host"},"upstream_quota":[],"hot_bases":[]}` {
+		t.Fatalf("expected empty lists, got %s", w.Body)
+	}
+}
+
+// expiringProv caches rates for a minute from every refresh.
+type expiringProv struct{ mockProv }
+
+func (expiringProv) RefreshRates(context.Context, string, time.Duration) (time.Time, error) {
+	return time.Now().Add(time.Minute), nil
+}
+
+func TestStatusReportsHotBases(t *testing.T) {
+	lg := logger.New(logger.Options{Format: "text", Level: "info", Out: &bytes.Buffer{}})
+	srv := newTestServer(t, &config.Config{HTTPAddr: ":0", CacheTTL: time.Minute}, lg)
+	useDeps(srv, &expiringProv{}, newMemCache(), nil)
+	srv.hot = newHotRefresher(&config.Config{HotRefreshThreshold: 1.5, HotRefreshHalfLife: time.Hour,
+		HotRefreshLead: 10 * time.Second, HotRefreshInterval: time.Second}, srv.prov, srv.svc, lg)
+
+	for _, from := range []string{"usd", "USD", "EUR"} {
+		w := httptest.NewRecorder()
+		srv.mux.ServeHTTP(w, httptest.NewRequest("GET", "/convert?from="+from+"&to=BRL&amount=1", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("convert: expected 200, got %d: %s", w.Code, w.Body)
+		}
+	}
+	srv.hot.Tick(context.Background())
+
+	w := httptest.NewRecorder()
+	srv.mux.ServeHTTP(w, httptest.NewRequest("GET", "/status", nil))
+	var out statusBody
+	if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil {
+		t.Fatal(err)
+	}
+	if len(out.HotBases) != 2 || out.HotBases[0].Base != "USD" || !out.HotBases[0].Hot || out.HotBases[0].NextRefresh == nil ||
+		out.HotBases[1].Base != "EUR" || out.HotBases[1].Hot {
+		t.Fatalf("expected USD hot and EUR tracked, got %+v", out.HotBases)
+	}
+	doc := servedOpenAPI(t, srv)
+	assertMatches(t, doc, doc.responseSchema(t, "/status", "GET", "200"), w)
+}
+
+func TestStatusReportsStartupSummary(t *testing.T) {
+	lg := logger.New(logger.Options{Format: "text", Level: "info", Out: &bytes.Buffer{}})
+	srv := newTestServer(t, &config.Config{HTTPAddr: ":0", CacheTTL: time.Minute}, lg)
+	// configured for Redis, but newTestServer opened the in-memory cache
+	sum := srv.SetStartupSummary(StartupSummary{Version: "dev", Provider: SummaryProvider{Name: "bcb"},
+		Cache: SummaryCache{Backend: "redis", Addr: "redis:6379"}, FeeSource: "none",
+		OTelExporters: []SummaryExporter{{Type: "otlp-grpc", Endpoint: "collector:4317", Headers: []string{"authorization"}}},
+		HTTPAddr:      ":0", Features: []string{"gzip"}, Warnings: []string{}})
+	if sum.Cache.Backend != "memory" || sum.Cache.Addr != "" {
+		t.Fatalf("expected the cache actually opened, got %+v", sum.Cache)
+	}
+
+	w := httptest.NewRecorder()
+	srv.mux.ServeHTTP(w, httptest.NewRequest("GET", "/status", nil))
+	var out statusBody
+	if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Startup == nil || out.Startup.Provider.Name != "bcb" || out.Startup.Cache.Backend != "memory" {
+		t.Fatalf("unexpected startup summary %+v", out.Startup)
+	}
+	doc := servedOpenAPI(t, srv)
+	assertMatches(t, doc, doc.responseSchema(t, "/status", "GET", "200"), w)
+}
@@ -0,0 +1,384 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/thiagozs/go-exchange/internal/config"
+	"github.com/thiagozs/go-exchange/internal/exchange"
+	"github.com/thiagozs/go-exchange/internal/logger"
+)
+
+const (
+	// defaultStreamInterval is the poll interval of a stream without
+	// interval=.
+	defaultStreamInterval = 30 * time.Second
+	// streamKeepalive is how often an idle stream gets a comment line, so
+	// proxies do not drop the connection.
+	streamKeepalive = 15 * time.Second
+)
+
+// errTooManyStreams is returned by subscribe when STREAM_MAX_SUBSCRIBERS
+// streams are open.
+var errTooManyStreams = errors.New("too many open streams")
+
+// ratePair is a currency pair of a rate stream.
+type ratePair struct{ from, to string }
+
+func (p ratePair) String() string { return p.from + "-" + p.to }
+
+// rateEvent is one server-sent event.
+type rateEvent struct {
+	name string
+	data []byte
+}
+
+// rateSubscriber is an open stream. Only the latest event of each pair is
+// kept until the stream writes it, so a slow client skips intermediate rates
+// rather than blocking the pollers.
+type rateSubscriber struct {
+	pairs    []ratePair
+	interval time.Duration
+
+	mu      sync.Mutex
+	pending map[ratePair]rateEvent
+	notify  chan struct{}
+}
+
+func (sub *rateSubscriber) send(p ratePair, ev rateEvent) {
+	sub.mu.Lock()
+	sub.pending[p] = ev
+	sub.mu.Unlock()
+	select {
+	case sub.notify <- struct{}{}:
+	default:
+	}
+}
+
+// take returns the pending events ordered by pair.
+func (sub *rateSubscriber) take() []rateEvent {
+	sub.mu.Lock()
+	pending := sub.pending
+	sub.pending = map[ratePair]rateEvent{}
+	sub.mu.Unlock()
+
+	pairs := make([]ratePair, 0, len(pending))
+	for p := range pending {
+		pairs = append(pairs, p)
+	}
+	sort.Slice(pairs, func(i, j int) bool { return pairs[i].String() < pairs[j].String() })
+	events := make([]rateEvent, len(pairs))
+	for i, p := range pairs {
+		events[i] = pending[p]
+	}
+	return events
+}
+
+// ratePoller polls the rate of one pair for all of its subscribers, at the
+// shortest of their intervals.
+type ratePoller struct {
+	pair ratePair
+	subs map[*rateSubscriber]struct{}
+	// wake interrupts the wait when the subscribers change
+	wake chan struct{}
+	stop context.CancelFunc
+
+	// last is the last rate event, sent to new subscribers right away
+	last     *rateEvent
+	lastRate float64
+	// lastErr is the code of the last error event, so a failing pair reports
+	// it once
+	lastErr string
+}
+
+// rateHub serves GET /stream/rates: one background poller per subscribed
+// pair, shared by every stream of that pair and stopped with its last
+// subscriber. Polls go through the conversion service, so they are served
+// from the conversion cache while it is fresh.
+type rateHub struct {
+	svc            *exchange.Service
+	log            *logger.Logger
+	maxSubscribers int
+	epsilon        float64
+
+	ctx   context.Context
+	close context.CancelFunc
+
+	mu          sync.Mutex
+	subscribers int
+	pollers     map[ratePair]*ratePoller
+}
+
+func newRateHub(cfg *config.Config, svc *exchange.Service, lg *logger.Logger) *rateHub {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &rateHub{svc: svc, log: lg,
+		maxSubscribers: cfg.StreamMaxSubscribers,
+		epsilon:        cfg.StreamRateEpsilon,
+		ctx:            ctx, close: cancel,
+		pollers: map[ratePair]*ratePoller{},
+	}
+}
+
+// subscribe opens a stream of pairs, starting the pollers it needs.
+func (h *rateHub) subscribe(pairs []ratePair, interval time.Duration) (*rateSubscriber, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.subscribers >= h.maxSubscribers {
+		return nil, errTooManyStreams
+	}
+	h.subscribers++
+	sub := &rateSubscriber{pairs: pairs, interval: interval,
+		pending: map[ratePair]rateEvent{}, notify: make(chan struct{}, 1)}
+	for _, pair := range pairs {
+		p, ok := h.pollers[pair]
+		if !ok {
+			ctx, stop := context.WithCancel(h.ctx)
+			p = &ratePoller{pair: pair, subs: map[*rateSubscriber]struct{}{}, wake: make(chan struct{}, 1), stop: stop}
+			h.pollers[pair] = p
+			go h.poll(ctx, p)
+		}
+		p.subs[sub] = struct{}{}
+		if p.last != nil {
+			sub.send(pair, *p.last)
+		}
+		p.signal()
+	}
+	return sub, nil
+}
+
+// unsubscribe closes a stream, stopping the pollers left without
+// subscribers.
+func (h *rateHub) unsubscribe(sub *rateSubscriber) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.subscribers--
+	for _, pair := range sub.pairs {
+		p := h.pollers[pair]
+		delete(p.subs, sub)
+		if len(p.subs) == 0 {
+			p.stop()
+			delete(h.pollers, pair)
+		}
+	}
+}
+
+func (p *ratePoller) signal() {
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+}
+
+// interval is the shortest interval of the subscribers of p, or 0 once it
+// has none.
+func (h *rateHub) interval(p *ratePoller) time.Duration {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var d time.Duration
+	for sub := range p.subs {
+		if d == 0 || sub.interval < d {
+			d = sub.interval
+		}
+	}
+	return d
+}
+
+func (h *rateHub) poll(ctx context.Context, p *ratePoller) {
+	var last time.Time
+	for {
+		interval := h.interval(p)
+		if interval == 0 {
+			return
+		}
+		wait := time.Duration(0)
+		if !last.IsZero() {
+			wait = interval - time.Since(last)
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-p.wake:
+			// a subscriber joined or left: recompute the interval
+			timer.Stop()
+			continue
+		case <-timer.C:
+		}
+		last = time.Now()
+		h.refresh(ctx, p)
+	}
+}
+
+// refresh polls the rate of p and sends it to the subscribers when it moved
+// by more than STREAM_RATE_EPSILON.
+func (h *rateHub) refresh(ctx context.Context, p *ratePoller) {
+	q, err := h.svc.Rate(ctx, exchange.RateRequest{From: p.pair.from, To: p.pair.to})
+	if ctx.Err() != nil {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if err != nil {
+		var cerr *exchange.Error
+		if !errors.As(err, &cerr) {
+			cerr = exchange.Classify(p.pair.from, p.pair.to, err)
+		}
+		if p.lastErr == cerr.Code {
+			return
+		}
+		p.lastErr = cerr.Code
+		h.log.WithContext(ctx).Warnf("stream: rate %s failed code=%s: %v", p.pair, cerr.Code, cerr.Err)
+		data, _ := json.Marshal(map[string]any{"from": p.pair.from, "to": p.pair.to, "code": cerr.Code, "message": cerr.Message})
+		h.broadcast(p, rateEvent{name: "error", data: data})
+		return
+	}
+	// after an error event the rate is sent again even if it did not move
+	recovered := p.lastErr != ""
+	p.lastErr = ""
+	if !recovered && p.last != nil && math.Abs(q.Rate-p.lastRate) <= h.epsilon {
+		return
+	}
+	data, _ := json.Marshal(rateEventBody(q))
+	ev := rateEvent{name: "rate", data: data}
+	p.last, p.lastRate = &ev, q.Rate
+	h.broadcast(p, ev)
+}
+
+func (h *rateHub) broadcast(p *ratePoller, ev rateEvent) {
+	for sub := range p.subs {
+		sub.send(p.pair, ev)
+	}
+}
+
+// rateEventBody is the data of a rate event.
+func rateEventBody(q exchange.RateResponse) map[string]any {
+	out := map[string]any{"from": q.From, "to": q.To, "rate": q.Rate}
+	if !q.RateTimestamp.IsZero() {
+		out["rate_timestamp"] = q.RateTimestamp.UTC().Format(time.RFC3339)
+	}
+	if q.Source != "" {
+		out["source"] = q.Source
+	}
+	if q.Stale {
+		out["stale"] = true
+	}
+	return out
+}
+
+// streamParams are the query parameters of handleStreamRates.
+var streamParams = queryParams{"pairs", "interval"}
+
+// handleStreamRates streams rate updates for pairs=USD-BRL,EUR-BRL as
+// server-sent events: a "rate" event with the current rate of each pair once
+// it is known, then one whenever it changes, and an "error" event when a
+// pair cannot be polled. interval (default 30s, at least STREAM_MIN_INTERVAL)
+// sets how often the pairs are polled.
+func (s *Server) handleStreamRates(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	pairs, interval, ok := s.parseStreamQuery(w, r)
+	if !ok {
+		return
+	}
+	sub, err := s.streams.subscribe(pairs, interval)
+	if err != nil {
+		writeError(w, http.StatusServiceUnavailable, errCodeTooManyStreams, "too many open streams, try again later", nil)
+		return
+	}
+	defer s.streams.unsubscribe(sub)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	// nginx buffers responses unless told otherwise
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	rc := http.NewResponseController(w)
+	fmt.Fprint(w, ": stream open\n\n")
+	if err := rc.Flush(); err != nil {
+		s.log.WithContext(ctx).Warnf("stream: flushing not supported: %v", err)
+		return
+	}
+
+	keepalive := time.NewTicker(streamKeepalive)
+	defer keepalive.Stop()
+	for {
+		var err error
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.streams.ctx.Done():
+			// shutting down: close the stream so the server can stop
+			return
+		case <-keepalive.C:
+			_, err = fmt.Fprint(w, ": keepalive\n\n")
+		case <-sub.notify:
+			for _, ev := range sub.take() {
+				if _, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev.name, ev.data); err != nil {
+					break
+				}
+			}
+		}
+		if err == nil {
+			err = rc.Flush()
+		}
+		if err != nil {
+			// client gone
+			return
+		}
+	}
+}
+
+// parseStreamQuery reads pairs and interval, writing the error response when
+// they are invalid.
+func (s *Server) parseStreamQuery(w http.ResponseWriter, r *http.Request) ([]ratePair, time.Duration, bool) {
+	q := r.URL.Query()
+	if q.Get("pairs") == "" {
+		writeError(w, http.StatusBadRequest, errCodeMissingParameters, "pairs is required", nil)
+		return nil, 0, false
+	}
+	var pairs []ratePair
+	seen := map[ratePair]bool{}
+	for _, v := range strings.Split(q.Get("pairs"), ",") {
+		from, to, ok := strings.Cut(strings.TrimSpace(v), "-")
+		if !ok {
+			writeError(w, http.StatusBadRequest, errCodeInvalidParameter, fmt.Sprintf("invalid pair %q: expected FROM-TO", v), nil)
+			return nil, 0, false
+		}
+		for _, c := range []struct{ field, code string }{{"from", from}, {"to", to}} {
+			if err := exchange.ValidateCurrency(c.field, c.code); err != nil {
+				writeValidationError(w, err)
+				return nil, 0, false
+			}
+		}
+		p := ratePair{strings.ToUpper(from), strings.ToUpper(to)}
+		if !seen[p] {
+			seen[p] = true
+			pairs = append(pairs, p)
+		}
+	}
+	if len(pairs) > s.cfg.StreamMaxPairs {
+		writeError(w, http.StatusBadRequest, errCodeInvalidParameter, "too many pairs (max "+strconv.Itoa(s.cfg.StreamMaxPairs)+")", nil)
+		return nil, 0, false
+	}
+
+	interval := defaultStreamInterval
+	if v := q.Get("interval"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			writeError(w, http.StatusBadRequest, errCodeInvalidParameter, "interval must be a positive duration, e.g. 30s", nil)
+			return nil, 0, false
+		}
+		interval = d
+	}
+	return pairs, max(interval, s.cfg.StreamMinInterval), true
+}
@@ -0,0 +1,284 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/thiagozs/go-exchange/internal/config"
+	"github.com/thiagozs/go-exchange/internal/logger"
+	"github.com/thiagozs/go-exchange/internal/provider"
+)
+
+// scriptedProv returns the scripted rates in turn, then keeps the last one;
+// a zero rate fails the call.
+type scriptedProv struct {
+	mu    sync.Mutex
+	rates []float64
+	calls int
+}
+
+func (p *scriptedProv) Convert(ctx context.Context, from, to string, amount int64) (int64, error) {
+	res, err := p.ConvertDetailed(ctx, from, to, amount)
+	return res.ResultCents, err
+}
+
+func (p *scriptedProv) ConvertDetailed(ctx context.Context, from, to string, amount int64) (provider.ConvertResult, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	rate := p.rates[min(p.calls, len(p.rates)-1)]
+	p.calls++
+	if rate == 0 {
+		return provider.ConvertResult{}, errors.New("upstream down")
+	}
+	return provider.ConvertResult{ResultCents: int64(float64(amount) * rate), Rate: rate, Source: "script"}, nil
+}
+
+func (p *scriptedProv) callCount() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.calls
+}
+
+// streamRecorder is a ResponseRecorder that can be read while the handler
+// is still writing.
+type streamRecorder struct {
+	mu     sync.Mutex
+	header http.Header
+	code   int
+	buf    bytes.Buffer
+}
+
+func newStreamRecorder() *streamRecorder { return &streamRecorder{header: http.Header{}} }
+
+func (r *streamRecorder) Header() http.Header { return r.header }
+
+func (r *streamRecorder) WriteHeader(code int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.code == 0 {
+		r.code = code
+	}
+}
+
+func (r *streamRecorder) Write(b []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.code == 0 {
+		r.code = http.StatusOK
+	}
+	return r.buf.Write(b)
+}
+
+func (r *streamRecorder) Flush() {}
+
+type sseEvent struct {
+	name string
+	data map[string]any
+}
+
+// events parses the events written so far.
+func (r *streamRecorder) events(t *testing.T) []sseEvent {
+	t.Helper()
+	r.mu.Lock()
+	body := r.buf.String()
+	r.mu.Unlock()
+	var out []sseEvent
+	for _, block := range strings.Split(body, "\n\n") {
+		var ev sseEvent
+		for _, line := range strings.Split(block, "\n") {
+			if name, ok := strings.CutPrefix(line, "event: "); ok {
+				ev.name = name
+			}
+			if data, ok := strings.CutPrefix(line, "data: "); ok {
+				if err := json.Unmarshal([]byte(data), &ev.data); err != nil {
+					t.Fatalf("invalid event data %q: %v", data, err)
+				}
+			}
+		}
+		if ev.name != "" {
+			out = append(out, ev)
+		}
+	}
+	return out
+}
+
+// waitEvents waits until n events were written.
+func (r *streamRecorder) waitEvents(t *testing.T, n int) []sseEvent {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		evs := r.events(t)
+		if len(evs) >= n {
+			return evs
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d events, got %v", n, evs)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func newStreamTestServer(t *testing.T, p provider.Provider, maxSubscribers int) *Server {
+	t.Helper()
+	lg := logger.New(logger.Options{Format: "text", Level: "info", Out: &bytes.Buffer{}})
+	srv := newTestServer(t, &config.Config{HTTPAddr: ":0", StreamMaxSubscribers: maxSubscribers, StreamMaxPairs: 2,
+		StreamMinInterval: 10 * time.Millisecond, StreamRateEpsilon: 0.01}, lg)
+	// no conversion cache, so every poll reaches the provider
+	useDeps(srv, p, &stubCache{}, nil)
+	return srv
+}
+
+// openStream runs the handler until the returned function closes the
+// stream.
+func openStream(srv *Server, query string) (*streamRecorder, func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	rec := newStreamRecorder()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		srv.handleStreamRates(rec, httptest.NewRequest("GET", "/stream/rates"+query, nil).WithContext(ctx))
+	}()
+	return rec, func() { cancel(); <-done }
+}
+
+func TestRateHubLogsAsStreamComponent(t *testing.T) {
+	var buf bytes.Buffer
+	lg := logger.New(logger.Options{Format: "json", Level: "info", Out: &buf})
+	srv := newTestServer(t, &config.Config{HTTPAddr: ":0", CacheTTL: time.Minute}, lg)
+	buf.Reset()
+	srv.log.WithContext(context.Background()).Info("from http")
+	srv.streams.log.WithContext(context.Background()).Info("from stream")
+	got := map[any]any{}
+	for _, l := range jsonLogLines(t, &buf) {
+		got[l["msg"]] = l["component"]
+	}
+	if got["from http"] != "http" || got["from stream"] != "stream" {
+		t.Fatalf("expected the http and stream components, got %v", got)
+	}
+}
+
+func TestStreamRatesSendsChanges(t *testing.T) {
+	// 5.1005 is within STREAM_RATE_EPSILON of 5.1 and the failure is
+	// reported once
+	p := &scriptedProv{rates: []float64{5, 5, 5.1, 5.1005, 0, 0, 5.1, 5.3}}
+	srv := newStreamTestServer(t, p, 10)
+	rec, stop := openStream(srv, "?pairs=usd-brl&interval=20ms")
+	defer stop()
+
+	evs := rec.waitEvents(t, 5)
+	if ct := rec.header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("unexpected content type %q", ct)
+	}
+	var got []string
+	for _, ev := range evs[:5] {
+		if ev.data["from"] != "USD" || ev.data["to"] != "BRL" {
+			t.Fatalf("unexpected pair in %v", ev.data)
+		}
+		if ev.name == "error" {
+			got = append(got, ev.data["code"].(string))
+			continue
+		}
+		got = append(got, fmt.Sprint(ev.data["rate"]))
+	}
+	if want := "5,5.1,PROVIDER_ERROR,5.1,5.3"; strings.Join(got, ",") != want {
+		t.Fatalf("got events %s, want %s", strings.Join(got, ","), want)
+	}
+}
+
+func TestStreamRatesSharesPollers(t *testing.T) {
+	p := &scriptedProv{rates: []float64{5}}
+	srv := newStreamTestServer(t, p, 10)
+
+	rec1, stop1 := openStream(srv, "?pairs=USD-BRL&interval=1h")
+	rec1.waitEvents(t, 1)
+	rec2, stop2 := openStream(srv, "?pairs=USD-BRL,EUR-BRL&interval=1h")
+	// the second stream gets the known USD-BRL rate without another poll
+	evs := rec2.waitEvents(t, 2)
+	if evs[0].data["from"] != "EUR" && evs[1].data["from"] != "EUR" {
+		t.Fatalf("expected both pairs, got %v", evs)
+	}
+	if n := p.callCount(); n != 2 {
+		t.Fatalf("expected one poll per pair, got %d", n)
+	}
+	srv.streams.mu.Lock()
+	pollers, subs := len(srv.streams.pollers), srv.streams.subscribers
+	srv.streams.mu.Unlock()
+	if pollers != 2 || subs != 2 {
+		t.Fatalf("expected 2 pollers and 2 subscribers, got %d and %d", pollers, subs)
+	}
+
+	stop1()
+	stop2()
+	srv.streams.mu.Lock()
+	pollers, subs = len(srv.streams.pollers), srv.streams.subscribers
+	srv.streams.mu.Unlock()
+	if pollers != 0 || subs != 0 {
+		t.Fatalf("expected everything cleaned up, got %d pollers and %d subscribers", pollers, subs)
+	}
+}
+
+func TestStreamRatesRejects(t *testing.T) {
+	srv := newStreamTestServer(t, &scriptedProv{rates: []float64{5}}, 1)
+	_, stop := openStream(srv, "?pairs=USD-BRL&interval=1h")
+	defer stop()
+	// wait for the first stream to hold the only slot
+	for deadline := time.Now().Add(2 * time.Second); ; {
+		srv.streams.mu.Lock()
+		n := srv.streams.subscribers
+		srv.streams.mu.Unlock()
+		if n == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("stream did not open")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	cases := []struct {
+		query  string
+		status int
+		code   string
+	}{
+		{"", http.StatusBadRequest, errCodeMissingParameters},
+		{"?pairs=USDBRL", http.StatusBadRequest, errCodeInvalidParameter},
+		{"?pairs=US-BRL", http.StatusBadRequest, "INVALID_CURRENCY"},
+		{"?pairs=USD-BRL,EUR-BRL,GBP-BRL", http.StatusBadRequest, errCodeInvalidParameter},
+		{"?pairs=USD-BRL&interval=soon", http.StatusBadRequest, errCodeInvalidParameter},
+		{"?pairs=USD-BRL", http.StatusServiceUnavailable, errCodeTooManyStreams},
+	}
+	for _, tc := range cases {
+		w := httptest.NewRecorder()
+		srv.handleStreamRates(w, httptest.NewRequest("GET", "/stream/rates"+tc.query, nil))
+		if w.Code != tc.status || decodeError(t, w).Code != tc.code {
+			t.Fatalf("%s: expected %d %s, got %d %s", tc.query, tc.status, tc.code, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestStreamRatesEndOnShutdown(t *testing.T) {
+	srv := newStreamTestServer(t, &scriptedProv{rates: []float64{5}}, 10)
+	rec := newStreamRecorder()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		srv.handleStreamRates(rec, httptest.NewRequest("GET", "/stream/rates?pairs=USD-BRL&interval=1h", nil))
+	}()
+	rec.waitEvents(t, 1)
+
+	srv.streams.close()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("stream still open after shutdown")
+	}
+}
@@ -0,0 +1,58 @@
+package server
+
+// StartupSummary describes what an instance is configured to do. It is
+// logged once at startup and reported by GET /status.
+type StartupSummary struct {
+	Version  string          `json:"version"`
+	Commit   string          `json:"commit"`
+	Provider SummaryProvider `json:"provider"`
+	Cache    SummaryCache    `json:"cache"`
+	// FeeSource is the fee provider in use: api, tiers, pairs, percent or
+	// none.
+	FeeSource     string            `json:"fee_source"`
+	OTelExporters []SummaryExporter `json:"otel_exporters"`
+	HTTPAddr      string            `json:"http_addr"`
+	GRPCAddr      string            `json:"grpc_addr,omitempty"`
+	// Features are the optional middlewares and features turned on.
+	Features []string `json:"features"`
+	Warnings []string `json:"warnings"`
+}
+
+// SummaryProvider is the EXCHANGE_PROVIDER of a StartupSummary.
+type SummaryProvider struct {
+	Name    string   `json:"name"`
+	BaseURL string   `json:"base_url,omitempty"`
+	Sources []string `json:"sources,omitempty"` // AGGREGATE_SOURCES
+}
+
+// SummaryCache is the cache of a StartupSummary, with credentials redacted.
+type SummaryCache struct {
+	Backend string `json:"backend"` // redis or memory
+	Addr    string `json:"addr,omitempty"`
+	// Degraded is set when REDIS_STARTUP=optional fell back to memory.
+	Degraded bool `json:"degraded,omitempty"`
+}
+
+// SummaryExporter is an OTel exporter of a StartupSummary. Only the header
+// names are kept, their values are often credentials.
+type SummaryExporter struct {
+	Type        string   `json:"type"`
+	Endpoint    string   `json:"endpoint,omitempty"`
+	Protocol    string   `json:"protocol,omitempty"`
+	Insecure    bool     `json:"insecure"`
+	Compression string   `json:"compression,omitempty"`
+	Headers     []string `json:"headers,omitempty"`
+}
+
+// SetStartupSummary records sum for GET /status, with the cache backend
+// actually opened, and returns it. It must be called before Run.
+func (s *Server) SetStartupSummary(sum StartupSummary) StartupSummary {
+	if s.backend.Cache != "" {
+		sum.Cache.Backend, sum.Cache.Degraded = s.backend.Cache, s.backend.Degraded
+	}
+	if sum.Cache.Backend == "memory" && !sum.Cache.Degraded {
+		sum.Cache.Addr = ""
+	}
+	s.summary = &sum
+	return sum
+}
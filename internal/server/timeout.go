@@ -0,0 +1,62 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/thiagozs/go-exchange/internal/exchange"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// timeoutExempt are the routes REQUEST_TIMEOUT does not apply to: rate
+// streams stay open for as long as the client listens.
+var timeoutExempt = map[string]bool{
+	"/stream/rates": true,
+}
+
+// headerTracker records whether the handler started its response.
+type headerTracker struct {
+	http.ResponseWriter
+	wrote bool
+}
+
+func (t *headerTracker) WriteHeader(code int) {
+	t.wrote = true
+	t.ResponseWriter.WriteHeader(code)
+}
+
+func (t *headerTracker) Write(b []byte) (int, error) {
+	t.wrote = true
+	return t.ResponseWriter.Write(b)
+}
+
+func (t *headerTracker) Unwrap() http.ResponseWriter {
+	return t.ResponseWriter
+}
+
+// withTimeout gives the route registered as pattern a REQUEST_TIMEOUT
+// budget. Providers and the fee API honour it through the request context,
+// and a handler that gives up on it without answering gets 504. What is left
+// of the budget is recorded on the request span.
+func (s *Server) withTimeout(pattern string, next http.HandlerFunc) http.HandlerFunc {
+	if s.cfg.RequestTimeout <= 0 || timeoutExempt[pattern] {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), s.cfg.RequestTimeout)
+		defer cancel()
+		tw := &headerTracker{ResponseWriter: w}
+		next(tw, r.WithContext(ctx))
+
+		deadline, _ := ctx.Deadline()
+		trace.SpanFromContext(ctx).SetAttributes(
+			attribute.Int64("http.request.budget_remaining_ms", max(0, time.Until(deadline).Milliseconds())))
+		if !tw.wrote && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			s.log.WithContext(ctx).Warnf("request %s timed out after %v", r.URL.Path, s.cfg.RequestTimeout)
+			writeError(w, http.StatusGatewayTimeout, exchange.CodeTimeout, "request timed out", nil)
+		}
+	}
+}
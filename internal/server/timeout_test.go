@@ -0,0 +1,81 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/thiagozs/go-exchange/internal/config"
+	"github.com/thiagozs/go-exchange/internal/exchange"
+	"github.com/thiagozs/go-exchange/internal/logger"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// hangingProv answers only once ctx is done, like a provider whose upstream
+// stopped responding.
+type hangingProv struct{}
+
+func (hangingProv) Convert(ctx context.Context, from, to string, amount int64) (int64, error) {
+	<-ctx.Done()
+	return 0, ctx.Err()
+}
+
+func TestRequestTimeoutAnswers504(t *testing.T) {
+	exp := tracetest.NewInMemoryExporter()
+	useProviders(t, sdktrace.NewTracerProvider(sdktrace.WithSyncer(exp)), sdkmetric.NewMeterProvider())
+
+	lg := logger.New(logger.Options{Format: "text", Level: "info", Out: &bytes.Buffer{}})
+	srv := newTestServer(t, &config.Config{HTTPAddr: ":0", CacheTTL: time.Minute, RequestTimeout: 50 * time.Millisecond}, lg)
+	useDeps(srv, hangingProv{}, newMemCache(), nil)
+
+	start := time.Now()
+	w := httptest.NewRecorder()
+	srv.instrumentHandler(srv.withTimeout("/convert", srv.handleConvert))(w,
+		httptest.NewRequest("GET", "/convert?from=USD&to=BRL&amount=1000", nil))
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("request took %v with a 50ms budget", elapsed)
+	}
+	if w.Code != http.StatusGatewayTimeout || decodeError(t, w).Code != exchange.CodeTimeout {
+		t.Fatalf("expected 504 %s, got %d: %s", exchange.CodeTimeout, w.Code, w.Body)
+	}
+
+	spans := exp.GetSpans()
+	if len(spans) == 0 {
+		t.Fatalf("no span recorded")
+	}
+	var found bool
+	for _, kv := range spans[len(spans)-1].Attributes {
+		if kv.Key == "http.request.budget_remaining_ms" {
+			found = kv.Value.AsInt64() == 0
+		}
+	}
+	if !found {
+		t.Fatalf("expected an exhausted budget on the span, got %v", spans[len(spans)-1].Attributes)
+	}
+}
+
+func TestRequestTimeoutWithoutAnswer(t *testing.T) {
+	lg := logger.New(logger.Options{Format: "text", Level: "info", Out: &bytes.Buffer{}})
+	srv := newTestServer(t, &config.Config{HTTPAddr: ":0", RequestTimeout: 20 * time.Millisecond}, lg)
+
+	// a handler that gives up without writing anything
+	w := httptest.NewRecorder()
+	srv.withTimeout("/currencies", func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	})(w, httptest.NewRequest("GET", "/currencies", nil))
+	if w.Code != http.StatusGatewayTimeout || decodeError(t, w).Code != exchange.CodeTimeout {
+		t.Fatalf("expected 504 %s, got %d: %s", exchange.CodeTimeout, w.Code, w.Body)
+	}
+
+	// streams have no budget
+	srv.withTimeout("/stream/rates", func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.Context().Deadline(); ok {
+			t.Fatalf("unexpected deadline on /stream/rates")
+		}
+	})(httptest.NewRecorder(), httptest.NewRequest("GET", "/stream/rates", nil))
+}
@@ -0,0 +1,122 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/thiagozs/go-exchange/internal/exchange"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// timingTotal is the whole request in Server-Timing and the
+// exchange.total_ms span attribute, next to the exchange.Phase* dependencies.
+const timingTotal = "total"
+
+// timingRecorder accumulates per-dependency durations for one request. It is
+// finalized right before the response header is written, so the breakdown
+// can be sent as a Server-Timing header and mirrored on the request span.
+type timingRecorder struct {
+	start time.Time
+	span  trace.Span
+
+	mu        sync.Mutex
+	durs      map[string]time.Duration
+	order     []string
+	finalized bool
+}
+
+type timingCtxKey struct{}
+
+func newTimingRecorder(start time.Time, span trace.Span) *timingRecorder {
+	return &timingRecorder{start: start, span: span, durs: map[string]time.Duration{}}
+}
+
+// withTiming stores t in ctx, also as the exchange.Timer of the request's
+// conversions.
+func withTiming(ctx context.Context, t *timingRecorder) context.Context {
+	return exchange.WithTimer(context.WithValue(ctx, timingCtxKey{}, t), t)
+}
+
+// timingFrom returns the request's recorder; nil (a no-op recorder) when the
+// handler runs without instrumentHandler.
+func timingFrom(ctx context.Context) *timingRecorder {
+	t, _ := ctx.Value(timingCtxKey{}).(*timingRecorder)
+	return t
+}
+
+// Track starts timing name and returns the function that stops it. It
+// implements exchange.Timer.
+func (t *timingRecorder) Track(name string) func() {
+	if t == nil {
+		return func() {}
+	}
+	start := time.Now()
+	return func() { t.add(name, time.Since(start)) }
+}
+
+func (t *timingRecorder) add(name string, d time.Duration) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.finalized {
+		return
+	}
+	if _, ok := t.durs[name]; !ok {
+		t.order = append(t.order, name)
+	}
+	t.durs[name] += d
+}
+
+// finalize records the total, annotates the span and, when emit is set, adds
+// the Server-Timing header. Later calls are no-ops.
+func (t *timingRecorder) finalize(h http.Header, emit bool) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	if t.finalized {
+		t.mu.Unlock()
+		return
+	}
+	t.finalized = true
+	t.order = append(t.order, timingTotal)
+	t.durs[timingTotal] = time.Since(t.start)
+	t.mu.Unlock()
+
+	entries := t.entries()
+	attrs := make([]attribute.KeyValue, 0, len(entries))
+	parts := make([]string, 0, len(entries))
+	for _, e := range entries {
+		attrs = append(attrs, attribute.Float64("exchange."+e.name+"_ms", e.ms))
+		parts = append(parts, fmt.Sprintf("%s;dur=%.3f", e.name, e.ms))
+	}
+	if t.span != nil {
+		t.span.SetAttributes(attrs...)
+	}
+	if emit {
+		h.Set("Server-Timing", strings.Join(parts, ", "))
+	}
+}
+
+type timingEntry struct {
+	name string
+	ms   float64
+}
+
+// entries returns the recorded durations in milliseconds, in first-seen order.
+func (t *timingRecorder) entries() []timingEntry {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	out := make([]timingEntry, 0, len(t.order))
+	for _, name := range t.order {
+		out = append(out, timingEntry{name: name, ms: float64(t.durs[name]) / float64(time.Millisecond)})
+	}
+	return out
+}
@@ -0,0 +1,103 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/thiagozs/go-exchange/internal/config"
+	"github.com/thiagozs/go-exchange/internal/exchange"
+	"github.com/thiagozs/go-exchange/internal/fee"
+	"github.com/thiagozs/go-exchange/internal/logger"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// slowProv takes a fixed time to answer.
+type slowProv struct{ delay time.Duration }
+
+func (p *slowProv) Convert(ctx context.Context, from, to string, amount int64) (int64, error) {
+	time.Sleep(p.delay)
+	return 20000, nil
+}
+
+// parseServerTiming parses "name;dur=1.234, ..." into durations in ms.
+func parseServerTiming(t *testing.T, v string) map[string]float64 {
+	t.Helper()
+	out := map[string]float64{}
+	for metric := range strings.SplitSeq(v, ",") {
+		name, dur, ok := strings.Cut(strings.TrimSpace(metric), ";dur=")
+		if !ok {
+			t.Fatalf("malformed Server-Timing entry %q", metric)
+		}
+		ms, err := strconv.ParseFloat(dur, 64)
+		if err != nil {
+			t.Fatalf("malformed duration in %q: %v", metric, err)
+		}
+		out[name] = ms
+	}
+	return out
+}
+
+func TestServerTimingMatchesRecorder(t *testing.T) {
+	exp := tracetest.NewInMemoryExporter()
+	useProviders(t, sdktrace.NewTracerProvider(sdktrace.WithSyncer(exp)), sdkmetric.NewMeterProvider())
+
+	lg := logger.New(logger.Options{Format: "text", Level: "info", Out: &bytes.Buffer{}})
+	srv := newTestServer(t, &config.Config{HTTPAddr: ":0", CacheTTL: time.Minute, ServerTiming: true}, lg)
+	useDeps(srv, &slowProv{delay: 20 * time.Millisecond}, newMemCache(), fee.NewEnvFeeProviderWithPercent(0.01))
+
+	var rec *timingRecorder
+	h := srv.instrumentHandler(func(w http.ResponseWriter, r *http.Request) {
+		rec = timingFrom(r.Context())
+		srv.handleConvert(w, r)
+	})
+	w := httptest.NewRecorder()
+	h(w, httptest.NewRequest("GET", "/convert?from=USD&to=BRL&amount=1000", nil))
+
+	header := w.Result().Header.Get("Server-Timing")
+	got := parseServerTiming(t, header)
+	entries := rec.entries()
+	if len(entries) != 4 || len(got) != 4 {
+		t.Fatalf("expected cache, provider, fee and total; header=%q recorder=%v", header, entries)
+	}
+	for _, e := range entries {
+		if math.Abs(got[e.name]-e.ms) > 0.001 {
+			t.Fatalf("%s: header %.3fms != recorder %.3fms", e.name, got[e.name], e.ms)
+		}
+	}
+	if got[exchange.PhaseProvider] < 20 || got[timingTotal] < got[exchange.PhaseProvider] {
+		t.Fatalf("implausible timings %v", got)
+	}
+
+	spans := exp.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("expected one span, got %d", len(spans))
+	}
+	attrs := map[string]float64{}
+	for _, kv := range spans[0].Attributes {
+		attrs[string(kv.Key)] = kv.Value.AsFloat64()
+	}
+	for _, e := range entries {
+		if attrs["exchange."+e.name+"_ms"] != e.ms {
+			t.Fatalf("span attribute exchange.%s_ms = %v, want %v", e.name, attrs["exchange."+e.name+"_ms"], e.ms)
+		}
+	}
+}
+
+func TestServerTimingDisabled(t *testing.T) {
+	lg := logger.New(logger.Options{Format: "text", Level: "info", Out: &bytes.Buffer{}})
+	srv := newTestServer(t, &config.Config{HTTPAddr: ":0"}, lg)
+	w := httptest.NewRecorder()
+	srv.instrumentHandler(srv.handleHealth)(w, httptest.NewRequest("GET", "/health", nil))
+	if v := w.Result().Header.Get("Server-Timing"); v != "" {
+		t.Fatalf("expected no Server-Timing header, got %q", v)
+	}
+}
@@ -0,0 +1,17 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/thiagozs/go-exchange/internal/exchange"
+)
+
+// validationStatus is the HTTP status for a rejected /convert input.
+func validationStatus(err error) int {
+	var verr *exchange.ValidationError
+	if errors.As(err, &verr) && verr.Code == exchange.CodeAmountTooLarge {
+		return http.StatusUnprocessableEntity
+	}
+	return http.StatusBadRequest
+}
@@ -0,0 +1,58 @@
+package server
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/thiagozs/go-exchange/internal/config"
+	"github.com/thiagozs/go-exchange/internal/logger"
+)
+
+func TestHandleConvertAmountValidation(t *testing.T) {
+	lg := logger.New(logger.Options{Format: "text", Level: "info", Out: &bytes.Buffer{}})
+	srv := newTestServer(t, &config.Config{HTTPAddr: ":0", MaxAmountCents: 1000000}, lg)
+	p := &countingProv{}
+	useDeps(srv, p, nil, nil)
+
+	cases := []struct {
+		query  string
+		status int
+		msg    string
+	}{
+		{"from=USD&to=BRL&amount=0", http.StatusBadRequest, "amount must be positive"},
+		{"from=USD&to=BRL&amount=0.00", http.StatusBadRequest, "amount must be positive"},
+		{"from=USD&to=BRL&amount=-500", http.StatusBadRequest, "amount must be positive"},
+		{"from=USD&to=BRL&amount=10.001", http.StatusBadRequest, "more than 2 decimal places for USD"},
+		{"from=JPY&to=BRL&amount=10.5", http.StatusBadRequest, "more than 0 decimal places for JPY"},
+		{"from=USD&to=BRL&amount=1e3", http.StatusBadRequest, "invalid amount"},
+		{"from=USD&to=BRL&amount=1.5e3", http.StatusBadRequest, "invalid amount"},
+		{"from=USD&to=BRL&amount=1000001", http.StatusUnprocessableEntity, "exceeds the maximum of 1000000 cents"},
+		{"from=USD&to=BRL&amount=10000.01", http.StatusUnprocessableEntity, "exceeds the maximum"},
+	}
+	for _, tc := range cases {
+		w := httptest.NewRecorder()
+		srv.handleConvert(w, httptest.NewRequest("GET", "/convert?"+tc.query, nil))
+		if w.Code != tc.status || !strings.Contains(w.Body.String(), tc.msg) {
+			t.Errorf("%s: expected %d %q, got %d %q", tc.query, tc.status, tc.msg, w.Code, w.Body.String())
+		}
+	}
+	if p.calls != 0 {
+		t.Fatalf("expected rejected amounts not to reach the provider, got %d calls", p.calls)
+	}
+
+	for _, query := range []string{
+		"from=USD&to=BRL&amount=1000000",
+		"from=USD&to=BRL&amount=10.50",
+		"from=USD&to=BRL&amount=10.500", // trailing zeros add no precision
+		"from=JPY&to=BRL&amount=10.0",
+	} {
+		w := httptest.NewRecorder()
+		srv.handleConvert(w, httptest.NewRequest("GET", "/convert?"+query, nil))
+		if w.Code != http.StatusOK {
+			t.Errorf("%s: expected 200, got %d %q", query, w.Code, w.Body.String())
+		}
+	}
+}
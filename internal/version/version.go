@@ -0,0 +1,53 @@
+// Package version reports the running build. Version, Commit and BuildDate
+// are injected at build time:
+//
+//	go build -ldflags "-X github.com/thiagozs/go-exchange/internal/version.Version=v1.2.3 \
+//	  -X github.com/thiagozs/go-exchange/internal/version.Commit=$(git rev-parse HEAD) \
+//	  -X github.com/thiagozs/go-exchange/internal/version.BuildDate=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+package version
+
+import (
+	"runtime"
+
+	"github.com/thiagozs/go-exchange/internal/config"
+)
+
+// Set via -ldflags. An empty Version falls back to APP_VERSION.
+var (
+	Version   = ""
+	Commit    = "unknown"
+	BuildDate = "unknown"
+)
+
+// defaultApp names the application when no configuration is available.
+const defaultApp = "go-exchange"
+
+// Info describes the running build.
+type Info struct {
+	App       string `json:"app"`
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildDate string `json:"build_date"`
+	GoVersion string `json:"go_version"`
+	Provider  string `json:"provider,omitempty"`
+}
+
+// Get returns the build info. cfg supplies the application name, the
+// fallback version and the exchange provider; it may be nil.
+func Get(cfg *config.Config) Info {
+	info := Info{App: defaultApp, Version: Version, Commit: Commit,
+		BuildDate: BuildDate, GoVersion: runtime.Version()}
+	if cfg != nil {
+		if cfg.AppName != "" {
+			info.App = cfg.AppName
+		}
+		if info.Version == "" {
+			info.Version = cfg.AppVersion
+		}
+		info.Provider = cfg.Provider
+	}
+	if info.Version == "" {
+		info.Version = "dev"
+	}
+	return info
+}
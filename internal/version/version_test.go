@@ -0,0 +1,31 @@
+package version
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/thiagozs/go-exchange/internal/config"
+)
+
+func TestGetDefaults(t *testing.T) {
+	got := Get(nil)
+	want := Info{App: "go-exchange", Version: "dev", Commit: "unknown", BuildDate: "unknown", GoVersion: runtime.Version()}
+	if got != want {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+
+	got = Get(&config.Config{AppName: "fx", AppVersion: "1.0.0", Provider: "bcb"})
+	if got.App != "fx" || got.Version != "1.0.0" || got.Provider != "bcb" {
+		t.Fatalf("config not applied: %+v", got)
+	}
+}
+
+func TestGetPrefersInjectedVersion(t *testing.T) {
+	old := Version
+	Version = "v1.2.3"
+	defer func() { Version = old }()
+
+	if got := Get(&config.Config{AppVersion: "1.0.0"}); got.Version != "v1.2.3" {
+		t.Fatalf("expected the ldflags version, got %q", got.Version)
+	}
+}
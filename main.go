@@ -1,11 +1,13 @@
 package main
 
 import (
+	"os"
+
 	"github.com/thiagozs/go-exchange/cmd"
 )
 
 func main() {
 	if err := cmd.Execute(); err != nil {
-		panic(err)
+		os.Exit(cmd.ExitCode(err))
 	}
 }